@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// relationAreaOffset converts an OSM relation ID to its Overpass area ID
+const relationAreaOffset = 3600000000
+
+// defaultRegionAdminLevel is used for --region when no --admin-level is given (counties/states in most countries)
+const defaultRegionAdminLevel = 4
+
+// AreaSelector chooses the area a run processes. The zero value selects the
+// country by name at admin_level=2.
+type AreaSelector struct {
+	// BBox restricts queries to a rectangle
+	BBox *BoundingBox
+	// RelationID selects the area of any boundary relation (county, national park, ...)
+	RelationID int64
+	// Region selects an administrative boundary by name inside the country
+	Region string
+	// AdminLevel is the admin_level of Region
+	AdminLevel int
+	// CountryCode selects the country by its ISO3166-1 tag instead of its name
+	CountryCode string
+}
+
+// IsCountry reports whether the selector targets the whole country
+func (a AreaSelector) IsCountry() bool {
+	return a.BBox == nil && a.RelationID == 0 && a.Region == ""
+}
+
+// regionAdminLevel returns the admin_level used for Region
+func (a AreaSelector) regionAdminLevel() int {
+	if a.AdminLevel == 0 {
+		return defaultRegionAdminLevel
+	}
+	return a.AdminLevel
+}
+
+// Validate checks that at most one area option is set
+func (a AreaSelector) Validate() error {
+	set := 0
+	for _, isSet := range []bool{a.BBox != nil, a.RelationID != 0, a.Region != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set > 1 {
+		return fmt.Errorf("--bbox, --area-relation-id and --region cannot be combined")
+	}
+	if a.RelationID < 0 {
+		return fmt.Errorf("invalid relation ID %d", a.RelationID)
+	}
+	if a.AdminLevel != 0 && a.Region == "" {
+		return fmt.Errorf("--admin-level requires --region")
+	}
+	if a.AdminLevel != 0 && (a.AdminLevel < 3 || a.AdminLevel > 11) {
+		return fmt.Errorf("invalid admin level %d (expected 3-11)", a.AdminLevel)
+	}
+	if a.CountryCode != "" && !isCountryCode(a.CountryCode) {
+		return fmt.Errorf("invalid country code %q (expected ISO3166-1 alpha-2, e.g. RO)", a.CountryCode)
+	}
+	return nil
+}
+
+// Describe returns a human-readable name for the selected area
+func (a AreaSelector) Describe(country string) string {
+	switch {
+	case a.BBox != nil:
+		return fmt.Sprintf("bbox %s", a.BBox)
+	case a.RelationID != 0:
+		return fmt.Sprintf("relation %d", a.RelationID)
+	case a.Region != "":
+		return fmt.Sprintf("%s (admin_level %d), %s", a.Region, a.regionAdminLevel(), country)
+	}
+	return country
+}
+
+// LedgerKey returns the run ledger key. Partial areas keep their own state so
+// that they do not advance the country's incremental baseline.
+func (a AreaSelector) LedgerKey(country string) string {
+	switch {
+	case a.BBox != nil:
+		return "bbox:" + a.BBox.String()
+	case a.RelationID != 0:
+		return fmt.Sprintf("relation:%d", a.RelationID)
+	case a.Region != "":
+		return fmt.Sprintf("region:%s/%d/%s", country, a.regionAdminLevel(), a.Region)
+	}
+	return country
+}
+
+// isCountryCode reports whether a value is an upper-case ISO3166-1 alpha-2 code
+func isCountryCode(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// NormalizeCountryCode upper-cases and trims a country code
+func NormalizeCountryCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// countryArea returns the Overpass area selector of the country, by ISO code when set
+func (a AreaSelector) countryArea(country string) string {
+	if a.CountryCode != "" {
+		return "area" + overpassTagFilter("ISO3166-1", a.CountryCode) + `["admin_level"="2"]`
+	}
+	return "area" + overpassTagFilter("name", country) + `["admin_level"="2"]`
+}
+
+// overpassFilter returns the statement that defines the search area and the
+// filter that restricts element statements to it
+func (a AreaSelector) overpassFilter(country string) (setup, filter string) {
+	switch {
+	case a.BBox != nil:
+		return "", fmt.Sprintf("(%s)", a.BBox)
+	case a.RelationID != 0:
+		return fmt.Sprintf("area(id:%d)->.country;\n", relationAreaOffset+a.RelationID), "(area.country)"
+	case a.Region != "":
+		// Look the region up inside the country so that equally named regions elsewhere are not matched
+		return fmt.Sprintf(`%s->.parent;
+rel["boundary"="administrative"]["admin_level"="%d"]%s(area.parent);
+map_to_area->.country;
+`, a.countryArea(country), a.regionAdminLevel(), overpassTagFilter("name", a.Region)), "(area.country)"
+	}
+	return a.countryArea(country) + "->.country;\n", "(area.country)"
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAreaSelector(t *testing.T) {
+	bbox := &BoundingBox{MinLat: 45.2, MinLon: 22.5, MaxLat: 47.8, MaxLon: 26.5}
+
+	tests := []struct {
+		name        string
+		area        AreaSelector
+		setup       string
+		filter      string
+		ledgerKey   string
+		description string
+	}{
+		{
+			name:        "Country",
+			area:        AreaSelector{},
+			setup:       `area["name"="România"]["admin_level"="2"]->.country;`,
+			filter:      "(area.country)",
+			ledgerKey:   "România",
+			description: "România",
+		},
+		{
+			name:        "BBox",
+			area:        AreaSelector{BBox: bbox},
+			setup:       "",
+			filter:      "(45.2,22.5,47.8,26.5)",
+			ledgerKey:   "bbox:45.2,22.5,47.8,26.5",
+			description: "bbox 45.2,22.5,47.8,26.5",
+		},
+		{
+			name:        "Relation",
+			area:        AreaSelector{RelationID: 123456},
+			setup:       "area(id:3600123456)->.country;",
+			filter:      "(area.country)",
+			ledgerKey:   "relation:123456",
+			description: "relation 123456",
+		},
+		{
+			name: "Region",
+			area: AreaSelector{Region: "Județul Cluj"},
+			setup: `area["name"="România"]["admin_level"="2"]->.parent;
+rel["boundary"="administrative"]["admin_level"="4"]["name"="Județul Cluj"](area.parent);
+map_to_area->.country;`,
+			filter:      "(area.country)",
+			ledgerKey:   "region:România/4/Județul Cluj",
+			description: "Județul Cluj (admin_level 4), România",
+		},
+		{
+			name:        "Country code",
+			area:        AreaSelector{CountryCode: "RO"},
+			setup:       `area["ISO3166-1"="RO"]["admin_level"="2"]->.country;`,
+			filter:      "(area.country)",
+			ledgerKey:   "România",
+			description: "România",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setup, filter := tt.area.overpassFilter("România")
+			if strings.TrimSpace(setup) != tt.setup {
+				t.Errorf("Expected setup %q, got %q", tt.setup, setup)
+			}
+			if filter != tt.filter {
+				t.Errorf("Expected filter %q, got %q", tt.filter, filter)
+			}
+			if key := tt.area.LedgerKey("România"); key != tt.ledgerKey {
+				t.Errorf("Expected ledger key %q, got %q", tt.ledgerKey, key)
+			}
+			if desc := tt.area.Describe("România"); desc != tt.description {
+				t.Errorf("Expected description %q, got %q", tt.description, desc)
+			}
+		})
+	}
+}
+
+func TestAreaSelectorValidate(t *testing.T) {
+	bbox := &BoundingBox{MinLat: 45.2, MinLon: 22.5, MaxLat: 47.8, MaxLon: 26.5}
+
+	if err := (AreaSelector{BBox: bbox, RelationID: 1}).Validate(); err == nil {
+		t.Error("Expected error when combining bbox and relation")
+	}
+	if err := (AreaSelector{RelationID: -1}).Validate(); err == nil {
+		t.Error("Expected error for negative relation ID")
+	}
+	if err := (AreaSelector{AdminLevel: 4}).Validate(); err == nil {
+		t.Error("Expected error for --admin-level without --region")
+	}
+	if err := (AreaSelector{Region: "Cluj", RelationID: 1}).Validate(); err == nil {
+		t.Error("Expected error when combining region and relation")
+	}
+	if err := (AreaSelector{Region: "Cluj", AdminLevel: 6}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := (AreaSelector{CountryCode: "ROU"}).Validate(); err == nil {
+		t.Error("Expected error for three-letter country code")
+	}
+	if err := (AreaSelector{CountryCode: NormalizeCountryCode(" ro ")}).Validate(); err != nil {
+		t.Errorf("Unexpected error for normalized code: %v", err)
+	}
+	if err := (AreaSelector{RelationID: 1}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultAuditReportFile is the CSV report written by --audit
+const DefaultAuditReportFile = "output/audit_report.csv"
+
+// DefaultAuditThreshold is the difference in metres between ele and the DEM that is reported
+const DefaultAuditThreshold = 50.0
+
+// Audit issues reported for an element
+const (
+	AuditIssueDifference  = "difference"
+	AuditIssueUnparsable  = "unparsable_ele"
+	AuditIssueNoElevation = "no_dem_elevation"
+)
+
+// AuditOptions configures the audit of existing ele tags
+type AuditOptions struct {
+	Country   string
+	Area      AreaSelector
+	Threshold float64
+	Limit     int // elements per category, 0 for all
+	Workspace Workspace
+}
+
+// AuditFinding is an element whose ele tag needs manual QA
+type AuditFinding struct {
+	Category   string
+	Element    OSMElement
+	Issue      string
+	OSMEle     *float64
+	DEMEle     *float64
+	Difference float64
+	Provider   string
+}
+
+// parseEleTag reads an ele value in metres, accepting an optional "m" unit
+func parseEleTag(value string) (float64, bool) {
+	value = strings.TrimSpace(value)
+	value = strings.TrimSpace(strings.TrimSuffix(value, "m"))
+	ele, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(ele) || math.IsInf(ele, 0) {
+		return 0, false
+	}
+	return ele, true
+}
+
+// compareElevations reports elements whose ele differs from the DEM by more than threshold,
+// or that could not be compared. fetched holds the DEM elements keyed by elementKey.
+func compareElevations(category string, elements []OSMElement, fetched map[string]OSMElement, threshold float64) []AuditFinding {
+	var findings []AuditFinding
+	for _, element := range elements {
+		osmEle, ok := parseEleTag(element.Tags["ele"])
+		if !ok {
+			findings = append(findings, AuditFinding{Category: category, Element: element, Issue: AuditIssueUnparsable})
+			continue
+		}
+		dem, ok := fetched[elementKey(element.Type, element.ID)]
+		if !ok || dem.ElevationFetched == nil {
+			findings = append(findings, AuditFinding{Category: category, Element: element, Issue: AuditIssueNoElevation, OSMEle: &osmEle})
+			continue
+		}
+		difference := *dem.ElevationFetched - osmEle
+		if math.Abs(difference) > threshold {
+			findings = append(findings, AuditFinding{
+				Category:   category,
+				Element:    element,
+				Issue:      AuditIssueDifference,
+				OSMEle:     &osmEle,
+				DEMEle:     dem.ElevationFetched,
+				Difference: difference,
+				Provider:   dem.ElevationProvider,
+			})
+		}
+	}
+	return findings
+}
+
+// sortAuditFindings puts the largest differences first, followed by elements that could not be compared
+func sortAuditFindings(findings []AuditFinding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if (a.Issue == AuditIssueDifference) != (b.Issue == AuditIssueDifference) {
+			return a.Issue == AuditIssueDifference
+		}
+		return math.Abs(a.Difference) > math.Abs(b.Difference)
+	})
+}
+
+// WriteAuditReport writes the findings as CSV
+func WriteAuditReport(path string, findings []AuditFinding) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create audit report: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	header := []string{"category", "type", "id", "name", "lat", "lon", "osm_ele", "dem_ele", "difference", "provider", "issue", "osm_link"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write audit report: %v", err)
+	}
+
+	exporter := NewCSVExporter()
+	for _, finding := range findings {
+		info := exporter.getElementInfo(finding.Element, finding.Category)
+		osmEle, demEle, difference := finding.Element.Tags["ele"], "", ""
+		if finding.DEMEle != nil {
+			demEle = fmt.Sprintf("%.1f", *finding.DEMEle)
+			difference = fmt.Sprintf("%+.1f", finding.Difference)
+		}
+		record := []string{
+			info.Category, info.Type, info.ID, info.Name, info.Lat, info.Lon,
+			osmEle, demEle, difference, finding.Provider, finding.Issue, info.OSMLink,
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write audit report: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write audit report: %v", err)
+	}
+	return nil
+}
+
+// runAudit extracts elements that already have ele, looks them up in the DEM and reports
+// discrepancies above the threshold for manual QA. Nothing is uploaded.
+func runAudit(ctx context.Context, opts AuditOptions) error {
+	pipelineLog.Banner("AUDIT - Comparing existing ele tags in %s with the DEM", opts.Area.Describe(opts.Country))
+
+	config := NewConfig()
+	config.LoadFromEnv()
+	config.Set("COUNTRY", opts.Country)
+	logger := NewLogger("Audit")
+	factory := NewAPIClientFactory(config, logger)
+
+	extractor := factory.CreateOverpassExtractor()
+	extractor.Area = opts.Area
+	extractor.WithEle = true
+	data, err := extractor.GetAllData(ctx)
+	if err != nil {
+		return err
+	}
+
+	filter := NewElevationFilter()
+	tagged := filter.FilterExisting(data)
+	if filter.Excluded > 0 {
+		pipelineLog.Info("Skipped %d elements matching the profile exclude rules", filter.Excluded)
+	}
+
+	enricher := factory.CreateBatchElevationEnricher("opentopo")
+	chain, err := factory.CreateElevationProviderChain()
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+	enricher.Provider = chain
+	if cache := sharedElevationCache(); cache != nil {
+		enricher.Provider = NewCachedElevationProvider(cache, chain.Source(), chain)
+	}
+	if names := chain.Names(); len(names) == 1 && names[0] == ProviderHGT {
+		enricher.RateLimit = 0
+	}
+
+	var findings []AuditFinding
+	audited := 0
+	for _, cat := range activeProfile().Categories {
+		elements := *tagged.Category(cat.Key)
+		if opts.Limit > 0 && len(elements) > opts.Limit {
+			elements = elements[:opts.Limit]
+		}
+		if len(elements) == 0 {
+			continue
+		}
+		pipelineLog.Info("Looking up DEM elevation for %d %s...", len(elements), strings.ToLower(cat.Label))
+
+		// The enricher returns copies with new tags, so elements keep their ele for the comparison
+		results, err := enricher.EnrichElementsBatch(ctx, elements, 0)
+		if err != nil {
+			return fmt.Errorf("audit interrupted: %v", err)
+		}
+		fetched := make(map[string]OSMElement, len(results))
+		for _, element := range results {
+			fetched[elementKey(element.Type, element.ID)] = element
+		}
+
+		audited += len(elements)
+		findings = append(findings, compareElevations(cat.Key, elements, fetched, opts.Threshold)...)
+	}
+	sortAuditFindings(findings)
+
+	reportFile := opts.Workspace.File(DefaultAuditReportFile)
+	if err := WriteAuditReport(reportFile, findings); err != nil {
+		return err
+	}
+
+	counts := make(map[string]int)
+	for _, finding := range findings {
+		counts[finding.Issue]++
+	}
+	pipelineLog.Info("✓ Audited %d elements with ele", audited)
+	pipelineLog.Info("✓ Differences above %.0f m: %d", opts.Threshold, counts[AuditIssueDifference])
+	if counts[AuditIssueUnparsable] > 0 {
+		pipelineLog.Info("✓ Unparsable ele values: %d", counts[AuditIssueUnparsable])
+	}
+	if counts[AuditIssueNoElevation] > 0 {
+		pipelineLog.Info("✓ Without DEM elevation: %d", counts[AuditIssueNoElevation])
+	}
+	pipelineLog.Info("✓ Audit report saved to %s (nothing was uploaded)", reportFile)
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEleTag(t *testing.T) {
+	tests := []struct {
+		value string
+		want  float64
+		ok    bool
+	}{
+		{"2544", 2544, true},
+		{" 1234.5 ", 1234.5, true},
+		{"812 m", 812, true},
+		{"-3", -3, true},
+		{"1,234", 0, false},
+		{"approx 900", 0, false},
+		{"", 0, false},
+		{"NaN", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseEleTag(tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseEleTag(%q) = %v, %v; want %v, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCompareElevations(t *testing.T) {
+	dem := func(element OSMElement, elevation float64) OSMElement {
+		element.ElevationFetched = &elevation
+		element.ElevationProvider = ProviderOpenTopo
+		return element
+	}
+	near := OSMElement{Type: "node", ID: 1, Tags: map[string]string{"ele": "2500"}}
+	far := OSMElement{Type: "node", ID: 2, Tags: map[string]string{"ele": "1800"}}
+	farther := OSMElement{Type: "way", ID: 3, Tags: map[string]string{"ele": "900 m"}}
+	broken := OSMElement{Type: "node", ID: 4, Tags: map[string]string{"ele": "unknown"}}
+	missing := OSMElement{Type: "node", ID: 5, Tags: map[string]string{"ele": "700"}}
+
+	fetched := map[string]OSMElement{
+		"node/1": dem(near, 2530),
+		"node/2": dem(far, 1720),
+		"way/3":  dem(farther, 1150),
+	}
+	findings := compareElevations("peaks", []OSMElement{near, far, farther, broken, missing}, fetched, 50)
+	sortAuditFindings(findings)
+
+	var got []string
+	for _, finding := range findings {
+		got = append(got, elementKey(finding.Element.Type, finding.Element.ID)+" "+finding.Issue)
+	}
+	want := []string{"way/3 difference", "node/2 difference", "node/4 unparsable_ele", "node/5 no_dem_elevation"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("findings = %v, want %v", got, want)
+	}
+	if findings[1].Difference != -80 {
+		t.Errorf("difference = %v, want -80", findings[1].Difference)
+	}
+
+	path := filepath.Join(t.TempDir(), "audit.csv")
+	if err := WriteAuditReport(path, findings); err != nil {
+		t.Fatalf("WriteAuditReport() error = %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 || strings.Join(records[1][6:11], ",") != "900 m,1150.0,+250.0,opentopo,difference" {
+		t.Errorf("unexpected report rows: %v", records)
+	}
+}
+
+func TestFilterExistingKeepsTaggedElements(t *testing.T) {
+	data := &OSMData{Peaks: []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak", "ele": "2544"}},
+		{Type: "node", ID: 2, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak"}},
+		{Type: "node", ID: 3, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak", "ele": "100", "fixme": "position"}},
+	}}
+
+	filtered := NewElevationFilter().FilterExisting(data)
+	if len(filtered.Peaks) != 1 || filtered.Peaks[0].ID != 1 {
+		t.Errorf("Expected only the tagged, non-excluded peak, got %v", filtered.Peaks)
+	}
+
+	extractor := NewOverpassExtractor("Moldova")
+	extractor.WithEle = true
+	cat, _ := DefaultProfile().Category("peaks")
+	if query := extractor.categoryQuery(cat); !strings.Contains(query, `node["natural"="peak"]["ele"](area.country);`) {
+		t.Errorf("Expected audit query to select elements with ele:\n%s", query)
+	}
+}
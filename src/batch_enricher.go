@@ -1,7 +1,10 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -11,12 +14,18 @@ import (
 
 // BatchElevationEnricher handles batch elevation requests
 type BatchElevationEnricher struct {
-	APIType        string
-	RateLimit      time.Duration
-	BaseURL        string
-	BatchSize      int
-	httpClient     *http.Client
-	coordExtractor *CoordinateExtractor
+	APIType         string
+	RateLimit       time.Duration
+	BaseURL         string
+	BatchSize       int
+	Provider        BatchElevationProvider // overrides the HTTP API when set (e.g. local DEM tiles)
+	Checkpoint      *EnrichCheckpoint      // restores and records progress when set
+	CheckpointEvery int                    // batches between checkpoint saves
+	NoData          []OSMElement           // elements the DEM had no data for, collected by EnrichElementsBatch
+	Format          *ElevationFormat       // precision and rounding of ele values, one decimal when nil
+	Source          *ElevationSource       // source tag written with each value, ele:source=SRTM when nil
+	httpClient      HTTPClient
+	coordExtractor  *CoordinateExtractor
 }
 
 // LocationRequest represents a location to fetch elevation for
@@ -31,13 +40,14 @@ type BatchElevationResult struct {
 	Elevation *float64
 	Error     error
 	Element   *OSMElement
+	Provider  string
 }
 
 // OpenTopoDataBatchResponse represents the response from OpenTopoData API
 type OpenTopoDataBatchResponse struct {
 	Status  string `json:"status"`
 	Results []struct {
-		Elevation float64 `json:"elevation"`
+		Elevation *float64 `json:"elevation"` // null where the dataset has no data (e.g. over the ocean)
 		Location  struct {
 			Lat float64 `json:"lat"`
 			Lng float64 `json:"lng"`
@@ -45,6 +55,26 @@ type OpenTopoDataBatchResponse struct {
 	} `json:"results"`
 }
 
+// OpenElevationLocation is a single location in an Open-Elevation lookup
+type OpenElevationLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// OpenElevationBatchRequest is the POST body of an Open-Elevation lookup
+type OpenElevationBatchRequest struct {
+	Locations []OpenElevationLocation `json:"locations"`
+}
+
+// OpenElevationBatchResponse represents the response from the Open-Elevation API
+type OpenElevationBatchResponse struct {
+	Results []struct {
+		Latitude  float64  `json:"latitude"`
+		Longitude float64  `json:"longitude"`
+		Elevation *float64 `json:"elevation"`
+	} `json:"results"`
+}
+
 // NewBatchElevationEnricher creates a new batch enricher
 func NewBatchElevationEnricher(apiType string, rateLimit float64, batchSize int) *BatchElevationEnricher {
 	if batchSize <= 0 || batchSize > 100 {
@@ -56,9 +86,7 @@ func NewBatchElevationEnricher(apiType string, rateLimit float64, batchSize int)
 		RateLimit:      time.Duration(rateLimit * float64(time.Millisecond)),
 		BatchSize:      batchSize,
 		coordExtractor: NewCoordinateExtractor(),
-		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		httpClient:     NewRetryingReadClient(30 * time.Second),
 	}
 
 	// Note: Using direct API endpoint instead of proxy for better reliability
@@ -73,13 +101,17 @@ func NewBatchElevationEnricher(apiType string, rateLimit float64, batchSize int)
 }
 
 // BatchGetElevations fetches elevations for multiple locations in a single API call
-func (e *BatchElevationEnricher) BatchGetElevations(locations []LocationRequest) ([]BatchElevationResult, error) {
+func (e *BatchElevationEnricher) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
 	if len(locations) == 0 {
 		return []BatchElevationResult{}, nil
 	}
 
+	if e.APIType == ProviderOpenElevation {
+		return e.batchGetOpenElevation(ctx, locations)
+	}
+
 	if e.APIType != "opentopo" {
-		return nil, fmt.Errorf("batch mode only supported for opentopo API")
+		return nil, fmt.Errorf("batch mode not supported for %s API", e.APIType)
 	}
 
 	// Build the locations parameter: "lat1,lon1|lat2,lon2|..."
@@ -89,9 +121,17 @@ func (e *BatchElevationEnricher) BatchGetElevations(locations []LocationRequest)
 	}
 	locationsParam := strings.Join(locationParts, "|")
 
+	if err := sharedBudget().AcquireContext(ctx, BudgetElevation); err != nil {
+		return nil, err
+	}
+
 	// Make the API request with properly encoded query parameter
 	requestURL := fmt.Sprintf("%s?locations=%s", e.BaseURL, url.QueryEscape(locationsParam))
-	resp, err := e.httpClient.Get(requestURL)
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	resp, err := e.httpClient.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch batch elevations: %v", err)
 	}
@@ -113,29 +153,91 @@ func (e *BatchElevationEnricher) BatchGetElevations(locations []LocationRequest)
 	// Match results back to input locations
 	results := make([]BatchElevationResult, len(locations))
 	for i, loc := range locations {
-		if i < len(result.Results) {
-			elevation := result.Results[i].Elevation
-			results[i] = BatchElevationResult{
-				Elevation: &elevation,
-				Error:     nil,
-				Element:   loc.Element,
-			}
-		} else {
-			results[i] = BatchElevationResult{
-				Elevation: nil,
-				Error:     fmt.Errorf("no elevation data returned for location %d", i),
-				Element:   loc.Element,
-			}
+		results[i] = BatchElevationResult{Element: loc.Element}
+		if i >= len(result.Results) {
+			results[i].Error = fmt.Errorf("no elevation data returned for location %d", i)
+			continue
 		}
+		results[i].Elevation, results[i].Error = checkElevation(result.Results[i].Elevation)
 	}
 
 	return results, nil
 }
 
-// EnrichElementsBatch enriches multiple elements using batch API calls
-func (e *BatchElevationEnricher) EnrichElementsBatch(elements []OSMElement, maxCount int) []OSMElement {
+// batchGetOpenElevation fetches elevations using the Open-Elevation POST /api/v1/lookup protocol
+func (e *BatchElevationEnricher) batchGetOpenElevation(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	request := OpenElevationBatchRequest{Locations: make([]OpenElevationLocation, len(locations))}
+	for i, loc := range locations {
+		request.Locations[i] = OpenElevationLocation{Latitude: loc.Lat, Longitude: loc.Lon}
+	}
+
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode batch request: %v", err)
+	}
+
+	if err := sharedBudget().AcquireContext(ctx, BudgetElevation); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.BaseURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch batch elevations: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("elevation API returned status %d", resp.StatusCode)
+	}
+
+	var result OpenElevationBatchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode batch response: %v", err)
+	}
+
+	// Open-Elevation returns results in request order
+	results := make([]BatchElevationResult, len(locations))
+	for i, loc := range locations {
+		results[i] = BatchElevationResult{Element: loc.Element}
+		if i >= len(result.Results) {
+			results[i].Error = fmt.Errorf("no elevation data returned for location %d", i)
+			continue
+		}
+		results[i].Elevation, results[i].Error = checkElevation(result.Results[i].Elevation)
+	}
+
+	return results, nil
+}
+
+// checkElevation turns a null or nodata sentinel value from an API into ErrElevationVoid
+func checkElevation(elevation *float64) (*float64, error) {
+	if elevation == nil || isNoDataElevation(*elevation) {
+		return nil, ErrElevationVoid
+	}
+	value := *elevation
+	return &value, nil
+}
+
+// EnrichElementsBatch enriches multiple elements using batch API calls. When ctx is
+// canceled it saves the checkpoint and returns the context's error.
+func (e *BatchElevationEnricher) EnrichElementsBatch(ctx context.Context, elements []OSMElement, maxCount int) ([]OSMElement, error) {
 	var enriched []OSMElement
 	var locationsToFetch []LocationRequest
+	restored := 0
+	noData := 0
+	format := defaultElevationFormat
+	if e.Format != nil {
+		format = *e.Format
+	}
+	source := ElevationSource{Key: eleSourceKeys[0]}
+	if e.Source != nil {
+		source = *e.Source
+	}
 
 	// Prepare locations for batch processing
 	for i := range elements {
@@ -145,10 +247,18 @@ func (e *BatchElevationEnricher) EnrichElementsBatch(elements []OSMElement, maxC
 
 		element := elements[i]
 
+		// Skip elements already enriched before an interruption
+		if e.Checkpoint != nil {
+			if _, ok := e.Checkpoint.Lookup(element); ok {
+				restored++
+				continue
+			}
+		}
+
 		// Get coordinates using the coordinate extractor
 		coords, valid := e.coordExtractor.Extract(element)
 		if !valid {
-			fmt.Printf("Warning: element %d has no valid coordinates\n", element.ID)
+			enrichLog.Warn("Element %d has no valid coordinates", element.ID)
 			continue
 		}
 
@@ -159,55 +269,144 @@ func (e *BatchElevationEnricher) EnrichElementsBatch(elements []OSMElement, maxC
 		})
 	}
 
-	// Process in batches
+	// Elements sharing a DEM cell (a hotel and its entrance, adjacent platforms) need one lookup
 	totalLocations := len(locationsToFetch)
-	for i := 0; i < totalLocations; i += e.BatchSize {
+	uniqueLocations, sharedBy := dedupeLocations(locationsToFetch)
+	if len(uniqueLocations) < totalLocations {
+		enrichLog.Info("Deduplicated %d locations to %d unique points", totalLocations, len(uniqueLocations))
+	}
+
+	// Process in batches
+	totalUnique := len(uniqueLocations)
+	for i := 0; i < totalUnique; i += e.BatchSize {
+		if ctx.Err() != nil {
+			return nil, e.interrupted(ctx)
+		}
+
 		end := i + e.BatchSize
-		if end > totalLocations {
-			end = totalLocations
+		if end > totalUnique {
+			end = totalUnique
 		}
 
-		batch := locationsToFetch[i:end]
+		batch := uniqueLocations[i:end]
 		batchNum := (i / e.BatchSize) + 1
-		totalBatches := (totalLocations + e.BatchSize - 1) / e.BatchSize
+		totalBatches := (totalUnique + e.BatchSize - 1) / e.BatchSize
 
-		fmt.Printf("Processing batch %d/%d (%d locations)...\n", batchNum, totalBatches, len(batch))
+		enrichLog.Info("Processing batch %d/%d (%d locations)...", batchNum, totalBatches, len(batch))
 
-		results, err := e.BatchGetElevations(batch)
+		var results []BatchElevationResult
+		var err error
+		if e.Provider != nil {
+			results, err = e.Provider.BatchGetElevations(ctx, batch)
+		} else {
+			results, err = e.BatchGetElevations(ctx, batch)
+		}
+		if err != nil && ctx.Err() != nil {
+			return nil, e.interrupted(ctx)
+		}
 		if err != nil {
-			fmt.Printf("Warning: batch request failed: %v\n", err)
+			enrichLog.Warn("Batch request failed: %v", err)
 			// Continue to next batch instead of failing completely
 			continue
 		}
 
-		// Apply results to elements
-		for _, result := range results {
-			if result.Error != nil {
-				fmt.Printf("Warning: failed to get elevation for element %d: %v\n", result.Element.ID, result.Error)
-				continue
+		// Apply each result to every element sharing the location
+		for k, result := range results {
+			if i+k >= totalUnique {
+				break
 			}
+			for _, location := range sharedBy[i+k] {
+				if errors.Is(result.Error, ErrElevationVoid) {
+					// Over the ocean or in an SRTM void: no data rather than an absurd value
+					e.NoData = append(e.NoData, *location.Element)
+					noData++
+					continue
+				}
+				if result.Error != nil {
+					enrichLog.Warn("Failed to get elevation for element %d: %v", location.Element.ID, result.Error)
+					continue
+				}
 
-			if result.Elevation != nil {
-				// Create a new element with elevation data
-				enrichedElement := *result.Element
-				if enrichedElement.Tags == nil {
-					enrichedElement.Tags = make(map[string]string)
+				if result.Elevation != nil {
+					// Create a new element with elevation data
+					enrichedElement := *location.Element
+					enrichedElement.ElevationFetched = result.Elevation
+					enrichedElement.ElevationProvider = result.Provider
+					format.Apply(&enrichedElement)
+					provider := result.Provider
+					if provider == "" {
+						provider = e.APIType
+					}
+					enrichedElement.Tags[source.Key] = source.Value(provider)
+
+					enriched = append(enriched, enrichedElement)
+					if e.Checkpoint != nil {
+						e.Checkpoint.Record(enrichedElement)
+					}
 				}
-				enrichedElement.Tags["ele"] = fmt.Sprintf("%.1f", *result.Elevation)
-				enrichedElement.Tags["ele:source"] = "SRTM"
-				enrichedElement.ElevationFetched = result.Elevation
+			}
+		}
 
-				enriched = append(enriched, enrichedElement)
+		if e.Checkpoint != nil && e.CheckpointEvery > 0 && batchNum%e.CheckpointEvery == 0 {
+			if err := e.Checkpoint.Save(); err != nil {
+				enrichLog.Warn("Failed to save enrich checkpoint: %v", err)
 			}
 		}
 
 		// Rate limiting between batches
-		if end < totalLocations {
-			time.Sleep(e.RateLimit)
+		if end < totalUnique {
+			if err := sleepContext(ctx, e.RateLimit); err != nil {
+				return nil, e.interrupted(ctx)
+			}
+		}
+	}
+
+	enrichLog.Info("Successfully enriched %d/%d elements", len(enriched), totalLocations)
+	if noData > 0 {
+		enrichLog.Info("No DEM data (void or nodata) for %d elements", noData)
+	}
+
+	if e.Checkpoint != nil {
+		if restored > 0 {
+			enrichLog.Info("Restored %d elements from checkpoint", restored)
+		}
+		if err := e.Checkpoint.Save(); err != nil {
+			enrichLog.Warn("Failed to save enrich checkpoint: %v", err)
 		}
+		enriched = e.Checkpoint.Collect(elements, maxCount)
 	}
 
-	fmt.Printf("Successfully enriched %d/%d elements\n", len(enriched), totalLocations)
+	return enriched, nil
+}
+
+// dedupeLocations merges locations in the same DEM cell (about 30 m, see elevationCacheKey).
+// It returns one representative per cell and, at the same index, all locations of that cell.
+func dedupeLocations(locations []LocationRequest) ([]LocationRequest, [][]LocationRequest) {
+	var unique []LocationRequest
+	var sharedBy [][]LocationRequest
+	index := make(map[string]int)
 
-	return enriched
+	for _, loc := range locations {
+		key := string(elevationCacheKey(loc.Lat, loc.Lon))
+		if i, ok := index[key]; ok {
+			sharedBy[i] = append(sharedBy[i], loc)
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, loc)
+		sharedBy = append(sharedBy, []LocationRequest{loc})
+	}
+	return unique, sharedBy
+}
+
+// interrupted flushes the checkpoint so an interrupted run can resume, and returns the context's error
+func (e *BatchElevationEnricher) interrupted(ctx context.Context) error {
+	if e.Checkpoint != nil {
+		if err := e.Checkpoint.Save(); err != nil {
+			enrichLog.Warn("Failed to save enrich checkpoint: %v", err)
+		} else {
+			enrichLog.Debug("Enrich checkpoint saved with %d elements", len(e.Checkpoint.Elements))
+		}
+	}
+	return ctx.Err()
 }
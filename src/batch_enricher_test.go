@@ -1,6 +1,10 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -184,3 +188,98 @@ func TestBatchProcessingLogic(t *testing.T) {
 		})
 	}
 }
+
+func TestBatchGetElevationsOpenElevation(t *testing.T) {
+	disableSharedBudget(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("Expected POST, got %s", r.Method)
+		}
+		var request OpenElevationBatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatalf("failed to decode request: %v", err)
+		}
+		if len(request.Locations) != 2 || request.Locations[1].Latitude != 46.5 {
+			t.Errorf("Unexpected request locations: %+v", request.Locations)
+		}
+		w.Write([]byte(`{"results":[{"latitude":45.5,"longitude":25.5,"elevation":1234},{"latitude":46.5,"longitude":24.5,"elevation":null}]}`))
+	}))
+	defer server.Close()
+
+	enricher := NewBatchElevationEnricher(ProviderOpenElevation, 0, 100)
+	enricher.BaseURL = server.URL
+
+	elements := []OSMElement{{ID: 1}, {ID: 2}}
+	results, err := enricher.BatchGetElevations(context.Background(), []LocationRequest{
+		{Lat: 45.5, Lon: 25.5, Element: &elements[0]},
+		{Lat: 46.5, Lon: 24.5, Element: &elements[1]},
+	})
+	if err != nil {
+		t.Fatalf("BatchGetElevations() error = %v", err)
+	}
+
+	if results[0].Elevation == nil || *results[0].Elevation != 1234 {
+		t.Errorf("Expected elevation 1234 for first location, got %+v", results[0])
+	}
+	if results[1].Error == nil {
+		t.Error("Expected error for location without elevation data")
+	}
+}
+
+func TestEnrichElementsBatchDeduplicatesLocations(t *testing.T) {
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.60000, Lon: 25.50000},
+		{Type: "node", ID: 2, Lat: 45.60002, Lon: 25.50001}, // entrance of node 1, same DEM cell
+		{Type: "node", ID: 3, Lat: 45.70000, Lon: 25.50000},
+	}
+	provider := &countingProvider{}
+	enricher := NewBatchElevationEnricher("opentopo", 0, 2)
+	enricher.Provider = provider
+
+	enriched, err := enricher.EnrichElementsBatch(context.Background(), elements, 0)
+	if err != nil {
+		t.Fatalf("EnrichElementsBatch() error = %v", err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("provider asked for %d locations, want 2", provider.calls)
+	}
+	if len(enriched) != 3 {
+		t.Fatalf("enriched %d elements, want 3", len(enriched))
+	}
+
+	want := map[int64]float64{1: 45.6 + 25.5, 2: 45.6 + 25.5, 3: 45.7 + 25.5}
+	for _, element := range enriched {
+		if element.ElevationFetched == nil || *element.ElevationFetched != want[element.ID] {
+			t.Errorf("element %d elevation = %v, want %v", element.ID, element.ElevationFetched, want[element.ID])
+		}
+	}
+}
+
+func TestEnrichElementsBatchReportsNoData(t *testing.T) {
+	disableSharedBudget(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"OK","results":[{"elevation":812.4},{"elevation":null},{"elevation":-32768}]}`))
+	}))
+	defer server.Close()
+
+	enricher := NewBatchElevationEnricher(ProviderOpenTopo, 0, 100)
+	enricher.BaseURL = server.URL
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.5, Lon: 25.5},
+		{Type: "node", ID: 2, Lat: 44.0, Lon: 30.0}, // Black Sea
+		{Type: "node", ID: 3, Lat: 46.5, Lon: 24.5}, // SRTM void
+	}
+
+	enriched, err := enricher.EnrichElementsBatch(context.Background(), elements, 0)
+	if err != nil {
+		t.Fatalf("EnrichElementsBatch() error = %v", err)
+	}
+	if len(enriched) != 1 || *enriched[0].ElevationFetched != 812.4 {
+		t.Errorf("Expected only element 1 enriched, got %+v", enriched)
+	}
+	if len(enricher.NoData) != 2 || enricher.NoData[0].ID != 2 || enricher.NoData[1].ID != 3 {
+		t.Errorf("Expected elements 2 and 3 reported as no data, got %+v", enricher.NoData)
+	}
+}
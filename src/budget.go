@@ -0,0 +1,278 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Budget kinds consulted by the API clients
+const (
+	BudgetOverpass      = "overpass"
+	BudgetElevation     = "elevation"
+	BudgetOSMEdits      = "osm_edits"
+	BudgetOSMChangesets = "osm_changesets"
+)
+
+// DefaultBudgetFile persists usage counters so limits hold across invocations
+const DefaultBudgetFile = "output/budget.json"
+
+// ErrBudgetExhausted is returned when a request would exceed a limit and waiting is not allowed
+var ErrBudgetExhausted = errors.New("API budget exhausted")
+
+// BudgetLimit defines hourly and daily request limits for one kind (0 = unlimited)
+type BudgetLimit struct {
+	Hourly int
+	Daily  int
+}
+
+// BudgetUsage tracks request counts in the current hour and day windows
+type BudgetUsage struct {
+	HourStart time.Time `json:"hour_start"`
+	HourCount int       `json:"hour_count"`
+	DayStart  time.Time `json:"day_start"`
+	DayCount  int       `json:"day_count"`
+}
+
+// Budget enforces per-API usage limits shared by all clients
+type Budget struct {
+	mu      sync.Mutex
+	path    string
+	limits  map[string]BudgetLimit
+	usage   map[string]*BudgetUsage
+	maxWait time.Duration
+	now     func() time.Time
+	sleep   func(context.Context, time.Duration) error
+
+	// saveMu serializes writes of the budget file, which happen outside mu; revision numbers
+	// the reservations so an older snapshot never overwrites a newer one
+	saveMu   sync.Mutex
+	revision int
+	saved    int
+}
+
+// NewBudget creates a budget with the given limits, loading persisted usage from path when present
+func NewBudget(path string, limits map[string]BudgetLimit, maxWait time.Duration) *Budget {
+	b := &Budget{
+		path:    path,
+		limits:  limits,
+		usage:   make(map[string]*BudgetUsage),
+		maxWait: maxWait,
+		now:     time.Now,
+		sleep:   sleepContext,
+	}
+
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			if err := loadJSON(path, &b.usage); err != nil {
+				pipelineLog.Warn("Ignoring unreadable budget file %s: %v", path, err)
+				b.usage = make(map[string]*BudgetUsage)
+			}
+		}
+	}
+
+	return b
+}
+
+// NewBudgetFromConfig creates a budget using the BUDGET_* configuration keys
+func NewBudgetFromConfig(config *Config) *Budget {
+	limits := map[string]BudgetLimit{
+		BudgetOverpass: {
+			Hourly: config.GetInt("BUDGET_OVERPASS_HOURLY"),
+			Daily:  config.GetInt("BUDGET_OVERPASS_DAILY"),
+		},
+		BudgetElevation: {
+			Hourly: config.GetInt("BUDGET_ELEVATION_HOURLY"),
+			Daily:  config.GetInt("BUDGET_ELEVATION_DAILY"),
+		},
+		BudgetOSMEdits: {
+			Hourly: config.GetInt("BUDGET_OSM_EDITS_HOURLY"),
+			Daily:  config.GetInt("BUDGET_OSM_EDITS_DAILY"),
+		},
+		BudgetOSMChangesets: {
+			Hourly: config.GetInt("BUDGET_OSM_CHANGESETS_HOURLY"),
+			Daily:  config.GetInt("BUDGET_OSM_CHANGESETS_DAILY"),
+		},
+	}
+	maxWait := time.Duration(config.GetInt("BUDGET_MAX_WAIT_MIN")) * time.Minute
+	return NewBudget(config.Get("BUDGET_FILE"), limits, maxWait)
+}
+
+var (
+	sharedBudgetOnce     sync.Once
+	sharedBudgetInstance *Budget
+)
+
+// sharedBudget returns the process-wide budget configured from the environment
+func sharedBudget() *Budget {
+	sharedBudgetOnce.Do(func() {
+		config := NewConfig()
+		config.LoadFromEnv()
+		sharedBudgetInstance = NewBudgetFromConfig(config)
+	})
+	return sharedBudgetInstance
+}
+
+// rollWindows resets the hour and day counters when their windows have passed
+func (u *BudgetUsage) rollWindows(now time.Time) {
+	hour := now.Truncate(time.Hour)
+	if !u.HourStart.Equal(hour) {
+		u.HourStart = hour
+		u.HourCount = 0
+	}
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if !u.DayStart.Equal(day) {
+		u.DayStart = day
+		u.DayCount = 0
+	}
+}
+
+// waitTime returns how long n requests of this kind must wait to stay within its limits
+func (b *Budget) waitTime(kind string, n int, now time.Time) time.Duration {
+	limit := b.limits[kind]
+	usage := b.usageFor(kind)
+	usage.rollWindows(now)
+
+	if limit.Daily > 0 && usage.DayCount+n > limit.Daily {
+		return usage.DayStart.AddDate(0, 0, 1).Sub(now)
+	}
+	if limit.Hourly > 0 && usage.HourCount+n > limit.Hourly {
+		return usage.HourStart.Add(time.Hour).Sub(now)
+	}
+	return 0
+}
+
+// usageFor returns the usage counters for a kind, creating them if needed
+func (b *Budget) usageFor(kind string) *BudgetUsage {
+	usage, ok := b.usage[kind]
+	if !ok {
+		usage = &BudgetUsage{}
+		b.usage[kind] = usage
+	}
+	return usage
+}
+
+// AcquireContext reserves one request of the given kind. When the budget is exhausted it
+// pauses until the window resets, ending early when ctx is canceled, or returns
+// ErrBudgetExhausted if that would exceed the maximum wait.
+func (b *Budget) AcquireContext(ctx context.Context, kind string) error {
+	return b.AcquireN(ctx, kind, 1)
+}
+
+// AcquireN reserves n requests of a kind at once, all or none, so a diff upload never spends
+// part of the budget and then fails. The lock is released while pausing: a wait for one kind
+// does not hold up the others.
+func (b *Budget) AcquireN(ctx context.Context, kind string, n int) error {
+	if b == nil || n <= 0 {
+		return nil
+	}
+
+	limit := b.limits[kind]
+	if (limit.Daily > 0 && n > limit.Daily) || (limit.Hourly > 0 && n > limit.Hourly) {
+		return fmt.Errorf("%w for %s: %d requests exceed the hourly or daily limit; split this work",
+			ErrBudgetExhausted, kind, n)
+	}
+
+	for {
+		b.mu.Lock()
+		wait := b.waitTime(kind, n, b.now())
+		if wait <= 0 {
+			usage := b.usageFor(kind)
+			usage.HourCount += n
+			usage.DayCount += n
+			b.revision++
+			revision, snapshot := b.revision, b.snapshot()
+			b.mu.Unlock()
+
+			b.persist(revision, snapshot)
+			return nil
+		}
+		b.mu.Unlock()
+
+		if wait > b.maxWait {
+			return fmt.Errorf("%w for %s: next slot in %v exceeds maximum wait of %v; reschedule this work",
+				ErrBudgetExhausted, kind, wait.Round(time.Second), b.maxWait)
+		}
+		pipelineLog.Info("Budget for %s reached, pausing %v...", kind, wait.Round(time.Second))
+		if err := b.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// snapshot copies the usage counters; the caller holds mu
+func (b *Budget) snapshot() map[string]BudgetUsage {
+	usage := make(map[string]BudgetUsage, len(b.usage))
+	for kind, u := range b.usage {
+		usage[kind] = *u
+	}
+	return usage
+}
+
+// persist writes a usage snapshot to the budget file unless a later one was written already
+func (b *Budget) persist(revision int, usage map[string]BudgetUsage) {
+	if b.path == "" {
+		return
+	}
+
+	b.saveMu.Lock()
+	defer b.saveMu.Unlock()
+	if revision <= b.saved {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(b.path), 0755); err != nil {
+		pipelineLog.Warn("Failed to create budget directory: %v", err)
+	} else if err := saveJSON(b.path, usage); err != nil {
+		pipelineLog.Warn("Failed to persist budget usage: %v", err)
+	} else {
+		b.saved = revision
+	}
+}
+
+// Remaining returns the number of requests left today for a kind (-1 when unlimited)
+func (b *Budget) Remaining(kind string) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	limit := b.limits[kind]
+	if limit.Daily <= 0 {
+		return -1
+	}
+	usage := b.usageFor(kind)
+	usage.rollWindows(b.now())
+	return limit.Daily - usage.DayCount
+}
+
+// Available returns how many requests of a kind fit in the current hour and day windows
+// (-1 when unlimited) and, when none do, how long until the next window opens
+func (b *Budget) Available(kind string) (int, time.Duration) {
+	if b == nil {
+		return -1, 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	limit := b.limits[kind]
+	usage := b.usageFor(kind)
+	usage.rollWindows(now)
+
+	available := -1
+	if limit.Hourly > 0 {
+		available = max(limit.Hourly-usage.HourCount, 0)
+	}
+	if limit.Daily > 0 {
+		if left := max(limit.Daily-usage.DayCount, 0); available < 0 || left < available {
+			available = left
+		}
+	}
+	if available == 0 {
+		return 0, b.waitTime(kind, 1, now)
+	}
+	return available, 0
+}
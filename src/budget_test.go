@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBudget(path string, limits map[string]BudgetLimit, maxWait time.Duration, clock *time.Time) *Budget {
+	budget := NewBudget(path, limits, maxWait)
+	budget.now = func() time.Time { return *clock }
+	budget.sleep = func(_ context.Context, d time.Duration) error {
+		*clock = clock.Add(d)
+		return nil
+	}
+	return budget
+}
+
+func TestBudgetPausesWhenHourlyLimitReached(t *testing.T) {
+	clock := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	budget := newTestBudget("", map[string]BudgetLimit{BudgetOverpass: {Hourly: 2}}, time.Hour, &clock)
+
+	for i := 0; i < 3; i++ {
+		if err := budget.AcquireContext(context.Background(), BudgetOverpass); err != nil {
+			t.Fatalf("AcquireContext() #%d error = %v", i+1, err)
+		}
+	}
+
+	expected := time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC)
+	if !clock.Equal(expected) {
+		t.Errorf("Expected third request to wait until %v, clock is %v", expected, clock)
+	}
+}
+
+func TestBudgetExhaustedBeyondMaxWait(t *testing.T) {
+	clock := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	budget := newTestBudget("", map[string]BudgetLimit{BudgetElevation: {Daily: 1}}, time.Hour, &clock)
+
+	if err := budget.AcquireContext(context.Background(), BudgetElevation); err != nil {
+		t.Fatalf("first AcquireContext() error = %v", err)
+	}
+	err := budget.AcquireContext(context.Background(), BudgetElevation)
+	if !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Expected ErrBudgetExhausted, got %v", err)
+	}
+	if remaining := budget.Remaining(BudgetElevation); remaining != 0 {
+		t.Errorf("Remaining() = %d, want 0", remaining)
+	}
+	if remaining := budget.Remaining(BudgetOSMEdits); remaining != -1 {
+		t.Errorf("Remaining() for unlimited kind = %d, want -1", remaining)
+	}
+}
+
+func TestBudgetPersistsUsage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "budget.json")
+	clock := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	limits := map[string]BudgetLimit{BudgetOSMChangesets: {Daily: 5}}
+
+	budget := newTestBudget(path, limits, time.Hour, &clock)
+	budget.AcquireContext(context.Background(), BudgetOSMChangesets)
+	budget.AcquireContext(context.Background(), BudgetOSMChangesets)
+
+	reloaded := newTestBudget(path, limits, time.Hour, &clock)
+	if remaining := reloaded.Remaining(BudgetOSMChangesets); remaining != 3 {
+		t.Errorf("Remaining() after reload = %d, want 3", remaining)
+	}
+
+	clock = clock.Add(24 * time.Hour)
+	if remaining := reloaded.Remaining(BudgetOSMChangesets); remaining != 5 {
+		t.Errorf("Remaining() on the next day = %d, want 5", remaining)
+	}
+}
+
+// disableSharedBudget turns off the process-wide budget for tests that exercise API clients
+func disableSharedBudget(t *testing.T) {
+	t.Helper()
+	previous := sharedBudget()
+	sharedBudgetInstance = nil
+	t.Cleanup(func() { sharedBudgetInstance = previous })
+}
+
+func TestBudgetAvailable(t *testing.T) {
+	clock := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	budget := newTestBudget("", map[string]BudgetLimit{BudgetOSMEdits: {Hourly: 3, Daily: 4}}, time.Hour, &clock)
+
+	tests := []struct {
+		acquire   int
+		available int
+		wait      time.Duration
+	}{
+		{acquire: 0, available: 3},
+		{acquire: 2, available: 1},
+		{acquire: 1, available: 0, wait: 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		for i := 0; i < tt.acquire; i++ {
+			budget.AcquireContext(context.Background(), BudgetOSMEdits)
+		}
+		if available, wait := budget.Available(BudgetOSMEdits); available != tt.available || wait != tt.wait {
+			t.Errorf("Available() = %d, %v, want %d, %v", available, wait, tt.available, tt.wait)
+		}
+	}
+
+	// The next hour only has the one edit left of the daily limit
+	clock = clock.Add(30 * time.Minute)
+	if available, _ := budget.Available(BudgetOSMEdits); available != 1 {
+		t.Errorf("Available() in the next hour = %d, want 1", available)
+	}
+	if available, _ := budget.Available(BudgetOverpass); available != -1 {
+		t.Errorf("Available() for unlimited kind = %d, want -1", available)
+	}
+	var disabled *Budget
+	if available, _ := disabled.Available(BudgetOSMEdits); available != -1 {
+		t.Errorf("Available() without budget = %d, want -1", available)
+	}
+}
+
+func TestBudgetAcquireNIsAllOrNothing(t *testing.T) {
+	clock := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	budget := newTestBudget("", map[string]BudgetLimit{BudgetOSMEdits: {Daily: 5}}, time.Hour, &clock)
+
+	if err := budget.AcquireN(context.Background(), BudgetOSMEdits, 3); err != nil {
+		t.Fatalf("AcquireN(3) error = %v", err)
+	}
+	if err := budget.AcquireN(context.Background(), BudgetOSMEdits, 3); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("AcquireN(3) over the limit = %v, want ErrBudgetExhausted", err)
+	}
+	if remaining := budget.Remaining(BudgetOSMEdits); remaining != 2 {
+		t.Errorf("Remaining() after refused AcquireN = %d, want 2", remaining)
+	}
+	if err := budget.AcquireN(context.Background(), BudgetOSMEdits, 2); err != nil {
+		t.Errorf("AcquireN(2) error = %v", err)
+	}
+
+	// More edits than the daily limit never fit, however long the wait
+	budget.maxWait = 48 * time.Hour
+	if err := budget.AcquireN(context.Background(), BudgetOSMEdits, 6); !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("AcquireN(6) = %v, want ErrBudgetExhausted", err)
+	}
+}
+
+func TestBudgetPauseDoesNotBlockOtherKinds(t *testing.T) {
+	clock := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+	budget := NewBudget("", map[string]BudgetLimit{BudgetOverpass: {Hourly: 1}}, time.Hour)
+	budget.now = func() time.Time { return clock }
+	paused := make(chan struct{})
+	budget.sleep = func(ctx context.Context, _ time.Duration) error {
+		close(paused)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	if err := budget.AcquireContext(context.Background(), BudgetOverpass); err != nil {
+		t.Fatalf("AcquireContext() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	overpass := make(chan error)
+	go func() { overpass <- budget.AcquireContext(ctx, BudgetOverpass) }()
+	<-paused
+
+	elevation := make(chan error)
+	go func() { elevation <- budget.AcquireContext(context.Background(), BudgetElevation) }()
+	select {
+	case err := <-elevation:
+		if err != nil {
+			t.Errorf("AcquireContext(elevation) error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("AcquireContext(elevation) blocked while overpass was pausing")
+	}
+
+	cancel()
+	if err := <-overpass; !errors.Is(err, context.Canceled) {
+		t.Errorf("paused AcquireContext() = %v, want context.Canceled", err)
+	}
+}
@@ -1,19 +1,27 @@
 package main
 
 import (
+	"context"
 	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 )
 
+// appVersion is reported in the created_by changeset tag; set it at build time
+// with -ldflags "-X main.appVersion=1.2.0"
+var appVersion = "dev"
+
 // ChangesetManager handles OSM changeset operations
 type ChangesetManager struct {
-	client         *http.Client
-	changesetID    int
-	changesetOpen  bool
-	dryRun         bool
+	client        HTTPClient
+	changesetID   int
+	changesetOpen bool
+	dryRun        bool
+	baseURL       string
+	metadata      []ChangesetTag
 }
 
 // OSMChangeset represents the changeset XML structure
@@ -36,26 +44,59 @@ type ChangesetTag struct {
 // NewChangesetManager creates a new changeset manager
 func NewChangesetManager(client *http.Client, dryRun bool) *ChangesetManager {
 	return &ChangesetManager{
-		client:        client,
+		client:        NewRetryingClient(client),
 		dryRun:        dryRun,
 		changesetOpen: false,
+		baseURL:       osmAPIBaseURL(),
+		metadata:      changesetMetadataTags(),
+	}
+}
+
+// changesetMetadataTags returns the configured created_by, bot, source and hashtags tags.
+// Keys configured as empty are left out.
+func changesetMetadataTags() []ChangesetTag {
+	config := NewConfig()
+	config.LoadFromEnv()
+
+	var tags []ChangesetTag
+	for _, tag := range []ChangesetTag{
+		{Key: "created_by", Value: config.Get("CHANGESET_CREATED_BY")},
+		{Key: "bot", Value: config.Get("CHANGESET_BOT")},
+		{Key: "source", Value: config.Get("CHANGESET_SOURCE")},
+		{Key: "hashtags", Value: config.Get("CHANGESET_HASHTAGS")},
+	} {
+		if value := strings.TrimSpace(tag.Value); value != "" && value != "none" {
+			tags = append(tags, ChangesetTag{Key: tag.Key, Value: value})
+		}
 	}
+	return tags
 }
 
-// Create creates a new changeset
-func (cm *ChangesetManager) Create(comment string) error {
+// changesetTags returns all tags of a new changeset with the given comment
+func (cm *ChangesetManager) changesetTags(comment string) []ChangesetTag {
+	tags := []ChangesetTag{{Key: "comment", Value: comment}}
+	return append(tags, cm.metadata...)
+}
+
+// Create creates a new changeset. ctx only interrupts the budget wait: once sent, the
+// request is completed so that a created changeset is always known and can be closed.
+func (cm *ChangesetManager) Create(ctx context.Context, comment string) error {
 	if cm.dryRun {
-		fmt.Printf("[DRY-RUN] Would create changeset: %s\n", comment)
+		uploadLog.Info("[DRY-RUN] Would create changeset: %s", comment)
+		for _, tag := range cm.metadata {
+			uploadLog.Debug("  %s=%s", tag.Key, tag.Value)
+		}
 		cm.changesetOpen = true
 		return nil
 	}
 
+	if err := sharedBudget().AcquireContext(ctx, BudgetOSMChangesets); err != nil {
+		return err
+	}
+
 	changesetXML := OSMChangeset{
 		Changeset: ChangesetData{
-			Tags: []ChangesetTag{
-				{Key: "created_by", Value: "elevate-romania"},
-				{Key: "comment", Value: comment},
-			},
+			Tags: cm.changesetTags(comment),
 		},
 	}
 
@@ -64,7 +105,7 @@ func (cm *ChangesetManager) Create(comment string) error {
 		return fmt.Errorf("failed to marshal changeset XML: %v", err)
 	}
 
-	req, err := http.NewRequest("PUT", "https://api.openstreetmap.org/api/0.6/changeset/create", bytes.NewReader(xmlData))
+	req, err := http.NewRequest("PUT", cm.baseURL+"/changeset/create", bytes.NewReader(xmlData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
@@ -72,13 +113,13 @@ func (cm *ChangesetManager) Create(comment string) error {
 
 	resp, err := cm.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to create changeset: %v", err)
+		return fmt.Errorf("failed to create changeset: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to create changeset: status code %d: %s", resp.StatusCode, string(body))
+		return &APIError{Operation: "failed to create changeset", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	body, err := io.ReadAll(resp.Body)
@@ -88,7 +129,8 @@ func (cm *ChangesetManager) Create(comment string) error {
 
 	fmt.Sscanf(string(body), "%d", &cm.changesetID)
 	cm.changesetOpen = true
-	fmt.Printf("Created changeset #%d\n", cm.changesetID)
+	runSummary.AddChangeset(cm.changesetID)
+	uploadLog.Info("Created changeset #%d", cm.changesetID)
 
 	return nil
 }
@@ -99,7 +141,7 @@ func (cm *ChangesetManager) Close() error {
 		return nil
 	}
 
-	url := fmt.Sprintf("https://api.openstreetmap.org/api/0.6/changeset/%d/close", cm.changesetID)
+	url := fmt.Sprintf("%s/changeset/%d/close", cm.baseURL, cm.changesetID)
 	req, err := http.NewRequest("PUT", url, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
@@ -107,16 +149,16 @@ func (cm *ChangesetManager) Close() error {
 
 	resp, err := cm.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to close changeset: %v", err)
+		return fmt.Errorf("failed to close changeset: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to close changeset: status code %d", resp.StatusCode)
+		return &APIError{Operation: "failed to close changeset", StatusCode: resp.StatusCode}
 	}
 
 	cm.changesetOpen = false
-	fmt.Printf("Closed changeset #%d\n", cm.changesetID)
+	uploadLog.Info("Closed changeset #%d", cm.changesetID)
 	return nil
 }
 
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// ChangesetCommentData holds the variables available to changeset comment templates
+type ChangesetCommentData struct {
+	Count        int
+	Country      string
+	Region       string // set for --region runs
+	ClusterIndex int
+	ClusterTotal int
+	// Categories are the keys of the categories uploaded in the changeset, in upload order
+	Categories []string
+	// CategoryNames lists Categories in the comment language, e.g. "peaks, alpine huts"
+	CategoryNames string
+}
+
+// Place returns the region and country the edits were made in
+func (d ChangesetCommentData) Place() string {
+	if d.Region == "" {
+		return d.Country
+	}
+	return d.Region + ", " + d.Country
+}
+
+// defaultCommentLanguage is used when no template exists for a country's language
+const defaultCommentLanguage = "en"
+
+// countryCommentLanguages maps OSM country names (name tag) to the language used for changeset comments
+var countryCommentLanguages = map[string]string{
+	"România":      "ro",
+	"Moldova":      "ro",
+	"France":       "fr",
+	"Deutschland":  "de",
+	"Österreich":   "de",
+	"España":       "es",
+	"Italia":       "it",
+	"Magyarország": "hu",
+	"България":     "bg",
+	"Polska":       "pl",
+}
+
+// commentTemplates is the catalog of changeset comment templates keyed by language
+var commentTemplates = map[string]string{
+	"en": "Add elevation data to {{.Count}} locations in {{.Place}} - cluster {{.ClusterIndex}}/{{.ClusterTotal}}{{with .CategoryNames}} ({{.}}){{end}}",
+	"ro": "Adăugare altitudine pentru {{.Count}} locații în {{.Place}} - grupul {{.ClusterIndex}}/{{.ClusterTotal}}{{with .CategoryNames}} ({{.}}){{end}}",
+	"fr": "Ajout de l'altitude à {{.Count}} lieux en {{.Place}} - groupe {{.ClusterIndex}}/{{.ClusterTotal}}{{with .CategoryNames}} ({{.}}){{end}}",
+	"de": "Höhenangaben für {{.Count}} Orte in {{.Place}} ergänzt - Cluster {{.ClusterIndex}}/{{.ClusterTotal}}{{with .CategoryNames}} ({{.}}){{end}}",
+	"es": "Añadir altitud a {{.Count}} lugares en {{.Place}} - grupo {{.ClusterIndex}}/{{.ClusterTotal}}{{with .CategoryNames}} ({{.}}){{end}}",
+	"it": "Aggiunta quota a {{.Count}} luoghi in {{.Place}} - gruppo {{.ClusterIndex}}/{{.ClusterTotal}}{{with .CategoryNames}} ({{.}}){{end}}",
+	"hu": "Magassági adat hozzáadása {{.Count}} helyhez ({{.Place}}) - {{.ClusterIndex}}/{{.ClusterTotal}}. csoport{{with .CategoryNames}} ({{.}}){{end}}",
+	"bg": "Добавяне на надморска височина към {{.Count}} обекта в {{.Place}} - група {{.ClusterIndex}}/{{.ClusterTotal}}{{with .CategoryNames}} ({{.}}){{end}}",
+	"pl": "Dodanie wysokości dla {{.Count}} miejsc w {{.Place}} - grupa {{.ClusterIndex}}/{{.ClusterTotal}}{{with .CategoryNames}} ({{.}}){{end}}",
+}
+
+// commentCategoryNames names the categories in each comment language
+var commentCategoryNames = map[string]map[string]string{
+	"en": {"peaks": "peaks", "alpine_huts": "alpine huts", "shelters": "shelters", "train_stations": "train stations", "other_accommodations": "accommodations"},
+	"ro": {"peaks": "vârfuri", "alpine_huts": "cabane", "shelters": "adăposturi", "train_stations": "gări", "other_accommodations": "cazări"},
+	"fr": {"peaks": "sommets", "alpine_huts": "refuges", "shelters": "abris", "train_stations": "gares", "other_accommodations": "hébergements"},
+	"de": {"peaks": "Gipfel", "alpine_huts": "Berghütten", "shelters": "Schutzhütten", "train_stations": "Bahnhöfe", "other_accommodations": "Unterkünfte"},
+	"es": {"peaks": "cumbres", "alpine_huts": "refugios de montaña", "shelters": "refugios", "train_stations": "estaciones de tren", "other_accommodations": "alojamientos"},
+	"it": {"peaks": "cime", "alpine_huts": "rifugi", "shelters": "bivacchi", "train_stations": "stazioni", "other_accommodations": "alloggi"},
+	"hu": {"peaks": "csúcsok", "alpine_huts": "menedékházak", "shelters": "esőbeállók", "train_stations": "vasútállomások", "other_accommodations": "szállások"},
+	"bg": {"peaks": "върхове", "alpine_huts": "хижи", "shelters": "заслони", "train_stations": "гари", "other_accommodations": "места за настаняване"},
+	"pl": {"peaks": "szczyty", "alpine_huts": "schroniska", "shelters": "wiaty", "train_stations": "stacje kolejowe", "other_accommodations": "noclegi"},
+}
+
+// categoryNames joins the names of category keys in a comment language, falling back to English
+func categoryNames(language string, keys []string) string {
+	names := make([]string, 0, len(keys))
+	for _, key := range keys {
+		name, ok := commentCategoryNames[language][key]
+		if !ok {
+			name = commentCategoryNames[defaultCommentLanguage][key]
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// CommentLanguageForCountry returns the changeset comment language for a country, falling back to English
+func CommentLanguageForCountry(country string) string {
+	if language, ok := countryCommentLanguages[country]; ok {
+		if _, hasTemplate := commentTemplates[language]; hasTemplate {
+			return language
+		}
+	}
+	return defaultCommentLanguage
+}
+
+// renderCommentTemplate executes a comment template with the given data
+func renderCommentTemplate(text string, data ChangesetCommentData) (string, error) {
+	tmpl, err := template.New("comment").Parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// ChangesetComment renders a custom comment template, or the localized comment when the template is empty
+func ChangesetComment(customTemplate string, data ChangesetCommentData) string {
+	if customTemplate != "" {
+		data.CategoryNames = categoryNames(CommentLanguageForCountry(data.Country), data.Categories)
+		if comment, err := renderCommentTemplate(customTemplate, data); err == nil {
+			return comment
+		}
+	}
+	return LocalizedChangesetComment(data)
+}
+
+// ValidateCommentTemplate checks that a custom comment template parses and renders
+func ValidateCommentTemplate(text string) error {
+	if _, err := renderCommentTemplate(text, ChangesetCommentData{Count: 1, Country: "România", ClusterIndex: 1, ClusterTotal: 1, Categories: []string{"peaks"}, CategoryNames: "vârfuri"}); err != nil {
+		return fmt.Errorf("invalid changeset comment template: %v", err)
+	}
+	return nil
+}
+
+// LocalizedChangesetComment builds the changeset comment in the local language of the country
+func LocalizedChangesetComment(data ChangesetCommentData) string {
+	language := CommentLanguageForCountry(data.Country)
+	data.CategoryNames = categoryNames(language, data.Categories)
+	if comment, err := renderCommentTemplate(commentTemplates[language], data); err == nil {
+		return comment
+	}
+
+	data.CategoryNames = categoryNames(defaultCommentLanguage, data.Categories)
+	comment, _ := renderCommentTemplate(commentTemplates[defaultCommentLanguage], data)
+	return comment
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommentLanguageForCountry(t *testing.T) {
+	tests := []struct {
+		country  string
+		expected string
+	}{
+		{"România", "ro"},
+		{"Moldova", "ro"},
+		{"France", "fr"},
+		{"Unknownland", "en"},
+		{"", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.country, func(t *testing.T) {
+			if got := CommentLanguageForCountry(tt.country); got != tt.expected {
+				t.Errorf("CommentLanguageForCountry(%q) = %q, want %q", tt.country, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLocalizedChangesetComment(t *testing.T) {
+	data := ChangesetCommentData{Count: 12, Country: "România", ClusterIndex: 2, ClusterTotal: 5, Categories: []string{"peaks", "shelters"}}
+	comment := LocalizedChangesetComment(data)
+	if !strings.Contains(comment, "12 locații în România") || !strings.Contains(comment, "2/5") {
+		t.Errorf("Unexpected Romanian comment: %q", comment)
+	}
+
+	data.Region = "Județul Cluj"
+	comment = LocalizedChangesetComment(data)
+	if !strings.Contains(comment, "12 locații în Județul Cluj, România") {
+		t.Errorf("Expected region in comment: %q", comment)
+	}
+
+	data.Region = ""
+	data.Country = "Narnia"
+	comment = LocalizedChangesetComment(data)
+	expected := "Add elevation data to 12 locations in Narnia - cluster 2/5 (peaks, shelters)"
+	if comment != expected {
+		t.Errorf("Fallback comment = %q, want %q", comment, expected)
+	}
+
+	data.Categories = nil
+	comment = LocalizedChangesetComment(data)
+	if expected := "Add elevation data to 12 locations in Narnia - cluster 2/5"; comment != expected {
+		t.Errorf("Comment without categories = %q, want %q", comment, expected)
+	}
+}
+
+func TestChangesetCommentCustomTemplate(t *testing.T) {
+	data := ChangesetCommentData{Count: 3, Country: "Moldova", ClusterIndex: 1, ClusterTotal: 2}
+
+	if got := ChangesetComment("{{.Count}} in {{.Place}} ({{.ClusterIndex}}/{{.ClusterTotal}})", data); got != "3 in Moldova (1/2)" {
+		t.Errorf("Unexpected custom comment: %q", got)
+	}
+	if got := ChangesetComment("", data); got != LocalizedChangesetComment(data) {
+		t.Errorf("Expected localized comment without template, got %q", got)
+	}
+
+	if err := ValidateCommentTemplate("{{.Count"); err == nil {
+		t.Error("Expected error for unparsable template")
+	}
+	if err := ValidateCommentTemplate("{{.Missing}}"); err == nil {
+		t.Error("Expected error for unknown field")
+	}
+}
+
+func TestUseCommentTemplate(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	if err := useCommentTemplate("Ele for {{.Unknown}}"); err == nil {
+		t.Error("Expected error for unknown template variable")
+	}
+	if err := useCommentTemplate("Ele for {{.Count"); err == nil {
+		t.Error("Expected error for unparsable template")
+	}
+
+	text := "Ele for {{.Count}} places in {{.Country}} ({{.ClusterIndex}})"
+	if err := useCommentTemplate(text); err != nil {
+		t.Fatalf("useCommentTemplate: %v", err)
+	}
+	t.Setenv("CHANGESET_COMMENT_TEMPLATE", "from environment")
+	config := NewConfig()
+	config.LoadFromEnv()
+	if got := config.Get("CHANGESET_COMMENT_TEMPLATE"); got != text {
+		t.Errorf("CHANGESET_COMMENT_TEMPLATE = %q, want the flag value", got)
+	}
+}
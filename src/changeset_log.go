@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultChangesetsFile lists every changeset created by the tool
+const DefaultChangesetsFile = "output/changesets.json"
+
+// ChangesetRecord describes a changeset created by an upload
+type ChangesetRecord struct {
+	ID        int         `json:"id"`
+	Country   string      `json:"country"`
+	BBox      ClusterBBox `json:"bbox"`
+	Elements  int         `json:"elements"`
+	Comment   string      `json:"comment"`
+	CreatedAt string      `json:"created_at"`
+	URL       string      `json:"url"`
+}
+
+// ClusterBBox is the bounding box of the cluster uploaded in a changeset
+type ClusterBBox struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+// ChangesetLog is the changesets file, appended to by every upload
+type ChangesetLog struct {
+	path       string
+	Changesets []ChangesetRecord `json:"changesets"`
+}
+
+// LoadChangesetLog reads the changesets file or starts an empty one
+func LoadChangesetLog(path string) (*ChangesetLog, error) {
+	changesetLog := &ChangesetLog{path: path, Changesets: []ChangesetRecord{}}
+	if err := loadJSON(path, changesetLog); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load changesets file %s: %v", path, err)
+	}
+	return changesetLog, nil
+}
+
+// Add records a changeset and persists the file immediately, so the record survives an
+// interrupted upload
+func (l *ChangesetLog) Add(record ChangesetRecord) error {
+	l.Changesets = append(l.Changesets, record)
+	return saveJSON(l.path, l)
+}
+
+// changesetWebURL returns the page of a changeset on the website of the API server
+func changesetWebURL(apiURL string, id int) string {
+	site := strings.TrimSuffix(strings.TrimSuffix(apiURL, "/"), "/api/0.6")
+	// The main API has its own host name; the sandbox serves the website and API together
+	site = strings.Replace(site, "://api.openstreetmap.org", "://www.openstreetmap.org", 1)
+	return fmt.Sprintf("%s/changeset/%d", site, id)
+}
+
+// recordChangeset adds the changeset just created for a cluster to the changesets file
+func (u *OSMUploader) recordChangeset(cluster ElementCluster, comment string) {
+	id := u.changesetManager.GetID()
+	if u.dryRun || id == 0 {
+		return
+	}
+	record := ChangesetRecord{
+		ID:      id,
+		Country: u.country,
+		BBox: ClusterBBox{
+			MinLat: cluster.BBox.MinLat,
+			MinLon: cluster.BBox.MinLon,
+			MaxLat: cluster.BBox.MaxLat,
+			MaxLon: cluster.BBox.MaxLon,
+		},
+		Elements:  len(cluster.Elements),
+		Comment:   comment,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		URL:       changesetWebURL(u.apiClient.baseURL, id),
+	}
+	u.created = append(u.created, record)
+	if u.changesetLog == nil {
+		return
+	}
+	if err := u.changesetLog.Add(record); err != nil {
+		uploadLog.Warn("Failed to record changeset #%d: %v", id, err)
+	}
+}
+
+// printChangesetLinks lists the changesets created by an upload with their openstreetmap.org pages
+func printChangesetLinks(records []ChangesetRecord, file string) {
+	if len(records) == 0 {
+		return
+	}
+	uploadLog.Info("Changesets created (%d):", len(records))
+	for _, record := range records {
+		uploadLog.Info("  %s  (%d elements)", record.URL, record.Elements)
+	}
+	if file != "" {
+		uploadLog.Info("✓ Changesets recorded in %s", file)
+	}
+}
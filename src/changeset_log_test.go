@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestChangesetWebURL(t *testing.T) {
+	tests := []struct {
+		apiURL   string
+		expected string
+	}{
+		{"https://api.openstreetmap.org/api/0.6", "https://www.openstreetmap.org/changeset/42"},
+		{"https://master.apis.dev.openstreetmap.org/api/0.6/", "https://master.apis.dev.openstreetmap.org/changeset/42"},
+		{"http://localhost:3000", "http://localhost:3000/changeset/42"},
+	}
+	for _, tt := range tests {
+		if got := changesetWebURL(tt.apiURL, 42); got != tt.expected {
+			t.Errorf("changesetWebURL(%q) = %q, want %q", tt.apiURL, got, tt.expected)
+		}
+	}
+}
+
+func TestRecordChangeset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "changesets.json")
+	changesetLog, err := LoadChangesetLog(path)
+	if err != nil {
+		t.Fatalf("LoadChangesetLog() error = %v", err)
+	}
+
+	api := NewOSMAPIClient(nil, false)
+	api.baseURL = "https://api.openstreetmap.org/api/0.6"
+	uploader := &OSMUploader{
+		country:          "România",
+		apiClient:        api,
+		changesetManager: &ChangesetManager{changesetID: 123},
+		changesetLog:     changesetLog,
+	}
+	cluster := ElementCluster{
+		Elements: []OSMElement{{ID: 1}, {ID: 2}},
+		BBox:     BoundingBox{MinLat: 45, MaxLat: 46, MinLon: 25, MaxLon: 26},
+	}
+	uploader.recordChangeset(cluster, "Add elevation")
+
+	// A dry run creates no changeset to record
+	dryRun := &OSMUploader{dryRun: true, changesetManager: &ChangesetManager{}, changesetLog: changesetLog}
+	dryRun.recordChangeset(cluster, "Add elevation")
+
+	reloaded, err := LoadChangesetLog(path)
+	if err != nil {
+		t.Fatalf("LoadChangesetLog() error = %v", err)
+	}
+	if len(reloaded.Changesets) != 1 || len(uploader.created) != 1 {
+		t.Fatalf("Expected one recorded changeset, got %+v", reloaded.Changesets)
+	}
+	record := reloaded.Changesets[0]
+	want := ClusterBBox{MinLat: 45, MinLon: 25, MaxLat: 46, MaxLon: 26}
+	if record.ID != 123 || record.Country != "România" || record.Elements != 2 || record.BBox != want ||
+		record.Comment != "Add elevation" || record.CreatedAt == "" {
+		t.Errorf("Unexpected record %+v", record)
+	}
+	if record.URL != "https://www.openstreetmap.org/changeset/123" {
+		t.Errorf("Unexpected URL %q", record.URL)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestChangesetMetadataTags(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want []ChangesetTag
+	}{
+		{
+			name: "defaults",
+			want: []ChangesetTag{
+				{Key: "created_by", Value: "elevate-romania/" + appVersion},
+				{Key: "bot", Value: "yes"},
+				{Key: "source", Value: "SRTM/OpenTopoData"},
+				{Key: "hashtags", Value: "#elevate"},
+			},
+		},
+		{
+			name: "configured",
+			env: map[string]string{
+				"CHANGESET_CREATED_BY": "elevate-romania/1.4.0",
+				"CHANGESET_SOURCE":     "SRTM",
+				"CHANGESET_HASHTAGS":   "none",
+			},
+			want: []ChangesetTag{
+				{Key: "created_by", Value: "elevate-romania/1.4.0"},
+				{Key: "bot", Value: "yes"},
+				{Key: "source", Value: "SRTM"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"CHANGESET_CREATED_BY", "CHANGESET_BOT", "CHANGESET_SOURCE", "CHANGESET_HASHTAGS"} {
+				t.Setenv(key, tt.env[key])
+			}
+			if got := changesetMetadataTags(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("changesetMetadataTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestChangesetCreateSendsMetadata(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	var sent OSMChangeset
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" || r.URL.Path != "/api/0.6/changeset/create" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if err := xml.Unmarshal(body, &sent); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, "123")
+	}))
+	defer server.Close()
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	cm := NewChangesetManager(server.Client(), false)
+	if err := cm.Create(context.Background(), "Add elevation"); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if cm.GetID() != 123 {
+		t.Errorf("changeset ID = %d, want 123", cm.GetID())
+	}
+
+	tags := make(map[string]string)
+	for _, tag := range sent.Changeset.Tags {
+		tags[tag.Key] = tag.Value
+	}
+	for _, key := range []string{"comment", "created_by", "bot", "source", "hashtags"} {
+		if tags[key] == "" {
+			t.Errorf("changeset tag %s missing: %v", key, tags)
+		}
+	}
+	if tags["comment"] != "Add elevation" || tags["bot"] != "yes" {
+		t.Errorf("unexpected changeset tags: %v", tags)
+	}
+}
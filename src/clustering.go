@@ -1,8 +1,8 @@
 package main
 
 import (
-	"fmt"
 	"math"
+	"sort"
 )
 
 // ElementCluster represents a group of OSM elements that are geographically close
@@ -19,7 +19,9 @@ type elementWithCoord struct {
 }
 
 // ClusterElements groups OSM elements by geographic proximity to avoid OSM changeset
-// bounding box size limits. Uses a grid-based approach for efficiency.
+// bounding box size limits. maxBBoxDiagonal is in kilometers. Uses a grid-based approach
+// for efficiency. The result only depends on the set of elements, not on their order, so a
+// dry run and the following upload produce the same changesets.
 func ClusterElements(elements []OSMElement, maxBBoxDiagonal float64) []ElementCluster {
 	if len(elements) == 0 {
 		return []ElementCluster{}
@@ -38,26 +40,39 @@ func ClusterElements(elements []OSMElement, maxBBoxDiagonal float64) []ElementCl
 	if len(elementsWithCoords) == 0 {
 		return []ElementCluster{}
 	}
+	sortByElementID(elementsWithCoords)
 	
 	// Calculate grid cell size based on maxBBoxDiagonal
 	// Use half the max diagonal to ensure cells can merge if needed
 	cellSize := maxBBoxDiagonal / 2.0
 	
 	// Create grid-based clusters
-	gridClusters := make(map[string][]elementWithCoord)
+	gridClusters := make(map[[2]int][]elementWithCoord)
 	
 	for _, ewc := range elementsWithCoords {
 		// Calculate grid cell for this coordinate
-		cellLat := math.Floor(ewc.coord.Lat / cellSize)
-		cellLon := math.Floor(ewc.coord.Lon / cellSize)
-		cellKey := fmt.Sprintf("%d,%d", int(cellLat), int(cellLon))
+		cellLat, cellLon := gridCell(ewc.coord, cellSize)
+		cellKey := [2]int{cellLat, cellLon}
 		
 		gridClusters[cellKey] = append(gridClusters[cellKey], ewc)
 	}
 	
+	// Visit the cells south to north, then west to east, so clusters come out in a stable order
+	cellKeys := make([][2]int, 0, len(gridClusters))
+	for key := range gridClusters {
+		cellKeys = append(cellKeys, key)
+	}
+	sort.Slice(cellKeys, func(i, j int) bool {
+		if cellKeys[i][0] != cellKeys[j][0] {
+			return cellKeys[i][0] < cellKeys[j][0]
+		}
+		return cellKeys[i][1] < cellKeys[j][1]
+	})
+	
 	// Convert grid clusters to ElementCluster objects
 	var clusters []ElementCluster
-	for _, cellElements := range gridClusters {
+	for _, cellKey := range cellKeys {
+		cellElements := gridClusters[cellKey]
 		if len(cellElements) == 0 {
 			continue
 		}
@@ -73,7 +88,7 @@ func ClusterElements(elements []OSMElement, maxBBoxDiagonal float64) []ElementCl
 		centroid := Centroid(coords)
 		
 		// Check if this cluster's bounding box is acceptable
-		if bbox.Diagonal() <= maxBBoxDiagonal {
+		if bbox.DiagonalKm() <= maxBBoxDiagonal {
 			clusters = append(clusters, ElementCluster{
 				Elements: elements,
 				BBox:     bbox,
@@ -89,6 +104,90 @@ func ClusterElements(elements []OSMElement, maxBBoxDiagonal float64) []ElementCl
 	return clusters
 }
 
+// sortByElementID orders elements by type and ID
+func sortByElementID(elements []elementWithCoord) {
+	sort.Slice(elements, func(i, j int) bool {
+		if elements[i].element.Type != elements[j].element.Type {
+			return elements[i].element.Type < elements[j].element.Type
+		}
+		return elements[i].element.ID < elements[j].element.ID
+	})
+}
+
+// gridCell returns the row and column of the grid cell containing c. Rows are cellKm high;
+// columns are cellKm wide at the row's edge nearest the equator, so no cell is wider than
+// cellKm however far from the equator it lies.
+func gridCell(c Coordinates, cellKm float64) (int, int) {
+	kmPerDegree := metersPerDegreeLat / 1000
+	latStep := cellKm / kmPerDegree
+	row := math.Floor(c.Lat / latStep)
+
+	edgeLat := math.Min(math.Abs(row*latStep), math.Abs((row+1)*latStep))
+	lonStep := 360.0
+	if width := kmPerDegree * math.Cos(edgeLat*math.Pi/180); width > 0 && cellKm/width < lonStep {
+		lonStep = cellKm / width
+	}
+	return int(row), int(math.Floor(c.Lon / lonStep))
+}
+
+// SplitOversizedClusters caps the number of elements per cluster, and thus per changeset.
+// Clusters with more than maxElements elements are cut into nearly equal parts along the
+// longer side of their bounding box, so each part stays compact. maxElements <= 0 disables the cap.
+func SplitOversizedClusters(clusters []ElementCluster, maxElements int) []ElementCluster {
+	if maxElements <= 0 {
+		return clusters
+	}
+
+	extractor := NewCoordinateExtractor()
+	var result []ElementCluster
+	for _, cluster := range clusters {
+		if len(cluster.Elements) <= maxElements {
+			result = append(result, cluster)
+			continue
+		}
+
+		elements := make([]elementWithCoord, 0, len(cluster.Elements))
+		for _, elem := range cluster.Elements {
+			coord, _ := extractor.Extract(elem)
+			elements = append(elements, elementWithCoord{elem, coord})
+		}
+
+		byLat := cluster.BBox.MaxLat-cluster.BBox.MinLat >= cluster.BBox.MaxLon-cluster.BBox.MinLon
+		sort.SliceStable(elements, func(i, j int) bool {
+			if byLat {
+				return elements[i].coord.Lat < elements[j].coord.Lat
+			}
+			return elements[i].coord.Lon < elements[j].coord.Lon
+		})
+
+		parts := (len(elements) + maxElements - 1) / maxElements
+		size := (len(elements) + parts - 1) / parts
+		for start := 0; start < len(elements); start += size {
+			end := start + size
+			if end > len(elements) {
+				end = len(elements)
+			}
+			result = append(result, newElementCluster(elements[start:end]))
+		}
+	}
+	return result
+}
+
+// newElementCluster builds a cluster with its bounding box and centroid
+func newElementCluster(elements []elementWithCoord) ElementCluster {
+	clusterElements := make([]OSMElement, len(elements))
+	coords := make([]Coordinates, len(elements))
+	for i, ewc := range elements {
+		clusterElements[i] = ewc.element
+		coords[i] = ewc.coord
+	}
+	return ElementCluster{
+		Elements: clusterElements,
+		BBox:     NewBoundingBox(coords),
+		Centroid: Centroid(coords),
+	}
+}
+
 // splitLargeCluster splits a cluster that's still too large into smaller clusters
 // using a simple k-means-like approach
 func splitLargeCluster(elements []elementWithCoord, maxBBoxDiagonal float64) []ElementCluster {
@@ -111,7 +210,7 @@ func splitLargeCluster(elements []elementWithCoord, maxBBoxDiagonal float64) []E
 		coords[i] = ewc.coord
 	}
 	bbox := NewBoundingBox(coords)
-	currentDiagonal := bbox.Diagonal()
+	currentDiagonal := bbox.DiagonalKm()
 	
 	// Estimate number of clusters needed (add safety margin)
 	numClusters := int(math.Ceil(currentDiagonal/maxBBoxDiagonal)) + 1
@@ -125,7 +224,9 @@ func splitLargeCluster(elements []elementWithCoord, maxBBoxDiagonal float64) []E
 	return clusters
 }
 
-// simpleKMeans performs a simple k-means clustering on elements
+// simpleKMeans performs a simple k-means clustering on elements. The centroids are seeded
+// evenly along the diagonal of the elements' bounding box rather than at random, and ties go
+// to the lowest centroid index, so the same elements always give the same clusters.
 func simpleKMeans(elements []elementWithCoord, k int, maxBBoxDiagonal float64) []ElementCluster {
 	if len(elements) <= k {
 		// If we have fewer elements than clusters, one per cluster
@@ -229,3 +330,18 @@ func simpleKMeans(elements []elementWithCoord, k int, maxBBoxDiagonal float64) [
 	
 	return finalClusters
 }
+
+// splitClusterAt splits a cluster after its first n elements
+func splitClusterAt(cluster ElementCluster, n int) (ElementCluster, ElementCluster) {
+	extractor := NewCoordinateExtractor()
+	var head, tail []elementWithCoord
+	for i, elem := range cluster.Elements {
+		coord, _ := extractor.Extract(elem)
+		if i < n {
+			head = append(head, elementWithCoord{elem, coord})
+		} else {
+			tail = append(tail, elementWithCoord{elem, coord})
+		}
+	}
+	return newElementCluster(head), newElementCluster(tail)
+}
@@ -38,7 +38,7 @@ func TestClusteringIntegration(t *testing.T) {
 	}
 	overallBBox := NewBoundingBox(coords)
 
-	t.Logf("Overall bounding box diagonal: %.4f degrees", overallBBox.Diagonal())
+	t.Logf("Overall bounding box diagonal: %.1f km", overallBBox.DiagonalKm())
 
 	// Cluster the elements
 	clusters := ClusterElements(elements, MaxBoundingBoxDiagonal)
@@ -48,11 +48,11 @@ func TestClusteringIntegration(t *testing.T) {
 	// Verify all clusters are within limits
 	totalElements := 0
 	for i, cluster := range clusters {
-		diagonal := cluster.BBox.Diagonal()
-		t.Logf("Cluster %d: %d elements, diagonal: %.4f degrees", i+1, len(cluster.Elements), diagonal)
+		diagonal := cluster.BBox.DiagonalKm()
+		t.Logf("Cluster %d: %d elements, diagonal: %.1f km", i+1, len(cluster.Elements), diagonal)
 
 		if diagonal > MaxBoundingBoxDiagonal {
-			t.Errorf("Cluster %d exceeds maximum diagonal (%.4f > %.2f)", i+1, diagonal, MaxBoundingBoxDiagonal)
+			t.Errorf("Cluster %d exceeds maximum diagonal (%.1f > %.0f km)", i+1, diagonal, MaxBoundingBoxDiagonal)
 		}
 
 		totalElements += len(cluster.Elements)
@@ -96,13 +96,13 @@ func TestRealWorldScenarioRussia(t *testing.T) {
 		}
 	}
 	overallBBox := NewBoundingBox(coords)
-	overallDiagonal := overallBBox.Diagonal()
+	overallDiagonal := overallBBox.DiagonalKm()
 
-	t.Logf("Russia scenario: Overall diagonal = %.2f degrees (HUGE!)", overallDiagonal)
+	t.Logf("Russia scenario: Overall diagonal = %.0f km (HUGE!)", overallDiagonal)
 
 	// This should definitely be larger than our limit
 	if overallDiagonal <= MaxBoundingBoxDiagonal {
-		t.Errorf("Test setup error: Expected overall diagonal > %.2f, got %.2f", MaxBoundingBoxDiagonal, overallDiagonal)
+		t.Errorf("Test setup error: Expected overall diagonal > %.0f km, got %.0f", MaxBoundingBoxDiagonal, overallDiagonal)
 	}
 
 	// Cluster the elements
@@ -112,9 +112,9 @@ func TestRealWorldScenarioRussia(t *testing.T) {
 
 	// Verify all clusters are within limits
 	for i, cluster := range clusters {
-		diagonal := cluster.BBox.Diagonal()
+		diagonal := cluster.BBox.DiagonalKm()
 		if diagonal > MaxBoundingBoxDiagonal {
-			t.Errorf("Cluster %d exceeds limit: %.4f > %.2f", i+1, diagonal, MaxBoundingBoxDiagonal)
+			t.Errorf("Cluster %d exceeds limit: %.1f > %.0f km", i+1, diagonal, MaxBoundingBoxDiagonal)
 		}
 	}
 
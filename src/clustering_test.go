@@ -1,6 +1,8 @@
 package main
 
 import (
+	"math/rand"
+	"reflect"
 	"testing"
 )
 
@@ -161,7 +163,7 @@ func TestClusterElements(t *testing.T) {
 		{
 			name:             "Empty elements",
 			elements:         []OSMElement{},
-			maxBBoxDiagonal:  0.5,
+			maxBBoxDiagonal:  50,
 			expectedClusters: 0,
 		},
 		{
@@ -169,7 +171,7 @@ func TestClusterElements(t *testing.T) {
 			elements: []OSMElement{
 				{ID: 1, Type: "node", Lat: 45.0, Lon: 25.0, Tags: map[string]string{"tourism": "alpine_hut"}},
 			},
-			maxBBoxDiagonal:  0.5,
+			maxBBoxDiagonal:  50,
 			expectedClusters: 1,
 			checkFunc: func(t *testing.T, clusters []ElementCluster) {
 				if len(clusters[0].Elements) != 1 {
@@ -183,7 +185,7 @@ func TestClusterElements(t *testing.T) {
 				{ID: 1, Type: "node", Lat: 45.0, Lon: 25.0, Tags: map[string]string{"tourism": "alpine_hut"}},
 				{ID: 2, Type: "node", Lat: 45.01, Lon: 25.01, Tags: map[string]string{"railway": "station"}},
 			},
-			maxBBoxDiagonal:  0.5,
+			maxBBoxDiagonal:  50,
 			expectedClusters: 1,
 			checkFunc: func(t *testing.T, clusters []ElementCluster) {
 				if len(clusters[0].Elements) != 2 {
@@ -197,7 +199,7 @@ func TestClusterElements(t *testing.T) {
 				{ID: 1, Type: "node", Lat: 45.0, Lon: 25.0, Tags: map[string]string{"tourism": "alpine_hut"}},
 				{ID: 2, Type: "node", Lat: 48.0, Lon: 28.0, Tags: map[string]string{"railway": "station"}},
 			},
-			maxBBoxDiagonal:  0.5,
+			maxBBoxDiagonal:  50,
 			expectedClusters: 2,
 			checkFunc: func(t *testing.T, clusters []ElementCluster) {
 				for _, cluster := range clusters {
@@ -205,8 +207,8 @@ func TestClusterElements(t *testing.T) {
 						t.Errorf("Expected 1 element per cluster, got %d", len(cluster.Elements))
 					}
 					// Check that bounding box diagonal is within limits
-					if cluster.BBox.Diagonal() > 0.5 {
-						t.Errorf("Cluster bounding box diagonal %f exceeds maximum 0.5", cluster.BBox.Diagonal())
+					if cluster.BBox.DiagonalKm() > 50 {
+						t.Errorf("Cluster bounding box diagonal %f km exceeds maximum 50", cluster.BBox.DiagonalKm())
 					}
 				}
 			},
@@ -232,11 +234,21 @@ func TestCategoryToKey(t *testing.T) {
 		category ElementCategory
 		expected string
 	}{
+		{
+			name:     "Peak",
+			category: CategoryPeak,
+			expected: "peaks",
+		},
 		{
 			name:     "Alpine hut",
 			category: CategoryAlpineHut,
 			expected: "alpine_huts",
 		},
+		{
+			name:     "Shelter",
+			category: CategoryShelter,
+			expected: "shelters",
+		},
 		{
 			name:     "Train station",
 			category: CategoryTrainStation,
@@ -263,3 +275,147 @@ func TestCategoryToKey(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitOversizedClusters(t *testing.T) {
+	var elements []OSMElement
+	for i := 0; i < 1200; i++ {
+		elements = append(elements, OSMElement{ID: int64(i + 1), Type: "node", Lat: 45.0 + float64(i%40)*0.001, Lon: 25.0 + float64(i/40)*0.001})
+	}
+	big := ClusterElements(elements, 50)
+	if len(big) != 1 {
+		t.Fatalf("Expected a single dense cluster, got %d", len(big))
+	}
+	small := ClusterElements([]OSMElement{{ID: 9999, Type: "node", Lat: 47, Lon: 27}}, 50)
+	clusters := append(big, small...)
+
+	if got := SplitOversizedClusters(clusters, 0); len(got) != 2 {
+		t.Errorf("Expected no split without a cap, got %d clusters", len(got))
+	}
+
+	split := SplitOversizedClusters(clusters, 500)
+	if len(split) != 4 {
+		t.Fatalf("Expected 3 parts plus the small cluster, got %d clusters", len(split))
+	}
+
+	seen := make(map[int64]bool)
+	for _, cluster := range split {
+		if len(cluster.Elements) > 500 {
+			t.Errorf("Cluster has %d elements, want at most 500", len(cluster.Elements))
+		}
+		for _, element := range cluster.Elements {
+			if seen[element.ID] {
+				t.Errorf("Element %d appears in more than one cluster", element.ID)
+			}
+			seen[element.ID] = true
+		}
+		if cluster.BBox.Diagonal() > big[0].BBox.Diagonal() {
+			t.Errorf("Part bounding box %v larger than the original", cluster.BBox)
+		}
+	}
+	if len(seen) != 1201 {
+		t.Errorf("Expected all 1201 elements after splitting, got %d", len(seen))
+	}
+	if sizes := []int{len(split[0].Elements), len(split[1].Elements), len(split[2].Elements)}; sizes[0] != 400 || sizes[1] != 400 || sizes[2] != 400 {
+		t.Errorf("Expected three equal parts of 400, got %v", sizes)
+	}
+}
+
+func TestClusterElementsKilometres(t *testing.T) {
+	tests := []struct {
+		name             string
+		elements         []OSMElement
+		expectedClusters int
+	}{
+		{
+			// 0.3° of longitude is only 11 km at 70°N
+			name: "Near the pole",
+			elements: []OSMElement{
+				{ID: 1, Type: "node", Lat: 70.01, Lon: 24.95},
+				{ID: 2, Type: "node", Lat: 70.02, Lon: 25.25},
+			},
+			expectedClusters: 1,
+		},
+		{
+			// 0.2° by 0.2° is 31 km across at the equator
+			name: "At the equator",
+			elements: []OSMElement{
+				{ID: 1, Type: "node", Lat: 0.01, Lon: 25.01},
+				{ID: 2, Type: "node", Lat: 0.21, Lon: 25.21},
+			},
+			expectedClusters: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters := ClusterElements(tt.elements, MaxBoundingBoxDiagonal)
+			if len(clusters) != tt.expectedClusters {
+				t.Errorf("ClusterElements() returned %d clusters, want %d", len(clusters), tt.expectedClusters)
+			}
+			for _, cluster := range clusters {
+				if cluster.BBox.DiagonalKm() > MaxBoundingBoxDiagonal {
+					t.Errorf("Cluster diagonal %.1f km exceeds %.0f km", cluster.BBox.DiagonalKm(), MaxBoundingBoxDiagonal)
+				}
+			}
+		})
+	}
+}
+
+func TestGridCellWidth(t *testing.T) {
+	for _, lat := range []float64{0, 45, -60, 80, 89.99} {
+		// Walk east from a cell's west edge until the column changes
+		row, col := gridCell(Coordinates{Lat: lat, Lon: 10}, 14)
+		lon := 10.0
+		for {
+			if _, c := gridCell(Coordinates{Lat: lat, Lon: lon - 0.001}, 14); c != col {
+				break
+			}
+			lon -= 0.001
+		}
+		start := lon
+		for {
+			lon += 0.001
+			if r, c := gridCell(Coordinates{Lat: lat, Lon: lon}, 14); r != row || c != col || lon > 370 {
+				break
+			}
+		}
+		if width := HaversineDistance(Coordinates{Lat: lat, Lon: start}, Coordinates{Lat: lat, Lon: lon}); width > 14.1 {
+			t.Errorf("Cell at latitude %.2f is %.1f km wide, want at most 14", lat, width)
+		}
+	}
+}
+
+func TestClusterElementsDeterministic(t *testing.T) {
+	rng := rand.New(rand.NewSource(42))
+	var elements []OSMElement
+	for i := 0; i < 400; i++ {
+		elements = append(elements, OSMElement{ID: int64(i + 1), Type: "node", Lat: 44 + rng.Float64()*4, Lon: 21 + rng.Float64()*8})
+	}
+	// A dense spot that needs the k-means split
+	for i := 0; i < 50; i++ {
+		elements = append(elements, OSMElement{ID: int64(1000 + i), Type: "way", Lat: 45.5 + rng.Float64()*0.3, Lon: 25 + rng.Float64()*0.3})
+	}
+
+	layout := func(clusters []ElementCluster) [][]int64 {
+		var ids [][]int64
+		for _, cluster := range clusters {
+			var clusterIDs []int64
+			for _, element := range cluster.Elements {
+				clusterIDs = append(clusterIDs, element.ID)
+			}
+			ids = append(ids, clusterIDs)
+		}
+		return ids
+	}
+
+	want := layout(SplitOversizedClusters(ClusterElements(elements, MaxBoundingBoxDiagonal), 20))
+	for run := 0; run < 20; run++ {
+		shuffled := append([]OSMElement(nil), elements...)
+		rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+		got := layout(SplitOversizedClusters(ClusterElements(shuffled, MaxBoundingBoxDiagonal), 20))
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("Run %d: clusters differ after shuffling the input", run+1)
+		}
+	}
+}
@@ -0,0 +1,1221 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Command is a CLI subcommand with its own flags and help text
+type Command struct {
+	Name    string
+	Summary string
+	// Args describes positional arguments in the usage line
+	Args string
+	// Setup registers the command's flags and returns the function that runs it once they are parsed
+	Setup       func(fs *flag.FlagSet) CommandFunc
+	Subcommands []*Command
+}
+
+// CommandFunc runs a command with its positional arguments; ctx is canceled on SIGINT/SIGTERM
+type CommandFunc func(ctx context.Context, args []string) error
+
+// commandName is the program name shown in usage text
+const commandName = "elevate-romania"
+
+// rootCommands returns the top-level subcommands
+func rootCommands() []*Command {
+	return []*Command{
+		{Name: "run", Summary: "Run the full pipeline (extract, filter, enrich, validate, export CSV, upload)", Setup: setupRun},
+		{Name: "extract", Summary: "Extract elements without elevation from OSM", Setup: setupExtract},
+		{Name: "filter", Summary: "Filter elements without elevation", Setup: setupFilter},
+		{Name: "enrich", Summary: "Enrich with elevation data", Setup: setupEnrich},
+		{Name: "validate", Summary: "Validate elevation ranges", Setup: setupValidate},
+		{Name: "export", Summary: "Export validated data", Subcommands: []*Command{
+			{Name: "csv", Summary: "Export to CSV", Setup: setupExportCSV},
+			{Name: "osc", Summary: "Export planned edits as an osmChange (.osc) file for review in JOSM", Setup: setupExportOSC},
+			{Name: "preview", Summary: "Write an HTML map preview of the enriched elements", Setup: setupExportPreview},
+			{Name: "report", Summary: "Write an HTML report of statistics, elevations, validation failures and changesets", Setup: setupExportReport},
+			{Name: "umap", Summary: "Export validated elements as GeoJSON styled for a uMap review map", Setup: setupExportUMap},
+		}},
+		{Name: "upload", Summary: "Upload to OSM", Setup: setupUpload},
+		{Name: "import", Summary: "Validate and upload elevations from an external CSV (type, id, ele)", Setup: setupImport},
+		{Name: "retry-errors", Summary: "Upload again the elements that failed in the last upload, in fresh changesets", Setup: setupRetryErrors},
+		{Name: "propose", Summary: "Compute exact element diffs and write a signed proposal file", Setup: setupPropose},
+		{Name: "apply", Summary: "Execute a previously generated proposal file", Setup: setupApply},
+		{Name: "stats", Summary: "Count features with and without ele per category and save the coverage (nothing is uploaded)", Setup: setupStats},
+		{Name: "history", Summary: "Show the elements uploaded over time, per country and category", Setup: setupHistory},
+		{Name: "audit", Summary: "Report existing ele tags that differ from the DEM (nothing is uploaded)", Setup: setupAudit},
+		{Name: "revert", Summary: "Revert the ele/ele:source edits of a changeset", Setup: setupRevert},
+		{Name: "merge", Summary: "Merge enriched or validated files into one dataset", Args: "FILE...", Setup: setupMerge},
+		{Name: "countries", Summary: "List or process all countries", Subcommands: []*Command{
+			{Name: "list", Summary: "List all available admin_level=2 countries", Setup: setupCountriesList},
+			{Name: "process", Summary: "Run the full pipeline for every country", Setup: setupCountriesProcess},
+		}},
+		{Name: "daemon", Summary: "Re-run the incremental pipeline for the configured countries at a fixed interval", Setup: setupDaemon},
+	}
+}
+
+// isSubcommand reports whether the command line uses subcommands rather than the legacy step flags
+func isSubcommand(args []string) bool {
+	return len(args) > 0 && !strings.HasPrefix(args[0], "-")
+}
+
+// runCommand dispatches args to the matching subcommand
+func runCommand(ctx context.Context, commands []*Command, args []string) error {
+	return dispatch(ctx, commandName, commands, args)
+}
+
+// dispatch finds the subcommand named by args[0] among commands and runs it
+func dispatch(ctx context.Context, path string, commands []*Command, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		if len(args) > 1 && args[0] == "help" {
+			return dispatch(ctx, path, commands, append(args[1:], "--help"))
+		}
+		printCommands(path, commands)
+		return nil
+	}
+
+	for _, cmd := range commands {
+		if cmd.Name != args[0] {
+			continue
+		}
+		cmdPath := path + " " + cmd.Name
+		if len(cmd.Subcommands) > 0 {
+			return dispatch(ctx, cmdPath, cmd.Subcommands, args[1:])
+		}
+		return cmd.run(ctx, cmdPath, args[1:])
+	}
+
+	printCommands(path, commands)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+// run parses the command's flags and executes it
+func (c *Command) run(ctx context.Context, path string, args []string) error {
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	runner := c.Setup(fs)
+	configFile := registerConfigFlag(fs)
+	applyLogging := registerLogFlags(fs)
+	applyWorkspace := registerWorkspaceFlag(fs)
+	fs.Usage = func() {
+		usage := path + " [flags]"
+		if c.Args != "" {
+			usage += " " + c.Args
+		}
+		fmt.Fprintf(fs.Output(), "%s\n\nUsage:\n  %s\n\nFlags:\n", c.Summary, usage)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil
+		}
+		return err
+	}
+	if err := applyConfigFile(*configFile, fs); err != nil {
+		return err
+	}
+	applyLogging()
+	applyWorkspace()
+	runConfig := NewConfig()
+	runConfig.LoadFromEnv()
+	if err := startLogging(runConfig); err != nil {
+		return err
+	}
+	useWorkspace(runConfig)
+	if c.Args == "" && fs.NArg() > 0 {
+		return fmt.Errorf("%s: unexpected arguments %v", path, fs.Args())
+	}
+	return runner(ctx, fs.Args())
+}
+
+// printCommands prints the available subcommands
+func printCommands(path string, commands []*Command) {
+	fmt.Fprintf(os.Stderr, "Usage:\n  %s <command> [flags]\n\nCommands:\n", path)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", cmd.Name, cmd.Summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s help <command>' for the flags of a command.\n", path)
+}
+
+// areaFlags are the flags that select the country or area a command processes
+type areaFlags struct {
+	fs             *flag.FlagSet
+	country        *string
+	countryCode    *string
+	bbox           *string
+	areaRelationID *int64
+	region         *string
+	adminLevel     *int
+}
+
+// registerAreaFlags adds the area selection flags to a flag set
+func registerAreaFlags(fs *flag.FlagSet) *areaFlags {
+	return &areaFlags{
+		fs:             fs,
+		country:        fs.String("country", "România", "Country name to target (int_name from OSM)"),
+		countryCode:    fs.String("country-code", "", "Select the country by ISO3166-1 code (e.g. RO) instead of by name"),
+		bbox:           fs.String("bbox", "", "Process a rectangle minLat,minLon,maxLat,maxLon instead of the whole country (e.g. 45.2,22.5,47.8,26.5)"),
+		areaRelationID: fs.Int64("area-relation-id", 0, "Process the area of any OSM boundary relation (county, national park, ...) instead of the country"),
+		region:         fs.String("region", "", "Process a single administrative region of --country by name (e.g. \"Județul Cluj\")"),
+		adminLevel:     fs.Int("admin-level", 0, "admin_level of --region (default 4)"),
+	}
+}
+
+// resolve validates the area flags and returns the selector and the country name
+func (f *areaFlags) resolve(ctx context.Context) (AreaSelector, string, error) {
+	area := AreaSelector{
+		RelationID:  *f.areaRelationID,
+		Region:      *f.region,
+		AdminLevel:  *f.adminLevel,
+		CountryCode: NormalizeCountryCode(*f.countryCode),
+	}
+	if *f.bbox != "" {
+		bbox, err := ParseBoundingBox(*f.bbox)
+		if err != nil {
+			return AreaSelector{}, "", err
+		}
+		area.BBox = &bbox
+	}
+	if err := area.Validate(); err != nil {
+		return AreaSelector{}, "", err
+	}
+
+	country := *f.country
+	if area.CountryCode != "" && !flagWasSet(f.fs, "country") {
+		// The name is still needed for changeset comments and their language
+		info, err := lookupCountryByCode(ctx, area.CountryCode)
+		if err != nil {
+			return AreaSelector{}, "", fmt.Errorf("country code lookup failed: %v", err)
+		}
+		country = info.Name
+		pipelineLog.Info("Country code %s: %s", area.CountryCode, info.Name)
+	}
+	return area, country, nil
+}
+
+// uploadFlags are the flags shared by the commands that upload
+type uploadFlags struct {
+	dryRun           *bool
+	oauthInteractive *bool
+	uploadMode       *string
+	reupload         *bool
+	retryErrors      *string
+	review           *bool
+	commentTemplate  *string
+	applyOSMAPI      func() error
+	applyMaxEdits    func() error
+	applyMaxBBox     func() error
+	applyConcurrency func() error
+	applyMaxFailures func() error
+	applyUser        func()
+	applyDripFeed    func() error
+}
+
+// registerUploadFlags adds the upload flags to a flag set
+func registerUploadFlags(fs *flag.FlagSet) *uploadFlags {
+	uploadMode := fs.String("upload-mode", UploadModeDiff, "Upload mode: diff (one osmChange per changeset) or element (one PUT per element)")
+	return &uploadFlags{
+		dryRun:           fs.Bool("dry-run", false, "Dry-run mode (don't upload)"),
+		oauthInteractive: fs.Bool("oauth-interactive", false, "Interactive OAuth setup"),
+		uploadMode:       uploadMode,
+		reupload:         fs.Bool("reupload", false, "Upload elements again even if the run ledger records them as already uploaded"),
+		retryErrors:      fs.String("retry-errors", "", "Only retry elements that failed with these error classes in the last run (e.g. conflict,network)"),
+		review:           fs.Bool("review", false, "Accept or reject each pending edit in the terminal before uploading"),
+		commentTemplate:  registerCommentTemplateFlag(fs),
+		applyOSMAPI:      registerOSMAPIFlags(fs),
+		applyMaxEdits:    registerMaxEditsFlag(fs),
+		applyMaxBBox:     registerMaxBBoxDiagonalFlag(fs),
+		applyConcurrency: registerUploadConcurrencyFlag(fs, uploadMode),
+		applyMaxFailures: registerMaxConsecutiveFailuresFlag(fs),
+		applyUser:        registerRequireUserFlag(fs),
+		applyDripFeed:    registerDripFeedFlags(fs),
+	}
+}
+
+// apply activates the upload target and comment template, so mistakes surface before the pipeline runs
+func (f *uploadFlags) apply() error {
+	if err := f.applyOSMAPI(); err != nil {
+		return err
+	}
+	if err := f.applyMaxEdits(); err != nil {
+		return err
+	}
+	if err := f.applyMaxBBox(); err != nil {
+		return err
+	}
+	if err := f.applyConcurrency(); err != nil {
+		return err
+	}
+	if err := f.applyMaxFailures(); err != nil {
+		return err
+	}
+	if err := f.applyDripFeed(); err != nil {
+		return err
+	}
+	f.applyUser()
+	return useCommentTemplate(*f.commentTemplate)
+}
+
+// registerMaxEditsFlag adds --max-edits-per-changeset and returns a function that applies it when given
+func registerMaxEditsFlag(fs *flag.FlagSet) func() error {
+	maxEdits := fs.Int("max-edits-per-changeset", 500, "Split clusters so no changeset has more edits than this (0 = unlimited; default: MAX_EDITS_PER_CHANGESET)")
+	return func() error {
+		if !flagWasSet(fs, "max-edits-per-changeset") {
+			return nil
+		}
+		if *maxEdits < 0 {
+			return fmt.Errorf("--max-edits-per-changeset must not be negative")
+		}
+		flagConfig.Set("MAX_EDITS_PER_CHANGESET", strconv.Itoa(*maxEdits))
+		return nil
+	}
+}
+
+// registerMaxBBoxDiagonalFlag adds --max-bbox-diagonal and returns a function that applies it when given
+func registerMaxBBoxDiagonalFlag(fs *flag.FlagSet) func() error {
+	diagonal := fs.Float64("max-bbox-diagonal", MaxBoundingBoxDiagonal, "Maximum changeset bounding box diagonal in km (default: MAX_BBOX_DIAGONAL_KM)")
+	return func() error {
+		if !flagWasSet(fs, "max-bbox-diagonal") {
+			return nil
+		}
+		if *diagonal <= 0 || *diagonal > MaxAllowedBoundingBoxDiagonal {
+			return fmt.Errorf("--max-bbox-diagonal must be more than 0 and at most %.0f km", MaxAllowedBoundingBoxDiagonal)
+		}
+		flagConfig.Set("MAX_BBOX_DIAGONAL_KM", strconv.FormatFloat(*diagonal, 'f', -1, 64))
+		return nil
+	}
+}
+
+// registerMaxConsecutiveFailuresFlag adds --max-consecutive-failures and returns a function that applies it when given
+func registerMaxConsecutiveFailuresFlag(fs *flag.FlagSet) func() error {
+	limit := fs.Int("max-consecutive-failures", DefaultMaxConsecutiveFailures, "Abort the upload after this many failures in a row (0 = never; default: MAX_CONSECUTIVE_FAILURES)")
+	return func() error {
+		if !flagWasSet(fs, "max-consecutive-failures") {
+			return nil
+		}
+		if *limit < 0 {
+			return fmt.Errorf("--max-consecutive-failures must not be negative")
+		}
+		flagConfig.Set("MAX_CONSECUTIVE_FAILURES", strconv.Itoa(*limit))
+		return nil
+	}
+}
+
+// registerUploadConcurrencyFlag adds --upload-concurrency and returns a function that applies it when
+// given. Only element mode uploads in parallel, so more than one worker is rejected in diff mode.
+func registerUploadConcurrencyFlag(fs *flag.FlagSet, uploadMode *string) func() error {
+	concurrency := fs.Int("upload-concurrency", 1, "Elements updated in parallel within a changeset; element mode only, diff mode uploads each changeset as one request (default: UPLOAD_CONCURRENCY)")
+	return func() error {
+		if !flagWasSet(fs, "upload-concurrency") {
+			return nil
+		}
+		if *concurrency < 1 || *concurrency > maxUploadConcurrency {
+			return fmt.Errorf("--upload-concurrency must be between 1 and %d", maxUploadConcurrency)
+		}
+		if mode, err := ParseUploadMode(*uploadMode); err == nil && mode != UploadModeElement && *concurrency > 1 {
+			return fmt.Errorf("--upload-concurrency only applies to --upload-mode element; diff mode uploads each changeset as a single request")
+		}
+		flagConfig.Set("UPLOAD_CONCURRENCY", strconv.Itoa(*concurrency))
+		return nil
+	}
+}
+
+// registerCommentTemplateFlag adds --changeset-comment-template
+func registerCommentTemplateFlag(fs *flag.FlagSet) *string {
+	return fs.String("changeset-comment-template", "", "Go template for changeset comments, e.g. \"Add ele to {{.Count}} places in {{.Country}} ({{.ClusterIndex}}/{{.ClusterTotal}})\" (default: localized comment)")
+}
+
+// useCommentTemplate validates a custom changeset comment template and makes it override CHANGESET_COMMENT_TEMPLATE
+func useCommentTemplate(text string) error {
+	if text == "" {
+		return nil
+	}
+	if err := ValidateCommentTemplate(text); err != nil {
+		return err
+	}
+	flagConfig.Set("CHANGESET_COMMENT_TEMPLATE", text)
+	return nil
+}
+
+// registerOSMAPIFlags adds --osm-api and --sandbox and returns a function that selects the target API
+func registerOSMAPIFlags(fs *flag.FlagSet) func() error {
+	apiURL := fs.String("osm-api", "", "OSM API server to upload to, e.g. "+osmSandboxURL+" (default: OSM_API_URL)")
+	sandbox := fs.Bool("sandbox", false, "Upload to the OSM development server ("+osmSandboxURL+") to rehearse a run")
+	return func() error {
+		return useOSMAPI(*apiURL, *sandbox)
+	}
+}
+
+// useOSMAPI points all OSM API and OAuth requests at apiURL, or at the sandbox server
+func useOSMAPI(apiURL string, sandbox bool) error {
+	if sandbox {
+		if apiURL != "" {
+			return fmt.Errorf("--osm-api and --sandbox cannot be combined")
+		}
+		apiURL = osmSandboxURL
+	}
+	if apiURL == "" {
+		return nil
+	}
+	base, err := normalizeOSMAPIURL(apiURL)
+	if err != nil {
+		return err
+	}
+	flagConfig.Set("OSM_API_URL", base)
+	pipelineLog.Info("Using OSM API %s", base)
+	return nil
+}
+
+// registerProfileFlag adds --profile and returns a function that activates the chosen profile
+func registerProfileFlag(fs *flag.FlagSet) func() error {
+	profileFile := fs.String("profile", "", "YAML extraction profile declaring categories and tag selectors (default: built-in profile)")
+	return func() error {
+		return useProfileFile(*profileFile)
+	}
+}
+
+// useProfileFile activates the profile in path, keeping the built-in profile when path is empty
+func useProfileFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	profile, err := LoadProfile(path)
+	if err != nil {
+		return err
+	}
+	SetActiveProfile(profile)
+	pipelineLog.Info("Using profile %s (%s)", profile.Name, strings.Join(profile.Keys(), ", "))
+	return nil
+}
+
+// registerOnlyCategoryFlag adds --only-category and returns a function that restricts the active
+// profile to the chosen categories; call it after the profile is activated
+func registerOnlyCategoryFlag(fs *flag.FlagSet) func() error {
+	only := fs.String("only-category", "", "Only process these categories (comma-separated, e.g. alpine_huts)")
+	return func() error {
+		return useOnlyCategories(*only)
+	}
+}
+
+// useOnlyCategories restricts the active profile to a comma-separated list of categories,
+// keeping all of them when the list is empty
+func useOnlyCategories(list string) error {
+	keys := splitList(list)
+	if len(keys) == 0 {
+		return nil
+	}
+	profile, err := activeProfile().Only(keys)
+	if err != nil {
+		return fmt.Errorf("--only-category: %v", err)
+	}
+	SetActiveProfile(profile)
+	pipelineLog.Info("Only processing %s", strings.Join(profile.Keys(), ", "))
+	return nil
+}
+
+// upload resolves credentials and uploads the validated data of the default workspace
+func (f *uploadFlags) upload(ctx context.Context, country string, area AreaSelector, incremental bool) error {
+	mode, err := ParseUploadMode(*f.uploadMode)
+	if err != nil {
+		return err
+	}
+	oauthConfig, isDryRun, err := resolveUploadCredentials(ctx, *f.oauthInteractive, *f.dryRun)
+	if err != nil {
+		return err
+	}
+	if err := runUpload(ctx, oauthConfig, UploadOptions{
+		DryRun:      isDryRun,
+		Country:     country,
+		Incremental: incremental,
+		Reupload:    *f.reupload,
+		Mode:        mode,
+		RetryErrors: splitList(*f.retryErrors),
+		Area:        area,
+		Review:      *f.review,
+		Workspace:   DefaultWorkspace,
+	}); err != nil {
+		return fmt.Errorf("upload failed: %v", err)
+	}
+	return nil
+}
+
+func setupRun(fs *flag.FlagSet) CommandFunc {
+	area := registerAreaFlags(fs)
+	upload := registerUploadFlags(fs)
+	applyProfile := registerProfileFlag(fs)
+	applyOnlyCategory := registerOnlyCategoryFlag(fs)
+	limit := fs.Int("limit", 0, "Limit number of items to enrich (for testing)")
+	applyCategoryLimits := registerCategoryLimitFlags(fs)
+	applyGPX := registerGPXFlag(fs)
+	applyCSVAllTags := registerCSVAllTagsFlag(fs)
+	incremental := fs.Bool("incremental", false, "Only extract elements changed since the last successful run and skip already uploaded ones")
+	applyElevationRange := registerElevationRangeFlags(fs)
+	applyElevationFormat := registerElevationFormatFlags(fs)
+	applyOverwrite := registerOverwriteFlags(fs)
+	applyElementIDs := registerElementIDFlags(fs)
+	report := fs.Bool("report", false, "Write an HTML report of the run to "+DefaultReportFile)
+	until := fs.String("until", "", "Run up to this step (extract, filter, enrich, validate, export-csv, upload), skipping steps whose files are unchanged since their last run")
+	from := fs.String("from", "", "Re-run this step and the later ones even when up to date; earlier steps only run when their files changed (default without --until: every step runs)")
+
+	return func(ctx context.Context, _ []string) error {
+		selector, country, err := area.resolve(ctx)
+		if err != nil {
+			return err
+		}
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		if err := applyOnlyCategory(); err != nil {
+			return err
+		}
+		applyElevationRange()
+		applyElevationFormat()
+		applyCategoryLimits()
+		applyGPX()
+		applyCSVAllTags()
+		applyOverwrite()
+		applyElementIDs()
+		if err := upload.apply(); err != nil {
+			return err
+		}
+		if err := DefaultWorkspace.Create(); err != nil {
+			return err
+		}
+
+		// The settings each step's output depends on; changing one re-runs the step
+		profile := activeProfile()
+		profileParam := profile.Name + ": " + strings.Join(profile.Keys(), ",")
+		extractParams := map[string]string{
+			"country":     country,
+			"area":        selector.LedgerKey(country),
+			"profile":     profileParam,
+			"incremental": strconv.FormatBool(*incremental),
+			"queries":     queriesParam(renderedQueries(country, selector)),
+		}
+		filterParams := configParams("OVERWRITE_POLICY", "OVERWRITE_THRESHOLD_M", "ONLY_IDS_FILE", "EXCLUDE_IDS_FILE")
+		filterParams["profile"] = profileParam
+		enrichParams := configParams("ENRICH_LIMITS", "ELEVATION_PROVIDERS", "OPENTOPO_URL", "OPEN_ELEVATION_URL",
+			"ELEVATION_TILE_DIR", "GEOID_CORRECTED_PROVIDERS", "GEOID_GRID_FILE", "ELEVATION_CONSENSUS_URL",
+			"ELEVATION_CONSENSUS_TOLERANCE_M", "SLOPE_CHECK", "SLOPE_SAMPLE_DISTANCE_M", "GPX_FILES",
+			"GPX_MATCH_RADIUS_M", "GPX_ELE_SOURCE", "OVERWRITE_POLICY", "OVERWRITE_THRESHOLD_M")
+		enrichParams["profile"] = profileParam
+		enrichParams["limit"] = strconv.Itoa(*limit)
+		validateParams := configParams("MIN_ELEVATION", "MAX_ELEVATION", "SLOPE_MAX_DEG", "ELE_PRECISION", "ELE_ROUNDING", "COUNTRY_CHECK")
+		validateParams["country"] = country
+
+		orchestrator := &PipelineOrchestrator{
+			Workspace: DefaultWorkspace,
+			Until:     *until,
+			From:      *from,
+			Steps: []PipelineStep{
+				{Name: StepExtract, Output: DefaultRawDataFile, Params: extractParams, Run: func() error {
+					if err := runExtract(ctx, ExtractOptions{Country: country, Incremental: *incremental, Area: selector, Workspace: DefaultWorkspace}); err != nil {
+						return fmt.Errorf("extract failed: %v", err)
+					}
+					return nil
+				}},
+				{Name: StepFilter, Input: DefaultRawDataFile, Output: DefaultFilteredDataFile, Params: filterParams, Run: func() error {
+					if err := runFilter(DefaultWorkspace); err != nil {
+						return fmt.Errorf("filter failed: %v", err)
+					}
+					return nil
+				}},
+				{Name: StepEnrich, Input: DefaultFilteredDataFile, Output: DefaultEnrichedDataFile, Params: enrichParams, Run: func() error {
+					if err := runEnrich(ctx, DefaultWorkspace, *limit); err != nil {
+						return fmt.Errorf("enrich failed: %v", err)
+					}
+					return nil
+				}},
+				{Name: StepValidate, Input: DefaultEnrichedDataFile, Output: DefaultValidatedDataFile, Params: validateParams, Run: func() error {
+					if err := runValidate(DefaultWorkspace, country); err != nil {
+						return fmt.Errorf("validate failed: %v", err)
+					}
+					return nil
+				}},
+				{Name: StepExportCSV, Input: DefaultValidatedDataFile, Output: DefaultCSVFile, Params: configParams("CSV_ALL_TAGS"), Run: func() error {
+					if err := runExportCSV(DefaultWorkspace); err != nil {
+						return fmt.Errorf("export CSV failed: %v", err)
+					}
+					return nil
+				}},
+				{Name: StepUpload, Run: func() error {
+					return upload.upload(ctx, country, selector, *incremental)
+				}},
+			},
+		}
+		// Without --until every step runs, as a plain pipeline run always did
+		if *until == "" && *from == "" {
+			orchestrator.From = StepExtract
+		}
+
+		printBanner(country)
+		if err := orchestrator.Run(); err != nil {
+			return err
+		}
+		if *report {
+			if err := runReport(DefaultWorkspace); err != nil {
+				return fmt.Errorf("report failed: %v", err)
+			}
+		}
+		notify(countryResultMessage(runSummary.CountryResult(country)))
+		printCompleted()
+		return nil
+	}
+}
+
+func setupExtract(fs *flag.FlagSet) CommandFunc {
+	area := registerAreaFlags(fs)
+	applyProfile := registerProfileFlag(fs)
+	incremental := fs.Bool("incremental", false, "Only extract elements changed since the last successful run")
+	applyOverwrite := registerOverwriteFlags(fs)
+	applyFiles := registerStepFileFlags(fs, "", DefaultRawDataFile)
+
+	return func(ctx context.Context, _ []string) error {
+		selector, country, err := area.resolve(ctx)
+		if err != nil {
+			return err
+		}
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		applyOverwrite()
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		if err := runExtract(ctx, ExtractOptions{Country: country, Incremental: *incremental, Area: selector, Workspace: DefaultWorkspace}); err != nil {
+			return fmt.Errorf("extract failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupFilter(fs *flag.FlagSet) CommandFunc {
+	applyProfile := registerProfileFlag(fs)
+	applyOverwrite := registerOverwriteFlags(fs)
+	applyElementIDs := registerElementIDFlags(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultRawDataFile, DefaultFilteredDataFile)
+
+	return func(ctx context.Context, _ []string) error {
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		applyOverwrite()
+		applyElementIDs()
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		if err := runFilter(DefaultWorkspace); err != nil {
+			return fmt.Errorf("filter failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupEnrich(fs *flag.FlagSet) CommandFunc {
+	applyProfile := registerProfileFlag(fs)
+	applyOnlyCategory := registerOnlyCategoryFlag(fs)
+	limit := fs.Int("limit", 0, "Limit number of items to enrich (for testing)")
+	applyCategoryLimits := registerCategoryLimitFlags(fs)
+	applyGPX := registerGPXFlag(fs)
+	applyElevationFormat := registerElevationFormatFlags(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultFilteredDataFile, DefaultEnrichedDataFile)
+
+	return func(ctx context.Context, _ []string) error {
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		if err := applyOnlyCategory(); err != nil {
+			return err
+		}
+		applyElevationFormat()
+		applyCategoryLimits()
+		applyGPX()
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		if err := runEnrich(ctx, DefaultWorkspace, *limit); err != nil {
+			return fmt.Errorf("enrich failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupValidate(fs *flag.FlagSet) CommandFunc {
+	applyProfile := registerProfileFlag(fs)
+	applyOnlyCategory := registerOnlyCategoryFlag(fs)
+	country := fs.String("country", "România", "Country whose elevation range preset is used")
+	applyElevationRange := registerElevationRangeFlags(fs)
+	applyElevationFormat := registerElevationFormatFlags(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultEnrichedDataFile, DefaultValidatedDataFile)
+
+	return func(ctx context.Context, _ []string) error {
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		if err := applyOnlyCategory(); err != nil {
+			return err
+		}
+		applyElevationRange()
+		applyElevationFormat()
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		if err := runValidate(DefaultWorkspace, *country); err != nil {
+			return fmt.Errorf("validate failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupExportCSV(fs *flag.FlagSet) CommandFunc {
+	applyProfile := registerProfileFlag(fs)
+	applyOnlyCategory := registerOnlyCategoryFlag(fs)
+	applyCSVAllTags := registerCSVAllTagsFlag(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultValidatedDataFile, DefaultCSVFile)
+
+	return func(ctx context.Context, _ []string) error {
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		if err := applyOnlyCategory(); err != nil {
+			return err
+		}
+		applyCSVAllTags()
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		if err := runExportCSV(DefaultWorkspace); err != nil {
+			return fmt.Errorf("export CSV failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupExportOSC(fs *flag.FlagSet) CommandFunc {
+	oscFile := fs.String("osc-file", "", "Output .osc file (default: elevation_changes.osc in the workspace)")
+	applyOnlyCategory := registerOnlyCategoryFlag(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultValidatedDataFile, "")
+
+	return func(ctx context.Context, _ []string) error {
+		if err := applyOnlyCategory(); err != nil {
+			return err
+		}
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		if err := runExportOSC(ctx, *oscFile); err != nil {
+			return fmt.Errorf("export OSC failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupExportPreview(fs *flag.FlagSet) CommandFunc {
+	applyOnlyCategory := registerOnlyCategoryFlag(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultEnrichedDataFile, DefaultPreviewFile)
+
+	return func(ctx context.Context, _ []string) error {
+		if err := applyOnlyCategory(); err != nil {
+			return err
+		}
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		if err := runPreview(DefaultWorkspace); err != nil {
+			return fmt.Errorf("export preview failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupExportReport(fs *flag.FlagSet) CommandFunc {
+	applyOnlyCategory := registerOnlyCategoryFlag(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultValidatedDataFile, DefaultReportFile)
+
+	return func(ctx context.Context, _ []string) error {
+		if err := applyOnlyCategory(); err != nil {
+			return err
+		}
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		if err := runReport(DefaultWorkspace); err != nil {
+			return fmt.Errorf("export report failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupExportUMap(fs *flag.FlagSet) CommandFunc {
+	applyOnlyCategory := registerOnlyCategoryFlag(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultValidatedDataFile, DefaultUMapFile)
+
+	return func(ctx context.Context, _ []string) error {
+		if err := applyOnlyCategory(); err != nil {
+			return err
+		}
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		if err := runExportUMap(DefaultWorkspace); err != nil {
+			return fmt.Errorf("export umap failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupUpload(fs *flag.FlagSet) CommandFunc {
+	area := registerAreaFlags(fs)
+	upload := registerUploadFlags(fs)
+	applyProfile := registerProfileFlag(fs)
+	applyOnlyCategory := registerOnlyCategoryFlag(fs)
+	incremental := fs.Bool("incremental", false, "Skip elements the run ledger records as already uploaded")
+	applyOverwrite := registerOverwriteFlags(fs)
+	applyElementIDs := registerElementIDFlags(fs)
+	applyElevationFormat := registerElevationFormatFlags(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultValidatedDataFile, "")
+
+	return func(ctx context.Context, _ []string) error {
+		selector, country, err := area.resolve(ctx)
+		if err != nil {
+			return err
+		}
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		if err := applyOnlyCategory(); err != nil {
+			return err
+		}
+		applyElevationFormat()
+		applyOverwrite()
+		applyElementIDs()
+		if err := upload.apply(); err != nil {
+			return err
+		}
+		if err := applyFiles(); err != nil {
+			return err
+		}
+		return upload.upload(ctx, country, selector, *incremental)
+	}
+}
+
+func setupImport(fs *flag.FlagSet) CommandFunc {
+	csvFile := fs.String("csv", "", "CSV with type, id and ele columns (and optionally source) to import")
+	source := fs.String("source", DefaultImportSource, "Source tag value of rows without a source column")
+	country := fs.String("country", "România", "Country whose elevation range validates the values and names the changesets")
+	upload := registerUploadFlags(fs)
+	applyProfile := registerProfileFlag(fs)
+	applyOverwrite := registerOverwriteFlags(fs)
+	applyElevationRange := registerElevationRangeFlags(fs)
+	applyElevationFormat := registerElevationFormatFlags(fs)
+
+	return func(ctx context.Context, _ []string) error {
+		if *csvFile == "" {
+			return fmt.Errorf("--csv is required")
+		}
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		applyOverwrite()
+		applyElevationRange()
+		applyElevationFormat()
+		if err := upload.apply(); err != nil {
+			return err
+		}
+
+		// The imported data gets its own workspace so the pipeline files are left alone
+		DefaultWorkspace = ImportWorkspace()
+		if err := DefaultWorkspace.Create(); err != nil {
+			return err
+		}
+		if err := runImport(ctx, DefaultWorkspace, *csvFile, *country, *source); err != nil {
+			return fmt.Errorf("import failed: %v", err)
+		}
+		return upload.upload(ctx, *country, AreaSelector{}, false)
+	}
+}
+
+func setupRetryErrors(fs *flag.FlagSet) CommandFunc {
+	classes := fs.String("classes", retryableClassNames(), "Error classes to retry, or all")
+	dryRun := fs.Bool("dry-run", false, "Dry-run mode (don't upload)")
+	oauthInteractive := fs.Bool("oauth-interactive", false, "Interactive OAuth setup")
+	uploadMode := fs.String("upload-mode", UploadModeDiff, "Upload mode: diff (one osmChange per changeset) or element (one PUT per element)")
+	commentTemplate := registerCommentTemplateFlag(fs)
+	applyOSMAPI := registerOSMAPIFlags(fs)
+	applyMaxEdits := registerMaxEditsFlag(fs)
+	applyMaxBBox := registerMaxBBoxDiagonalFlag(fs)
+	applyConcurrency := registerUploadConcurrencyFlag(fs, uploadMode)
+	applyMaxFailures := registerMaxConsecutiveFailuresFlag(fs)
+	applyUser := registerRequireUserFlag(fs)
+	applyDripFeed := registerDripFeedFlags(fs)
+
+	return func(ctx context.Context, _ []string) error {
+		applyUser()
+		for _, apply := range []func() error{applyOSMAPI, applyMaxEdits, applyMaxBBox, applyConcurrency, applyMaxFailures, applyDripFeed} {
+			if err := apply(); err != nil {
+				return err
+			}
+		}
+		if err := useCommentTemplate(*commentTemplate); err != nil {
+			return err
+		}
+		mode, err := ParseUploadMode(*uploadMode)
+		if err != nil {
+			return err
+		}
+		var retryClasses []string
+		if *classes != "all" {
+			retryClasses = splitList(*classes)
+		}
+		oauthConfig, isDryRun, err := resolveUploadCredentials(ctx, *oauthInteractive, *dryRun)
+		if err != nil {
+			return err
+		}
+		if err := runUpload(ctx, oauthConfig, UploadOptions{
+			DryRun:       isDryRun,
+			Mode:         mode,
+			RetryErrors:  retryClasses,
+			FromErrorLog: true,
+			Workspace:    DefaultWorkspace,
+		}); err != nil {
+			return fmt.Errorf("retry failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupPropose(fs *flag.FlagSet) CommandFunc {
+	country := fs.String("country", "România", "Country name used in the changeset comment")
+	proposalFile := fs.String("proposal", "", "Proposal file to write (default: proposal.json in the workspace)")
+
+	return func(ctx context.Context, _ []string) error {
+		if err := runPropose(ctx, *country, *proposalFile); err != nil {
+			return fmt.Errorf("propose failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupApply(fs *flag.FlagSet) CommandFunc {
+	proposalFile := fs.String("proposal", "", "Proposal file to execute (default: proposal.json in the workspace)")
+	approvedFile := fs.String("approved", "", "Review CSV; only rows marked approved are uploaded")
+	dryRun := fs.Bool("dry-run", false, "Dry-run mode (don't upload)")
+	oauthInteractive := fs.Bool("oauth-interactive", false, "Interactive OAuth setup")
+	applyOSMAPI := registerOSMAPIFlags(fs)
+	applyMaxEdits := registerMaxEditsFlag(fs)
+	applyMaxBBox := registerMaxBBoxDiagonalFlag(fs)
+	applyMaxFailures := registerMaxConsecutiveFailuresFlag(fs)
+	applyUser := registerRequireUserFlag(fs)
+	applyDripFeed := registerDripFeedFlags(fs)
+
+	return func(ctx context.Context, _ []string) error {
+		applyUser()
+		if err := applyDripFeed(); err != nil {
+			return err
+		}
+		if err := applyOSMAPI(); err != nil {
+			return err
+		}
+		if err := applyMaxEdits(); err != nil {
+			return err
+		}
+		if err := applyMaxBBox(); err != nil {
+			return err
+		}
+		if err := applyMaxFailures(); err != nil {
+			return err
+		}
+		oauthConfig, isDryRun, err := resolveUploadCredentials(ctx, *oauthInteractive, *dryRun)
+		if err != nil {
+			return err
+		}
+		if err := runApply(ctx, isDryRun, oauthConfig, *proposalFile, *approvedFile); err != nil {
+			return fmt.Errorf("apply failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupAudit(fs *flag.FlagSet) CommandFunc {
+	area := registerAreaFlags(fs)
+	applyProfile := registerProfileFlag(fs)
+	threshold := fs.Float64("threshold", DefaultAuditThreshold, "Report elements whose ele differs from the DEM by more than this many metres")
+	limit := fs.Int("limit", 0, "Limit number of elements audited per category (for testing)")
+
+	return func(ctx context.Context, _ []string) error {
+		selector, country, err := area.resolve(ctx)
+		if err != nil {
+			return err
+		}
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		if err := DefaultWorkspace.Create(); err != nil {
+			return err
+		}
+		opts := AuditOptions{Country: country, Area: selector, Threshold: *threshold, Limit: *limit}
+		if err := runAudit(ctx, opts); err != nil {
+			return fmt.Errorf("audit failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupStats(fs *flag.FlagSet) CommandFunc {
+	area := registerAreaFlags(fs)
+	applyProfile := registerProfileFlag(fs)
+
+	return func(ctx context.Context, _ []string) error {
+		selector, country, err := area.resolve(ctx)
+		if err != nil {
+			return err
+		}
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		if err := DefaultWorkspace.Create(); err != nil {
+			return err
+		}
+		if err := runStats(ctx, country, selector); err != nil {
+			return fmt.Errorf("stats failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupHistory(fs *flag.FlagSet) CommandFunc {
+	country := fs.String("country", "", "Only show the uploads of this country (default: all countries)")
+	period := fs.String("by", "month", "Group uploads per day, month or year")
+
+	return func(ctx context.Context, _ []string) error {
+		return runHistory(*country, *period)
+	}
+}
+
+func setupRevert(fs *flag.FlagSet) CommandFunc {
+	changesetID := fs.Int("changeset", 0, "ID of the changeset to revert")
+	force := fs.Bool("force", false, "Revert a changeset that was not created by elevate-romania")
+	dryRun := fs.Bool("dry-run", false, "Dry-run mode (show the revert without uploading)")
+	oauthInteractive := fs.Bool("oauth-interactive", false, "Interactive OAuth setup")
+	applyOSMAPI := registerOSMAPIFlags(fs)
+
+	return func(ctx context.Context, _ []string) error {
+		if *changesetID <= 0 {
+			return fmt.Errorf("--changeset is required")
+		}
+		if err := applyOSMAPI(); err != nil {
+			return err
+		}
+		oauthConfig, isDryRun, err := resolveUploadCredentials(ctx, *oauthInteractive, *dryRun)
+		if err != nil {
+			return err
+		}
+		if err := runRevert(ctx, isDryRun, oauthConfig, *changesetID, *force); err != nil {
+			return fmt.Errorf("revert failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupMerge(fs *flag.FlagSet) CommandFunc {
+	output := fs.String("output", "output/osm_data_merged.json", "Merged output file")
+	rule := fs.String("rule", "first", "Conflict rule: first, last, mean, min, max")
+	applyElevationFormat := registerElevationFormatFlags(fs)
+
+	return func(ctx context.Context, inputs []string) error {
+		applyElevationFormat()
+		if err := runMerge(inputs, *output, *rule); err != nil {
+			return fmt.Errorf("merge failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupCountriesList(fs *flag.FlagSet) CommandFunc {
+	format := fs.String("format", CountryFormatText, "Output format: text, json or csv")
+	refresh := fs.Bool("refresh", false, "Query Overpass even when the cached country list is fresh")
+
+	return func(ctx context.Context, _ []string) error {
+		if err := runListCountries(ctx, *format, *refresh); err != nil {
+			return fmt.Errorf("list countries failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupCountriesProcess(fs *flag.FlagSet) CommandFunc {
+	applyProfile := registerProfileFlag(fs)
+	limit := fs.Int("limit", 0, "Limit number of items to enrich per country")
+	dryRun := fs.Bool("dry-run", false, "Dry-run mode (don't upload)")
+	oauthInteractive := fs.Bool("oauth-interactive", false, "Interactive OAuth setup")
+	incremental := fs.Bool("incremental", false, "Only extract elements changed since each country's last successful run")
+	uploadMode := fs.String("upload-mode", UploadModeDiff, "Upload mode: diff or element")
+	concurrency := fs.Int("concurrency", 1, "Number of countries processed in parallel")
+	include := fs.String("countries", "", "Only process these countries (comma-separated names or ISO codes, or @file with one per line)")
+	exclude := fs.String("exclude-countries", "", "Skip these countries (comma-separated names or ISO codes, or @file with one per line)")
+	continent := fs.String("continent", "", "Only process countries on these continents (comma-separated, e.g. Europe)")
+	unRegion := fs.String("un-region", "", "Only process countries in these UN regions or sub-regions (comma-separated, e.g. \"Eastern Europe\")")
+	commentTemplate := registerCommentTemplateFlag(fs)
+	applyOSMAPI := registerOSMAPIFlags(fs)
+	applyMaxEdits := registerMaxEditsFlag(fs)
+	applyMaxBBox := registerMaxBBoxDiagonalFlag(fs)
+	applyUploadConcurrency := registerUploadConcurrencyFlag(fs, uploadMode)
+	applyMaxFailures := registerMaxConsecutiveFailuresFlag(fs)
+	applyUser := registerRequireUserFlag(fs)
+	applyDripFeed := registerDripFeedFlags(fs)
+	applyOverwrite := registerOverwriteFlags(fs)
+	applyElevationRange := registerElevationRangeFlags(fs)
+	applyElevationFormat := registerElevationFormatFlags(fs)
+
+	return func(ctx context.Context, _ []string) error {
+		if err := applyProfile(); err != nil {
+			return err
+		}
+		if err := applyOSMAPI(); err != nil {
+			return err
+		}
+		if err := applyMaxEdits(); err != nil {
+			return err
+		}
+		if err := applyMaxBBox(); err != nil {
+			return err
+		}
+		if err := applyUploadConcurrency(); err != nil {
+			return err
+		}
+		if err := applyMaxFailures(); err != nil {
+			return err
+		}
+		applyUser()
+		if err := applyDripFeed(); err != nil {
+			return err
+		}
+		applyElevationRange()
+		applyElevationFormat()
+		applyOverwrite()
+		if err := useCommentTemplate(*commentTemplate); err != nil {
+			return err
+		}
+		mode, err := ParseUploadMode(*uploadMode)
+		if err != nil {
+			return err
+		}
+		filter, err := parseCountryFilter(*include, *exclude, *continent, *unRegion)
+		if err != nil {
+			return err
+		}
+		if err := runProcessAllCountries(ctx, PipelineOptions{
+			Limit:            *limit,
+			DryRun:           *dryRun,
+			OAuthInteractive: *oauthInteractive,
+			Incremental:      *incremental,
+			UploadMode:       mode,
+			Concurrency:      *concurrency,
+			Countries:        filter,
+		}); err != nil {
+			return fmt.Errorf("process all countries failed: %v", err)
+		}
+		return nil
+	}
+}
+
+func setupDaemon(fs *flag.FlagSet) CommandFunc {
+	applyProfile := registerProfileFlag(fs)
+	interval := fs.Duration("interval", 168*time.Hour, "Time between runs (default: DAEMON_INTERVAL)")
+	countries := fs.String("countries", "", "Countries to keep up to date (comma-separated names or ISO codes, or @file; default: DAEMON_COUNTRIES)")
+	lockFile := fs.String("lock-file", DefaultDaemonLockFile, "Lock file preventing overlapping runs")
+	limit := fs.Int("limit", 0, "Limit number of items to enrich per country")
+	dryRun := fs.Bool("dry-run", false, "Dry-run mode (don't upload)")
+	uploadMode := fs.String("upload-mode", UploadModeDiff, "Upload mode: diff or element")
+	concurrency := fs.Int("concurrency", 1, "Number of countries processed in parallel")
+	commentTemplate := registerCommentTemplateFlag(fs)
+	applyOSMAPI := registerOSMAPIFlags(fs)
+	applyMaxEdits := registerMaxEditsFlag(fs)
+	applyMaxBBox := registerMaxBBoxDiagonalFlag(fs)
+	applyUploadConcurrency := registerUploadConcurrencyFlag(fs, uploadMode)
+	applyMaxFailures := registerMaxConsecutiveFailuresFlag(fs)
+	applyUser := registerRequireUserFlag(fs)
+	applyDripFeed := registerDripFeedFlags(fs)
+	applyOverwrite := registerOverwriteFlags(fs)
+	applyElevationRange := registerElevationRangeFlags(fs)
+	applyElevationFormat := registerElevationFormatFlags(fs)
+
+	return func(ctx context.Context, _ []string) error {
+		if flagWasSet(fs, "interval") {
+			flagConfig.Set("DAEMON_INTERVAL", interval.String())
+		}
+		if flagWasSet(fs, "countries") {
+			flagConfig.Set("DAEMON_COUNTRIES", *countries)
+		}
+		for _, apply := range []func() error{applyProfile, applyOSMAPI, applyMaxEdits, applyMaxBBox, applyUploadConcurrency, applyMaxFailures, applyDripFeed} {
+			if err := apply(); err != nil {
+				return err
+			}
+		}
+		applyUser()
+		applyElevationRange()
+		applyElevationFormat()
+		applyOverwrite()
+		if err := useCommentTemplate(*commentTemplate); err != nil {
+			return err
+		}
+		mode, err := ParseUploadMode(*uploadMode)
+		if err != nil {
+			return err
+		}
+
+		config := NewConfig()
+		config.LoadFromEnv()
+		daemonInterval, filter, err := resolveDaemonSettings(config)
+		if err != nil {
+			return err
+		}
+		return runDaemon(ctx, DaemonOptions{
+			Interval: daemonInterval,
+			LockFile: *lockFile,
+			Pipeline: PipelineOptions{
+				Limit:       *limit,
+				DryRun:      *dryRun,
+				UploadMode:  mode,
+				Concurrency: *concurrency,
+				Countries:   filter,
+			},
+		})
+	}
+}
+
+// parseCountryFilter parses the include and exclude country lists and the continent and UN region filters
+func parseCountryFilter(include, exclude, continents, regions string) (CountryFilter, error) {
+	includeList, err := ParseCountryList(include)
+	if err != nil {
+		return CountryFilter{}, err
+	}
+	excludeList, err := ParseCountryList(exclude)
+	if err != nil {
+		return CountryFilter{}, err
+	}
+	filter := CountryFilter{Include: includeList, Exclude: excludeList, Continents: splitList(continents), UNRegions: splitList(regions)}
+	if err := checkGeographyNames(filter.Continents, true); err != nil {
+		return CountryFilter{}, err
+	}
+	if err := checkGeographyNames(filter.UNRegions, false); err != nil {
+		return CountryFilter{}, err
+	}
+	return filter, nil
+}
+
+// printBanner prints the header of a pipeline run
+func printBanner(country string) {
+	pipelineLog.Banner("ELEVAȚIE OSM\nAdding elevation to train stations and accommodations in %s\nStarted: %s",
+		country, time.Now().Format("2006-01-02 15:04:05"))
+}
+
+// printCompleted prints the footer of a successful pipeline run
+func printCompleted() {
+	pipelineLog.Banner("COMPLETED SUCCESSFULLY!\nFinished: %s", time.Now().Format("2006-01-02 15:04:05"))
+}
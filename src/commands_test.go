@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDispatch(t *testing.T) {
+	var ran string
+	var gotLimit int
+	var gotArgs []string
+
+	commands := []*Command{
+		{Name: "enrich", Setup: func(fs *flag.FlagSet) CommandFunc {
+			limit := fs.Int("limit", 0, "")
+			return func(context.Context, []string) error {
+				ran, gotLimit = "enrich", *limit
+				return nil
+			}
+		}},
+		{Name: "merge", Args: "FILE...", Setup: func(fs *flag.FlagSet) CommandFunc {
+			return func(_ context.Context, args []string) error {
+				ran, gotArgs = "merge", args
+				return nil
+			}
+		}},
+		{Name: "countries", Subcommands: []*Command{
+			{Name: "list", Setup: func(fs *flag.FlagSet) CommandFunc {
+				return func(context.Context, []string) error {
+					ran = "countries list"
+					return nil
+				}
+			}},
+		}},
+	}
+
+	tests := []struct {
+		name    string
+		args    []string
+		ran     string
+		wantErr string
+	}{
+		{"Flags", []string{"enrich", "--limit", "10"}, "enrich", ""},
+		{"Positional args", []string{"merge", "a.json", "b.json"}, "merge", ""},
+		{"Nested", []string{"countries", "list"}, "countries list", ""},
+		{"Unknown command", []string{"bogus"}, "", "unknown command"},
+		{"Unknown nested command", []string{"countries", "bogus"}, "", "unknown command"},
+		{"Unexpected args", []string{"enrich", "extra"}, "", "unexpected arguments"},
+		{"Unknown flag", []string{"enrich", "--nope"}, "", "not defined"},
+		{"Help", []string{"help", "enrich"}, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ran = ""
+			err := dispatch(context.Background(), "elevate-romania", commands, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if ran != tt.ran {
+				t.Errorf("Expected %q to run, got %q", tt.ran, ran)
+			}
+		})
+	}
+
+	dispatch(context.Background(), "elevate-romania", commands, []string{"enrich", "--limit", "10"})
+	if gotLimit != 10 {
+		t.Errorf("Expected limit 10, got %d", gotLimit)
+	}
+	dispatch(context.Background(), "elevate-romania", commands, []string{"merge", "a.json", "b.json"})
+	if !reflect.DeepEqual(gotArgs, []string{"a.json", "b.json"}) {
+		t.Errorf("Expected merge inputs, got %v", gotArgs)
+	}
+}
+
+func TestRootCommandsSetup(t *testing.T) {
+	// Every command must register its flags without conflicts
+	var check func(path string, commands []*Command)
+	check = func(path string, commands []*Command) {
+		for _, cmd := range commands {
+			if len(cmd.Subcommands) > 0 {
+				check(path+" "+cmd.Name, cmd.Subcommands)
+				continue
+			}
+			if cmd.Setup(flag.NewFlagSet(path+" "+cmd.Name, flag.ContinueOnError)) == nil {
+				t.Errorf("%s %s: Setup returned no runner", path, cmd.Name)
+			}
+		}
+	}
+	check("elevate-romania", rootCommands())
+}
+
+func TestIsSubcommand(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{"--all", "--dry-run"}, false},
+		{[]string{"-extract"}, false},
+		{[]string{"extract", "--country", "Moldova"}, true},
+		{[]string{"help"}, true},
+	}
+	for _, tt := range tests {
+		if got := isSubcommand(tt.args); got != tt.expected {
+			t.Errorf("isSubcommand(%v) = %v, expected %v", tt.args, got, tt.expected)
+		}
+	}
+}
@@ -18,25 +18,165 @@ func NewConfig() *Config {
 	}
 }
 
+// flagConfig holds settings given by command-line flags (e.g. --osm-api).
+// LoadFromEnv applies them above environment variables.
+var flagConfig = NewConfig()
+
 // LoadFromEnv loads configuration from environment variables
 func (c *Config) LoadFromEnv() {
 	// OSM OAuth Configuration
 	c.Set("OSM_CLIENT_ID", os.Getenv("OSM_CLIENT_ID"))
 	c.Set("OSM_CLIENT_SECRET", os.Getenv("OSM_CLIENT_SECRET"))
 	c.Set("OSM_ACCESS_TOKEN", os.Getenv("OSM_ACCESS_TOKEN"))
+	c.SetDefault("OSM_CLIENT_ID", fileConfig.Get("OSM_CLIENT_ID"))
+	c.SetDefault("OSM_CLIENT_SECRET", fileConfig.Get("OSM_CLIENT_SECRET"))
+	c.SetDefault("OSM_ACCESS_TOKEN", fileConfig.Get("OSM_ACCESS_TOKEN"))
+	// Refresh token and RFC 3339 expiry of the access token, if the server issued them
+	c.Set("OSM_REFRESH_TOKEN", os.Getenv("OSM_REFRESH_TOKEN"))
+	c.Set("OSM_TOKEN_EXPIRY", os.Getenv("OSM_TOKEN_EXPIRY"))
+	c.SetDefault("OSM_REFRESH_TOKEN", fileConfig.Get("OSM_REFRESH_TOKEN"))
+	c.SetDefault("OSM_TOKEN_EXPIRY", fileConfig.Get("OSM_TOKEN_EXPIRY"))
 	
+	// Directory holding the pipeline files (--workspace)
+	c.loadEnvDefault("WORKSPACE", "output")
+	// Store the raw, filtered, enriched and validated data as .json.gz (about 10x smaller)
+	c.loadEnvDefault("GZIP_INTERMEDIATE", "false")
+
 	// API Configuration
-	c.SetDefault("OVERPASS_URL", "https://overpass-api.de/api/interpreter")
-	c.SetDefault("OPENTOPO_URL", "https://api.opentopodata.org/v1/srtm30m")
-	c.SetDefault("OSM_API_URL", "https://api.openstreetmap.org/api/0.6")
+	c.loadEnvDefault("OVERPASS_URL", "https://overpass-api.de/api/interpreter")
+	// Comma-separated fallback instances used when OVERPASS_URL is overloaded; "none" disables failover
+	c.loadEnvDefault("OVERPASS_MIRRORS", DefaultOverpassMirrors)
+	c.loadEnvDefault("OPENTOPO_URL", "https://api.opentopodata.org/v1/srtm30m")
+	c.loadEnvDefault("OPEN_ELEVATION_URL", "https://api.open-elevation.com/api/v1/lookup")
+	c.loadEnvDefault("OSM_API_URL", "https://api.openstreetmap.org/api/0.6")
+	// Directory with SRTM .hgt tiles used by the "hgt" elevation provider
+	c.loadEnvDefault("ELEVATION_TILE_DIR", "")
+	// Ordered elevation provider fallback chain (opentopo, open-elevation, hgt);
+	// defaults to "hgt" when ELEVATION_TILE_DIR is set, otherwise "opentopo"
+	c.loadEnvDefault("ELEVATION_PROVIDERS", "")
+	// On-disk cache of elevation lookups shared by all runs; "none" disables it
+	c.loadEnvDefault("ELEVATION_CACHE_FILE", DefaultElevationCacheFile)
+	// Providers returning heights above the WGS84 ellipsoid rather than sea level; their results
+	// are corrected with the geoid grid in GEOID_GRID_FILE (GeographicLib .pgm, e.g. egm96-5.pgm),
+	// which they require
+	c.loadEnvDefault("GEOID_CORRECTED_PROVIDERS", "")
+	c.loadEnvDefault("GEOID_GRID_FILE", "")
+	// Second OpenTopoData dataset (e.g. .../v1/aster30m) that must agree with the providers
+	// within ELEVATION_CONSENSUS_TOLERANCE_M meters; empty or "none" disables the check
+	c.loadEnvDefault("ELEVATION_CONSENSUS_URL", "")
+	c.loadEnvDefault("ELEVATION_CONSENSUS_TOLERANCE_M", "20")
+	// Cache of the admin_level=2 country list ("none" disables it), refetched after the TTL
+	c.loadEnvDefault("COUNTRY_LIST_FILE", DefaultCountryListFile)
+	c.loadEnvDefault("COUNTRY_LIST_TTL_HOURS", "168")
+	// Reverse-geocode a sample of extracted elements and abort when they lie outside the country
+	c.loadEnvDefault("COUNTRY_CHECK", "true")
+	// Sample the DEM at 4 neighbors of each element to score its terrain slope; elements
+	// steeper than SLOPE_MAX_DEG degrees fail validation (0 only scores them)
+	c.loadEnvDefault("SLOPE_CHECK", "false")
+	c.loadEnvDefault("SLOPE_SAMPLE_DISTANCE_M", "30")
+	c.loadEnvDefault("SLOPE_MAX_DEG", "0")
 	
 	// Rate Limiting
-	c.SetDefault("API_RATE_LIMIT_MS", "1000")
-	c.SetDefault("BATCH_SIZE", "100")
-	c.SetDefault("API_TIMEOUT_SEC", "30")
+	c.loadEnvDefault("API_RATE_LIMIT_MS", "1000")
+	c.loadEnvDefault("BATCH_SIZE", "100")
+	c.loadEnvDefault("API_TIMEOUT_SEC", "30")
+	c.loadEnvDefault("ENRICH_CHECKPOINT_EVERY", "5")
+	// Per-category enrichment limits ("alpine_huts=0,other_accommodations=50") overriding --limit; 0 = all
+	c.loadEnvDefault("ENRICH_LIMITS", "")
+	// Files of element IDs (node/123, one per line) that filter and upload are restricted to or skip
+	c.loadEnvDefault("ONLY_IDS_FILE", "")
+	c.loadEnvDefault("EXCLUDE_IDS_FILE", "")
+	// GPX survey tracks (comma-separated) whose elevations replace the DEM within
+	// GPX_MATCH_RADIUS_M meters of their points, tagged with GPX_ELE_SOURCE
+	c.loadEnvDefault("GPX_FILES", "")
+	c.loadEnvDefault("GPX_MATCH_RADIUS_M", "30")
+	c.loadEnvDefault("GPX_ELE_SOURCE", "GPS survey")
+	// Add a JSON column with every tag of the element to the CSV export
+	c.loadEnvDefault("CSV_ALL_TAGS", "false")
+	
+	// API budgets (0 = unlimited), shared by all clients and persisted across runs
+	c.loadEnvDefault("BUDGET_FILE", DefaultBudgetFile)
+	c.loadEnvDefault("BUDGET_MAX_WAIT_MIN", "60")
+	c.loadEnvDefault("BUDGET_OVERPASS_HOURLY", "0")
+	c.loadEnvDefault("BUDGET_OVERPASS_DAILY", "10000")
+	c.loadEnvDefault("BUDGET_ELEVATION_HOURLY", "0")
+	c.loadEnvDefault("BUDGET_ELEVATION_DAILY", "1000")
+	c.loadEnvDefault("BUDGET_OSM_EDITS_HOURLY", "0")
+	c.loadEnvDefault("BUDGET_OSM_EDITS_DAILY", "0")
+	c.loadEnvDefault("BUDGET_OSM_CHANGESETS_HOURLY", "0")
+	c.loadEnvDefault("BUDGET_OSM_CHANGESETS_DAILY", "0")
+	
+	// Decimals and rounding policy (nearest, down, up) of written ele values
+	c.loadEnvDefault("ELE_PRECISION", "1")
+	c.loadEnvDefault("ELE_ROUNDING", RoundNearest)
+	// Source tag key (ele:source or source:ele) and "provider=value" overrides of the
+	// dataset name each provider's values are tagged with
+	c.loadEnvDefault("ELE_SOURCE_KEY", "ele:source")
+	c.loadEnvDefault("ELE_SOURCE_VALUES", "")
+
+	// Existing ele policy (fill-missing, overwrite-if-differs, never-touch) and the difference
+	// in meters above which overwrite-if-differs replaces ele
+	c.loadEnvDefault("OVERWRITE_POLICY", OverwriteFillMissing)
+	c.loadEnvDefault("OVERWRITE_THRESHOLD_M", "50")
+
+	// Validation range (meters); empty uses the country's preset (see elevation_ranges.go)
+	c.loadEnvDefault("MIN_ELEVATION", "")
+	c.loadEnvDefault("MAX_ELEVATION", "")
+
+	// Custom changeset comment template (Go text/template, see ChangesetCommentData);
+	// empty uses the localized templates
+	c.loadEnvDefault("CHANGESET_COMMENT_TEMPLATE", "")
+
+	// Maximum edits per changeset; larger clusters are split (0 = unlimited)
+	c.loadEnvDefault("MAX_EDITS_PER_CHANGESET", "500")
+	// Maximum changeset bounding box diagonal in km (at most 55, about 0.5 degrees)
+	c.loadEnvDefault("MAX_BBOX_DIAGONAL_KM", "28")
+	// Elements updated in parallel within a changeset in element upload mode (1 to 8)
+	c.loadEnvDefault("UPLOAD_CONCURRENCY", "1")
+	// Consecutive upload failures after which the run is aborted (0 = never abort)
+	c.loadEnvDefault("MAX_CONSECUTIVE_FAILURES", "25")
+	// OSM display name that uploads must be authenticated as (empty = any account)
+	c.loadEnvDefault("REQUIRE_USER", "")
+	// Time between daemon runs and the countries they keep up to date
+	c.loadEnvDefault("DAEMON_INTERVAL", "168h")
+	c.loadEnvDefault("DAEMON_COUNTRIES", "România")
+	// Minimum log level (debug, info, warn, error) and a file that receives a copy of all output
+	c.loadEnvDefault("LOG_LEVEL", "info")
+	c.loadEnvDefault("LOG_FILE", "")
+	// Slack, Discord or compatible incoming webhook notified when a country completes or a run fails
+	c.loadEnvDefault("NOTIFY_WEBHOOK_URL", "")
+	// Shell commands run before each step, after each step and after each upload, with the step
+	// in ELEVATE_* environment variables
+	c.loadEnvDefault("ON_STEP_START", "")
+	c.loadEnvDefault("ON_STEP_COMPLETE", "")
+	c.loadEnvDefault("ON_UPLOAD_COMPLETE", "")
+
+	// Changeset metadata tags required for automated edits; "none" leaves a tag out
+	c.loadEnvDefault("CHANGESET_CREATED_BY", "elevate-romania/"+appVersion)
+	c.loadEnvDefault("CHANGESET_BOT", "yes")
+	c.loadEnvDefault("CHANGESET_SOURCE", "SRTM/OpenTopoData")
+	c.loadEnvDefault("CHANGESET_HASHTAGS", "#elevate")
 	
 	// OAuth
-	c.SetDefault("OAUTH_REDIRECT_URI", "http://127.0.0.1:8080/callback")
+	c.loadEnvDefault("OAUTH_REDIRECT_URI", "http://127.0.0.1:8080/callback")
+}
+
+// loadEnvDefault sets a key from a command-line flag override, the environment variable
+// of the same name, then the --config file, or the default if all are unset
+func (c *Config) loadEnvDefault(key, defaultValue string) {
+	if value := flagConfig.Get(key); value != "" {
+		c.SetDefault(key, value)
+		return
+	}
+	if value := os.Getenv(key); value != "" {
+		c.SetDefault(key, value)
+		return
+	}
+	if value := fileConfig.Get(key); value != "" {
+		c.SetDefault(key, value)
+		return
+	}
+	c.SetDefault(key, defaultValue)
 }
 
 // Get retrieves a configuration value
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// fileConfig holds the settings of the --config file. LoadFromEnv applies them
+// below environment variables and above built-in defaults.
+var fileConfig = NewConfig()
+
+// ConfigFile is a parsed --config file: a flat map of flag names (country,
+// limit, dry-run, ...) and configuration keys (elevation_providers, max_elevation, ...)
+type ConfigFile map[string]string
+
+// LoadConfigFile reads a YAML (.yaml, .yml) or TOML (.toml) config file
+func LoadConfigFile(path string) (ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %v", err)
+	}
+
+	raw := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("unsupported config format %q (expected .yaml, .yml or .toml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %v", path, err)
+	}
+
+	file := make(ConfigFile)
+	for key, value := range raw {
+		str, err := configValueString(value)
+		if err != nil {
+			return nil, fmt.Errorf("config %s: %s: %v", path, key, err)
+		}
+		file[key] = str
+	}
+	return file, nil
+}
+
+// configValueString converts a scalar or a list of scalars (joined with commas) to a string
+func configValueString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string, bool, int, int64, float64:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			str, err := configValueString(item)
+			if err != nil {
+				return "", err
+			}
+			items[i] = str
+		}
+		return strings.Join(items, ","), nil
+	}
+	return "", fmt.Errorf("unsupported value %v (expected a string, number, boolean or list)", value)
+}
+
+// configKey converts a config file key to its Config key (elevation_providers → ELEVATION_PROVIDERS)
+func configKey(key string) string {
+	return strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// flagName converts a config file key to a flag name (dry_run → dry-run)
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// knownConfigKeys returns the keys understood by Config
+func knownConfigKeys() map[string]bool {
+	config := NewConfig()
+	config.LoadFromEnv()
+	known := make(map[string]bool, len(config.values))
+	for key := range config.values {
+		known[key] = true
+	}
+	return known
+}
+
+// Apply sets the flags of fs that were not given on the command line and stores
+// the remaining configuration keys in fileConfig. It returns the keys that are
+// neither a flag of fs, a flag of another command nor a configuration key.
+func (f ConfigFile) Apply(fs *flag.FlagSet, otherFlags map[string]bool) ([]string, error) {
+	known := knownConfigKeys()
+
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var unknown []string
+	for _, key := range keys {
+		value := f[key]
+		name := flagName(key)
+		switch {
+		case fs.Lookup(name) != nil:
+			if !flagWasSet(fs, name) {
+				if err := fs.Set(name, value); err != nil {
+					return nil, fmt.Errorf("config key %s: %v", key, err)
+				}
+			}
+		case known[configKey(key)]:
+			fileConfig.Set(configKey(key), value)
+		case !otherFlags[name]:
+			unknown = append(unknown, key)
+		}
+	}
+
+	return unknown, nil
+}
+
+// commandFlagNames returns the flag names of all subcommands
+func commandFlagNames(commands []*Command) map[string]bool {
+	names := make(map[string]bool)
+	var walk func(commands []*Command)
+	walk = func(commands []*Command) {
+		for _, cmd := range commands {
+			if len(cmd.Subcommands) > 0 {
+				walk(cmd.Subcommands)
+				continue
+			}
+			fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+			cmd.Setup(fs)
+			fs.VisitAll(func(f *flag.Flag) { names[f.Name] = true })
+		}
+	}
+	walk(commands)
+	return names
+}
+
+// registerConfigFlag adds --config to a flag set
+func registerConfigFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "", "YAML or TOML file setting pipeline options (flags given on the command line take precedence)")
+}
+
+// applyConfigFile loads a --config file into fs and fileConfig; an empty path is a no-op
+func applyConfigFile(path string, fs *flag.FlagSet) error {
+	if path == "" {
+		return nil
+	}
+	file, err := LoadConfigFile(path)
+	if err != nil {
+		return err
+	}
+	unknown, err := file.Apply(fs, commandFlagNames(rootCommands()))
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	pipelineLog.Info("Loaded config from %s", path)
+	if len(unknown) > 0 {
+		pipelineLog.Warn("Ignoring unknown config keys: %s", strings.Join(unknown, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// resetFileConfig clears the --config settings after a test
+func resetFileConfig(t *testing.T) {
+	t.Helper()
+	t.Cleanup(func() { fileConfig = NewConfig() })
+}
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	expected := ConfigFile{
+		"country":             "Moldova",
+		"limit":               "10",
+		"dry-run":             "true",
+		"elevation_providers": "hgt,opentopo",
+		"max_elevation":       "3000.5",
+	}
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+	}{
+		{"YAML", "config.yaml", "country: Moldova\nlimit: 10\ndry-run: true\nelevation_providers: [hgt, opentopo]\nmax_elevation: 3000.5\n"},
+		{"TOML", "config.toml", "country = \"Moldova\"\nlimit = 10\ndry-run = true\nelevation_providers = [\"hgt\", \"opentopo\"]\nmax_elevation = 3000.5\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := LoadConfigFile(writeConfigFile(t, tt.file, tt.content))
+			if err != nil {
+				t.Fatalf("LoadConfigFile failed: %v", err)
+			}
+			if !reflect.DeepEqual(file, expected) {
+				t.Errorf("Expected %v, got %v", expected, file)
+			}
+		})
+	}
+
+	if _, err := LoadConfigFile(writeConfigFile(t, "config.json", "{}")); err == nil {
+		t.Error("Expected error for unsupported format")
+	}
+	if _, err := LoadConfigFile(writeConfigFile(t, "nested.yaml", "budgets:\n  daily: 10\n")); err == nil {
+		t.Error("Expected error for nested values")
+	}
+}
+
+func TestLoadExampleConfigFile(t *testing.T) {
+	resetFileConfig(t)
+
+	file, err := LoadConfigFile("config.example.yaml")
+	if err != nil {
+		t.Fatalf("LoadConfigFile failed: %v", err)
+	}
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	setupRun(fs)
+	unknown, err := file.Apply(fs, nil)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if len(unknown) > 0 {
+		t.Errorf("Example config has unknown keys: %v", unknown)
+	}
+}
+
+func TestConfigFileApply(t *testing.T) {
+	resetFileConfig(t)
+
+	fs := flag.NewFlagSet("enrich", flag.ContinueOnError)
+	limit := fs.Int("limit", 0, "")
+	profile := fs.String("profile", "", "")
+	if err := fs.Parse([]string{"--profile", "cli.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := ConfigFile{
+		"limit":               "25",
+		"profile":             "file.yaml",
+		"elevation_providers": "hgt",
+		"dry-run":             "true",
+		"no_such_option":      "1",
+	}
+	unknown, err := file.Apply(fs, map[string]bool{"dry-run": true})
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if *limit != 25 {
+		t.Errorf("Expected limit from config file, got %d", *limit)
+	}
+	if *profile != "cli.yaml" {
+		t.Errorf("Expected command line to override config file, got %q", *profile)
+	}
+	if got := fileConfig.Get("ELEVATION_PROVIDERS"); got != "hgt" {
+		t.Errorf("Expected ELEVATION_PROVIDERS=hgt in file config, got %q", got)
+	}
+	if !reflect.DeepEqual(unknown, []string{"no_such_option"}) {
+		t.Errorf("Expected only no_such_option to be unknown, got %v", unknown)
+	}
+
+	fresh := flag.NewFlagSet("enrich", flag.ContinueOnError)
+	fresh.Int("limit", 0, "")
+	if _, err := (ConfigFile{"limit": "many"}).Apply(fresh, nil); err == nil {
+		t.Error("Expected error for invalid flag value")
+	}
+}
+
+func TestLoadFromEnvWithConfigFile(t *testing.T) {
+	resetFileConfig(t)
+	t.Setenv("BATCH_SIZE", "50")
+	t.Setenv("MAX_ELEVATION", "")
+
+	fileConfig.Set("BATCH_SIZE", "20")
+	fileConfig.Set("MAX_ELEVATION", "3000")
+
+	config := NewConfig()
+	config.LoadFromEnv()
+
+	if got := config.GetInt("BATCH_SIZE"); got != 50 {
+		t.Errorf("Expected environment to override config file, got %d", got)
+	}
+	if got := config.GetFloat("MAX_ELEVATION"); got != 3000 {
+		t.Errorf("Expected config file to override default, got %g", got)
+	}
+	if got := config.GetInt("API_TIMEOUT_SEC"); got != 30 {
+		t.Errorf("Expected default API_TIMEOUT_SEC, got %d", got)
+	}
+}
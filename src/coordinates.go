@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 )
 
 // Coordinates represents a geographic coordinate pair
@@ -37,7 +39,8 @@ func (ce *CoordinateExtractor) Extract(element OSMElement) (Coordinates, bool) {
 		return coords, coords.IsValid()
 	}
 	
-	if element.Type == "way" && element.Center != nil {
+	// Ways and relations carry the center computed by Overpass
+	if (element.Type == "way" || element.Type == "relation") && element.Center != nil {
 		coords := Coordinates{Lat: element.Center.Lat, Lon: element.Center.Lon}
 		return coords, coords.IsValid()
 	}
@@ -101,6 +104,37 @@ func NewBoundingBox(coords []Coordinates) BoundingBox {
 	return bbox
 }
 
+// ParseBoundingBox parses a "minLat,minLon,maxLat,maxLon" rectangle
+func ParseBoundingBox(value string) (BoundingBox, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return BoundingBox{}, fmt.Errorf("invalid bbox %q: expected minLat,minLon,maxLat,maxLon", value)
+	}
+
+	var coords [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return BoundingBox{}, fmt.Errorf("invalid bbox %q: %v", value, err)
+		}
+		coords[i] = v
+	}
+
+	bbox := BoundingBox{MinLat: coords[0], MinLon: coords[1], MaxLat: coords[2], MaxLon: coords[3]}
+	if bbox.MinLat < -90 || bbox.MaxLat > 90 || bbox.MinLon < -180 || bbox.MaxLon > 180 {
+		return BoundingBox{}, fmt.Errorf("invalid bbox %q: coordinates out of range", value)
+	}
+	if bbox.MinLat >= bbox.MaxLat || bbox.MinLon >= bbox.MaxLon {
+		return BoundingBox{}, fmt.Errorf("invalid bbox %q: min must be less than max", value)
+	}
+	return bbox, nil
+}
+
+// String formats the bounding box as minLat,minLon,maxLat,maxLon (Overpass order)
+func (bb BoundingBox) String() string {
+	return fmt.Sprintf("%g,%g,%g,%g", bb.MinLat, bb.MinLon, bb.MaxLat, bb.MaxLon)
+}
+
 // Area returns the approximate area of the bounding box in square degrees
 func (bb BoundingBox) Area() float64 {
 	return (bb.MaxLat - bb.MinLat) * (bb.MaxLon - bb.MinLon)
@@ -113,6 +147,11 @@ func (bb BoundingBox) Diagonal() float64 {
 	return math.Sqrt(latDiff*latDiff + lonDiff*lonDiff)
 }
 
+// DiagonalKm returns the great-circle distance between the south-west and north-east corners in kilometers
+func (bb BoundingBox) DiagonalKm() float64 {
+	return HaversineDistance(Coordinates{Lat: bb.MinLat, Lon: bb.MinLon}, Coordinates{Lat: bb.MaxLat, Lon: bb.MaxLon})
+}
+
 // HaversineDistance calculates the distance between two coordinates in kilometers
 func HaversineDistance(c1, c2 Coordinates) float64 {
 	const earthRadius = 6371.0 // Earth's radius in kilometers
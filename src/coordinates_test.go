@@ -54,6 +54,16 @@ func TestCoordinateExtractorExtract(t *testing.T) {
 			expectLat:   46.0,
 			expectLon:   26.0,
 		},
+		{
+			name: "Multipolygon relation with center",
+			element: OSMElement{
+				Type:   "relation",
+				Center: &OSMCenter{Lat: 45.4, Lon: 25.4},
+			},
+			expectValid: true,
+			expectLat:   45.4,
+			expectLon:   25.4,
+		},
 		{
 			name: "Node with zero coordinates",
 			element: OSMElement{
@@ -124,3 +134,48 @@ func TestCoordinateExtractorHasValidCoordinates(t *testing.T) {
 		})
 	}
 }
+
+func TestParseBoundingBox(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected BoundingBox
+		wantErr  bool
+	}{
+		{
+			name:     "Carpathians",
+			value:    "45.2,22.5,47.8,26.5",
+			expected: BoundingBox{MinLat: 45.2, MinLon: 22.5, MaxLat: 47.8, MaxLon: 26.5},
+		},
+		{
+			name:     "Spaces",
+			value:    " 45.2, 22.5 ,47.8,26.5 ",
+			expected: BoundingBox{MinLat: 45.2, MinLon: 22.5, MaxLat: 47.8, MaxLon: 26.5},
+		},
+		{name: "Too few values", value: "45.2,22.5,47.8", wantErr: true},
+		{name: "Not a number", value: "45.2,abc,47.8,26.5", wantErr: true},
+		{name: "Min above max", value: "47.8,22.5,45.2,26.5", wantErr: true},
+		{name: "Out of range", value: "45.2,22.5,95,26.5", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bbox, err := ParseBoundingBox(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error for %q", tt.value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if bbox != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, bbox)
+			}
+			if bbox.String() != "45.2,22.5,47.8,26.5" {
+				t.Errorf("Unexpected String(): %s", bbox.String())
+			}
+		})
+	}
+}
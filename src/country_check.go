@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// countryCheckSampleSize is the number of extracted elements reverse-geocoded after extraction
+	countryCheckSampleSize = 20
+	// countryCheckMaxOutside is the share of sampled elements that may lie outside the country
+	// (border areas, territorial waters) before the extraction is rejected
+	countryCheckMaxOutside = 0.5
+)
+
+// countryCheckResult is the outcome of reverse-geocoding a sample of elements
+type countryCheckResult struct {
+	Checked int
+	Outside int
+	// FoundIn counts the countries the outside elements were found in ("" for none)
+	FoundIn map[string]int
+}
+
+// sampleElements picks up to n elements with coordinates, spread evenly over all categories
+func sampleElements(data *OSMData, n int) []Coordinates {
+	coords := NewCoordinateExtractor()
+	var all []Coordinates
+	for _, bucket := range [][]OSMElement{data.Peaks, data.Shelters, data.TrainStations, data.Accommodations} {
+		for _, element := range bucket {
+			if c, ok := coords.Extract(element); ok {
+				all = append(all, c)
+			}
+		}
+	}
+	if len(all) <= n {
+		return all
+	}
+
+	sample := make([]Coordinates, n)
+	for i := range sample {
+		sample[i] = all[i*len(all)/n]
+	}
+	return sample
+}
+
+// countryCheckQuery asks Overpass for the admin_level=2 areas containing each point. A derived
+// "sample" element precedes the areas of every point so they can be told apart.
+func countryCheckQuery(points []Coordinates) string {
+	var b strings.Builder
+	b.WriteString("[out:json][timeout:120];\n")
+	for i, p := range points {
+		fmt.Fprintf(&b, "make sample index=%d; out;\n", i)
+		fmt.Fprintf(&b, "is_in(%.7f,%.7f)->.a; area.a[\"admin_level\"=\"2\"]; out tags;\n", p.Lat, p.Lon)
+	}
+	return b.String()
+}
+
+// parseCountryCheck groups the tags of the areas returned by countryCheckQuery per point
+func parseCountryCheck(r io.Reader, points int) ([][]map[string]string, error) {
+	var response struct {
+		Elements []struct {
+			Type string            `json:"type"`
+			Tags map[string]string `json:"tags"`
+		} `json:"elements"`
+	}
+	if err := json.NewDecoder(r).Decode(&response); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	areas := make([][]map[string]string, points)
+	current := -1
+	for _, element := range response.Elements {
+		switch element.Type {
+		case "sample":
+			index, err := strconv.Atoi(element.Tags["index"])
+			if err != nil || index < 0 || index >= points {
+				return nil, fmt.Errorf("unexpected sample marker %q", element.Tags["index"])
+			}
+			current = index
+		case "area":
+			if current >= 0 {
+				areas[current] = append(areas[current], element.Tags)
+			}
+		}
+	}
+	return areas, nil
+}
+
+// matchesCountry reports whether area tags belong to the selected country
+func matchesCountry(tags map[string]string, area AreaSelector, country string) bool {
+	if area.CountryCode != "" {
+		return tags["ISO3166-1"] == area.CountryCode
+	}
+	return tags["name"] == country
+}
+
+// evaluateCountryCheck counts the sampled points that are not inside the selected country
+func evaluateCountryCheck(areas [][]map[string]string, area AreaSelector, country string) countryCheckResult {
+	result := countryCheckResult{Checked: len(areas), FoundIn: make(map[string]int)}
+	for _, pointAreas := range areas {
+		inside := false
+		for _, tags := range pointAreas {
+			inside = inside || matchesCountry(tags, area, country)
+		}
+		if inside {
+			continue
+		}
+		result.Outside++
+		found := ""
+		if len(pointAreas) > 0 {
+			found = pointAreas[0]["name"]
+		}
+		result.FoundIn[found]++
+	}
+	return result
+}
+
+// describeFoundIn lists the countries outside elements were found in, most frequent first
+func (r countryCheckResult) describeFoundIn() string {
+	names := make([]string, 0, len(r.FoundIn))
+	for name := range r.FoundIn {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if r.FoundIn[names[i]] != r.FoundIn[names[j]] {
+			return r.FoundIn[names[i]] > r.FoundIn[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		label := name
+		if label == "" {
+			label = "no country"
+		}
+		parts[i] = fmt.Sprintf("%s: %d", label, r.FoundIn[name])
+	}
+	return strings.Join(parts, ", ")
+}
+
+// CheckCountryMembership reverse-geocodes a sample of the extracted elements and fails when too
+// many lie outside the selected country, which means the area query matched the wrong boundary
+func (e *OverpassExtractor) CheckCountryMembership(ctx context.Context, data *OSMData) error {
+	points := sampleElements(data, countryCheckSampleSize)
+	if len(points) == 0 {
+		return nil
+	}
+
+	if err := sharedBudget().AcquireContext(ctx, BudgetOverpass); err != nil {
+		return err
+	}
+	resp, err := e.postQuery(ctx, countryCheckQuery(points), 3*time.Minute)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		extractLog.Warn("Country check skipped: %v", err)
+		return nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		extractLog.Warn("Country check skipped: Overpass API returned status %d", resp.StatusCode)
+		return nil
+	}
+
+	areas, err := parseCountryCheck(resp.Body, len(points))
+	if err != nil {
+		extractLog.Warn("Country check skipped: %v", err)
+		return nil
+	}
+
+	result := evaluateCountryCheck(areas, e.Area, e.Country)
+	name := e.Country
+	if e.Area.CountryCode != "" {
+		name = e.Area.CountryCode
+	}
+	if float64(result.Outside) > countryCheckMaxOutside*float64(result.Checked) {
+		return fmt.Errorf("country check failed: %d of %d sampled elements lie outside %s (%s); the area query probably matched the wrong boundary, try --country-code",
+			result.Outside, result.Checked, name, result.describeFoundIn())
+	}
+	extractLog.Info("✓ Country check: %d of %d sampled elements lie inside %s", result.Checked-result.Outside, result.Checked, name)
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// countryCheckServer answers country check queries, placing every queried point in country
+func countryCheckServer(t *testing.T, country string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/status" {
+			io.WriteString(w, "Rate limit: 0\n")
+			return
+		}
+		points := strings.Count(r.FormValue("data"), "is_in(")
+		var elements []string
+		for i := 0; i < points; i++ {
+			elements = append(elements, fmt.Sprintf(`{"type":"sample","id":%d,"tags":{"index":"%d"}}`, i+1, i))
+			elements = append(elements, fmt.Sprintf(`{"type":"area","id":3600000001,"tags":{"name":%q,"ISO3166-1":"XX"}}`, country))
+		}
+		io.WriteString(w, `{"elements":[`+strings.Join(elements, ",")+`]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCheckCountryMembership(t *testing.T) {
+	disableSharedBudget(t)
+	data := &OSMData{Peaks: []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.6, Lon: 24.7},
+		{Type: "node", ID: 2, Lat: 45.4, Lon: 25.5},
+	}}
+
+	tests := []struct {
+		name    string
+		found   string
+		area    AreaSelector
+		wantErr bool
+	}{
+		{name: "Inside", found: "România"},
+		{name: "Wrong boundary", found: "Moldova", wantErr: true},
+		{name: "By country code", found: "Elsewhere", area: AreaSelector{CountryCode: "XX"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := countryCheckServer(t, tt.found)
+			extractor := NewOverpassExtractor("România")
+			extractor.OverpassURL = server.URL + "/api/interpreter"
+			extractor.Area = tt.area
+
+			err := extractor.CheckCountryMembership(context.Background(), data)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "2 of 2 sampled elements lie outside România (Moldova: 2)") {
+					t.Errorf("Expected country check failure, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("CheckCountryMembership() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestParseCountryCheck(t *testing.T) {
+	// The second point is at sea: its marker is followed by no area
+	body := `{"elements":[
+		{"type":"sample","tags":{"index":"0"}},
+		{"type":"area","tags":{"name":"România"}},
+		{"type":"sample","tags":{"index":"1"}},
+		{"type":"sample","tags":{"index":"2"}},
+		{"type":"area","tags":{"name":"Magyarország"}}
+	]}`
+	areas, err := parseCountryCheck(strings.NewReader(body), 3)
+	if err != nil {
+		t.Fatalf("parseCountryCheck() error = %v", err)
+	}
+
+	result := evaluateCountryCheck(areas, AreaSelector{}, "România")
+	if result.Checked != 3 || result.Outside != 2 {
+		t.Errorf("result = %+v, want 2 of 3 outside", result)
+	}
+	if got := result.describeFoundIn(); got != "no country: 1, Magyarország: 1" {
+		t.Errorf("describeFoundIn() = %q", got)
+	}
+
+	if len(sampleElements(&OSMData{Peaks: make([]OSMElement, 50)}, 20)) != 0 {
+		t.Error("Elements without coordinates should not be sampled")
+	}
+}
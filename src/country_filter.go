@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CountryFilter restricts a global run to a curated subset of countries
+type CountryFilter struct {
+	Include []string
+	Exclude []string
+	// Continents and UNRegions (UN M49 regions or sub-regions) select countries by their ISO3166-1 code
+	Continents []string
+	UNRegions  []string
+}
+
+// ParseCountryList parses a comma-separated country list, or reads one country
+// per line from a file when the value starts with @ (lines starting with # are ignored)
+func ParseCountryList(value string) ([]string, error) {
+	if !strings.HasPrefix(value, "@") {
+		return splitList(value), nil
+	}
+
+	path := strings.TrimPrefix(value, "@")
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open country list: %v", err)
+	}
+	defer file.Close()
+
+	var countries []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		countries = append(countries, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read country list %s: %v", path, err)
+	}
+	return countries, nil
+}
+
+// countryInList reports whether a country is named in a list by name, int_name or ISO code
+func countryInList(country CountryInfo, list []string) bool {
+	for _, entry := range list {
+		if entry == country.Name || (country.IntName != "" && entry == country.IntName) {
+			return true
+		}
+		if country.ISOCode != "" && strings.EqualFold(entry, country.ISOCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the countries selected by the filter, warning about include entries that matched nothing
+func (f CountryFilter) Apply(countries []CountryInfo) []CountryInfo {
+	var selected []CountryInfo
+	for _, country := range countries {
+		if len(f.Include) > 0 && !countryInList(country, f.Include) {
+			continue
+		}
+		if len(f.Continents) > 0 && !inGeography(country, f.Continents, true) {
+			continue
+		}
+		if len(f.UNRegions) > 0 && !inGeography(country, f.UNRegions, false) {
+			continue
+		}
+		if countryInList(country, f.Exclude) {
+			continue
+		}
+		selected = append(selected, country)
+	}
+
+	for _, entry := range f.Include {
+		found := false
+		for _, country := range countries {
+			if countryInList(country, []string{entry}) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			pipelineLog.Warn("Country %q from --countries not found", entry)
+		}
+	}
+
+	return selected
+}
+
+// IsEmpty reports whether the filter selects every country
+func (f CountryFilter) IsEmpty() bool {
+	return len(f.Include) == 0 && len(f.Exclude) == 0 && len(f.Continents) == 0 && len(f.UNRegions) == 0
+}
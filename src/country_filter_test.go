@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCountryFilterApply(t *testing.T) {
+	countries := []CountryInfo{
+		{Name: "România", IntName: "Romania", ISOCode: "RO"},
+		{Name: "Moldova", ISOCode: "MD"},
+		{Name: "България", IntName: "Bulgaria", ISOCode: "BG"},
+		{Name: "Magyarország", IntName: "Hungary", ISOCode: "HU"},
+	}
+
+	tests := []struct {
+		name     string
+		filter   CountryFilter
+		expected []string
+	}{
+		{"No filter", CountryFilter{}, []string{"România", "Moldova", "България", "Magyarország"}},
+		{"Include by name, int_name and code", CountryFilter{Include: []string{"România", "Bulgaria", "md"}}, []string{"România", "Moldova", "България"}},
+		{"Exclude", CountryFilter{Exclude: []string{"HU", "Moldova"}}, []string{"România", "България"}},
+		{"Include and exclude", CountryFilter{Include: []string{"RO", "MD"}, Exclude: []string{"MD"}}, []string{"România"}},
+		{"Unknown include", CountryFilter{Include: []string{"Narnia"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, c := range tt.filter.Apply(countries) {
+				names = append(names, c.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestParseCountryList(t *testing.T) {
+	list, err := ParseCountryList(" România, Moldova ,,BG")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, []string{"România", "Moldova", "BG"}) {
+		t.Errorf("Unexpected list: %v", list)
+	}
+
+	path := filepath.Join(t.TempDir(), "countries.txt")
+	content := "# discussed with the local community\nRomânia\n\nMoldova\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	list, err = ParseCountryList("@" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, []string{"România", "Moldova"}) {
+		t.Errorf("Unexpected list from file: %v", list)
+	}
+
+	if _, err := ParseCountryList("@" + filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+}
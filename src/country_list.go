@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// DefaultCountryListFile caches the admin_level=2 country list between runs
+const DefaultCountryListFile = "output/countries.json"
+
+// DefaultCountryListTTLHours is how long a cached country list is used before it is fetched again
+const DefaultCountryListTTLHours = 168
+
+// Country list output formats
+const (
+	CountryFormatText = "text"
+	CountryFormatJSON = "json"
+	CountryFormatCSV  = "csv"
+)
+
+// countryListCache is the content of the country list cache file
+type countryListCache struct {
+	FetchedAt string        `json:"fetched_at"`
+	Countries []CountryInfo `json:"countries"`
+}
+
+// countryListSettings reads COUNTRY_LIST_FILE ("none" disables the cache) and COUNTRY_LIST_TTL_HOURS
+func countryListSettings(config *Config) (string, time.Duration, error) {
+	path := config.Get("COUNTRY_LIST_FILE")
+	if path == "none" {
+		path = ""
+	}
+	ttl := time.Duration(DefaultCountryListTTLHours) * time.Hour
+	if value := config.Get("COUNTRY_LIST_TTL_HOURS"); value != "" {
+		hours, err := strconv.ParseFloat(value, 64)
+		if err != nil || hours < 0 {
+			return path, ttl, fmt.Errorf("invalid COUNTRY_LIST_TTL_HOURS %q", value)
+		}
+		ttl = time.Duration(hours * float64(time.Hour))
+	}
+	return path, ttl, nil
+}
+
+// loadCachedCountries returns the cached country list when it is younger than ttl
+func loadCachedCountries(path string, ttl time.Duration, now time.Time) ([]CountryInfo, bool) {
+	if path == "" || ttl <= 0 {
+		return nil, false
+	}
+	var cache countryListCache
+	if err := loadJSON(path, &cache); err != nil {
+		return nil, false
+	}
+	fetchedAt, err := time.Parse(time.RFC3339, cache.FetchedAt)
+	if err != nil || now.Sub(fetchedAt) > ttl || len(cache.Countries) == 0 {
+		return nil, false
+	}
+	return cache.Countries, true
+}
+
+// saveCachedCountries writes the country list cache
+func saveCachedCountries(path string, countries []CountryInfo, now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return saveJSON(path, countryListCache{FetchedAt: now.UTC().Format(time.RFC3339), Countries: countries})
+}
+
+// fetchAllCountries returns the sorted list of countries, from the cache file while it is fresh
+// and otherwise from the Overpass API. refresh skips the cache.
+func fetchAllCountries(ctx context.Context, refresh bool) ([]CountryInfo, error) {
+	config := NewConfig()
+	config.LoadFromEnv()
+	path, ttl, err := countryListSettings(config)
+	if err != nil {
+		return nil, err
+	}
+
+	if !refresh {
+		if countries, ok := loadCachedCountries(path, ttl, time.Now()); ok {
+			return countries, nil
+		}
+	}
+
+	countries, err := queryCountries(ctx, `
+[out:json][timeout:60];
+area["admin_level"="2"];
+out tags;
+`)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" && len(countries) > 0 {
+		if err := saveCachedCountries(path, countries, time.Now()); err != nil {
+			extractLog.Warn("Failed to cache country list: %v", err)
+		}
+	}
+	return countries, nil
+}
+
+// checkCountryFormat rejects unknown country list formats
+func checkCountryFormat(format string) error {
+	switch format {
+	case CountryFormatText, CountryFormatJSON, CountryFormatCSV:
+		return nil
+	}
+	return fmt.Errorf("invalid country list format %q (expected %s, %s or %s)",
+		format, CountryFormatText, CountryFormatJSON, CountryFormatCSV)
+}
+
+// writeCountries writes the country list as JSON or CSV
+func writeCountries(w io.Writer, countries []CountryInfo, format string) error {
+	switch format {
+	case CountryFormatJSON:
+		if countries == nil {
+			countries = []CountryInfo{}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(countries)
+	case CountryFormatCSV:
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"iso_code", "name", "int_name"})
+		for _, country := range countries {
+			writer.Write([]string{country.ISOCode, country.Name, country.IntName})
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return checkCountryFormat(format)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadCachedCountries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "countries.json")
+	fetched := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := saveCachedCountries(path, []CountryInfo{{Name: "România", ISOCode: "RO"}}, fetched); err != nil {
+		t.Fatalf("saveCachedCountries() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+		ttl  time.Duration
+		now  time.Time
+		want bool
+	}{
+		{name: "Fresh", path: path, ttl: 24 * time.Hour, now: fetched.Add(time.Hour), want: true},
+		{name: "Expired", path: path, ttl: 24 * time.Hour, now: fetched.Add(25 * time.Hour)},
+		{name: "TTL zero", path: path, ttl: 0, now: fetched},
+		{name: "Disabled", path: "", ttl: 24 * time.Hour, now: fetched},
+		{name: "Missing file", path: filepath.Join(t.TempDir(), "none.json"), ttl: 24 * time.Hour, now: fetched},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			countries, ok := loadCachedCountries(tt.path, tt.ttl, tt.now)
+			if ok != tt.want {
+				t.Fatalf("loadCachedCountries() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && (len(countries) != 1 || countries[0].ISOCode != "RO") {
+				t.Errorf("loadCachedCountries() = %+v", countries)
+			}
+		})
+	}
+}
+
+func TestFetchAllCountriesCache(t *testing.T) {
+	disableSharedBudget(t)
+	queries := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/status" {
+			io.WriteString(w, "Rate limit: 0\n")
+			return
+		}
+		queries++
+		io.WriteString(w, `{"elements":[{"type":"area","tags":{"name":"România","ISO3166-1":"RO"}},{"type":"area","tags":{"name":"Moldova","ISO3166-1":"MD"}}]}`)
+	}))
+	defer server.Close()
+	t.Setenv("OVERPASS_URL", server.URL+"/api/interpreter")
+	t.Setenv("OVERPASS_MIRRORS", "none")
+	t.Setenv("COUNTRY_LIST_FILE", filepath.Join(t.TempDir(), "countries.json"))
+
+	for i, refresh := range []bool{false, false, true} {
+		countries, err := fetchAllCountries(context.Background(), refresh)
+		if err != nil {
+			t.Fatalf("fetchAllCountries() error = %v", err)
+		}
+		if len(countries) != 2 || countries[0].Name != "Moldova" {
+			t.Errorf("fetchAllCountries() = %+v", countries)
+		}
+		if want := []int{1, 1, 2}[i]; queries != want {
+			t.Errorf("After call %d: %d Overpass queries, want %d", i+1, queries, want)
+		}
+	}
+}
+
+func TestWriteCountries(t *testing.T) {
+	countries := []CountryInfo{
+		{Name: "Côte d'Ivoire", IntName: "Ivory Coast", ISOCode: "CI"},
+		{Name: "România", ISOCode: "RO"},
+	}
+
+	tests := []struct {
+		format  string
+		want    string
+		wantErr bool
+	}{
+		{format: CountryFormatCSV, want: "iso_code,name,int_name\nCI,Côte d'Ivoire,Ivory Coast\nRO,România,\n"},
+		{format: CountryFormatJSON, want: `[
+  {
+    "name": "Côte d'Ivoire",
+    "int_name": "Ivory Coast",
+    "iso_code": "CI"
+  },
+  {
+    "name": "România",
+    "iso_code": "RO"
+  }
+]
+`},
+		{format: "xml", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := writeCountries(&buf, countries, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("writeCountries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("writeCountries() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
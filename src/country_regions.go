@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// unSubregion is a UN M49 sub-region with its region, continent and member ISO3166-1 codes
+type unSubregion struct {
+	Name      string
+	Region    string
+	Continent string
+	Countries []string
+}
+
+// unSubregions is the UN M49 geoscheme, extended with Taiwan, Kosovo and Antarctica which
+// have an OSM admin_level=2 boundary. Continents follow the seven-continent model.
+var unSubregions = []unSubregion{
+	{"Northern Africa", "Africa", "Africa", []string{"DZ", "EG", "EH", "LY", "MA", "SD", "TN"}},
+	{"Eastern Africa", "Africa", "Africa", []string{"BI", "DJ", "ER", "ET", "IO", "KE", "KM", "MG", "MU", "MW", "MZ", "RE", "RW", "SC", "SO", "SS", "TF", "TZ", "UG", "YT", "ZM", "ZW"}},
+	{"Middle Africa", "Africa", "Africa", []string{"AO", "CD", "CF", "CG", "CM", "GA", "GQ", "ST", "TD"}},
+	{"Southern Africa", "Africa", "Africa", []string{"BW", "LS", "NA", "SZ", "ZA"}},
+	{"Western Africa", "Africa", "Africa", []string{"BF", "BJ", "CI", "CV", "GH", "GM", "GN", "GW", "LR", "ML", "MR", "NE", "NG", "SH", "SL", "SN", "TG"}},
+	{"Caribbean", "Americas", "North America", []string{"AG", "AI", "AW", "BB", "BL", "BQ", "BS", "CU", "CW", "DM", "DO", "GD", "GP", "HT", "JM", "KN", "KY", "LC", "MF", "MQ", "MS", "PR", "SX", "TC", "TT", "VC", "VG", "VI"}},
+	{"Central America", "Americas", "North America", []string{"BZ", "CR", "GT", "HN", "MX", "NI", "PA", "SV"}},
+	{"Northern America", "Americas", "North America", []string{"BM", "CA", "GL", "PM", "US"}},
+	{"South America", "Americas", "South America", []string{"AR", "BO", "BR", "BV", "CL", "CO", "EC", "FK", "GF", "GS", "GY", "PE", "PY", "SR", "UY", "VE"}},
+	{"Central Asia", "Asia", "Asia", []string{"KG", "KZ", "TJ", "TM", "UZ"}},
+	{"Eastern Asia", "Asia", "Asia", []string{"CN", "HK", "JP", "KP", "KR", "MN", "MO", "TW"}},
+	{"South-eastern Asia", "Asia", "Asia", []string{"BN", "ID", "KH", "LA", "MM", "MY", "PH", "SG", "TH", "TL", "VN"}},
+	{"Southern Asia", "Asia", "Asia", []string{"AF", "BD", "BT", "IN", "IR", "LK", "MV", "NP", "PK"}},
+	{"Western Asia", "Asia", "Asia", []string{"AE", "AM", "AZ", "BH", "CY", "GE", "IL", "IQ", "JO", "KW", "LB", "OM", "PS", "QA", "SA", "SY", "TR", "YE"}},
+	{"Eastern Europe", "Europe", "Europe", []string{"BG", "BY", "CZ", "HU", "MD", "PL", "RO", "RU", "SK", "UA"}},
+	{"Northern Europe", "Europe", "Europe", []string{"AX", "DK", "EE", "FI", "FO", "GB", "GG", "IE", "IM", "IS", "JE", "LT", "LV", "NO", "SE", "SJ"}},
+	{"Southern Europe", "Europe", "Europe", []string{"AD", "AL", "BA", "ES", "GI", "GR", "HR", "IT", "ME", "MK", "MT", "PT", "RS", "SI", "SM", "VA", "XK"}},
+	{"Western Europe", "Europe", "Europe", []string{"AT", "BE", "CH", "DE", "FR", "LI", "LU", "MC", "NL"}},
+	{"Australia and New Zealand", "Oceania", "Oceania", []string{"AU", "CC", "CX", "HM", "NF", "NZ"}},
+	{"Melanesia", "Oceania", "Oceania", []string{"FJ", "NC", "PG", "SB", "VU"}},
+	{"Micronesia", "Oceania", "Oceania", []string{"FM", "GU", "KI", "MH", "MP", "NR", "PW", "UM"}},
+	{"Polynesia", "Oceania", "Oceania", []string{"AS", "CK", "NU", "PF", "PN", "TK", "TO", "TV", "WF", "WS"}},
+	{"Antarctica", "Antarctica", "Antarctica", []string{"AQ"}},
+}
+
+// countrySubregions indexes unSubregions by ISO3166-1 code
+var countrySubregions = func() map[string]*unSubregion {
+	index := make(map[string]*unSubregion)
+	for i := range unSubregions {
+		for _, code := range unSubregions[i].Countries {
+			index[code] = &unSubregions[i]
+		}
+	}
+	return index
+}()
+
+// countrySubregion returns the UN sub-region of a country, which must have an ISO3166-1 code
+func countrySubregion(country CountryInfo) (*unSubregion, bool) {
+	subregion, ok := countrySubregions[strings.ToUpper(country.ISOCode)]
+	return subregion, ok
+}
+
+// knownGeographyNames returns the sorted distinct continent names, or region and sub-region names
+func knownGeographyNames(continents bool) []string {
+	seen := make(map[string]bool)
+	for _, subregion := range unSubregions {
+		if continents {
+			seen[subregion.Continent] = true
+		} else {
+			seen[subregion.Region] = true
+			seen[subregion.Name] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// checkGeographyNames rejects continent (or UN region) names missing from the table
+func checkGeographyNames(names []string, continents bool) error {
+	known := knownGeographyNames(continents)
+	for _, name := range names {
+		found := false
+		for _, k := range known {
+			found = found || strings.EqualFold(name, k)
+		}
+		if found {
+			continue
+		}
+		if continents {
+			return fmt.Errorf("unknown continent %q (expected one of: %s)", name, strings.Join(known, ", "))
+		}
+		return fmt.Errorf("unknown UN region %q (expected one of: %s)", name, strings.Join(known, ", "))
+	}
+	return nil
+}
+
+// inGeography reports whether a country lies in one of the continents, or in one of the
+// UN regions or sub-regions. Countries without a known ISO3166-1 code match nothing.
+func inGeography(country CountryInfo, names []string, continents bool) bool {
+	subregion, ok := countrySubregion(country)
+	if !ok {
+		return false
+	}
+	for _, name := range names {
+		if continents && strings.EqualFold(name, subregion.Continent) {
+			return true
+		}
+		if !continents && (strings.EqualFold(name, subregion.Region) || strings.EqualFold(name, subregion.Name)) {
+			return true
+		}
+	}
+	return false
+}
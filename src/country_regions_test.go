@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountryFilterGeography(t *testing.T) {
+	countries := []CountryInfo{
+		{Name: "România", ISOCode: "RO"},
+		{Name: "France", ISOCode: "FR"},
+		{Name: "Türkiye", ISOCode: "TR"},
+		{Name: "Perú", ISOCode: "PE"},
+		{Name: "México", ISOCode: "MX"},
+		{Name: "Unknown land"},
+	}
+
+	tests := []struct {
+		name     string
+		filter   CountryFilter
+		expected []string
+	}{
+		{"Continent", CountryFilter{Continents: []string{"europe"}}, []string{"România", "France"}},
+		{"Several continents", CountryFilter{Continents: []string{"Asia", "South America"}}, []string{"Türkiye", "Perú"}},
+		{"UN region", CountryFilter{UNRegions: []string{"Americas"}}, []string{"Perú", "México"}},
+		{"UN sub-region", CountryFilter{UNRegions: []string{"Eastern Europe", "Western Asia"}}, []string{"România", "Türkiye"}},
+		{"Continent and exclude", CountryFilter{Continents: []string{"Europe"}, Exclude: []string{"FR"}}, []string{"România"}},
+		{"Continent and UN region", CountryFilter{Continents: []string{"North America"}, UNRegions: []string{"Central America"}}, []string{"México"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var names []string
+			for _, c := range tt.filter.Apply(countries) {
+				names = append(names, c.Name)
+			}
+			if !reflect.DeepEqual(names, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+func TestParseCountryFilterGeography(t *testing.T) {
+	tests := []struct {
+		name       string
+		continents string
+		regions    string
+		wantErr    bool
+	}{
+		{name: "Valid", continents: "Europe, Asia", regions: "Southern Europe,Polynesia"},
+		{name: "Unknown continent", continents: "Atlantis", wantErr: true},
+		{name: "Continent is not a UN region", regions: "North America", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCountryFilter("", "", tt.continents, tt.regions)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCountryFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUNSubregionsCoverEachCodeOnce(t *testing.T) {
+	total := 0
+	for _, subregion := range unSubregions {
+		total += len(subregion.Countries)
+	}
+	if total != len(countrySubregions) {
+		t.Errorf("%d codes listed but %d distinct", total, len(countrySubregions))
+	}
+	if subregion, ok := countrySubregion(CountryInfo{ISOCode: "ro"}); !ok || subregion.Name != "Eastern Europe" {
+		t.Errorf("countrySubregion(ro) = %v, %v", subregion, ok)
+	}
+}
@@ -2,12 +2,19 @@ package main
 
 import (
 	"encoding/csv"
+	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
-type CSVExporter struct{}
+type CSVExporter struct {
+	// AllTags adds a tags column with every tag of the element as a JSON object
+	AllTags bool
+}
 
 type ElementInfo struct {
 	Category        string
@@ -21,6 +28,7 @@ type ElementInfo struct {
 	Tourism         string
 	Railway         string
 	OSMLink         string
+	Tags            string
 }
 
 func NewCSVExporter() *CSVExporter {
@@ -38,7 +46,7 @@ func (e *CSVExporter) getElementInfo(element OSMElement, category string) Elemen
 	if element.Type == "node" {
 		info.Lat = fmt.Sprintf("%.6f", element.Lat)
 		info.Lon = fmt.Sprintf("%.6f", element.Lon)
-	} else if element.Type == "way" && element.Center != nil {
+	} else if (element.Type == "way" || element.Type == "relation") && element.Center != nil {
 		info.Lat = fmt.Sprintf("%.6f", element.Center.Lat)
 		info.Lon = fmt.Sprintf("%.6f", element.Center.Lon)
 	}
@@ -52,7 +60,7 @@ func (e *CSVExporter) getElementInfo(element OSMElement, category string) Elemen
 		}
 
 		info.Elevation = element.Tags["ele"]
-		info.ElevationSource = element.Tags["ele:source"]
+		_, info.ElevationSource = eleSourceTag(element.Tags)
 		info.Tourism = element.Tags["tourism"]
 		info.Railway = element.Tags["railway"]
 	}
@@ -60,6 +68,19 @@ func (e *CSVExporter) getElementInfo(element OSMElement, category string) Elemen
 	// OSM link
 	info.OSMLink = fmt.Sprintf("https://www.openstreetmap.org/%s/%d", element.Type, element.ID)
 
+	if e.AllTags {
+		tags := element.Tags
+		if tags == nil {
+			tags = map[string]string{}
+		}
+		// Keys are sorted, so exports of the same data are identical; "&" in names stays readable
+		var encoded strings.Builder
+		encoder := json.NewEncoder(&encoded)
+		encoder.SetEscapeHTML(false)
+		encoder.Encode(tags)
+		info.Tags = strings.TrimSuffix(encoded.String(), "\n")
+	}
+
 	return info
 }
 
@@ -67,21 +88,15 @@ func (e *CSVExporter) ExportToCSV(data ValidatedData, outputFile string) (int, e
 	var rows []ElementInfo
 
 	// Process all categories
-	categories := map[string][]OSMElement{
-		"train_stations":       data.TrainStations.ValidElements,
-		"alpine_huts":          data.AlpineHuts.ValidElements,
-		"other_accommodations": data.OtherAccommodations.ValidElements,
-	}
-
-	for category, elements := range categories {
-		for _, element := range elements {
+	for _, category := range categoryKeys {
+		for _, element := range data.Category(category).ValidElements {
 			info := e.getElementInfo(element, category)
 			rows = append(rows, info)
 		}
 	}
 
 	if len(rows) == 0 {
-		fmt.Println("No data to export")
+		exportLog.Info("No data to export")
 		return 0, nil
 	}
 
@@ -100,6 +115,9 @@ func (e *CSVExporter) ExportToCSV(data ValidatedData, outputFile string) (int, e
 		"category", "type", "id", "name", "lat", "lon",
 		"elevation", "elevation_source", "tourism", "railway", "osm_link",
 	}
+	if e.AllTags {
+		header = append(header, "tags")
+	}
 	if err := writer.Write(header); err != nil {
 		return 0, fmt.Errorf("failed to write header: %v", err)
 	}
@@ -119,34 +137,58 @@ func (e *CSVExporter) ExportToCSV(data ValidatedData, outputFile string) (int, e
 			row.Railway,
 			row.OSMLink,
 		}
+		if e.AllTags {
+			record = append(record, row.Tags)
+		}
 		if err := writer.Write(record); err != nil {
 			return 0, fmt.Errorf("failed to write row: %v", err)
 		}
 	}
 
-	fmt.Printf("Exported %d elements to %s\n", len(rows), outputFile)
+	exportLog.Info("Exported %d elements to %s", len(rows), outputFile)
 	return len(rows), nil
 }
 
-func runExportCSV() error {
-	fmt.Println("\n" + string(repeat('=', 60)))
-	fmt.Println("STEP 5: EXPORT - Creating CSV output")
-	fmt.Println(string(repeat('=', 60)))
+func runExportCSV(ws Workspace) error {
+	exportLog.Banner("STEP 5: EXPORT - Creating CSV output")
+	started := time.Now()
+	if err := runStepStartHook(ws, StepExportCSV, ""); err != nil {
+		return err
+	}
 
 	// Load validated data
 	var data ValidatedData
-	if err := loadJSON("output/osm_data_validated.json", &data); err != nil {
-		return fmt.Errorf("output/osm_data_validated.json not found. Run --validate first: %v", err)
+	validatedFile := ws.File(DefaultValidatedDataFile)
+	if err := loadPipelineFile(validatedFile, SchemaValidated, &data); err != nil {
+		return pipelineLoadError(validatedFile, "validate", err)
 	}
 
 	// Export to CSV
+	csvFile := ws.File(DefaultCSVFile)
+	config := NewConfig()
+	config.LoadFromEnv()
 	exporter := NewCSVExporter()
-	count, err := exporter.ExportToCSV(data, "output/elevation_data.csv")
+	exporter.AllTags = config.GetBool("CSV_ALL_TAGS")
+	count, err := exporter.ExportToCSV(data, csvFile)
 	if err != nil {
 		return err
 	}
 
-	fmt.Printf("\n✓ Exported %d elements to output/elevation_data.csv\n\n", count)
+	exportLog.Info("✓ Exported %d elements to %s", count, csvFile)
+	recordManifestStep(ws, ManifestStep{
+		Step:   StepExportCSV,
+		Counts: map[string]int{"rows": count},
+	}, started, []string{validatedFile}, []string{csvFile})
 
 	return nil
 }
+
+// registerCSVAllTagsFlag adds --csv-all-tags and returns a function that applies it when given
+func registerCSVAllTagsFlag(fs *flag.FlagSet) func() {
+	allTags := fs.Bool("csv-all-tags", false, "Add a tags column with every tag of the element as JSON to the CSV export (default: CSV_ALL_TAGS)")
+	return func() {
+		if flagWasSet(fs, "csv-all-tags") {
+			flagConfig.Set("CSV_ALL_TAGS", strconv.FormatBool(*allTags))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportToCSVAllTags(t *testing.T) {
+	elevation := 1950.0
+	data := ValidatedData{}
+	data.AlpineHuts.ValidElements = []OSMElement{{
+		Type: "node", ID: 7, Lat: 45.6, Lon: 24.6,
+		Tags:             map[string]string{"tourism": "alpine_hut", "name": "Cabana Bâlea", "operator": "Salvamont & Club Alpin", "ele": "1950"},
+		ElevationFetched: &elevation,
+	}}
+
+	tests := []struct {
+		name     string
+		allTags  bool
+		wantLast string
+		wantCols int
+	}{
+		{"Default", false, "https://www.openstreetmap.org/node/7", 11},
+		{"AllTags", true, `{"ele":"1950","name":"Cabana Bâlea","operator":"Salvamont & Club Alpin","tourism":"alpine_hut"}`, 12},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "elevation_data.csv")
+			exporter := NewCSVExporter()
+			exporter.AllTags = tt.allTags
+			count, err := exporter.ExportToCSV(data, path)
+			if err != nil {
+				t.Fatalf("ExportToCSV() error = %v", err)
+			}
+			if count != 1 {
+				t.Errorf("ExportToCSV() = %d rows, want 1", count)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer file.Close()
+			records, err := csv.NewReader(file).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("got %d records, want header and 1 row", len(records))
+			}
+			header, row := records[0], records[1]
+			if len(header) != tt.wantCols || len(row) != tt.wantCols {
+				t.Fatalf("got %d header and %d row columns, want %d", len(header), len(row), tt.wantCols)
+			}
+			if tt.allTags && header[len(header)-1] != "tags" {
+				t.Errorf("last header column = %q, want tags", header[len(header)-1])
+			}
+			if got := row[len(row)-1]; got != tt.wantLast {
+				t.Errorf("last column = %q, want %q", got, tt.wantLast)
+			}
+		})
+	}
+}
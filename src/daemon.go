@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// DefaultDaemonLockFile is held while a daemon run is in progress
+const DefaultDaemonLockFile = "output/daemon.lock"
+
+// minDaemonInterval keeps the daemon from hammering Overpass with back-to-back runs
+const minDaemonInterval = time.Minute
+
+// ErrRunLocked is returned when another run holds the lock file
+var ErrRunLocked = errors.New("another run is in progress")
+
+// DaemonOptions configures the scheduled daemon
+type DaemonOptions struct {
+	Interval time.Duration
+	LockFile string
+	Pipeline PipelineOptions
+}
+
+// resolveDaemonSettings reads DAEMON_INTERVAL and DAEMON_COUNTRIES
+func resolveDaemonSettings(config *Config) (time.Duration, CountryFilter, error) {
+	interval, err := time.ParseDuration(config.Get("DAEMON_INTERVAL"))
+	if err != nil || interval < minDaemonInterval {
+		return 0, CountryFilter{}, fmt.Errorf("invalid DAEMON_INTERVAL %q (expected a duration of at least %v, e.g. 168h)", config.Get("DAEMON_INTERVAL"), minDaemonInterval)
+	}
+	countries, err := ParseCountryList(config.Get("DAEMON_COUNTRIES"))
+	if err != nil {
+		return 0, CountryFilter{}, err
+	}
+	if len(countries) == 0 {
+		return 0, CountryFilter{}, fmt.Errorf("DAEMON_COUNTRIES lists no countries")
+	}
+	return interval, CountryFilter{Include: countries}, nil
+}
+
+// acquireRunLock creates the lock file with the process ID, or fails with ErrRunLocked while
+// a live process holds it. A lock left behind by a crashed run is replaced.
+func acquireRunLock(path string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			fmt.Fprintf(file, "%d %s\n", os.Getpid(), time.Now().UTC().Format(time.RFC3339))
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %v", err)
+		}
+
+		pid, since := readRunLock(path)
+		if pid > 0 && processAlive(pid) {
+			return nil, fmt.Errorf("%w (pid %d since %s, lock file %s)", ErrRunLocked, pid, since, path)
+		}
+		pipelineLog.Warn("Removing stale lock file %s (pid %d is not running)", path, pid)
+		os.Remove(path)
+	}
+	return nil, fmt.Errorf("%w (lock file %s)", ErrRunLocked, path)
+}
+
+// readRunLock returns the process ID and start time recorded in a lock file
+func readRunLock(path string) (int, string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, ""
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, ""
+	}
+	pid, _ := strconv.Atoi(fields[0])
+	return pid, fields[1]
+}
+
+// processAlive reports whether a process with the given ID exists
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
+
+// runDaemon runs the incremental pipeline for the configured countries every interval until
+// ctx is canceled. A failed run is reported and retried at the next interval; a run is skipped
+// while another one holds the lock file.
+func runDaemon(ctx context.Context, opts DaemonOptions) error {
+	opts.Pipeline.Incremental = true
+	pipelineLog.Info("Daemon started: %s every %v", strings.Join(opts.Pipeline.Countries.Include, ", "), opts.Interval)
+
+	for {
+		if err := runDaemonCycle(ctx, opts); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			pipelineLog.Error("Daemon run failed: %v", err)
+		}
+
+		pipelineLog.Info("Next run at %s", time.Now().Add(opts.Interval).Format("2006-01-02 15:04:05"))
+		if err := sleepContext(ctx, opts.Interval); err != nil {
+			break
+		}
+	}
+	pipelineLog.Info("Daemon stopped")
+	return nil
+}
+
+// runDaemonCycle runs the pipeline once while holding the lock file
+func runDaemonCycle(ctx context.Context, opts DaemonOptions) error {
+	release, err := acquireRunLock(opts.LockFile)
+	if err != nil {
+		if errors.Is(err, ErrRunLocked) {
+			pipelineLog.Info("Skipping this run: %v", err)
+			return nil
+		}
+		return err
+	}
+	defer release()
+
+	return runProcessAllCountries(ctx, opts.Pipeline)
+}
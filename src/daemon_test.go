@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquireRunLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "output", "daemon.lock")
+
+	release, err := acquireRunLock(path)
+	if err != nil {
+		t.Fatalf("acquireRunLock() error = %v", err)
+	}
+	if pid, _ := readRunLock(path); pid != os.Getpid() {
+		t.Errorf("lock file holds pid %d, want %d", pid, os.Getpid())
+	}
+	if _, err := acquireRunLock(path); !errors.Is(err, ErrRunLocked) {
+		t.Errorf("second acquireRunLock() error = %v, want ErrRunLocked", err)
+	}
+
+	release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("lock file still exists after release: %v", err)
+	}
+
+	// A lock left behind by a process that no longer runs is replaced
+	if err := os.WriteFile(path, []byte("999999999 2024-05-01T10:00:00Z\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	release, err = acquireRunLock(path)
+	if err != nil {
+		t.Fatalf("acquireRunLock() over a stale lock error = %v", err)
+	}
+	release()
+}
+
+func TestResolveDaemonSettings(t *testing.T) {
+	tests := []struct {
+		name      string
+		interval  string
+		countries string
+		want      time.Duration
+		wantErr   bool
+	}{
+		{name: "Weekly", interval: "168h", countries: "România, MD", want: 168 * time.Hour},
+		{name: "Too short", interval: "10s", countries: "România", wantErr: true},
+		{name: "Not a duration", interval: "weekly", countries: "România", wantErr: true},
+		{name: "No countries", interval: "24h", countries: " ", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Set("DAEMON_INTERVAL", tt.interval)
+			config.Set("DAEMON_COUNTRIES", tt.countries)
+			interval, filter, err := resolveDaemonSettings(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveDaemonSettings() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (interval != tt.want || len(filter.Include) != 2) {
+				t.Errorf("resolveDaemonSettings() = %v, %+v", interval, filter)
+			}
+		})
+	}
+}
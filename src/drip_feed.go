@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// ErrEditQuotaReached is returned by UploadAll when the hourly or daily edit limit leaves
+// elements for a later invocation
+var ErrEditQuotaReached = errors.New("edit quota reached")
+
+// waitForEditBudget returns how many edits the next changeset may hold (-1 when unlimited). It
+// pauses while the edit budget is used up, or returns ErrEditQuotaReached when the next slot is
+// further away than BUDGET_MAX_WAIT_MIN.
+func waitForEditBudget(ctx context.Context, budget *Budget) (int, error) {
+	for {
+		available, wait := budget.Available(BudgetOSMEdits)
+		if available != 0 {
+			return available, nil
+		}
+		if wait > budget.maxWait {
+			return 0, fmt.Errorf("%w, next edits allowed at %s", ErrEditQuotaReached, budget.now().Add(wait).Format("2006-01-02 15:04"))
+		}
+		uploadLog.Info("Edit limit reached, pausing %v...", wait.Round(time.Second))
+		if err := budget.sleep(ctx, wait); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// registerDripFeedFlags adds --max-edits-per-hour and --max-edits-per-day and returns a
+// function that applies them when given
+func registerDripFeedFlags(fs *flag.FlagSet) func() error {
+	hourly := fs.Int("max-edits-per-hour", 0, "Upload at most this many edits per hour across invocations (0 = unlimited; default: BUDGET_OSM_EDITS_HOURLY)")
+	daily := fs.Int("max-edits-per-day", 0, "Upload at most this many edits per day across invocations (0 = unlimited; default: BUDGET_OSM_EDITS_DAILY)")
+	return func() error {
+		for name, value := range map[string]*int{"max-edits-per-hour": hourly, "max-edits-per-day": daily} {
+			if flagWasSet(fs, name) && *value < 0 {
+				return fmt.Errorf("--%s must not be negative", name)
+			}
+		}
+		if flagWasSet(fs, "max-edits-per-hour") {
+			flagConfig.Set("BUDGET_OSM_EDITS_HOURLY", strconv.Itoa(*hourly))
+		}
+		if flagWasSet(fs, "max-edits-per-day") {
+			flagConfig.Set("BUDGET_OSM_EDITS_DAILY", strconv.Itoa(*daily))
+		}
+		return nil
+	}
+}
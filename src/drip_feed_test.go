@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWaitForEditBudget(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     BudgetLimit
+		used      int
+		available int
+		waited    time.Duration
+		wantErr   bool
+	}{
+		{name: "Unlimited", available: -1},
+		{name: "Edits left", limit: BudgetLimit{Hourly: 10}, used: 4, available: 6},
+		{name: "Waits for the next hour", limit: BudgetLimit{Hourly: 10}, used: 10, available: 10, waited: 30 * time.Minute},
+		{name: "Daily limit beyond the maximum wait", limit: BudgetLimit{Daily: 10}, used: 10, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC)
+			clock := start
+			budget := newTestBudget("", map[string]BudgetLimit{BudgetOSMEdits: tt.limit}, time.Hour, &clock)
+			for i := 0; i < tt.used; i++ {
+				budget.AcquireContext(context.Background(), BudgetOSMEdits)
+			}
+			clock = start
+
+			available, err := waitForEditBudget(context.Background(), budget)
+			if tt.wantErr {
+				if !errors.Is(err, ErrEditQuotaReached) {
+					t.Errorf("waitForEditBudget() error = %v, want ErrEditQuotaReached", err)
+				}
+				return
+			}
+			if err != nil || available != tt.available {
+				t.Errorf("waitForEditBudget() = %d, %v, want %d", available, err, tt.available)
+			}
+			if waited := clock.Sub(start); waited != tt.waited {
+				t.Errorf("waited %v, want %v", waited, tt.waited)
+			}
+		})
+	}
+}
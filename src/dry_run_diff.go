@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// DefaultDryRunDiffFile is where a dry-run upload writes the tag changes it would make
+const DefaultDryRunDiffFile = "output/dry_run_diff.json"
+
+// TagChange is the before and after value of one tag; "" means the tag is absent
+type TagChange struct {
+	Key    string `json:"key"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// ElementDiff is the change a dry run would make to one element
+type ElementDiff struct {
+	ElementType string      `json:"element_type"`
+	ElementID   int64       `json:"element_id"`
+	Version     int         `json:"version,omitempty"`
+	Changes     []TagChange `json:"changes"`
+	// Skipped or Error explain why the element would not be edited
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DryRunDiffReport collects the element diffs of a dry-run upload
+type DryRunDiffReport struct {
+	GeneratedAt string        `json:"generated_at"`
+	Country     string        `json:"country"`
+	Elements    []ElementDiff `json:"elements"`
+}
+
+// NewDryRunDiffReport creates an empty report
+func NewDryRunDiffReport(country string) *DryRunDiffReport {
+	return &DryRunDiffReport{
+		GeneratedAt: time.Now().UTC().Format(time.RFC3339),
+		Country:     country,
+		Elements:    []ElementDiff{},
+	}
+}
+
+// Save writes the report as JSON
+func (r *DryRunDiffReport) Save(path string) error {
+	if err := saveJSON(path, r); err != nil {
+		return fmt.Errorf("failed to save dry-run diff %s: %v", path, err)
+	}
+	return nil
+}
+
+// diffTags lists the tags of newTags whose value differs from current, sorted by key
+func diffTags(current []NodeTag, newTags map[string]string) []TagChange {
+	keys := make([]string, 0, len(newTags))
+	for key := range newTags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changes := []TagChange{}
+	for _, key := range keys {
+		if before := tagValue(current, key); before != newTags[key] {
+			changes = append(changes, TagChange{Key: key, Before: before, After: newTags[key]})
+		}
+	}
+	return changes
+}
+
+// formatTagValue shows an absent tag as "(none)"
+func formatTagValue(value string) string {
+	if value == "" {
+		return "(none)"
+	}
+	return value
+}
+
+// previewElement fetches the live element and prints the tag changes an upload would make,
+// applying the same checks as a real upload. The diff is added to the uploader's report.
+func (u *OSMUploader) previewElement(ctx context.Context, element OSMElement, newTags map[string]string) error {
+	diff := ElementDiff{ElementType: element.Type, ElementID: element.ID, Changes: []TagChange{}}
+	err := u.diffElement(ctx, element, newTags, &diff)
+
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+	if err != nil {
+		if diff.Skipped == "" {
+			diff.Error = err.Error()
+		}
+		uploadLog.Info("[DRY-RUN] Would not update %s %d: %v", element.Type, element.ID, err)
+	} else {
+		uploadLog.Info("[DRY-RUN] Would update %s %d (v%d):", element.Type, element.ID, diff.Version)
+		for _, change := range diff.Changes {
+			uploadLog.Info("  %s: %s → %s", change.Key, formatTagValue(change.Before), formatTagValue(change.After))
+		}
+	}
+
+	if u.dryRunDiff != nil {
+		u.dryRunDiff.Elements = append(u.dryRunDiff.Elements, diff)
+	}
+	return err
+}
+
+// diffElement fills diff with the live version and tag changes of an element
+func (u *OSMUploader) diffElement(ctx context.Context, element OSMElement, newTags map[string]string, diff *ElementDiff) error {
+	var tags []NodeTag
+	switch element.Type {
+	case "node":
+		node, err := u.apiClient.FetchNode(ctx, element.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch node: %w", err)
+		}
+		diff.Version, tags = node.Version, node.Tags
+	case "way":
+		way, err := u.apiClient.FetchWay(ctx, element.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch way: %w", err)
+		}
+		diff.Version, tags = way.Version, way.Tags
+	case "relation":
+		relation, err := u.apiClient.FetchRelation(ctx, element.ID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch relation: %w", err)
+		}
+		diff.Version, tags = relation.Version, relation.Tags
+	default:
+		return fmt.Errorf("%w: unsupported element type: %s", ErrInvalidUpload, element.Type)
+	}
+
+	if err := u.checkExpectedVersion(element.Type, element.ID, diff.Version); err != nil {
+		return err
+	}
+	if err := u.overwrite.Check(element.Type, element.ID, tags, newTags["ele"]); err != nil {
+		diff.Skipped = "existing ele kept by the " + u.overwrite.Mode + " policy"
+		return err
+	}
+
+	diff.Changes = diffTags(tags, newTags)
+	return nil
+}
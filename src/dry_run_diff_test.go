@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffTags(t *testing.T) {
+	newTags := map[string]string{"ele": "1234", "ele:source": "SRTM"}
+	tests := []struct {
+		name    string
+		current []NodeTag
+		want    []TagChange
+	}{
+		{"both added", []NodeTag{{Key: "natural", Value: "peak"}}, []TagChange{{"ele", "", "1234"}, {"ele:source", "", "SRTM"}}},
+		{"source unchanged", []NodeTag{{Key: "ele:source", Value: "SRTM"}}, []TagChange{{"ele", "", "1234"}}},
+		{"nothing changes", []NodeTag{{Key: "ele", Value: "1234"}, {Key: "ele:source", Value: "SRTM"}}, []TagChange{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffTags(tt.current, newTags)
+			if len(got) != len(tt.want) {
+				t.Fatalf("diffTags() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("change %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDryRunUploadRecordsDiff(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("dry run sent a %s request", r.Method)
+		}
+		switch r.URL.Path {
+		case "/api/0.6/node/1":
+			w.Write([]byte(`<osm version="0.6"><node id="1" version="4" lat="45" lon="25"><tag k="natural" v="peak"/></node></osm>`))
+		case "/api/0.6/node/2":
+			w.Write([]byte(`<osm version="0.6"><node id="2" version="2" lat="45" lon="25"><tag k="ele" v="900"/></node></osm>`))
+		default:
+			http.Error(w, "gone", http.StatusGone)
+		}
+	}))
+	defer server.Close()
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	uploader, err := NewOSMUploader(nil, true, "România", "")
+	if err != nil {
+		t.Fatalf("NewOSMUploader() error = %v", err)
+	}
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Tags: map[string]string{"ele": "1234", "ele:source": "SRTM"}},
+		{Type: "node", ID: 2, Tags: map[string]string{"ele": "950", "ele:source": "SRTM"}},
+		{Type: "way", ID: 3, Tags: map[string]string{"ele": "100", "ele:source": "SRTM"}},
+	}
+	stats := uploader.UploadElements(context.Background(), elements, "peaks")
+	if stats.Successful != 1 || stats.AlreadyHasEle != 1 || stats.Failed != 1 {
+		t.Errorf("stats = %+v, want 1 successful, 1 already has ele, 1 failed", stats)
+	}
+
+	path := filepath.Join(t.TempDir(), "dry_run_diff.json")
+	if err := uploader.dryRunDiff.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	var report DryRunDiffReport
+	if err := loadJSON(path, &report); err != nil {
+		t.Fatalf("loadJSON() error = %v", err)
+	}
+	if len(report.Elements) != 3 {
+		t.Fatalf("report has %d elements, want 3", len(report.Elements))
+	}
+
+	first := report.Elements[0]
+	if first.Version != 4 || len(first.Changes) != 2 || first.Changes[0] != (TagChange{"ele", "", "1234"}) {
+		t.Errorf("node 1 diff = %+v", first)
+	}
+	if report.Elements[1].Skipped == "" || len(report.Elements[1].Changes) != 0 {
+		t.Errorf("node 2 diff = %+v, want skipped", report.Elements[1])
+	}
+	if report.Elements[2].Error == "" {
+		t.Errorf("way 3 diff = %+v, want error", report.Elements[2])
+	}
+}
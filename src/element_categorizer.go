@@ -4,43 +4,34 @@ package main
 type ElementCategory string
 
 const (
+	CategoryPeak               ElementCategory = "peak"
 	CategoryAlpineHut          ElementCategory = "alpine_hut"
+	CategoryShelter            ElementCategory = "shelter"
 	CategoryTrainStation       ElementCategory = "train_station"
 	CategoryOtherAccommodation ElementCategory = "other_accommodation"
 	CategoryUnknown            ElementCategory = "unknown"
 )
 
 // ElementCategorizer provides utilities for categorizing OSM elements
-type ElementCategorizer struct{}
+type ElementCategorizer struct {
+	profile *Profile
+}
 
-// NewElementCategorizer creates a new element categorizer
+// NewElementCategorizer creates a new element categorizer using the active profile
 func NewElementCategorizer() *ElementCategorizer {
-	return &ElementCategorizer{}
+	return &ElementCategorizer{profile: activeProfile()}
 }
 
-// Categorize determines the category of an OSM element
+// Categorize determines the category of an OSM element from the profile's tag selectors,
+// checking categories in priority order
 func (ec *ElementCategorizer) Categorize(element OSMElement) ElementCategory {
 	if element.Tags == nil {
 		return CategoryUnknown
 	}
 	
-	// Check for alpine hut
-	if element.Tags["tourism"] == "alpine_hut" {
-		return CategoryAlpineHut
-	}
-	
-	// Check for train station
-	railway := element.Tags["railway"]
-	if railway == "station" || railway == "halt" {
-		return CategoryTrainStation
-	}
-	
-	// Check for other accommodations
-	tourism := element.Tags["tourism"]
-	accommodationTypes := []string{"hotel", "guest_house", "chalet", "hostel", "motel"}
-	for _, accType := range accommodationTypes {
-		if tourism == accType {
-			return CategoryOtherAccommodation
+	for _, cat := range ec.profile.Categories {
+		if cat.Matches(element) {
+			return pipelineCategories[cat.Key]
 		}
 	}
 	
@@ -52,6 +43,16 @@ func (ec *ElementCategorizer) IsAlpineHut(element OSMElement) bool {
 	return ec.Categorize(element) == CategoryAlpineHut
 }
 
+// IsPeak checks if an element is a mountain peak
+func (ec *ElementCategorizer) IsPeak(element OSMElement) bool {
+	return ec.Categorize(element) == CategoryPeak
+}
+
+// IsShelter checks if an element is a shelter or wilderness hut
+func (ec *ElementCategorizer) IsShelter(element OSMElement) bool {
+	return ec.Categorize(element) == CategoryShelter
+}
+
 // IsTrainStation checks if an element is a train station
 func (ec *ElementCategorizer) IsTrainStation(element OSMElement) bool {
 	return ec.Categorize(element) == CategoryTrainStation
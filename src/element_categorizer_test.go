@@ -17,6 +17,27 @@ func TestElementCategorizerCategorize(t *testing.T) {
 			},
 			expected: CategoryAlpineHut,
 		},
+		{
+			name: "Peak",
+			element: OSMElement{
+				Tags: map[string]string{"natural": "peak", "name": "Moldoveanu"},
+			},
+			expected: CategoryPeak,
+		},
+		{
+			name: "Shelter",
+			element: OSMElement{
+				Tags: map[string]string{"amenity": "shelter"},
+			},
+			expected: CategoryShelter,
+		},
+		{
+			name: "Wilderness hut",
+			element: OSMElement{
+				Tags: map[string]string{"tourism": "wilderness_hut"},
+			},
+			expected: CategoryShelter,
+		},
 		{
 			name: "Train station",
 			element: OSMElement{
@@ -161,3 +182,47 @@ func TestElementCategorizerCategorizeMultiple(t *testing.T) {
 		t.Errorf("Expected 1 train station, got %d", len(result[CategoryTrainStation]))
 	}
 }
+
+func TestFilterDataIncludesShelters(t *testing.T) {
+	filter := NewElevationFilter()
+	data := &OSMData{
+		Shelters: []OSMElement{
+			{Type: "node", ID: 1, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"amenity": "shelter"}},
+			{Type: "way", ID: 2, Center: &OSMCenter{Lat: 45.5, Lon: 24.6}, Tags: map[string]string{"tourism": "wilderness_hut"}},
+			{Type: "node", ID: 3, Lat: 45.4, Lon: 24.5, Tags: map[string]string{"amenity": "shelter", "ele": "1800"}},
+		},
+	}
+
+	filtered := filter.FilterData(data)
+	if len(filtered.Shelters) != 2 {
+		t.Errorf("Expected 2 shelters without ele, got %d", len(filtered.Shelters))
+	}
+}
+
+func TestCategoryAccessorsCoverAllKeys(t *testing.T) {
+	var validated ValidatedData
+	var enriched EnrichedData
+	for _, key := range categoryKeys {
+		if validated.Category(key) == nil {
+			t.Errorf("ValidatedData.Category(%q) returned nil", key)
+		}
+		if enriched.Category(key) == nil {
+			t.Errorf("EnrichedData.Category(%q) returned nil", key)
+		}
+	}
+}
+
+func TestFilterDataIncludesPeaks(t *testing.T) {
+	filter := NewElevationFilter()
+	data := &OSMData{
+		Peaks: []OSMElement{
+			{Type: "node", ID: 1, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak"}},
+			{Type: "node", ID: 2, Lat: 45.5, Lon: 24.6, Tags: map[string]string{"natural": "peak", "ele": "2544"}},
+		},
+	}
+
+	filtered := filter.FilterData(data)
+	if len(filtered.Peaks) != 1 || filtered.Peaks[0].ID != 1 {
+		t.Errorf("Expected only the peak without ele, got %+v", filtered.Peaks)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ElementIDList is a set of OSM elements read from a file with one "node/123" (or bare ID, matching
+// any element type) per line; text after # is a comment
+type ElementIDList struct {
+	path  string
+	typed map[string]bool
+	bare  map[int64]bool
+}
+
+// LoadElementIDList reads an element ID list file
+func LoadElementIDList(path string) (*ElementIDList, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ID list: %v", err)
+	}
+	defer file.Close()
+
+	list := &ElementIDList{path: path, typed: make(map[string]bool), bare: make(map[int64]bool)}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if err := list.add(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ID list %s: %v", path, err)
+	}
+	return list, nil
+}
+
+// add parses one "type/id" or "id" entry
+func (l *ElementIDList) add(entry string) error {
+	elementType, idText, typed := strings.Cut(entry, "/")
+	if !typed {
+		idText = entry
+	}
+	id, err := strconv.ParseInt(strings.TrimSpace(idText), 10, 64)
+	if err != nil || id <= 0 {
+		return fmt.Errorf("invalid element ID %q (expected node/123, way/123, relation/123 or 123)", entry)
+	}
+	if !typed {
+		l.bare[id] = true
+		return nil
+	}
+	switch elementType = strings.ToLower(strings.TrimSpace(elementType)); elementType {
+	case "node", "way", "relation":
+		l.typed[elementKey(elementType, id)] = true
+		return nil
+	}
+	return fmt.Errorf("invalid element type %q in %q (expected node, way or relation)", elementType, entry)
+}
+
+// Len returns the number of entries in the list
+func (l *ElementIDList) Len() int {
+	return len(l.typed) + len(l.bare)
+}
+
+// Contains reports whether the list names the element
+func (l *ElementIDList) Contains(element OSMElement) bool {
+	return l.bare[element.ID] || l.typed[elementKey(element.Type, element.ID)]
+}
+
+// ElementIDFilter keeps only the elements of an allow list (when given) that are not in a deny list
+type ElementIDFilter struct {
+	Only    *ElementIDList
+	Exclude *ElementIDList
+}
+
+// loadElementIDFilter reads the ONLY_IDS_FILE and EXCLUDE_IDS_FILE lists; unset files select every element
+func loadElementIDFilter(config *Config) (ElementIDFilter, error) {
+	var filter ElementIDFilter
+	var err error
+	if path := config.Get("ONLY_IDS_FILE"); path != "" {
+		if filter.Only, err = LoadElementIDList(path); err != nil {
+			return filter, err
+		}
+	}
+	if path := config.Get("EXCLUDE_IDS_FILE"); path != "" {
+		if filter.Exclude, err = LoadElementIDList(path); err != nil {
+			return filter, err
+		}
+	}
+	return filter, nil
+}
+
+// Active reports whether the filter restricts any element
+func (f ElementIDFilter) Active() bool {
+	return f.Only != nil || f.Exclude != nil
+}
+
+// Allows reports whether an element passes the filter
+func (f ElementIDFilter) Allows(element OSMElement) bool {
+	if f.Only != nil && !f.Only.Contains(element) {
+		return false
+	}
+	return f.Exclude == nil || !f.Exclude.Contains(element)
+}
+
+// Apply removes the elements the filter does not allow from validated data
+func (f ElementIDFilter) Apply(data ValidatedData) (ValidatedData, int) {
+	if !f.Active() {
+		return data, 0
+	}
+	var kept ValidatedData
+	skipped := 0
+	for _, key := range categoryKeys {
+		var elements []OSMElement
+		for _, element := range data.Category(key).ValidElements {
+			if !f.Allows(element) {
+				skipped++
+				continue
+			}
+			elements = append(elements, element)
+		}
+		*kept.Category(key) = ValidatedCategory{ValidCount: len(elements), ValidElements: elements}
+	}
+	return kept, skipped
+}
+
+// describe summarizes the lists of an active filter for the log
+func (f ElementIDFilter) describe() string {
+	var parts []string
+	if f.Only != nil {
+		parts = append(parts, fmt.Sprintf("only the %d elements of %s", f.Only.Len(), f.Only.path))
+	}
+	if f.Exclude != nil {
+		parts = append(parts, fmt.Sprintf("excluding the %d elements of %s", f.Exclude.Len(), f.Exclude.path))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// registerElementIDFlags adds --only-ids and --exclude-ids and returns a function that applies them when given
+func registerElementIDFlags(fs *flag.FlagSet) func() {
+	only := fs.String("only-ids", "", "File of element IDs (node/123, one per line) to restrict filter and upload to (default: ONLY_IDS_FILE)")
+	exclude := fs.String("exclude-ids", "", "File of element IDs (node/123, one per line) that filter and upload skip (default: EXCLUDE_IDS_FILE)")
+	return func() {
+		if flagWasSet(fs, "only-ids") {
+			flagConfig.Set("ONLY_IDS_FILE", *only)
+		}
+		if flagWasSet(fs, "exclude-ids") {
+			flagConfig.Set("EXCLUDE_IDS_FILE", *exclude)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeIDList(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ids.txt")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadElementIDList(t *testing.T) {
+	list, err := LoadElementIDList(writeIDList(t, "# contested\nnode/1\nWay/2  # disputed name\n\n3\n"))
+	if err != nil {
+		t.Fatalf("LoadElementIDList() error = %v", err)
+	}
+	tests := []struct {
+		element OSMElement
+		want    bool
+	}{
+		{OSMElement{Type: "node", ID: 1}, true},
+		{OSMElement{Type: "way", ID: 1}, false},
+		{OSMElement{Type: "way", ID: 2}, true},
+		{OSMElement{Type: "relation", ID: 3}, true},
+		{OSMElement{Type: "node", ID: 4}, false},
+	}
+	for _, tt := range tests {
+		if got := list.Contains(tt.element); got != tt.want {
+			t.Errorf("Contains(%s/%d) = %v, want %v", tt.element.Type, tt.element.ID, got, tt.want)
+		}
+	}
+	if list.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", list.Len())
+	}
+
+	for _, content := range []string{"node/abc\n", "area/5\n", "-7\n"} {
+		if _, err := LoadElementIDList(writeIDList(t, content)); err == nil {
+			t.Errorf("LoadElementIDList(%q) succeeded, want an error", content)
+		}
+	}
+}
+
+func TestElementIDFilter(t *testing.T) {
+	only, err := LoadElementIDList(writeIDList(t, "node/1\nnode/2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	exclude, err := LoadElementIDList(writeIDList(t, "node/2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := ValidatedData{
+		Peaks:      ValidatedCategory{ValidCount: 2, ValidElements: []OSMElement{{Type: "node", ID: 1}, {Type: "node", ID: 2}}},
+		AlpineHuts: ValidatedCategory{ValidCount: 1, ValidElements: []OSMElement{{Type: "node", ID: 3}}},
+	}
+
+	tests := []struct {
+		name        string
+		filter      ElementIDFilter
+		wantPeaks   int
+		wantHuts    int
+		wantSkipped int
+	}{
+		{"None", ElementIDFilter{}, 2, 1, 0},
+		{"Only", ElementIDFilter{Only: only}, 2, 0, 1},
+		{"Exclude", ElementIDFilter{Exclude: exclude}, 1, 1, 1},
+		{"Both", ElementIDFilter{Only: only, Exclude: exclude}, 1, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept, skipped := tt.filter.Apply(data)
+			if kept.Peaks.ValidCount != tt.wantPeaks || kept.AlpineHuts.ValidCount != tt.wantHuts || skipped != tt.wantSkipped {
+				t.Errorf("Apply() kept %d peaks and %d huts, skipped %d; want %d, %d, %d",
+					kept.Peaks.ValidCount, kept.AlpineHuts.ValidCount, skipped, tt.wantPeaks, tt.wantHuts, tt.wantSkipped)
+			}
+		})
+	}
+}
+
+func TestFilterDataSkipsElementIDs(t *testing.T) {
+	exclude, err := LoadElementIDList(writeIDList(t, "node/2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	filter := NewElevationFilter()
+	filter.ids = ElementIDFilter{Exclude: exclude}
+
+	peak := func(id int64) OSMElement {
+		return OSMElement{Type: "node", ID: id, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak"}}
+	}
+	filtered := filter.FilterData(&OSMData{Peaks: []OSMElement{peak(1), peak(2)}})
+	if len(filtered.Peaks) != 1 || filtered.Peaks[0].ID != 1 || filter.SkippedIDs != 1 {
+		t.Errorf("FilterData() kept %+v and skipped %d, want only node 1", filtered.Peaks, filter.SkippedIDs)
+	}
+}
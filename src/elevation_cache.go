@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultElevationCacheFile is shared by all countries of a run, so overlapping areas reuse lookups
+const DefaultElevationCacheFile = "output/elevation_cache.db"
+
+// elevationCacheResolution is the number of cache cells per degree; 3600 matches the
+// 1 arc-second (~30 m) grid of SRTM, so points within one DEM cell share an entry
+const elevationCacheResolution = 3600
+
+// elevationCacheBucket holds one nested bucket of elevations per provider source (see
+// ElevationProviderChain.Source), keyed by grid cell
+var elevationCacheBucket = []byte("elevations")
+
+// cachedElevation is a stored lookup result
+type cachedElevation struct {
+	Elevation float64 `json:"ele"`
+	Provider  string  `json:"provider"`
+	FetchedAt string  `json:"fetched_at"`
+}
+
+// ElevationCache persists elevation lookups on disk, keyed by coordinates rounded to the DEM grid.
+// Lookups are kept apart per provider source, so another DEM or datum never reuses them.
+type ElevationCache struct {
+	db *bolt.DB
+}
+
+// OpenElevationCache opens or creates the cache database
+func OpenElevationCache(path string) (*ElevationCache, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open elevation cache %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(elevationCacheBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize elevation cache %s: %v", path, err)
+	}
+	return &ElevationCache{db: db}, nil
+}
+
+// Close closes the cache database
+func (c *ElevationCache) Close() error {
+	return c.db.Close()
+}
+
+// elevationCacheKey returns the grid cell of a location
+func elevationCacheKey(lat, lon float64) []byte {
+	return []byte(fmt.Sprintf("%d,%d",
+		int64(math.Round(lat*elevationCacheResolution)),
+		int64(math.Round(lon*elevationCacheResolution))))
+}
+
+// Lookup returns the elevation of each location cached for source, or nil for the ones not cached
+func (c *ElevationCache) Lookup(source string, locations []LocationRequest) ([]*cachedElevation, error) {
+	found := make([]*cachedElevation, len(locations))
+	err := c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(elevationCacheBucket).Bucket([]byte(source))
+		if bucket == nil {
+			return nil
+		}
+		for i, loc := range locations {
+			value := bucket.Get(elevationCacheKey(loc.Lat, loc.Lon))
+			if value == nil {
+				continue
+			}
+			var entry cachedElevation
+			if err := json.Unmarshal(value, &entry); err != nil {
+				// A corrupt entry is fetched again and overwritten
+				continue
+			}
+			found[i] = &entry
+		}
+		return nil
+	})
+	return found, err
+}
+
+// Store saves the successful results of a lookup from source in a single transaction
+func (c *ElevationCache) Store(source string, locations []LocationRequest, results []BatchElevationResult) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	return c.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(elevationCacheBucket).CreateBucketIfNotExists([]byte(source))
+		if err != nil {
+			return err
+		}
+		for i, result := range results {
+			if i >= len(locations) || result.Error != nil || result.Elevation == nil {
+				continue
+			}
+			value, err := json.Marshal(cachedElevation{Elevation: *result.Elevation, Provider: result.Provider, FetchedAt: now})
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(elevationCacheKey(locations[i].Lat, locations[i].Lon), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Len returns the number of cached cells of all sources
+func (c *ElevationCache) Len() int {
+	count := 0
+	c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(elevationCacheBucket)
+		return bucket.ForEach(func(name, value []byte) error {
+			if value == nil {
+				count += bucket.Bucket(name).Stats().KeyN
+			}
+			return nil
+		})
+	})
+	return count
+}
+
+// CachedElevationProvider answers lookups from the cache and only asks the wrapped
+// provider for the locations it has not seen before
+type CachedElevationProvider struct {
+	cache  *ElevationCache
+	source string
+	next   BatchElevationProvider
+	hits   int
+}
+
+// NewCachedElevationProvider wraps a provider with the cache; source names the datasets behind
+// next, so the cache only answers with elevations they produced
+func NewCachedElevationProvider(cache *ElevationCache, source string, next BatchElevationProvider) *CachedElevationProvider {
+	return &CachedElevationProvider{cache: cache, source: source, next: next}
+}
+
+// Hits returns the number of locations answered from the cache
+func (p *CachedElevationProvider) Hits() int {
+	return p.hits
+}
+
+// BatchGetElevations implements BatchElevationProvider
+func (p *CachedElevationProvider) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	cached, err := p.cache.Lookup(p.source, locations)
+	if err != nil {
+		enrichLog.Warn("Elevation cache lookup failed: %v", err)
+		cached = make([]*cachedElevation, len(locations))
+	}
+
+	results := make([]BatchElevationResult, len(locations))
+	var missing []LocationRequest
+	var missingIndex []int
+	for i, loc := range locations {
+		if entry := cached[i]; entry != nil {
+			elevation := entry.Elevation
+			results[i] = BatchElevationResult{Elevation: &elevation, Element: loc.Element, Provider: entry.Provider}
+			p.hits++
+			continue
+		}
+		missing = append(missing, loc)
+		missingIndex = append(missingIndex, i)
+	}
+
+	if len(missing) == 0 {
+		return results, nil
+	}
+
+	fetched, err := p.next.BatchGetElevations(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.cache.Store(p.source, missing, fetched); err != nil {
+		enrichLog.Warn("Failed to save elevations to cache: %v", err)
+	}
+
+	for j, index := range missingIndex {
+		if j < len(fetched) {
+			results[index] = fetched[j]
+		} else {
+			results[index] = BatchElevationResult{Element: locations[index].Element, Error: fmt.Errorf("no result returned")}
+		}
+	}
+	return results, nil
+}
+
+var (
+	sharedElevationCacheOnce     sync.Once
+	sharedElevationCacheInstance *ElevationCache
+)
+
+// sharedElevationCache returns the process-wide cache configured by ELEVATION_CACHE_FILE, or
+// nil when it is disabled or cannot be opened. The database allows one open handle, so
+// countries processed in parallel share it.
+func sharedElevationCache() *ElevationCache {
+	sharedElevationCacheOnce.Do(func() {
+		config := NewConfig()
+		config.LoadFromEnv()
+		path := config.Get("ELEVATION_CACHE_FILE")
+		if path == "none" {
+			return
+		}
+		cache, err := OpenElevationCache(path)
+		if err != nil {
+			enrichLog.Warn("Elevation cache disabled: %v", err)
+			return
+		}
+		sharedElevationCacheInstance = cache
+	})
+	return sharedElevationCacheInstance
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// countingProvider returns lat+lon as elevation and records how many locations it was asked for
+type countingProvider struct {
+	calls int
+}
+
+func (p *countingProvider) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	results := make([]BatchElevationResult, len(locations))
+	for i, loc := range locations {
+		p.calls++
+		elevation := loc.Lat + loc.Lon
+		results[i] = BatchElevationResult{Elevation: &elevation, Element: loc.Element, Provider: "test"}
+	}
+	return results, nil
+}
+
+func TestElevationCacheKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		lat1      float64
+		lon1      float64
+		lat2      float64
+		lon2      float64
+		sameEntry bool
+	}{
+		{"identical", 45.5, 25.1, 45.5, 25.1, true},
+		{"within one arc-second cell", 45.50001, 25.10001, 45.50003, 25.10002, true},
+		{"neighbouring cells", 45.5, 25.1, 45.5 + 1.0/3600, 25.1, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			same := string(elevationCacheKey(tt.lat1, tt.lon1)) == string(elevationCacheKey(tt.lat2, tt.lon2))
+			if same != tt.sameEntry {
+				t.Errorf("same cache entry = %v, want %v", same, tt.sameEntry)
+			}
+		})
+	}
+}
+
+func TestCachedElevationProviderPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "elevation_cache.db")
+	locations := []LocationRequest{
+		{Lat: 45.5, Lon: 25.1, Element: &OSMElement{ID: 1}},
+		{Lat: 46.0, Lon: 24.0, Element: &OSMElement{ID: 2}},
+	}
+
+	cache, err := OpenElevationCache(path)
+	if err != nil {
+		t.Fatalf("OpenElevationCache() error = %v", err)
+	}
+	next := &countingProvider{}
+	provider := NewCachedElevationProvider(cache, "opentopo(srtm30m)", next)
+	if _, err := provider.BatchGetElevations(context.Background(), locations[:1]); err != nil {
+		t.Fatalf("BatchGetElevations() error = %v", err)
+	}
+	cache.Close()
+
+	// A later run only fetches the location it has not seen
+	cache, err = OpenElevationCache(path)
+	if err != nil {
+		t.Fatalf("OpenElevationCache() error = %v", err)
+	}
+	defer cache.Close()
+	if cache.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", cache.Len())
+	}
+
+	next = &countingProvider{}
+	provider = NewCachedElevationProvider(cache, "opentopo(srtm30m)", next)
+	results, err := provider.BatchGetElevations(context.Background(), locations)
+	if err != nil {
+		t.Fatalf("BatchGetElevations() error = %v", err)
+	}
+	if next.calls != 1 || provider.Hits() != 1 {
+		t.Errorf("provider calls = %d, cache hits = %d; want 1 and 1", next.calls, provider.Hits())
+	}
+	for i, result := range results {
+		want := locations[i].Lat + locations[i].Lon
+		if result.Elevation == nil || *result.Elevation != want {
+			t.Errorf("result %d elevation = %v, want %v", i, result.Elevation, want)
+		}
+		if result.Element != locations[i].Element || result.Provider != "test" {
+			t.Errorf("result %d = %+v, want element %d from provider test", i, result, locations[i].Element.ID)
+		}
+	}
+}
+
+func TestCachedElevationProviderMissesAfterProviderSwitch(t *testing.T) {
+	cache, err := OpenElevationCache(filepath.Join(t.TempDir(), "elevation_cache.db"))
+	if err != nil {
+		t.Fatalf("OpenElevationCache() error = %v", err)
+	}
+	defer cache.Close()
+
+	config := NewConfig()
+	config.Set("ELEVATION_PROVIDERS", "opentopo")
+	config.Set("GEOID_GRID_FILE", writeGeoidGrid(t, make([]uint16, 12)))
+	factory := NewAPIClientFactory(config, NewLogger("test"))
+	source := func() string {
+		chain, err := factory.CreateElevationProviderChain()
+		if err != nil {
+			t.Fatalf("CreateElevationProviderChain() error = %v", err)
+		}
+		return chain.Source()
+	}
+
+	locations := []LocationRequest{{Lat: 45.5, Lon: 25.1, Element: &OSMElement{ID: 1}}}
+	if _, err := NewCachedElevationProvider(cache, source(), &countingProvider{}).BatchGetElevations(context.Background(), locations); err != nil {
+		t.Fatalf("BatchGetElevations() error = %v", err)
+	}
+
+	switches := []struct {
+		name  string
+		key   string
+		value string
+	}{
+		{"provider", "ELEVATION_PROVIDERS", "open-elevation"},
+		{"dataset", "OPENTOPO_URL", "https://api.opentopodata.org/v1/aster30m"},
+		{"geoid correction", "GEOID_CORRECTED_PROVIDERS", "opentopo"},
+	}
+	for _, sw := range switches {
+		t.Run(sw.name, func(t *testing.T) {
+			previous := config.Get(sw.key)
+			config.Set(sw.key, sw.value)
+			defer config.Set(sw.key, previous)
+
+			next := &countingProvider{}
+			provider := NewCachedElevationProvider(cache, source(), next)
+			if _, err := provider.BatchGetElevations(context.Background(), locations); err != nil {
+				t.Fatalf("BatchGetElevations() error = %v", err)
+			}
+			if provider.Hits() != 0 || next.calls != 1 {
+				t.Errorf("cache hits = %d, provider calls = %d; want a miss after switching %s", provider.Hits(), next.calls, sw.key)
+			}
+		})
+	}
+
+	next := &countingProvider{}
+	provider := NewCachedElevationProvider(cache, source(), next)
+	if _, err := provider.BatchGetElevations(context.Background(), locations); err != nil {
+		t.Fatalf("BatchGetElevations() error = %v", err)
+	}
+	if provider.Hits() != 1 || next.calls != 0 {
+		t.Errorf("cache hits = %d, provider calls = %d; want a hit for the original source", provider.Hits(), next.calls)
+	}
+}
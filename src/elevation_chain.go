@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Elevation provider names accepted in ELEVATION_PROVIDERS
+const (
+	ProviderOpenTopo      = "opentopo"
+	ProviderOpenElevation = "open-elevation"
+	ProviderHGT           = "hgt"
+)
+
+// namedProvider is a batch provider together with the name recorded on elements
+type namedProvider struct {
+	name     string
+	provider BatchElevationProvider
+}
+
+// ElevationProviderChain tries an ordered list of providers, falling back to the next one
+// for every location the previous provider failed on or returned no data for
+type ElevationProviderChain struct {
+	providers []namedProvider
+	closers   []func()
+	// source describes the datasets and datum corrections behind the providers
+	source string
+}
+
+// Add appends a provider to the end of the chain
+func (c *ElevationProviderChain) Add(name string, provider BatchElevationProvider) {
+	c.providers = append(c.providers, namedProvider{name: name, provider: provider})
+}
+
+// Names returns the provider names in fallback order
+func (c *ElevationProviderChain) Names() []string {
+	names := make([]string, len(c.providers))
+	for i, p := range c.providers {
+		names[i] = p.name
+	}
+	return names
+}
+
+// Source identifies what the chain's elevations come from: the providers in order with their
+// dataset and geoid correction. Cached elevations are only reused for the same source.
+func (c *ElevationProviderChain) Source() string {
+	if c.source == "" {
+		return strings.Join(c.Names(), ",")
+	}
+	return c.source
+}
+
+// BatchGetElevations resolves every location with the first provider that has data for it
+func (c *ElevationProviderChain) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	results := make([]BatchElevationResult, len(locations))
+	pending := make([]int, len(locations))
+	for i, loc := range locations {
+		pending[i] = i
+		results[i] = BatchElevationResult{
+			Element: loc.Element,
+			Error:   fmt.Errorf("no elevation provider configured"),
+		}
+	}
+
+	for _, p := range c.providers {
+		if len(pending) == 0 {
+			break
+		}
+
+		batch := make([]LocationRequest, len(pending))
+		for i, index := range pending {
+			batch[i] = locations[index]
+		}
+
+		providerResults, err := p.provider.BatchGetElevations(ctx, batch)
+		if ctx.Err() != nil {
+			// Do not fall back to the next provider after an interruption
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			enrichLog.Warn("%s failed for %d locations, trying next provider: %v", p.name, len(batch), err)
+			for _, index := range pending {
+				results[index].Error = fmt.Errorf("%s: %v", p.name, err)
+			}
+			continue
+		}
+
+		var stillPending []int
+		for i, index := range pending {
+			if i >= len(providerResults) {
+				results[index].Error = fmt.Errorf("%s: no result returned", p.name)
+				stillPending = append(stillPending, index)
+				continue
+			}
+
+			result := providerResults[i]
+			if result.Error != nil || result.Elevation == nil {
+				if result.Error != nil {
+					results[index].Error = fmt.Errorf("%s: %w", p.name, result.Error)
+				} else {
+					results[index].Error = fmt.Errorf("%s: no elevation data", p.name)
+				}
+				stillPending = append(stillPending, index)
+				continue
+			}
+
+			results[index] = BatchElevationResult{
+				Elevation: result.Elevation,
+				Element:   locations[index].Element,
+				Provider:  p.name,
+			}
+		}
+		pending = stillPending
+	}
+
+	return results, nil
+}
+
+// Close releases resources held by providers (e.g. open tile files)
+func (c *ElevationProviderChain) Close() {
+	for _, closer := range c.closers {
+		closer()
+	}
+}
+
+// parseProviderNames splits a comma-separated provider list and validates each name
+func parseProviderNames(value string) ([]string, error) {
+	names := splitList(strings.ToLower(value))
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no elevation providers configured")
+	}
+	for _, name := range names {
+		switch name {
+		case ProviderOpenTopo, ProviderOpenElevation, ProviderHGT:
+		default:
+			return nil, fmt.Errorf("unknown elevation provider %q (expected %s, %s or %s)",
+				name, ProviderOpenTopo, ProviderOpenElevation, ProviderHGT)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// fakeBatchProvider returns fixed elevations keyed by element ID
+type fakeBatchProvider struct {
+	elevations map[int64]float64
+	err        error
+	calls      int
+}
+
+func (f *fakeBatchProvider) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	results := make([]BatchElevationResult, len(locations))
+	for i, loc := range locations {
+		results[i].Element = loc.Element
+		if elevation, ok := f.elevations[loc.Element.ID]; ok {
+			results[i].Elevation = &elevation
+		} else {
+			results[i].Error = errors.New("no data")
+		}
+	}
+	return results, nil
+}
+
+func TestElevationProviderChainFallback(t *testing.T) {
+	elements := []OSMElement{{ID: 1}, {ID: 2}, {ID: 3}}
+	locations := make([]LocationRequest, len(elements))
+	for i := range elements {
+		locations[i] = LocationRequest{Element: &elements[i]}
+	}
+
+	failing := &fakeBatchProvider{err: errors.New("status 503")}
+	partial := &fakeBatchProvider{elevations: map[int64]float64{1: 100}}
+	local := &fakeBatchProvider{elevations: map[int64]float64{1: 999, 2: 200}}
+
+	chain := &ElevationProviderChain{}
+	chain.Add("opentopo", failing)
+	chain.Add("open-elevation", partial)
+	chain.Add("hgt", local)
+
+	results, err := chain.BatchGetElevations(context.Background(), locations)
+	if err != nil {
+		t.Fatalf("BatchGetElevations() error = %v", err)
+	}
+
+	if results[0].Provider != "open-elevation" || *results[0].Elevation != 100 {
+		t.Errorf("Element 1: got %+v, want 100 from open-elevation", results[0])
+	}
+	if results[1].Provider != "hgt" || *results[1].Elevation != 200 {
+		t.Errorf("Element 2: got %+v, want 200 from hgt", results[1])
+	}
+	if results[2].Elevation != nil || results[2].Error == nil {
+		t.Errorf("Element 3: expected an error when no provider has data, got %+v", results[2])
+	}
+	if results[2].Element.ID != 3 {
+		t.Errorf("Element 3: result not matched to its element")
+	}
+}
+
+func TestParseProviderNames(t *testing.T) {
+	names, err := parseProviderNames("opentopo, Open-Elevation,hgt")
+	if err != nil {
+		t.Fatalf("parseProviderNames() error = %v", err)
+	}
+	if len(names) != 3 || names[1] != ProviderOpenElevation {
+		t.Errorf("Unexpected names: %v", names)
+	}
+
+	if _, err := parseProviderNames("opentopo,google"); err == nil {
+		t.Error("Expected error for unknown provider")
+	}
+}
+
+func TestCreateElevationProviderChainDefaults(t *testing.T) {
+	config := NewConfig()
+	factory := NewAPIClientFactory(config, NewLogger("test"))
+
+	chain, err := factory.CreateElevationProviderChain()
+	if err != nil {
+		t.Fatalf("CreateElevationProviderChain() error = %v", err)
+	}
+	if names := chain.Names(); len(names) != 1 || names[0] != ProviderOpenTopo {
+		t.Errorf("Default chain = %v, want [opentopo]", names)
+	}
+
+	config.Set("ELEVATION_PROVIDERS", "opentopo,hgt")
+	if _, err := factory.CreateElevationProviderChain(); err == nil {
+		t.Error("Expected error when hgt is used without ELEVATION_TILE_DIR")
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// DefaultConsensusReviewFile lists the locations where the elevation datasets disagree
+const DefaultConsensusReviewFile = "output/consensus_review.csv"
+
+// ConsensusDisagreement is a location whose primary elevation was rejected by the consensus check
+type ConsensusDisagreement struct {
+	ElementType     string
+	ElementID       int64
+	Name            string
+	Lat             float64
+	Lon             float64
+	Primary         float64
+	PrimaryProvider string
+	Secondary       *float64 // nil when the second dataset has no value (e.g. an SRTM void)
+}
+
+// ConsensusElevationProvider accepts an elevation only when a second dataset agrees with it
+// within Tolerance metres. Rejected locations fail and are collected for review.
+type ConsensusElevationProvider struct {
+	Primary       BatchElevationProvider
+	Secondary     BatchElevationProvider
+	SecondaryName string
+	Tolerance     float64
+
+	mu            sync.Mutex
+	disagreements []ConsensusDisagreement
+}
+
+// NewConsensusElevationProvider checks the primary provider against a second dataset
+func NewConsensusElevationProvider(primary, secondary BatchElevationProvider, secondaryName string, tolerance float64) *ConsensusElevationProvider {
+	return &ConsensusElevationProvider{
+		Primary:       primary,
+		Secondary:     secondary,
+		SecondaryName: secondaryName,
+		Tolerance:     tolerance,
+	}
+}
+
+// BatchGetElevations resolves locations with the primary provider and confirms each value with the secondary one
+func (p *ConsensusElevationProvider) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	results, err := p.Primary.BatchGetElevations(ctx, locations)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []int
+	for i := range locations {
+		if i < len(results) && results[i].Error == nil && results[i].Elevation != nil {
+			found = append(found, i)
+		}
+	}
+	if len(found) == 0 {
+		return results, nil
+	}
+
+	batch := make([]LocationRequest, len(found))
+	for i, index := range found {
+		batch[i] = locations[index]
+	}
+	confirmations, err := p.Secondary.BatchGetElevations(ctx, batch)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		// Without the second dataset nothing can be confirmed; the next run retries these locations
+		for _, index := range found {
+			results[index].Elevation = nil
+			results[index].Error = fmt.Errorf("consensus check with %s failed: %v", p.SecondaryName, err)
+		}
+		return results, nil
+	}
+
+	for i, index := range found {
+		var secondary *float64
+		if i < len(confirmations) && confirmations[i].Error == nil {
+			secondary = confirmations[i].Elevation
+		}
+		primary := *results[index].Elevation
+		if secondary != nil && math.Abs(primary-*secondary) <= p.Tolerance {
+			continue
+		}
+
+		p.record(locations[index], results[index], secondary)
+		if secondary == nil {
+			results[index].Error = fmt.Errorf("%s has no elevation to confirm %.1f m", p.SecondaryName, primary)
+		} else {
+			results[index].Error = fmt.Errorf("%s disagrees by %.1f m", p.SecondaryName, math.Abs(primary-*secondary))
+		}
+		results[index].Elevation = nil
+	}
+	return results, nil
+}
+
+// record adds a rejected location to the review list
+func (p *ConsensusElevationProvider) record(location LocationRequest, result BatchElevationResult, secondary *float64) {
+	disagreement := ConsensusDisagreement{
+		Lat:             location.Lat,
+		Lon:             location.Lon,
+		Primary:         *result.Elevation,
+		PrimaryProvider: result.Provider,
+		Secondary:       secondary,
+	}
+	if location.Element != nil {
+		disagreement.ElementType = location.Element.Type
+		disagreement.ElementID = location.Element.ID
+		disagreement.Name = location.Element.Tags["name"]
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.disagreements = append(p.disagreements, disagreement)
+}
+
+// Disagreements returns the locations rejected so far
+func (p *ConsensusElevationProvider) Disagreements() []ConsensusDisagreement {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]ConsensusDisagreement(nil), p.disagreements...)
+}
+
+// WriteConsensusReview writes the rejected locations as CSV for manual review
+func WriteConsensusReview(filename string, disagreements []ConsensusDisagreement) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("failed to create consensus review: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	header := []string{"type", "id", "name", "lat", "lon", "primary_ele", "primary_provider", "secondary_ele", "difference", "osm_link"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write consensus review: %v", err)
+	}
+	for _, d := range disagreements {
+		secondary, difference := "", ""
+		if d.Secondary != nil {
+			secondary = fmt.Sprintf("%.1f", *d.Secondary)
+			difference = fmt.Sprintf("%.1f", math.Abs(d.Primary-*d.Secondary))
+		}
+		record := []string{
+			d.ElementType, fmt.Sprintf("%d", d.ElementID), d.Name,
+			fmt.Sprintf("%.6f", d.Lat), fmt.Sprintf("%.6f", d.Lon),
+			fmt.Sprintf("%.1f", d.Primary), d.PrimaryProvider, secondary, difference,
+			fmt.Sprintf("https://www.openstreetmap.org/%s/%d", d.ElementType, d.ElementID),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write consensus review: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write consensus review: %v", err)
+	}
+	return nil
+}
+
+// consensusDatasetName names the second dataset after the last segment of its URL (e.g. aster30m)
+func consensusDatasetName(url string) string {
+	name := path.Base(strings.TrimRight(url, "/"))
+	if name == "." || name == "/" || name == "" {
+		return url
+	}
+	return name
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConsensusElevationProvider(t *testing.T) {
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Tags: map[string]string{"name": "Agree"}},
+		{Type: "node", ID: 2, Tags: map[string]string{"name": "Disagree"}},
+		{Type: "node", ID: 3, Tags: map[string]string{"name": "Void"}},
+		{Type: "node", ID: 4},
+	}
+	locations := make([]LocationRequest, len(elements))
+	for i := range elements {
+		locations[i] = LocationRequest{Lat: 45, Lon: 25, Element: &elements[i]}
+	}
+
+	primary := &fakeBatchProvider{elevations: map[int64]float64{1: 1000, 2: 1500, 3: 800}}
+	secondary := &fakeBatchProvider{elevations: map[int64]float64{1: 1012, 2: 1440}}
+	consensus := NewConsensusElevationProvider(primary, secondary, "aster30m", 20)
+
+	results, err := consensus.BatchGetElevations(context.Background(), locations)
+	if err != nil {
+		t.Fatalf("BatchGetElevations() error = %v", err)
+	}
+	if results[0].Elevation == nil || *results[0].Elevation != 1000 {
+		t.Errorf("Element 1: got %+v, want the primary 1000 m", results[0])
+	}
+	for i := 1; i < 4; i++ {
+		if results[i].Elevation != nil || results[i].Error == nil {
+			t.Errorf("Element %d: got %+v, want a rejection", i+1, results[i])
+		}
+	}
+
+	disagreements := consensus.Disagreements()
+	if len(disagreements) != 2 || disagreements[0].ElementID != 2 || disagreements[1].Secondary != nil {
+		t.Fatalf("Disagreements() = %+v, want elements 2 and 3 (void)", disagreements)
+	}
+
+	path := filepath.Join(t.TempDir(), "review.csv")
+	if err := WriteConsensusReview(path, disagreements); err != nil {
+		t.Fatalf("WriteConsensusReview() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "node,2,Disagree,45.000000,25.000000,1500.0,,1440.0,60.0,") {
+		t.Errorf("review file missing the disagreement:\n%s", data)
+	}
+
+	// Nothing is accepted when the second dataset is unreachable
+	consensus = NewConsensusElevationProvider(primary, &fakeBatchProvider{err: errors.New("status 503")}, "aster30m", 20)
+	results, err = consensus.BatchGetElevations(context.Background(), locations)
+	if err != nil {
+		t.Fatalf("BatchGetElevations() error = %v", err)
+	}
+	if results[0].Elevation != nil || len(consensus.Disagreements()) != 0 {
+		t.Errorf("Expected no accepted values and no review entries, got %+v", results[0])
+	}
+}
+
+func TestConsensusDatasetName(t *testing.T) {
+	tests := map[string]string{
+		"https://api.opentopodata.org/v1/aster30m":  "aster30m",
+		"https://api.opentopodata.org/v1/eudem25m/": "eudem25m",
+	}
+	for url, want := range tests {
+		if got := consensusDatasetName(url); got != want {
+			t.Errorf("consensusDatasetName(%q) = %q, want %q", url, got, want)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Rounding policies for written elevations
+const (
+	// RoundNearest rounds half away from zero
+	RoundNearest = "nearest"
+	// RoundDown always rounds towards lower elevations
+	RoundDown = "down"
+	// RoundUp always rounds towards higher elevations
+	RoundUp = "up"
+)
+
+// maxElePrecision is the most decimals written; DEMs are not accurate below a meter anyway
+const maxElePrecision = 3
+
+// ElevationFormat is the precision and rounding policy of ele values written by the pipeline
+type ElevationFormat struct {
+	Precision int // decimals, 0 for whole meters
+	Rounding  string
+}
+
+// defaultElevationFormat keeps the historical one-decimal output
+var defaultElevationFormat = ElevationFormat{Precision: 1, Rounding: RoundNearest}
+
+// resolveElevationFormat reads ELE_PRECISION and ELE_ROUNDING
+func resolveElevationFormat(config *Config) (ElevationFormat, error) {
+	format := defaultElevationFormat
+	if value := config.Get("ELE_PRECISION"); value != "" {
+		precision, err := strconv.Atoi(value)
+		if err != nil || precision < 0 || precision > maxElePrecision {
+			return format, fmt.Errorf("invalid ELE_PRECISION %q (expected 0-%d decimals)", value, maxElePrecision)
+		}
+		format.Precision = precision
+	}
+	if value := config.Get("ELE_ROUNDING"); value != "" {
+		switch value {
+		case RoundNearest, RoundDown, RoundUp:
+			format.Rounding = value
+		default:
+			return format, fmt.Errorf("invalid ELE_ROUNDING %q (expected %s, %s or %s)", value, RoundNearest, RoundDown, RoundUp)
+		}
+	}
+	return format, nil
+}
+
+// elevationFormatOrDefault is resolveElevationFormat for components built after the
+// configuration was checked
+func elevationFormatOrDefault(config *Config) ElevationFormat {
+	format, err := resolveElevationFormat(config)
+	if err != nil {
+		return defaultElevationFormat
+	}
+	return format
+}
+
+// Round applies the precision and rounding policy to an elevation
+func (f ElevationFormat) Round(elevation float64) float64 {
+	scale := math.Pow(10, float64(f.Precision))
+	// Undo binary representation errors (e.g. 1234.5 stored as 1234.4999...) before rounding
+	scaled := math.Round(elevation*scale*1e6) / 1e6
+	switch f.Rounding {
+	case RoundDown:
+		scaled = math.Floor(scaled)
+	case RoundUp:
+		scaled = math.Ceil(scaled)
+	default:
+		scaled = math.Round(scaled)
+	}
+	return scaled / scale
+}
+
+// Format returns the ele tag value of an elevation
+func (f ElevationFormat) Format(elevation float64) string {
+	return strconv.FormatFloat(f.Round(elevation), 'f', f.Precision, 64)
+}
+
+// Apply rounds the element's fetched elevation and rewrites its ele tag to match
+func (f ElevationFormat) Apply(element *OSMElement) {
+	if element.ElevationFetched == nil {
+		return
+	}
+	rounded := f.Round(*element.ElevationFetched)
+	element.ElevationFetched = &rounded
+	tags := make(map[string]string, len(element.Tags)+1)
+	for k, v := range element.Tags {
+		tags[k] = v
+	}
+	tags["ele"] = f.Format(rounded)
+	element.Tags = tags
+}
+
+// registerElevationFormatFlags adds --ele-precision and --ele-rounding and returns a function
+// that applies them when given
+func registerElevationFormatFlags(fs *flag.FlagSet) func() {
+	precision := fs.Int("ele-precision", defaultElevationFormat.Precision, "Decimals written in ele tags (0 for whole meters)")
+	rounding := fs.String("ele-rounding", defaultElevationFormat.Rounding, "Rounding of ele values: nearest, down or up")
+	return func() {
+		if flagWasSet(fs, "ele-precision") {
+			flagConfig.Set("ELE_PRECISION", strconv.Itoa(*precision))
+		}
+		if flagWasSet(fs, "ele-rounding") {
+			flagConfig.Set("ELE_ROUNDING", *rounding)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestElevationFormat(t *testing.T) {
+	tests := []struct {
+		name      string
+		precision string
+		rounding  string
+		elevation float64
+		want      string
+	}{
+		{name: "Default one decimal", elevation: 1234.56, want: "1234.6"},
+		{name: "Whole meters", precision: "0", elevation: 1234.5, want: "1235"},
+		{name: "Round down", precision: "0", rounding: RoundDown, elevation: 1234.9, want: "1234"},
+		{name: "Round up", precision: "0", rounding: RoundUp, elevation: 1234.1, want: "1235"},
+		{name: "Round up keeps exact values", precision: "1", rounding: RoundUp, elevation: 0.3, want: "0.3"},
+		{name: "Below sea level", precision: "0", elevation: -2.5, want: "-3"},
+		{name: "Two decimals", precision: "2", elevation: 850.005, want: "850.01"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Set("ELE_PRECISION", tt.precision)
+			config.Set("ELE_ROUNDING", tt.rounding)
+			format, err := resolveElevationFormat(config)
+			if err != nil {
+				t.Fatalf("resolveElevationFormat() error = %v", err)
+			}
+			if got := format.Format(tt.elevation); got != tt.want {
+				t.Errorf("Format(%v) = %q, want %q", tt.elevation, got, tt.want)
+			}
+		})
+	}
+
+	for key, value := range map[string]string{"ELE_PRECISION": "5", "ELE_ROUNDING": "banker"} {
+		config := NewConfig()
+		config.Set(key, value)
+		if _, err := resolveElevationFormat(config); err == nil || !strings.Contains(err.Error(), key) {
+			t.Errorf("Expected error for %s=%s, got %v", key, value, err)
+		}
+	}
+}
+
+func TestElevationFormatAppliedConsistently(t *testing.T) {
+	format := ElevationFormat{Precision: 0, Rounding: RoundDown}
+	tags := map[string]string{"ele": "2600.4", "ele:source": "SRTM"}
+	element := OSMElement{Type: "node", ID: 1, ElevationFetched: floatPtr(2600.4), Tags: tags}
+
+	validator := NewElevationValidator(0, 2600)
+	validator.Format = &format
+	results := validator.ValidateElements([]OSMElement{element})
+	if len(results.Valid) != 1 {
+		t.Fatalf("Expected the rounded elevation to pass validation, got %+v", results.Invalid)
+	}
+	if got := results.Valid[0].Tags["ele"]; got != "2600" || *results.Valid[0].ElevationFetched != 2600 {
+		t.Errorf("Validated ele = %q (%v), want 2600", got, *results.Valid[0].ElevationFetched)
+	}
+	if tags["ele"] != "2600.4" {
+		t.Error("Validation should not modify the input tags")
+	}
+
+	newTags, err := elevationTags(element, format, elevationSourceOrDefault(NewConfig()))
+	if err != nil {
+		t.Fatalf("elevationTags() error = %v", err)
+	}
+	if newTags["ele"] != "2600" {
+		t.Errorf("Uploaded ele = %q, want 2600", newTags["ele"])
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+)
+
+// ElevationRange is the plausible range of elevations in meters
+type ElevationRange struct {
+	Min float64
+	Max float64
+}
+
+// worldElevationRange covers every land elevation, from the Dead Sea shore to Everest
+var worldElevationRange = ElevationRange{Min: -440, Max: 8850}
+
+// countryElevationRanges maps OSM country names (name tag) to their lowest and highest
+// land elevation, rounded outwards
+var countryElevationRanges = map[string]ElevationRange{
+	"România":                        {Min: 0, Max: 2600},
+	"Moldova":                        {Min: 0, Max: 450},
+	"България":                       {Min: 0, Max: 2950},
+	"Magyarország":                   {Min: 70, Max: 1050},
+	"Україна":                        {Min: -10, Max: 2100},
+	"Србија":                         {Min: 25, Max: 2200},
+	"Hrvatska":                       {Min: 0, Max: 1850},
+	"Slovenija":                      {Min: 0, Max: 2900},
+	"Österreich":                     {Min: 110, Max: 3820},
+	"Schweiz/Suisse/Svizzera/Svizra": {Min: 190, Max: 4650},
+	"Deutschland":                    {Min: -5, Max: 2970},
+	"France":                         {Min: -5, Max: 4810},
+	"Italia":                         {Min: -5, Max: 4810},
+	"España":                         {Min: 0, Max: 3720},
+	"Portugal":                       {Min: 0, Max: 2360},
+	"Nederland":                      {Min: -10, Max: 890},
+	"België / Belgique / Belgien":    {Min: 0, Max: 700},
+	"Polska":                         {Min: -5, Max: 2500},
+	"Česko":                          {Min: 110, Max: 1610},
+	"Slovensko":                      {Min: 90, Max: 2660},
+	"Ελλάς":                          {Min: 0, Max: 2920},
+	"United Kingdom":                 {Min: -5, Max: 1350},
+	"Éire / Ireland":                 {Min: 0, Max: 1040},
+	"Norge":                          {Min: 0, Max: 2470},
+	"Sverige":                        {Min: -5, Max: 2110},
+	"Suomi / Finland":                {Min: 0, Max: 1330},
+	"Danmark":                        {Min: -10, Max: 175},
+	"Ísland":                         {Min: 0, Max: 2120},
+	"Россия":                         {Min: -30, Max: 5650},
+	"Türkiye":                        {Min: 0, Max: 5140},
+	"United States":                  {Min: -90, Max: 6200},
+	"Canada":                         {Min: 0, Max: 5960},
+	"México":                         {Min: -15, Max: 5640},
+	"Brasil":                         {Min: 0, Max: 3000},
+	"Argentina":                      {Min: -110, Max: 6970},
+	"Chile":                          {Min: 0, Max: 6900},
+	"Perú":                           {Min: 0, Max: 6770},
+	"नेपाल":                          {Min: 55, Max: 8850},
+	"中国":                             {Min: -160, Max: 8850},
+	"भारत":                           {Min: -5, Max: 8590},
+	"日本":                             {Min: -5, Max: 3780},
+	"ישראל":                          {Min: -440, Max: 2240},
+	"مصر":                            {Min: -135, Max: 2640},
+	"Australia":                      {Min: -20, Max: 2230},
+	"New Zealand / Aotearoa":         {Min: -5, Max: 3730},
+	"South Africa":                   {Min: 0, Max: 3460},
+	"Kenya":                          {Min: 0, Max: 5200},
+	"Tanzania":                       {Min: 0, Max: 5900},
+}
+
+// ElevationRangeForCountry returns the preset range of a country, or the worldwide range
+// and false when the country has no preset
+func ElevationRangeForCountry(country string) (ElevationRange, bool) {
+	if r, ok := countryElevationRanges[country]; ok {
+		return r, true
+	}
+	return worldElevationRange, false
+}
+
+// resolveElevationRange returns the validation range of a country: its preset, with
+// MIN_ELEVATION and MAX_ELEVATION overriding either bound when configured
+func resolveElevationRange(config *Config, country string) (ElevationRange, error) {
+	r, _ := ElevationRangeForCountry(country)
+	if value := config.Get("MIN_ELEVATION"); value != "" {
+		lower, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return ElevationRange{}, fmt.Errorf("invalid MIN_ELEVATION %q: %v", value, err)
+		}
+		r.Min = lower
+	}
+	if value := config.Get("MAX_ELEVATION"); value != "" {
+		upper, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return ElevationRange{}, fmt.Errorf("invalid MAX_ELEVATION %q: %v", value, err)
+		}
+		r.Max = upper
+	}
+	if r.Min >= r.Max {
+		return ElevationRange{}, fmt.Errorf("invalid elevation range %g-%g m (MIN_ELEVATION must be below MAX_ELEVATION)", r.Min, r.Max)
+	}
+	return r, nil
+}
+
+// registerElevationRangeFlags adds --min-ele and --max-ele and returns a function that applies them when given
+func registerElevationRangeFlags(fs *flag.FlagSet) func() {
+	minEle := fs.Float64("min-ele", 0, "Lowest valid elevation in meters (default: the country's preset or MIN_ELEVATION)")
+	maxEle := fs.Float64("max-ele", 0, "Highest valid elevation in meters (default: the country's preset or MAX_ELEVATION)")
+	return func() {
+		if flagWasSet(fs, "min-ele") {
+			flagConfig.Set("MIN_ELEVATION", strconv.FormatFloat(*minEle, 'f', -1, 64))
+		}
+		if flagWasSet(fs, "max-ele") {
+			flagConfig.Set("MAX_ELEVATION", strconv.FormatFloat(*maxEle, 'f', -1, 64))
+		}
+	}
+}
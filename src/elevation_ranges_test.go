@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestResolveElevationRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		country  string
+		min, max string
+		want     ElevationRange
+		wantErr  string
+	}{
+		{name: "Romania preset", country: "România", want: ElevationRange{Min: 0, Max: 2600}},
+		{name: "Nepal preset", country: "नेपाल", want: ElevationRange{Min: 55, Max: 8850}},
+		{name: "Below sea level", country: "Nederland", want: ElevationRange{Min: -10, Max: 890}},
+		{name: "Unknown country", country: "Atlantis", want: worldElevationRange},
+		{name: "Max override", country: "România", max: "3000", want: ElevationRange{Min: 0, Max: 3000}},
+		{name: "Both overrides", country: "Moldova", min: "-5", max: "500", want: ElevationRange{Min: -5, Max: 500}},
+		{name: "Empty range", country: "Moldova", min: "600", wantErr: "MIN_ELEVATION must be below"},
+		{name: "Invalid value", country: "Moldova", max: "high", wantErr: "invalid MAX_ELEVATION"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			config.Set("MIN_ELEVATION", tt.min)
+			config.Set("MAX_ELEVATION", tt.max)
+
+			got, err := resolveElevationRange(config, tt.country)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("resolveElevationRange(%q) = %+v, want %+v", tt.country, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// eleSourceKeys are the tag keys communities use for the source of an ele value
+var eleSourceKeys = []string{"ele:source", "source:ele"}
+
+// defaultEleSource is the source value used when the dataset of a provider is unknown
+const defaultEleSource = "SRTM"
+
+// openTopoDatasetSources names the OpenTopoData datasets (the last segment of OPENTOPO_URL)
+var openTopoDatasetSources = map[string]string{
+	"srtm30m":   "SRTM 1 arc-second",
+	"srtm90m":   "SRTM 3 arc-second",
+	"aster30m":  "ASTER GDEM",
+	"eudem25m":  "EU-DEM",
+	"mapzen":    "Mapzen Terrain Tiles",
+	"ned10m":    "USGS NED",
+	"nzdem8m":   "LINZ NZ DEM",
+	"etopo1":    "ETOPO1",
+	"gebco2020": "GEBCO 2020",
+	"emod2018":  "EMODnet Bathymetry 2018",
+	"bkg200m":   "BKG DGM200",
+}
+
+// ElevationSource is the tag key and per-provider value recording where an ele value comes from
+type ElevationSource struct {
+	Key    string
+	values map[string]string // provider name → source value
+}
+
+// resolveElevationSource reads ELE_SOURCE_KEY and ELE_SOURCE_VALUES ("provider=value,..."). Providers
+// without an override are named after their dataset.
+func resolveElevationSource(config *Config) (ElevationSource, error) {
+	source := ElevationSource{Key: eleSourceKeys[0], values: map[string]string{
+		ProviderOpenTopo:      openTopoSource(config.Get("OPENTOPO_URL")),
+		ProviderOpenElevation: defaultEleSource,
+		ProviderHGT:           defaultEleSource,
+	}}
+
+	if key := config.Get("ELE_SOURCE_KEY"); key != "" {
+		valid := false
+		for _, k := range eleSourceKeys {
+			valid = valid || k == key
+		}
+		if !valid {
+			return source, fmt.Errorf("invalid ELE_SOURCE_KEY %q (expected %s)", key, strings.Join(eleSourceKeys, " or "))
+		}
+		source.Key = key
+	}
+
+	for _, item := range splitList(config.Get("ELE_SOURCE_VALUES")) {
+		provider, value, ok := strings.Cut(item, "=")
+		provider, value = strings.ToLower(strings.TrimSpace(provider)), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return source, fmt.Errorf("invalid ELE_SOURCE_VALUES entry %q (expected provider=value)", item)
+		}
+		if _, known := source.values[provider]; !known {
+			return source, fmt.Errorf("invalid ELE_SOURCE_VALUES entry %q: unknown elevation provider %q", item, provider)
+		}
+		source.values[provider] = value
+	}
+	return source, nil
+}
+
+// elevationSourceOrDefault is resolveElevationSource for components built after the
+// configuration was checked
+func elevationSourceOrDefault(config *Config) ElevationSource {
+	source, err := resolveElevationSource(config)
+	if err != nil {
+		source, _ = resolveElevationSource(NewConfig())
+	}
+	return source
+}
+
+// openTopoSource names the dataset served at an OpenTopoData URL
+func openTopoSource(url string) string {
+	if url == "" {
+		return openTopoDatasetSources["srtm30m"]
+	}
+	if name, ok := openTopoDatasetSources[consensusDatasetName(url)]; ok {
+		return name
+	}
+	return defaultEleSource
+}
+
+// Value returns the source value for elevations from provider
+func (s ElevationSource) Value(provider string) string {
+	if value, ok := s.values[provider]; ok {
+		return value
+	}
+	return defaultEleSource
+}
+
+// Of returns the source recorded on an enriched element, or the value of its provider
+func (s ElevationSource) Of(element OSMElement) string {
+	if value := element.Tags[s.Key]; value != "" {
+		return value
+	}
+	if _, value := eleSourceTag(element.Tags); value != "" {
+		return value
+	}
+	return s.Value(element.ElevationProvider)
+}
+
+// eleSourceTag returns the source key and value present in tags, or the default key and ""
+func eleSourceTag(tags map[string]string) (string, string) {
+	for _, key := range eleSourceKeys {
+		if value := tags[key]; value != "" {
+			return key, value
+		}
+	}
+	return eleSourceKeys[0], ""
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestResolveElevationSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]string
+		provider string
+		wantKey  string
+		want     string
+		wantErr  string
+	}{
+		{name: "Default OpenTopoData dataset", provider: ProviderOpenTopo, wantKey: "ele:source", want: "SRTM 1 arc-second"},
+		{name: "Dataset from URL", settings: map[string]string{"OPENTOPO_URL": "https://api.opentopodata.org/v1/eudem25m"}, provider: ProviderOpenTopo, wantKey: "ele:source", want: "EU-DEM"},
+		{name: "Local tiles", provider: ProviderHGT, wantKey: "ele:source", want: "SRTM"},
+		{name: "Alternative key", settings: map[string]string{"ELE_SOURCE_KEY": "source:ele"}, provider: ProviderHGT, wantKey: "source:ele", want: "SRTM"},
+		{name: "Provider override", settings: map[string]string{"ELE_SOURCE_VALUES": "hgt=Copernicus GLO-30, opentopo=SRTM"}, provider: ProviderHGT, wantKey: "ele:source", want: "Copernicus GLO-30"},
+		{name: "Unknown key", settings: map[string]string{"ELE_SOURCE_KEY": "source"}, wantErr: "invalid ELE_SOURCE_KEY"},
+		{name: "Unknown provider", settings: map[string]string{"ELE_SOURCE_VALUES": "lidar=LiDAR"}, wantErr: "unknown elevation provider"},
+		{name: "Missing value", settings: map[string]string{"ELE_SOURCE_VALUES": "hgt"}, wantErr: "expected provider=value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := NewConfig()
+			for key, value := range tt.settings {
+				config.Set(key, value)
+			}
+			source, err := resolveElevationSource(config)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveElevationSource() error = %v", err)
+			}
+			if source.Key != tt.wantKey || source.Value(tt.provider) != tt.want {
+				t.Errorf("Got %s=%s, want %s=%s", source.Key, source.Value(tt.provider), tt.wantKey, tt.want)
+			}
+		})
+	}
+}
+
+func TestElevationSourceTaggedFromProvider(t *testing.T) {
+	config := NewConfig()
+	config.Set("ELE_SOURCE_KEY", "source:ele")
+	config.Set("ELE_SOURCE_VALUES", "hgt=Copernicus GLO-30")
+	source, err := resolveElevationSource(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	chain := &ElevationProviderChain{}
+	chain.Add(ProviderHGT, &fakeBatchProvider{elevations: map[int64]float64{1: 1500}})
+	enricher := &BatchElevationEnricher{
+		APIType:        ProviderOpenTopo,
+		BatchSize:      10,
+		Provider:       chain,
+		Source:         &source,
+		coordExtractor: NewCoordinateExtractor(),
+	}
+	elements := []OSMElement{{Type: "node", ID: 1, Lat: 45.5, Lon: 25.1, Tags: map[string]string{"natural": "peak"}}}
+	enriched, err := enricher.EnrichElementsBatch(context.Background(), elements, 0)
+	if err != nil || len(enriched) != 1 {
+		t.Fatalf("EnrichElementsBatch() = %d elements, %v", len(enriched), err)
+	}
+	if got := enriched[0].Tags["source:ele"]; got != "Copernicus GLO-30" {
+		t.Errorf("source:ele = %q, want Copernicus GLO-30", got)
+	}
+	if _, ok := enriched[0].Tags["ele:source"]; ok {
+		t.Error("ele:source should not be written when source:ele is configured")
+	}
+
+	newTags, err := elevationTags(enriched[0], defaultElevationFormat, source)
+	if err != nil {
+		t.Fatalf("elevationTags() error = %v", err)
+	}
+	if newTags["source:ele"] != "Copernicus GLO-30" || newTags["ele"] != "1500.0" {
+		t.Errorf("elevationTags() = %v", newTags)
+	}
+}
@@ -1,9 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -12,19 +17,22 @@ type ElevationEnricher struct {
 	APIType        string
 	RateLimit      time.Duration
 	BaseURL        string
+	Format         *ElevationFormat // precision and rounding of ele values, one decimal when nil
+	Source         *ElevationSource // source tag written with each value, ele:source=SRTM when nil
 	coordExtractor *CoordinateExtractor
+	httpClient     HTTPClient
 }
 
 type OpenTopoDataResponse struct {
 	Status  string `json:"status"`
 	Results []struct {
-		Elevation float64 `json:"elevation"`
+		Elevation *float64 `json:"elevation"`
 	} `json:"results"`
 }
 
 type OpenElevationResponse struct {
 	Results []struct {
-		Elevation float64 `json:"elevation"`
+		Elevation *float64 `json:"elevation"`
 	} `json:"results"`
 }
 
@@ -33,6 +41,7 @@ func NewElevationEnricher(apiType string, rateLimit float64) *ElevationEnricher
 		APIType:        apiType,
 		RateLimit:      time.Duration(rateLimit * float64(time.Millisecond)),
 		coordExtractor: NewCoordinateExtractor(),
+		httpClient:     NewRetryingReadClient(30 * time.Second),
 	}
 	// Note: Using direct API endpoint instead of proxy for better reliability
 	// The proxy URL (go.proxy.okssh.com) was causing DNS resolution issues
@@ -45,20 +54,37 @@ func NewElevationEnricher(apiType string, rateLimit float64) *ElevationEnricher
 	return e
 }
 
-func (e *ElevationEnricher) GetElevation(lat, lon float64) (*float64, error) {
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-	}
-
+func (e *ElevationEnricher) GetElevation(ctx context.Context, lat, lon float64) (*float64, error) {
 	var resp *http.Response
 	var err error
 
 	if e.APIType == "opentopo" {
+		if err := sharedBudget().AcquireContext(ctx, BudgetElevation); err != nil {
+			return nil, err
+		}
 		url := fmt.Sprintf("%s?locations=%.6f,%.6f", e.BaseURL, lat, lon)
-		resp, err = client.Get(url)
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %v", err)
+		}
+		resp, err = e.httpClient.Do(req)
 	} else {
-		// Open-Elevation (not implemented in this example, but structure is here)
-		return nil, fmt.Errorf("open-elevation not implemented yet")
+		if err := sharedBudget().AcquireContext(ctx, BudgetElevation); err != nil {
+			return nil, err
+		}
+		body, err := json.Marshal(OpenElevationBatchRequest{
+			Locations: []OpenElevationLocation{{Latitude: lat, Longitude: lon}},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode request: %v", err)
+		}
+		req, reqErr := http.NewRequestWithContext(ctx, "POST", e.BaseURL, bytes.NewReader(body))
+		if reqErr != nil {
+			return nil, fmt.Errorf("failed to create request: %v", reqErr)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err = e.httpClient.Do(req)
 	}
 
 	if err != nil {
@@ -70,20 +96,30 @@ func (e *ElevationEnricher) GetElevation(lat, lon float64) (*float64, error) {
 		return nil, fmt.Errorf("elevation API returned status %d", resp.StatusCode)
 	}
 
+	if e.APIType != "opentopo" {
+		var result OpenElevationResponse
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			return nil, fmt.Errorf("failed to decode response: %v", err)
+		}
+		if len(result.Results) > 0 {
+			return checkElevation(result.Results[0].Elevation)
+		}
+		return nil, fmt.Errorf("no elevation data returned")
+	}
+
 	var result OpenTopoDataResponse
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %v", err)
 	}
 
 	if result.Status == "OK" && len(result.Results) > 0 {
-		elevation := result.Results[0].Elevation
-		return &elevation, nil
+		return checkElevation(result.Results[0].Elevation)
 	}
 
 	return nil, fmt.Errorf("no elevation data returned")
 }
 
-func (e *ElevationEnricher) EnrichElement(element OSMElement) (*OSMElement, error) {
+func (e *ElevationEnricher) EnrichElement(ctx context.Context, element OSMElement) (*OSMElement, error) {
 	// Get coordinates using the coordinate extractor
 	coords, valid := e.coordExtractor.Extract(element)
 	if !valid {
@@ -91,19 +127,24 @@ func (e *ElevationEnricher) EnrichElement(element OSMElement) (*OSMElement, erro
 	}
 
 	// Get elevation
-	elevation, err := e.GetElevation(coords.Lat, coords.Lon)
+	elevation, err := e.GetElevation(ctx, coords.Lat, coords.Lon)
 	if err != nil {
 		return nil, err
 	}
 
 	if elevation != nil {
 		// Add elevation to element
-		if element.Tags == nil {
-			element.Tags = make(map[string]string)
+		format := defaultElevationFormat
+		if e.Format != nil {
+			format = *e.Format
+		}
+		source := ElevationSource{Key: eleSourceKeys[0]}
+		if e.Source != nil {
+			source = *e.Source
 		}
-		element.Tags["ele"] = fmt.Sprintf("%.1f", *elevation)
-		element.Tags["ele:source"] = "SRTM"
 		element.ElevationFetched = elevation
+		format.Apply(&element)
+		element.Tags[source.Key] = source.Value(e.APIType)
 	}
 
 	// Rate limiting
@@ -112,7 +153,7 @@ func (e *ElevationEnricher) EnrichElement(element OSMElement) (*OSMElement, erro
 	return &element, nil
 }
 
-func (e *ElevationEnricher) EnrichElements(elements []OSMElement, maxCount int) []OSMElement {
+func (e *ElevationEnricher) EnrichElements(ctx context.Context, elements []OSMElement, maxCount int) []OSMElement {
 	var enriched []OSMElement
 	count := 0
 
@@ -121,9 +162,9 @@ func (e *ElevationEnricher) EnrichElements(elements []OSMElement, maxCount int)
 			break
 		}
 
-		enrichedElement, err := e.EnrichElement(element)
+		enrichedElement, err := e.EnrichElement(ctx, element)
 		if err != nil {
-			fmt.Printf("Warning: failed to enrich element %d: %v\n", element.ID, err)
+			enrichLog.Warn("Failed to enrich element %d: %v", element.ID, err)
 			continue
 		}
 
@@ -131,7 +172,7 @@ func (e *ElevationEnricher) EnrichElements(elements []OSMElement, maxCount int)
 			enriched = append(enriched, *enrichedElement)
 			count++
 			if count%10 == 0 {
-				fmt.Printf("Processed %d elements...\n", count)
+				enrichLog.Info("Processed %d elements...", count)
 			}
 		}
 	}
@@ -140,65 +181,306 @@ func (e *ElevationEnricher) EnrichElements(elements []OSMElement, maxCount int)
 }
 
 type EnrichedData struct {
+	SchemaHeader
 	TrainStations       []OSMElement `json:"train_stations"`
 	AlpineHuts          []OSMElement `json:"alpine_huts"`
 	OtherAccommodations []OSMElement `json:"other_accommodations"`
+	Peaks               []OSMElement `json:"peaks"`
+	Shelters            []OSMElement `json:"shelters"`
+}
+
+// Category returns the elements of a category by its key
+func (d *EnrichedData) Category(key string) *[]OSMElement {
+	switch key {
+	case "peaks":
+		return &d.Peaks
+	case "alpine_huts":
+		return &d.AlpineHuts
+	case "shelters":
+		return &d.Shelters
+	case "train_stations":
+		return &d.TrainStations
+	case "other_accommodations":
+		return &d.OtherAccommodations
+	}
+	return nil
 }
 
-func runEnrich(maxItems int) error {
-	fmt.Println("\n" + string(repeat('=', 60)))
-	fmt.Println("STEP 3: ENRICH - Fetching elevation from OpenTopoData (Batch Mode)")
-	fmt.Println(string(repeat('=', 60)))
+// categoryLimitFlags are the shortcut flags for per-category enrichment limits
+var categoryLimitFlags = []struct {
+	name string
+	key  string
+}{
+	{"limit-alpine-huts", "alpine_huts"},
+	{"limit-stations", "train_stations"},
+	{"limit-accommodations", "other_accommodations"},
+}
+
+// parseCategoryLimits reads ENRICH_LIMITS ("category=count,..."). A later entry for a category
+// replaces an earlier one; 0 means no limit.
+func parseCategoryLimits(value string) (map[string]int, error) {
+	limits := make(map[string]int)
+	known := knownCategoryKeys()
+	for _, item := range splitList(value) {
+		key, count, ok := strings.Cut(item, "=")
+		key = strings.TrimSpace(key)
+		limit, err := strconv.Atoi(strings.TrimSpace(count))
+		if !ok || err != nil || limit < 0 {
+			return nil, fmt.Errorf("invalid ENRICH_LIMITS entry %q (expected category=count)", item)
+		}
+		valid := false
+		for _, k := range known {
+			valid = valid || k == key
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid ENRICH_LIMITS entry %q: unknown category %q (expected %s)", item, key, strings.Join(known, ", "))
+		}
+		limits[key] = limit
+	}
+	return limits, nil
+}
+
+// registerCategoryLimitFlags adds --limit-alpine-huts, --limit-stations and --limit-accommodations
+// and returns a function that adds the given ones to ENRICH_LIMITS
+func registerCategoryLimitFlags(fs *flag.FlagSet) func() {
+	values := make([]*int, len(categoryLimitFlags))
+	for i, f := range categoryLimitFlags {
+		values[i] = fs.Int(f.name, 0, fmt.Sprintf("Limit number of %s to enrich, overriding --limit (0 = all)", f.key))
+	}
+	return func() {
+		var entries []string
+		for i, f := range categoryLimitFlags {
+			if flagWasSet(fs, f.name) {
+				entries = append(entries, fmt.Sprintf("%s=%d", f.key, *values[i]))
+			}
+		}
+		if len(entries) == 0 {
+			return
+		}
+		// Keep the limits of other categories from the environment or config file
+		config := NewConfig()
+		config.LoadFromEnv()
+		flagConfig.Set("ENRICH_LIMITS", strings.Join(append(splitList(config.Get("ENRICH_LIMITS")), entries...), ","))
+	}
+}
+
+func runEnrich(ctx context.Context, ws Workspace, maxItems int) error {
+	enrichLog.Banner("STEP 3: ENRICH - Fetching elevation from OpenTopoData (Batch Mode)")
+	started := time.Now()
+	if err := runStepStartHook(ws, StepEnrich, ""); err != nil {
+		return err
+	}
 
 	// Load filtered data
 	var data FilteredData
-	if err := loadJSON("output/osm_data_filtered.json", &data); err != nil {
-		return fmt.Errorf("output/osm_data_filtered.json not found. Run --filter first: %v", err)
+	filteredFile := ws.File(DefaultFilteredDataFile)
+	if err := loadPipelineFile(filteredFile, SchemaFiltered, &data); err != nil {
+		return pipelineLoadError(filteredFile, "filter", err)
 	}
 
 	// Initialize configuration and factory
 	config := NewConfig()
 	config.LoadFromEnv()
+	if _, err := resolveElevationFormat(config); err != nil {
+		return err
+	}
+	if _, err := resolveElevationSource(config); err != nil {
+		return err
+	}
+	limits, err := parseCategoryLimits(config.Get("ENRICH_LIMITS"))
+	if err != nil {
+		return err
+	}
+	survey, err := surveyMatcherFromConfig(config)
+	if err != nil {
+		return err
+	}
+	if survey != nil {
+		enrichLog.Info("GPX survey: %d points, used within %.0f m of an element", survey.Points, survey.Radius)
+	}
 	logger := NewLogger("Enricher")
 	factory := NewAPIClientFactory(config, logger)
 
 	// Create batch enricher using factory
 	batchEnricher := factory.CreateBatchElevationEnricher("opentopo")
 
+	chain, err := factory.CreateElevationProviderChain()
+	if err != nil {
+		return err
+	}
+	defer chain.Close()
+	batchEnricher.Provider = chain
+
+	var cached *CachedElevationProvider
+	if cache := sharedElevationCache(); cache != nil {
+		cached = NewCachedElevationProvider(cache, chain.Source(), chain)
+		batchEnricher.Provider = cached
+		enrichLog.Info("Elevation cache: %s (%d points)", config.Get("ELEVATION_CACHE_FILE"), cache.Len())
+	}
+
+	var slopeScorer *SlopeScorer
+	if config.GetBool("SLOPE_CHECK") {
+		slopeScorer = NewSlopeScorer(batchEnricher.Provider, config.GetFloat("SLOPE_SAMPLE_DISTANCE_M"), batchEnricher.BatchSize)
+		enrichLog.Info("Slope check: sampling the DEM %.0f m around each element", slopeScorer.Distance)
+	}
+
+	// The consensus check wraps the cache so cached values are confirmed too
+	consensus := factory.CreateConsensusProvider(batchEnricher.Provider)
+	if consensus != nil {
+		batchEnricher.Provider = consensus
+		enrichLog.Info("Consensus check: %s must agree within %.0f m", consensus.SecondaryName, consensus.Tolerance)
+	}
+
+	names := chain.Names()
+	enrichLog.Info("Elevation providers: %s", strings.Join(names, " → "))
+	if len(names) == 1 && names[0] == ProviderHGT {
+		// Local tiles need no rate limiting
+		batchEnricher.RateLimit = 0
+		enrichLog.Info("Using local SRTM tiles from %s (offline mode)", config.Get("ELEVATION_TILE_DIR"))
+	} else if remaining := sharedBudget().Remaining(BudgetElevation); remaining >= 0 {
+		enrichLog.Info("Elevation API budget remaining today: %d requests", remaining)
+	}
+
+	inputHash, err := fileHash(existingJSONFile(filteredFile))
+	if err != nil {
+		return fmt.Errorf("failed to hash filtered data: %v", err)
+	}
+	checkpoint, err := LoadEnrichCheckpoint(ws.File(DefaultEnrichCheckpointFile), inputHash)
+	if err != nil {
+		return err
+	}
+	if len(checkpoint.Elements) > 0 {
+		enrichLog.Info("Resuming from checkpoint with %d already enriched elements", len(checkpoint.Elements))
+	}
+	batchEnricher.Checkpoint = checkpoint
+	batchEnricher.CheckpointEvery = config.GetInt("ENRICH_CHECKPOINT_EVERY")
+
 	enriched := &EnrichedData{
 		TrainStations:       []OSMElement{},
 		AlpineHuts:          []OSMElement{},
 		OtherAccommodations: []OSMElement{},
+		Peaks:               []OSMElement{},
+		Shelters:            []OSMElement{},
+	}
+	// Elements the DEM has no data for (voids, ocean), reported instead of enriched
+	noData := &EnrichedData{
+		TrainStations:       []OSMElement{},
+		AlpineHuts:          []OSMElement{},
+		OtherAccommodations: []OSMElement{},
+		Peaks:               []OSMElement{},
+		Shelters:            []OSMElement{},
 	}
 
-	// Process alpine huts first (priority)
-	if len(data.AlpineHuts) > 0 {
-		fmt.Println("\n[PRIORITY] Enriching alpine huts using batch API...")
-		enriched.AlpineHuts = batchEnricher.EnrichElementsBatch(data.AlpineHuts, maxItems)
+	// Process categories in profile priority order
+	for _, cat := range activeProfile().Categories {
+		elements := sortByImportance(*data.Category(cat.Key))
+		if len(elements) == 0 {
+			continue
+		}
+		if cat.Priority {
+			enrichLog.Info("[PRIORITY] Enriching %s using batch API...", strings.ToLower(cat.Label))
+		} else {
+			enrichLog.Info("Enriching %s using batch API...", strings.ToLower(cat.Label))
+		}
+		categoryLimit := maxItems
+		if limit, ok := limits[cat.Key]; ok {
+			categoryLimit = limit
+		}
+		batchEnricher.NoData = nil
+		categoryElements, err := batchEnricher.EnrichElementsBatch(ctx, elements, categoryLimit)
+		if err != nil {
+			return fmt.Errorf("enrich interrupted, rerun to resume from %s: %v", checkpoint.path, err)
+		}
+		if survey != nil {
+			categoryElements, batchEnricher.NoData = survey.Apply(categoryElements, batchEnricher.NoData, *batchEnricher.Format, batchEnricher.Source.Key)
+		}
+		if slopeScorer != nil {
+			slopeScorer.RateLimit = batchEnricher.RateLimit
+			scored, err := slopeScorer.Score(ctx, categoryElements)
+			if err != nil {
+				return fmt.Errorf("enrich interrupted, rerun to resume from %s: %v", checkpoint.path, err)
+			}
+			enrichLog.Info("Scored terrain slope of %d/%d elements", scored, len(categoryElements))
+		}
+		*enriched.Category(cat.Key) = categoryElements
+		*noData.Category(cat.Key) = append(*noData.Category(cat.Key), batchEnricher.NoData...)
 	}
 
-	// Process train stations
-	if len(data.TrainStations) > 0 {
-		fmt.Println("\nEnriching train stations using batch API...")
-		enriched.TrainStations = batchEnricher.EnrichElementsBatch(data.TrainStations, maxItems)
+	// Save enriched data
+	enrichedFile := ws.File(DefaultEnrichedDataFile)
+	if err := savePipelineFile(enrichedFile, SchemaEnriched, enriched); err != nil {
+		return err
 	}
 
-	// Process other accommodations
-	if len(data.OtherAccommodations) > 0 {
-		fmt.Println("\nEnriching other accommodations using batch API...")
-		enriched.OtherAccommodations = batchEnricher.EnrichElementsBatch(data.OtherAccommodations, maxItems)
+	// The run completed, so the next enrich starts fresh
+	if err := checkpoint.Remove(); err != nil {
+		enrichLog.Warn("Failed to remove enrich checkpoint: %v", err)
 	}
 
-	// Save enriched data
-	if err := saveJSON("output/osm_data_enriched.json", enriched); err != nil {
-		return err
+	outputs := []string{enrichedFile}
+	enrichLog.Info("✓ Enrichment complete!")
+	if cached != nil {
+		enrichLog.Info("  Answered from elevation cache: %d", cached.Hits())
+	}
+	if survey != nil {
+		enrichLog.Info("  Surveyed elevations from GPX: %d", survey.Matched)
+	}
+	noDataCount := 0
+	for _, key := range categoryKeys {
+		noDataCount += len(*noData.Category(key))
 	}
+	if noDataCount > 0 {
+		noDataFile := ws.File(DefaultNoDataFile)
+		if err := savePipelineFile(noDataFile, SchemaNoData, noData); err != nil {
+			return err
+		}
+		enrichLog.Warn("  No DEM data (void or nodata): %d (see %s)", noDataCount, noDataFile)
+		outputs = append(outputs, noDataFile)
+	}
+	if consensus != nil {
+		disagreements := consensus.Disagreements()
+		reviewFile := ws.File(DefaultConsensusReviewFile)
+		if err := WriteConsensusReview(reviewFile, disagreements); err != nil {
+			return err
+		}
+		enrichLog.Warn("  Rejected by consensus check: %d (see %s)", len(disagreements), reviewFile)
+		outputs = append(outputs, reviewFile)
+	}
+	for _, cat := range activeProfile().Categories {
+		enrichLog.Info("  %s: %d", cat.Label, len(*enriched.Category(cat.Key)))
+	}
+	enrichLog.Info("✓ Enriched data saved to %s", enrichedFile)
 
-	fmt.Println("\n✓ Enrichment complete!")
-	fmt.Printf("  Alpine huts: %d\n", len(enriched.AlpineHuts))
-	fmt.Printf("  Train stations: %d\n", len(enriched.TrainStations))
-	fmt.Printf("  Other accommodations: %d\n", len(enriched.OtherAccommodations))
-	fmt.Println("✓ Enriched data saved to output/osm_data_enriched.json")
+	recordPipelineState(ws, func(store *PipelineStore) error {
+		elements := make(map[string][]OSMElement)
+		for _, key := range categoryKeys {
+			elements[key] = *enriched.Category(key)
+		}
+		if err := store.Record(StateEnriched, elements); err != nil {
+			return err
+		}
+		missing := make(map[string][]OSMElement)
+		reasons := make(map[string]string)
+		for _, key := range categoryKeys {
+			missing[key] = *noData.Category(key)
+			for _, element := range missing[key] {
+				reasons[elementKey(element.Type, element.ID)] = ErrElevationVoid.Error()
+			}
+		}
+		return store.RecordErrors(StateNoData, missing, reasons)
+	})
+
+	sources := elevationSources(config, names)
+	if consensus != nil {
+		sources = append(sources, config.Get("ELEVATION_CONSENSUS_URL"))
+	}
+	recordManifestStep(ws, ManifestStep{
+		Step:    StepEnrich,
+		Sources: sources,
+		Counts: categoryCounts(func(key string) int {
+			return len(*enriched.Category(key))
+		}),
+	}, started, []string{filteredFile}, outputs)
 
 	return nil
 }
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// DefaultEnrichCheckpointFile stores enrichment progress so an interrupted run can resume
+const DefaultEnrichCheckpointFile = "output/enrich_checkpoint.json"
+
+// EnrichCheckpoint holds the elements enriched so far for a given input file
+type EnrichCheckpoint struct {
+	path      string
+	InputHash string                `json:"input_hash"`
+	Elements  map[string]OSMElement `json:"elements"`
+}
+
+// LoadEnrichCheckpoint loads the checkpoint at path. A checkpoint written for a different
+// input is discarded and an empty one is returned.
+func LoadEnrichCheckpoint(path, inputHash string) (*EnrichCheckpoint, error) {
+	checkpoint := &EnrichCheckpoint{
+		path:      path,
+		InputHash: inputHash,
+		Elements:  make(map[string]OSMElement),
+	}
+
+	if _, err := os.Stat(path); err != nil {
+		return checkpoint, nil
+	}
+
+	var saved EnrichCheckpoint
+	if err := loadJSON(path, &saved); err != nil {
+		return nil, fmt.Errorf("failed to load enrich checkpoint %s: %v", path, err)
+	}
+
+	if saved.InputHash != inputHash {
+		enrichLog.Warn("Ignoring enrich checkpoint written for a different input file")
+		return checkpoint, nil
+	}
+
+	if saved.Elements != nil {
+		checkpoint.Elements = saved.Elements
+	}
+	return checkpoint, nil
+}
+
+// Lookup returns the enriched version of an element if it was already processed
+func (c *EnrichCheckpoint) Lookup(element OSMElement) (OSMElement, bool) {
+	enriched, ok := c.Elements[elementKey(element.Type, element.ID)]
+	return enriched, ok
+}
+
+// Record stores an enriched element
+func (c *EnrichCheckpoint) Record(element OSMElement) {
+	c.Elements[elementKey(element.Type, element.ID)] = element
+}
+
+// Collect returns the enriched elements in input order, honouring maxCount
+func (c *EnrichCheckpoint) Collect(elements []OSMElement, maxCount int) []OSMElement {
+	var collected []OSMElement
+	for i, element := range elements {
+		if maxCount > 0 && i >= maxCount {
+			break
+		}
+		if enriched, ok := c.Lookup(element); ok {
+			collected = append(collected, enriched)
+		}
+	}
+	return collected
+}
+
+// Save writes the checkpoint to disk
+func (c *EnrichCheckpoint) Save() error {
+	return saveJSON(c.path, c)
+}
+
+// Remove deletes the checkpoint file after a completed run
+func (c *EnrichCheckpoint) Remove() error {
+	if err := os.Remove(c.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// fileHash returns the SHA-256 of a file's contents
+func fileHash(path string) (string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:]), nil
+}
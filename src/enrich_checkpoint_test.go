@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestEnrichResumesFromCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enrich_checkpoint.json")
+
+	checkpoint, err := LoadEnrichCheckpoint(path, "hash-a")
+	if err != nil {
+		t.Fatalf("LoadEnrichCheckpoint() error = %v", err)
+	}
+	restoredElevation := 850.0
+	checkpoint.Record(OSMElement{
+		Type:             "node",
+		ID:               1,
+		Tags:             map[string]string{"ele": "850.0"},
+		ElevationFetched: &restoredElevation,
+	})
+	if err := checkpoint.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	resumed, err := LoadEnrichCheckpoint(path, "hash-a")
+	if err != nil {
+		t.Fatalf("LoadEnrichCheckpoint() reload error = %v", err)
+	}
+
+	provider := &fakeBatchProvider{elevations: map[int64]float64{2: 420}}
+	enricher := NewBatchElevationEnricher("opentopo", 0, 100)
+	enricher.Provider = provider
+	enricher.Checkpoint = resumed
+
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.1, Lon: 25.1},
+		{Type: "node", ID: 2, Lat: 45.2, Lon: 25.2},
+	}
+	enriched, err := enricher.EnrichElementsBatch(context.Background(), elements, 0)
+	if err != nil {
+		t.Fatalf("EnrichElementsBatch() error = %v", err)
+	}
+
+	if len(enriched) != 2 {
+		t.Fatalf("Expected 2 enriched elements, got %d", len(enriched))
+	}
+	if enriched[0].ID != 1 || *enriched[0].ElevationFetched != 850 {
+		t.Errorf("Expected restored element 1 first, got %+v", enriched[0])
+	}
+	if enriched[1].ID != 2 || *enriched[1].ElevationFetched != 420 {
+		t.Errorf("Expected fetched element 2 second, got %+v", enriched[1])
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected a single provider call, got %d", provider.calls)
+	}
+}
+
+func TestEnrichCheckpointIgnoresDifferentInput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enrich_checkpoint.json")
+
+	checkpoint, _ := LoadEnrichCheckpoint(path, "hash-a")
+	checkpoint.Record(OSMElement{Type: "node", ID: 1})
+	if err := checkpoint.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	other, err := LoadEnrichCheckpoint(path, "hash-b")
+	if err != nil {
+		t.Fatalf("LoadEnrichCheckpoint() error = %v", err)
+	}
+	if len(other.Elements) != 0 {
+		t.Errorf("Expected empty checkpoint for different input, got %d elements", len(other.Elements))
+	}
+
+	if err := other.Remove(); err != nil {
+		t.Errorf("Remove() error = %v", err)
+	}
+	if err := other.Remove(); err != nil {
+		t.Errorf("Remove() on missing file error = %v", err)
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestParseCategoryLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]int
+		wantErr bool
+	}{
+		{"Empty", "", map[string]int{}, false},
+		{"Several", "alpine_huts=0, other_accommodations=50", map[string]int{"alpine_huts": 0, "other_accommodations": 50}, false},
+		{"Later wins", "peaks=10,peaks=20", map[string]int{"peaks": 20}, false},
+		{"Unknown category", "hotels=5", nil, true},
+		{"Negative", "peaks=-1", nil, true},
+		{"Not a number", "peaks=all", nil, true},
+		{"No count", "peaks", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCategoryLimits(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCategoryLimits(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCategoryLimits(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCategoryLimitFlags(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+	t.Setenv("ENRICH_LIMITS", "peaks=5")
+
+	fs := flag.NewFlagSet("enrich", flag.ContinueOnError)
+	apply := registerCategoryLimitFlags(fs)
+	if err := fs.Parse([]string{"--limit-alpine-huts", "0", "--limit-accommodations", "50"}); err != nil {
+		t.Fatal(err)
+	}
+	apply()
+
+	config := NewConfig()
+	config.LoadFromEnv()
+	got, err := parseCategoryLimits(config.Get("ENRICH_LIMITS"))
+	if err != nil {
+		t.Fatalf("parseCategoryLimits() error = %v", err)
+	}
+	want := map[string]int{"peaks": 5, "alpine_huts": 0, "other_accommodations": 50}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("limits = %v, want %v", got, want)
+	}
+}
@@ -30,6 +30,21 @@ func NewError(operation string, err error, context map[string]interface{}) *Erro
 	}
 }
 
+// APIError describes a non-success HTTP response from an upstream API
+type APIError struct {
+	Operation  string
+	StatusCode int
+	Body       string
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("%s: status code %d", e.Operation, e.StatusCode)
+	}
+	return fmt.Sprintf("%s: status code %d: %s", e.Operation, e.StatusCode, e.Body)
+}
+
 // WrapError wraps an error with an operation description
 func WrapError(operation string, err error) error {
 	if err == nil {
@@ -1,29 +1,64 @@
 package main
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 )
 
 type OverpassExtractor struct {
 	OverpassURL string
+	// Mirrors are further Overpass instances tried when OverpassURL is overloaded
+	Mirrors []string
+	Country string
+	// NewerThan restricts queries to elements created or modified after this timestamp (incremental mode)
+	NewerThan string
+	// Pending lists the IDs per element type still in progress from earlier runs. Incremental
+	// queries select them whether or not they changed since NewerThan.
+	Pending map[string][]int64
+	// DataTimestamp is the OSM base timestamp reported by Overpass for the extracted data
+	DataTimestamp string
+	// Area restricts queries to a bbox or boundary relation instead of the country area
+	Area AreaSelector
+	// WithEle selects elements that already have ele instead of those missing it (--audit)
+	WithEle bool
+	// AnyEle selects elements regardless of ele, for the overwrite-if-differs policy
+	AnyEle bool
+	// Queries holds the category queries sent, for the run manifest
+	Queries []string
+}
+
+// ExtractOptions configures the extract step
+type ExtractOptions struct {
 	Country     string
+	Incremental bool
+	Area        AreaSelector
+	Workspace   Workspace
 }
 
 type OSMElement struct {
-	Type             string            `json:"type"`
-	ID               int64             `json:"id"`
-	Lat              float64           `json:"lat,omitempty"`
-	Lon              float64           `json:"lon,omitempty"`
-	Center           *OSMCenter        `json:"center,omitempty"`
-	Tags             map[string]string `json:"tags,omitempty"`
-	ElevationFetched *float64          `json:"elevation_fetched,omitempty"`
+	Type              string              `json:"type"`
+	ID                int64               `json:"id"`
+	Version           int                 `json:"version,omitempty"`
+	Lat               float64             `json:"lat,omitempty"`
+	Lon               float64             `json:"lon,omitempty"`
+	Center            *OSMCenter          `json:"center,omitempty"`
+	Tags              map[string]string   `json:"tags,omitempty"`
+	ElevationFetched  *float64            `json:"elevation_fetched,omitempty"`
+	ElevationProvider string              `json:"elevation_provider,omitempty"`
+	Provenance        []ElementProvenance `json:"provenance,omitempty"`
+	// Slope is the terrain slope in degrees around the element and ElevationConfidence
+	// (0-1) how far its DEM elevation can be trusted there; both are set when SLOPE_CHECK is on
+	Slope               *float64 `json:"slope,omitempty"`
+	ElevationConfidence *float64 `json:"elevation_confidence,omitempty"`
 }
 
 type OSMCenter struct {
@@ -32,12 +67,34 @@ type OSMCenter struct {
 }
 
 type OverpassResponse struct {
+	OSM3S struct {
+		TimestampOSMBase string `json:"timestamp_osm_base"`
+	} `json:"osm3s"`
 	Elements []OSMElement `json:"elements"`
 }
 
 type OSMData struct {
+	SchemaHeader
 	TrainStations  []OSMElement `json:"train_stations"`
 	Accommodations []OSMElement `json:"accommodations"`
+	Peaks          []OSMElement `json:"peaks"`
+	Shelters       []OSMElement `json:"shelters"`
+}
+
+// Category returns the raw element list a category is extracted into.
+// Alpine huts and other accommodations share the accommodations list and are split by the filter step.
+func (d *OSMData) Category(key string) *[]OSMElement {
+	switch key {
+	case "peaks":
+		return &d.Peaks
+	case "alpine_huts", "other_accommodations":
+		return &d.Accommodations
+	case "shelters":
+		return &d.Shelters
+	case "train_stations":
+		return &d.TrainStations
+	}
+	return nil
 }
 
 func NewOverpassExtractor(country string) *OverpassExtractor {
@@ -47,21 +104,11 @@ func NewOverpassExtractor(country string) *OverpassExtractor {
 	}
 }
 
-// escapeCountryName escapes double quotes in country name to prevent query injection
-func escapeCountryName(country string) string {
-	return strings.ReplaceAll(country, `"`, `\"`)
-}
-
-func (e *OverpassExtractor) queryOverpass(query string) ([]OSMElement, error) {
-	client := &http.Client{
-		Timeout: 5 * time.Minute,
+func (e *OverpassExtractor) queryOverpass(ctx context.Context, query string) ([]OSMElement, error) {
+	if err := sharedBudget().AcquireContext(ctx, BudgetOverpass); err != nil {
+		return nil, err
 	}
-
-	resp, err := client.Post(
-		e.OverpassURL,
-		"application/x-www-form-urlencoded",
-		bytes.NewBufferString("data="+query),
-	)
+	resp, err := e.postQuery(ctx, query, 5*time.Minute)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query Overpass API: %v", err)
 	}
@@ -77,141 +124,333 @@ func (e *OverpassExtractor) queryOverpass(query string) ([]OSMElement, error) {
 		return nil, fmt.Errorf("failed to decode response: %v", err)
 	}
 
+	// Keep the oldest base timestamp so no edit between queries is missed
+	if ts := result.OSM3S.TimestampOSMBase; ts != "" && (e.DataTimestamp == "" || ts < e.DataTimestamp) {
+		e.DataTimestamp = ts
+	}
+
 	return result.Elements, nil
 }
 
-func (e *OverpassExtractor) GetTrainStations() ([]OSMElement, error) {
-	escapedCountry := escapeCountryName(e.Country)
-	query := fmt.Sprintf(`
-[out:json][timeout:180];
-area["name"="%s"]["admin_level"="2"]->.country;
-(
-  node["railway"="station"]["ele"!~".*"](area.country);
-  node["railway"="halt"]["ele"!~".*"](area.country);
-);
-out body;
-`, escapedCountry)
-
-	fmt.Printf("Querying train stations in %s...\n", e.Country)
-	elements, err := e.queryOverpass(query)
+// postOverpass sends a query to an Overpass interpreter; canceling ctx aborts the request
+func postOverpass(ctx context.Context, client HTTPClient, endpoint, query string) (*http.Response, error) {
+	// The query is form-encoded so that "&", "+" and "%" in names reach Overpass unchanged
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(url.Values{"data": {query}}.Encode()))
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return client.Do(req)
+}
 
-	fmt.Printf("Found %d train stations\n", len(elements))
-	return elements, nil
+// newerFilter returns the Overpass newer: filter used in incremental mode
+func (e *OverpassExtractor) newerFilter() string {
+	if e.NewerThan == "" {
+		return ""
+	}
+	return fmt.Sprintf(`(newer:"%s")`, e.NewerThan)
 }
 
-func (e *OverpassExtractor) GetAccommodations() ([]OSMElement, error) {
-	escapedCountry := escapeCountryName(e.Country)
-	query := fmt.Sprintf(`
+// pendingSets returns the statements collecting the pending elements into the sets read by
+// categoryStatements; they are only queried in incremental mode
+func (e *OverpassExtractor) pendingSets() string {
+	if e.NewerThan == "" {
+		return ""
+	}
+	var sets strings.Builder
+	for _, elementType := range []string{"node", "way", "relation"} {
+		ids := e.Pending[elementType]
+		if len(ids) == 0 {
+			continue
+		}
+		list := make([]string, len(ids))
+		for i, id := range ids {
+			list[i] = strconv.FormatInt(id, 10)
+		}
+		fmt.Fprintf(&sets, "%s(id:%s)->.pending_%s;\n", elementType, strings.Join(list, ","), elementType)
+	}
+	return sets.String()
+}
+
+// categoryQuery builds the Overpass query for a profile category's elements missing ele (or having it, with WithEle)
+func (e *OverpassExtractor) categoryQuery(cat ProfileCategory) string {
+	setup, area := e.Area.overpassFilter(e.Country)
+	eleFilter := `["ele"!~".*"]`
+	if e.WithEle {
+		eleFilter = `["ele"]`
+	} else if e.AnyEle {
+		eleFilter = ""
+	}
+
+	return fmt.Sprintf(`
 [out:json][timeout:300];
-area["name"="%s"]["admin_level"="2"]->.country;
-(
-  node["tourism"="hotel"]["ele"!~".*"](area.country);
-  node["tourism"="guest_house"]["ele"!~".*"](area.country);
-  node["tourism"="alpine_hut"]["ele"!~".*"](area.country);
-  node["tourism"="chalet"]["ele"!~".*"](area.country);
-  node["tourism"="hostel"]["ele"!~".*"](area.country);
-  node["tourism"="motel"]["ele"!~".*"](area.country);
-  way["tourism"="hotel"]["ele"!~".*"](area.country);
-  way["tourism"="guest_house"]["ele"!~".*"](area.country);
-  way["tourism"="alpine_hut"]["ele"!~".*"](area.country);
-  way["tourism"="chalet"]["ele"!~".*"](area.country);
-  way["tourism"="hostel"]["ele"!~".*"](area.country);
-  way["tourism"="motel"]["ele"!~".*"](area.country);
+%s%s(
+%s
 );
-out center;
-`, escapedCountry)
+out center meta;
+`, setup, e.pendingSets(), e.categoryStatements(cat, eleFilter, area))
+}
 
-	fmt.Printf("Querying accommodations in %s...\n", e.Country)
-	elements, err := e.queryOverpass(query)
+// categoryStatements returns the union statements selecting a category's elements in an area
+func (e *OverpassExtractor) categoryStatements(cat ProfileCategory, eleFilter, area string) string {
+	var statements []string
+	for _, elementType := range cat.elementTypes() {
+		for _, selector := range cat.selectors() {
+			statements = append(statements, fmt.Sprintf(`  %s%s%s%s%s;`,
+				elementType, selector.overpassFilter(), eleFilter, area, e.newerFilter()))
+			if e.NewerThan != "" && len(e.Pending[elementType]) > 0 {
+				statements = append(statements, fmt.Sprintf(`  %s.pending_%s%s%s%s;`,
+					elementType, elementType, selector.overpassFilter(), eleFilter, area))
+			}
+		}
+	}
+	return strings.Join(statements, "\n")
+}
+
+// renderedQueries returns the category queries an extract of the area sends, without the
+// incremental newer: filter, so the run command can tell when they changed
+func renderedQueries(country string, area AreaSelector) []string {
+	config := NewConfig()
+	config.LoadFromEnv()
+	extractor := &OverpassExtractor{
+		Country: country,
+		Area:    area,
+		AnyEle:  overwritePolicyOrDefault(config).Mode == OverwriteIfDiffers,
+	}
+	var queries []string
+	for _, cat := range activeProfile().Categories {
+		queries = append(queries, extractor.categoryQuery(cat))
+	}
+	return queries
+}
+
+// GetCategory queries the elements of a profile category that are missing ele
+func (e *OverpassExtractor) GetCategory(ctx context.Context, cat ProfileCategory) ([]OSMElement, error) {
+	label := strings.ToLower(cat.Label)
+	extractLog.Info("Querying %s in %s...", label, e.Area.Describe(e.Country))
+	query := e.categoryQuery(cat)
+	e.Queries = append(e.Queries, query)
+	elements, err := e.queryOverpass(ctx, query)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Printf("Found %d accommodations\n", len(elements))
+	extractLog.Info("Found %d %s", len(elements), label)
 	return elements, nil
 }
 
-func (e *OverpassExtractor) GetAllData() (*OSMData, error) {
-	stations, err := e.GetTrainStations()
-	if err != nil {
-		return nil, err
+// GetAllData queries every category of the active profile
+func (e *OverpassExtractor) GetAllData(ctx context.Context) (*OSMData, error) {
+	data := &OSMData{
+		TrainStations:  []OSMElement{},
+		Accommodations: []OSMElement{},
+		Peaks:          []OSMElement{},
+		Shelters:       []OSMElement{},
 	}
 
-	// Be nice to Overpass API
-	time.Sleep(2 * time.Second)
+	for i, cat := range activeProfile().Categories {
+		if i > 0 {
+			// Be nice to Overpass API
+			if err := sleepContext(ctx, 2*time.Second); err != nil {
+				return nil, err
+			}
+		}
 
-	accommodations, err := e.GetAccommodations()
-	if err != nil {
-		return nil, err
+		elements, err := e.GetCategory(ctx, cat)
+		if err != nil {
+			return nil, err
+		}
+		bucket := data.Category(cat.Key)
+		*bucket = append(*bucket, elements...)
 	}
 
-	return &OSMData{
-		TrainStations:  stations,
-		Accommodations: accommodations,
-	}, nil
+	return data, nil
 }
 
-func runExtract(country string) error {
-	fmt.Println("\n" + string(repeat('=', 60)))
-	fmt.Printf("STEP 1: EXTRACT - Querying Overpass API for %s\n", country)
-	fmt.Println(string(repeat('=', 60)))
+func runExtract(ctx context.Context, opts ExtractOptions) error {
+	country := opts.Country
+	extractLog.Banner("STEP 1: EXTRACT - Querying Overpass API for %s", opts.Area.Describe(country))
+	started := time.Now()
+	if err := runStepStartHook(opts.Workspace, StepExtract, country); err != nil {
+		return err
+	}
 
 	// Initialize configuration and factory
 	config := NewConfig()
 	config.LoadFromEnv()
 	config.Set("COUNTRY", country)
+	if _, err := resolveOverwritePolicy(config); err != nil {
+		return err
+	}
 	logger := NewLogger("Extractor")
 	factory := NewAPIClientFactory(config, logger)
 
+	ledger, err := LoadRunLedger(opts.Workspace.File(DefaultRunLedgerFile))
+	if err != nil {
+		return err
+	}
+	state := ledger.Country(opts.Area.LedgerKey(country))
+
 	// Create extractor using factory
 	extractor := factory.CreateOverpassExtractor()
-	data, err := extractor.GetAllData()
+	extractor.Area = opts.Area
+	if opts.Incremental {
+		if state.LastSuccess == "" {
+			extractLog.Info("Incremental mode: no previous successful run recorded, extracting everything")
+		} else {
+			extractLog.Info("Incremental mode: only elements created or modified since %s", state.LastSuccess)
+			extractor.NewerThan = state.LastSuccess
+			extractor.Pending = pendingElements(opts.Workspace)
+		}
+	}
+
+	data, err := extractor.GetAllData(ctx)
 	if err != nil {
 		return err
 	}
+	// A bbox or relation is not tied to the country, so only name and code lookups are checked
+	if config.GetBool("COUNTRY_CHECK") && opts.Area.BBox == nil && opts.Area.RelationID == 0 {
+		if err := extractor.CheckCountryMembership(ctx, data); err != nil {
+			return err
+		}
+	}
+	if opts.Incremental {
+		skipUnchangedElements(opts.Workspace, data)
+	}
 
 	// Save to file
-	if err := saveJSON("output/osm_data_raw.json", data); err != nil {
+	rawFile := opts.Workspace.File(DefaultRawDataFile)
+	if err := savePipelineFile(rawFile, SchemaRaw, data); err != nil {
 		return err
 	}
 
-	fmt.Printf("\n✓ Extracted %d train stations\n", len(data.TrainStations))
-	fmt.Printf("✓ Extracted %d accommodations\n", len(data.Accommodations))
-	fmt.Println("✓ Data saved to output/osm_data_raw.json")
+	state.LastExtract = extractor.DataTimestamp
+	if state.LastExtract == "" {
+		state.LastExtract = time.Now().UTC().Format(time.RFC3339)
+	}
+	if err := ledger.Save(); err != nil {
+		return err
+	}
+
+	for _, cat := range activeProfile().Categories {
+		extractLog.Info("✓ Extracted %d %s", len(*data.Category(cat.Key)), strings.ToLower(cat.Label))
+	}
+	extractLog.Info("✓ Data saved to %s", rawFile)
+
+	recordPipelineState(opts.Workspace, func(store *PipelineStore) error {
+		return store.Record(StateExtracted, map[string][]OSMElement{
+			"train_stations": data.TrainStations,
+			"accommodations": data.Accommodations,
+			"peaks":          data.Peaks,
+			"shelters":       data.Shelters,
+		})
+	})
+	recordManifestStep(opts.Workspace, ManifestStep{
+		Step:    StepExtract,
+		Country: country,
+		Sources: []string{extractor.OverpassURL},
+		Queries: extractor.Queries,
+		Counts: map[string]int{
+			"train_stations": len(data.TrainStations),
+			"accommodations": len(data.Accommodations),
+			"peaks":          len(data.Peaks),
+			"shelters":       len(data.Shelters),
+		},
+	}, started, nil, []string{rawFile})
 
 	return nil
 }
 
+// pendingStates are the pipeline store states of elements an earlier run left unfinished,
+// e.g. skipped by a drip-feed pause or the API budget, or failed to upload
+var pendingStates = []string{StateFiltered, StateEnriched, StateValidated, StateFailed}
+
+// pendingElements returns the IDs per element type of the unfinished elements in the pipeline
+// store. Incremental runs query them again, as newer: no longer selects them when unchanged.
+func pendingElements(ws Workspace) map[string][]int64 {
+	store, err := OpenPipelineStore(ws.File(DefaultPipelineStoreFile))
+	if err != nil {
+		extractLog.Warn("%v, not re-querying unfinished elements", err)
+		return nil
+	}
+	defer store.Close()
+	pending, err := store.ElementIDs(pendingStates...)
+	if err != nil {
+		extractLog.Warn("%v, not re-querying unfinished elements", err)
+		return nil
+	}
+	if count := len(pending["node"]) + len(pending["way"]) + len(pending["relation"]); count > 0 {
+		extractLog.Info("Incremental mode: also querying %d elements left unfinished by earlier runs", count)
+	}
+	return pending
+}
+
+// skipUnchangedElements drops elements the pipeline store already finished with at the same
+// OSM version: uploaded ones and ones that failed validation, which would fail again
+func skipUnchangedElements(ws Workspace, data *OSMData) {
+	store, err := OpenPipelineStore(ws.File(DefaultPipelineStoreFile))
+	if err != nil {
+		extractLog.Warn("%v, keeping all extracted elements", err)
+		return
+	}
+	defer store.Close()
+	known, err := store.KnownVersions(StateUploaded, StateInvalid)
+	if err != nil {
+		extractLog.Warn("%v, keeping all extracted elements", err)
+		return
+	}
+
+	skipped := 0
+	for _, bucket := range []*[]OSMElement{&data.TrainStations, &data.Accommodations, &data.Peaks, &data.Shelters} {
+		kept := (*bucket)[:0]
+		for _, element := range *bucket {
+			if version, ok := known[elementKey(element.Type, element.ID)]; ok && version == element.Version {
+				skipped++
+				continue
+			}
+			kept = append(kept, element)
+		}
+		*bucket = kept
+	}
+	if skipped > 0 {
+		extractLog.Info("Incremental mode: skipped %d elements unchanged since they were uploaded or rejected by validation", skipped)
+	}
+}
+
 // CountryInfo holds information about a country
 type CountryInfo struct {
 	Name    string `json:"name"`
 	IntName string `json:"int_name,omitempty"`
+	ISOCode string `json:"iso_code,omitempty"`
 }
 
-// fetchAllCountries queries the Overpass API and returns a sorted list of countries
-func fetchAllCountries() ([]CountryInfo, error) {
-	extractor := &OverpassExtractor{
-		OverpassURL: "https://overpass-api.de/api/interpreter",
-	}
-
-	query := `
+// lookupCountryByCode returns the country with the given ISO3166-1 code
+func lookupCountryByCode(ctx context.Context, code string) (CountryInfo, error) {
+	countries, err := queryCountries(ctx, fmt.Sprintf(`
 [out:json][timeout:60];
-area["admin_level"="2"];
+area%s["admin_level"="2"];
 out tags;
-`
+`, overpassTagFilter("ISO3166-1", code)))
+	if err != nil {
+		return CountryInfo{}, err
+	}
+	if len(countries) == 0 {
+		return CountryInfo{}, fmt.Errorf("no admin_level=2 area with ISO3166-1=%s", code)
+	}
+	return countries[0], nil
+}
 
-	client := &http.Client{
-		Timeout: 2 * time.Minute,
+// queryCountries runs an Overpass query for admin_level=2 areas and returns them sorted by name
+func queryCountries(ctx context.Context, query string) ([]CountryInfo, error) {
+	config := NewConfig()
+	config.LoadFromEnv()
+	extractor := NewAPIClientFactory(config, NewLogger("Extractor")).CreateOverpassExtractor()
+
+	if err := sharedBudget().AcquireContext(ctx, BudgetOverpass); err != nil {
+		return nil, err
 	}
 
-	resp, err := client.Post(
-		extractor.OverpassURL,
-		"application/x-www-form-urlencoded",
-		bytes.NewBufferString("data="+query),
-	)
+	resp, err := extractor.postQuery(ctx, query, 2*time.Minute)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query Overpass API: %v", err)
 	}
@@ -242,6 +481,10 @@ out tags;
 			if intName, ok := element.Tags["int_name"]; ok && intName != "" {
 				country.IntName = intName
 			}
+			country.ISOCode = element.Tags["ISO3166-1"]
+			if country.ISOCode == "" {
+				country.ISOCode = element.Tags["ISO3166-1:alpha2"]
+			}
 			countriesMap[name] = country
 		}
 	}
@@ -260,15 +503,27 @@ out tags;
 	return countries, nil
 }
 
-// runListCountries queries and lists all available admin_level=2 countries
-func runListCountries() error {
+// runListCountries lists all available admin_level=2 countries. The json and csv formats
+// write only the list to stdout so scripts can consume it.
+func runListCountries(ctx context.Context, format string, refresh bool) error {
+	if err := checkCountryFormat(format); err != nil {
+		return err
+	}
+	if format != CountryFormatText {
+		countries, err := fetchAllCountries(ctx, refresh)
+		if err != nil {
+			return err
+		}
+		return writeCountries(os.Stdout, countries, format)
+	}
+
 	fmt.Println("\n" + string(repeat('=', 60)))
 	fmt.Println("Available Countries (admin_level=2)")
 	fmt.Println(string(repeat('=', 60)))
 
-	fmt.Println("Querying Overpass API for all countries...")
+	extractLog.Info("Loading the country list...")
 	
-	countries, err := fetchAllCountries()
+	countries, err := fetchAllCountries(ctx, refresh)
 	if err != nil {
 		return err
 	}
@@ -276,15 +531,21 @@ func runListCountries() error {
 	fmt.Printf("\nFound %d countries:\n\n", len(countries))
 	
 	// Display in columns
+	fmt.Printf("  %-4s %s\n", "ISO", "Name")
 	for _, country := range countries {
+		code := country.ISOCode
+		if code == "" {
+			code = "-"
+		}
 		if country.IntName != "" && country.IntName != country.Name {
-			fmt.Printf("  %-40s (int_name: %s)\n", country.Name, country.IntName)
+			fmt.Printf("  %-4s %-40s (int_name: %s)\n", code, country.Name, country.IntName)
 		} else {
-			fmt.Printf("  %s\n", country.Name)
+			fmt.Printf("  %-4s %s\n", code, country.Name)
 		}
 	}
 
 	fmt.Println("\nUsage: elevate-romania --country \"Country Name\" --extract")
+	fmt.Println("   or: elevate-romania --country-code RO --extract")
 	fmt.Println("Note: Use the exact name (case-sensitive) as shown above")
 	fmt.Println("\n" + string(repeat('=', 60)) + "\n")
 
@@ -5,7 +5,7 @@ import (
 	"testing"
 )
 
-func TestEscapeCountryName(t *testing.T) {
+func TestEscapeOverpassString(t *testing.T) {
 	tests := []struct {
 		name     string
 		input    string
@@ -31,11 +31,31 @@ func TestEscapeCountryName(t *testing.T) {
 			input:    "",
 			expected: "",
 		},
+		{
+			name:     "Apostrophe",
+			input:    "Côte d'Ivoire",
+			expected: `Côte d\'Ivoire`,
+		},
+		{
+			name:     "Backslash before quote",
+			input:    `Name\"]; out;`,
+			expected: `Name\\\"]; out;`,
+		},
+		{
+			name:     "Brackets and regex characters",
+			input:    "Saint (Kitts) [and] Nevis.*",
+			expected: "Saint (Kitts) [and] Nevis.*",
+		},
+		{
+			name:     "Control characters",
+			input:    "Line\nBreak\tTab\x00",
+			expected: `Line\nBreak\tTab\u0000`,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := escapeCountryName(tt.input)
+			result := escapeOverpassString(tt.input)
 			if result != tt.expected {
 				t.Errorf("Expected %q, got %q", tt.expected, result)
 			}
@@ -134,3 +154,45 @@ func TestNewOverpassExtractor(t *testing.T) {
 		}
 	})
 }
+
+func TestCategoryQueryBBox(t *testing.T) {
+	extractor := NewOverpassExtractor("România")
+	extractor.Area = AreaSelector{BBox: &BoundingBox{MinLat: 45.2, MinLon: 22.5, MaxLat: 47.8, MaxLon: 26.5}}
+	cat, _ := DefaultProfile().Category("peaks")
+
+	query := extractor.categoryQuery(cat)
+	if strings.Contains(query, "area") {
+		t.Errorf("Expected no area filter in bbox query:\n%s", query)
+	}
+	if !strings.Contains(query, `node["natural"="peak"]["ele"!~".*"](45.2,22.5,47.8,26.5);`) {
+		t.Errorf("Expected bbox filter in query:\n%s", query)
+	}
+	if extractor.Area.LedgerKey("România") == "România" {
+		t.Error("Expected bbox runs to use their own ledger key")
+	}
+}
+
+func TestCategoryQueryIncludesPendingElements(t *testing.T) {
+	extractor := NewOverpassExtractor("România")
+	extractor.Pending = map[string][]int64{"node": {5, 9}, "relation": {7}}
+	cat, _ := DefaultProfile().Category("peaks")
+
+	if query := extractor.categoryQuery(cat); strings.Contains(query, "pending") {
+		t.Errorf("Expected no pending sets outside incremental mode:\n%s", query)
+	}
+
+	extractor.NewerThan = "2024-05-01T10:00:00Z"
+	query := extractor.categoryQuery(cat)
+	for _, want := range []string{
+		"node(id:5,9)->.pending_node;",
+		`node["natural"="peak"]["ele"!~".*"](area.country)(newer:"2024-05-01T10:00:00Z");`,
+		`node.pending_node["natural"="peak"]["ele"!~".*"](area.country);`,
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Query missing %q:\n%s", want, query)
+		}
+	}
+	if strings.Index(query, "->.pending_node;") > strings.Index(query, "(\n") {
+		t.Errorf("Expected the pending sets before the union:\n%s", query)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -25,11 +27,21 @@ func (f *APIClientFactory) CreateElevationEnricher(apiType string) *ElevationEnr
 	if rateLimit == 0 {
 		rateLimit = 1000 // Default 1 second
 	}
-	
+
+	timeout := time.Duration(f.config.GetInt("API_TIMEOUT_SEC")) * time.Second
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+
+	format := elevationFormatOrDefault(f.config)
+	source := elevationSourceOrDefault(f.config)
 	e := &ElevationEnricher{
 		APIType:        apiType,
 		RateLimit:      time.Duration(rateLimit * float64(time.Millisecond)),
+		Format:         &format,
+		Source:         &source,
 		coordExtractor: NewCoordinateExtractor(),
+		httpClient:     NewRetryingReadClient(timeout),
 	}
 	
 	// Use configured URL or default
@@ -39,9 +51,12 @@ func (f *APIClientFactory) CreateElevationEnricher(apiType string) *ElevationEnr
 			e.BaseURL = "https://api.opentopodata.org/v1/srtm30m"
 		}
 	} else {
-		e.BaseURL = "https://api.open-elevation.com/api/v1/lookup"
+		e.BaseURL = f.config.Get("OPEN_ELEVATION_URL")
+		if e.BaseURL == "" {
+			e.BaseURL = "https://api.open-elevation.com/api/v1/lookup"
+		}
 	}
-	
+
 	return e
 }
 
@@ -62,14 +77,16 @@ func (f *APIClientFactory) CreateBatchElevationEnricher(apiType string) *BatchEl
 		timeout = 30 * time.Second
 	}
 	
+	format := elevationFormatOrDefault(f.config)
+	source := elevationSourceOrDefault(f.config)
 	e := &BatchElevationEnricher{
 		APIType:        apiType,
 		RateLimit:      time.Duration(rateLimit * float64(time.Millisecond)),
 		BatchSize:      batchSize,
+		Format:         &format,
+		Source:         &source,
 		coordExtractor: NewCoordinateExtractor(),
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+		httpClient:     NewRetryingReadClient(timeout),
 	}
 	
 	// Use configured URL or default
@@ -79,12 +96,117 @@ func (f *APIClientFactory) CreateBatchElevationEnricher(apiType string) *BatchEl
 			e.BaseURL = "https://api.opentopodata.org/v1/srtm30m"
 		}
 	} else {
-		e.BaseURL = "https://api.open-elevation.com/api/v1/lookup"
+		e.BaseURL = f.config.Get("OPEN_ELEVATION_URL")
+		if e.BaseURL == "" {
+			e.BaseURL = "https://api.open-elevation.com/api/v1/lookup"
+		}
 	}
-	
+
 	return e
 }
 
+// CreateElevationProviderChain creates the elevation fallback chain from ELEVATION_PROVIDERS
+func (f *APIClientFactory) CreateElevationProviderChain() (*ElevationProviderChain, error) {
+	providers := f.config.Get("ELEVATION_PROVIDERS")
+	if providers == "" {
+		providers = ProviderOpenTopo
+		if f.config.Get("ELEVATION_TILE_DIR") != "" {
+			providers = ProviderHGT
+		}
+	}
+
+	names, err := parseProviderNames(providers)
+	if err != nil {
+		return nil, err
+	}
+
+	ellipsoidal, err := f.ellipsoidalProviders(names)
+	if err != nil {
+		return nil, err
+	}
+	var grid *GeoidGrid
+	gridFile := f.config.Get("GEOID_GRID_FILE")
+	if len(ellipsoidal) > 0 {
+		if grid, err = LoadGeoidGrid(gridFile); err != nil {
+			return nil, err
+		}
+	}
+
+	chain := &ElevationProviderChain{}
+	var sources []string
+	for _, name := range names {
+		var provider BatchElevationProvider
+		var dataset string
+		switch name {
+		case ProviderHGT:
+			tileDir := f.config.Get("ELEVATION_TILE_DIR")
+			if tileDir == "" {
+				return nil, fmt.Errorf("elevation provider %q requires ELEVATION_TILE_DIR", name)
+			}
+			hgtProvider := NewHGTElevationProvider(tileDir)
+			provider = hgtProvider
+			chain.closers = append(chain.closers, hgtProvider.Close)
+			dataset = tileDir
+		default:
+			enricher := f.CreateBatchElevationEnricher(name)
+			provider = enricher
+			dataset = enricher.BaseURL
+		}
+		source := fmt.Sprintf("%s(%s)", name, dataset)
+		if ellipsoidal[name] {
+			provider = NewGeoidCorrectedProvider(provider, grid)
+			source += "+geoid(" + gridFile + ")"
+		}
+		chain.Add(name, provider)
+		sources = append(sources, source)
+	}
+	chain.source = strings.Join(sources, ",")
+
+	return chain, nil
+}
+
+// ellipsoidalProviders returns the providers listed in GEOID_CORRECTED_PROVIDERS, which must
+// all be part of the chain and need the grid in GEOID_GRID_FILE
+func (f *APIClientFactory) ellipsoidalProviders(chain []string) (map[string]bool, error) {
+	value := f.config.Get("GEOID_CORRECTED_PROVIDERS")
+	if value == "" || value == "none" {
+		return nil, nil
+	}
+	names, err := parseProviderNames(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid GEOID_CORRECTED_PROVIDERS: %v", err)
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range names {
+		found := false
+		for _, c := range chain {
+			found = found || c == name
+		}
+		if !found {
+			return nil, fmt.Errorf("GEOID_CORRECTED_PROVIDERS lists %q, which is not in ELEVATION_PROVIDERS", name)
+		}
+		selected[name] = true
+	}
+	if f.config.Get("GEOID_GRID_FILE") == "" {
+		return nil, fmt.Errorf("GEOID_CORRECTED_PROVIDERS requires GEOID_GRID_FILE, a GeographicLib geoid grid such as egm96-5.pgm")
+	}
+	return selected, nil
+}
+
+// CreateConsensusProvider wraps primary in a check against the OpenTopoData dataset at
+// ELEVATION_CONSENSUS_URL, or returns nil when no second dataset is configured
+func (f *APIClientFactory) CreateConsensusProvider(primary BatchElevationProvider) *ConsensusElevationProvider {
+	url := f.config.Get("ELEVATION_CONSENSUS_URL")
+	if url == "" || url == "none" {
+		return nil
+	}
+
+	secondary := f.CreateBatchElevationEnricher(ProviderOpenTopo)
+	secondary.BaseURL = url
+	return NewConsensusElevationProvider(primary, secondary, consensusDatasetName(url), f.config.GetFloat("ELEVATION_CONSENSUS_TOLERANCE_M"))
+}
+
 // CreateOverpassExtractor creates a configured Overpass extractor
 func (f *APIClientFactory) CreateOverpassExtractor() *OverpassExtractor {
 	url := f.config.Get("OVERPASS_URL")
@@ -99,7 +221,9 @@ func (f *APIClientFactory) CreateOverpassExtractor() *OverpassExtractor {
 	
 	return &OverpassExtractor{
 		OverpassURL: url,
+		Mirrors:     parseOverpassMirrors(f.config.Get("OVERPASS_MIRRORS")),
 		Country:     country,
+		AnyEle:      overwritePolicyOrDefault(f.config).Mode == OverwriteIfDiffers,
 	}
 }
 
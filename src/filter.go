@@ -1,36 +1,48 @@
 package main
 
-import (
-	"fmt"
-)
+import "time"
 
 // ElevationFilter filters OSM elements based on elevation and coordinates
 type ElevationFilter struct {
 	coordExtractor  *CoordinateExtractor
 	categorizer     *ElementCategorizer
+	exclusions      []TagSelector
+	policy          OverwritePolicy
+	ids             ElementIDFilter
+	Excluded        int // elements skipped by an exclusion rule in the last FilterData
+	SkippedIDs      int // elements skipped by the --only-ids or --exclude-ids lists in the last FilterData
 }
 
 // FilteredData contains categorized OSM elements
 type FilteredData struct {
+	SchemaHeader
 	TrainStations       []OSMElement `json:"train_stations"`
 	AlpineHuts          []OSMElement `json:"alpine_huts"`
 	OtherAccommodations []OSMElement `json:"other_accommodations"`
+	Peaks               []OSMElement `json:"peaks"`
+	Shelters            []OSMElement `json:"shelters"`
 }
 
-// NewElevationFilter creates a new elevation filter
+// NewElevationFilter creates a new elevation filter using the configured overwrite policy
 func NewElevationFilter() *ElevationFilter {
+	config := NewConfig()
+	config.LoadFromEnv()
+
 	return &ElevationFilter{
 		coordExtractor:  NewCoordinateExtractor(),
 		categorizer:     NewElementCategorizer(),
+		exclusions:      activeProfile().exclusions(),
+		policy:          overwritePolicyOrDefault(config),
 	}
 }
 
-// filterMissingElevation filters elements without elevation data
+// filterMissingElevation filters elements without elevation data, or those the overwrite
+// policy allows to be edited despite having it
 func (f *ElevationFilter) filterMissingElevation(elements []OSMElement) []OSMElement {
 	var result []OSMElement
 
 	for _, element := range elements {
-		if !f.categorizer.HasElevation(element) {
+		if f.policy.Selects(element.Tags) {
 			if f.coordExtractor.HasValidCoordinates(element) {
 				result = append(result, element)
 			}
@@ -40,66 +52,173 @@ func (f *ElevationFilter) filterMissingElevation(elements []OSMElement) []OSMEle
 	return result
 }
 
-// prioritizeAlpineHuts separates alpine huts from other elements
-func (f *ElevationFilter) prioritizeAlpineHuts(elements []OSMElement) ([]OSMElement, []OSMElement) {
-	var alpineHuts []OSMElement
-	var others []OSMElement
+// filterExistingElevation keeps elements that already have elevation data (for --audit)
+func (f *ElevationFilter) filterExistingElevation(elements []OSMElement) []OSMElement {
+	var result []OSMElement
 
 	for _, element := range elements {
-		if f.categorizer.IsAlpineHut(element) {
-			alpineHuts = append(alpineHuts, element)
-		} else {
-			others = append(others, element)
+		if f.categorizer.HasElevation(element) && f.coordExtractor.HasValidCoordinates(element) {
+			result = append(result, element)
 		}
 	}
 
-	return alpineHuts, others
+	return result
+}
+
+// isExcluded reports whether an element matches one of the profile's exclusion rules
+func (f *ElevationFilter) isExcluded(element OSMElement) bool {
+	for _, selector := range f.exclusions {
+		if selector.Matches(element.Tags) {
+			return true
+		}
+	}
+	return false
 }
 
-// FilterData filters OSM data by elevation status and categorizes elements
+// Category returns the filtered elements of a category by its key
+func (d *FilteredData) Category(key string) *[]OSMElement {
+	switch key {
+	case "peaks":
+		return &d.Peaks
+	case "alpine_huts":
+		return &d.AlpineHuts
+	case "shelters":
+		return &d.Shelters
+	case "train_stations":
+		return &d.TrainStations
+	case "other_accommodations":
+		return &d.OtherAccommodations
+	}
+	return nil
+}
+
+// FilterData filters OSM data by elevation status and sorts elements into the
+// active profile's categories. Elements extracted by several queries are kept once,
+// in the highest-priority category they match.
 func (f *ElevationFilter) FilterData(data *OSMData) *FilteredData {
+	return f.categorize(data, f.filterMissingElevation)
+}
+
+// FilterExisting sorts the elements that already have ele into categories, like FilterData
+func (f *ElevationFilter) FilterExisting(data *OSMData) *FilteredData {
+	return f.categorize(data, f.filterExistingElevation)
+}
+
+// categorize sorts the elements kept by keep into categories, skipping duplicates and exclusions
+func (f *ElevationFilter) categorize(data *OSMData, keep func([]OSMElement) []OSMElement) *FilteredData {
 	result := &FilteredData{
 		TrainStations:       []OSMElement{},
 		AlpineHuts:          []OSMElement{},
 		OtherAccommodations: []OSMElement{},
+		Peaks:               []OSMElement{},
+		Shelters:            []OSMElement{},
 	}
 
-	// Filter train stations
-	result.TrainStations = f.filterMissingElevation(data.TrainStations)
+	f.Excluded = 0
+	f.SkippedIDs = 0
+	seen := make(map[string]bool)
+	for _, elements := range [][]OSMElement{data.Peaks, data.Shelters, data.TrainStations, data.Accommodations} {
+		for _, element := range keep(elements) {
+			key := elementKey(element.Type, element.ID)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
 
-	// Filter accommodations and prioritize alpine huts
-	missingEle := f.filterMissingElevation(data.Accommodations)
-	alpineHuts, others := f.prioritizeAlpineHuts(missingEle)
-	result.AlpineHuts = alpineHuts
-	result.OtherAccommodations = others
+			if f.isExcluded(element) {
+				f.Excluded++
+				continue
+			}
+			if !f.ids.Allows(element) {
+				f.SkippedIDs++
+				continue
+			}
+			category := f.categorizer.Categorize(element)
+			if category == CategoryUnknown {
+				continue
+			}
+			bucket := result.Category(categoryToKey(category))
+			*bucket = append(*bucket, element)
+		}
+	}
 
 	return result
 }
 
-func runFilter() error {
-	fmt.Println("\n" + string(repeat('=', 60)))
-	fmt.Println("STEP 2: FILTER - Identifying elements without elevation")
-	fmt.Println(string(repeat('=', 60)))
+func runFilter(ws Workspace) error {
+	filterLog.Banner("STEP 2: FILTER - Identifying elements without elevation")
+	started := time.Now()
+	if err := runStepStartHook(ws, StepFilter, ""); err != nil {
+		return err
+	}
 
 	// Load raw data
 	var data OSMData
-	if err := loadJSON("output/osm_data_raw.json", &data); err != nil {
-		return fmt.Errorf("output/osm_data_raw.json not found. Run --extract first: %v", err)
+	rawFile := ws.File(DefaultRawDataFile)
+	if err := loadPipelineFile(rawFile, SchemaRaw, &data); err != nil {
+		return pipelineLoadError(rawFile, "extract", err)
+	}
+
+	config := NewConfig()
+	config.LoadFromEnv()
+	policy, err := resolveOverwritePolicy(config)
+	if err != nil {
+		return err
+	}
+	switch policy.Mode {
+	case OverwriteIfDiffers:
+		pipelineLog.Info("Overwrite policy: also keeping elements with ele (replaced when the DEM differs by more than %.0f m)", policy.Threshold)
+	case OverwriteNeverTouch:
+		pipelineLog.Info("Overwrite policy: skipping elements with any elevation tag")
+	}
+
+	ids, err := loadElementIDFilter(config)
+	if err != nil {
+		return err
+	}
+	if ids.Active() {
+		pipelineLog.Info("Element IDs: %s", ids.describe())
 	}
 
 	// Filter
 	filter := NewElevationFilter()
+	filter.ids = ids
 	filtered := filter.FilterData(&data)
 
 	// Save filtered data
-	if err := saveJSON("output/osm_data_filtered.json", filtered); err != nil {
+	filteredFile := ws.File(DefaultFilteredDataFile)
+	if err := savePipelineFile(filteredFile, SchemaFiltered, filtered); err != nil {
 		return err
 	}
 
-	fmt.Printf("\n✓ Train stations without elevation: %d\n", len(filtered.TrainStations))
-	fmt.Printf("✓ Alpine huts without elevation: %d (PRIORITY)\n", len(filtered.AlpineHuts))
-	fmt.Printf("✓ Other accommodations without elevation: %d\n", len(filtered.OtherAccommodations))
-	fmt.Println("✓ Filtered data saved to output/osm_data_filtered.json")
+	for _, cat := range activeProfile().Categories {
+		priority := ""
+		if cat.Priority {
+			priority = " (PRIORITY)"
+		}
+		filterLog.Info("✓ %s without elevation: %d%s", cat.Label, len(*filtered.Category(cat.Key)), priority)
+	}
+	if filter.Excluded > 0 {
+		filterLog.Info("✓ Skipped %d underground, indoor or lifecycle-tagged elements (profile exclude rules)", filter.Excluded)
+	}
+	if filter.SkippedIDs > 0 {
+		filterLog.Info("✓ Skipped %d elements by the element ID lists", filter.SkippedIDs)
+	}
+	filterLog.Info("✓ Filtered data saved to %s", filteredFile)
+
+	recordPipelineState(ws, func(store *PipelineStore) error {
+		elements := make(map[string][]OSMElement)
+		for _, key := range categoryKeys {
+			elements[key] = *filtered.Category(key)
+		}
+		return store.Record(StateFiltered, elements)
+	})
+	recordManifestStep(ws, ManifestStep{
+		Step: StepFilter,
+		Counts: categoryCounts(func(key string) int {
+			return len(*filtered.Category(key))
+		}),
+	}, started, []string{rawFile}, []string{filteredFile})
 
 	return nil
 }
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// GeoidGrid holds geoid undulations (the height of the geoid above the WGS84 ellipsoid) in
+// the PGM format of GeographicLib's geoid files (egm96-5.pgm, egm2008-2_5.pgm, ...). Rows run
+// from 90°N to 90°S and columns eastwards from 0°, each stored as offset + scale * value.
+type GeoidGrid struct {
+	width, height int
+	offset, scale float64
+	values        []uint16
+}
+
+// LoadGeoidGrid reads a GeographicLib geoid grid file
+func LoadGeoidGrid(path string) (*GeoidGrid, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoid grid: %v", err)
+	}
+	defer file.Close()
+
+	grid, err := parseGeoidGrid(bufio.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("invalid geoid grid %s: %v", path, err)
+	}
+	return grid, nil
+}
+
+// parseGeoidGrid decodes a binary (P5) 16-bit PGM with "# Offset" and "# Scale" comments
+func parseGeoidGrid(r *bufio.Reader) (*GeoidGrid, error) {
+	grid := &GeoidGrid{scale: 1}
+	var header []int
+
+	magic, err := readPGMToken(r, grid)
+	if err != nil {
+		return nil, err
+	}
+	if magic != "P5" {
+		return nil, fmt.Errorf("not a binary PGM file (magic %q)", magic)
+	}
+	for len(header) < 3 {
+		token, err := readPGMToken(r, grid)
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(token)
+		if err != nil {
+			return nil, fmt.Errorf("invalid header value %q", token)
+		}
+		header = append(header, n)
+	}
+	grid.width, grid.height = header[0], header[1]
+	if grid.width < 2 || grid.height < 2 || header[2] != 65535 {
+		return nil, fmt.Errorf("unsupported grid %dx%d with maximum value %d", grid.width, grid.height, header[2])
+	}
+
+	grid.values = make([]uint16, grid.width*grid.height)
+	if err := binary.Read(r, binary.BigEndian, grid.values); err != nil {
+		return nil, fmt.Errorf("failed to read %dx%d samples: %v", grid.width, grid.height, err)
+	}
+	return grid, nil
+}
+
+// readPGMToken returns the next header token, reading Offset and Scale from comments
+func readPGMToken(r *bufio.Reader, grid *GeoidGrid) (string, error) {
+	var token strings.Builder
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			if err == io.EOF && token.Len() > 0 {
+				return token.String(), nil
+			}
+			return "", fmt.Errorf("truncated header: %v", err)
+		}
+		switch {
+		case c == '#' && token.Len() == 0:
+			line, _ := r.ReadString('\n')
+			fields := strings.Fields(line)
+			if len(fields) == 2 {
+				value, err := strconv.ParseFloat(fields[1], 64)
+				if err == nil && fields[0] == "Offset" {
+					grid.offset = value
+				} else if err == nil && fields[0] == "Scale" {
+					grid.scale = value
+				}
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if token.Len() > 0 {
+				return token.String(), nil
+			}
+		default:
+			token.WriteByte(c)
+		}
+	}
+}
+
+// sample returns the undulation at a grid node, wrapping columns around the globe
+func (g *GeoidGrid) sample(row, col int) float64 {
+	col = ((col % g.width) + g.width) % g.width
+	return g.offset + g.scale*float64(g.values[row*g.width+col])
+}
+
+// Undulation returns the geoid height above the ellipsoid at a location, interpolated bilinearly
+func (g *GeoidGrid) Undulation(lat, lon float64) float64 {
+	latStep := 180 / float64(g.height-1)
+	lonStep := 360 / float64(g.width)
+
+	y := (90 - math.Max(-90, math.Min(90, lat))) / latStep
+	x := math.Mod(lon+360, 360) / lonStep
+	row, col := int(math.Floor(y)), int(math.Floor(x))
+	if row >= g.height-1 {
+		row = g.height - 2
+	}
+	fy, fx := y-float64(row), x-float64(col)
+
+	top := g.sample(row, col)*(1-fx) + g.sample(row, col+1)*fx
+	bottom := g.sample(row+1, col)*(1-fx) + g.sample(row+1, col+1)*fx
+	return top*(1-fy) + bottom*fy
+}
+
+// GeoidCorrectedProvider converts the ellipsoidal heights of a provider into the orthometric
+// (mean sea level) heights OSM expects
+type GeoidCorrectedProvider struct {
+	next BatchElevationProvider
+	grid *GeoidGrid
+}
+
+// NewGeoidCorrectedProvider subtracts the geoid undulation from every elevation of next
+func NewGeoidCorrectedProvider(next BatchElevationProvider, grid *GeoidGrid) *GeoidCorrectedProvider {
+	return &GeoidCorrectedProvider{next: next, grid: grid}
+}
+
+// BatchGetElevations fetches ellipsoidal heights from the wrapped provider and corrects them
+func (p *GeoidCorrectedProvider) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	results, err := p.next.BatchGetElevations(ctx, locations)
+	if err != nil {
+		return nil, err
+	}
+	for i := range results {
+		if i >= len(locations) || results[i].Elevation == nil {
+			continue
+		}
+		orthometric := *results[i].Elevation - p.grid.Undulation(locations[i].Lat, locations[i].Lon)
+		results[i].Elevation = &orthometric
+	}
+	return results, nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeGeoidGrid writes a 90° grid (4 columns, 3 rows) in GeographicLib's PGM format
+// with undulations offset + 0.5 * raw
+func writeGeoidGrid(t *testing.T, raw []uint16) string {
+	t.Helper()
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "P5\n# Description test grid\n# Offset -100\n# Scale 0.5\n4 3\n65535\n")
+	binary.Write(&buf, binary.BigEndian, raw)
+
+	path := filepath.Join(t.TempDir(), "test.pgm")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestGeoidGridUndulation(t *testing.T) {
+	// Rows at 90°N, 0° and 90°S; columns at 0°, 90°E, 180° and 90°W
+	path := writeGeoidGrid(t, []uint16{
+		200, 200, 200, 200,
+		280, 240, 200, 160,
+		220, 220, 220, 220,
+	})
+	grid, err := LoadGeoidGrid(path)
+	if err != nil {
+		t.Fatalf("LoadGeoidGrid() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		want     float64
+	}{
+		{"Grid node", 0, 0, 40},
+		{"Between columns", 0, 45, 30},
+		{"Wraps around 0°", 0, -45, 10},
+		{"Between rows", 45, 0, 20},
+		{"South pole", -90, 10, 10},
+	}
+	for _, tt := range tests {
+		if got := grid.Undulation(tt.lat, tt.lon); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Undulation(%v, %v) = %v, want %v", tt.name, tt.lat, tt.lon, got, tt.want)
+		}
+	}
+
+	if _, err := LoadGeoidGrid(writeGeoidGrid(t, []uint16{1, 2, 3})); err == nil {
+		t.Error("Expected error for a truncated grid")
+	}
+}
+
+func TestGeoidCorrectedProvider(t *testing.T) {
+	path := writeGeoidGrid(t, []uint16{
+		200, 200, 200, 200,
+		280, 280, 280, 280,
+		200, 200, 200, 200,
+	})
+	grid, err := LoadGeoidGrid(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := NewGeoidCorrectedProvider(&fakeBatchProvider{elevations: map[int64]float64{1: 540}}, grid)
+	locations := []LocationRequest{
+		{Lat: 0, Lon: 10, Element: &OSMElement{ID: 1}},
+		{Lat: 0, Lon: 20, Element: &OSMElement{ID: 2}},
+	}
+	results, err := provider.BatchGetElevations(context.Background(), locations)
+	if err != nil {
+		t.Fatalf("BatchGetElevations() error = %v", err)
+	}
+	if results[0].Elevation == nil || *results[0].Elevation != 500 {
+		t.Errorf("Corrected elevation = %v, want 500", results[0].Elevation)
+	}
+	if results[1].Elevation != nil {
+		t.Error("Missing elevations should stay missing")
+	}
+}
+
+func TestCreateElevationProviderChainGeoid(t *testing.T) {
+	config := NewConfig()
+	config.Set("ELEVATION_PROVIDERS", "opentopo,open-elevation")
+	factory := NewAPIClientFactory(config, NewLogger("test"))
+
+	gridFile := writeGeoidGrid(t, make([]uint16, 12))
+
+	// Without GEOID_GRID_FILE there is no grid to correct with
+	config.Set("GEOID_CORRECTED_PROVIDERS", "open-elevation")
+	if _, err := factory.CreateElevationProviderChain(); err == nil || !strings.Contains(err.Error(), "GEOID_GRID_FILE") {
+		t.Errorf("CreateElevationProviderChain() without GEOID_GRID_FILE error = %v, want it required", err)
+	}
+
+	config.Set("GEOID_GRID_FILE", gridFile)
+	chain, err := factory.CreateElevationProviderChain()
+	if err != nil {
+		t.Fatalf("CreateElevationProviderChain() error = %v", err)
+	}
+	if _, ok := chain.providers[1].provider.(*GeoidCorrectedProvider); !ok {
+		t.Error("Expected open-elevation to be geoid corrected")
+	}
+	if _, ok := chain.providers[0].provider.(*GeoidCorrectedProvider); ok {
+		t.Error("Expected opentopo to be left uncorrected")
+	}
+
+	config.Set("GEOID_CORRECTED_PROVIDERS", "hgt")
+	if _, err := factory.CreateElevationProviderChain(); err == nil {
+		t.Error("Expected error for a provider outside the chain")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import "os"
+
+// DefaultGlobalSummaryFile aggregates the per-country outcomes of --process-all-countries
+const DefaultGlobalSummaryFile = "output/global_summary.json"
+
+// CountryResult is the outcome of processing one country in a global run
+type CountryResult struct {
+	Country       string `json:"country"`
+	Workspace     string `json:"workspace"`
+	Success       bool   `json:"success"`
+	Error         string `json:"error,omitempty"`
+	Duration      string `json:"duration"`
+	ValidElements int    `json:"valid_elements"`
+	Uploaded      int    `json:"uploaded"`
+	FailedUploads int    `json:"failed_uploads"`
+}
+
+// GlobalSummary aggregates the results of all countries in a global run
+type GlobalSummary struct {
+	StartedAt     string          `json:"started_at"`
+	CompletedAt   string          `json:"completed_at"`
+	Successful    int             `json:"successful"`
+	Failed        int             `json:"failed"`
+	ValidElements int             `json:"valid_elements"`
+	Uploaded      int             `json:"uploaded"`
+	FailedUploads int             `json:"failed_uploads"`
+	Countries     []CountryResult `json:"countries"`
+}
+
+// Add records the result of one country
+func (s *GlobalSummary) Add(result CountryResult) {
+	s.Countries = append(s.Countries, result)
+	if result.Success {
+		s.Successful++
+	} else {
+		s.Failed++
+	}
+	s.ValidElements += result.ValidElements
+	s.Uploaded += result.Uploaded
+	s.FailedUploads += result.FailedUploads
+}
+
+// Print displays the aggregated summary
+func (s *GlobalSummary) Print() {
+	pipelineLog.Banner("GLOBAL PROCESSING SUMMARY")
+	pipelineLog.Info("Total countries: %d", len(s.Countries))
+	pipelineLog.Info("Successfully processed: %d", s.Successful)
+	pipelineLog.Info("Failed: %d", s.Failed)
+	pipelineLog.Info("Valid elements: %d", s.ValidElements)
+	pipelineLog.Info("Uploaded: %d (failed: %d)", s.Uploaded, s.FailedUploads)
+
+	for _, c := range s.Countries {
+		if !c.Success {
+			pipelineLog.Warn("Failed country %s: %s", c.Country, c.Error)
+		}
+	}
+
+	pipelineLog.Info("Completed: %s", s.CompletedAt)
+}
+
+// summarizeCountry reads the element and upload counts from a country's workspace
+func summarizeCountry(country string, ws Workspace, dryRun bool) CountryResult {
+	result := CountryResult{Country: country, Workspace: ws.Dir}
+
+	var data ValidatedData
+	if err := loadPipelineFile(ws.File(DefaultValidatedDataFile), SchemaValidated, &data); err == nil {
+		for _, key := range categoryKeys {
+			result.ValidElements += data.Category(key).ValidCount
+		}
+	}
+
+	resultsFile := ws.File(DefaultUploadResultsFile)
+	if dryRun {
+		return result
+	}
+	if _, err := os.Stat(resultsFile); err != nil {
+		return result
+	}
+	var uploadResults UploadResults
+	if err := loadJSON(resultsFile, &uploadResults); err == nil {
+		for _, stats := range uploadResults.Stats {
+			result.Uploaded += stats.Successful
+			result.FailedUploads += stats.Failed
+		}
+	}
+	return result
+}
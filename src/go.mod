@@ -1,8 +1,14 @@
 module elevate-romania
 
-go 1.21
+go 1.22
 
 require (
+	github.com/BurntSushi/toml v1.4.0
 	github.com/joho/godotenv v1.5.1
+	github.com/mattn/go-sqlite3 v1.14.33
+	go.etcd.io/bbolt v1.3.11
 	golang.org/x/oauth2 v0.23.0
+	gopkg.in/yaml.v3 v3.0.1
 )
+
+require golang.org/x/sys v0.27.0 // indirect
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// gpxProvider marks surveyed elevations in place of an elevation provider
+const gpxProvider = "gpx"
+
+// metersPerDegree is the length of a degree of latitude
+const metersPerDegree = 111320.0
+
+// GPXPoint is a surveyed position with its GPS or barometric elevation
+type GPXPoint struct {
+	Lat float64
+	Lon float64
+	Ele float64
+}
+
+// gpxPoint is a waypoint, route point or track point of a GPX file
+type gpxPoint struct {
+	Lat float64  `xml:"lat,attr"`
+	Lon float64  `xml:"lon,attr"`
+	Ele *float64 `xml:"ele"`
+}
+
+type gpxDocument struct {
+	Waypoints []gpxPoint `xml:"wpt"`
+	Routes    []struct {
+		Points []gpxPoint `xml:"rtept"`
+	} `xml:"rte"`
+	Tracks []struct {
+		Segments []struct {
+			Points []gpxPoint `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+// ReadGPX returns the waypoints, route points and track points of a GPX file that have an elevation
+func ReadGPX(r io.Reader) ([]GPXPoint, error) {
+	var doc gpxDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("failed to parse GPX: %v", err)
+	}
+
+	all := doc.Waypoints
+	for _, route := range doc.Routes {
+		all = append(all, route.Points...)
+	}
+	for _, track := range doc.Tracks {
+		for _, segment := range track.Segments {
+			all = append(all, segment.Points...)
+		}
+	}
+
+	var points []GPXPoint
+	for _, p := range all {
+		if p.Ele != nil {
+			points = append(points, GPXPoint{Lat: p.Lat, Lon: p.Lon, Ele: *p.Ele})
+		}
+	}
+	return points, nil
+}
+
+// SurveyMatcher replaces DEM elevations with the elevation of the nearest surveyed GPX point
+// within Radius meters of an element
+type SurveyMatcher struct {
+	Radius float64
+	// Source is the source tag value of surveyed elevations
+	Source  string
+	cell    float64 // grid cell size in degrees of latitude
+	grid    map[[2]int][]GPXPoint
+	Points  int
+	Matched int
+}
+
+// NewSurveyMatcher indexes the points of the GPX files for lookups within radius meters
+func NewSurveyMatcher(paths []string, radius float64, source string) (*SurveyMatcher, error) {
+	if radius <= 0 {
+		return nil, fmt.Errorf("GPX_MATCH_RADIUS_M must be positive, got %g", radius)
+	}
+	m := &SurveyMatcher{Radius: radius, Source: source, cell: radius / metersPerDegree, grid: make(map[[2]int][]GPXPoint)}
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open GPX file: %v", err)
+		}
+		points, err := ReadGPX(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		for _, p := range points {
+			m.add(p)
+		}
+	}
+	return m, nil
+}
+
+// cellOf returns the grid cell of a position
+func (m *SurveyMatcher) cellOf(lat, lon float64) [2]int {
+	return [2]int{int(math.Floor(lat / m.cell)), int(math.Floor(lon / m.cell))}
+}
+
+func (m *SurveyMatcher) add(p GPXPoint) {
+	key := m.cellOf(p.Lat, p.Lon)
+	m.grid[key] = append(m.grid[key], p)
+	m.Points++
+}
+
+// Nearest returns the surveyed point closest to c within the radius
+func (m *SurveyMatcher) Nearest(c Coordinates) (GPXPoint, bool) {
+	// Cells are narrower than the radius in longitude away from the equator
+	lonSpan := int(math.Ceil(1 / math.Max(math.Cos(c.Lat*math.Pi/180), 0.01)))
+	center := m.cellOf(c.Lat, c.Lon)
+
+	var nearest GPXPoint
+	best := m.Radius
+	found := false
+	for dy := -1; dy <= 1; dy++ {
+		for dx := -lonSpan; dx <= lonSpan; dx++ {
+			for _, p := range m.grid[[2]int{center[0] + dy, center[1] + dx}] {
+				if d := HaversineDistance(c, Coordinates{Lat: p.Lat, Lon: p.Lon}) * 1000; d <= best {
+					nearest, best, found = p, d, true
+				}
+			}
+		}
+	}
+	return nearest, found
+}
+
+// Apply gives enriched elements near a surveyed point its elevation, and moves elements the DEM
+// had no data for into enriched when a survey covers them
+func (m *SurveyMatcher) Apply(enriched, noData []OSMElement, format ElevationFormat, sourceKey string) ([]OSMElement, []OSMElement) {
+	extractor := NewCoordinateExtractor()
+	survey := func(element *OSMElement) bool {
+		coords, valid := extractor.Extract(*element)
+		if !valid {
+			return false
+		}
+		point, ok := m.Nearest(coords)
+		if !ok {
+			return false
+		}
+		ele := point.Ele
+		element.ElevationFetched = &ele
+		element.ElevationProvider = gpxProvider
+		format.Apply(element)
+		element.Tags[sourceKey] = m.Source
+		m.Matched++
+		return true
+	}
+
+	for i := range enriched {
+		survey(&enriched[i])
+	}
+	var missing []OSMElement
+	for _, element := range noData {
+		if survey(&element) {
+			enriched = append(enriched, element)
+		} else {
+			missing = append(missing, element)
+		}
+	}
+	return enriched, missing
+}
+
+// surveyMatcherFromConfig loads GPX_FILES, or returns nil when no GPX file is configured
+func surveyMatcherFromConfig(config *Config) (*SurveyMatcher, error) {
+	paths := splitList(config.Get("GPX_FILES"))
+	if len(paths) == 0 {
+		return nil, nil
+	}
+	return NewSurveyMatcher(paths, config.GetFloat("GPX_MATCH_RADIUS_M"), config.Get("GPX_ELE_SOURCE"))
+}
+
+// registerGPXFlag adds --gpx and returns a function that applies it when given
+func registerGPXFlag(fs *flag.FlagSet) func() {
+	files := fs.String("gpx", "", "GPX survey tracks (comma-separated) whose elevations replace the DEM near their points (default: GPX_FILES)")
+	return func() {
+		if flagWasSet(fs, "gpx") {
+			flagConfig.Set("GPX_FILES", *files)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <wpt lat="45.6000" lon="24.7400"><ele>2544.2</ele><name>Moldoveanu</name></wpt>
+  <trk><trkseg>
+    <trkpt lat="45.5000" lon="25.0000"><ele>1801.0</ele></trkpt>
+    <trkpt lat="45.5002" lon="25.0000"><ele>1803.0</ele></trkpt>
+    <trkpt lat="45.5100" lon="25.0000"></trkpt>
+  </trkseg></trk>
+</gpx>`
+
+func TestReadGPX(t *testing.T) {
+	points, err := ReadGPX(strings.NewReader(testGPX))
+	if err != nil {
+		t.Fatalf("ReadGPX() error = %v", err)
+	}
+	if len(points) != 3 {
+		t.Fatalf("ReadGPX() = %+v, want the 3 points with an elevation", points)
+	}
+	if points[0] != (GPXPoint{Lat: 45.6, Lon: 24.74, Ele: 2544.2}) {
+		t.Errorf("waypoint = %+v", points[0])
+	}
+	if _, err := ReadGPX(strings.NewReader("not xml")); err == nil {
+		t.Error("ReadGPX(invalid) succeeded, want an error")
+	}
+}
+
+func TestSurveyMatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "survey.gpx")
+	if err := os.WriteFile(path, []byte(testGPX), 0644); err != nil {
+		t.Fatal(err)
+	}
+	matcher, err := NewSurveyMatcher([]string{path}, 30, "GPS survey")
+	if err != nil {
+		t.Fatalf("NewSurveyMatcher() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		coords Coordinates
+		want   float64
+		found  bool
+	}{
+		{"On a point", Coordinates{Lat: 45.6, Lon: 24.74}, 2544.2, true},
+		{"Nearest of two", Coordinates{Lat: 45.50015, Lon: 25.0001}, 1803, true},
+		{"Out of range", Coordinates{Lat: 45.501, Lon: 25.0}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			point, found := matcher.Nearest(tt.coords)
+			if found != tt.found || (found && point.Ele != tt.want) {
+				t.Errorf("Nearest(%v) = %+v, %v; want ele %g, %v", tt.coords, point, found, tt.want, tt.found)
+			}
+		})
+	}
+
+	dem := 2500.0
+	enriched := []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.6, Lon: 24.74, Tags: map[string]string{"ele:source": "SRTM"}, ElevationFetched: &dem, ElevationProvider: "opentopo"},
+		{Type: "node", ID: 2, Lat: 46.0, Lon: 24.0, Tags: map[string]string{"ele:source": "SRTM"}, ElevationFetched: &dem},
+	}
+	noData := []OSMElement{{Type: "node", ID: 3, Lat: 45.5, Lon: 25.0}, {Type: "node", ID: 4, Lat: 44.0, Lon: 28.6}}
+
+	enriched, noData = matcher.Apply(enriched, noData, defaultElevationFormat, "ele:source")
+	if len(enriched) != 3 || len(noData) != 1 || noData[0].ID != 4 {
+		t.Fatalf("Apply() = %d enriched, %+v without data; want node 3 rescued", len(enriched), noData)
+	}
+	if got := enriched[0]; got.Tags["ele"] != "2544.2" || got.Tags["ele:source"] != "GPS survey" || got.ElevationProvider != gpxProvider {
+		t.Errorf("surveyed element = %+v, want ele=2544.2 from GPS survey", got)
+	}
+	if got := enriched[1]; got.Tags["ele:source"] != "SRTM" || *got.ElevationFetched != dem {
+		t.Errorf("element far from the survey = %+v, want its DEM value kept", got)
+	}
+	if got := enriched[2]; got.ID != 3 || got.Tags["ele"] != "1801.0" {
+		t.Errorf("rescued element = %+v, want ele=1801.0", got)
+	}
+	if matcher.Matched != 2 {
+		t.Errorf("Matched = %d, want 2", matcher.Matched)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// hgtVoidValue marks missing data in SRTM tiles
+const hgtVoidValue = -32768
+
+// ErrElevationVoid is returned when a DEM has no data at a location
+var ErrElevationVoid = errors.New("no elevation data (void) at location")
+
+// isNoDataElevation reports whether a DEM value is a nodata sentinel rather than an elevation
+func isNoDataElevation(elevation float64) bool {
+	return math.IsNaN(elevation) || elevation <= hgtVoidValue+1 || elevation == -9999
+}
+
+// HGTElevationProvider reads elevations from local SRTM .hgt tiles
+type HGTElevationProvider struct {
+	TileDir string
+
+	mu    sync.Mutex
+	tiles map[string]*hgtTile
+}
+
+// hgtTile is an open .hgt file with its grid size
+type hgtTile struct {
+	file *os.File
+	size int
+}
+
+// NewHGTElevationProvider creates a provider reading tiles from a directory
+func NewHGTElevationProvider(tileDir string) *HGTElevationProvider {
+	return &HGTElevationProvider{
+		TileDir: tileDir,
+		tiles:   make(map[string]*hgtTile),
+	}
+}
+
+// hgtTileName returns the SRTM tile name covering a location, e.g. N45E025.hgt
+func hgtTileName(lat, lon float64) string {
+	latBase := int(math.Floor(lat))
+	lonBase := int(math.Floor(lon))
+
+	latPrefix := "N"
+	if latBase < 0 {
+		latPrefix = "S"
+		latBase = -latBase
+	}
+	lonPrefix := "E"
+	if lonBase < 0 {
+		lonPrefix = "W"
+		lonBase = -lonBase
+	}
+
+	return fmt.Sprintf("%s%02d%s%03d.hgt", latPrefix, latBase, lonPrefix, lonBase)
+}
+
+// tile opens (or returns the cached) tile covering a location
+func (p *HGTElevationProvider) tile(lat, lon float64) (*hgtTile, error) {
+	name := hgtTileName(lat, lon)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if t, ok := p.tiles[name]; ok {
+		if t == nil {
+			return nil, fmt.Errorf("tile %s not found in %s", name, p.TileDir)
+		}
+		return t, nil
+	}
+
+	file, err := os.Open(filepath.Join(p.TileDir, name))
+	if err != nil {
+		// Remember missing tiles so we don't hit the filesystem for every lookup
+		p.tiles[name] = nil
+		return nil, fmt.Errorf("tile %s not found in %s", name, p.TileDir)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to stat tile %s: %v", name, err)
+	}
+
+	// Tiles are square grids of big-endian int16 samples (1201x1201 for SRTM3, 3601x3601 for SRTM1)
+	size := int(math.Sqrt(float64(info.Size() / 2)))
+	if size < 2 || int64(size*size*2) != info.Size() {
+		file.Close()
+		return nil, fmt.Errorf("tile %s has unexpected size %d bytes", name, info.Size())
+	}
+
+	t := &hgtTile{file: file, size: size}
+	p.tiles[name] = t
+	return t, nil
+}
+
+// sample reads the elevation at a grid row and column
+func (t *hgtTile) sample(row, col int) (int16, error) {
+	buf := make([]byte, 2)
+	offset := int64(row*t.size+col) * 2
+	if _, err := t.file.ReadAt(buf, offset); err != nil {
+		return 0, fmt.Errorf("failed to read tile sample: %v", err)
+	}
+	return int16(binary.BigEndian.Uint16(buf)), nil
+}
+
+// GetElevation returns the bilinearly interpolated elevation at a location.
+// Void samples are ignored; if all surrounding samples are void ErrElevationVoid is returned.
+func (p *HGTElevationProvider) GetElevation(lat, lon float64) (*float64, error) {
+	t, err := p.tile(lat, lon)
+	if err != nil {
+		return nil, err
+	}
+
+	// Row 0 is the northern edge of the tile, column 0 the western edge
+	cells := float64(t.size - 1)
+	y := (math.Floor(lat) + 1 - lat) * cells
+	x := (lon - math.Floor(lon)) * cells
+
+	row0 := int(math.Floor(y))
+	col0 := int(math.Floor(x))
+	if row0 >= t.size-1 {
+		row0 = t.size - 2
+	}
+	if col0 >= t.size-1 {
+		col0 = t.size - 2
+	}
+	dy := y - float64(row0)
+	dx := x - float64(col0)
+
+	corners := []struct {
+		row, col int
+		weight   float64
+	}{
+		{row0, col0, (1 - dx) * (1 - dy)},
+		{row0, col0 + 1, dx * (1 - dy)},
+		{row0 + 1, col0, (1 - dx) * dy},
+		{row0 + 1, col0 + 1, dx * dy},
+	}
+
+	sum, weights := 0.0, 0.0
+	for _, c := range corners {
+		value, err := t.sample(c.row, c.col)
+		if err != nil {
+			return nil, err
+		}
+		if value == hgtVoidValue {
+			continue
+		}
+		sum += float64(value) * c.weight
+		weights += c.weight
+	}
+
+	if weights == 0 {
+		return nil, ErrElevationVoid
+	}
+
+	elevation := math.Round(sum/weights*10) / 10
+	return &elevation, nil
+}
+
+// BatchGetElevations looks up elevations for several locations from local tiles
+func (p *HGTElevationProvider) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	results := make([]BatchElevationResult, len(locations))
+	for i, loc := range locations {
+		elevation, err := p.GetElevation(loc.Lat, loc.Lon)
+		results[i] = BatchElevationResult{
+			Elevation: elevation,
+			Error:     err,
+			Element:   loc.Element,
+		}
+	}
+	return results, nil
+}
+
+// Close releases all open tile files
+func (p *HGTElevationProvider) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name, t := range p.tiles {
+		if t != nil {
+			t.file.Close()
+		}
+		delete(p.tiles, name)
+	}
+}
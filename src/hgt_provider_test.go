@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeTestTile writes a square .hgt tile with the given row-major samples
+func writeTestTile(t *testing.T, dir, name string, samples []int16) {
+	t.Helper()
+	buf := make([]byte, len(samples)*2)
+	for i, s := range samples {
+		binary.BigEndian.PutUint16(buf[i*2:], uint16(s))
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), buf, 0644); err != nil {
+		t.Fatalf("failed to write tile: %v", err)
+	}
+}
+
+func TestHGTTileName(t *testing.T) {
+	tests := []struct {
+		lat, lon float64
+		expected string
+	}{
+		{45.5, 25.3, "N45E025.hgt"},
+		{-0.5, -73.2, "S01W074.hgt"},
+		{0, 0, "N00E000.hgt"},
+		{47.99, -0.01, "N47W001.hgt"},
+	}
+	for _, tt := range tests {
+		if got := hgtTileName(tt.lat, tt.lon); got != tt.expected {
+			t.Errorf("hgtTileName(%v, %v) = %q, want %q", tt.lat, tt.lon, got, tt.expected)
+		}
+	}
+}
+
+func TestHGTElevationProvider(t *testing.T) {
+	dir := t.TempDir()
+	// 3x3 grid: row 0 is the northern edge (lat 46), row 2 the southern edge (lat 45)
+	writeTestTile(t, dir, "N45E025.hgt", []int16{
+		100, 200, 300,
+		400, 500, hgtVoidValue,
+		700, 800, 900,
+	})
+	writeTestTile(t, dir, "N44E025.hgt", []int16{
+		hgtVoidValue, hgtVoidValue, 0,
+		hgtVoidValue, hgtVoidValue, 0,
+		0, 0, 0,
+	})
+
+	provider := NewHGTElevationProvider(dir)
+	defer provider.Close()
+
+	tests := []struct {
+		name     string
+		lat, lon float64
+		expected float64
+	}{
+		{"north-west corner", 46.0 - 1e-9, 25.0, 100},
+		{"center sample", 45.5, 25.5, 500},
+		{"south-east corner", 45.0, 25.999999999, 900},
+		{"interpolated", 45.75, 25.25, 300},
+		{"void neighbour ignored", 45.5, 25.75, 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elevation, err := provider.GetElevation(tt.lat, tt.lon)
+			if err != nil {
+				t.Fatalf("GetElevation() error = %v", err)
+			}
+			if *elevation != tt.expected {
+				t.Errorf("GetElevation() = %v, want %v", *elevation, tt.expected)
+			}
+		})
+	}
+
+	if _, err := provider.GetElevation(44.75, 25.25); !errors.Is(err, ErrElevationVoid) {
+		t.Errorf("Expected ErrElevationVoid, got %v", err)
+	}
+
+	if _, err := provider.GetElevation(10, 10); err == nil {
+		t.Error("Expected error for missing tile")
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultHistoryFile is the SQLite database of every upload, shared by all workspaces
+const DefaultHistoryFile = "output/history.db"
+
+// History grouping periods, with the length of their prefix of an RFC 3339 timestamp
+var historyPeriods = map[string]int{
+	"day":   len("2006-01-02"),
+	"month": len("2006-01"),
+	"year":  len("2006"),
+}
+
+const historySchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	run_at     TEXT    NOT NULL,
+	country    TEXT    NOT NULL,
+	step       TEXT    NOT NULL,
+	changesets INTEGER NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS run_categories (
+	run_id   INTEGER NOT NULL REFERENCES runs (id),
+	category TEXT    NOT NULL,
+	uploaded INTEGER NOT NULL DEFAULT 0,
+	failed   INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (run_id, category)
+);
+CREATE INDEX IF NOT EXISTS runs_run_at ON runs (run_at);
+`
+
+// HistoryDB records the uploads of all runs so contributions can be totaled over time
+type HistoryDB struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// HistoryPeriod totals the uploads of one day, month or year
+type HistoryPeriod struct {
+	Period     string
+	Runs       int
+	Changesets int
+	Uploaded   int
+	// Cumulative is the number of elements uploaded up to the end of the period
+	Cumulative int
+}
+
+// HistoryTotal is the number of elements uploaded for a country or category
+type HistoryTotal struct {
+	Name     string
+	Runs     int
+	Uploaded int
+}
+
+// OpenHistoryDB opens or creates the history database
+func OpenHistoryDB(path string) (*HistoryDB, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history %s: %v", path, err)
+	}
+	if _, err := db.Exec(historySchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize history %s: %v", path, err)
+	}
+	return &HistoryDB{db: db, now: time.Now}, nil
+}
+
+// Close closes the history database
+func (h *HistoryDB) Close() error {
+	return h.db.Close()
+}
+
+// RecordRun adds an upload with its per-category statistics
+func (h *HistoryDB) RecordRun(step, country string, stats map[string]UploadStats, changesets int) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to update history: %v", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`INSERT INTO runs (run_at, country, step, changesets) VALUES (?, ?, ?, ?)`,
+		h.now().UTC().Format(time.RFC3339), country, step, changesets)
+	if err != nil {
+		return fmt.Errorf("failed to update history: %v", err)
+	}
+	runID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to update history: %v", err)
+	}
+	for category, categoryStats := range stats {
+		if _, err := tx.Exec(`INSERT INTO run_categories (run_id, category, uploaded, failed) VALUES (?, ?, ?, ?)`,
+			runID, category, categoryStats.Successful, categoryStats.Failed); err != nil {
+			return fmt.Errorf("failed to update history: %v", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update history: %v", err)
+	}
+	return nil
+}
+
+// Periods totals the uploads per day, month or year, oldest first; an empty country selects all
+func (h *HistoryDB) Periods(country, period string) ([]HistoryPeriod, error) {
+	length, ok := historyPeriods[period]
+	if !ok {
+		return nil, fmt.Errorf("invalid history period %q (expected day, month or year)", period)
+	}
+	rows, err := h.db.Query(`
+SELECT substr(run_at, 1, ?) AS period, COUNT(*), SUM(changesets),
+	SUM((SELECT COALESCE(SUM(uploaded), 0) FROM run_categories WHERE run_id = runs.id))
+FROM runs WHERE ? = '' OR country = ?
+GROUP BY period ORDER BY period`, length, country, country)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %v", err)
+	}
+	defer rows.Close()
+
+	var periods []HistoryPeriod
+	cumulative := 0
+	for rows.Next() {
+		var p HistoryPeriod
+		if err := rows.Scan(&p.Period, &p.Runs, &p.Changesets, &p.Uploaded); err != nil {
+			return nil, fmt.Errorf("failed to query history: %v", err)
+		}
+		cumulative += p.Uploaded
+		p.Cumulative = cumulative
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+// CountryTotals returns the uploads per country, the largest contribution first
+func (h *HistoryDB) CountryTotals() ([]HistoryTotal, error) {
+	return h.totals(`
+SELECT country, COUNT(*),
+	SUM((SELECT COALESCE(SUM(uploaded), 0) FROM run_categories WHERE run_id = runs.id)) AS uploaded
+FROM runs GROUP BY country ORDER BY uploaded DESC, country`)
+}
+
+// CategoryTotals returns the uploads per category, the largest first; an empty country selects all
+func (h *HistoryDB) CategoryTotals(country string) ([]HistoryTotal, error) {
+	return h.totals(`
+SELECT category, COUNT(*), SUM(uploaded) AS uploaded
+FROM run_categories JOIN runs ON runs.id = run_id
+WHERE ? = '' OR country = ?
+GROUP BY category ORDER BY uploaded DESC, category`, country, country)
+}
+
+func (h *HistoryDB) totals(query string, args ...interface{}) ([]HistoryTotal, error) {
+	rows, err := h.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query history: %v", err)
+	}
+	defer rows.Close()
+
+	var totals []HistoryTotal
+	for rows.Next() {
+		var total HistoryTotal
+		if err := rows.Scan(&total.Name, &total.Runs, &total.Uploaded); err != nil {
+			return nil, fmt.Errorf("failed to query history: %v", err)
+		}
+		totals = append(totals, total)
+	}
+	return totals, rows.Err()
+}
+
+// recordHistory adds a finished upload to the history. Like the pipeline store, the history
+// is bookkeeping, so an error is only reported.
+func recordHistory(step, country string, stats map[string]UploadStats, changesets int) {
+	history, err := OpenHistoryDB(DefaultHistoryFile)
+	if err == nil {
+		err = history.RecordRun(step, country, stats, changesets)
+		history.Close()
+	}
+	if err != nil {
+		pipelineLog.Warn("Failed to update history: %v", err)
+	}
+}
+
+// runHistory prints the cumulative contributions recorded in the history
+func runHistory(country, period string) error {
+	if _, ok := historyPeriods[period]; !ok {
+		return fmt.Errorf("invalid history period %q (expected day, month or year)", period)
+	}
+	if _, err := os.Stat(DefaultHistoryFile); os.IsNotExist(err) {
+		fmt.Println("No uploads recorded yet")
+		return nil
+	}
+	history, err := OpenHistoryDB(DefaultHistoryFile)
+	if err != nil {
+		return err
+	}
+	defer history.Close()
+
+	periods, err := history.Periods(country, period)
+	if err != nil {
+		return err
+	}
+	if len(periods) == 0 {
+		fmt.Println("No uploads recorded yet")
+		return nil
+	}
+
+	title := "all countries"
+	if country != "" {
+		title = country
+	}
+	fmt.Printf("\nContributions to %s per %s:\n\n", title, period)
+	fmt.Printf("%-12s %6s %11s %9s %11s\n", "Period", "Runs", "Changesets", "Uploaded", "Cumulative")
+	for _, p := range periods {
+		fmt.Printf("%-12s %6d %11d %9d %11d\n", p.Period, p.Runs, p.Changesets, p.Uploaded, p.Cumulative)
+	}
+
+	categories, err := history.CategoryTotals(country)
+	if err != nil {
+		return err
+	}
+	fmt.Println("\nBy category:")
+	for _, total := range categories {
+		fmt.Printf("  %-25s %9d\n", total.Name, total.Uploaded)
+	}
+
+	if country == "" {
+		countries, err := history.CountryTotals()
+		if err != nil {
+			return err
+		}
+		fmt.Println("\nBy country:")
+		for _, total := range countries {
+			fmt.Printf("  %-25s %9d  (%d runs)\n", total.Name, total.Uploaded, total.Runs)
+		}
+	}
+	return nil
+}
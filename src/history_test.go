@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHistoryDBTotals(t *testing.T) {
+	history, err := OpenHistoryDB(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("OpenHistoryDB() error = %v", err)
+	}
+	defer history.Close()
+
+	runs := []struct {
+		at      string
+		country string
+		stats   map[string]UploadStats
+	}{
+		{"2026-08-03T10:00:00Z", "România", map[string]UploadStats{"peaks": {Successful: 10, Failed: 1}, "shelters": {Successful: 2}}},
+		{"2026-08-20T10:00:00Z", "Moldova", map[string]UploadStats{"train_stations": {Successful: 5}}},
+		{"2026-09-01T10:00:00Z", "România", map[string]UploadStats{"peaks": {Successful: 3}}},
+	}
+	for _, run := range runs {
+		at, _ := time.Parse(time.RFC3339, run.at)
+		history.now = func() time.Time { return at }
+		if err := history.RecordRun(StepUpload, run.country, run.stats, 1); err != nil {
+			t.Fatalf("RecordRun() error = %v", err)
+		}
+	}
+
+	periods, err := history.Periods("", "month")
+	if err != nil {
+		t.Fatalf("Periods() error = %v", err)
+	}
+	want := []HistoryPeriod{
+		{Period: "2026-08", Runs: 2, Changesets: 2, Uploaded: 17, Cumulative: 17},
+		{Period: "2026-09", Runs: 1, Changesets: 1, Uploaded: 3, Cumulative: 20},
+	}
+	if !reflect.DeepEqual(periods, want) {
+		t.Errorf("Periods(month) = %+v, want %+v", periods, want)
+	}
+
+	periods, err = history.Periods("România", "year")
+	if err != nil {
+		t.Fatalf("Periods() error = %v", err)
+	}
+	if len(periods) != 1 || periods[0].Uploaded != 15 || periods[0].Runs != 2 {
+		t.Errorf("Periods(România, year) = %+v, want 15 uploads in 2 runs", periods)
+	}
+	if _, err := history.Periods("", "week"); err == nil {
+		t.Error("Periods(week) succeeded, want an error")
+	}
+
+	countries, err := history.CountryTotals()
+	if err != nil {
+		t.Fatalf("CountryTotals() error = %v", err)
+	}
+	if want := []HistoryTotal{{Name: "România", Runs: 2, Uploaded: 15}, {Name: "Moldova", Runs: 1, Uploaded: 5}}; !reflect.DeepEqual(countries, want) {
+		t.Errorf("CountryTotals() = %+v, want %+v", countries, want)
+	}
+
+	categories, err := history.CategoryTotals("România")
+	if err != nil {
+		t.Fatalf("CategoryTotals() error = %v", err)
+	}
+	if want := []HistoryTotal{{Name: "peaks", Runs: 2, Uploaded: 13}, {Name: "shelters", Runs: 1, Uploaded: 2}}; !reflect.DeepEqual(categories, want) {
+		t.Errorf("CategoryTotals() = %+v, want %+v", categories, want)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Hook events; each is also the config key holding the shell command run on it
+const (
+	HookStepStart      = "ON_STEP_START"
+	HookStepComplete   = "ON_STEP_COMPLETE"
+	HookUploadComplete = "ON_UPLOAD_COMPLETE"
+)
+
+// hookTimeout bounds a hook so a hanging script never holds up the run
+const hookTimeout = 5 * time.Minute
+
+// HookEvent describes the step a hook runs for; it reaches the command as ELEVATE_* variables
+type HookEvent struct {
+	Name      string
+	Step      string
+	Country   string
+	Workspace Workspace
+	DryRun    bool
+	Counts    map[string]int
+}
+
+// env returns the variables describing the event to the hook command
+func (e HookEvent) env() []string {
+	counts, _ := json.Marshal(e.Counts)
+	return []string{
+		"ELEVATE_HOOK=" + e.Name,
+		"ELEVATE_STEP=" + e.Step,
+		"ELEVATE_COUNTRY=" + e.Country,
+		"ELEVATE_WORKSPACE=" + e.Workspace.Dir,
+		"ELEVATE_MANIFEST=" + e.Workspace.File(DefaultManifestFile),
+		"ELEVATE_DRY_RUN=" + strconv.FormatBool(e.DryRun),
+		"ELEVATE_COUNTS=" + string(counts),
+	}
+}
+
+// runHook runs the shell command configured for an event, if any, with the event in its
+// environment and its output passed through
+func runHook(event HookEvent) error {
+	config := NewConfig()
+	config.LoadFromEnv()
+	command := strings.TrimSpace(config.Get(event.Name))
+	if command == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), hookTimeout)
+	defer cancel()
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Env = append(os.Environ(), event.env()...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s hook of %s failed: %v", strings.ToLower(event.Name), event.Step, err)
+	}
+	return nil
+}
+
+// runStepStartHook runs the ON_STEP_START hook before a step. Unlike the other hooks its
+// failure stops the step, so a hook can e.g. require a backup before an upload.
+func runStepStartHook(ws Workspace, step, country string) error {
+	return runHook(HookEvent{Name: HookStepStart, Step: step, Country: country, Workspace: ws})
+}
+
+// runStepCompleteHooks runs the ON_STEP_COMPLETE hook after a step, and ON_UPLOAD_COMPLETE
+// after an upload. The step already finished, so a failure is only reported.
+func runStepCompleteHooks(ws Workspace, step ManifestStep) {
+	names := []string{HookStepComplete}
+	if step.Step == StepUpload || step.Step == StepApply {
+		names = append(names, HookUploadComplete)
+	}
+	for _, name := range names {
+		event := HookEvent{Name: name, Step: step.Step, Country: step.Country, Workspace: ws, DryRun: step.DryRun, Counts: step.Counts}
+		if err := runHook(event); err != nil {
+			pipelineLog.Warn("%v", err)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestStepHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands use sh")
+	}
+	t.Cleanup(func() { flagConfig = NewConfig() })
+	dir := t.TempDir()
+	logFile := filepath.Join(dir, "hooks.log")
+	record := `echo "$ELEVATE_HOOK $ELEVATE_STEP $ELEVATE_COUNTRY $ELEVATE_DRY_RUN $ELEVATE_COUNTS" >> ` + logFile
+	flagConfig.Set(HookStepStart, record)
+	flagConfig.Set(HookStepComplete, record)
+	flagConfig.Set(HookUploadComplete, record)
+
+	ws := Workspace{Dir: dir}
+	if err := runStepStartHook(ws, StepValidate, "Moldova"); err != nil {
+		t.Fatalf("runStepStartHook() error = %v", err)
+	}
+	runStepCompleteHooks(ws, ManifestStep{Step: StepValidate, Country: "Moldova", Counts: map[string]int{"peaks": 2}})
+	runStepCompleteHooks(ws, ManifestStep{Step: StepUpload, Country: "Moldova", DryRun: true})
+
+	raw, err := os.ReadFile(logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ON_STEP_START validate Moldova false null",
+		`ON_STEP_COMPLETE validate Moldova false {"peaks":2}`,
+		"ON_STEP_COMPLETE upload Moldova true null",
+		"ON_UPLOAD_COMPLETE upload Moldova true null",
+	}
+	if got := strings.Split(strings.TrimSpace(string(raw)), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("hooks ran as\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	flagConfig.Set(HookStepStart, "exit 3")
+	err = runStepStartHook(ws, StepUpload, "Moldova")
+	if err == nil || !strings.Contains(err.Error(), "on_step_start hook of upload failed") {
+		t.Errorf("runStepStartHook() error = %v, want the failed hook", err)
+	}
+}
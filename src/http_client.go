@@ -12,6 +12,10 @@ type RetryConfig struct {
 	InitialBackoff time.Duration
 	MaxBackoff     time.Duration
 	Multiplier     float64
+	// RetryWrites also retries POST/PUT/DELETE requests after network errors and any 5xx.
+	// Enable it only for read-only APIs queried with POST (Overpass, Open-Elevation); other
+	// writes are only retried on 429, 503 and 509, which guarantee the request was not processed.
+	RetryWrites bool
 }
 
 // DefaultRetryConfig returns sensible defaults for retry configuration
@@ -24,11 +28,13 @@ func DefaultRetryConfig() RetryConfig {
 	}
 }
 
-// HTTPClientWrapper wraps an HTTP client with retry logic and error handling
+// HTTPClientWrapper wraps an HTTP client with retry logic and error handling.
+// Requests are paced by the shared adaptive rate limiter.
 type HTTPClientWrapper struct {
 	client      *http.Client
 	retryConfig RetryConfig
 	logger      Logger
+	limiter     *AdaptiveRateLimiter
 }
 
 // NewHTTPClientWrapper creates a new HTTP client wrapper
@@ -46,19 +52,54 @@ func NewHTTPClientWrapper(client *http.Client, retryConfig RetryConfig, logger L
 		client:      client,
 		retryConfig: retryConfig,
 		logger:      logger,
+		limiter:     sharedRateLimiter(),
 	}
 }
 
-// Do executes an HTTP request with retry logic
+// NewRetryingClient wraps client with the default retry configuration
+func NewRetryingClient(client *http.Client) *HTTPClientWrapper {
+	return NewHTTPClientWrapper(client, DefaultRetryConfig(), nil)
+}
+
+// NewRetryingReadClient creates a retrying client for read-only APIs, including their POST queries
+func NewRetryingReadClient(timeout time.Duration) *HTTPClientWrapper {
+	retryConfig := DefaultRetryConfig()
+	retryConfig.RetryWrites = true
+	return NewHTTPClientWrapper(&http.Client{Timeout: timeout}, retryConfig, nil)
+}
+
+// Do executes an HTTP request with retry logic. A Retry-After delay longer than the
+// backoff is honored. When all attempts get a retryable status, the last response is
+// returned so the caller can report it.
 func (w *HTTPClientWrapper) Do(req *http.Request) (*http.Response, error) {
 	var lastErr error
 	backoff := w.retryConfig.InitialBackoff
+	var retryAfter time.Duration
+	ctx := req.Context()
+	safeToRepeat := w.retryConfig.RetryWrites || req.Method == "GET" || req.Method == "HEAD"
+	// A body that cannot be rewound can only be sent once
+	canResend := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
 	
 	for attempt := 0; attempt <= w.retryConfig.MaxRetries; attempt++ {
 		if attempt > 0 {
+			// The previous attempt consumed the body
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, fmt.Errorf("failed to rewind request body: %w", err)
+				}
+				req.Body = body
+			}
+
+			delay := backoff
+			if retryAfter > delay {
+				delay = retryAfter
+			}
 			w.logger.Warn("Retrying request (attempt %d/%d) after %v",
-				attempt, w.retryConfig.MaxRetries, backoff)
-			time.Sleep(backoff)
+				attempt, w.retryConfig.MaxRetries, delay)
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
 			
 			// Exponential backoff
 			backoff = time.Duration(float64(backoff) * w.retryConfig.Multiplier)
@@ -67,22 +108,35 @@ func (w *HTTPClientWrapper) Do(req *http.Request) (*http.Response, error) {
 			}
 		}
 		
+		if err := w.limiter.Wait(ctx, req.URL.Host); err != nil {
+			return nil, err
+		}
+		
 		resp, err := w.client.Do(req)
 		if err != nil {
 			lastErr = fmt.Errorf("request failed: %w", err)
+			if ctx.Err() != nil || !safeToRepeat || !canResend {
+				return nil, lastErr
+			}
 			w.logger.Warn("Request attempt %d failed: %v", attempt+1, err)
+			retryAfter = 0
 			continue
 		}
+		w.logger.Debug("%s %s -> %d", req.Method, req.URL.Redacted(), resp.StatusCode)
+		retryAfter = w.limiter.Observe(req.URL.Host, resp)
 		
 		// Check if status code indicates we should retry
-		if w.shouldRetry(resp.StatusCode) {
+		retry := w.shouldRetry(resp.StatusCode) && canResend && (safeToRepeat ||
+			resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable ||
+			resp.StatusCode == StatusBandwidthLimitExceeded)
+		if retry && attempt < w.retryConfig.MaxRetries {
 			resp.Body.Close()
 			lastErr = fmt.Errorf("server returned status %d", resp.StatusCode)
 			w.logger.Warn("Request attempt %d got status %d", attempt+1, resp.StatusCode)
 			continue
 		}
 		
-		// Success
+		// Success, or the final response for the caller to report
 		return resp, nil
 	}
 	
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHTTPClientWrapperRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		method       string
+		retryWrites  bool
+		statuses     []int
+		wantRequests int
+		wantStatus   int
+	}{
+		{"GET retried on 504", "GET", false, []int{504, 200}, 2, 200},
+		{"POST not retried on 500", "POST", false, []int{500, 200}, 1, 500},
+		{"POST retried on 503", "POST", false, []int{503, 200}, 2, 200},
+		{"read-only POST retried on 504", "POST", true, []int{504, 200}, 2, 200},
+		{"last response returned", "GET", false, []int{502, 502, 502}, 3, 502},
+		{"client error not retried", "GET", false, []int{404, 200}, 1, 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if r.Method == "POST" && string(body) != "data=query" {
+					t.Errorf("attempt %d body = %q, want the full request body", requests+1, body)
+				}
+				w.WriteHeader(tt.statuses[requests])
+				requests++
+			}))
+			defer server.Close()
+
+			retryConfig := RetryConfig{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1, RetryWrites: tt.retryWrites}
+			wrapper := NewHTTPClientWrapper(server.Client(), retryConfig, NewLoggerWithOutput("", io.Discard))
+			wrapper.limiter = NewAdaptiveRateLimiter()
+
+			req, err := http.NewRequest(tt.method, server.URL, strings.NewReader("data=query"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := wrapper.Do(req)
+			if err != nil {
+				t.Fatalf("Do() error = %v", err)
+			}
+			resp.Body.Close()
+
+			if requests != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", requests, tt.wantRequests)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
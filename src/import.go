@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultImportSource is the source value of imported elevations when the CSV has no source column
+const DefaultImportSource = "survey"
+
+// importProvider marks imported elevations in place of an elevation provider
+const importProvider = "import"
+
+// ImportRow is one externally measured elevation read from an import CSV
+type ImportRow struct {
+	Type      string
+	ID        int64
+	Elevation float64
+	// Source overrides the source tag value of this row, e.g. "GPS survey"
+	Source string
+}
+
+// ImportWorkspace returns the workspace of imported data, kept apart from the pipeline files
+func ImportWorkspace() Workspace {
+	return Workspace{Dir: filepath.Join(DefaultWorkspace.Dir, "import"), Gzip: DefaultWorkspace.Gzip}
+}
+
+// ReadImportCSV reads the type, id and ele columns (and an optional source column) of an import CSV
+func ReadImportCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import CSV header: %v", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["ele"]; !ok {
+		if i, ok := columns["elevation"]; ok {
+			columns["ele"] = i
+		}
+	}
+	for _, required := range []string{"type", "id", "ele"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("import CSV is missing the %q column", required)
+		}
+	}
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	var rows []ImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read import CSV: %v", err)
+		}
+
+		row := ImportRow{Type: strings.ToLower(field(record, "type")), Source: field(record, "source")}
+		switch row.Type {
+		case "node", "way", "relation":
+		default:
+			return nil, fmt.Errorf("import CSV line %d: invalid element type %q", line, row.Type)
+		}
+		if row.ID, err = strconv.ParseInt(field(record, "id"), 10, 64); err != nil || row.ID <= 0 {
+			return nil, fmt.Errorf("import CSV line %d: invalid element id %q", line, field(record, "id"))
+		}
+		if row.Elevation, err = strconv.ParseFloat(field(record, "ele"), 64); err != nil {
+			return nil, fmt.Errorf("import CSV line %d: invalid elevation %q", line, field(record, "ele"))
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// elementLookupQuery builds the Overpass query returning the tags and location of the rows' elements
+func elementLookupQuery(rows []ImportRow) string {
+	ids := make(map[string][]string)
+	for _, row := range rows {
+		ids[row.Type] = append(ids[row.Type], strconv.FormatInt(row.ID, 10))
+	}
+	var statements []string
+	for _, elementType := range []string{"node", "way", "relation"} {
+		if len(ids[elementType]) > 0 {
+			statements = append(statements, fmt.Sprintf("  %s(id:%s);", elementType, strings.Join(ids[elementType], ",")))
+		}
+	}
+	return fmt.Sprintf(`
+[out:json][timeout:300];
+(
+%s
+);
+out center meta;
+`, strings.Join(statements, "\n"))
+}
+
+// LookupElements fetches the current tags and location of the elements of an import
+func (e *OverpassExtractor) LookupElements(ctx context.Context, rows []ImportRow) ([]OSMElement, error) {
+	query := elementLookupQuery(rows)
+	e.Queries = append(e.Queries, query)
+	return e.queryOverpass(ctx, query)
+}
+
+// BuildImportedData gives the looked-up elements the imported elevations and sorts them into the
+// active profile's categories. Rows whose element was not found or fits no category are returned
+// as skipped, with the reason.
+func BuildImportedData(rows []ImportRow, elements []OSMElement, format ElevationFormat, source ElevationSource, defaultSource string) (*EnrichedData, map[string]string) {
+	found := make(map[string]OSMElement, len(elements))
+	for _, element := range elements {
+		found[elementKey(element.Type, element.ID)] = element
+	}
+
+	data := &EnrichedData{
+		TrainStations:       []OSMElement{},
+		AlpineHuts:          []OSMElement{},
+		OtherAccommodations: []OSMElement{},
+		Peaks:               []OSMElement{},
+		Shelters:            []OSMElement{},
+	}
+	skipped := make(map[string]string)
+	categorizer := NewElementCategorizer()
+	for _, row := range rows {
+		key := elementKey(row.Type, row.ID)
+		element, ok := found[key]
+		if !ok {
+			skipped[key] = "not found in OSM"
+			continue
+		}
+		category := categorizer.Categorize(element)
+		if category == CategoryUnknown {
+			skipped[key] = "matches no category of the profile"
+			continue
+		}
+
+		elevation := row.Elevation
+		element.ElevationFetched = &elevation
+		element.ElevationProvider = importProvider
+		format.Apply(&element)
+		element.Tags[source.Key] = defaultSource
+		if row.Source != "" {
+			element.Tags[source.Key] = row.Source
+		}
+		bucket := data.Category(categoryToKey(category))
+		*bucket = append(*bucket, element)
+	}
+	return data, skipped
+}
+
+// runImport reads externally measured elevations from a CSV and validates them in the workspace,
+// ready for the upload step
+func runImport(ctx context.Context, ws Workspace, csvFile, country, defaultSource string) error {
+	pipelineLog.Banner("IMPORT - Reading elevations from %s", csvFile)
+	started := time.Now()
+	if err := runStepStartHook(ws, StepImport, country); err != nil {
+		return err
+	}
+
+	file, err := os.Open(csvFile)
+	if err != nil {
+		return fmt.Errorf("failed to open import CSV: %v", err)
+	}
+	rows, err := ReadImportCSV(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return fmt.Errorf("no elevations found in %s", csvFile)
+	}
+	pipelineLog.Info("Read %d elevations from %s", len(rows), csvFile)
+
+	config := NewConfig()
+	config.LoadFromEnv()
+	format, err := resolveElevationFormat(config)
+	if err != nil {
+		return err
+	}
+	source, err := resolveElevationSource(config)
+	if err != nil {
+		return err
+	}
+
+	extractor := NewAPIClientFactory(config, NewLogger("Extractor")).CreateOverpassExtractor()
+	elements, err := extractor.LookupElements(ctx, rows)
+	if err != nil {
+		return fmt.Errorf("failed to look up the imported elements: %v", err)
+	}
+
+	data, skipped := BuildImportedData(rows, elements, format, source, defaultSource)
+	keys := make([]string, 0, len(skipped))
+	for key := range skipped {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		pipelineLog.Warn("Skipping %s: %s", key, skipped[key])
+	}
+
+	enrichedFile := ws.File(DefaultEnrichedDataFile)
+	if err := savePipelineFile(enrichedFile, SchemaEnriched, data); err != nil {
+		return err
+	}
+	pipelineLog.Info("✓ %d of %d imported elevations saved to %s", len(rows)-len(skipped), len(rows), enrichedFile)
+	recordManifestStep(ws, ManifestStep{
+		Step:    StepImport,
+		Sources: []string{csvFile, extractor.OverpassURL},
+		Counts: categoryCounts(func(key string) int {
+			return len(*data.Category(key))
+		}),
+	}, started, []string{csvFile}, []string{enrichedFile})
+
+	return runValidate(ws, country)
+}
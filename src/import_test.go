@@ -0,0 +1,78 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadImportCSV(t *testing.T) {
+	rows, err := ReadImportCSV(strings.NewReader("Type,ID,Elevation,Source\nnode,1,2544.4,GPS survey\nWay, 2 ,1720,\n"))
+	if err != nil {
+		t.Fatalf("ReadImportCSV() error = %v", err)
+	}
+	want := []ImportRow{{Type: "node", ID: 1, Elevation: 2544.4, Source: "GPS survey"}, {Type: "way", ID: 2, Elevation: 1720}}
+	if len(rows) != len(want) || rows[0] != want[0] || rows[1] != want[1] {
+		t.Errorf("ReadImportCSV() = %+v, want %+v", rows, want)
+	}
+
+	tests := []struct {
+		name    string
+		csv     string
+		wantErr string
+	}{
+		{"Missing column", "type,id\nnode,1\n", `missing the "ele" column`},
+		{"Bad type", "type,id,ele\narea,1,100\n", "invalid element type"},
+		{"Bad id", "type,id,ele\nnode,x,100\n", "invalid element id"},
+		{"Bad elevation", "type,id,ele\nnode,1,high\n", "line 2: invalid elevation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadImportCSV(strings.NewReader(tt.csv)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ReadImportCSV() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestElementLookupQuery(t *testing.T) {
+	query := elementLookupQuery([]ImportRow{{Type: "way", ID: 5}, {Type: "node", ID: 1}, {Type: "node", ID: 2}})
+	for _, want := range []string{"node(id:1,2);", "way(id:5);", "out center meta;"} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if strings.Contains(query, "relation") {
+		t.Errorf("query selects relations without any:\n%s", query)
+	}
+}
+
+func TestBuildImportedData(t *testing.T) {
+	useProfile(t, DefaultProfile())
+	rows := []ImportRow{
+		{Type: "node", ID: 1, Elevation: 2544.44},
+		{Type: "node", ID: 2, Elevation: 1700, Source: "GPS survey"},
+		{Type: "node", ID: 3, Elevation: 900},
+		{Type: "node", ID: 4, Elevation: 100},
+	}
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak"}},
+		{Type: "node", ID: 2, Lat: 45.5, Lon: 25.5, Tags: map[string]string{"tourism": "alpine_hut"}},
+		{Type: "node", ID: 3, Lat: 45.0, Lon: 25.0, Tags: map[string]string{"amenity": "bench"}},
+	}
+	source := ElevationSource{Key: "ele:source"}
+
+	data, skipped := BuildImportedData(rows, elements, defaultElevationFormat, source, DefaultImportSource)
+	if len(data.Peaks) != 1 || len(data.AlpineHuts) != 1 {
+		t.Fatalf("BuildImportedData() = %+v, want one peak and one hut", data)
+	}
+	peak := data.Peaks[0]
+	if peak.Tags["ele"] != "2544.4" || peak.Tags["ele:source"] != DefaultImportSource || *peak.ElevationFetched != 2544.4 {
+		t.Errorf("peak = %+v, want ele=2544.4 from %s", peak, DefaultImportSource)
+	}
+	if got := data.AlpineHuts[0].Tags["ele:source"]; got != "GPS survey" {
+		t.Errorf("hut ele:source = %q, want the row's source", got)
+	}
+	if len(skipped) != 2 || skipped["node/3"] == "" || skipped["node/4"] == "" {
+		t.Errorf("skipped = %v, want the bench and the missing node", skipped)
+	}
+}
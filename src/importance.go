@@ -0,0 +1,52 @@
+package main
+
+import "sort"
+
+// importanceWeights scores the tags that make an element worth enriching first: named and
+// wiki-linked features, and stations that serve passenger trains rather than halts
+var importanceWeights = []struct {
+	Selector string
+	Weight   int
+}{
+	{"name", 4},
+	{"wikidata", 3},
+	{"wikipedia", 2},
+	{"railway=station", 2},
+	{"public_transport=station", 1},
+	{"train=yes", 1},
+	{"uic_ref", 1},
+	{"railway:ref", 1},
+}
+
+// elementImportance sums the weights of the importance tags an element has
+func elementImportance(element OSMElement) int {
+	score := 0
+	for _, w := range importanceWeights {
+		if selector, err := ParseTagSelector(w.Selector); err == nil && selector.Matches(element.Tags) {
+			score += w.Weight
+		}
+	}
+	return score
+}
+
+// sortByImportance returns the elements ordered from most to least important, keeping the
+// Overpass order of equally important ones, so a --limit processes the most valuable first
+func sortByImportance(elements []OSMElement) []OSMElement {
+	type scored struct {
+		element OSMElement
+		score   int
+	}
+	ranked := make([]scored, len(elements))
+	for i, element := range elements {
+		ranked[i] = scored{element, elementImportance(element)}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+
+	sorted := make([]OSMElement, len(ranked))
+	for i, r := range ranked {
+		sorted[i] = r.element
+	}
+	return sorted
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestElementImportance(t *testing.T) {
+	tests := []struct {
+		name string
+		tags map[string]string
+		want int
+	}{
+		{"Untagged", map[string]string{"tourism": "hotel"}, 0},
+		{"Named", map[string]string{"name": "Cabana Omu"}, 4},
+		{"Wiki-linked", map[string]string{"name": "Omu", "wikidata": "Q1", "wikipedia": "ro:Omu"}, 9},
+		{"Station", map[string]string{"railway": "station", "public_transport": "station", "train": "yes", "uic_ref": "53"}, 5},
+		{"Halt", map[string]string{"railway": "halt", "train": "yes"}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := elementImportance(OSMElement{Tags: tt.tags}); got != tt.want {
+				t.Errorf("elementImportance(%v) = %d, want %d", tt.tags, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortByImportance(t *testing.T) {
+	elements := []OSMElement{
+		{ID: 1, Tags: map[string]string{"railway": "halt"}},
+		{ID: 2, Tags: map[string]string{"railway": "station", "name": "Brașov"}},
+		{ID: 3, Tags: map[string]string{"railway": "halt", "name": "Timișu de Sus"}},
+		{ID: 4, Tags: map[string]string{"railway": "halt"}},
+	}
+	var ids []int64
+	for _, element := range sortByImportance(elements) {
+		ids = append(ids, element.ID)
+	}
+	if want := []int64{2, 3, 1, 4}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("sortByImportance() order = %v, want %v", ids, want)
+	}
+	if elements[0].ID != 1 {
+		t.Error("sortByImportance() reordered its input")
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultRejectsFile stores the elements rejected during an interactive --review
+const DefaultRejectsFile = "output/rejects.json"
+
+// RejectedElement is an edit a reviewer refused
+type RejectedElement struct {
+	ElementType string `json:"element_type"`
+	ElementID   int64  `json:"element_id"`
+	Category    string `json:"category"`
+	Name        string `json:"name,omitempty"`
+	ProposedEle string `json:"proposed_ele"`
+	RejectedAt  string `json:"rejected_at"`
+}
+
+// Rejects is the persisted list of rejected elements; they are never uploaded again
+type Rejects struct {
+	path     string
+	Elements []RejectedElement `json:"elements"`
+}
+
+// LoadRejects loads the rejects file, or starts an empty list when it does not exist
+func LoadRejects(path string) (*Rejects, error) {
+	rejects := &Rejects{path: path}
+	if _, err := os.Stat(path); err == nil {
+		if err := loadJSON(path, rejects); err != nil {
+			return nil, fmt.Errorf("failed to load rejects %s: %v", path, err)
+		}
+	}
+	return rejects, nil
+}
+
+// Contains reports whether an element was rejected
+func (r *Rejects) Contains(elementType string, elementID int64) bool {
+	for _, rejected := range r.Elements {
+		if rejected.ElementType == elementType && rejected.ElementID == elementID {
+			return true
+		}
+	}
+	return false
+}
+
+// Add records a rejected element and saves the file immediately
+func (r *Rejects) Add(category string, element OSMElement) error {
+	r.Elements = append(r.Elements, RejectedElement{
+		ElementType: element.Type,
+		ElementID:   element.ID,
+		Category:    category,
+		Name:        elementName(element),
+		ProposedEle: element.Tags["ele"],
+		RejectedAt:  time.Now().UTC().Format(time.RFC3339),
+	})
+	return saveJSON(r.path, r)
+}
+
+// ExcludeRejected removes previously rejected elements from the data
+func (r *Rejects) ExcludeRejected(data ValidatedData) (ValidatedData, int) {
+	var kept ValidatedData
+	excluded := 0
+	for _, key := range categoryKeys {
+		var elements []OSMElement
+		for _, element := range data.Category(key).ValidElements {
+			if r.Contains(element.Type, element.ID) {
+				excluded++
+				continue
+			}
+			elements = append(elements, element)
+		}
+		*kept.Category(key) = ValidatedCategory{ValidCount: len(elements), ValidElements: elements}
+	}
+	return kept, excluded
+}
+
+// reviewDecision is the answer given for one pending edit
+type reviewDecision int
+
+const (
+	reviewAccept reviewDecision = iota
+	reviewReject
+	reviewSkipAll
+)
+
+// reviewPrompt lists the keys accepted by the review prompt
+const reviewPrompt = "[a]ccept, [r]eject, [s]kip all remaining? "
+
+// readReviewDecision prompts until a valid key is entered; end of input skips the rest
+func readReviewDecision(scanner *bufio.Scanner, out io.Writer) reviewDecision {
+	for {
+		fmt.Fprint(out, reviewPrompt)
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return reviewSkipAll
+		}
+		switch strings.ToLower(strings.TrimSpace(scanner.Text())) {
+		case "a", "y", "yes":
+			return reviewAccept
+		case "r", "n", "no":
+			return reviewReject
+		case "s":
+			return reviewSkipAll
+		}
+	}
+}
+
+// printPendingEdit shows an edit to the reviewer
+func printPendingEdit(out io.Writer, index, total int, category string, element OSMElement) {
+	fmt.Fprintf(out, "\n[%d/%d] %s %s %d", index, total, category, element.Type, element.ID)
+	if name := elementName(element); name != "" {
+		fmt.Fprintf(out, " %q", name)
+	}
+	fmt.Fprintln(out)
+
+	if coords, valid := NewCoordinateExtractor().Extract(element); valid {
+		fmt.Fprintf(out, "  Location: %.6f, %.6f\n", coords.Lat, coords.Lon)
+	}
+
+	sourceKey, sourceValue := eleSourceTag(element.Tags)
+	var current []string
+	for key, value := range element.Tags {
+		if key != "ele" && key != sourceKey {
+			current = append(current, key+"="+value)
+		}
+	}
+	sort.Strings(current)
+	fmt.Fprintf(out, "  Current tags: %s\n", strings.Join(current, ", "))
+	fmt.Fprintf(out, "  Proposed: ele=%s (%s=%s)\n", element.Tags["ele"], sourceKey, sourceValue)
+	fmt.Fprintf(out, "  %s\n", osmLink(element.Type, element.ID))
+}
+
+// ReviewEdits walks through the pending edits and returns only the accepted ones.
+// Rejected elements are added to rejects; skipped ones are left for a later run.
+// Elements for which pending returns false (e.g. already uploaded) are passed through unreviewed.
+func ReviewEdits(in io.Reader, out io.Writer, data ValidatedData, rejects *Rejects, pending func(OSMElement) bool) (ValidatedData, error) {
+	total := 0
+	for _, key := range categoryKeys {
+		for _, element := range data.Category(key).ValidElements {
+			if pending(element) {
+				total++
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(in)
+	var accepted ValidatedData
+	index, acceptedCount, rejectedCount, skippedCount := 0, 0, 0, 0
+	skipAll := false
+
+	for _, key := range categoryKeys {
+		var elements []OSMElement
+		for _, element := range data.Category(key).ValidElements {
+			if !pending(element) {
+				elements = append(elements, element)
+				continue
+			}
+			if skipAll {
+				skippedCount++
+				continue
+			}
+
+			index++
+			printPendingEdit(out, index, total, key, element)
+			switch readReviewDecision(scanner, out) {
+			case reviewAccept:
+				acceptedCount++
+				elements = append(elements, element)
+			case reviewReject:
+				rejectedCount++
+				if err := rejects.Add(key, element); err != nil {
+					return accepted, fmt.Errorf("failed to save rejects: %v", err)
+				}
+			case reviewSkipAll:
+				skipAll = true
+				skippedCount++
+			}
+		}
+		*accepted.Category(key) = ValidatedCategory{ValidCount: len(elements), ValidElements: elements}
+	}
+
+	fmt.Fprintf(out, "\nReview finished: %d accepted, %d rejected, %d skipped\n", acceptedCount, rejectedCount, skippedCount)
+	if rejectedCount > 0 {
+		fmt.Fprintf(out, "Rejected elements saved to %s\n", rejects.path)
+	}
+	return accepted, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func reviewTestData() ValidatedData {
+	var data ValidatedData
+	*data.Category("alpine_huts") = ValidatedCategory{ValidCount: 2, ValidElements: []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.5, Lon: 25.1, Tags: map[string]string{"name": "Cabana Babele", "tourism": "alpine_hut", "ele": "2206", "ele:source": "SRTM"}},
+		{Type: "node", ID: 2, Lat: 45.4, Lon: 25.2, Tags: map[string]string{"tourism": "alpine_hut", "ele": "1800", "ele:source": "SRTM"}},
+	}}
+	*data.Category("train_stations") = ValidatedCategory{ValidCount: 1, ValidElements: []OSMElement{
+		{Type: "node", ID: 3, Lat: 44.4, Lon: 26.1, Tags: map[string]string{"railway": "station", "ele": "80", "ele:source": "SRTM"}},
+	}}
+	return data
+}
+
+func TestReviewEdits(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        string
+		pending      func(OSMElement) bool
+		wantAccepted []int64
+		wantRejected []int64
+	}{
+		{"accept all", "a\na\na\n", nil, []int64{1, 2, 3}, nil},
+		{"reject one", "a\nr\ny\n", nil, []int64{1, 3}, []int64{2}},
+		{"invalid input is asked again", "x\n\nn\na\na\n", nil, []int64{2, 3}, []int64{1}},
+		{"skip all remaining", "a\ns\n", nil, []int64{1}, nil},
+		{"end of input skips the rest", "r\n", nil, nil, []int64{1}},
+		{"not pending passes through", "r\n", func(element OSMElement) bool { return element.ID == 3 }, []int64{1, 2}, []int64{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejects, err := LoadRejects(filepath.Join(t.TempDir(), "rejects.json"))
+			if err != nil {
+				t.Fatalf("LoadRejects() error = %v", err)
+			}
+			pending := tt.pending
+			if pending == nil {
+				pending = func(OSMElement) bool { return true }
+			}
+
+			var out bytes.Buffer
+			accepted, err := ReviewEdits(strings.NewReader(tt.input), &out, reviewTestData(), rejects, pending)
+			if err != nil {
+				t.Fatalf("ReviewEdits() error = %v", err)
+			}
+
+			var acceptedIDs []int64
+			for _, key := range categoryKeys {
+				category := accepted.Category(key)
+				if category.ValidCount != len(category.ValidElements) {
+					t.Errorf("%s: ValidCount = %d, want %d", key, category.ValidCount, len(category.ValidElements))
+				}
+				for _, element := range category.ValidElements {
+					acceptedIDs = append(acceptedIDs, element.ID)
+				}
+			}
+			if !equalIDs(acceptedIDs, tt.wantAccepted) {
+				t.Errorf("accepted = %v, want %v", acceptedIDs, tt.wantAccepted)
+			}
+
+			var rejectedIDs []int64
+			for _, rejected := range rejects.Elements {
+				rejectedIDs = append(rejectedIDs, rejected.ElementID)
+			}
+			if !equalIDs(rejectedIDs, tt.wantRejected) {
+				t.Errorf("rejected = %v, want %v", rejectedIDs, tt.wantRejected)
+			}
+		})
+	}
+}
+
+func equalIDs(got, want []int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestReviewEditsShowsEdit(t *testing.T) {
+	rejects, _ := LoadRejects(filepath.Join(t.TempDir(), "rejects.json"))
+	var out bytes.Buffer
+	if _, err := ReviewEdits(strings.NewReader("s\n"), &out, reviewTestData(), rejects, func(OSMElement) bool { return true }); err != nil {
+		t.Fatalf("ReviewEdits() error = %v", err)
+	}
+
+	for _, want := range []string{
+		`[1/3] alpine_huts node 1 "Cabana Babele"`,
+		"Location: 45.500000, 25.100000",
+		"Current tags: name=Cabana Babele, tourism=alpine_hut",
+		"Proposed: ele=2206 (ele:source=SRTM)",
+		"https://www.openstreetmap.org/node/1",
+		"0 accepted, 0 rejected, 3 skipped",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRejectsPersistAndExclude(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rejects.json")
+	rejects, err := LoadRejects(path)
+	if err != nil {
+		t.Fatalf("LoadRejects() error = %v", err)
+	}
+	data := reviewTestData()
+	if err := rejects.Add("alpine_huts", data.AlpineHuts.ValidElements[1]); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	reloaded, err := LoadRejects(path)
+	if err != nil {
+		t.Fatalf("LoadRejects() error = %v", err)
+	}
+	if !reloaded.Contains("node", 2) || reloaded.Contains("node", 1) {
+		t.Fatalf("reloaded rejects = %+v", reloaded.Elements)
+	}
+	if reloaded.Elements[0].ProposedEle != "1800" {
+		t.Errorf("ProposedEle = %q, want 1800", reloaded.Elements[0].ProposedEle)
+	}
+
+	kept, excluded := reloaded.ExcludeRejected(data)
+	if excluded != 1 {
+		t.Errorf("excluded = %d, want 1", excluded)
+	}
+	if kept.AlpineHuts.ValidCount != 1 || kept.AlpineHuts.ValidElements[0].ID != 1 {
+		t.Errorf("kept alpine huts = %+v", kept.AlpineHuts)
+	}
+	if kept.TrainStations.ValidCount != 1 {
+		t.Errorf("kept train stations = %+v", kept.TrainStations)
+	}
+}
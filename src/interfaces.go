@@ -1,6 +1,9 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"net/http"
+)
 
 // ElevationProvider defines the interface for fetching elevation data
 type ElevationProvider interface {
@@ -9,12 +12,12 @@ type ElevationProvider interface {
 
 // BatchElevationProvider defines the interface for batch elevation fetching
 type BatchElevationProvider interface {
-	BatchGetElevations(locations []LocationRequest) ([]BatchElevationResult, error)
+	BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error)
 }
 
 // DataExtractor defines the interface for extracting OSM data
 type DataExtractor interface {
-	GetAllData() (*OSMData, error)
+	GetAllData(ctx context.Context) (*OSMData, error)
 }
 
 // ElementFilter defines the interface for filtering OSM elements
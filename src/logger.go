@@ -1,12 +1,65 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
+	"log"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// DefaultLogFile is the suggested --log-file destination
+const DefaultLogFile = "output/run.log"
+
+// Log levels, from the most to the least verbose
+const (
+	LogLevelDebug int32 = iota
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+)
+
+// logLevelNames maps --log-level values to levels
+var logLevelNames = map[string]int32{
+	"debug": LogLevelDebug,
+	"info":  LogLevelInfo,
+	"warn":  LogLevelWarn,
+	"error": LogLevelError,
+}
+
+// logLevel is the minimum level written by every logger
+var logLevel = LogLevelInfo
+
+// ParseLogLevel parses debug, info, warn or error
+func ParseLogLevel(name string) (int32, error) {
+	level, ok := logLevelNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("invalid log level %q (expected debug, info, warn or error)", name)
+	}
+	return level, nil
+}
+
+// SetLogLevel sets the minimum level written by every logger
+func SetLogLevel(level int32) {
+	atomic.StoreInt32(&logLevel, level)
+}
+
+// Loggers of the pipeline steps
+var (
+	extractLog  = NewLogger("Extractor")
+	filterLog   = NewLogger("Filter")
+	enrichLog   = NewLogger("Enricher")
+	validateLog = NewLogger("Validator")
+	exportLog   = NewLogger("Export")
+	uploadLog   = NewLogger("Upload")
+	pipelineLog = NewLogger("Pipeline")
+)
+
 // SimpleLogger implements a simple structured logger
 type SimpleLogger struct {
 	prefix string
@@ -17,7 +70,6 @@ type SimpleLogger struct {
 func NewLogger(prefix string) *SimpleLogger {
 	return &SimpleLogger{
 		prefix: prefix,
-		output: os.Stdout,
 	}
 }
 
@@ -31,32 +83,161 @@ func NewLoggerWithOutput(prefix string, output io.Writer) *SimpleLogger {
 
 // Info logs an informational message
 func (l *SimpleLogger) Info(msg string, args ...interface{}) {
-	l.log("INFO", msg, args...)
+	l.log(LogLevelInfo, "INFO", msg, args...)
 }
 
 // Warn logs a warning message
 func (l *SimpleLogger) Warn(msg string, args ...interface{}) {
-	l.log("WARN", msg, args...)
+	l.log(LogLevelWarn, "WARN", msg, args...)
 }
 
 // Error logs an error message
 func (l *SimpleLogger) Error(msg string, args ...interface{}) {
-	l.log("ERROR", msg, args...)
+	l.log(LogLevelError, "ERROR", msg, args...)
 }
 
 // Debug logs a debug message
 func (l *SimpleLogger) Debug(msg string, args ...interface{}) {
-	l.log("DEBUG", msg, args...)
+	l.log(LogLevelDebug, "DEBUG", msg, args...)
 }
 
 // log is the internal logging function
-func (l *SimpleLogger) log(level, msg string, args ...interface{}) {
+func (l *SimpleLogger) log(level int32, name, msg string, args ...interface{}) {
+	if level < atomic.LoadInt32(&logLevel) {
+		return
+	}
 	timestamp := time.Now().Format("2006-01-02 15:04:05")
 	prefix := ""
 	if l.prefix != "" {
 		prefix = fmt.Sprintf("[%s] ", l.prefix)
 	}
-	
+
 	message := fmt.Sprintf(msg, args...)
-	fmt.Fprintf(l.output, "%s [%s] %s%s\n", timestamp, level, prefix, message)
+	fmt.Fprintf(l.writer(), "%s [%s] %s%s\n", timestamp, name, prefix, message)
+}
+
+// Banner logs the heading of a step at info level between two rulers, so the steps stand out
+func (l *SimpleLogger) Banner(msg string, args ...interface{}) {
+	if LogLevelInfo < atomic.LoadInt32(&logLevel) {
+		return
+	}
+	ruler := string(repeat('=', 60))
+	fmt.Fprintf(l.writer(), "\n%s\n%s\n%s\n", ruler, fmt.Sprintf(msg, args...), ruler)
+}
+
+// writer returns the logger's output, resolved on every call so output follows a --log-file
+// redirect of stdout
+func (l *SimpleLogger) writer() io.Writer {
+	if l.output == nil {
+		return os.Stdout
+	}
+	return l.output
+}
+
+// stopLogFile flushes and closes the --log-file, if one is open
+var stopLogFile = func() {}
+
+// startLogging applies LOG_LEVEL and, when LOG_FILE is set, copies everything written to
+// stdout and stderr into that file as well
+func startLogging(config *Config) error {
+	level, err := ParseLogLevel(config.Get("LOG_LEVEL"))
+	if err != nil {
+		return err
+	}
+	SetLogLevel(level)
+
+	path := config.Get("LOG_FILE")
+	if path == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %v", err)
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %v", err)
+	}
+	stop, err := teeOutput(file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+	stopLogFile = func() {
+		stop()
+		file.Close()
+		stopLogFile = func() {}
+	}
+	return nil
+}
+
+// lockedWriter serializes writes from the stdout and stderr copies into the log file
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// teeOutput replaces os.Stdout and os.Stderr with pipes whose content goes both to the
+// terminal and to w. The returned function restores them once everything was copied.
+func teeOutput(w io.Writer) (func(), error) {
+	shared := &lockedWriter{w: w}
+	origStdout, origStderr := os.Stdout, os.Stderr
+
+	var wg sync.WaitGroup
+	var pipes []*os.File
+	redirect := func(orig *os.File) (*os.File, error) {
+		r, pw, err := os.Pipe()
+		if err != nil {
+			return nil, fmt.Errorf("failed to redirect output to the log file: %v", err)
+		}
+		pipes = append(pipes, pw)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			io.Copy(io.MultiWriter(orig, shared), r)
+			r.Close()
+		}()
+		return pw, nil
+	}
+
+	stdout, err := redirect(origStdout)
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := redirect(origStderr)
+	if err != nil {
+		stdout.Close()
+		wg.Wait()
+		return nil, err
+	}
+	os.Stdout, os.Stderr = stdout, stderr
+	log.SetOutput(stderr)
+
+	return func() {
+		os.Stdout, os.Stderr = origStdout, origStderr
+		log.SetOutput(origStderr)
+		for _, pipe := range pipes {
+			pipe.Close()
+		}
+		wg.Wait()
+	}, nil
+}
+
+// registerLogFlags adds --log-level and --log-file and returns a function that applies them when given
+func registerLogFlags(fs *flag.FlagSet) func() {
+	level := fs.String("log-level", "", "Minimum level of log messages: debug, info, warn or error (default: LOG_LEVEL or info)")
+	file := fs.String("log-file", "", "Also write all output to this file, e.g. "+DefaultLogFile+" (default: LOG_FILE)")
+	return func() {
+		if flagWasSet(fs, "log-level") {
+			flagConfig.Set("LOG_LEVEL", *level)
+		}
+		if flagWasSet(fs, "log-file") {
+			flagConfig.Set("LOG_FILE", *file)
+		}
+	}
 }
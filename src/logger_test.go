@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseLogLevel(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected int32
+		wantErr  bool
+	}{
+		{"debug", LogLevelDebug, false},
+		{"INFO", LogLevelInfo, false},
+		{" warn ", LogLevelWarn, false},
+		{"error", LogLevelError, false},
+		{"verbose", 0, true},
+		{"", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			level, err := ParseLogLevel(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseLogLevel() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if level != tt.expected {
+				t.Errorf("Expected level %d, got %d", tt.expected, level)
+			}
+		})
+	}
+}
+
+func TestLoggerLevelFilter(t *testing.T) {
+	t.Cleanup(func() { SetLogLevel(LogLevelInfo) })
+
+	tests := []struct {
+		level    int32
+		expected []string
+	}{
+		{LogLevelDebug, []string{"[DEBUG]", "[INFO]", "[WARN]", "[ERROR]"}},
+		{LogLevelInfo, []string{"[INFO]", "[WARN]", "[ERROR]"}},
+		{LogLevelWarn, []string{"[WARN]", "[ERROR]"}},
+		{LogLevelError, []string{"[ERROR]"}},
+	}
+	for _, tt := range tests {
+		SetLogLevel(tt.level)
+		var buf bytes.Buffer
+		logger := NewLoggerWithOutput("Test", &buf)
+		logger.Debug("d")
+		logger.Info("i")
+		logger.Warn("w")
+		logger.Error("e")
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != len(tt.expected) {
+			t.Fatalf("Level %d: expected %d lines, got %q", tt.level, len(tt.expected), buf.String())
+		}
+		for i, want := range tt.expected {
+			if !strings.Contains(lines[i], want+" [Test] ") {
+				t.Errorf("Level %d: line %q does not contain %s", tt.level, lines[i], want)
+			}
+		}
+	}
+}
+
+func TestStartLoggingWritesLogFile(t *testing.T) {
+	t.Cleanup(func() { SetLogLevel(LogLevelInfo) })
+	path := filepath.Join(t.TempDir(), "logs", "run.log")
+
+	config := NewConfig()
+	config.Set("LOG_LEVEL", "warn")
+	config.Set("LOG_FILE", path)
+	if err := startLogging(config); err != nil {
+		t.Fatalf("startLogging() error = %v", err)
+	}
+	fmt.Println("plain output")
+	fmt.Fprintln(os.Stderr, "error output")
+	NewLogger("Test").Info("hidden")
+	NewLogger("Test").Warn("shown")
+	stopLogFile()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read log file: %v", err)
+	}
+	content := string(data)
+	for _, want := range []string{"plain output", "error output", "[WARN] [Test] shown"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Log file does not contain %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "hidden") {
+		t.Errorf("Log file contains a message below the log level:\n%s", content)
+	}
+
+	config.Set("LOG_LEVEL", "loud")
+	if err := startLogging(config); err == nil {
+		t.Error("Expected an error for an invalid log level")
+	}
+}
+
+func TestLoggerBannerFollowsLevel(t *testing.T) {
+	t.Cleanup(func() { SetLogLevel(LogLevelInfo) })
+
+	var buf bytes.Buffer
+	logger := NewLoggerWithOutput("Test", &buf)
+	logger.Banner("STEP %d: TEST", 1)
+	if !strings.Contains(buf.String(), "STEP 1: TEST\n"+string(repeat('=', 60))) {
+		t.Errorf("Banner() wrote %q, want the heading between rulers", buf.String())
+	}
+
+	buf.Reset()
+	SetLogLevel(LogLevelWarn)
+	logger.Banner("STEP 2: TEST")
+	if buf.Len() != 0 {
+		t.Errorf("Banner() at warn level wrote %q, want nothing", buf.String())
+	}
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,129 +17,406 @@ func main() {
 	// Load .env file if it exists
 	_ = godotenv.Load()
 
+	ctx, stop := signalContext()
+	defer stop()
+	runSummary = NewRunSummary(os.Args[1:])
+
+	if isSubcommand(os.Args[1:]) {
+		if err := runCommand(ctx, rootCommands(), os.Args[1:]); err != nil {
+			fail(ctx, "%v", err)
+		}
+	} else {
+		runLegacy(ctx)
+	}
+	exit(ctx, nil)
+}
+
+// fail logs a fatal error and exits, with status 130 when the run was interrupted by a signal
+func fail(ctx context.Context, format string, args ...interface{}) {
+	if ctx.Err() != nil {
+		log.Printf("Interrupted: "+format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+	exit(ctx, fmt.Errorf(format, args...))
+}
+
+// exit writes the run summary and exits with the status of the run: 0 when complete,
+// 2 with partial failures, 1 on a fatal error and 130 when interrupted
+func exit(ctx context.Context, err error) {
+	if err != nil {
+		notify(runFailedMessage(runSummary.Command, err, ctx.Err() != nil))
+	}
+	code := finishRun(ctx, err)
+	stopLogFile()
+	os.Exit(code)
+}
+
+// runLegacy runs the pipeline steps selected by the original step flags (--extract, --all, ...)
+func runLegacy(ctx context.Context) {
 	// Define command-line flags
 	extract := flag.Bool("extract", false, "Extract data from OSM")
 	filter := flag.Bool("filter", false, "Filter elements without elevation")
 	enrich := flag.Bool("enrich", false, "Enrich with elevation data")
 	validate := flag.Bool("validate", false, "Validate elevation ranges")
 	exportCSV := flag.Bool("export-csv", false, "Export to CSV")
+	exportOSC := flag.Bool("export-osc", false, "Export planned edits as an osmChange (.osc) file for review in JOSM")
+	oscFile := flag.String("osc-file", "", "Output file for --export-osc (default: elevation_changes.osc in the workspace)")
+	preview := flag.Bool("preview", false, "Write an HTML map preview of the enriched elements to output/preview.html")
+	report := flag.Bool("report", false, "Write an HTML report of statistics, elevations, validation failures and changesets to output/report.html")
 	upload := flag.Bool("upload", false, "Upload to OSM")
 	all := flag.Bool("all", false, "Run all steps")
 	dryRun := flag.Bool("dry-run", false, "Dry-run mode (don't upload)")
 	limit := flag.Int("limit", 0, "Limit number of items to process (for testing)")
+	applyCategoryLimits := registerCategoryLimitFlags(flag.CommandLine)
+	applyElementIDs := registerElementIDFlags(flag.CommandLine)
+	applyGPX := registerGPXFlag(flag.CommandLine)
+	applyCSVAllTags := registerCSVAllTagsFlag(flag.CommandLine)
 	oauthInteractive := flag.Bool("oauth-interactive", false, "Interactive OAuth setup")
-	country := flag.String("country", "România", "Country name to target (int_name from OSM)")
+	areaOpts := registerAreaFlags(flag.CommandLine)
 	listCountries := flag.Bool("list-countries", false, "List all available admin_level=2 countries")
+	countriesFormat := flag.String("format", CountryFormatText, "With --list-countries, output format: text, json or csv")
+	refreshCountries := flag.Bool("refresh-countries", false, "With --list-countries, query Overpass even when the cached country list is fresh")
 	processAllCountries := flag.Bool("process-all-countries", false, "Process all available countries sequentially")
+	propose := flag.Bool("propose", false, "Compute exact element diffs and write a signed proposal file")
+	apply := flag.Bool("apply", false, "Execute a previously generated proposal file")
+	audit := flag.Bool("audit", false, "Report existing ele tags that differ from the DEM, without uploading")
+	auditThreshold := flag.Float64("audit-threshold", DefaultAuditThreshold, "With --audit, report differences above this many metres")
+	proposalFile := flag.String("proposal", "", "Proposal file used by --propose and --apply (default: proposal.json in the workspace)")
+	approvedFile := flag.String("approved", "", "Review CSV; with --apply only rows marked approved are uploaded")
+	mergeInputs := flag.String("merge", "", "Comma-separated enriched or validated files to merge into one dataset")
+	mergeOutput := flag.String("merge-output", "output/osm_data_merged.json", "Output file for --merge")
+	mergeRule := flag.String("merge-rule", "first", "Conflict rule for --merge: first, last, mean, min, max")
+	incremental := flag.Bool("incremental", false, "Only extract elements changed since the last successful run and skip already uploaded ones")
+	uploadMode := flag.String("upload-mode", UploadModeDiff, "Upload mode: diff (one osmChange per changeset) or element (one PUT per element)")
+	reupload := flag.Bool("reupload", false, "Upload elements again even if the run ledger records them as already uploaded")
+	retryErrors := flag.String("retry-errors", "", "With --upload, only retry elements that failed with these error classes in the last run (e.g. conflict,network)")
+	review := flag.Bool("review", false, "With --upload, accept or reject each pending edit in the terminal before uploading")
+	countryConcurrency := flag.Int("country-concurrency", 1, "With --process-all-countries, number of countries processed in parallel")
+	includeCountries := flag.String("countries", "", "With --process-all-countries, only process these countries (comma-separated names or ISO codes, or @file with one per line)")
+	excludeCountries := flag.String("exclude-countries", "", "With --process-all-countries, skip these countries (comma-separated names or ISO codes, or @file with one per line)")
+	continent := flag.String("continent", "", "With --process-all-countries, only process countries on these continents (comma-separated, e.g. Europe)")
+	unRegion := flag.String("un-region", "", "With --process-all-countries, only process countries in these UN regions or sub-regions (comma-separated)")
+	configFile := registerConfigFlag(flag.CommandLine)
+	profileFile := flag.String("profile", "", "YAML extraction profile declaring categories and tag selectors (default: built-in profile)")
+	onlyCategory := flag.String("only-category", "", "Only process these categories (comma-separated, e.g. alpine_huts)")
+	applyOSMAPI := registerOSMAPIFlags(flag.CommandLine)
+	commentTemplate := registerCommentTemplateFlag(flag.CommandLine)
+	applyMaxEdits := registerMaxEditsFlag(flag.CommandLine)
+	applyMaxBBox := registerMaxBBoxDiagonalFlag(flag.CommandLine)
+	applyUploadConcurrency := registerUploadConcurrencyFlag(flag.CommandLine, uploadMode)
+	applyMaxFailures := registerMaxConsecutiveFailuresFlag(flag.CommandLine)
+	applyUser := registerRequireUserFlag(flag.CommandLine)
+	applyDripFeed := registerDripFeedFlags(flag.CommandLine)
+	applyElevationRange := registerElevationRangeFlags(flag.CommandLine)
+	applyElevationFormat := registerElevationFormatFlags(flag.CommandLine)
+	applyOverwrite := registerOverwriteFlags(flag.CommandLine)
+	applyLogging := registerLogFlags(flag.CommandLine)
+	applyWorkspace := registerWorkspaceFlag(flag.CommandLine)
 
 	flag.Parse()
 
+	if err := applyConfigFile(*configFile, flag.CommandLine); err != nil {
+		fail(ctx, "%v", err)
+	}
+	applyLogging()
+	applyWorkspace()
+	runConfig := NewConfig()
+	runConfig.LoadFromEnv()
+	if err := startLogging(runConfig); err != nil {
+		fail(ctx, "%v", err)
+	}
+	useWorkspace(runConfig)
+
 	// Handle list-countries flag
 	if *listCountries {
-		if err := runListCountries(); err != nil {
-			log.Fatalf("List countries failed: %v", err)
+		if err := runListCountries(ctx, *countriesFormat, *refreshCountries); err != nil {
+			fail(ctx, "List countries failed: %v", err)
 		}
 		return
 	}
 
+	// Handle merge flag
+	if *mergeInputs != "" {
+		if err := runMerge(splitList(*mergeInputs), *mergeOutput, *mergeRule); err != nil {
+			fail(ctx, "Merge failed: %v", err)
+		}
+		return
+	}
+
+	mode, err := ParseUploadMode(*uploadMode)
+	if err != nil {
+		fail(ctx, "%v", err)
+	}
+
+	area, country, err := areaOpts.resolve(ctx)
+	if err != nil {
+		fail(ctx, "%v", err)
+	}
+
+	if err := useProfileFile(*profileFile); err != nil {
+		fail(ctx, "%v", err)
+	}
+	if err := useOnlyCategories(*onlyCategory); err != nil {
+		fail(ctx, "%v", err)
+	}
+
+	if err := applyOSMAPI(); err != nil {
+		fail(ctx, "%v", err)
+	}
+
+	if err := useCommentTemplate(*commentTemplate); err != nil {
+		fail(ctx, "%v", err)
+	}
+
+	if err := applyMaxEdits(); err != nil {
+		fail(ctx, "%v", err)
+	}
+	if err := applyMaxBBox(); err != nil {
+		fail(ctx, "%v", err)
+	}
+	if err := applyUploadConcurrency(); err != nil {
+		fail(ctx, "%v", err)
+	}
+	if err := applyMaxFailures(); err != nil {
+		fail(ctx, "%v", err)
+	}
+	applyUser()
+	if err := applyDripFeed(); err != nil {
+		fail(ctx, "%v", err)
+	}
+
+	applyElevationRange()
+	applyElevationFormat()
+	applyCategoryLimits()
+	applyOverwrite()
+	applyElementIDs()
+	applyGPX()
+	applyCSVAllTags()
+
 	// Handle process-all-countries flag
 	if *processAllCountries {
-		if err := runProcessAllCountries(*limit, *dryRun, *oauthInteractive); err != nil {
-			log.Fatalf("Process all countries failed: %v", err)
+		if !area.IsCountry() || area.CountryCode != "" {
+			fail(ctx, "--bbox, --area-relation-id, --region and --country-code cannot be combined with --process-all-countries")
+		}
+		countries, err := parseCountryFilter(*includeCountries, *excludeCountries, *continent, *unRegion)
+		if err != nil {
+			fail(ctx, "%v", err)
+		}
+		opts := PipelineOptions{
+			Limit:            *limit,
+			DryRun:           *dryRun,
+			OAuthInteractive: *oauthInteractive,
+			Incremental:      *incremental,
+			UploadMode:       mode,
+			Concurrency:      *countryConcurrency,
+			Countries:        countries,
+		}
+		if err := runProcessAllCountries(ctx, opts); err != nil {
+			fail(ctx, "Process all countries failed: %v", err)
 		}
 		return
 	}
 
 	// Check if any action is specified
-	if !(*extract || *filter || *enrich || *validate || *exportCSV || *upload || *all) {
+	if !(*extract || *filter || *enrich || *validate || *exportCSV || *exportOSC || *preview || *upload || *all || *propose || *apply || *audit || *report) {
 		flag.Usage()
+		fmt.Println("\nCommands (run 'elevate-romania help <command>' for their flags):")
+		fmt.Println("  elevate-romania run --dry-run")
+		fmt.Println("  elevate-romania extract --country Moldova")
+		fmt.Println("  elevate-romania enrich --limit 10")
+		fmt.Println("  elevate-romania export osc --osc-file output/review.osc")
+		fmt.Println("  elevate-romania export preview")
+		fmt.Println("  elevate-romania upload --dry-run")
+		fmt.Println("  elevate-romania audit --threshold 50")
+		fmt.Println("  elevate-romania countries list")
+		fmt.Println("  elevate-romania countries process --concurrency 4 --dry-run")
 		fmt.Println("\nExamples:")
 		fmt.Println("  elevate-romania --all --dry-run")
 		fmt.Println("  elevate-romania --extract --filter")
 		fmt.Println("  elevate-romania --enrich --limit 10")
 		fmt.Println("  elevate-romania --upload --dry-run")
 		fmt.Println("  elevate-romania --upload --oauth-interactive")
+		fmt.Println("  elevate-romania --export-osc --osc-file output/review.osc")
+		fmt.Println("  elevate-romania --enrich --preview")
+		fmt.Println("  elevate-romania --audit --audit-threshold 100")
+		fmt.Println("  elevate-romania --validate --min-ele -5 --max-ele 3000")
+		fmt.Println("  elevate-romania --propose")
+		fmt.Println("  elevate-romania --apply --proposal output/proposal.json")
+		fmt.Println("  elevate-romania --apply --approved output/proposal_review.csv")
 		fmt.Println("  elevate-romania --country \"Moldova\" --extract")
 		fmt.Println("  elevate-romania --list-countries")
+		fmt.Println("  elevate-romania --country-code MD --extract")
+		fmt.Println("  elevate-romania --all --bbox 45.2,22.5,47.8,26.5 --dry-run")
+		fmt.Println("  elevate-romania --all --area-relation-id 123456 --dry-run")
+		fmt.Println("  elevate-romania --all --admin-level 4 --region \"Județul Cluj\" --dry-run")
 		fmt.Println("  elevate-romania --process-all-countries --limit 2000 --dry-run")
+		fmt.Println("  elevate-romania --process-all-countries --country-concurrency 4 --dry-run")
+		fmt.Println("  elevate-romania --process-all-countries --countries \"România,Moldova,BG\" --dry-run")
+		fmt.Println("  elevate-romania --process-all-countries --exclude-countries @excluded.txt --dry-run")
+		fmt.Println("  elevate-romania --process-all-countries --continent Europe --dry-run")
+		fmt.Println("  elevate-romania --all --incremental")
+		fmt.Println("  elevate-romania --all --profile profiles/alpine.yaml")
+		fmt.Println("  elevate-romania --upload --upload-mode element")
+		fmt.Println("  elevate-romania --upload --retry-errors conflict,network")
+		fmt.Println("  elevate-romania --merge a/osm_data_enriched.json,b/osm_data_enriched.json --merge-rule mean")
 		return
 	}
 
-	fmt.Println("=" + string(repeat('=', 60)))
-	fmt.Println("ELEVAȚIE OSM")
-	fmt.Printf("Adding elevation to train stations and accommodations in %s\n", *country)
-	fmt.Printf("Started: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Println("=" + string(repeat('=', 60)))
+	printBanner(country)
 
 	// Create output directory
-	if err := os.MkdirAll("output", 0755); err != nil {
-		log.Fatalf("Failed to create output directory: %v", err)
+	if err := DefaultWorkspace.Create(); err != nil {
+		fail(ctx, "%v", err)
 	}
 
 	// Run steps
 	if *all || *extract {
-		if err := runExtract(*country); err != nil {
-			log.Fatalf("Extract failed: %v", err)
+		if err := runExtract(ctx, ExtractOptions{Country: country, Incremental: *incremental, Area: area, Workspace: DefaultWorkspace}); err != nil {
+			fail(ctx, "Extract failed: %v", err)
 		}
 	}
 
 	if *all || *filter {
-		if err := runFilter(); err != nil {
-			log.Fatalf("Filter failed: %v", err)
+		if err := runFilter(DefaultWorkspace); err != nil {
+			fail(ctx, "Filter failed: %v", err)
 		}
 	}
 
 	if *all || *enrich {
-		if err := runEnrich(*limit); err != nil {
-			log.Fatalf("Enrich failed: %v", err)
+		if err := runEnrich(ctx, DefaultWorkspace, *limit); err != nil {
+			fail(ctx, "Enrich failed: %v", err)
 		}
 	}
 
 	if *all || *validate {
-		if err := runValidate(); err != nil {
-			log.Fatalf("Validate failed: %v", err)
+		if err := runValidate(DefaultWorkspace, country); err != nil {
+			fail(ctx, "Validate failed: %v", err)
 		}
 	}
 
 	if *all || *exportCSV {
-		if err := runExportCSV(); err != nil {
-			log.Fatalf("Export CSV failed: %v", err)
+		if err := runExportCSV(DefaultWorkspace); err != nil {
+			fail(ctx, "Export CSV failed: %v", err)
+		}
+	}
+
+	if *exportOSC {
+		if err := runExportOSC(ctx, *oscFile); err != nil {
+			fail(ctx, "Export OSC failed: %v", err)
+		}
+	}
+
+	if *preview {
+		if err := runPreview(DefaultWorkspace); err != nil {
+			fail(ctx, "Export preview failed: %v", err)
 		}
 	}
 
 	if *all || *upload {
-		// Handle OAuth credentials
-		var oauthConfig *OAuthConfig
-		var err error
-
-		if *oauthInteractive {
-			oauthConfig, err = InteractiveOAuthSetup()
-			if err != nil {
-				log.Fatalf("OAuth setup failed: %v", err)
-			}
-		} else {
-			oauthConfig, err = LoadOAuthConfig()
-			if err != nil {
-				log.Fatalf("Failed to load OAuth config: %v", err)
-			}
+		oauthConfig, isDryRun, err := resolveUploadCredentials(ctx, *oauthInteractive, *dryRun)
+		if err != nil {
+			fail(ctx, "%v", err)
 		}
 
-		isDryRun := *dryRun
-		if !isDryRun && (oauthConfig.ClientID == "" || oauthConfig.ClientSecret == "" || oauthConfig.AccessToken == "") {
-			fmt.Println("\nWarning: OAuth credentials not provided, running in dry-run mode")
-			fmt.Println("Use --oauth-interactive for setup or set OSM_CLIENT_ID, OSM_CLIENT_SECRET, OSM_ACCESS_TOKEN in .env")
-			isDryRun = true
+		if err := runUpload(ctx, oauthConfig, UploadOptions{
+			DryRun:      isDryRun,
+			Country:     country,
+			Incremental: *incremental,
+			Reupload:    *reupload,
+			Mode:        mode,
+			RetryErrors: splitList(*retryErrors),
+			Area:        area,
+			Review:      *review,
+			Workspace:   DefaultWorkspace,
+		}); err != nil {
+			fail(ctx, "Upload failed: %v", err)
 		}
+	}
 
-		if err := runUpload(isDryRun, oauthConfig, *country); err != nil {
-			log.Fatalf("Upload failed: %v", err)
+	if *audit {
+		opts := AuditOptions{Country: country, Area: area, Threshold: *auditThreshold, Limit: *limit}
+		if err := runAudit(ctx, opts); err != nil {
+			fail(ctx, "Audit failed: %v", err)
 		}
 	}
 
-	fmt.Println("\n" + string(repeat('=', 60)))
-	fmt.Println("COMPLETED SUCCESSFULLY!")
-	fmt.Printf("Finished: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Println(string(repeat('=', 60)) + "\n")
+	if *propose {
+		if err := runPropose(ctx, country, *proposalFile); err != nil {
+			fail(ctx, "Propose failed: %v", err)
+		}
+	}
+
+	if *apply {
+		oauthConfig, isDryRun, err := resolveUploadCredentials(ctx, *oauthInteractive, *dryRun)
+		if err != nil {
+			fail(ctx, "%v", err)
+		}
+
+		if err := runApply(ctx, isDryRun, oauthConfig, *proposalFile, *approvedFile); err != nil {
+			fail(ctx, "Apply failed: %v", err)
+		}
+	}
+
+	if *report {
+		if err := runReport(DefaultWorkspace); err != nil {
+			fail(ctx, "Report failed: %v", err)
+		}
+	}
+
+	if *all {
+		notify(countryResultMessage(runSummary.CountryResult(country)))
+	}
+	printCompleted()
+}
+
+// resolveUploadCredentials loads OAuth credentials and falls back to dry-run when they are incomplete
+func resolveUploadCredentials(ctx context.Context, oauthInteractive, dryRun bool) (*OAuthConfig, bool, error) {
+	var oauthConfig *OAuthConfig
+	var err error
+
+	if oauthInteractive {
+		oauthConfig, err = InteractiveOAuthSetup(ctx)
+		if err != nil {
+			return nil, false, fmt.Errorf("OAuth setup failed: %v", err)
+		}
+	} else {
+		oauthConfig, err = LoadOAuthConfig()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to load OAuth config: %v", err)
+		}
+	}
+
+	isDryRun := dryRun
+	if !isDryRun && (oauthConfig.ClientID == "" || oauthConfig.ClientSecret == "" || !oauthConfig.HasToken()) {
+		uploadLog.Warn("OAuth credentials not provided, running in dry-run mode")
+		uploadLog.Warn("Use --oauth-interactive for setup or set OSM_CLIENT_ID, OSM_CLIENT_SECRET, OSM_ACCESS_TOKEN in .env")
+		isDryRun = true
+	}
+
+	return oauthConfig, isDryRun, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// flagWasSet reports whether a flag was given on the command line
+func flagWasSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
 
 func repeat(char rune, count int) []rune {
@@ -147,135 +427,167 @@ func repeat(char rune, count int) []rune {
 	return result
 }
 
+// PipelineOptions configures a full pipeline run for one or more countries
+type PipelineOptions struct {
+	Limit            int
+	DryRun           bool
+	OAuthInteractive bool
+	Incremental      bool
+	UploadMode       string
+	// Concurrency is the number of countries processed in parallel
+	Concurrency int
+	// Countries restricts the run to a subset of countries
+	Countries CountryFilter
+}
+
 // runProcessAllCountries fetches all countries and processes each one with the full pipeline
-func runProcessAllCountries(limit int, dryRun bool, oauthInteractive bool) error {
-	fmt.Println("\n" + string(repeat('=', 60)))
-	fmt.Println("GLOBAL PROCESSING - Processing all countries")
-	fmt.Println(string(repeat('=', 60)))
-	fmt.Printf("Limit per country: %d\n", limit)
-	fmt.Printf("Dry-run mode: %v\n", dryRun)
-	fmt.Printf("Started: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Println(string(repeat('=', 60)))
+func runProcessAllCountries(ctx context.Context, opts PipelineOptions) error {
+	if opts.Concurrency < 1 {
+		opts.Concurrency = 1
+	}
+
+	pipelineLog.Banner("GLOBAL PROCESSING - Processing all countries\nLimit per country: %d\nDry-run mode: %v\nIncremental mode: %v\nCountries in parallel: %d\nStarted: %s",
+		opts.Limit, opts.DryRun, opts.Incremental, opts.Concurrency, time.Now().Format("2006-01-02 15:04:05"))
 
 	// Fetch all countries
-	fmt.Println("\nFetching list of all countries...")
-	countries, err := fetchAllCountries()
+	pipelineLog.Info("Fetching list of all countries...")
+	countries, err := fetchAllCountries(ctx, false)
 	if err != nil {
 		return fmt.Errorf("failed to fetch countries: %v", err)
 	}
 
-	fmt.Printf("\nFound %d countries to process\n", len(countries))
-	
-	// Track statistics
-	successCount := 0
-	failedCountries := []string{}
-	
-	// Process each country
-	for i, country := range countries {
-		countryName := country.Name
-		fmt.Println("\n" + string(repeat('=', 60)))
-		fmt.Printf("Processing country %d/%d: %s\n", i+1, len(countries), countryName)
-		fmt.Println(string(repeat('=', 60)))
-		
-		// Process this country
-		if err := processCountry(countryName, limit, dryRun, oauthInteractive); err != nil {
-			log.Printf("ERROR: Failed to process %s: %v\n", countryName, err)
-			failedCountries = append(failedCountries, countryName)
-			// Continue with next country instead of stopping
-			continue
-		}
-		
-		successCount++
-		
-		// Add delay between countries to be nice to APIs
-		if i < len(countries)-1 {
-			fmt.Println("\nWaiting 5 seconds before processing next country...")
-			time.Sleep(5 * time.Second)
-		}
+	if !opts.Countries.IsEmpty() {
+		total := len(countries)
+		countries = opts.Countries.Apply(countries)
+		pipelineLog.Info("Selected %d of %d countries", len(countries), total)
 	}
-	
-	// Print summary
-	fmt.Println("\n" + string(repeat('=', 80)))
-	fmt.Println("GLOBAL PROCESSING SUMMARY")
-	fmt.Println(string(repeat('=', 80)))
-	fmt.Printf("Total countries: %d\n", len(countries))
-	fmt.Printf("Successfully processed: %d\n", successCount)
-	fmt.Printf("Failed: %d\n", len(failedCountries))
-	
-	if len(failedCountries) > 0 {
-		fmt.Println("\nFailed countries:")
-		for _, c := range failedCountries {
-			fmt.Printf("  - %s\n", c)
-		}
+
+	pipelineLog.Info("Found %d countries to process", len(countries))
+
+	// Resolve credentials once so interactive setup is not repeated for every country
+	oauthConfig, isDryRun, err := resolveUploadCredentials(ctx, opts.OAuthInteractive, opts.DryRun)
+	if err != nil {
+		return err
+	}
+
+	summary := &GlobalSummary{StartedAt: time.Now().UTC().Format(time.RFC3339)}
+	results := make([]CountryResult, len(countries))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			first := true
+			for i := range jobs {
+				if !first {
+					// Add delay between countries to be nice to APIs
+					sleepContext(ctx, 5*time.Second)
+				}
+				first = false
+				if ctx.Err() != nil {
+					results[i] = CountryResult{Country: countries[i].Name, Error: "skipped: interrupted"}
+					continue
+				}
+
+				countryName := countries[i].Name
+				pipelineLog.Banner("Processing country %d/%d: %s", i+1, len(countries), countryName)
+
+				results[i] = runCountry(ctx, countryName, oauthConfig, isDryRun, opts)
+			}
+		}()
+	}
+	for i := range countries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, result := range results {
+		summary.Add(result)
+	}
+	summary.CompletedAt = time.Now().UTC().Format(time.RFC3339)
+	summary.Print()
+	notify(globalSummaryMessage(summary))
+
+	if err := saveJSON(DefaultWorkspace.File(DefaultGlobalSummaryFile), summary); err != nil {
+		return fmt.Errorf("failed to save global summary: %v", err)
+	}
+	pipelineLog.Info("✓ Summary saved to %s", DefaultWorkspace.File(DefaultGlobalSummaryFile))
+
+	if ctx.Err() != nil {
+		return fmt.Errorf("global run interrupted: %v", ctx.Err())
 	}
-	
-	fmt.Printf("\nCompleted: %s\n", time.Now().Format("2006-01-02 15:04:05"))
-	fmt.Println(string(repeat('=', 80)) + "\n")
-	
 	return nil
 }
 
+// runCountry processes one country in its own workspace and records the outcome
+func runCountry(ctx context.Context, country string, oauthConfig *OAuthConfig, dryRun bool, opts PipelineOptions) CountryResult {
+	ws := CountryWorkspace(country)
+	start := time.Now()
+
+	if err := processCountry(ctx, country, ws, oauthConfig, dryRun, opts); err != nil {
+		// Continue with the other countries instead of stopping
+		pipelineLog.Error("Failed to process %s: %v", country, err)
+		runSummary.AddProblem(fmt.Sprintf("%s: %v", country, err))
+		result := CountryResult{
+			Country:   country,
+			Workspace: ws.Dir,
+			Error:     err.Error(),
+			Duration:  time.Since(start).Round(time.Second).String(),
+		}
+		notify(countryResultMessage(result))
+		return result
+	}
+
+	result := summarizeCountry(country, ws, dryRun)
+	result.Success = true
+	result.Duration = time.Since(start).Round(time.Second).String()
+	notify(countryResultMessage(result))
+	return result
+}
+
 // processCountry runs the full pipeline for a single country
-func processCountry(country string, limit int, dryRun bool, oauthInteractive bool) error {
+func processCountry(ctx context.Context, country string, ws Workspace, oauthConfig *OAuthConfig, dryRun bool, opts PipelineOptions) error {
 	// Create output directory
-	if err := os.MkdirAll("output", 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %v", err)
+	if err := ws.Create(); err != nil {
+		return err
 	}
 
 	// Step 1: Extract
-	fmt.Println("\nStep 1: Extract")
-	if err := runExtract(country); err != nil {
+	if err := runExtract(ctx, ExtractOptions{Country: country, Incremental: opts.Incremental, Workspace: ws}); err != nil {
 		return fmt.Errorf("extract failed: %v", err)
 	}
 
 	// Step 2: Filter
-	fmt.Println("\nStep 2: Filter")
-	if err := runFilter(); err != nil {
+	if err := runFilter(ws); err != nil {
 		return fmt.Errorf("filter failed: %v", err)
 	}
 
 	// Step 3: Enrich
-	fmt.Println("\nStep 3: Enrich")
-	if err := runEnrich(limit); err != nil {
+	if err := runEnrich(ctx, ws, opts.Limit); err != nil {
 		return fmt.Errorf("enrich failed: %v", err)
 	}
 
 	// Step 4: Validate
-	fmt.Println("\nStep 4: Validate")
-	if err := runValidate(); err != nil {
+	if err := runValidate(ws, country); err != nil {
 		return fmt.Errorf("validate failed: %v", err)
 	}
 
 	// Step 5: Export CSV
-	fmt.Println("\nStep 5: Export CSV")
-	if err := runExportCSV(); err != nil {
+	if err := runExportCSV(ws); err != nil {
 		return fmt.Errorf("export CSV failed: %v", err)
 	}
 
 	// Step 6: Upload (only if not dry-run)
-	fmt.Println("\nStep 6: Upload")
-	var oauthConfig *OAuthConfig
-	var err error
-
-	if oauthInteractive {
-		oauthConfig, err = InteractiveOAuthSetup()
-		if err != nil {
-			return fmt.Errorf("OAuth setup failed: %v", err)
-		}
-	} else {
-		oauthConfig, err = LoadOAuthConfig()
-		if err != nil {
-			return fmt.Errorf("failed to load OAuth config: %v", err)
-		}
-	}
-
-	isDryRun := dryRun
-	if !isDryRun && (oauthConfig.ClientID == "" || oauthConfig.ClientSecret == "" || oauthConfig.AccessToken == "") {
-		fmt.Println("\nWarning: OAuth credentials not provided, running in dry-run mode")
-		isDryRun = true
-	}
-
-	if err := runUpload(isDryRun, oauthConfig, country); err != nil {
+	if err := runUpload(ctx, oauthConfig, UploadOptions{
+		DryRun:      dryRun,
+		Country:     country,
+		Incremental: opts.Incremental,
+		Mode:        opts.UploadMode,
+		Workspace:   ws,
+	}); err != nil {
 		return fmt.Errorf("upload failed: %v", err)
 	}
 
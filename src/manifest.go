@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// DefaultManifestFile records the provenance of the files written by each pipeline step
+const DefaultManifestFile = "output/manifest.json"
+
+// Pipeline step names used in the manifest
+const (
+	StepExtract   = "extract"
+	StepFilter    = "filter"
+	StepEnrich    = "enrich"
+	StepValidate  = "validate"
+	StepExportCSV = "export-csv"
+	StepUpload    = "upload"
+	StepApply     = "apply"
+	StepImport    = "import"
+)
+
+// RunManifest is the provenance of the pipeline files of a workspace: for the last run of
+// every step, which tool version produced which files from which sources
+type RunManifest struct {
+	ToolVersion string         `json:"tool_version"`
+	UpdatedAt   string         `json:"updated_at"`
+	Steps       []ManifestStep `json:"steps"`
+}
+
+// ManifestStep records one run of a pipeline step
+type ManifestStep struct {
+	Step        string `json:"step"`
+	ToolVersion string `json:"tool_version"`
+	Country     string `json:"country,omitempty"`
+	DryRun      bool   `json:"dry_run,omitempty"`
+	StartedAt   string `json:"started_at"`
+	FinishedAt  string `json:"finished_at"`
+	// Sources are the URLs (or local directories) the step read data from
+	Sources []string `json:"sources,omitempty"`
+	// Queries are the Overpass queries sent by the extract step
+	Queries []string `json:"queries,omitempty"`
+	// Params are the settings of a run command step, compared to find stale steps
+	Params map[string]string `json:"params,omitempty"`
+	Counts map[string]int    `json:"counts,omitempty"`
+	Files  []ManifestFile    `json:"files"`
+}
+
+// ManifestFile is an input or output file of a step with its SHA-256
+type ManifestFile struct {
+	Path   string `json:"path"`
+	Role   string `json:"role"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+// LoadRunManifest reads a manifest; a missing file yields an empty manifest
+func LoadRunManifest(path string) (*RunManifest, error) {
+	manifest := &RunManifest{}
+	if err := loadJSON(path, manifest); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load manifest %s: %v", path, err)
+	}
+	return manifest, nil
+}
+
+// Record replaces the previous run of the step, keeping the steps in the order they first ran
+func (m *RunManifest) Record(step ManifestStep) {
+	m.ToolVersion = appVersion
+	m.UpdatedAt = step.FinishedAt
+	for i := range m.Steps {
+		if m.Steps[i].Step == step.Step {
+			m.Steps[i] = step
+			return
+		}
+	}
+	m.Steps = append(m.Steps, step)
+}
+
+// Step returns the last recorded run of a step
+func (m *RunManifest) Step(name string) (ManifestStep, bool) {
+	for _, step := range m.Steps {
+		if step.Step == name {
+			return step, true
+		}
+	}
+	return ManifestStep{}, false
+}
+
+// manifestFiles hashes the files of a step; files the step did not write are left out
+func manifestFiles(role string, paths ...string) []ManifestFile {
+	var files []ManifestFile
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		hash, err := fileHash(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, ManifestFile{Path: path, Role: role, SHA256: hash, Size: info.Size()})
+	}
+	return files
+}
+
+// recordManifestStep adds a finished step with its input and output files to the manifest of a
+// workspace and to the run summary; files the step rewrites are hashed into step.Files
+// beforehand. Like the pipeline store, the manifest is bookkeeping, so an error is only reported.
+func recordManifestStep(ws Workspace, step ManifestStep, started time.Time, inputs, outputs []string) {
+	step.ToolVersion = appVersion
+	step.StartedAt = started.UTC().Format(time.RFC3339)
+	step.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	step.Files = append(step.Files, manifestFiles("input", inputs...)...)
+	step.Files = append(step.Files, manifestFiles("output", outputs...)...)
+
+	path := ws.File(DefaultManifestFile)
+	manifest, err := LoadRunManifest(path)
+	if err == nil {
+		manifest.Record(step)
+		err = saveJSON(path, manifest)
+	}
+	if err != nil {
+		pipelineLog.Warn("Failed to update manifest: %v", err)
+	}
+
+	runSummary.AddStep(SummaryStep{
+		Step:            step.Step,
+		Country:         step.Country,
+		DryRun:          step.DryRun,
+		DurationSeconds: time.Since(started).Round(time.Millisecond).Seconds(),
+		Counts:          step.Counts,
+	})
+	runStepCompleteHooks(ws, step)
+}
+
+// categoryCounts returns the number of elements per category key
+func categoryCounts(count func(key string) int) map[string]int {
+	counts := make(map[string]int, len(categoryKeys))
+	for _, key := range categoryKeys {
+		counts[key] = count(key)
+	}
+	return counts
+}
+
+// uploadCounts totals the upload statistics of all categories
+func uploadCounts(stats map[string]UploadStats) map[string]int {
+	counts := make(map[string]int)
+	for _, categoryStats := range stats {
+		counts["successful"] += categoryStats.Successful
+		counts["failed"] += categoryStats.Failed
+		counts["skipped"] += categoryStats.Skipped
+		counts["already_has_ele"] += categoryStats.AlreadyHasEle
+	}
+	return counts
+}
+
+// elevationSources returns the data source of every provider in the chain
+func elevationSources(config *Config, names []string) []string {
+	var sources []string
+	for _, name := range names {
+		switch name {
+		case ProviderOpenTopo:
+			sources = append(sources, config.Get("OPENTOPO_URL"))
+		case ProviderOpenElevation:
+			sources = append(sources, config.Get("OPEN_ELEVATION_URL"))
+		case ProviderHGT:
+			sources = append(sources, config.Get("ELEVATION_TILE_DIR"))
+		}
+	}
+	return sources
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRunManifestRecord(t *testing.T) {
+	manifest := &RunManifest{}
+	manifest.Record(ManifestStep{Step: StepExtract, FinishedAt: "1"})
+	manifest.Record(ManifestStep{Step: StepFilter, FinishedAt: "2"})
+	manifest.Record(ManifestStep{Step: StepExtract, FinishedAt: "3", Queries: []string{"q"}})
+
+	if len(manifest.Steps) != 2 || manifest.Steps[0].Step != StepExtract || manifest.Steps[1].Step != StepFilter {
+		t.Fatalf("Expected extract then filter, got %+v", manifest.Steps)
+	}
+	step, ok := manifest.Step(StepExtract)
+	if !ok || step.FinishedAt != "3" || len(step.Queries) != 1 {
+		t.Errorf("Expected the last extract run, got %+v", step)
+	}
+	if manifest.UpdatedAt != "3" || manifest.ToolVersion != appVersion {
+		t.Errorf("Unexpected manifest header %q %q", manifest.UpdatedAt, manifest.ToolVersion)
+	}
+	if _, ok := manifest.Step(StepUpload); ok {
+		t.Error("Expected no upload step")
+	}
+}
+
+func TestRecordManifestStep(t *testing.T) {
+	ws := Workspace{Dir: t.TempDir()}
+	input := ws.File(DefaultRawDataFile)
+	output := ws.File(DefaultFilteredDataFile)
+	if err := os.WriteFile(input, []byte("raw"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(output, []byte("filtered"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	started := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	step := ManifestStep{Step: StepFilter, Counts: map[string]int{"peaks": 2}}
+	recordManifestStep(ws, step, started, []string{input}, []string{output, filepath.Join(ws.Dir, "missing.json")})
+
+	manifest, err := LoadRunManifest(ws.File(DefaultManifestFile))
+	if err != nil {
+		t.Fatalf("LoadRunManifest() error = %v", err)
+	}
+	recorded, ok := manifest.Step(StepFilter)
+	if !ok {
+		t.Fatalf("Filter step not recorded: %+v", manifest)
+	}
+	if recorded.StartedAt != "2024-05-01T10:00:00Z" || recorded.FinishedAt == "" || recorded.Counts["peaks"] != 2 {
+		t.Errorf("Unexpected step %+v", recorded)
+	}
+	if len(recorded.Files) != 2 {
+		t.Fatalf("Expected the input and the existing output, got %+v", recorded.Files)
+	}
+	// SHA-256 of "raw"
+	want := ManifestFile{Path: input, Role: "input", SHA256: "d7439bee24773bcbfa2d0a97947ee36227b10d1022b1a55847e928965bb6bfde", Size: 3}
+	if recorded.Files[0] != want {
+		t.Errorf("Expected %+v, got %+v", want, recorded.Files[0])
+	}
+	if recorded.Files[1].Path != output || recorded.Files[1].Role != "output" || recorded.Files[1].Size != 8 {
+		t.Errorf("Unexpected output file %+v", recorded.Files[1])
+	}
+}
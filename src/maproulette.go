@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultMapRouletteFile holds the elements that failed validation as a MapRoulette challenge
+const DefaultMapRouletteFile = "output/maproulette_invalid.geojson"
+
+// mapRouletteInstruction is the task instruction; MapRoulette fills in {{problem}} per task
+const mapRouletteInstruction = "Check the elevation of this feature: {{problem}}. Add a correct ele tag from a reliable source, or fix its location if it is misplaced."
+
+// ExportMapRouletteChallenge writes invalid elements as a GeoJSON MapRoulette challenge, one
+// task per element. Elements without coordinates cannot become tasks and are counted as skipped.
+func ExportMapRouletteChallenge(invalid map[string][]InvalidElement, outputFile string) (int, int, error) {
+	collection := GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []GeoJSONFeature{},
+	}
+
+	extractor := NewCoordinateExtractor()
+	skipped := 0
+	for _, category := range categoryKeys {
+		for _, item := range invalid[category] {
+			element := item.Element
+			coords, valid := extractor.Extract(element)
+			if !valid {
+				skipped++
+				continue
+			}
+
+			properties := map[string]interface{}{
+				"@id":         fmt.Sprintf("%s/%d", element.Type, element.ID),
+				"category":    category,
+				"name":        elementName(element),
+				"problem":     strings.Join(item.Validation.Errors, "; "),
+				"osm_link":    osmLink(element.Type, element.ID),
+				"instruction": mapRouletteInstruction,
+			}
+			if item.Validation.Elevation != nil {
+				properties["fetched_ele"] = *item.Validation.Elevation
+			}
+
+			collection.Features = append(collection.Features, GeoJSONFeature{
+				Type: "Feature",
+				Geometry: GeoJSONGeometry{
+					Type:        "Point",
+					Coordinates: []float64{coords.Lon, coords.Lat},
+				},
+				Properties: properties,
+			})
+		}
+	}
+
+	if err := saveJSON(outputFile, collection); err != nil {
+		return 0, 0, err
+	}
+	return len(collection.Features), skipped, nil
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExportMapRouletteChallenge(t *testing.T) {
+	elevation := 3100.0
+	validator := NewElevationValidator(0, 2600)
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.6, Lon: 25.5, Tags: map[string]string{"name": "Vârful Omu"}, ElevationFetched: &elevation},
+		{Type: "way", ID: 2, Center: &OSMCenter{Lat: 45.5, Lon: 25.4}},
+		{Type: "way", ID: 3},
+	}
+	var invalid []InvalidElement
+	for _, element := range elements {
+		invalid = append(invalid, InvalidElement{Element: element, Validation: validator.ValidateElement(element)})
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "challenge.geojson")
+	tasks, skipped, err := ExportMapRouletteChallenge(map[string][]InvalidElement{"peaks": invalid}, outputFile)
+	if err != nil {
+		t.Fatalf("ExportMapRouletteChallenge() error = %v", err)
+	}
+	if tasks != 2 || skipped != 1 {
+		t.Errorf("tasks, skipped = %d, %d; want 2, 1", tasks, skipped)
+	}
+
+	var collection GeoJSONFeatureCollection
+	if err := loadJSON(outputFile, &collection); err != nil {
+		t.Fatalf("loadJSON() error = %v", err)
+	}
+	if len(collection.Features) != 2 {
+		t.Fatalf("got %d features, want 2", len(collection.Features))
+	}
+
+	tests := []struct {
+		id         string
+		coords     []float64
+		problem    string
+		fetchedEle interface{}
+	}{
+		{"node/1", []float64{25.5, 45.6}, "Elevation 3100.0m above maximum 2600.0m", 3100.0},
+		{"way/2", []float64{25.4, 45.5}, "No elevation data", nil},
+	}
+	for i, tt := range tests {
+		feature := collection.Features[i]
+		if feature.Properties["@id"] != tt.id {
+			t.Errorf("feature %d: @id = %v, want %s", i, feature.Properties["@id"], tt.id)
+		}
+		if feature.Geometry.Coordinates[0] != tt.coords[0] || feature.Geometry.Coordinates[1] != tt.coords[1] {
+			t.Errorf("%s: coordinates = %v, want %v", tt.id, feature.Geometry.Coordinates, tt.coords)
+		}
+		if feature.Properties["problem"] != tt.problem {
+			t.Errorf("%s: problem = %v, want %q", tt.id, feature.Properties["problem"], tt.problem)
+		}
+		if feature.Properties["fetched_ele"] != tt.fetchedEle {
+			t.Errorf("%s: fetched_ele = %v, want %v", tt.id, feature.Properties["fetched_ele"], tt.fetchedEle)
+		}
+	}
+}
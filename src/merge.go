@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MergeRule determines how conflicting elevations for the same element are resolved
+type MergeRule string
+
+const (
+	MergeRuleFirst MergeRule = "first"
+	MergeRuleLast  MergeRule = "last"
+	MergeRuleMean  MergeRule = "mean"
+	MergeRuleMin   MergeRule = "min"
+	MergeRuleMax   MergeRule = "max"
+)
+
+// ElementProvenance records which input supplied an elevation for a merged element
+type ElementProvenance struct {
+	Source    string   `json:"source"`
+	Elevation *float64 `json:"elevation,omitempty"`
+}
+
+// ParseMergeRule validates a merge rule name
+func ParseMergeRule(value string) (MergeRule, error) {
+	switch rule := MergeRule(strings.ToLower(strings.TrimSpace(value))); rule {
+	case MergeRuleFirst, MergeRuleLast, MergeRuleMean, MergeRuleMin, MergeRuleMax:
+		return rule, nil
+	}
+	return "", fmt.Errorf("unknown merge rule %q (expected first, last, mean, min or max)", value)
+}
+
+// elementMerger deduplicates elements by type and ID across several inputs
+type elementMerger struct {
+	rule      MergeRule
+	format    ElevationFormat
+	order     []string
+	versions  map[string][]OSMElement
+	sources   map[string][]string
+	conflicts int
+}
+
+// newElementMerger creates a merger using the given conflict resolution rule
+func newElementMerger(rule MergeRule) *elementMerger {
+	return &elementMerger{
+		rule:     rule,
+		format:   defaultElevationFormat,
+		versions: make(map[string][]OSMElement),
+		sources:  make(map[string][]string),
+	}
+}
+
+// Add registers elements coming from a single input file
+func (m *elementMerger) Add(source string, elements []OSMElement) {
+	for _, element := range elements {
+		key := elementKey(element.Type, element.ID)
+		if _, seen := m.versions[key]; !seen {
+			m.order = append(m.order, key)
+		}
+		m.versions[key] = append(m.versions[key], element)
+		m.sources[key] = append(m.sources[key], source)
+	}
+}
+
+// Result returns the merged elements in first-seen order
+func (m *elementMerger) Result() []OSMElement {
+	merged := make([]OSMElement, 0, len(m.order))
+	for _, key := range m.order {
+		merged = append(merged, m.resolve(m.versions[key], m.sources[key]))
+	}
+	return merged
+}
+
+// resolve picks the elevation for one element according to the merge rule
+func (m *elementMerger) resolve(versions []OSMElement, sources []string) OSMElement {
+	var provenance []ElementProvenance
+	var elevations []float64
+	for i, version := range versions {
+		if len(version.Provenance) > 0 {
+			// Keep provenance from previously merged inputs
+			provenance = append(provenance, version.Provenance...)
+		} else {
+			provenance = append(provenance, ElementProvenance{Source: sources[i], Elevation: version.ElevationFetched})
+		}
+		if version.ElevationFetched != nil {
+			elevations = append(elevations, *version.ElevationFetched)
+		}
+	}
+
+	result := versions[0]
+	if m.rule == MergeRuleLast {
+		result = versions[len(versions)-1]
+	}
+	result.Tags = copyTags(result.Tags)
+
+	if hasConflict(elevations) {
+		m.conflicts++
+	}
+
+	if len(elevations) > 0 {
+		var chosen float64
+		switch m.rule {
+		case MergeRuleFirst:
+			chosen = elevations[0]
+		case MergeRuleLast:
+			chosen = elevations[len(elevations)-1]
+		case MergeRuleMean:
+			sum := 0.0
+			for _, e := range elevations {
+				sum += e
+			}
+			chosen = sum / float64(len(elevations))
+		case MergeRuleMin, MergeRuleMax:
+			sorted := append([]float64(nil), elevations...)
+			sort.Float64s(sorted)
+			chosen = sorted[0]
+			if m.rule == MergeRuleMax {
+				chosen = sorted[len(sorted)-1]
+			}
+		}
+		result.ElevationFetched = &chosen
+		m.format.Apply(&result)
+	}
+
+	result.Provenance = provenance
+
+	return result
+}
+
+// hasConflict reports whether the elevations disagree
+func hasConflict(elevations []float64) bool {
+	for _, e := range elevations {
+		if e != elevations[0] {
+			return true
+		}
+	}
+	return false
+}
+
+// copyTags returns a shallow copy of a tag map
+func copyTags(tags map[string]string) map[string]string {
+	if tags == nil {
+		return nil
+	}
+	copied := make(map[string]string, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+	return copied
+}
+
+// detectValidatedFormat reports whether a pipeline file uses the validated layout
+// (categories are objects) rather than the enriched layout (categories are arrays)
+func detectValidatedFormat(filename string) (bool, error) {
+	var fields map[string]json.RawMessage
+	if err := loadJSON(filename, &fields); err != nil {
+		if os.IsNotExist(err) {
+			return false, err
+		}
+		return false, fmt.Errorf("failed to parse %s: %v", filename, err)
+	}
+
+	for _, value := range fields {
+		trimmed := strings.TrimSpace(string(value))
+		if strings.HasPrefix(trimmed, "{") {
+			return true, nil
+		}
+		if strings.HasPrefix(trimmed, "[") {
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// runMerge combines several partial enriched or validated files into one dataset
+func runMerge(inputs []string, outputFile string, ruleName string) error {
+	pipelineLog.Banner("MERGE - Combining partial pipeline outputs")
+
+	if len(inputs) < 2 {
+		return fmt.Errorf("merge needs at least two input files")
+	}
+
+	rule, err := ParseMergeRule(ruleName)
+	if err != nil {
+		return err
+	}
+
+	config := NewConfig()
+	config.LoadFromEnv()
+	format, err := resolveElevationFormat(config)
+	if err != nil {
+		return err
+	}
+
+	validated, err := detectValidatedFormat(inputs[0])
+	if err != nil {
+		return err
+	}
+
+	mergers := make(map[string]*elementMerger)
+	for _, key := range categoryKeys {
+		mergers[key] = newElementMerger(rule)
+		mergers[key].format = format
+	}
+	invalidCounts := make(map[string]int)
+
+	for _, input := range inputs {
+		isValidated, err := detectValidatedFormat(input)
+		if err != nil {
+			return err
+		}
+		if isValidated != validated {
+			return fmt.Errorf("%s has a different format than %s; merge enriched and validated files separately", input, inputs[0])
+		}
+
+		if validated {
+			var data ValidatedData
+			if err := loadPipelineFile(input, SchemaValidated, &data); err != nil {
+				return fmt.Errorf("failed to load %s: %v", input, err)
+			}
+			for _, key := range categoryKeys {
+				mergers[key].Add(input, data.Category(key).ValidElements)
+				invalidCounts[key] += data.Category(key).InvalidCount
+			}
+		} else {
+			var data EnrichedData
+			if err := loadPipelineFile(input, SchemaEnriched, &data); err != nil {
+				return fmt.Errorf("failed to load %s: %v", input, err)
+			}
+			for _, key := range categoryKeys {
+				mergers[key].Add(input, *data.Category(key))
+			}
+		}
+		pipelineLog.Info("Loaded %s", input)
+	}
+
+	var output pipelineFile
+	kind := SchemaEnriched
+	if validated {
+		kind = SchemaValidated
+		var data ValidatedData
+		for _, key := range categoryKeys {
+			elements := mergers[key].Result()
+			*data.Category(key) = ValidatedCategory{
+				ValidCount:    len(elements),
+				InvalidCount:  invalidCounts[key],
+				ValidElements: elements,
+			}
+		}
+		output = &data
+	} else {
+		var data EnrichedData
+		for _, key := range categoryKeys {
+			*data.Category(key) = mergers[key].Result()
+		}
+		output = &data
+	}
+
+	if err := savePipelineFile(outputFile, kind, output); err != nil {
+		return err
+	}
+
+	pipelineLog.Info("✓ Merged %d files using rule %q", len(inputs), rule)
+	for _, key := range categoryKeys {
+		pipelineLog.Info("  %s: %d elements (%d elevation conflicts)", key, len(mergers[key].order), mergers[key].conflicts)
+	}
+	pipelineLog.Info("✓ Merged data saved to %s", outputFile)
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func TestParseMergeRule(t *testing.T) {
+	for _, value := range []string{"first", "LAST", " mean ", "min", "max"} {
+		if _, err := ParseMergeRule(value); err != nil {
+			t.Errorf("ParseMergeRule(%q) error = %v", value, err)
+		}
+	}
+	if _, err := ParseMergeRule("median"); err == nil {
+		t.Error("ParseMergeRule(median) should fail")
+	}
+}
+
+func TestElementMergerResolvesConflicts(t *testing.T) {
+	a := []OSMElement{
+		{Type: "node", ID: 1, ElevationFetched: floatPtr(100), Tags: map[string]string{"ele": "100.0"}},
+		{Type: "node", ID: 2, ElevationFetched: floatPtr(50), Tags: map[string]string{"ele": "50.0"}},
+	}
+	b := []OSMElement{
+		{Type: "node", ID: 1, ElevationFetched: floatPtr(110), Tags: map[string]string{"ele": "110.0"}},
+		{Type: "way", ID: 1, ElevationFetched: floatPtr(70), Tags: map[string]string{"ele": "70.0"}},
+	}
+
+	tests := []struct {
+		rule     MergeRule
+		expected float64
+	}{
+		{MergeRuleFirst, 100},
+		{MergeRuleLast, 110},
+		{MergeRuleMean, 105},
+		{MergeRuleMin, 100},
+		{MergeRuleMax, 110},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.rule), func(t *testing.T) {
+			merger := newElementMerger(tt.rule)
+			merger.Add("a.json", a)
+			merger.Add("b.json", b)
+			merged := merger.Result()
+
+			if len(merged) != 3 {
+				t.Fatalf("Expected 3 deduplicated elements, got %d", len(merged))
+			}
+			if merged[0].ID != 1 || merged[0].Type != "node" {
+				t.Fatalf("Expected node/1 first, got %s/%d", merged[0].Type, merged[0].ID)
+			}
+			if *merged[0].ElevationFetched != tt.expected {
+				t.Errorf("Elevation = %.1f, want %.1f", *merged[0].ElevationFetched, tt.expected)
+			}
+			if len(merged[0].Provenance) != 2 || merged[0].Provenance[1].Source != "b.json" {
+				t.Errorf("Unexpected provenance: %+v", merged[0].Provenance)
+			}
+			if merger.conflicts != 1 {
+				t.Errorf("Expected 1 conflict, got %d", merger.conflicts)
+			}
+		})
+	}
+
+	if a[0].Tags["ele"] != "100.0" {
+		t.Error("Merging should not modify input tags")
+	}
+}
+
+func TestRunMergeEnrichedFiles(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "a.json")
+	second := filepath.Join(dir, "b.json")
+	output := filepath.Join(dir, "merged.json")
+
+	savePipelineFile(first, SchemaEnriched, &EnrichedData{AlpineHuts: []OSMElement{{Type: "node", ID: 1, ElevationFetched: floatPtr(1200)}}})
+	savePipelineFile(second, SchemaEnriched, &EnrichedData{AlpineHuts: []OSMElement{{Type: "node", ID: 1, ElevationFetched: floatPtr(1200)}, {Type: "node", ID: 2, ElevationFetched: floatPtr(900)}}})
+
+	if err := runMerge([]string{first, second}, output, "first"); err != nil {
+		t.Fatalf("runMerge() error = %v", err)
+	}
+
+	var merged EnrichedData
+	if err := loadJSON(output, &merged); err != nil {
+		t.Fatalf("loadJSON() error = %v", err)
+	}
+	if len(merged.AlpineHuts) != 2 {
+		t.Errorf("Expected 2 alpine huts after merge, got %d", len(merged.AlpineHuts))
+	}
+}
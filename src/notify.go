@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// notifyTimeout bounds a webhook post so a slow chat service never holds up the run
+const notifyTimeout = 10 * time.Second
+
+// WebhookNotifier posts run notifications to a Slack, Discord or compatible incoming webhook
+type WebhookNotifier struct {
+	URL    string
+	client *http.Client
+}
+
+// NewWebhookNotifier returns the notifier configured by NOTIFY_WEBHOOK_URL, or nil when unset
+func NewWebhookNotifier(config *Config) *WebhookNotifier {
+	url := strings.TrimSpace(config.Get("NOTIFY_WEBHOOK_URL"))
+	if url == "" {
+		return nil
+	}
+	return &WebhookNotifier{URL: url, client: &http.Client{Timeout: notifyTimeout}}
+}
+
+// webhookPayload encodes a message for the webhook: Discord reads "content", Slack and
+// Slack-compatible services (Mattermost, Rocket.Chat) read "text"
+func webhookPayload(url, text string) ([]byte, error) {
+	key := "text"
+	if strings.Contains(url, "discord.com/api/webhooks") || strings.Contains(url, "discordapp.com/api/webhooks") {
+		key = "content"
+	}
+	return json.Marshal(map[string]string{key: text})
+}
+
+// Notify posts a message; a nil notifier does nothing
+func (n *WebhookNotifier) Notify(text string) error {
+	if n == nil {
+		return nil
+	}
+	payload, err := webhookPayload(n.URL, text)
+	if err != nil {
+		return err
+	}
+	resp, err := n.client.Post(n.URL, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// notify posts a message to the configured webhook. A notification is a courtesy, so a
+// failure is only reported.
+func notify(text string) {
+	config := NewConfig()
+	config.LoadFromEnv()
+	if err := NewWebhookNotifier(config).Notify(text); err != nil {
+		pipelineLog.Warn("Failed to send notification: %v", err)
+	}
+}
+
+// countryResultMessage describes the outcome of one country
+func countryResultMessage(result CountryResult) string {
+	if !result.Success {
+		return fmt.Sprintf("❌ %s: %s failed after %s: %s", commandName, result.Country, result.Duration, result.Error)
+	}
+	return fmt.Sprintf("✅ %s: %s completed in %s: %d valid elements, %d uploaded, %d failed uploads",
+		commandName, result.Country, result.Duration, result.ValidElements, result.Uploaded, result.FailedUploads)
+}
+
+// globalSummaryMessage describes the outcome of a global run
+func globalSummaryMessage(s *GlobalSummary) string {
+	icon := "✅"
+	if s.Failed > 0 {
+		icon = "⚠️"
+	}
+	message := fmt.Sprintf("%s %s: global run finished, %d of %d countries succeeded, %d uploaded, %d failed uploads",
+		icon, commandName, s.Successful, len(s.Countries), s.Uploaded, s.FailedUploads)
+	var failed []string
+	for _, c := range s.Countries {
+		if !c.Success {
+			failed = append(failed, c.Country)
+		}
+	}
+	if len(failed) > 0 {
+		message += "\nFailed: " + strings.Join(failed, ", ")
+	}
+	return message
+}
+
+// runFailedMessage describes a run that stopped with an error
+func runFailedMessage(command string, err error, interrupted bool) string {
+	if command == "" {
+		command = "run"
+	}
+	if interrupted {
+		return fmt.Sprintf("⏹️ %s %s was interrupted: %v", commandName, command, err)
+	}
+	return fmt.Sprintf("❌ %s %s failed: %v", commandName, command, err)
+}
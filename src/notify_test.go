@@ -0,0 +1,110 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebhookPayload(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		key  string
+	}{
+		{"Slack", "https://hooks.slack.com/services/T000/B000/XXXX", "text"},
+		{"Discord", "https://discord.com/api/webhooks/123/abc", "content"},
+		{"Legacy Discord host", "https://discordapp.com/api/webhooks/123/abc", "content"},
+		{"Mattermost", "https://chat.example.org/hooks/xyz", "text"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := webhookPayload(tt.url, "done")
+			if err != nil {
+				t.Fatalf("webhookPayload() error = %v", err)
+			}
+			var decoded map[string]string
+			if err := json.Unmarshal(payload, &decoded); err != nil {
+				t.Fatalf("Invalid JSON %s: %v", payload, err)
+			}
+			if len(decoded) != 1 || decoded[tt.key] != "done" {
+				t.Errorf("Expected {%q: done}, got %s", tt.key, payload)
+			}
+		})
+	}
+}
+
+func TestWebhookNotifierNotify(t *testing.T) {
+	var received string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	config := NewConfig()
+	config.Set("NOTIFY_WEBHOOK_URL", server.URL)
+	notifier := NewWebhookNotifier(config)
+	if err := notifier.Notify("România completed"); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if received != `{"text":"România completed"}` {
+		t.Errorf("Unexpected payload %s", received)
+	}
+
+	status = http.StatusNotFound
+	if err := notifier.Notify("again"); err == nil {
+		t.Error("Expected an error for a 404 answer")
+	}
+
+	// Without NOTIFY_WEBHOOK_URL nothing is sent
+	disabled := NewWebhookNotifier(NewConfig())
+	if disabled != nil || disabled.Notify("ignored") != nil {
+		t.Error("Expected a nil notifier that ignores messages")
+	}
+}
+
+func TestNotificationMessages(t *testing.T) {
+	success := countryResultMessage(CountryResult{Country: "România", Success: true, Duration: "5m0s", ValidElements: 10, Uploaded: 8, FailedUploads: 2})
+	if !strings.Contains(success, "România completed in 5m0s: 10 valid elements, 8 uploaded, 2 failed uploads") {
+		t.Errorf("Unexpected success message %q", success)
+	}
+	failure := countryResultMessage(CountryResult{Country: "Moldova", Duration: "1m0s", Error: "extract failed"})
+	if !strings.Contains(failure, "Moldova failed after 1m0s: extract failed") {
+		t.Errorf("Unexpected failure message %q", failure)
+	}
+
+	summary := &GlobalSummary{}
+	summary.Add(CountryResult{Country: "România", Success: true, Uploaded: 8})
+	summary.Add(CountryResult{Country: "Moldova"})
+	global := globalSummaryMessage(summary)
+	if !strings.Contains(global, "1 of 2 countries succeeded, 8 uploaded") || !strings.HasSuffix(global, "Failed: Moldova") {
+		t.Errorf("Unexpected global message %q", global)
+	}
+
+	if got := runFailedMessage("", errors.New("boom"), false); !strings.Contains(got, "run failed: boom") {
+		t.Errorf("Unexpected run failure message %q", got)
+	}
+	if got := runFailedMessage("upload", errors.New("signal"), true); !strings.Contains(got, "upload was interrupted") {
+		t.Errorf("Unexpected interruption message %q", got)
+	}
+}
+
+func TestRunSummaryCountryResult(t *testing.T) {
+	summary := NewRunSummary([]string{"run"})
+	summary.AddStep(SummaryStep{Step: StepValidate, Country: "România", Counts: map[string]int{"peaks": 4, "shelters": 2, "invalid": 3}})
+	summary.AddStep(SummaryStep{Step: StepUpload, Country: "România", Counts: map[string]int{"successful": 5, "failed": 1}})
+	summary.AddStep(SummaryStep{Step: StepUpload, Country: "România", DryRun: true, Counts: map[string]int{"successful": 6}})
+	summary.AddStep(SummaryStep{Step: StepUpload, Country: "Moldova", Counts: map[string]int{"successful": 9}})
+
+	result := summary.CountryResult("România")
+	if !result.Success || result.ValidElements != 6 || result.Uploaded != 5 || result.FailedUploads != 1 {
+		t.Errorf("Unexpected result %+v", result)
+	}
+}
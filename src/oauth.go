@@ -7,20 +7,27 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"golang.org/x/oauth2"
 )
 
-var (
-	redirectURI = "http://127.0.0.1:8080/callback"
-)
+// oauthEnvFile is where credentials and refreshed tokens are persisted
+var oauthEnvFile = ".env"
 
 // OAuthConfig holds OAuth 2.0 configuration
 type OAuthConfig struct {
 	ClientID     string
 	ClientSecret string
 	AccessToken  string
+	RefreshToken string
+	// Expiry is zero for tokens that do not expire
+	Expiry time.Time
+
+	mu     sync.Mutex
+	source oauth2.TokenSource
 }
 
 // LoadOAuthConfig loads OAuth configuration from environment variables or .env file
@@ -28,21 +35,63 @@ func LoadOAuthConfig() (*OAuthConfig, error) {
 	// Load .env file if it exists
 	_ = godotenv.Load()
 
+	// Environment variables take precedence over the --config file
+	settings := NewConfig()
+	settings.LoadFromEnv()
+
+	return oauthConfigFromSettings(settings)
+}
+
+// oauthConfigFromSettings builds the OAuth configuration from loaded settings
+func oauthConfigFromSettings(settings *Config) (*OAuthConfig, error) {
 	config := &OAuthConfig{
-		ClientID:     os.Getenv("OSM_CLIENT_ID"),
-		ClientSecret: os.Getenv("OSM_CLIENT_SECRET"),
-		AccessToken:  os.Getenv("OSM_ACCESS_TOKEN"),
+		ClientID:     settings.Get("OSM_CLIENT_ID"),
+		ClientSecret: settings.Get("OSM_CLIENT_SECRET"),
+		AccessToken:  settings.Get("OSM_ACCESS_TOKEN"),
+		RefreshToken: settings.Get("OSM_REFRESH_TOKEN"),
+	}
+
+	if expiry := settings.Get("OSM_TOKEN_EXPIRY"); expiry != "" {
+		t, err := time.Parse(time.RFC3339, expiry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid OSM_TOKEN_EXPIRY %q: %v", expiry, err)
+		}
+		config.Expiry = t
 	}
 
 	return config, nil
 }
 
+// HasToken reports whether an access token or a refresh token to obtain one is available
+func (c *OAuthConfig) HasToken() bool {
+	return c.AccessToken != "" || c.RefreshToken != ""
+}
+
+// Token returns the stored credentials as an oauth2 token
+func (c *OAuthConfig) Token() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  c.AccessToken,
+		TokenType:    "Bearer",
+		RefreshToken: c.RefreshToken,
+		Expiry:       c.Expiry,
+	}
+}
+
+// setToken stores a freshly issued token; servers may omit the refresh token on refresh
+func (c *OAuthConfig) setToken(token *oauth2.Token) {
+	c.AccessToken = token.AccessToken
+	if token.RefreshToken != "" {
+		c.RefreshToken = token.RefreshToken
+	}
+	c.Expiry = token.Expiry
+}
+
 // SaveOAuthConfig saves OAuth configuration to .env file
 // File permissions are set to 0600 (owner read/write only) for security
 // to prevent unauthorized access to OAuth credentials
 func SaveOAuthConfig(config *OAuthConfig) error {
-	envFile := ".env"
-	
+	envFile := oauthEnvFile
+
 	// Read existing .env if present
 	existingEnv := make(map[string]string)
 	if data, err := os.ReadFile(envFile); err == nil {
@@ -62,6 +111,11 @@ func SaveOAuthConfig(config *OAuthConfig) error {
 	existingEnv["OSM_CLIENT_ID"] = config.ClientID
 	existingEnv["OSM_CLIENT_SECRET"] = config.ClientSecret
 	existingEnv["OSM_ACCESS_TOKEN"] = config.AccessToken
+	existingEnv["OSM_REFRESH_TOKEN"] = config.RefreshToken
+	existingEnv["OSM_TOKEN_EXPIRY"] = ""
+	if !config.Expiry.IsZero() {
+		existingEnv["OSM_TOKEN_EXPIRY"] = config.Expiry.UTC().Format(time.RFC3339)
+	}
 
 	// Write back to file
 	var content strings.Builder
@@ -69,6 +123,8 @@ func SaveOAuthConfig(config *OAuthConfig) error {
 	content.WriteString(fmt.Sprintf("OSM_CLIENT_ID=%s\n", existingEnv["OSM_CLIENT_ID"]))
 	content.WriteString(fmt.Sprintf("OSM_CLIENT_SECRET=%s\n", existingEnv["OSM_CLIENT_SECRET"]))
 	content.WriteString(fmt.Sprintf("OSM_ACCESS_TOKEN=%s\n", existingEnv["OSM_ACCESS_TOKEN"]))
+	content.WriteString(fmt.Sprintf("OSM_REFRESH_TOKEN=%s\n", existingEnv["OSM_REFRESH_TOKEN"]))
+	content.WriteString(fmt.Sprintf("OSM_TOKEN_EXPIRY=%s\n", existingEnv["OSM_TOKEN_EXPIRY"]))
 	
 	// Add other existing env vars that aren't OAuth-related
 	for key, value := range existingEnv {
@@ -80,8 +136,30 @@ func SaveOAuthConfig(config *OAuthConfig) error {
 	return os.WriteFile(envFile, []byte(content.String()), 0600)
 }
 
+// oauthRedirectURI returns the redirect URI registered for the application (OAUTH_REDIRECT_URI)
+func oauthRedirectURI() string {
+	settings := NewConfig()
+	settings.LoadFromEnv()
+	return settings.Get("OAUTH_REDIRECT_URI")
+}
+
+// osmOAuth2Config returns the oauth2 configuration for the authorization server of the configured OSM API
+func osmOAuth2Config(clientID, clientSecret string, scopes ...string) *oauth2.Config {
+	site := osmSiteURL(osmAPIBaseURL())
+	return &oauth2.Config{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  oauthRedirectURI(),
+		Scopes:       scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  site + "/oauth2/authorize",
+			TokenURL: site + "/oauth2/token",
+		},
+	}
+}
+
 // InteractiveOAuthSetup performs interactive OAuth setup
-func InteractiveOAuthSetup() (*OAuthConfig, error) {
+func InteractiveOAuthSetup(ctx context.Context) (*OAuthConfig, error) {
 	reader := bufio.NewReader(os.Stdin)
 
 	fmt.Println(string(repeat('=', 60)))
@@ -97,13 +175,10 @@ func InteractiveOAuthSetup() (*OAuthConfig, error) {
 	clientSecret = strings.TrimSpace(clientSecret)
 
 	fmt.Println("\nStarting OAuth 2.0 Flow")
-	fmt.Println("Make sure your redirect URI is set to: http://127.0.0.1:8080/callback")
-	fmt.Println("A browser window will open for you to authorize the application.")
-	fmt.Print("\nPress Enter to continue...")
-	reader.ReadString('\n')
+	fmt.Printf("Make sure your redirect URI is set to: %s\n", oauthRedirectURI())
 
 	// Start OAuth flow
-	accessToken, err := startOAuthFlow(clientID, clientSecret)
+	token, err := startOAuthFlow(ctx, clientID, clientSecret)
 	if err != nil {
 		return nil, err
 	}
@@ -111,12 +186,12 @@ func InteractiveOAuthSetup() (*OAuthConfig, error) {
 	config := &OAuthConfig{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
-		AccessToken:  accessToken,
 	}
+	config.setToken(token)
 
 	// Save to .env file
 	if err := SaveOAuthConfig(config); err != nil {
-		fmt.Printf("Warning: Failed to save credentials to .env: %v\n", err)
+		uploadLog.Warn("Failed to save credentials to .env: %v", err)
 	} else {
 		fmt.Println("✓ Credentials saved to .env file")
 	}
@@ -126,77 +201,125 @@ func InteractiveOAuthSetup() (*OAuthConfig, error) {
 	return config, nil
 }
 
-// startOAuthFlow performs the OAuth 2.0 authorization flow
-func startOAuthFlow(clientID, clientSecret string) (string, error) {
-	authURL := fmt.Sprintf("https://www.openstreetmap.org/oauth2/authorize?client_id=%s&redirect_uri=%s&response_type=code&scope=read_prefs+write_api",
-		clientID, redirectURI)
+// startOAuthFlow performs the OAuth 2.0 authorization flow.
+// The authorization code is captured by a local callback server on the redirect URI;
+// if that address cannot be served, the code has to be pasted manually.
+func startOAuthFlow(ctx context.Context, clientID, clientSecret string) (*oauth2.Token, error) {
+	oauth2Config := osmOAuth2Config(clientID, clientSecret, "read_prefs", "write_api")
 
-	fmt.Println("\nPlease open this URL in your browser:")
-	fmt.Println(authURL)
+	state, err := newOAuthState()
+	if err != nil {
+		return nil, err
+	}
+	authURL := oauth2Config.AuthCodeURL(state)
 
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Print("\nEnter authorization code: ")
-	code, _ := reader.ReadString('\n')
-	code = strings.TrimSpace(code)
+	var code string
+	callback, err := startOAuthCallbackServer(oauth2Config.RedirectURL, state)
+	if err != nil {
+		uploadLog.Warn("Cannot receive the OAuth callback automatically: %v", err)
+		fmt.Println("\nPlease open this URL in your browser:")
+		fmt.Println(authURL)
+
+		reader := bufio.NewReader(os.Stdin)
+		fmt.Print("\nEnter authorization code: ")
+		code, _ = reader.ReadString('\n')
+		code = strings.TrimSpace(code)
+	} else {
+		defer callback.Close()
+
+		fmt.Println("\nOpening your browser to authorize the application.")
+		fmt.Println("If it does not open, visit this URL:")
+		fmt.Println(authURL)
+		if err := openBrowser(authURL); err != nil {
+			uploadLog.Warn("Failed to open browser: %v", err)
+		}
+
+		fmt.Printf("\nWaiting for authorization (up to %v)...\n", oauthCallbackTimeout)
+		code, err = callback.Wait(ctx, oauthCallbackTimeout)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Println("✓ Authorization code received")
+	}
 
 	// Exchange code for token
-	token, err := exchangeCodeForToken(clientID, clientSecret, code)
+	token, err := exchangeCodeForToken(ctx, oauth2Config, code)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	return token, nil
 }
 
-// exchangeCodeForToken exchanges authorization code for access token
-func exchangeCodeForToken(clientID, clientSecret, code string) (string, error) {
-	oauth2Config := &oauth2.Config{
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		RedirectURL:  redirectURI,
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://www.openstreetmap.org/oauth2/authorize",
-			TokenURL: "https://www.openstreetmap.org/oauth2/token",
-		},
-	}
-
-	ctx := context.Background()
+// exchangeCodeForToken exchanges authorization code for a token, keeping refresh token and expiry
+func exchangeCodeForToken(ctx context.Context, oauth2Config *oauth2.Config, code string) (*oauth2.Token, error) {
 	token, err := oauth2Config.Exchange(ctx, code)
 	if err != nil {
-		return "", fmt.Errorf("failed to exchange token: %v", err)
+		return nil, fmt.Errorf("failed to exchange token: %v", err)
 	}
 
-	return token.AccessToken, nil
+	return token, nil
 }
 
-// CreateOAuthClient creates an authenticated HTTP client
+// CreateOAuthClient creates an authenticated HTTP client.
+// An expired token is refreshed up front so the upload does not fail mid-run;
+// later expiries are refreshed transparently by the client.
 func CreateOAuthClient(config *OAuthConfig) (*oauth2.Config, *http.Client, error) {
-	if config.AccessToken == "" {
+	if !config.HasToken() {
 		return nil, nil, fmt.Errorf("OAuth access token required")
 	}
 
-	oauth2Cfg := &oauth2.Config{
-		ClientID:     config.ClientID,
-		ClientSecret: config.ClientSecret,
-		RedirectURL:  redirectURI,
-		Scopes: []string{
-			"read_prefs",
-			"write_prefs",
-			"write_api",
-		},
-		Endpoint: oauth2.Endpoint{
-			AuthURL:  "https://www.openstreetmap.org/oauth2/authorize",
-			TokenURL: "https://www.openstreetmap.org/oauth2/token",
-		},
-	}
+	oauth2Cfg := osmOAuth2Config(config.ClientID, config.ClientSecret, "read_prefs", "write_prefs", "write_api")
 
-	token := &oauth2.Token{
-		AccessToken: config.AccessToken,
-		TokenType:   "Bearer",
+	source := config.tokenSource(oauth2Cfg)
+	if _, err := source.Token(); err != nil {
+		return nil, nil, fmt.Errorf("%v (rerun with --oauth-interactive to authorize again)", err)
 	}
 
 	ctx := context.Background()
-	client := oauth2Cfg.Client(ctx, token)
+	client := oauth2.NewClient(ctx, source)
 
 	return oauth2Cfg, client, nil
 }
+
+// tokenSource returns the token source shared by all clients of this config,
+// so concurrent uploaders refresh the token only once
+func (c *OAuthConfig) tokenSource(oauth2Cfg *oauth2.Config) oauth2.TokenSource {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.source == nil {
+		base := oauth2Cfg.TokenSource(context.Background(), c.Token())
+		c.source = &savingTokenSource{base: base, config: c}
+	}
+	return c.source
+}
+
+// savingTokenSource records refreshed tokens in the config and persists them to .env
+type savingTokenSource struct {
+	base   oauth2.TokenSource
+	config *OAuthConfig
+	mu     sync.Mutex
+}
+
+// Token returns a valid token, refreshing it when expired
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.base.Token()
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh OAuth token: %v", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if token.AccessToken != s.config.AccessToken {
+		s.config.setToken(token)
+		if err := SaveOAuthConfig(s.config); err != nil {
+			pipelineLog.Warn("Failed to save refreshed token to %s: %v", oauthEnvFile, err)
+		} else {
+			uploadLog.Info("✓ OAuth token refreshed and saved to %s", oauthEnvFile)
+		}
+	}
+
+	return token, nil
+}
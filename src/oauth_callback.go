@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// oauthCallbackTimeout bounds how long we wait for the browser to reach the local callback
+const oauthCallbackTimeout = 5 * time.Minute
+
+// oauthCallbackResult is what the authorization server sent to the redirect URI
+type oauthCallbackResult struct {
+	code string
+	err  error
+}
+
+// oauthCallbackServer is a temporary HTTP server that captures the authorization code
+type oauthCallbackServer struct {
+	listener net.Listener
+	server   *http.Server
+	results  chan oauthCallbackResult
+}
+
+// newOAuthState returns a random value that binds the callback to this authorization request
+func newOAuthState() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate OAuth state: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// oauthCallbackHandler validates the state of callback requests and reports the outcome on results.
+// Requests with a wrong state or without a code are rejected and do not end the wait.
+func oauthCallbackHandler(path, state string, results chan<- oauthCallbackResult) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			http.NotFound(w, r)
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Get("state") != state {
+			http.Error(w, "Invalid OAuth state, please restart the authorization.", http.StatusBadRequest)
+			return
+		}
+
+		var result oauthCallbackResult
+		if errCode := query.Get("error"); errCode != "" {
+			result.err = fmt.Errorf("authorization denied: %s %s", errCode, query.Get("error_description"))
+			fmt.Fprintln(w, "Authorization failed, you can close this window and check the terminal.")
+		} else if code := query.Get("code"); code != "" {
+			result.code = code
+			fmt.Fprintln(w, "Authorization complete, you can close this window and return to the terminal.")
+		} else {
+			http.Error(w, "Missing authorization code.", http.StatusBadRequest)
+			return
+		}
+
+		// Only the first outcome counts; reloads of the page are ignored
+		select {
+		case results <- result:
+		default:
+		}
+	})
+}
+
+// startOAuthCallbackServer listens on the host and port of the redirect URI
+func startOAuthCallbackServer(redirect, state string) (*oauthCallbackServer, error) {
+	u, err := url.Parse(redirect)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect URI %q: %v", redirect, err)
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("redirect URI %q is not a local http address", redirect)
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	listener, err := net.Listen("tcp", u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %v", u.Host, err)
+	}
+
+	results := make(chan oauthCallbackResult, 1)
+	s := &oauthCallbackServer{
+		listener: listener,
+		server: &http.Server{
+			Handler:           oauthCallbackHandler(path, state, results),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		results: results,
+	}
+	go s.server.Serve(listener)
+
+	return s, nil
+}
+
+// Wait blocks until the callback delivers a code, the timeout expires, or ctx is canceled
+func (s *oauthCallbackServer) Wait(ctx context.Context, timeout time.Duration) (string, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case result := <-s.results:
+		return result.code, result.err
+	case <-timer.C:
+		return "", fmt.Errorf("timed out after %v waiting for the OAuth callback", timeout)
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Close shuts the callback server down, letting the final response reach the browser
+func (s *oauthCallbackServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	s.server.Shutdown(ctx)
+}
+
+// openBrowser opens url in the user's default browser
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	return cmd.Start()
+}
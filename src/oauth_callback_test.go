@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOAuthCallbackHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantResult bool
+		wantCode   string
+		wantErr    bool
+	}{
+		{"success", "/callback?code=abc&state=s1", http.StatusOK, true, "abc", false},
+		{"state mismatch", "/callback?code=abc&state=other", http.StatusBadRequest, false, "", false},
+		{"missing state", "/callback?code=abc", http.StatusBadRequest, false, "", false},
+		{"missing code", "/callback?state=s1", http.StatusBadRequest, false, "", false},
+		{"denied", "/callback?error=access_denied&state=s1", http.StatusOK, true, "", true},
+		{"other path", "/favicon.ico", http.StatusNotFound, false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := make(chan oauthCallbackResult, 1)
+			handler := oauthCallbackHandler("/callback", "s1", results)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, httptest.NewRequest("GET", tt.target, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+
+			select {
+			case result := <-results:
+				if !tt.wantResult {
+					t.Fatalf("unexpected result %+v", result)
+				}
+				if result.code != tt.wantCode {
+					t.Errorf("code = %q, want %q", result.code, tt.wantCode)
+				}
+				if (result.err != nil) != tt.wantErr {
+					t.Errorf("err = %v, wantErr %v", result.err, tt.wantErr)
+				}
+			default:
+				if tt.wantResult {
+					t.Fatal("expected a result")
+				}
+			}
+		})
+	}
+}
+
+func TestOAuthCallbackServer(t *testing.T) {
+	server, err := startOAuthCallbackServer("http://127.0.0.1:0/callback", "s1")
+	if err != nil {
+		t.Fatalf("startOAuthCallbackServer: %v", err)
+	}
+	defer server.Close()
+
+	resp, err := http.Get("http://" + server.listener.Addr().String() + "/callback?code=xyz&state=s1")
+	if err != nil {
+		t.Fatalf("callback request: %v", err)
+	}
+	resp.Body.Close()
+
+	code, err := server.Wait(context.Background(), time.Second)
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	if code != "xyz" {
+		t.Errorf("code = %q, want xyz", code)
+	}
+}
+
+func TestOAuthCallbackServerWait(t *testing.T) {
+	server, err := startOAuthCallbackServer("http://127.0.0.1:0/callback", "s1")
+	if err != nil {
+		t.Fatalf("startOAuthCallbackServer: %v", err)
+	}
+	defer server.Close()
+
+	if _, err := server.Wait(context.Background(), 10*time.Millisecond); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("expected timeout error, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := server.Wait(ctx, time.Second); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestStartOAuthCallbackServerInvalidURI(t *testing.T) {
+	for _, uri := range []string{"https://127.0.0.1:0/callback", "://bad"} {
+		if _, err := startOAuthCallbackServer(uri, "s1"); err == nil {
+			t.Errorf("expected error for %q", uri)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestOAuthConfigFromSettings(t *testing.T) {
+	settings := NewConfig()
+	settings.Set("OSM_CLIENT_ID", "id")
+	settings.Set("OSM_CLIENT_SECRET", "secret")
+	settings.Set("OSM_REFRESH_TOKEN", "refresh")
+	settings.Set("OSM_TOKEN_EXPIRY", "2026-01-02T03:04:05Z")
+
+	config, err := oauthConfigFromSettings(settings)
+	if err != nil {
+		t.Fatalf("oauthConfigFromSettings: %v", err)
+	}
+	if !config.HasToken() {
+		t.Error("expected a refresh token to count as credentials")
+	}
+	want := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	if !config.Expiry.Equal(want) {
+		t.Errorf("Expiry = %v, want %v", config.Expiry, want)
+	}
+
+	settings.Set("OSM_TOKEN_EXPIRY", "tomorrow")
+	if _, err := oauthConfigFromSettings(settings); err == nil {
+		t.Error("expected error for invalid expiry")
+	}
+}
+
+func TestSaveOAuthConfigToken(t *testing.T) {
+	oauthEnvFile = filepath.Join(t.TempDir(), ".env")
+	defer func() { oauthEnvFile = ".env" }()
+
+	if err := os.WriteFile(oauthEnvFile, []byte("PROPOSAL_SIGNING_KEY=k\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := &OAuthConfig{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		AccessToken:  "access",
+		RefreshToken: "refresh",
+		Expiry:       time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	if err := SaveOAuthConfig(config); err != nil {
+		t.Fatalf("SaveOAuthConfig: %v", err)
+	}
+
+	data, err := os.ReadFile(oauthEnvFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"OSM_ACCESS_TOKEN=access",
+		"OSM_REFRESH_TOKEN=refresh",
+		"OSM_TOKEN_EXPIRY=2026-01-02T03:04:05Z",
+		"PROPOSAL_SIGNING_KEY=k",
+	} {
+		if !strings.Contains(string(data), line+"\n") {
+			t.Errorf(".env missing %q:\n%s", line, data)
+		}
+	}
+}
+
+func TestSavingTokenSourceRefreshesExpiredToken(t *testing.T) {
+	oauthEnvFile = filepath.Join(t.TempDir(), ".env")
+	defer func() { oauthEnvFile = ".env" }()
+
+	refreshes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "old-refresh" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		refreshes++
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"new-access","token_type":"Bearer","refresh_token":"new-refresh","expires_in":3600}`)
+	}))
+	defer server.Close()
+
+	config := &OAuthConfig{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		AccessToken:  "old-access",
+		RefreshToken: "old-refresh",
+		Expiry:       time.Now().Add(-time.Hour),
+	}
+	oauth2Cfg := &oauth2.Config{
+		ClientID:     "id",
+		ClientSecret: "secret",
+		Endpoint:     oauth2.Endpoint{TokenURL: server.URL},
+	}
+
+	source := config.tokenSource(oauth2Cfg)
+	for i := 0; i < 2; i++ {
+		token, err := source.Token()
+		if err != nil {
+			t.Fatalf("Token: %v", err)
+		}
+		if token.AccessToken != "new-access" {
+			t.Errorf("AccessToken = %q, want new-access", token.AccessToken)
+		}
+	}
+	if refreshes != 1 {
+		t.Errorf("refreshes = %d, want 1", refreshes)
+	}
+	if config.AccessToken != "new-access" || config.RefreshToken != "new-refresh" || !config.Expiry.After(time.Now()) {
+		t.Errorf("config not updated: %+v", config.Token())
+	}
+
+	data, err := os.ReadFile(oauthEnvFile)
+	if err != nil {
+		t.Fatalf("refreshed token not saved: %v", err)
+	}
+	if !strings.Contains(string(data), "OSM_REFRESH_TOKEN=new-refresh\n") {
+		t.Errorf(".env missing refreshed token:\n%s", data)
+	}
+}
+
+func TestSavingTokenSourceExpiredWithoutRefreshToken(t *testing.T) {
+	config := &OAuthConfig{
+		AccessToken: "old-access",
+		Expiry:      time.Now().Add(-time.Hour),
+	}
+	source := config.tokenSource(&oauth2.Config{Endpoint: oauth2.Endpoint{TokenURL: "http://127.0.0.1:0/token"}})
+	if _, err := source.Token(); err == nil {
+		t.Error("expected error for expired token without refresh token")
+	}
+}
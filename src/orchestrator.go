@@ -0,0 +1,198 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PipelineStep is a step of the run command with the default files it reads and writes. A step
+// without an output, like upload, always runs.
+type PipelineStep struct {
+	Name   string
+	Input  string
+	Output string
+	// Params are the settings the output depends on (country, queries, options); the run
+	// stores them in the step's manifest record and a change makes the step stale
+	Params map[string]string
+	// Run runs the step and returns its error already wrapped with the step name
+	Run func() error
+}
+
+// PipelineOrchestrator runs the steps in order up to Until, like make: a step is skipped when
+// the manifest records a run by this version with the same settings whose input and output
+// files are unchanged since
+type PipelineOrchestrator struct {
+	Workspace Workspace
+	Steps     []PipelineStep
+	// Until is the last step to run; empty runs all steps
+	Until string
+	// From is the first step that runs even when it is up to date; the steps after it follow
+	// from their changed inputs
+	From string
+}
+
+// stepIndex returns the position of a step by name, or an error listing the step names
+func (o *PipelineOrchestrator) stepIndex(name string) (int, error) {
+	var names []string
+	for i, step := range o.Steps {
+		if step.Name == name {
+			return i, nil
+		}
+		names = append(names, step.Name)
+	}
+	return 0, fmt.Errorf("unknown step %q (valid: %s)", name, strings.Join(names, ", "))
+}
+
+// staleReason says why a step has to run, or returns "" when its last run is still up to date
+func staleReason(manifest *RunManifest, ws Workspace, step PipelineStep) string {
+	if step.Output == "" {
+		return "always runs"
+	}
+	record, ok := manifest.Step(step.Name)
+	if !ok {
+		return "no recorded run"
+	}
+	if record.ToolVersion != appVersion {
+		return fmt.Sprintf("last run by version %s", record.ToolVersion)
+	}
+	if reason := paramsChange(record.Params, step.Params); reason != "" {
+		return reason
+	}
+
+	files := []struct{ role, name string }{{"input", step.Input}, {"output", step.Output}}
+	for _, file := range files {
+		if file.name == "" {
+			continue
+		}
+		path := ws.File(file.name)
+		recorded := ""
+		for _, f := range record.Files {
+			if f.Role == file.role && f.Path == path {
+				recorded = f.SHA256
+			}
+		}
+		if recorded == "" {
+			return fmt.Sprintf("%s %s not recorded", file.role, path)
+		}
+		hash, err := fileHash(path)
+		if err != nil {
+			return fmt.Sprintf("%s %s missing", file.role, path)
+		}
+		if hash != recorded {
+			return fmt.Sprintf("%s %s changed", file.role, path)
+		}
+	}
+	return ""
+}
+
+// paramsChange describes the first setting that differs between the recorded and the current
+// run, or returns "" when they match
+func paramsChange(recorded, current map[string]string) string {
+	if recorded == nil && len(current) > 0 {
+		return "settings not recorded"
+	}
+	keys := make(map[string]bool)
+	for key := range recorded {
+		keys[key] = true
+	}
+	for key := range current {
+		keys[key] = true
+	}
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	for _, key := range names {
+		was, now := recorded[key], current[key]
+		if was == now {
+			continue
+		}
+		if key == "queries" {
+			return "Overpass queries changed"
+		}
+		return fmt.Sprintf("%s changed from %q to %q", key, was, now)
+	}
+	return ""
+}
+
+// configParams returns the current values of the config keys a step depends on
+func configParams(keys ...string) map[string]string {
+	config := NewConfig()
+	config.LoadFromEnv()
+	params := make(map[string]string, len(keys))
+	for _, key := range keys {
+		params[key] = config.Get(key)
+	}
+	return params
+}
+
+// queriesParam condenses queries into a hash, as the manifest record already lists them
+func queriesParam(queries []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(queries, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordParams adds a step's settings to the manifest record the step just wrote
+func (o *PipelineOrchestrator) recordParams(step PipelineStep) {
+	path := o.Workspace.File(DefaultManifestFile)
+	manifest, err := LoadRunManifest(path)
+	if err == nil {
+		record, ok := manifest.Step(step.Name)
+		if !ok {
+			return
+		}
+		record.Params = step.Params
+		manifest.Record(record)
+		err = saveJSON(path, manifest)
+	}
+	if err != nil {
+		pipelineLog.Warn("Failed to update manifest: %v", err)
+	}
+}
+
+// Run runs the steps up to Until, skipping the up-to-date ones
+func (o *PipelineOrchestrator) Run() error {
+	last := len(o.Steps) - 1
+	if o.Until != "" {
+		i, err := o.stepIndex(o.Until)
+		if err != nil {
+			return fmt.Errorf("--until: %v", err)
+		}
+		last = i
+	}
+	from := len(o.Steps)
+	if o.From != "" {
+		i, err := o.stepIndex(o.From)
+		if err != nil {
+			return fmt.Errorf("--from: %v", err)
+		}
+		from = i
+	}
+
+	for i, step := range o.Steps[:last+1] {
+		if i < from {
+			// Each step records itself in the manifest, so reload it for the next one
+			manifest, err := LoadRunManifest(o.Workspace.File(DefaultManifestFile))
+			if err != nil {
+				return err
+			}
+			reason := staleReason(manifest, o.Workspace, step)
+			if reason == "" {
+				pipelineLog.Info("✓ %s is up to date, skipping", step.Name)
+				continue
+			}
+			pipelineLog.Info("Running %s: %s", step.Name, reason)
+		}
+		if err := step.Run(); err != nil {
+			return err
+		}
+		if step.Params != nil {
+			o.recordParams(step)
+		}
+	}
+	return nil
+}
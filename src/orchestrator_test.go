@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPipelineOrchestratorSkipsUpToDateSteps(t *testing.T) {
+	ws := Workspace{Dir: t.TempDir()}
+	var ran []string
+	// Each fake step appends its name to its input, so outputs only change with their inputs
+	step := func(name, input, output string) PipelineStep {
+		return PipelineStep{Name: name, Input: input, Output: output, Run: func() error {
+			ran = append(ran, name)
+			content := ""
+			if input != "" {
+				raw, err := os.ReadFile(ws.File(input))
+				if err != nil {
+					return err
+				}
+				content = string(raw)
+			}
+			var inputs []string
+			if input != "" {
+				inputs = []string{ws.File(input)}
+			}
+			if err := os.WriteFile(ws.File(output), []byte(content+name), 0644); err != nil {
+				return err
+			}
+			recordManifestStep(ws, ManifestStep{Step: name}, time.Now(), inputs, []string{ws.File(output)})
+			return nil
+		}}
+	}
+	steps := []PipelineStep{
+		step(StepExtract, "", DefaultRawDataFile),
+		step(StepFilter, DefaultRawDataFile, DefaultFilteredDataFile),
+		step(StepEnrich, DefaultFilteredDataFile, DefaultEnrichedDataFile),
+		{Name: StepUpload, Run: func() error {
+			ran = append(ran, StepUpload)
+			return nil
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		until  string
+		from   string
+		change string
+		want   []string
+	}{
+		{"First run up to filter", StepFilter, "", "", []string{StepExtract, StepFilter}},
+		{"Continues with the missing step", StepEnrich, "", "", []string{StepEnrich}},
+		{"Nothing changed", StepEnrich, "", "", nil},
+		{"Edited output is rebuilt", StepEnrich, "", DefaultFilteredDataFile, []string{StepFilter}},
+		{"Forced step reruns the later ones", StepEnrich, StepFilter, "", []string{StepFilter, StepEnrich}},
+		{"Upload always runs", StepUpload, "", "", []string{StepUpload}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.change != "" {
+				if err := os.WriteFile(ws.File(tt.change), []byte("edited"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			ran = nil
+			orchestrator := &PipelineOrchestrator{Workspace: ws, Steps: steps, Until: tt.until, From: tt.from}
+			if err := orchestrator.Run(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if !reflect.DeepEqual(ran, tt.want) {
+				t.Errorf("ran %v, want %v", ran, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineOrchestratorUnknownStep(t *testing.T) {
+	orchestrator := &PipelineOrchestrator{
+		Workspace: Workspace{Dir: t.TempDir()},
+		Steps:     []PipelineStep{{Name: StepExtract}, {Name: StepFilter}},
+		Until:     "publish",
+	}
+	err := orchestrator.Run()
+	if err == nil || !strings.Contains(err.Error(), "extract, filter") {
+		t.Errorf("Run() error = %v, want the valid step names", err)
+	}
+}
+
+func TestPipelineOrchestratorRerunsChangedSettings(t *testing.T) {
+	ws := Workspace{Dir: t.TempDir()}
+	var ran []string
+	// Extract has no input file, so only its settings tell a run for another country apart
+	step := func(name, input, output string, params map[string]string) PipelineStep {
+		return PipelineStep{Name: name, Input: input, Output: output, Params: params, Run: func() error {
+			ran = append(ran, name)
+			var inputs []string
+			if input != "" {
+				inputs = []string{ws.File(input)}
+			}
+			if err := os.WriteFile(ws.File(output), []byte(name), 0644); err != nil {
+				return err
+			}
+			recordManifestStep(ws, ManifestStep{Step: name}, time.Now(), inputs, []string{ws.File(output)})
+			return nil
+		}}
+	}
+	run := func(country, limit string) []string {
+		t.Helper()
+		ran = nil
+		orchestrator := &PipelineOrchestrator{Workspace: ws, Until: StepEnrich, Steps: []PipelineStep{
+			step(StepExtract, "", DefaultRawDataFile, map[string]string{"country": country}),
+			step(StepFilter, DefaultRawDataFile, DefaultFilteredDataFile, map[string]string{}),
+			step(StepEnrich, DefaultFilteredDataFile, DefaultEnrichedDataFile, map[string]string{"limit": limit}),
+		}}
+		if err := orchestrator.Run(); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return ran
+	}
+
+	run("România", "0")
+	if got := run("România", "0"); got != nil {
+		t.Fatalf("unchanged settings ran %v, want nothing", got)
+	}
+	// The fake outputs don't depend on the settings, so only the changed step itself reruns
+	if got, want := run("Moldova", "0"), []string{StepExtract}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed country ran %v, want %v", got, want)
+	}
+	if got, want := run("Moldova", "10"), []string{StepEnrich}; !reflect.DeepEqual(got, want) {
+		t.Errorf("changed limit ran %v, want %v", got, want)
+	}
+
+	manifest, err := LoadRunManifest(ws.File(DefaultManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, _ := manifest.Step(StepExtract)
+	if record.Params["country"] != "Moldova" {
+		t.Errorf("recorded params = %v, want country Moldova", record.Params)
+	}
+}
+
+func TestParamsChange(t *testing.T) {
+	tests := []struct {
+		name     string
+		recorded map[string]string
+		current  map[string]string
+		want     string
+	}{
+		{"Same settings", map[string]string{"country": "România"}, map[string]string{"country": "România"}, ""},
+		{"No settings", nil, nil, ""},
+		{"Recorded without settings", nil, map[string]string{"country": "România"}, "settings not recorded"},
+		{"Country changed", map[string]string{"country": "România"}, map[string]string{"country": "Moldova"}, `country changed from "România" to "Moldova"`},
+		{"Option added", map[string]string{}, map[string]string{"ENRICH_LIMITS": "peaks=5"}, `ENRICH_LIMITS changed from "" to "peaks=5"`},
+		{"Queries changed", map[string]string{"queries": "a"}, map[string]string{"queries": "b"}, "Overpass queries changed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paramsChange(tt.recorded, tt.current); got != tt.want {
+				t.Errorf("paramsChange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
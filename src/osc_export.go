@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultOSCFile is where --export-osc writes the planned edits
+const DefaultOSCFile = "output/elevation_changes.osc"
+
+// WriteOSMChange writes an osmChange document as a standalone .osc file
+func WriteOSMChange(w io.Writer, change *OSMChange) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(change); err != nil {
+		return fmt.Errorf("failed to encode osmChange: %v", err)
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// runExportOSC writes the planned edits for the validated data as a .osc file for review in JOSM
+func runExportOSC(ctx context.Context, outputFile string) error {
+	if outputFile == "" {
+		outputFile = DefaultWorkspace.File(DefaultOSCFile)
+	}
+	exportLog.Banner("EXPORT OSC - Writing planned edits as osmChange")
+
+	var data ValidatedData
+	validatedFile := DefaultWorkspace.File(DefaultValidatedDataFile)
+	if err := loadPipelineFile(validatedFile, SchemaValidated, &data); err != nil {
+		return pipelineLoadError(validatedFile, "validate", err)
+	}
+
+	// Reading elements does not require authentication; the changeset is assigned on upload in JOSM
+	uploader := &OSMUploader{
+		apiClient: NewOSMAPIClient(&http.Client{Timeout: 30 * time.Second}, true),
+		dryRun:    true,
+	}
+
+	change := NewOSMChange()
+	for _, element := range collectAllElements(data) {
+		if _, err := uploader.stageElement(ctx, element, 0, change); err != nil {
+			pipelineLog.Warn("Skipping %s %d: %v", element.Type, element.ID, err)
+		}
+	}
+
+	if change.Len() == 0 {
+		return fmt.Errorf("no edits to export")
+	}
+
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", outputFile, err)
+	}
+	defer file.Close()
+
+	if err := WriteOSMChange(file, change); err != nil {
+		return err
+	}
+
+	exportLog.Info("✓ Exported %d modifications to %s", change.Len(), outputFile)
+	exportLog.Info("  Open it in JOSM (File → Open) to review the tag changes and upload manually")
+
+	return nil
+}
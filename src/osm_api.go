@@ -2,16 +2,33 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// osmSandboxURL is the OSM development server used by --sandbox
+const osmSandboxURL = "https://api06.dev.openstreetmap.org"
+
+// maxPrefetchIDs bounds the element IDs requested in one multi-fetch call, keeping URLs short
+const maxPrefetchIDs = 500
+
 // OSMAPIClient handles OSM API operations
 type OSMAPIClient struct {
-	client *http.Client
-	dryRun bool
+	client  HTTPClient
+	dryRun  bool
+	baseURL string
+
+	// prefetched holds raw element documents fetched by Prefetch, keyed by elementKey
+	prefetchMu sync.Mutex
+	prefetched map[string][]byte
 }
 
 // OSMNode represents a node element in OSM XML
@@ -22,14 +39,19 @@ type OSMNode struct {
 	Node      *NodeData `xml:"node,omitempty"`
 }
 
-// NodeData contains node information
+// NodeData contains node information. Attributes and child elements without a field
+// (visible, timestamp, future API additions) are kept and sent back unchanged, in their
+// original position among the tags.
 type NodeData struct {
-	ID        int64     `xml:"id,attr"`
-	Version   int       `xml:"version,attr"`
-	Changeset int       `xml:"changeset,attr"`
-	Lat       float64   `xml:"lat,attr"`
-	Lon       float64   `xml:"lon,attr"`
-	Tags      []NodeTag `xml:"tag"`
+	ID        int64
+	Version   int
+	Changeset int
+	Lat       float64
+	Lon       float64
+	Attrs     []xml.Attr
+	Tags      []NodeTag
+	Extra     []UnknownChild
+	order     []string
 }
 
 // NodeTag represents a tag on a node
@@ -46,13 +68,16 @@ type OSMWay struct {
 	Way       *WayData `xml:"way,omitempty"`
 }
 
-// WayData contains way information
+// WayData contains way information. Node references come before tags, as in API responses.
 type WayData struct {
-	ID        int64     `xml:"id,attr"`
-	Version   int       `xml:"version,attr"`
-	Changeset int       `xml:"changeset,attr"`
-	Tags      []NodeTag `xml:"tag"`
-	Nodes     []WayNode `xml:"nd"`
+	ID        int64
+	Version   int
+	Changeset int
+	Attrs     []xml.Attr
+	Nodes     []WayNode
+	Tags      []NodeTag
+	Extra     []UnknownChild
+	order     []string
 }
 
 // WayNode represents a node reference in a way
@@ -60,84 +85,497 @@ type WayNode struct {
 	Ref int64 `xml:"ref,attr"`
 }
 
-// NewOSMAPIClient creates a new OSM API client
+// UnknownChild is a child element the tool does not know, preserved verbatim
+type UnknownChild struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+// attr returns the value of the named attribute of the child, or "" without one
+func (c UnknownChild) attr(name string) string {
+	for _, a := range c.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// osmElementXML is the wire form of a node, way or relation. Its children are decoded into
+// one slice in document order, so unknown children are written back where they were.
+type osmElementXML struct {
+	ID        int64          `xml:"id,attr"`
+	Version   int            `xml:"version,attr"`
+	Changeset int            `xml:"changeset,attr,omitempty"`
+	Lat       *float64       `xml:"lat,attr"`
+	Lon       *float64       `xml:"lon,attr"`
+	Attrs     []xml.Attr     `xml:",any,attr"`
+	Children  []UnknownChild `xml:",any"`
+}
+
+// childOrder returns the document order of the children: known names stand for the next
+// child of that kind and "" for the next unknown child, which is also returned
+func (raw osmElementXML) childOrder(known ...string) ([]string, []UnknownChild) {
+	isKnown := make(map[string]bool, len(known))
+	for _, name := range known {
+		isKnown[name] = true
+	}
+	order := make([]string, 0, len(raw.Children))
+	var extra []UnknownChild
+	for _, child := range raw.Children {
+		name := child.XMLName.Local
+		if child.XMLName.Space != "" || !isKnown[name] {
+			name = ""
+			extra = append(extra, child)
+		}
+		order = append(order, name)
+	}
+	return order, extra
+}
+
+// childGroup holds the encoded children of one known kind
+type childGroup struct {
+	name     string
+	children []UnknownChild
+}
+
+// orderChildren lays the known and unknown children out in their decoded order. Edited
+// children keep their slots, added ones follow the last child of their kind, and kinds
+// without a decoded slot come after the others in groups order.
+func orderChildren(order []string, extra []UnknownChild, groups ...childGroup) []UnknownChild {
+	byName := make(map[string][]UnknownChild, len(groups))
+	for _, group := range groups {
+		byName[group.name] = group.children
+	}
+	last := make(map[string]int)
+	for i, name := range order {
+		last[name] = i
+	}
+
+	var out []UnknownChild
+	next := make(map[string]int)
+	nextExtra := 0
+	for i, name := range order {
+		switch {
+		case name == "":
+			if nextExtra < len(extra) {
+				out = append(out, extra[nextExtra])
+				nextExtra++
+			}
+		case i == last[name]:
+			out = append(out, byName[name][next[name]:]...)
+			next[name] = len(byName[name])
+		case next[name] < len(byName[name]):
+			out = append(out, byName[name][next[name]])
+			next[name]++
+		}
+	}
+	for _, group := range groups {
+		out = append(out, group.children[next[group.name]:]...)
+	}
+	return append(out, extra[nextExtra:]...)
+}
+
+// tagChildren encodes tags as child elements
+func tagChildren(tags []NodeTag) []UnknownChild {
+	children := make([]UnknownChild, len(tags))
+	for i, tag := range tags {
+		children[i] = UnknownChild{
+			XMLName: xml.Name{Local: "tag"},
+			Attrs:   []xml.Attr{{Name: xml.Name{Local: "k"}, Value: tag.Key}, {Name: xml.Name{Local: "v"}, Value: tag.Value}},
+		}
+	}
+	return children
+}
+
+// decodeTags reads the tag children
+func decodeTags(children []UnknownChild) []NodeTag {
+	var tags []NodeTag
+	for _, child := range children {
+		if child.XMLName.Space == "" && child.XMLName.Local == "tag" {
+			tags = append(tags, NodeTag{Key: child.attr("k"), Value: child.attr("v")})
+		}
+	}
+	return tags
+}
+
+// UnmarshalXML decodes a node, remembering the position of its unknown children
+func (n *NodeData) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var raw osmElementXML
+	if err := d.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	*n = NodeData{ID: raw.ID, Version: raw.Version, Changeset: raw.Changeset, Attrs: raw.Attrs, Tags: decodeTags(raw.Children)}
+	if raw.Lat != nil && raw.Lon != nil {
+		n.Lat, n.Lon = *raw.Lat, *raw.Lon
+	}
+	n.order, n.Extra = raw.childOrder("tag")
+	return nil
+}
+
+// MarshalXML encodes a node with its unknown children in their original position
+func (n NodeData) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	raw := osmElementXML{ID: n.ID, Version: n.Version, Changeset: n.Changeset, Lat: &n.Lat, Lon: &n.Lon, Attrs: n.Attrs}
+	raw.Children = orderChildren(n.order, n.Extra, childGroup{"tag", tagChildren(n.Tags)})
+	return e.EncodeElement(raw, start)
+}
+
+// UnmarshalXML decodes a way, remembering the position of its unknown children
+func (w *WayData) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var raw osmElementXML
+	if err := d.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	*w = WayData{ID: raw.ID, Version: raw.Version, Changeset: raw.Changeset, Attrs: raw.Attrs, Tags: decodeTags(raw.Children)}
+	for _, child := range raw.Children {
+		if child.XMLName.Space != "" || child.XMLName.Local != "nd" {
+			continue
+		}
+		ref, err := strconv.ParseInt(child.attr("ref"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid node reference in way %d: %v", raw.ID, err)
+		}
+		w.Nodes = append(w.Nodes, WayNode{Ref: ref})
+	}
+	w.order, w.Extra = raw.childOrder("nd", "tag")
+	return nil
+}
+
+// MarshalXML encodes a way with its unknown children in their original position
+func (w WayData) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	nodes := make([]UnknownChild, len(w.Nodes))
+	for i, node := range w.Nodes {
+		nodes[i] = UnknownChild{
+			XMLName: xml.Name{Local: "nd"},
+			Attrs:   []xml.Attr{{Name: xml.Name{Local: "ref"}, Value: strconv.FormatInt(node.Ref, 10)}},
+		}
+	}
+	raw := osmElementXML{ID: w.ID, Version: w.Version, Changeset: w.Changeset, Attrs: w.Attrs}
+	raw.Children = orderChildren(w.order, w.Extra, childGroup{"nd", nodes}, childGroup{"tag", tagChildren(w.Tags)})
+	return e.EncodeElement(raw, start)
+}
+
+// OSMRelation represents a relation element in OSM XML
+type OSMRelation struct {
+	XMLName   xml.Name      `xml:"osm"`
+	Version   string        `xml:"version,attr"`
+	Generator string        `xml:"generator,attr"`
+	Relation  *RelationData `xml:"relation,omitempty"`
+}
+
+// RelationData contains relation information. Members come before tags, as in API responses.
+type RelationData struct {
+	ID        int64
+	Version   int
+	Changeset int
+	Attrs     []xml.Attr
+	Members   []RelationMember
+	Tags      []NodeTag
+	Extra     []UnknownChild
+	order     []string
+}
+
+// RelationMember represents a member of a relation
+type RelationMember struct {
+	Type string `xml:"type,attr"`
+	Ref  int64  `xml:"ref,attr"`
+	Role string `xml:"role,attr"`
+}
+
+// UnmarshalXML decodes a relation, remembering the position of its unknown children
+func (r *RelationData) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var raw osmElementXML
+	if err := d.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	*r = RelationData{ID: raw.ID, Version: raw.Version, Changeset: raw.Changeset, Attrs: raw.Attrs, Tags: decodeTags(raw.Children)}
+	for _, child := range raw.Children {
+		if child.XMLName.Space != "" || child.XMLName.Local != "member" {
+			continue
+		}
+		ref, err := strconv.ParseInt(child.attr("ref"), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid member reference in relation %d: %v", raw.ID, err)
+		}
+		r.Members = append(r.Members, RelationMember{Type: child.attr("type"), Ref: ref, Role: child.attr("role")})
+	}
+	r.order, r.Extra = raw.childOrder("member", "tag")
+	return nil
+}
+
+// MarshalXML encodes a relation with its unknown children in their original position
+func (r RelationData) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	members := make([]UnknownChild, len(r.Members))
+	for i, member := range r.Members {
+		members[i] = UnknownChild{
+			XMLName: xml.Name{Local: "member"},
+			Attrs: []xml.Attr{
+				{Name: xml.Name{Local: "type"}, Value: member.Type},
+				{Name: xml.Name{Local: "ref"}, Value: strconv.FormatInt(member.Ref, 10)},
+				{Name: xml.Name{Local: "role"}, Value: member.Role},
+			},
+		}
+	}
+	raw := osmElementXML{ID: r.ID, Version: r.Version, Changeset: r.Changeset, Attrs: r.Attrs}
+	raw.Children = orderChildren(r.order, r.Extra, childGroup{"member", members}, childGroup{"tag", tagChildren(r.Tags)})
+	return e.EncodeElement(raw, start)
+}
+
+// NewOSMAPIClient creates a new OSM API client. Requests are sent through client with retries.
 func NewOSMAPIClient(client *http.Client, dryRun bool) *OSMAPIClient {
 	return &OSMAPIClient{
-		client: client,
-		dryRun: dryRun,
+		client:  NewRetryingClient(client),
+		dryRun:  dryRun,
+		baseURL: osmAPIBaseURL(),
 	}
 }
 
-// FetchNode fetches a node from OSM
-func (api *OSMAPIClient) FetchNode(nodeID int64) (*NodeData, error) {
-	url := fmt.Sprintf("https://api.openstreetmap.org/api/0.6/node/%d", nodeID)
-	
-	req, err := http.NewRequest("GET", url, nil)
+// osmAPIBaseURL returns the configured OSM API 0.6 base URL (OSM_API_URL)
+func osmAPIBaseURL() string {
+	settings := NewConfig()
+	settings.LoadFromEnv()
+	return strings.TrimSuffix(settings.Get("OSM_API_URL"), "/")
+}
+
+// normalizeOSMAPIURL accepts a server root (https://api06.dev.openstreetmap.org)
+// or an API URL and returns the API 0.6 base URL
+func normalizeOSMAPIURL(value string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid OSM API URL %q (expected e.g. %s)", value, osmSandboxURL)
+	}
+	base := strings.TrimSuffix(u.Scheme+"://"+u.Host+u.Path, "/")
+	if !strings.HasSuffix(base, "/api/0.6") {
+		base += "/api/0.6"
+	}
+	return base, nil
+}
+
+// osmSiteURL returns the website root serving OAuth for an API base URL.
+// The production API host is only an API endpoint; its website is www.openstreetmap.org.
+func osmSiteURL(apiURL string) string {
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return "https://www.openstreetmap.org"
+	}
+	if u.Host == "api.openstreetmap.org" {
+		u.Host = "www.openstreetmap.org"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// FetchElementXML fetches the raw XML representation of an element from OSM. An element
+// fetched by Prefetch is answered from memory once; later calls request it again.
+func (api *OSMAPIClient) FetchElementXML(ctx context.Context, elementType string, elementID int64) ([]byte, error) {
+	if raw, ok := api.takePrefetched(elementType, elementID); ok {
+		return raw, nil
+	}
+	url := fmt.Sprintf("%s/%s/%d", api.baseURL, elementType, elementID)
+	return api.fetchXML(ctx, url, elementType)
+}
+
+// Prefetch fetches elements of one type with the multi-fetch API (/nodes?nodes=1,2,...), so
+// the following Fetch*Snapshot calls need no request each. Deleted elements are left out
+// and fetched one by one, so they fail with the usual 410 Gone.
+func (api *OSMAPIClient) Prefetch(ctx context.Context, elementType string, ids []int64) error {
+	for start := 0; start < len(ids); start += maxPrefetchIDs {
+		end := start + maxPrefetchIDs
+		if end > len(ids) {
+			end = len(ids)
+		}
+		parts := make([]string, 0, end-start)
+		for _, id := range ids[start:end] {
+			parts = append(parts, strconv.FormatInt(id, 10))
+		}
+
+		url := fmt.Sprintf("%s/%ss?%ss=%s", api.baseURL, elementType, elementType, strings.Join(parts, ","))
+		body, err := api.fetchXML(ctx, url, elementType+"s")
+		if err != nil {
+			return err
+		}
+		snapshots, err := splitElementSnapshots(body, elementType)
+		if err != nil {
+			return err
+		}
+
+		api.prefetchMu.Lock()
+		if api.prefetched == nil {
+			api.prefetched = make(map[string][]byte)
+		}
+		for id, raw := range snapshots {
+			api.prefetched[elementKey(elementType, id)] = raw
+		}
+		api.prefetchMu.Unlock()
+	}
+	return nil
+}
+
+// ClearPrefetched drops prefetched elements that were not used, so they cannot go stale
+func (api *OSMAPIClient) ClearPrefetched() {
+	api.prefetchMu.Lock()
+	defer api.prefetchMu.Unlock()
+	api.prefetched = nil
+}
+
+// takePrefetched returns and forgets the prefetched document of an element
+func (api *OSMAPIClient) takePrefetched(elementType string, elementID int64) ([]byte, bool) {
+	api.prefetchMu.Lock()
+	defer api.prefetchMu.Unlock()
+	key := elementKey(elementType, elementID)
+	raw, ok := api.prefetched[key]
+	if ok {
+		delete(api.prefetched, key)
+	}
+	return raw, ok
+}
+
+// splitElementSnapshots splits a multi-fetch response into one <osm> document per visible element,
+// each holding the element's XML exactly as returned
+func splitElementSnapshots(body []byte, elementType string) (map[int64][]byte, error) {
+	snapshots := make(map[int64][]byte)
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return snapshots, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %ss XML: %v", elementType, err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != elementType {
+			continue
+		}
+		var element struct {
+			ID      int64  `xml:"id,attr"`
+			Visible string `xml:"visible,attr"`
+		}
+		if err := decoder.DecodeElement(&element, &start); err != nil {
+			return nil, fmt.Errorf("failed to decode %ss XML: %v", elementType, err)
+		}
+		if element.Visible == "false" {
+			continue
+		}
+		raw := body[offset:decoder.InputOffset()]
+		snapshots[element.ID] = []byte(`<osm version="0.6">` + string(raw) + `</osm>`)
+	}
+}
+
+// fetchXML performs a GET request and returns the response body; what names the
+// requested document in errors
+func (api *OSMAPIClient) fetchXML(ctx context.Context, url, what string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	resp, err := api.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch node: %v", err)
+		return nil, fmt.Errorf("failed to fetch %s: %w", what, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch node: status code %d: %s", resp.StatusCode, string(body))
+		return nil, &APIError{Operation: "failed to fetch " + what, StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s response: %v", what, err)
+	}
+
+	return body, nil
+}
+
+// FetchNode fetches a node from OSM
+func (api *OSMAPIClient) FetchNode(ctx context.Context, nodeID int64) (*NodeData, error) {
+	node, _, err := api.FetchNodeSnapshot(ctx, nodeID)
+	return node, err
+}
+
+// FetchNodeSnapshot fetches a node together with its raw pre-edit XML
+func (api *OSMAPIClient) FetchNodeSnapshot(ctx context.Context, nodeID int64) (*NodeData, []byte, error) {
+	raw, err := api.FetchElementXML(ctx, "node", nodeID)
+	if err != nil {
+		return nil, nil, err
 	}
 
 	var osmNode OSMNode
-	if err := xml.NewDecoder(resp.Body).Decode(&osmNode); err != nil {
-		return nil, fmt.Errorf("failed to decode node XML: %v", err)
+	if err := xml.Unmarshal(raw, &osmNode); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode node XML: %v", err)
 	}
 
 	if osmNode.Node == nil {
-		return nil, fmt.Errorf("no node data in response")
+		return nil, nil, fmt.Errorf("no node data in response")
 	}
 
-	return osmNode.Node, nil
+	return osmNode.Node, raw, nil
 }
 
 // FetchWay fetches a way from OSM
-func (api *OSMAPIClient) FetchWay(wayID int64) (*WayData, error) {
-	url := fmt.Sprintf("https://api.openstreetmap.org/api/0.6/way/%d", wayID)
-	
-	req, err := http.NewRequest("GET", url, nil)
+func (api *OSMAPIClient) FetchWay(ctx context.Context, wayID int64) (*WayData, error) {
+	way, _, err := api.FetchWaySnapshot(ctx, wayID)
+	return way, err
+}
+
+// FetchWaySnapshot fetches a way together with its raw pre-edit XML
+func (api *OSMAPIClient) FetchWaySnapshot(ctx context.Context, wayID int64) (*WayData, []byte, error) {
+	raw, err := api.FetchElementXML(ctx, "way", wayID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
+		return nil, nil, err
 	}
 
-	resp, err := api.client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to fetch way: %v", err)
+	var osmWay OSMWay
+	if err := xml.Unmarshal(raw, &osmWay); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode way XML: %v", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("failed to fetch way: status code %d: %s", resp.StatusCode, string(body))
+	if osmWay.Way == nil {
+		return nil, nil, fmt.Errorf("no way data in response")
 	}
 
-	var osmWay OSMWay
-	if err := xml.NewDecoder(resp.Body).Decode(&osmWay); err != nil {
-		return nil, fmt.Errorf("failed to decode way XML: %v", err)
+	return osmWay.Way, raw, nil
+}
+
+// FetchRelation fetches a relation from OSM
+func (api *OSMAPIClient) FetchRelation(ctx context.Context, relationID int64) (*RelationData, error) {
+	relation, _, err := api.FetchRelationSnapshot(ctx, relationID)
+	return relation, err
+}
+
+// FetchRelationSnapshot fetches a relation together with its raw pre-edit XML
+func (api *OSMAPIClient) FetchRelationSnapshot(ctx context.Context, relationID int64) (*RelationData, []byte, error) {
+	raw, err := api.FetchElementXML(ctx, "relation", relationID)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if osmWay.Way == nil {
-		return nil, fmt.Errorf("no way data in response")
+	var osmRelation OSMRelation
+	if err := xml.Unmarshal(raw, &osmRelation); err != nil {
+		return nil, nil, fmt.Errorf("failed to decode relation XML: %v", err)
+	}
+
+	if osmRelation.Relation == nil {
+		return nil, nil, fmt.Errorf("no relation data in response")
 	}
 
-	return osmWay.Way, nil
+	return osmRelation.Relation, raw, nil
 }
 
 // UpdateNode updates a node in OSM
-func (api *OSMAPIClient) UpdateNode(node *NodeData, changesetID int) error {
+func (api *OSMAPIClient) UpdateNode(ctx context.Context, node *NodeData, changesetID int) error {
 	if api.dryRun {
 		return nil
 	}
 
+	if err := sharedBudget().AcquireContext(ctx, BudgetOSMEdits); err != nil {
+		return err
+	}
+
 	// Set changeset ID
 	node.Changeset = changesetID
 
@@ -152,8 +590,8 @@ func (api *OSMAPIClient) UpdateNode(node *NodeData, changesetID int) error {
 		return fmt.Errorf("failed to marshal node XML: %v", err)
 	}
 
-	url := fmt.Sprintf("https://api.openstreetmap.org/api/0.6/node/%d", node.ID)
-	req, err := http.NewRequest("PUT", url, bytes.NewReader(xmlData))
+	url := fmt.Sprintf("%s/node/%d", api.baseURL, node.ID)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(xmlData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
@@ -161,24 +599,28 @@ func (api *OSMAPIClient) UpdateNode(node *NodeData, changesetID int) error {
 
 	resp, err := api.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to update node: %v", err)
+		return fmt.Errorf("failed to update node: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to update node: status code %d: %s", resp.StatusCode, string(body))
+		return &APIError{Operation: "failed to update node", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	return nil
 }
 
 // UpdateWay updates a way in OSM
-func (api *OSMAPIClient) UpdateWay(way *WayData, changesetID int) error {
+func (api *OSMAPIClient) UpdateWay(ctx context.Context, way *WayData, changesetID int) error {
 	if api.dryRun {
 		return nil
 	}
 
+	if err := sharedBudget().AcquireContext(ctx, BudgetOSMEdits); err != nil {
+		return err
+	}
+
 	// Set changeset ID
 	way.Changeset = changesetID
 
@@ -193,8 +635,8 @@ func (api *OSMAPIClient) UpdateWay(way *WayData, changesetID int) error {
 		return fmt.Errorf("failed to marshal way XML: %v", err)
 	}
 
-	url := fmt.Sprintf("https://api.openstreetmap.org/api/0.6/way/%d", way.ID)
-	req, err := http.NewRequest("PUT", url, bytes.NewReader(xmlData))
+	url := fmt.Sprintf("%s/way/%d", api.baseURL, way.ID)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(xmlData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %v", err)
 	}
@@ -202,38 +644,85 @@ func (api *OSMAPIClient) UpdateWay(way *WayData, changesetID int) error {
 
 	resp, err := api.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to update way: %v", err)
+		return fmt.Errorf("failed to update way: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to update way: status code %d: %s", resp.StatusCode, string(body))
+		return &APIError{Operation: "failed to update way", StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	return nil
 }
 
-// MergeTags merges new tags with existing tags, updating values for existing keys
+// UpdateRelation updates a relation in OSM
+func (api *OSMAPIClient) UpdateRelation(ctx context.Context, relation *RelationData, changesetID int) error {
+	if api.dryRun {
+		return nil
+	}
+
+	if err := sharedBudget().AcquireContext(ctx, BudgetOSMEdits); err != nil {
+		return err
+	}
+
+	// Set changeset ID
+	relation.Changeset = changesetID
+
+	osmRelation := OSMRelation{
+		Version:   "0.6",
+		Generator: "elevate-romania",
+		Relation:  relation,
+	}
+
+	xmlData, err := xml.MarshalIndent(osmRelation, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal relation XML: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/relation/%d", api.baseURL, relation.ID)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewReader(xmlData))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/xml")
+
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to update relation: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return &APIError{Operation: "failed to update relation", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	return nil
+}
+
+// MergeTags merges new tags with existing tags, updating values for existing keys.
+// Existing tags keep their order; added tags follow in key order.
 func MergeTags(existingTags []NodeTag, newTags map[string]string) []NodeTag {
-	// Create a map of existing tags
-	tagMap := make(map[string]string)
+	result := make([]NodeTag, 0, len(existingTags)+len(newTags))
+	seen := make(map[string]bool)
 	for _, tag := range existingTags {
-		tagMap[tag.Key] = tag.Value
+		if value, ok := newTags[tag.Key]; ok {
+			tag.Value = value
+		}
+		result = append(result, tag)
+		seen[tag.Key] = true
 	}
 
-	// Update with new tags
-	for key, value := range newTags {
-		tagMap[key] = value
+	added := make([]string, 0, len(newTags))
+	for key := range newTags {
+		if !seen[key] {
+			added = append(added, key)
+		}
 	}
-
-	// Convert back to slice
-	var result []NodeTag
-	for key, value := range tagMap {
-		result = append(result, NodeTag{
-			Key:   key,
-			Value: value,
-		})
+	sort.Strings(added)
+	for _, key := range added {
+		result = append(result, NodeTag{Key: key, Value: newTags[key]})
 	}
 
 	return result
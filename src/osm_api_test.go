@@ -1,11 +1,18 @@
 package main
 
 import (
+	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestMergeTags(t *testing.T) {
@@ -122,3 +129,269 @@ func TestOAuthConfigSaveLoad(t *testing.T) {
 		t.Error("OSM_ACCESS_TOKEN not found in saved file")
 	}
 }
+
+func TestNormalizeOSMAPIURL(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"https://api06.dev.openstreetmap.org", "https://api06.dev.openstreetmap.org/api/0.6", false},
+		{"https://api06.dev.openstreetmap.org/", "https://api06.dev.openstreetmap.org/api/0.6", false},
+		{"https://api.openstreetmap.org/api/0.6/", "https://api.openstreetmap.org/api/0.6", false},
+		{"http://localhost:3000", "http://localhost:3000/api/0.6", false},
+		{"api06.dev.openstreetmap.org", "", true},
+		{"ftp://example.org", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeOSMAPIURL(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("normalizeOSMAPIURL(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeOSMAPIURL(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestOSMSiteURL(t *testing.T) {
+	tests := map[string]string{
+		"https://api.openstreetmap.org/api/0.6":       "https://www.openstreetmap.org",
+		"https://api06.dev.openstreetmap.org/api/0.6": "https://api06.dev.openstreetmap.org",
+		"http://localhost:3000/api/0.6":               "http://localhost:3000",
+	}
+	for apiURL, want := range tests {
+		if got := osmSiteURL(apiURL); got != want {
+			t.Errorf("osmSiteURL(%q) = %q, want %q", apiURL, got, want)
+		}
+	}
+}
+
+func TestUseOSMAPI(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	if err := useOSMAPI("https://example.org", true); err == nil {
+		t.Error("expected error when combining --osm-api and --sandbox")
+	}
+
+	if err := useOSMAPI("", true); err != nil {
+		t.Fatalf("useOSMAPI: %v", err)
+	}
+	if got := NewOSMAPIClient(nil, true).baseURL; got != osmSandboxURL+"/api/0.6" {
+		t.Errorf("API client baseURL = %q", got)
+	}
+	if got := NewChangesetManager(nil, true).baseURL; got != osmSandboxURL+"/api/0.6" {
+		t.Errorf("changeset manager baseURL = %q", got)
+	}
+	if got := osmOAuth2Config("id", "secret").Endpoint.TokenURL; got != osmSandboxURL+"/oauth2/token" {
+		t.Errorf("OAuth token URL = %q", got)
+	}
+}
+
+func TestOSMAPIClientUsesConfiguredURL(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/0.6/node/42" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<osm version="0.6"><node id="42" version="3" lat="45" lon="25"><tag k="natural" v="peak"/></node></osm>`)
+	}))
+	defer server.Close()
+
+	if err := useOSMAPI(server.URL, false); err != nil {
+		t.Fatalf("useOSMAPI: %v", err)
+	}
+	node, err := NewOSMAPIClient(server.Client(), false).FetchNode(context.Background(), 42)
+	if err != nil {
+		t.Fatalf("FetchNode: %v", err)
+	}
+	if node.Version != 3 {
+		t.Errorf("Version = %d, want 3", node.Version)
+	}
+}
+
+func TestOSMAPIClientFetchStopsOnCancel(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	if err := useOSMAPI(server.URL, false); err != nil {
+		t.Fatalf("useOSMAPI: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := NewOSMAPIClient(server.Client(), false).FetchNode(ctx, 42); !errors.Is(err, context.Canceled) {
+		t.Errorf("FetchNode() after cancel = %v, want context.Canceled", err)
+	}
+}
+
+func TestOSMAPIClientUpdatesRelation(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+	disableSharedBudget(t)
+
+	var updated string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/0.6/relation/7" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "PUT" {
+			body, _ := io.ReadAll(r.Body)
+			updated = string(body)
+			return
+		}
+		fmt.Fprint(w, `<osm version="0.6"><relation id="7" version="2"><member type="way" ref="10" role="outer"/><member type="way" ref="11" role="inner"/><tag k="type" v="multipolygon"/><tag k="tourism" v="alpine_hut"/></relation></osm>`)
+	}))
+	defer server.Close()
+
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+	api := NewOSMAPIClient(server.Client(), false)
+	relation, err := api.FetchRelation(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("FetchRelation: %v", err)
+	}
+	if relation.Version != 2 || len(relation.Members) != 2 || len(relation.Tags) != 2 {
+		t.Fatalf("relation = %+v, want version 2 with 2 members and 2 tags", relation)
+	}
+
+	relation.Tags = MergeTags(relation.Tags, map[string]string{"ele": "1850"})
+	if err := api.UpdateRelation(context.Background(), relation, 99); err != nil {
+		t.Fatalf("UpdateRelation: %v", err)
+	}
+	for _, want := range []string{
+		`<relation id="7" version="2" changeset="99">`,
+		`<member type="way" ref="10" role="outer"></member>`,
+		`<member type="way" ref="11" role="inner"></member>`,
+		`<tag k="ele" v="1850"></tag>`,
+	} {
+		if !strings.Contains(updated, want) {
+			t.Errorf("update body missing %q:\n%s", want, updated)
+		}
+	}
+}
+
+func TestOSMAPIClientPrefetch(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		switch r.URL.Path {
+		case "/api/0.6/nodes":
+			fmt.Fprint(w, `<osm version="0.6">
+ <node id="1" visible="true" version="4" lat="45.1" lon="25.1"><tag k="natural" v="peak"/></node>
+ <node id="2" visible="true" version="2" lat="45.2" lon="25.2"/>
+ <node id="3" visible="false" version="5"/>
+</osm>`)
+		case "/api/0.6/node/1":
+			fmt.Fprint(w, `<osm version="0.6"><node id="1" version="5" lat="45.1" lon="25.1"/></osm>`)
+		case "/api/0.6/node/3":
+			http.Error(w, "Gone", http.StatusGone)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+	api := NewOSMAPIClient(server.Client(), false)
+	if err := api.Prefetch(context.Background(), "node", []int64{1, 2, 3}); err != nil {
+		t.Fatalf("Prefetch: %v", err)
+	}
+
+	node, snapshot, err := api.FetchNodeSnapshot(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("FetchNodeSnapshot: %v", err)
+	}
+	if node.Version != 4 || len(node.Tags) != 1 || !strings.Contains(string(snapshot), `<node id="1" visible="true" version="4"`) {
+		t.Errorf("prefetched node = %+v, snapshot %s", node, snapshot)
+	}
+	if _, err := api.FetchNode(context.Background(), 3); err == nil {
+		t.Error("FetchNode(3) of a deleted node succeeded, want 410")
+	}
+	// A prefetched element is only used once, e.g. a retry after a conflict fetches it again
+	if node, err := api.FetchNode(context.Background(), 1); err != nil || node.Version != 5 {
+		t.Errorf("FetchNode(1) again = %+v, %v; want version 5 from the API", node, err)
+	}
+
+	want := []string{"/api/0.6/nodes?nodes=1,2,3", "/api/0.6/node/3", "/api/0.6/node/1"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
+
+func TestElementRoundTripPreservesXML(t *testing.T) {
+	raw := `<osm version="0.6"><way id="5" visible="true" version="3" changeset="100" timestamp="2024-05-01T10:00:00Z" user="mapper" uid="42" future="kept">` +
+		`<nd ref="10"/><nd ref="11"/><nd ref="10"/>` +
+		`<tag k="tourism" v="alpine_hut"/><tag k="name" v="Cabana"/>` +
+		`<note lang="ro">text</note></way></osm>`
+
+	var doc OSMWay
+	if err := xml.Unmarshal([]byte(raw), &doc); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	way := doc.Way
+	way.Tags = MergeTags(way.Tags, map[string]string{"ele:source": "SRTM", "ele": "1500"})
+	way.Changeset = 200
+
+	out, err := xml.Marshal(OSMWay{Version: "0.6", Generator: "elevate-romania", Way: way})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `<way id="5" version="3" changeset="200" visible="true" timestamp="2024-05-01T10:00:00Z" user="mapper" uid="42" future="kept">` +
+		`<nd ref="10"></nd><nd ref="11"></nd><nd ref="10"></nd>` +
+		`<tag k="tourism" v="alpine_hut"></tag><tag k="name" v="Cabana"></tag><tag k="ele" v="1500"></tag><tag k="ele:source" v="SRTM"></tag>` +
+		`<note lang="ro">text</note></way>`
+	if !strings.Contains(string(out), want) {
+		t.Errorf("round trip =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestElementRoundTripKeepsChildOrder(t *testing.T) {
+	raw := `<osm version="0.6"><node id="7" version="2" lat="45.5" lon="25.5">` +
+		`<tag k="natural" v="peak"/><note>between</note><tag k="ele" v="1200"/><tag k="name" v="Vf"/><fixme/>` +
+		`</node></osm>`
+
+	var doc OSMNode
+	if err := xml.Unmarshal([]byte(raw), &doc); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	node := doc.Node
+	node.Tags = MergeTags(node.Tags, map[string]string{"ele": "1234", "ele:source": "SRTM"})
+
+	out, err := xml.Marshal(OSMNode{Version: "0.6", Generator: "elevate-romania", Node: node})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want := `<node id="7" version="2" lat="45.5" lon="25.5">` +
+		`<tag k="natural" v="peak"></tag><note>between</note><tag k="ele" v="1234"></tag><tag k="name" v="Vf"></tag><tag k="ele:source" v="SRTM"></tag><fixme></fixme>` +
+		`</node>`
+	if !strings.Contains(string(out), want) {
+		t.Errorf("round trip =\n%s\nwant\n%s", out, want)
+	}
+
+	raw = `<osm version="0.6"><relation id="9" version="1"><member type="way" ref="5" role="outer"/><extra/>` +
+		`<member type="node" ref="6" role=""/><tag k="type" v="multipolygon"/></relation></osm>`
+	var relationDoc OSMRelation
+	if err := xml.Unmarshal([]byte(raw), &relationDoc); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if got := relationDoc.Relation.Members; len(got) != 2 || got[0].Role != "outer" || got[1].Ref != 6 {
+		t.Errorf("members = %+v", got)
+	}
+	out, err = xml.Marshal(relationDoc)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	want = `<relation id="9" version="1"><member type="way" ref="5" role="outer"></member><extra></extra>` +
+		`<member type="node" ref="6" role=""></member><tag k="type" v="multipolygon"></tag></relation>`
+	if !strings.Contains(string(out), want) {
+		t.Errorf("round trip =\n%s\nwant\n%s", out, want)
+	}
+}
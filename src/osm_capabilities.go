@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"time"
+)
+
+// capabilitiesTimeoutMargin is added to the advertised server timeout so the client never gives
+// up on a request the server is still allowed to process
+const capabilitiesTimeoutMargin = 30 * time.Second
+
+// OSMCapabilities holds the limits advertised by /api/0.6/capabilities
+type OSMCapabilities struct {
+	// MaxChangesetElements is the maximum number of edits in one changeset
+	MaxChangesetElements int
+	// TimeoutSeconds is how long the server processes a request before giving up
+	TimeoutSeconds int
+	// APIStatus is online, readonly or offline
+	APIStatus string
+}
+
+// capabilitiesDocument is the XML answer of the capabilities call
+type capabilitiesDocument struct {
+	API struct {
+		Changesets struct {
+			MaximumElements int `xml:"maximum_elements,attr"`
+		} `xml:"changesets"`
+		Timeout struct {
+			Seconds int `xml:"seconds,attr"`
+		} `xml:"timeout"`
+		Status struct {
+			API string `xml:"api,attr"`
+		} `xml:"status"`
+	} `xml:"api"`
+}
+
+// parseCapabilities decodes a capabilities document
+func parseCapabilities(data []byte) (*OSMCapabilities, error) {
+	var doc capabilitiesDocument
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode capabilities XML: %v", err)
+	}
+	return &OSMCapabilities{
+		MaxChangesetElements: doc.API.Changesets.MaximumElements,
+		TimeoutSeconds:       doc.API.Timeout.Seconds,
+		APIStatus:            doc.API.Status.API,
+	}, nil
+}
+
+// FetchCapabilities reads the limits and status of the OSM API
+func (api *OSMAPIClient) FetchCapabilities(ctx context.Context) (*OSMCapabilities, error) {
+	body, err := api.fetchXML(ctx, api.baseURL+"/capabilities", "capabilities")
+	if err != nil {
+		return nil, err
+	}
+	return parseCapabilities(body)
+}
+
+// applyCapabilities adapts the uploader to the advertised API limits: changesets are capped at
+// the maximum number of elements and the request timeout covers the server timeout. An API that
+// is read-only or offline fails the upload; unreadable capabilities keep the configured limits.
+func (u *OSMUploader) applyCapabilities(ctx context.Context) error {
+	caps, err := u.apiClient.FetchCapabilities(ctx)
+	if err != nil {
+		uploadLog.Warn("Could not read the OSM API capabilities, using the configured limits: %v", err)
+		return nil
+	}
+
+	if caps.APIStatus == "readonly" || caps.APIStatus == "offline" {
+		return fmt.Errorf("the OSM API is %s, try again later", caps.APIStatus)
+	}
+	if max := caps.MaxChangesetElements; max > 0 && (u.maxEdits == 0 || u.maxEdits > max) {
+		uploadLog.Info("The OSM API allows at most %d elements per changeset", max)
+		u.maxEdits = max
+	}
+	if caps.TimeoutSeconds > 0 && u.client != nil {
+		timeout := time.Duration(caps.TimeoutSeconds)*time.Second + capabilitiesTimeoutMargin
+		if u.client.Timeout < timeout {
+			u.client.Timeout = timeout
+		}
+	}
+	return nil
+}
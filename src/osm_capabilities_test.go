@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const testCapabilities = `<?xml version="1.0" encoding="UTF-8"?>
+<osm version="0.6" generator="OpenStreetMap server">
+  <api>
+    <version minimum="0.6" maximum="0.6"/>
+    <area maximum="0.25"/>
+    <changesets maximum_elements="%d" default_query_limit="100" maximum_query_limit="100"/>
+    <timeout seconds="%d"/>
+    <status database="online" api="%s" gpx="online"/>
+  </api>
+</osm>`
+
+func TestApplyCapabilities(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		maxElements int
+		apiStatus   string
+		maxEdits    int
+		wantEdits   int
+		wantTimeout time.Duration
+		wantErr     bool
+	}{
+		{name: "Caps unlimited edits", status: 200, maxElements: 10000, apiStatus: "online", wantEdits: 10000, wantTimeout: 330 * time.Second},
+		{name: "Keeps lower limit", status: 200, maxElements: 10000, apiStatus: "online", maxEdits: 500, wantEdits: 500, wantTimeout: 330 * time.Second},
+		{name: "Lowers higher limit", status: 200, maxElements: 200, apiStatus: "online", maxEdits: 500, wantEdits: 200, wantTimeout: 330 * time.Second},
+		{name: "Read-only API", status: 200, maxElements: 10000, apiStatus: "readonly", wantErr: true},
+		{name: "Unavailable", status: 404, maxEdits: 500, wantEdits: 500},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/0.6/capabilities" || tt.status != 200 {
+					http.NotFound(w, r)
+					return
+				}
+				fmt.Fprintf(w, testCapabilities, tt.maxElements, 300, tt.apiStatus)
+			}))
+			defer server.Close()
+
+			client := &http.Client{}
+			api := NewOSMAPIClient(client, false)
+			api.baseURL = server.URL + "/api/0.6"
+			uploader := &OSMUploader{client: client, apiClient: api, maxEdits: tt.maxEdits}
+
+			err := uploader.applyCapabilities(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyCapabilities() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if uploader.maxEdits != tt.wantEdits {
+				t.Errorf("maxEdits = %d, want %d", uploader.maxEdits, tt.wantEdits)
+			}
+			if client.Timeout != tt.wantTimeout {
+				t.Errorf("client timeout = %v, want %v", client.Timeout, tt.wantTimeout)
+			}
+		})
+	}
+}
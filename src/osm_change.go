@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// OSMChange represents an osmChange document uploaded to a changeset in one request
+type OSMChange struct {
+	XMLName   xml.Name         `xml:"osmChange"`
+	Version   string           `xml:"version,attr"`
+	Generator string           `xml:"generator,attr"`
+	Modify    []OSMChangeBlock `xml:"modify"`
+}
+
+// OSMChangeBlock groups elements of one action inside an osmChange document
+type OSMChangeBlock struct {
+	Nodes     []NodeData     `xml:"node"`
+	Ways      []WayData      `xml:"way"`
+	Relations []RelationData `xml:"relation"`
+}
+
+// DiffResult is the response of a changeset diff upload
+type DiffResult struct {
+	XMLName   xml.Name          `xml:"diffResult"`
+	Nodes     []DiffResultEntry `xml:"node"`
+	Ways      []DiffResultEntry `xml:"way"`
+	Relations []DiffResultEntry `xml:"relation"`
+}
+
+// DiffResultEntry maps an uploaded element to its new version
+type DiffResultEntry struct {
+	OldID      int64 `xml:"old_id,attr"`
+	NewID      int64 `xml:"new_id,attr"`
+	NewVersion int   `xml:"new_version,attr"`
+}
+
+// NewOSMChange creates an empty osmChange document
+func NewOSMChange() *OSMChange {
+	return &OSMChange{
+		Version:   "0.6",
+		Generator: "elevate-romania",
+		Modify:    []OSMChangeBlock{{}},
+	}
+}
+
+// ModifyNode adds a node modification to the document
+func (c *OSMChange) ModifyNode(node NodeData) {
+	c.Modify[0].Nodes = append(c.Modify[0].Nodes, node)
+}
+
+// ModifyWay adds a way modification to the document
+func (c *OSMChange) ModifyWay(way WayData) {
+	c.Modify[0].Ways = append(c.Modify[0].Ways, way)
+}
+
+// ModifyRelation adds a relation modification to the document
+func (c *OSMChange) ModifyRelation(relation RelationData) {
+	c.Modify[0].Relations = append(c.Modify[0].Relations, relation)
+}
+
+// Remove drops the modification of an element, e.g. to stage it again after a conflict
+func (c *OSMChange) Remove(elementType string, id int64) {
+	for i := range c.Modify {
+		block := &c.Modify[i]
+		switch elementType {
+		case "node":
+			nodes := block.Nodes[:0]
+			for _, node := range block.Nodes {
+				if node.ID != id {
+					nodes = append(nodes, node)
+				}
+			}
+			block.Nodes = nodes
+		case "way":
+			ways := block.Ways[:0]
+			for _, way := range block.Ways {
+				if way.ID != id {
+					ways = append(ways, way)
+				}
+			}
+			block.Ways = ways
+		case "relation":
+			relations := block.Relations[:0]
+			for _, relation := range block.Relations {
+				if relation.ID != id {
+					relations = append(relations, relation)
+				}
+			}
+			block.Relations = relations
+		}
+	}
+}
+
+// Len returns the number of modified elements
+func (c *OSMChange) Len() int {
+	count := 0
+	for _, block := range c.Modify {
+		count += len(block.Nodes) + len(block.Ways) + len(block.Relations)
+	}
+	return count
+}
+
+// UploadChange posts an osmChange document to an open changeset as a single atomic diff upload.
+// The edits of the whole document are reserved in the budget before the POST.
+func (api *OSMAPIClient) UploadChange(ctx context.Context, changesetID int, change *OSMChange) (*DiffResult, error) {
+	if api.dryRun {
+		return &DiffResult{}, nil
+	}
+
+	if err := sharedBudget().AcquireN(ctx, BudgetOSMEdits, change.Len()); err != nil {
+		return nil, err
+	}
+
+	xmlData, err := xml.MarshalIndent(change, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal osmChange XML: %v", err)
+	}
+
+	url := fmt.Sprintf("%s/changeset/%d/upload", api.baseURL, changesetID)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(xmlData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "text/xml")
+
+	resp, err := api.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload diff: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, &APIError{Operation: "failed to upload diff", StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var result DiffResult
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode diff result: %v", err)
+	}
+
+	return &result, nil
+}
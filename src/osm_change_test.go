@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestOSMChangeMarshal(t *testing.T) {
+	change := NewOSMChange()
+	change.ModifyNode(NodeData{
+		ID:        1,
+		Version:   3,
+		Changeset: 42,
+		Lat:       45.5,
+		Lon:       25.5,
+		Tags:      []NodeTag{{Key: "ele", Value: "1200"}},
+	})
+	change.ModifyWay(WayData{
+		ID:        2,
+		Version:   1,
+		Changeset: 42,
+		Nodes:     []WayNode{{Ref: 10}, {Ref: 11}},
+	})
+	change.ModifyRelation(RelationData{
+		ID:        3,
+		Version:   7,
+		Changeset: 42,
+		Members:   []RelationMember{{Type: "way", Ref: 2, Role: "outer"}},
+	})
+
+	if change.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", change.Len())
+	}
+
+	data, err := xml.Marshal(change)
+	if err != nil {
+		t.Fatalf("Marshal error = %v", err)
+	}
+
+	xmlText := string(data)
+	expected := []string{
+		`<osmChange version="0.6" generator="elevate-romania">`,
+		`<modify><node id="1" version="3" changeset="42" lat="45.5" lon="25.5"><tag k="ele" v="1200"></tag></node>`,
+		`<way id="2" version="1" changeset="42"><nd ref="10"></nd><nd ref="11"></nd></way>`,
+		`<relation id="3" version="7" changeset="42"><member type="way" ref="2" role="outer"></member></relation></modify>`,
+	}
+	for _, fragment := range expected {
+		if !strings.Contains(xmlText, fragment) {
+			t.Errorf("Expected %q in %s", fragment, xmlText)
+		}
+	}
+}
+
+func TestDiffResultUnmarshal(t *testing.T) {
+	body := `<diffResult version="0.6"><node old_id="1" new_id="1" new_version="4"/><way old_id="2" new_id="2" new_version="2"/><relation old_id="3" new_id="3" new_version="8"/></diffResult>`
+
+	var result DiffResult
+	if err := xml.Unmarshal([]byte(body), &result); err != nil {
+		t.Fatalf("Unmarshal error = %v", err)
+	}
+	if len(result.Nodes) != 1 || result.Nodes[0].NewVersion != 4 {
+		t.Errorf("Unexpected nodes: %+v", result.Nodes)
+	}
+	if len(result.Ways) != 1 || result.Ways[0].OldID != 2 {
+		t.Errorf("Unexpected ways: %+v", result.Ways)
+	}
+	if len(result.Relations) != 1 || result.Relations[0].NewVersion != 8 {
+		t.Errorf("Unexpected relations: %+v", result.Relations)
+	}
+}
+
+func TestParseUploadMode(t *testing.T) {
+	tests := map[string]string{
+		"":        UploadModeDiff,
+		"diff":    UploadModeDiff,
+		"Element": UploadModeElement,
+	}
+	for input, expected := range tests {
+		got, err := ParseUploadMode(input)
+		if err != nil || got != expected {
+			t.Errorf("ParseUploadMode(%q) = %q, %v; want %q", input, got, err, expected)
+		}
+	}
+	if _, err := ParseUploadMode("bulk"); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
+
+func TestWriteOSMChange(t *testing.T) {
+	change := NewOSMChange()
+	change.ModifyNode(NodeData{ID: 5, Version: 2, Lat: 45, Lon: 25, Tags: []NodeTag{{Key: "ele", Value: "700.0"}}})
+
+	var builder strings.Builder
+	if err := WriteOSMChange(&builder, change); err != nil {
+		t.Fatalf("WriteOSMChange() error = %v", err)
+	}
+
+	output := builder.String()
+	if !strings.HasPrefix(output, "<?xml") {
+		t.Errorf("Expected XML declaration, got %q", output[:20])
+	}
+	if strings.Contains(output, "changeset=") {
+		t.Errorf("Exported .osc should not reference a changeset: %s", output)
+	}
+	if !strings.Contains(output, `<node id="5" version="2" lat="45" lon="25">`) {
+		t.Errorf("Unexpected node serialization: %s", output)
+	}
+
+	var parsed OSMChange
+	if err := xml.Unmarshal([]byte(output), &parsed); err != nil {
+		t.Fatalf("Exported file does not parse: %v", err)
+	}
+	if parsed.Len() != 1 {
+		t.Errorf("Expected 1 modification after round trip, got %d", parsed.Len())
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// automatedEditsPolicyURL documents the account rules for automated edits
+const automatedEditsPolicyURL = "https://wiki.openstreetmap.org/wiki/Automated_Edits_code_of_conduct"
+
+// OSMUser is the account behind the OAuth token, from /api/0.6/user/details
+type OSMUser struct {
+	ID          int64  `xml:"id,attr"`
+	DisplayName string `xml:"display_name,attr"`
+	Description string `xml:"description"`
+	Changesets  struct {
+		Count int `xml:"count,attr"`
+	} `xml:"changesets"`
+}
+
+// FetchUserDetails returns the authenticated user
+func (api *OSMAPIClient) FetchUserDetails(ctx context.Context) (*OSMUser, error) {
+	body, err := api.fetchXML(ctx, api.baseURL+"/user/details", "user details")
+	if err != nil {
+		return nil, err
+	}
+	var doc struct {
+		User *OSMUser `xml:"user"`
+	}
+	if err := xml.Unmarshal(body, &doc); err != nil || doc.User == nil {
+		return nil, fmt.Errorf("failed to decode user details XML: %v", err)
+	}
+	return doc.User, nil
+}
+
+// LooksLikeBotAccount reports whether the display name ends in "bot" or "import" (ElevateBot,
+// ro_import) or the profile describes the account as one for automated edits
+func (u *OSMUser) LooksLikeBotAccount() bool {
+	words := strings.FieldsFunc(strings.ToLower(u.DisplayName), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if strings.HasSuffix(word, "bot") || strings.HasSuffix(word, "import") {
+			return true
+		}
+	}
+	description := strings.ToLower(u.Description)
+	for _, phrase := range []string{"bot account", "automated edit", "import account"} {
+		if strings.Contains(description, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUser prints the authenticated account and refuses to upload when it is not the
+// REQUIRE_USER account. Without REQUIRE_USER only an invalid token stops the upload.
+func (u *OSMUploader) checkUser(ctx context.Context) error {
+	user, err := u.apiClient.FetchUserDetails(ctx)
+	if err != nil {
+		if u.requiredUser != "" || ClassifyUploadError(err) == ErrorClassAuth {
+			return fmt.Errorf("cannot verify the OSM account: %v", err)
+		}
+		uploadLog.Warn("Could not read the OSM user details: %v", err)
+		return nil
+	}
+
+	kind := "a personal account"
+	if user.LooksLikeBotAccount() {
+		kind = "a dedicated bot account"
+	}
+	uploadLog.Info("Authenticated as %s (user #%d, %d changesets), which looks like %s", user.DisplayName, user.ID, user.Changesets.Count, kind)
+	if !user.LooksLikeBotAccount() {
+		uploadLog.Warn("Automated edits should be made from a dedicated account, see %s", automatedEditsPolicyURL)
+	}
+
+	if u.requiredUser != "" && user.DisplayName != u.requiredUser {
+		return fmt.Errorf("authenticated as %q but the upload requires %q; refusing to upload", user.DisplayName, u.requiredUser)
+	}
+	return nil
+}
+
+// registerRequireUserFlag adds --require-user and returns a function that applies it when given
+func registerRequireUserFlag(fs *flag.FlagSet) func() {
+	user := fs.String("require-user", "", "Only upload when authenticated as this OSM display name (default: REQUIRE_USER)")
+	return func() {
+		if flagWasSet(fs, "require-user") {
+			flagConfig.Set("REQUIRE_USER", strings.TrimSpace(*user))
+		}
+	}
+}
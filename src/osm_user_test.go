@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLooksLikeBotAccount(t *testing.T) {
+	tests := []struct {
+		user OSMUser
+		want bool
+	}{
+		{OSMUser{DisplayName: "ElevateBot"}, true},
+		{OSMUser{DisplayName: "ro_import"}, true},
+		{OSMUser{DisplayName: "Ion Popescu", Description: "Account for automated edits of the elevate project"}, true},
+		{OSMUser{DisplayName: "Ion Popescu"}, false},
+		{OSMUser{DisplayName: "Botanist Abbott"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.user.DisplayName, func(t *testing.T) {
+			if got := tt.user.LooksLikeBotAccount(); got != tt.want {
+				t.Errorf("LooksLikeBotAccount() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckUser(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		requiredUser string
+		wantErr      bool
+	}{
+		{name: "Any account", status: 200},
+		{name: "Required account", status: 200, requiredUser: "ElevateBot"},
+		{name: "Other account", status: 200, requiredUser: "Ion Popescu", wantErr: true},
+		{name: "Invalid token", status: 401, wantErr: true},
+		{name: "Unavailable", status: 404},
+		{name: "Unavailable with required account", status: 404, requiredUser: "ElevateBot", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/api/0.6/user/details" || tt.status != 200 {
+					http.Error(w, "no", tt.status)
+					return
+				}
+				fmt.Fprint(w, `<osm version="0.6"><user id="42" display_name="ElevateBot" account_created="2024-01-01T00:00:00Z">`+
+					`<description>Bot account</description><changesets count="7"/></user></osm>`)
+			}))
+			defer server.Close()
+
+			api := NewOSMAPIClient(server.Client(), false)
+			api.baseURL = server.URL + "/api/0.6"
+			uploader := &OSMUploader{apiClient: api, requiredUser: tt.requiredUser}
+			if err := uploader.checkUser(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("checkUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
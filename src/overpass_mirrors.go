@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultOverpassMirrors are the public Overpass instances tried when OVERPASS_URL is overloaded
+const DefaultOverpassMirrors = "https://overpass.kumi.systems/api/interpreter,https://maps.mail.ru/osm/tools/overpass/api/interpreter"
+
+// parseOverpassMirrors splits a comma-separated OVERPASS_MIRRORS value; "none" disables failover
+func parseOverpassMirrors(value string) []string {
+	if strings.TrimSpace(value) == "none" {
+		return nil
+	}
+	var mirrors []string
+	for _, url := range strings.Split(value, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			mirrors = append(mirrors, url)
+		}
+	}
+	return mirrors
+}
+
+// endpoints returns the current Overpass URL followed by the other mirrors
+func (e *OverpassExtractor) endpoints() []string {
+	endpoints := []string{e.OverpassURL}
+	for _, mirror := range e.Mirrors {
+		if mirror != e.OverpassURL {
+			endpoints = append(endpoints, mirror)
+		}
+	}
+	return endpoints
+}
+
+// overpassOverloaded reports whether a failed query should be sent to the next mirror:
+// the instance rejected it (429), timed out at the gateway (504) or did not answer in time
+func overpassOverloaded(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		var netErr net.Error
+		return ctx.Err() == nil && errors.As(err, &netErr) && netErr.Timeout()
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusGatewayTimeout
+}
+
+// postQuery sends a query, waiting for a slot first, and fails over to the next mirror while
+// instances are overloaded. The mirror that answered is kept for later queries.
+func (e *OverpassExtractor) postQuery(ctx context.Context, query string, timeout time.Duration) (*http.Response, error) {
+	endpoints := e.endpoints()
+	for i, url := range endpoints {
+		e.OverpassURL = url
+		if err := e.waitForSlot(ctx); err != nil {
+			return nil, err
+		}
+
+		client := NewRetryingReadClient(timeout)
+		if len(endpoints) > 1 {
+			// Switching mirrors beats retrying an overloaded instance for long
+			client.retryConfig.MaxRetries = 1
+		}
+		resp, err := postOverpass(ctx, client, url, query)
+		if i == len(endpoints)-1 || !overpassOverloaded(ctx, resp, err) {
+			return resp, err
+		}
+
+		if err != nil {
+			extractLog.Warn("Overpass instance %s timed out, switching to %s", url, endpoints[i+1])
+		} else {
+			resp.Body.Close()
+			extractLog.Warn("Overpass instance %s returned status %d, switching to %s", url, resp.StatusCode, endpoints[i+1])
+		}
+	}
+	return nil, fmt.Errorf("no Overpass instance configured")
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseOverpassMirrors(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"none", nil},
+		{"https://a/api/interpreter", []string{"https://a/api/interpreter"}},
+		{" https://a/api/interpreter , ,https://b/api/interpreter", []string{"https://a/api/interpreter", "https://b/api/interpreter"}},
+	}
+	for _, tt := range tests {
+		if got := parseOverpassMirrors(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseOverpassMirrors(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+// overpassServer answers queries with status and counts them; /api/status reports no rate limit
+func overpassServer(t *testing.T, status int, queries *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/status" {
+			io.WriteString(w, "Rate limit: 0\n")
+			return
+		}
+		*queries++
+		w.WriteHeader(status)
+		io.WriteString(w, `{"elements":[]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestPostQueryFailsOverToMirror(t *testing.T) {
+	var mainQueries, mirrorQueries, spareQueries int
+	main := overpassServer(t, http.StatusGatewayTimeout, &mainQueries)
+	mirror := overpassServer(t, http.StatusOK, &mirrorQueries)
+	spare := overpassServer(t, http.StatusOK, &spareQueries)
+
+	extractor := &OverpassExtractor{
+		OverpassURL: main.URL + "/api/interpreter",
+		Mirrors:     []string{main.URL + "/api/interpreter", mirror.URL + "/api/interpreter", spare.URL + "/api/interpreter"},
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := extractor.postQuery(context.Background(), "[out:json];", time.Minute)
+		if err != nil {
+			t.Fatalf("postQuery() error = %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("status = %d, want 200", resp.StatusCode)
+		}
+	}
+
+	// The overloaded instance is tried once and retried once, then the mirror is kept
+	if mainQueries != 2 || mirrorQueries != 2 || spareQueries != 0 {
+		t.Errorf("queries main/mirror/spare = %d/%d/%d, want 2/2/0", mainQueries, mirrorQueries, spareQueries)
+	}
+	if extractor.OverpassURL != mirror.URL+"/api/interpreter" {
+		t.Errorf("OverpassURL = %s, want the mirror", extractor.OverpassURL)
+	}
+}
+
+func TestPostQueryKeepsClientErrors(t *testing.T) {
+	var mainQueries, mirrorQueries int
+	main := overpassServer(t, http.StatusBadRequest, &mainQueries)
+	mirror := overpassServer(t, http.StatusOK, &mirrorQueries)
+
+	extractor := &OverpassExtractor{
+		OverpassURL: main.URL + "/api/interpreter",
+		Mirrors:     []string{mirror.URL + "/api/interpreter"},
+	}
+	resp, err := extractor.postQuery(context.Background(), "invalid", time.Minute)
+	if err != nil {
+		t.Fatalf("postQuery() error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest || mirrorQueries != 0 {
+		t.Errorf("status = %d after %d mirror queries, want 400 without failover", resp.StatusCode, mirrorQueries)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// escapeOverpassString escapes a value for use inside a quoted Overpass QL string. Backslashes
+// and both quote characters are escaped, and control characters are written as \n, \t or \uXXXX,
+// so names like `Côte d'Ivoire` or `A "B" \ C` cannot end the string or alter the query.
+func escapeOverpassString(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\'':
+			b.WriteString(`\'`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// overpassQuote returns value as a double-quoted Overpass QL string
+func overpassQuote(value string) string {
+	return `"` + escapeOverpassString(value) + `"`
+}
+
+// overpassTagFilter returns the filter for elements with key=value, or with key when value is empty
+func overpassTagFilter(key, value string) string {
+	if value == "" {
+		return "[" + overpassQuote(key) + "]"
+	}
+	return "[" + overpassQuote(key) + "=" + overpassQuote(value) + "]"
+}
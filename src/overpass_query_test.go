@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestQueriesWithExoticNames(t *testing.T) {
+	tests := []struct {
+		name string
+		area AreaSelector
+		want string
+	}{
+		{
+			name: "Country with apostrophe",
+			want: `area["name"="Côte d\'Ivoire"]["admin_level"="2"]->.country;`,
+		},
+		{
+			name: "Region with quotes and backslash",
+			area: AreaSelector{Region: `Ținutul "Sus" \ Nord`},
+			want: `rel["boundary"="administrative"]["admin_level"="4"]["name"="Ținutul \"Sus\" \\ Nord"](area.parent);`,
+		},
+		{
+			name: "Country code",
+			area: AreaSelector{CountryCode: "CI"},
+			want: `area["ISO3166-1"="CI"]["admin_level"="2"]->.country;`,
+		},
+	}
+	cat, _ := DefaultProfile().Category("peaks")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			extractor := NewOverpassExtractor("Côte d'Ivoire")
+			extractor.Area = tt.area
+			if query := extractor.categoryQuery(cat); !strings.Contains(query, tt.want) {
+				t.Errorf("Query missing %q:\n%s", tt.want, query)
+			}
+		})
+	}
+
+	selector, err := ParseTagSelector(`name=Bosnia & "Herzegovina"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := selector.overpassFilter(), `["name"="Bosnia & \"Herzegovina\""]`; got != want {
+		t.Errorf("overpassFilter() = %s, want %s", got, want)
+	}
+}
+
+func TestPostOverpassEncodesQuery(t *testing.T) {
+	query := `area["name"="Trinidad & Tobago + 100%"];out;`
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.FormValue("data")
+	}))
+	defer server.Close()
+
+	resp, err := postOverpass(context.Background(), http.DefaultClient, server.URL, query)
+	if err != nil {
+		t.Fatalf("postOverpass() error = %v", err)
+	}
+	resp.Body.Close()
+	if received != query {
+		t.Errorf("Server received %q, want %q", received, query)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	overpassRateLimitRegex = regexp.MustCompile(`^Rate limit: (\d+)`)
+	overpassSlotsNowRegex  = regexp.MustCompile(`^(\d+) slots? available now`)
+	overpassSlotAfterRegex = regexp.MustCompile(`^Slot available after: .*, in (-?\d+) seconds?\.`)
+)
+
+// maxOverpassSlotWait bounds how long we wait for a single slot before re-checking the status
+const maxOverpassSlotWait = 5 * time.Minute
+
+// OverpassStatus describes the slot situation reported by the Overpass /api/status endpoint
+type OverpassStatus struct {
+	RateLimit      int
+	SlotsAvailable int
+	NextSlotIn     []time.Duration
+}
+
+// ParseOverpassStatus parses the plain-text output of /api/status
+func ParseOverpassStatus(r io.Reader) (*OverpassStatus, error) {
+	status := &OverpassStatus{}
+	foundRateLimit := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if m := overpassRateLimitRegex.FindStringSubmatch(line); m != nil {
+			status.RateLimit, _ = strconv.Atoi(m[1])
+			foundRateLimit = true
+		} else if m := overpassSlotsNowRegex.FindStringSubmatch(line); m != nil {
+			status.SlotsAvailable, _ = strconv.Atoi(m[1])
+		} else if m := overpassSlotAfterRegex.FindStringSubmatch(line); m != nil {
+			seconds, _ := strconv.Atoi(m[1])
+			if seconds < 0 {
+				seconds = 0
+			}
+			status.NextSlotIn = append(status.NextSlotIn, time.Duration(seconds)*time.Second)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Overpass status: %v", err)
+	}
+
+	if !foundRateLimit {
+		return nil, fmt.Errorf("unrecognized Overpass status response")
+	}
+
+	return status, nil
+}
+
+// WaitTime returns how long to wait before a query can be started
+func (s *OverpassStatus) WaitTime() time.Duration {
+	// A rate limit of 0 means the instance does not restrict us
+	if s.RateLimit == 0 || s.SlotsAvailable > 0 {
+		return 0
+	}
+
+	if len(s.NextSlotIn) == 0 {
+		// All slots are taken by running queries; poll again shortly
+		return 10 * time.Second
+	}
+
+	wait := s.NextSlotIn[0]
+	for _, d := range s.NextSlotIn[1:] {
+		if d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// overpassStatusURL derives the /api/status URL from an interpreter URL
+func overpassStatusURL(interpreterURL string) string {
+	if strings.HasSuffix(interpreterURL, "/interpreter") {
+		return strings.TrimSuffix(interpreterURL, "/interpreter") + "/status"
+	}
+	return strings.TrimSuffix(interpreterURL, "/") + "/status"
+}
+
+// fetchStatus queries the Overpass /api/status endpoint for our IP
+func (e *OverpassExtractor) fetchStatus(ctx context.Context) (*OverpassStatus, error) {
+	client := NewRetryingReadClient(30 * time.Second)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", overpassStatusURL(e.OverpassURL), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create status request: %v", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Overpass status: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Overpass status returned status %d", resp.StatusCode)
+	}
+
+	return ParseOverpassStatus(resp.Body)
+}
+
+// waitForSlot blocks until the Overpass instance reports a free slot for our IP.
+// Status errors are not fatal: the query is attempted anyway. It only fails when ctx is canceled.
+func (e *OverpassExtractor) waitForSlot(ctx context.Context) error {
+	for {
+		status, err := e.fetchStatus(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			extractLog.Warn("%v, querying without slot check", err)
+			return nil
+		}
+
+		wait := status.WaitTime()
+		if wait <= 0 {
+			return nil
+		}
+		if wait > maxOverpassSlotWait {
+			wait = maxOverpassSlotWait
+		}
+
+		extractLog.Info("No Overpass slot available (rate limit %d), waiting %v...", status.RateLimit, wait)
+		// Add a small margin so the slot is actually free when we query
+		if err := sleepContext(ctx, wait+time.Second); err != nil {
+			return err
+		}
+	}
+}
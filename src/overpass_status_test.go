@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOverpassStatusSlotsAvailable(t *testing.T) {
+	input := `Connected as: 1234567890
+Current time: 2024-05-01T10:00:00Z
+Announced endpoint: none
+Rate limit: 2
+2 slots available now.
+Currently running queries (pid, space limit, time limit, start time):
+`
+	status, err := ParseOverpassStatus(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOverpassStatus() error = %v", err)
+	}
+	if status.RateLimit != 2 || status.SlotsAvailable != 2 {
+		t.Errorf("Unexpected status: %+v", status)
+	}
+	if wait := status.WaitTime(); wait != 0 {
+		t.Errorf("WaitTime() = %v, want 0", wait)
+	}
+}
+
+func TestParseOverpassStatusWaiting(t *testing.T) {
+	input := `Connected as: 1234567890
+Current time: 2024-05-01T10:00:00Z
+Rate limit: 2
+Slot available after: 2024-05-01T10:00:40Z, in 40 seconds.
+Slot available after: 2024-05-01T10:00:25Z, in 25 seconds.
+Currently running queries (pid, space limit, time limit, start time):
+`
+	status, err := ParseOverpassStatus(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseOverpassStatus() error = %v", err)
+	}
+	if len(status.NextSlotIn) != 2 {
+		t.Fatalf("Expected 2 pending slots, got %d", len(status.NextSlotIn))
+	}
+	if wait := status.WaitTime(); wait != 25*time.Second {
+		t.Errorf("WaitTime() = %v, want 25s", wait)
+	}
+}
+
+func TestParseOverpassStatusUnlimited(t *testing.T) {
+	status, err := ParseOverpassStatus(strings.NewReader("Rate limit: 0\n"))
+	if err != nil {
+		t.Fatalf("ParseOverpassStatus() error = %v", err)
+	}
+	if wait := status.WaitTime(); wait != 0 {
+		t.Errorf("WaitTime() with no rate limit = %v, want 0", wait)
+	}
+}
+
+func TestParseOverpassStatusInvalid(t *testing.T) {
+	if _, err := ParseOverpassStatus(strings.NewReader("<html>error</html>")); err == nil {
+		t.Error("Expected error for unrecognized status output")
+	}
+}
+
+func TestOverpassStatusURL(t *testing.T) {
+	tests := map[string]string{
+		"https://overpass-api.de/api/interpreter": "https://overpass-api.de/api/status",
+		"https://overpass.kumi.systems/api/":      "https://overpass.kumi.systems/api/status",
+	}
+	for input, expected := range tests {
+		if got := overpassStatusURL(input); got != expected {
+			t.Errorf("overpassStatusURL(%q) = %q, want %q", input, got, expected)
+		}
+	}
+}
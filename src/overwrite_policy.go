@@ -0,0 +1,125 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Overwrite policy modes for elements that already have ele
+const (
+	// OverwriteFillMissing only adds ele to elements without one
+	OverwriteFillMissing = "fill-missing"
+	// OverwriteIfDiffers also replaces an existing ele that differs from the DEM by more than a threshold
+	OverwriteIfDiffers = "overwrite-if-differs"
+	// OverwriteNeverTouch leaves alone every element carrying any elevation tag (ele, ele:*, source:ele)
+	OverwriteNeverTouch = "never-touch"
+)
+
+// DefaultOverwriteThreshold is the difference in meters above which overwrite-if-differs replaces ele
+const DefaultOverwriteThreshold = 50.0
+
+// OverwritePolicy decides which elements with an existing ele may be edited
+type OverwritePolicy struct {
+	Mode      string
+	Threshold float64 // meters, for OverwriteIfDiffers
+}
+
+// resolveOverwritePolicy reads OVERWRITE_POLICY and OVERWRITE_THRESHOLD_M
+func resolveOverwritePolicy(config *Config) (OverwritePolicy, error) {
+	policy := OverwritePolicy{Mode: OverwriteFillMissing, Threshold: DefaultOverwriteThreshold}
+	switch mode := config.Get("OVERWRITE_POLICY"); mode {
+	case "":
+	case OverwriteFillMissing, OverwriteIfDiffers, OverwriteNeverTouch:
+		policy.Mode = mode
+	default:
+		return policy, fmt.Errorf("invalid OVERWRITE_POLICY %q (expected %s, %s or %s)",
+			mode, OverwriteFillMissing, OverwriteIfDiffers, OverwriteNeverTouch)
+	}
+	if value := config.Get("OVERWRITE_THRESHOLD_M"); value != "" {
+		threshold, err := strconv.ParseFloat(value, 64)
+		if err != nil || threshold < 0 {
+			return policy, fmt.Errorf("invalid OVERWRITE_THRESHOLD_M %q", value)
+		}
+		policy.Threshold = threshold
+	}
+	return policy, nil
+}
+
+// overwritePolicyOrDefault is resolveOverwritePolicy for components built after the
+// configuration was checked; an invalid policy falls back to fill-missing
+func overwritePolicyOrDefault(config *Config) OverwritePolicy {
+	policy, err := resolveOverwritePolicy(config)
+	if err != nil {
+		return OverwritePolicy{Mode: OverwriteFillMissing, Threshold: DefaultOverwriteThreshold}
+	}
+	return policy
+}
+
+// isElevationTag reports whether a tag key records an elevation or its source
+func isElevationTag(key string) bool {
+	return key == "ele" || strings.HasPrefix(key, "ele:") || key == "source:ele"
+}
+
+// Selects reports whether the filter step keeps an extracted element with these tags
+func (p OverwritePolicy) Selects(tags map[string]string) bool {
+	switch p.Mode {
+	case OverwriteIfDiffers:
+		return true
+	case OverwriteNeverTouch:
+		for key := range tags {
+			if isElevationTag(key) {
+				return false
+			}
+		}
+		return true
+	default:
+		_, exists := tags["ele"]
+		return !exists
+	}
+}
+
+// Check refuses to edit a live element whose tags the policy protects, returning
+// ErrAlreadyHasEle. newEle is the value the upload would write.
+func (p OverwritePolicy) Check(elementType string, elementID int64, tags []NodeTag, newEle string) error {
+	for _, tag := range tags {
+		switch {
+		case p.Mode == OverwriteNeverTouch && isElevationTag(tag.Key) && strings.TrimSpace(tag.Value) != "":
+			return fmt.Errorf("%w: %s %d has %s=%s", ErrAlreadyHasEle, elementType, elementID, tag.Key, tag.Value)
+		case tag.Key != "ele" || strings.TrimSpace(tag.Value) == "":
+			continue
+		case p.Mode != OverwriteIfDiffers:
+			return fmt.Errorf("%w: %s %d now has ele=%s", ErrAlreadyHasEle, elementType, elementID, tag.Value)
+		}
+
+		live, ok := parseEleTag(tag.Value)
+		if !ok {
+			return fmt.Errorf("%w: %s %d has ele=%s, which cannot be compared", ErrAlreadyHasEle, elementType, elementID, tag.Value)
+		}
+		proposed, ok := parseEleTag(newEle)
+		if !ok {
+			return fmt.Errorf("%w: invalid elevation %q", ErrInvalidUpload, newEle)
+		}
+		if difference := math.Abs(live - proposed); difference <= p.Threshold {
+			return fmt.Errorf("%w: %s %d has ele=%s, within %.0f m of %s", ErrAlreadyHasEle, elementType, elementID, tag.Value, p.Threshold, newEle)
+		}
+	}
+	return nil
+}
+
+// registerOverwriteFlags adds --overwrite and --overwrite-threshold and returns a function that
+// applies them when given
+func registerOverwriteFlags(fs *flag.FlagSet) func() {
+	mode := fs.String("overwrite", OverwriteFillMissing, "Existing ele policy: fill-missing, overwrite-if-differs or never-touch")
+	threshold := fs.Float64("overwrite-threshold", DefaultOverwriteThreshold, "Meters an existing ele must differ by to be replaced (overwrite-if-differs)")
+	return func() {
+		if flagWasSet(fs, "overwrite") {
+			flagConfig.Set("OVERWRITE_POLICY", *mode)
+		}
+		if flagWasSet(fs, "overwrite-threshold") {
+			flagConfig.Set("OVERWRITE_THRESHOLD_M", strconv.FormatFloat(*threshold, 'f', -1, 64))
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOverwritePolicySelects(t *testing.T) {
+	tests := []struct {
+		mode string
+		tags map[string]string
+		want bool
+	}{
+		{OverwriteFillMissing, map[string]string{"natural": "peak"}, true},
+		{OverwriteFillMissing, map[string]string{"natural": "peak", "ele": "2544"}, false},
+		{OverwriteIfDiffers, map[string]string{"natural": "peak", "ele": "2544"}, true},
+		{OverwriteNeverTouch, map[string]string{"natural": "peak"}, true},
+		{OverwriteNeverTouch, map[string]string{"natural": "peak", "ele:local": "2540"}, false},
+		{OverwriteNeverTouch, map[string]string{"natural": "peak", "source:ele": "survey"}, false},
+	}
+	for _, tt := range tests {
+		policy := OverwritePolicy{Mode: tt.mode, Threshold: 10}
+		if got := policy.Selects(tt.tags); got != tt.want {
+			t.Errorf("%s: Selects(%v) = %v, want %v", tt.mode, tt.tags, got, tt.want)
+		}
+	}
+}
+
+func TestOverwritePolicyCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		live    []NodeTag
+		newEle  string
+		wantErr bool
+	}{
+		{"Fill missing without ele", OverwriteFillMissing, []NodeTag{{Key: "natural", Value: "peak"}}, "1500", false},
+		{"Fill missing keeps live ele", OverwriteFillMissing, []NodeTag{{Key: "ele", Value: "1490"}}, "1500", true},
+		{"Differs within threshold", OverwriteIfDiffers, []NodeTag{{Key: "ele", Value: "1490"}}, "1500", true},
+		{"Differs above threshold", OverwriteIfDiffers, []NodeTag{{Key: "ele", Value: "1300 m"}}, "1500", false},
+		{"Unparsable live ele", OverwriteIfDiffers, []NodeTag{{Key: "ele", Value: "~1300"}}, "1500", true},
+		{"Never touch ele:source", OverwriteNeverTouch, []NodeTag{{Key: "ele:source", Value: "survey"}}, "1500", true},
+		{"Never touch untagged", OverwriteNeverTouch, []NodeTag{{Key: "natural", Value: "peak"}}, "1500", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := OverwritePolicy{Mode: tt.mode, Threshold: 50}
+			err := policy.Check("node", 1, tt.live, tt.newEle)
+			if tt.wantErr != errors.Is(err, ErrAlreadyHasEle) {
+				t.Errorf("Check() error = %v, want ErrAlreadyHasEle: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFilterDataOverwritePolicy(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+	data := &OSMData{Peaks: []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak"}},
+		{Type: "node", ID: 2, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak", "ele": "2544"}},
+		{Type: "node", ID: 3, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak", "ele:source": "survey"}},
+	}}
+
+	for mode, want := range map[string]int{OverwriteFillMissing: 2, OverwriteIfDiffers: 3, OverwriteNeverTouch: 1} {
+		flagConfig.Set("OVERWRITE_POLICY", mode)
+		if got := len(NewElevationFilter().FilterData(data).Peaks); got != want {
+			t.Errorf("%s: kept %d peaks, want %d", mode, got, want)
+		}
+	}
+
+	config := NewConfig()
+	config.Set("OVERWRITE_POLICY", "always")
+	if _, err := resolveOverwritePolicy(config); err == nil {
+		t.Error("Expected error for an unknown policy")
+	}
+}
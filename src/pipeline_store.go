@@ -0,0 +1,344 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// DefaultPipelineStoreFile is the SQLite database tracking every element through the pipeline
+const DefaultPipelineStoreFile = "output/pipeline.db"
+
+// Pipeline states of an element, in processing order
+const (
+	StateExtracted = "extracted"
+	StateFiltered  = "filtered"
+	StateEnriched  = "enriched"
+	StateNoData    = "nodata"
+	StateInvalid   = "invalid"
+	StateValidated = "validated"
+	StateUploaded  = "uploaded"
+	StateFailed    = "failed"
+)
+
+// stateTimestampColumns maps each state to the column recording when an element last reached it
+var stateTimestampColumns = map[string]string{
+	StateExtracted: "extracted_at",
+	StateFiltered:  "filtered_at",
+	StateEnriched:  "enriched_at",
+	StateNoData:    "enriched_at",
+	StateInvalid:   "validated_at",
+	StateValidated: "validated_at",
+	StateUploaded:  "uploaded_at",
+	StateFailed:    "uploaded_at",
+}
+
+const pipelineStoreSchema = `
+CREATE TABLE IF NOT EXISTS elements (
+	type         TEXT    NOT NULL,
+	id           INTEGER NOT NULL,
+	version      INTEGER NOT NULL DEFAULT 0,
+	category     TEXT    NOT NULL,
+	name         TEXT    NOT NULL DEFAULT '',
+	lat          REAL,
+	lon          REAL,
+	state        TEXT    NOT NULL,
+	elevation    REAL,
+	provider     TEXT    NOT NULL DEFAULT '',
+	error        TEXT    NOT NULL DEFAULT '',
+	extracted_at TEXT,
+	filtered_at  TEXT,
+	enriched_at  TEXT,
+	validated_at TEXT,
+	uploaded_at  TEXT,
+	updated_at   TEXT    NOT NULL,
+	PRIMARY KEY (type, id)
+);
+CREATE INDEX IF NOT EXISTS elements_state ON elements (state);
+`
+
+// PipelineStore records the state, elevation and timestamps of each element in SQLite. It
+// complements the JSON handoff files, so a workspace can be queried with any SQLite client.
+type PipelineStore struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// OpenPipelineStore opens or creates the store database
+func OpenPipelineStore(path string) (*PipelineStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pipeline store %s: %v", path, err)
+	}
+	if _, err := db.Exec(pipelineStoreSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize pipeline store %s: %v", path, err)
+	}
+	if err := migratePipelineStore(db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to migrate pipeline store %s: %v", path, err)
+	}
+	return &PipelineStore{db: db, now: time.Now}, nil
+}
+
+// migratePipelineStore adds columns introduced after the store was created
+func migratePipelineStore(db *sql.DB) error {
+	var found int
+	err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info('elements') WHERE name = 'version'`).Scan(&found)
+	if err != nil || found > 0 {
+		return err
+	}
+	_, err = db.Exec(`ALTER TABLE elements ADD COLUMN version INTEGER NOT NULL DEFAULT 0`)
+	return err
+}
+
+// Close closes the store database
+func (s *PipelineStore) Close() error {
+	return s.db.Close()
+}
+
+// Record moves elements, grouped by category, to a pipeline state. A fetched elevation is
+// kept once known, even when a later step records the element without it.
+func (s *PipelineStore) Record(state string, elements map[string][]OSMElement) error {
+	return s.record(state, elements, nil)
+}
+
+// RecordErrors moves elements to a failure state together with the reason, keyed by elementKey
+func (s *PipelineStore) RecordErrors(state string, elements map[string][]OSMElement, reasons map[string]string) error {
+	return s.record(state, elements, reasons)
+}
+
+func (s *PipelineStore) record(state string, elements map[string][]OSMElement, reasons map[string]string) error {
+	column, ok := stateTimestampColumns[state]
+	if !ok {
+		return fmt.Errorf("unknown pipeline state %q", state)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to update pipeline store: %v", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(fmt.Sprintf(`
+INSERT INTO elements (type, id, version, category, name, lat, lon, state, elevation, provider, error, %[1]s, updated_at)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (type, id) DO UPDATE SET
+	version    = CASE WHEN excluded.version = 0 THEN elements.version ELSE excluded.version END,
+	category   = excluded.category,
+	name       = excluded.name,
+	lat        = excluded.lat,
+	lon        = excluded.lon,
+	state      = excluded.state,
+	elevation  = COALESCE(excluded.elevation, elements.elevation),
+	provider   = CASE WHEN excluded.provider = '' THEN elements.provider ELSE excluded.provider END,
+	error      = excluded.error,
+	%[1]s      = excluded.%[1]s,
+	updated_at = excluded.updated_at`, column))
+	if err != nil {
+		return fmt.Errorf("failed to update pipeline store: %v", err)
+	}
+	defer stmt.Close()
+
+	now := s.now().UTC().Format(time.RFC3339)
+	coords := NewCoordinateExtractor()
+	for category, list := range elements {
+		for _, element := range list {
+			var lat, lon *float64
+			if c, ok := coords.Extract(element); ok {
+				lat, lon = &c.Lat, &c.Lon
+			}
+			reason := reasons[elementKey(element.Type, element.ID)]
+			_, err := stmt.Exec(element.Type, element.ID, element.Version, category, element.Tags["name"], lat, lon,
+				state, element.ElevationFetched, element.ElevationProvider, reason, now, now)
+			if err != nil {
+				return fmt.Errorf("failed to record %s %d: %v", element.Type, element.ID, err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to update pipeline store: %v", err)
+	}
+	return nil
+}
+
+// StateCounts returns the number of elements in each state
+func (s *PipelineStore) StateCounts() (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT state, COUNT(*) FROM elements GROUP BY state`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var state string
+		var count int
+		if err := rows.Scan(&state, &count); err != nil {
+			return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+		}
+		counts[state] = count
+	}
+	return counts, rows.Err()
+}
+
+// KnownVersions returns the OSM version of the elements in one of states, keyed by elementKey
+func (s *PipelineStore) KnownVersions(states ...string) (map[string]int, error) {
+	versions := make(map[string]int)
+	for _, state := range states {
+		rows, err := s.db.Query(`SELECT type, id, version FROM elements WHERE state = ? AND version > 0`, state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+		}
+		for rows.Next() {
+			var elementType string
+			var id int64
+			var version int
+			if err := rows.Scan(&elementType, &id, &version); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+			}
+			versions[elementKey(elementType, id)] = version
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+		}
+	}
+	return versions, nil
+}
+
+// ElementIDs returns the IDs of the elements in one of states per element type, in ascending order
+func (s *PipelineStore) ElementIDs(states ...string) (map[string][]int64, error) {
+	ids := make(map[string][]int64)
+	for _, state := range states {
+		rows, err := s.db.Query(`SELECT type, id FROM elements WHERE state = ?`, state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+		}
+		for rows.Next() {
+			var elementType string
+			var id int64
+			if err := rows.Scan(&elementType, &id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+			}
+			ids[elementType] = append(ids[elementType], id)
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+		}
+	}
+	for _, list := range ids {
+		sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	}
+	return ids, nil
+}
+
+// ErrorCounts returns the number of elements in a state per recorded error
+func (s *PipelineStore) ErrorCounts(state string) (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT error, COUNT(*) FROM elements WHERE state = ? GROUP BY error`, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var reason string
+		var count int
+		if err := rows.Scan(&reason, &count); err != nil {
+			return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+		}
+		counts[reason] = count
+	}
+	return counts, rows.Err()
+}
+
+// ProblemElement is an element that failed validation or upload, as recorded in the store
+type ProblemElement struct {
+	State     string
+	Category  string
+	Type      string
+	ID        int64
+	Name      string
+	Lat       *float64
+	Lon       *float64
+	Elevation *float64
+	Reason    string
+}
+
+// Problems returns the elements that failed validation or upload, invalid ones first
+func (s *PipelineStore) Problems() ([]ProblemElement, error) {
+	rows, err := s.db.Query(`
+SELECT state, category, type, id, name, lat, lon, elevation, error FROM elements
+WHERE state IN (?, ?)
+ORDER BY state = ?, category, type, id`, StateInvalid, StateFailed, StateFailed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+	}
+	defer rows.Close()
+
+	var problems []ProblemElement
+	for rows.Next() {
+		var p ProblemElement
+		if err := rows.Scan(&p.State, &p.Category, &p.Type, &p.ID, &p.Name, &p.Lat, &p.Lon, &p.Elevation, &p.Reason); err != nil {
+			return nil, fmt.Errorf("failed to query pipeline store: %v", err)
+		}
+		problems = append(problems, p)
+	}
+	return problems, rows.Err()
+}
+
+// recordPipelineState updates the store of a workspace after a step. The JSON files remain
+// the handoff between steps, so a store error is only reported.
+func recordPipelineState(ws Workspace, update func(store *PipelineStore) error) {
+	path := ws.File(DefaultPipelineStoreFile)
+	store, err := OpenPipelineStore(path)
+	if err == nil {
+		err = update(store)
+		store.Close()
+	}
+	if err != nil {
+		pipelineLog.Warn("Failed to update pipeline store: %v", err)
+		return
+	}
+	pipelineLog.Info("✓ Pipeline state recorded in %s", path)
+}
+
+// recordUploadState records which validated elements are uploaded according to the run ledger
+// and which failed in this upload
+func recordUploadState(ws Workspace, data ValidatedData, state *CountryRunState, stats map[string]UploadStats) {
+	reasons := make(map[string]string)
+	for _, categoryStats := range stats {
+		for _, uploadErr := range categoryStats.Errors {
+			reasons[elementKey(uploadErr.ElementType, uploadErr.ElementID)] = uploadErr.Error
+		}
+	}
+
+	uploaded := make(map[string][]OSMElement)
+	failed := make(map[string][]OSMElement)
+	for _, key := range categoryKeys {
+		for _, element := range data.Category(key).ValidElements {
+			if _, ok := reasons[elementKey(element.Type, element.ID)]; ok {
+				failed[key] = append(failed[key], element)
+			} else if state.IsUploaded(element.Type, element.ID) {
+				uploaded[key] = append(uploaded[key], element)
+			}
+		}
+	}
+
+	recordPipelineState(ws, func(store *PipelineStore) error {
+		if err := store.Record(StateUploaded, uploaded); err != nil {
+			return err
+		}
+		return store.RecordErrors(StateFailed, failed, reasons)
+	})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPipelineStoreTracksElements(t *testing.T) {
+	store, err := OpenPipelineStore(filepath.Join(t.TempDir(), "pipeline.db"))
+	if err != nil {
+		t.Fatalf("OpenPipelineStore() error = %v", err)
+	}
+	defer store.Close()
+
+	elevation := 1234.5
+	peak := OSMElement{Type: "node", ID: 1, Lat: 45.5, Lon: 25.1, Tags: map[string]string{"name": "Vârful Omu"}}
+	hut := OSMElement{Type: "way", ID: 2, Center: &OSMCenter{Lat: 45.4, Lon: 25.4}}
+
+	if err := store.Record(StateFiltered, map[string][]OSMElement{"peaks": {peak}, "alpine_huts": {hut}}); err != nil {
+		t.Fatalf("Record(filtered) error = %v", err)
+	}
+	enrichedPeak := peak
+	enrichedPeak.ElevationFetched = &elevation
+	enrichedPeak.ElevationProvider = "opentopo"
+	if err := store.Record(StateEnriched, map[string][]OSMElement{"peaks": {enrichedPeak}}); err != nil {
+		t.Fatalf("Record(enriched) error = %v", err)
+	}
+	// Later steps keep the elevation even if they record the element without it
+	if err := store.Record(StateValidated, map[string][]OSMElement{"peaks": {peak}}); err != nil {
+		t.Fatalf("Record(validated) error = %v", err)
+	}
+	reasons := map[string]string{elementKey("way", 2): "no elevation"}
+	if err := store.RecordErrors(StateInvalid, map[string][]OSMElement{"alpine_huts": {hut}}, reasons); err != nil {
+		t.Fatalf("RecordErrors() error = %v", err)
+	}
+
+	counts, err := store.StateCounts()
+	if err != nil {
+		t.Fatalf("StateCounts() error = %v", err)
+	}
+	if want := map[string]int{StateValidated: 1, StateInvalid: 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("StateCounts() = %v, want %v", counts, want)
+	}
+
+	var gotElevation float64
+	var provider, name string
+	var enrichedAt, validatedAt *string
+	row := store.db.QueryRow(`SELECT elevation, provider, name, enriched_at, validated_at FROM elements WHERE type = 'node' AND id = 1`)
+	if err := row.Scan(&gotElevation, &provider, &name, &enrichedAt, &validatedAt); err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if gotElevation != elevation || provider != "opentopo" || name != "Vârful Omu" {
+		t.Errorf("peak = %v m from %q named %q, want %v m from opentopo", gotElevation, provider, name, elevation)
+	}
+	if enrichedAt == nil || validatedAt == nil {
+		t.Errorf("enriched_at = %v, validated_at = %v; want both set", enrichedAt, validatedAt)
+	}
+
+	var reason string
+	if err := store.db.QueryRow(`SELECT error FROM elements WHERE type = 'way' AND id = 2`).Scan(&reason); err != nil {
+		t.Fatalf("query error = %v", err)
+	}
+	if reason != "no elevation" {
+		t.Errorf("error = %q, want %q", reason, "no elevation")
+	}
+
+	if err := store.Record("published", nil); err == nil {
+		t.Error("Record() with an unknown state succeeded, want an error")
+	}
+}
+
+func TestSkipUnchangedElements(t *testing.T) {
+	ws := Workspace{Dir: t.TempDir()}
+	store, err := OpenPipelineStore(ws.File(DefaultPipelineStoreFile))
+	if err != nil {
+		t.Fatalf("OpenPipelineStore() error = %v", err)
+	}
+	uploaded := OSMElement{Type: "node", ID: 1, Version: 3}
+	invalid := OSMElement{Type: "node", ID: 2, Version: 5}
+	pending := OSMElement{Type: "node", ID: 3, Version: 1}
+	if err := store.Record(StateUploaded, map[string][]OSMElement{"peaks": {uploaded}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Record(StateInvalid, map[string][]OSMElement{"peaks": {invalid}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Record(StateValidated, map[string][]OSMElement{"peaks": {pending}}); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+
+	edited := invalid
+	edited.Version = 6
+	data := &OSMData{Peaks: []OSMElement{uploaded, edited, pending, {Type: "way", ID: 1, Version: 3}}}
+	skipUnchangedElements(ws, data)
+
+	var got []string
+	for _, element := range data.Peaks {
+		got = append(got, elementKey(element.Type, element.ID))
+	}
+	if want := []string{"node/2", "node/3", "way/1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("kept %v, want %v", got, want)
+	}
+}
+
+func TestPendingElements(t *testing.T) {
+	ws := Workspace{Dir: t.TempDir()}
+	if pending := pendingElements(ws); len(pending) != 0 {
+		t.Errorf("pendingElements() without a store = %v, want none", pending)
+	}
+
+	store, err := OpenPipelineStore(ws.File(DefaultPipelineStoreFile))
+	if err != nil {
+		t.Fatalf("OpenPipelineStore() error = %v", err)
+	}
+	records := []struct {
+		state    string
+		elements []OSMElement
+	}{
+		{StateUploaded, []OSMElement{{Type: "node", ID: 1, Version: 1}}},
+		{StateInvalid, []OSMElement{{Type: "node", ID: 2, Version: 1}}},
+		{StateValidated, []OSMElement{{Type: "node", ID: 9, Version: 1}, {Type: "way", ID: 4, Version: 2}}},
+		{StateEnriched, []OSMElement{{Type: "node", ID: 5, Version: 1}}},
+		{StateFailed, []OSMElement{{Type: "relation", ID: 7, Version: 3}}},
+	}
+	for _, record := range records {
+		if err := store.Record(record.state, map[string][]OSMElement{"peaks": record.elements}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	store.Close()
+
+	want := map[string][]int64{"node": {5, 9}, "way": {4}, "relation": {7}}
+	if got := pendingElements(ws); !reflect.DeepEqual(got, want) {
+		t.Errorf("pendingElements() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+)
+
+// DefaultPreviewFile is where the HTML map preview of the enriched elements is written
+const DefaultPreviewFile = "output/preview.html"
+
+// categoryHues gives each category its marker hue on the preview map
+var categoryHues = map[string]int{
+	"peaks":                0,
+	"alpine_huts":          30,
+	"shelters":             120,
+	"train_stations":       210,
+	"other_accommodations": 280,
+}
+
+// previewPoint is one element plotted on the preview map
+type previewPoint struct {
+	Category  string   `json:"category"`
+	Type      string   `json:"type"`
+	ID        int64    `json:"id"`
+	Name      string   `json:"name"`
+	Lat       float64  `json:"lat"`
+	Lon       float64  `json:"lon"`
+	Elevation *float64 `json:"ele"`
+	Link      string   `json:"link"`
+}
+
+// previewPage is the data rendered into previewTemplate
+type previewPage struct {
+	Points     []previewPoint
+	Hues       map[string]int
+	Categories []string
+}
+
+// previewPoints lists the enriched elements that have coordinates
+func previewPoints(data EnrichedData) []previewPoint {
+	extractor := NewCoordinateExtractor()
+	points := []previewPoint{}
+	for _, category := range categoryKeys {
+		for _, element := range *data.Category(category) {
+			coords, valid := extractor.Extract(element)
+			if !valid {
+				continue
+			}
+			points = append(points, previewPoint{
+				Category:  category,
+				Type:      element.Type,
+				ID:        element.ID,
+				Name:      elementName(element),
+				Lat:       coords.Lat,
+				Lon:       coords.Lon,
+				Elevation: element.ElevationFetched,
+				Link:      osmLink(element.Type, element.ID),
+			})
+		}
+	}
+	return points
+}
+
+// WritePreviewHTML renders a self-contained map page of the enriched elements. The data is
+// embedded in the page; Leaflet and its tiles are loaded when online, otherwise the points
+// are drawn on a plain canvas so the file still works offline.
+func WritePreviewHTML(w io.Writer, data EnrichedData) error {
+	return previewTemplate.Execute(w, previewPage{
+		Points:     previewPoints(data),
+		Hues:       categoryHues,
+		Categories: categoryKeys,
+	})
+}
+
+// runPreview writes the HTML map preview of the enriched data of a workspace
+func runPreview(ws Workspace) error {
+	exportLog.Banner("PREVIEW - Writing HTML map of enriched elements")
+
+	var data EnrichedData
+	enrichedFile := ws.File(DefaultEnrichedDataFile)
+	if err := loadPipelineFile(enrichedFile, SchemaEnriched, &data); err != nil {
+		return pipelineLoadError(enrichedFile, "enrich", err)
+	}
+
+	outputFile := ws.File(DefaultPreviewFile)
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", outputFile, err)
+	}
+	defer file.Close()
+
+	if err := WritePreviewHTML(file, data); err != nil {
+		return fmt.Errorf("failed to write preview: %v", err)
+	}
+
+	exportLog.Info("✓ Map preview of %d elements saved to %s", len(previewPoints(data)), outputFile)
+	return nil
+}
+
+var previewTemplate = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>elevate-romania preview</title>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<link rel="stylesheet" href="https://unpkg.com/leaflet@1.9.4/dist/leaflet.css">
+<script src="https://unpkg.com/leaflet@1.9.4/dist/leaflet.js"></script>
+<style>
+html, body { margin: 0; height: 100%; font-family: sans-serif; }
+#map { position: absolute; top: 0; bottom: 0; left: 0; right: 0; }
+#legend { position: absolute; z-index: 1000; right: 10px; bottom: 20px; background: #fff; padding: 8px; font-size: 12px; border-radius: 4px; box-shadow: 0 0 4px #888; }
+#legend span { display: inline-block; width: 10px; height: 10px; border-radius: 5px; margin-right: 4px; }
+#tooltip { position: absolute; z-index: 1000; display: none; background: #fff; padding: 4px; font-size: 12px; border: 1px solid #888; }
+</style>
+</head>
+<body>
+<div id="map"></div>
+<div id="tooltip"></div>
+<div id="legend"></div>
+<script>
+var points = {{.Points}};
+var hues = {{.Hues}};
+var categories = {{.Categories}};
+
+var maxEle = 1;
+points.forEach(function (p) { if (p.ele !== null && p.ele > maxEle) { maxEle = p.ele; } });
+
+// Hue shows the category, darkness the elevation; grey means no elevation was fetched
+function color(p) {
+  if (p.ele === null) { return "#999"; }
+  var lightness = 70 - 45 * Math.max(0, Math.min(1, p.ele / maxEle));
+  return "hsl(" + hues[p.category] + ", 80%, " + lightness + "%)";
+}
+
+function label(p) {
+  var ele = p.ele === null ? "no elevation" : p.ele.toFixed(1) + " m";
+  return (p.name || p.type + " " + p.id) + " (" + p.category + ", " + ele + ")";
+}
+
+var legend = document.getElementById("legend");
+categories.forEach(function (c) {
+  var row = document.createElement("div");
+  var swatch = document.createElement("span");
+  swatch.style.background = "hsl(" + hues[c] + ", 80%, 45%)";
+  row.appendChild(swatch);
+  row.appendChild(document.createTextNode(c));
+  legend.appendChild(row);
+});
+legend.appendChild(document.createTextNode(points.length + " elements, darker is higher"));
+
+if (typeof L !== "undefined") {
+  var map = L.map("map");
+  L.tileLayer("https://tile.openstreetmap.org/{z}/{x}/{y}.png", {
+    maxZoom: 19,
+    attribution: "&copy; OpenStreetMap contributors"
+  }).addTo(map);
+  var bounds = [];
+  points.forEach(function (p) {
+    var marker = L.circleMarker([p.lat, p.lon], { radius: 6, color: "#333", weight: 1, fillColor: color(p), fillOpacity: 0.9 });
+    var popup = document.createElement("div");
+    var link = document.createElement("a");
+    link.href = p.link;
+    link.target = "_blank";
+    link.textContent = label(p);
+    popup.appendChild(link);
+    marker.bindPopup(popup).addTo(map);
+    bounds.push([p.lat, p.lon]);
+  });
+  if (bounds.length > 0) { map.fitBounds(bounds, { padding: [20, 20] }); } else { map.setView([45.9, 25.0], 6); }
+} else {
+  // Offline: plot the points without a base map
+  var container = document.getElementById("map");
+  var canvas = document.createElement("canvas");
+  canvas.width = container.clientWidth;
+  canvas.height = container.clientHeight;
+  container.appendChild(canvas);
+  var ctx = canvas.getContext("2d");
+  var minLat = 90, maxLat = -90, minLon = 180, maxLon = -180;
+  points.forEach(function (p) {
+    minLat = Math.min(minLat, p.lat); maxLat = Math.max(maxLat, p.lat);
+    minLon = Math.min(minLon, p.lon); maxLon = Math.max(maxLon, p.lon);
+  });
+  var scale = Math.min((canvas.width - 40) / Math.max(maxLon - minLon, 1e-6), (canvas.height - 40) / Math.max(maxLat - minLat, 1e-6));
+  function project(p) { return [20 + (p.lon - minLon) * scale, canvas.height - 20 - (p.lat - minLat) * scale]; }
+  points.forEach(function (p) {
+    var xy = project(p);
+    ctx.beginPath();
+    ctx.arc(xy[0], xy[1], 5, 0, 2 * Math.PI);
+    ctx.fillStyle = color(p);
+    ctx.fill();
+    ctx.strokeStyle = "#333";
+    ctx.stroke();
+  });
+  var tooltip = document.getElementById("tooltip");
+  function nearest(e) {
+    var best = null, bestDistance = 64;
+    points.forEach(function (p) {
+      var xy = project(p), dx = xy[0] - e.offsetX, dy = xy[1] - e.offsetY;
+      if (dx * dx + dy * dy < bestDistance) { best = p; bestDistance = dx * dx + dy * dy; }
+    });
+    return best;
+  }
+  canvas.addEventListener("mousemove", function (e) {
+    var p = nearest(e);
+    tooltip.style.display = p ? "block" : "none";
+    if (p) {
+      tooltip.textContent = label(p);
+      tooltip.style.left = (e.clientX + 10) + "px";
+      tooltip.style.top = (e.clientY + 10) + "px";
+    }
+  });
+  canvas.addEventListener("click", function (e) {
+    var p = nearest(e);
+    if (p) { window.open(p.link, "_blank"); }
+  });
+}
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWritePreviewHTML(t *testing.T) {
+	elevation := 2505.5
+	data := EnrichedData{
+		Peaks: []OSMElement{
+			{Type: "node", ID: 1, Lat: 45.6, Lon: 25.5, Tags: map[string]string{"name": "Omu</script><b>"}, ElevationFetched: &elevation},
+			{Type: "node", ID: 2},
+		},
+		Shelters: []OSMElement{
+			{Type: "way", ID: 3, Center: &OSMCenter{Lat: 45.4, Lon: 25.3}},
+		},
+	}
+
+	points := previewPoints(data)
+	if len(points) != 2 {
+		t.Fatalf("previewPoints() returned %d points, want 2 (element without coordinates skipped)", len(points))
+	}
+	if points[1].Category != "shelters" || points[1].Elevation != nil {
+		t.Errorf("unexpected second point %+v", points[1])
+	}
+
+	var out bytes.Buffer
+	if err := WritePreviewHTML(&out, data); err != nil {
+		t.Fatalf("WritePreviewHTML() error = %v", err)
+	}
+	page := out.String()
+
+	for _, want := range []string{
+		`"ele":2505.5`,
+		`"link":"https://www.openstreetmap.org/way/3"`,
+		`"category":"shelters"`,
+		"leaflet.js",
+		"getContext",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("preview missing %q", want)
+		}
+	}
+	if strings.Contains(page, "Omu</script>") {
+		t.Error("element name was not escaped inside the script")
+	}
+}
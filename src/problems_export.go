@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// DefaultProblemsFile lists the elements rejected by validation or failed during upload
+const DefaultProblemsFile = "output/problem_elements.csv"
+
+var problemsCSVHeader = []string{"status", "category", "type", "id", "name", "lat", "lon", "elevation", "reason", "osm_link"}
+
+// ExportProblemsCSV writes the problem elements with their reason and an OSM link for follow-up
+func ExportProblemsCSV(problems []ProblemElement, outputFile string) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create problems CSV: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write(problemsCSVHeader); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+
+	optional := func(value *float64, format string) string {
+		if value == nil {
+			return ""
+		}
+		return fmt.Sprintf(format, *value)
+	}
+	for _, p := range problems {
+		record := []string{
+			p.State,
+			p.Category,
+			p.Type,
+			strconv.FormatInt(p.ID, 10),
+			p.Name,
+			optional(p.Lat, "%.6f"),
+			optional(p.Lon, "%.6f"),
+			optional(p.Elevation, "%.1f"),
+			p.Reason,
+			osmLink(p.Type, p.ID),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write row: %v", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write problems CSV: %v", err)
+	}
+	return nil
+}
+
+// exportProblemElements rewrites the problems CSV of a workspace from its pipeline store after
+// validation or upload. Like the store itself it is a by-product, so errors are only reported.
+func exportProblemElements(ws Workspace) {
+	storeFile := ws.File(DefaultPipelineStoreFile)
+	if _, err := os.Stat(storeFile); err != nil {
+		return
+	}
+	store, err := OpenPipelineStore(storeFile)
+	if err != nil {
+		pipelineLog.Warn("Failed to export problem elements: %v", err)
+		return
+	}
+	problems, err := store.Problems()
+	store.Close()
+	if err != nil {
+		pipelineLog.Warn("Failed to export problem elements: %v", err)
+		return
+	}
+
+	outputFile := ws.File(DefaultProblemsFile)
+	if err := ExportProblemsCSV(problems, outputFile); err != nil {
+		pipelineLog.Warn("Failed to export problem elements: %v", err)
+		return
+	}
+	if len(problems) > 0 {
+		exportLog.Info("✓ %d invalid or failed elements listed in %s", len(problems), outputFile)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestExportProblemsCSV(t *testing.T) {
+	dir := t.TempDir()
+	store, err := OpenPipelineStore(filepath.Join(dir, "pipeline.db"))
+	if err != nil {
+		t.Fatalf("OpenPipelineStore() error = %v", err)
+	}
+	defer store.Close()
+
+	elevation := 3012.0
+	peak := OSMElement{Type: "node", ID: 1, Lat: 45.5, Lon: 25.1, Tags: map[string]string{"name": "Vârful Omu"}, ElevationFetched: &elevation}
+	hut := OSMElement{Type: "way", ID: 2, Center: &OSMCenter{Lat: 45.4, Lon: 25.4}, Tags: map[string]string{"name": "Cabana Omu"}}
+	station := OSMElement{Type: "node", ID: 3, Lat: 44.4, Lon: 26.1}
+
+	if err := store.Record(StateValidated, map[string][]OSMElement{"train_stations": {station}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RecordErrors(StateFailed, map[string][]OSMElement{"alpine_huts": {hut}},
+		map[string]string{elementKey("way", 2): "version conflict"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RecordErrors(StateInvalid, map[string][]OSMElement{"peaks": {peak}},
+		map[string]string{elementKey("node", 1): "Elevation 3012.0m above maximum 2544.0m"}); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := store.Problems()
+	if err != nil {
+		t.Fatalf("Problems() error = %v", err)
+	}
+	outputFile := filepath.Join(dir, "problem_elements.csv")
+	if err := ExportProblemsCSV(problems, outputFile); err != nil {
+		t.Fatalf("ExportProblemsCSV() error = %v", err)
+	}
+
+	file, err := os.Open(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		problemsCSVHeader,
+		{"invalid", "peaks", "node", "1", "Vârful Omu", "45.500000", "25.100000", "3012.0", "Elevation 3012.0m above maximum 2544.0m", "https://www.openstreetmap.org/node/1"},
+		{"failed", "alpine_huts", "way", "2", "Cabana Omu", "45.400000", "25.400000", "", "version conflict", "https://www.openstreetmap.org/way/2"},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("CSV = %v, want %v", records, want)
+	}
+}
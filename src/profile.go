@@ -0,0 +1,350 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile declares which features are extracted and how they are grouped into categories
+type Profile struct {
+	Name       string            `yaml:"name"`
+	Categories []ProfileCategory `yaml:"categories"`
+	// Exclude lists selectors of elements skipped by the filter step because surface DEM
+	// elevation is wrong for them. Profiles without the key use DefaultExclusions; [] keeps all.
+	Exclude []string `yaml:"exclude"`
+}
+
+// DefaultExclusions skips underground and indoor elements, such as metro stations or hotels in malls,
+// and features whose existence is questionable (fixme, disused, abandoned or under construction)
+var DefaultExclusions = []string{
+	"location=underground", "layer<0", "level", "indoor=yes",
+	"fixme", "FIXME", "disused:*", "abandoned:*", "construction",
+}
+
+// ProfileCategory is a pipeline category with the tag selectors that select its elements.
+// Categories are listed in priority order: elements matching several categories belong to the first.
+type ProfileCategory struct {
+	Key      string   `yaml:"key"`      // pipeline category key, e.g. alpine_huts
+	Label    string   `yaml:"label"`    // human-readable name used in progress output
+	Types    []string `yaml:"types"`    // OSM element types to query (node, way)
+	Tags     []string `yaml:"tags"`     // selectors as key=value or key; an element matching any of them belongs to the category
+	Priority bool     `yaml:"priority"` // highlighted as a priority category in progress output
+}
+
+// TagSelector matches an OSM tag, or any value of a key when Value is empty. With Op set
+// to < or >, it matches numeric values below or above Value instead. A Key ending in *
+// matches every key with that prefix, e.g. disused:*.
+type TagSelector struct {
+	Key   string
+	Value string
+	Op    string
+}
+
+// pipelineCategories maps the category keys stored in the pipeline data files to element categories
+var pipelineCategories = map[string]ElementCategory{
+	"peaks":                CategoryPeak,
+	"alpine_huts":          CategoryAlpineHut,
+	"shelters":             CategoryShelter,
+	"train_stations":       CategoryTrainStation,
+	"other_accommodations": CategoryOtherAccommodation,
+}
+
+var (
+	profileMu      sync.Mutex
+	currentProfile *Profile
+)
+
+// DefaultProfile returns the built-in profile (same as profiles/default.yaml)
+func DefaultProfile() *Profile {
+	return &Profile{
+		Name: "default",
+		Categories: []ProfileCategory{
+			{
+				Key:      "peaks",
+				Label:    "Peaks",
+				Types:    []string{"node"},
+				Tags:     []string{"natural=peak"},
+				Priority: true,
+			},
+			{
+				Key:      "alpine_huts",
+				Label:    "Alpine huts",
+				Types:    []string{"node", "way", "relation"},
+				Tags:     []string{"tourism=alpine_hut"},
+				Priority: true,
+			},
+			{
+				Key:      "shelters",
+				Label:    "Shelters",
+				Types:    []string{"node", "way", "relation"},
+				Tags:     []string{"amenity=shelter", "tourism=wilderness_hut"},
+				Priority: true,
+			},
+			{
+				Key:   "train_stations",
+				Label: "Train stations",
+				Types: []string{"node"},
+				Tags:  []string{"railway=station", "railway=halt"},
+			},
+			{
+				Key:   "other_accommodations",
+				Label: "Other accommodations",
+				Types: []string{"node", "way", "relation"},
+				Tags:  []string{"tourism=hotel", "tourism=guest_house", "tourism=chalet", "tourism=hostel", "tourism=motel"},
+			},
+		},
+		Exclude: append([]string(nil), DefaultExclusions...),
+	}
+}
+
+// LoadProfile reads and validates a YAML profile file
+func LoadProfile(path string) (*Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read profile: %v", err)
+	}
+
+	var profile Profile
+	if err := yaml.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse profile %s: %v", path, err)
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid profile %s: %v", path, err)
+	}
+	return &profile, nil
+}
+
+// Validate checks category keys, element types and tag selectors
+func (p *Profile) Validate() error {
+	if len(p.Categories) == 0 {
+		return fmt.Errorf("no categories defined")
+	}
+
+	seen := make(map[string]bool)
+	for i, cat := range p.Categories {
+		if _, ok := pipelineCategories[cat.Key]; !ok {
+			return fmt.Errorf("category %d: unknown key %q (expected one of %s)", i+1, cat.Key, strings.Join(knownCategoryKeys(), ", "))
+		}
+		if seen[cat.Key] {
+			return fmt.Errorf("category %q defined more than once", cat.Key)
+		}
+		seen[cat.Key] = true
+
+		if len(cat.Tags) == 0 {
+			return fmt.Errorf("category %q has no tag selectors", cat.Key)
+		}
+		for _, tag := range cat.Tags {
+			selector, err := ParseTagSelector(tag)
+			if err != nil {
+				return fmt.Errorf("category %q: %v", cat.Key, err)
+			}
+			if selector.Op != "" || selector.isPrefix() {
+				return fmt.Errorf("category %q: selector %q is only supported in exclude", cat.Key, tag)
+			}
+		}
+		for _, elementType := range cat.Types {
+			if elementType != "node" && elementType != "way" && elementType != "relation" {
+				return fmt.Errorf("category %q: unsupported element type %q (expected node, way or relation)", cat.Key, elementType)
+			}
+		}
+	}
+
+	for _, rule := range p.Exclude {
+		if _, err := ParseTagSelector(rule); err != nil {
+			return fmt.Errorf("exclude: %v", err)
+		}
+	}
+	return nil
+}
+
+// exclusions returns the parsed exclusion selectors, defaulting to DefaultExclusions
+func (p *Profile) exclusions() []TagSelector {
+	rules := p.Exclude
+	if rules == nil {
+		rules = DefaultExclusions
+	}
+	selectors := make([]TagSelector, 0, len(rules))
+	for _, rule := range rules {
+		if selector, err := ParseTagSelector(rule); err == nil {
+			selectors = append(selectors, selector)
+		}
+	}
+	return selectors
+}
+
+// knownCategoryKeys returns the pipeline category keys in default priority order
+func knownCategoryKeys() []string {
+	var keys []string
+	for _, cat := range DefaultProfile().Categories {
+		keys = append(keys, cat.Key)
+	}
+	return keys
+}
+
+// Keys returns the profile's category keys in priority order
+func (p *Profile) Keys() []string {
+	keys := make([]string, len(p.Categories))
+	for i, cat := range p.Categories {
+		keys[i] = cat.Key
+	}
+	return keys
+}
+
+// Only returns a copy of the profile restricted to the given categories, keeping their priority order
+func (p *Profile) Only(keys []string) (*Profile, error) {
+	selected := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if _, ok := p.Category(key); !ok {
+			return nil, fmt.Errorf("unknown category %q (profile %s has %s)", key, p.Name, strings.Join(p.Keys(), ", "))
+		}
+		selected[key] = true
+	}
+
+	only := *p
+	only.Categories = nil
+	for _, cat := range p.Categories {
+		if selected[cat.Key] {
+			only.Categories = append(only.Categories, cat)
+		}
+	}
+	return &only, nil
+}
+
+// ParseTagSelector parses a key=value, key<number, key>number or key selector, where key may end in *
+func ParseTagSelector(value string) (TagSelector, error) {
+	trimmed := strings.TrimSpace(value)
+	selector := TagSelector{Key: trimmed}
+	if i := strings.IndexAny(trimmed, "=<>"); i >= 0 {
+		selector.Key = strings.TrimSpace(trimmed[:i])
+		selector.Value = strings.TrimSpace(trimmed[i+1:])
+		if trimmed[i] != '=' {
+			selector.Op = trimmed[i : i+1]
+			if _, err := strconv.ParseFloat(selector.Value, 64); err != nil {
+				return TagSelector{}, fmt.Errorf("invalid tag selector %q: %s needs a number", value, selector.Op)
+			}
+		}
+	}
+	if selector.Key == "" {
+		return TagSelector{}, fmt.Errorf("invalid tag selector %q", value)
+	}
+	return selector, nil
+}
+
+// isPrefix reports whether the selector's key is a prefix wildcard such as disused:*
+func (s TagSelector) isPrefix() bool {
+	return strings.HasSuffix(s.Key, "*")
+}
+
+// Matches reports whether an element's tags satisfy the selector
+func (s TagSelector) Matches(tags map[string]string) bool {
+	if s.isPrefix() {
+		prefix := strings.TrimSuffix(s.Key, "*")
+		for key, value := range tags {
+			if strings.HasPrefix(key, prefix) && s.matchesValue(value) {
+				return true
+			}
+		}
+		return false
+	}
+	value, ok := tags[s.Key]
+	return ok && s.matchesValue(value)
+}
+
+// matchesValue compares a tag value against the selector's value and operator
+func (s TagSelector) matchesValue(value string) bool {
+	switch s.Op {
+	case "<", ">":
+		number, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return false
+		}
+		limit, _ := strconv.ParseFloat(s.Value, 64)
+		if s.Op == "<" {
+			return number < limit
+		}
+		return number > limit
+	}
+	return s.Value == "" || value == s.Value
+}
+
+// overpassFilter renders the selector as an Overpass tag filter
+func (s TagSelector) overpassFilter() string {
+	return overpassTagFilter(s.Key, s.Value)
+}
+
+// selectors returns the parsed tag selectors of the category
+func (c ProfileCategory) selectors() []TagSelector {
+	selectors := make([]TagSelector, 0, len(c.Tags))
+	for _, tag := range c.Tags {
+		if selector, err := ParseTagSelector(tag); err == nil {
+			selectors = append(selectors, selector)
+		}
+	}
+	return selectors
+}
+
+// elementTypes returns the element types to query, defaulting to nodes and ways
+func (c ProfileCategory) elementTypes() []string {
+	if len(c.Types) == 0 {
+		return []string{"node", "way"}
+	}
+	return c.Types
+}
+
+// Matches reports whether an element belongs to the category
+func (c ProfileCategory) Matches(element OSMElement) bool {
+	if element.Type != "" {
+		typeOK := false
+		for _, elementType := range c.elementTypes() {
+			if element.Type == elementType {
+				typeOK = true
+				break
+			}
+		}
+		if !typeOK {
+			return false
+		}
+	}
+
+	for _, selector := range c.selectors() {
+		if selector.Matches(element.Tags) {
+			return true
+		}
+	}
+	return false
+}
+
+// Category returns the profile category with the given key
+func (p *Profile) Category(key string) (ProfileCategory, bool) {
+	for _, cat := range p.Categories {
+		if cat.Key == key {
+			return cat, true
+		}
+	}
+	return ProfileCategory{}, false
+}
+
+// activeProfile returns the profile driving the pipeline (the default unless --profile was given)
+func activeProfile() *Profile {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+
+	if currentProfile == nil {
+		currentProfile = DefaultProfile()
+	}
+	return currentProfile
+}
+
+// SetActiveProfile makes a profile drive extraction, filtering and upload categories
+func SetActiveProfile(profile *Profile) {
+	profileMu.Lock()
+	defer profileMu.Unlock()
+
+	currentProfile = profile
+	categoryKeys = profile.Keys()
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// useProfile activates a profile for the duration of a test
+func useProfile(t *testing.T, profile *Profile) {
+	t.Helper()
+	previous := activeProfile()
+	SetActiveProfile(profile)
+	t.Cleanup(func() { SetActiveProfile(previous) })
+}
+
+func TestDefaultProfileFileMatchesBuiltin(t *testing.T) {
+	profile, err := LoadProfile("profiles/default.yaml")
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	if !reflect.DeepEqual(profile, DefaultProfile()) {
+		t.Errorf("profiles/default.yaml differs from DefaultProfile():\n%+v\n%+v", profile, DefaultProfile())
+	}
+}
+
+func TestLoadProfileAlpine(t *testing.T) {
+	profile, err := LoadProfile("profiles/alpine.yaml")
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	expected := []string{"peaks", "alpine_huts", "shelters", "other_accommodations"}
+	if !reflect.DeepEqual(profile.Keys(), expected) {
+		t.Errorf("Expected keys %v, got %v", expected, profile.Keys())
+	}
+}
+
+func TestProfileValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name:    "No categories",
+			yaml:    "name: empty\n",
+			wantErr: "no categories",
+		},
+		{
+			name:    "Unknown key",
+			yaml:    "categories:\n  - key: viewpoints\n    tags: [tourism=viewpoint]\n",
+			wantErr: "unknown key",
+		},
+		{
+			name:    "Duplicate key",
+			yaml:    "categories:\n  - key: peaks\n    tags: [natural=peak]\n  - key: peaks\n    tags: [natural=volcano]\n",
+			wantErr: "more than once",
+		},
+		{
+			name:    "No selectors",
+			yaml:    "categories:\n  - key: peaks\n",
+			wantErr: "no tag selectors",
+		},
+		{
+			name:    "Bad element type",
+			yaml:    "categories:\n  - key: peaks\n    types: [area]\n    tags: [natural=peak]\n",
+			wantErr: "unsupported element type",
+		},
+		{
+			name:    "Comparison in category",
+			yaml:    "categories:\n  - key: peaks\n    tags: [layer<0]\n",
+			wantErr: "only supported in exclude",
+		},
+		{
+			name:    "Prefix in category",
+			yaml:    "categories:\n  - key: peaks\n    tags: [disused:*]\n",
+			wantErr: "only supported in exclude",
+		},
+		{
+			name:    "Bad exclude rule",
+			yaml:    "categories:\n  - key: peaks\n    tags: [natural=peak]\nexclude: [layer<deep]\n",
+			wantErr: "needs a number",
+		},
+		{
+			name: "Valid",
+			yaml: "categories:\n  - key: peaks\n    tags: [natural=peak]\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "profile.yaml")
+			if err := os.WriteFile(path, []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := LoadProfile(path)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestTagSelectorMatches(t *testing.T) {
+	tests := []struct {
+		selector string
+		tags     map[string]string
+		expected bool
+	}{
+		{"natural=peak", map[string]string{"natural": "peak"}, true},
+		{"natural=peak", map[string]string{"natural": "saddle"}, false},
+		{"natural=peak", map[string]string{}, false},
+		{"ruins", map[string]string{"ruins": "yes"}, true},
+		{" tourism = alpine_hut ", map[string]string{"tourism": "alpine_hut"}, true},
+		{"layer<0", map[string]string{"layer": "-1"}, true},
+		{"layer<0", map[string]string{"layer": "0"}, false},
+		{"layer<0", map[string]string{"layer": "-1;0"}, false},
+		{"layer > 1", map[string]string{"layer": "2"}, true},
+		{"disused:*", map[string]string{"disused:railway": "station"}, true},
+		{"disused:*", map[string]string{"disused": "yes"}, false},
+		{"abandoned:*=station", map[string]string{"abandoned:railway": "station"}, true},
+	}
+
+	for _, tt := range tests {
+		selector, err := ParseTagSelector(tt.selector)
+		if err != nil {
+			t.Fatalf("ParseTagSelector(%q) failed: %v", tt.selector, err)
+		}
+		if got := selector.Matches(tt.tags); got != tt.expected {
+			t.Errorf("%q.Matches(%v) = %v, expected %v", tt.selector, tt.tags, got, tt.expected)
+		}
+	}
+
+	if _, err := ParseTagSelector("=peak"); err == nil {
+		t.Error("Expected error for selector without key")
+	}
+}
+
+func TestCategoryQuery(t *testing.T) {
+	extractor := NewOverpassExtractor(`Côte d"Ivoire`)
+	cat, _ := DefaultProfile().Category("shelters")
+
+	query := extractor.categoryQuery(cat)
+	for _, want := range []string{
+		`area["name"="Côte d\"Ivoire"]["admin_level"="2"]->.country;`,
+		`node["amenity"="shelter"]["ele"!~".*"](area.country);`,
+		`way["tourism"="wilderness_hut"]["ele"!~".*"](area.country);`,
+		`out center meta;`,
+	} {
+		if !strings.Contains(query, want) {
+			t.Errorf("Query missing %q:\n%s", want, query)
+		}
+	}
+
+	extractor.NewerThan = "2024-01-01T00:00:00Z"
+	if query := extractor.categoryQuery(cat); !strings.Contains(query, `(area.country)(newer:"2024-01-01T00:00:00Z");`) {
+		t.Errorf("Expected newer filter in incremental query:\n%s", query)
+	}
+}
+
+func TestCategorizerUsesActiveProfile(t *testing.T) {
+	profile, err := LoadProfile("profiles/alpine.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useProfile(t, profile)
+
+	if !reflect.DeepEqual(categoryKeys, profile.Keys()) {
+		t.Errorf("Expected categoryKeys %v, got %v", profile.Keys(), categoryKeys)
+	}
+
+	categorizer := NewElementCategorizer()
+	tests := []struct {
+		tags     map[string]string
+		expected ElementCategory
+	}{
+		{map[string]string{"natural": "volcano"}, CategoryPeak},
+		{map[string]string{"tourism": "chalet"}, CategoryOtherAccommodation},
+		{map[string]string{"tourism": "hotel"}, CategoryUnknown},
+		{map[string]string{"railway": "station"}, CategoryUnknown},
+	}
+	for _, tt := range tests {
+		if got := categorizer.Categorize(OSMElement{Type: "node", Tags: tt.tags}); got != tt.expected {
+			t.Errorf("Categorize(%v) = %s, expected %s", tt.tags, got, tt.expected)
+		}
+	}
+}
+
+func TestProfileOnly(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []string
+		want    []string
+		wantErr bool
+	}{
+		{"Single", []string{"alpine_huts"}, []string{"alpine_huts"}, false},
+		{"Priority order", []string{"other_accommodations", "train_stations"}, []string{"train_stations", "other_accommodations"}, false},
+		{"Unknown", []string{"hotels"}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			only, err := DefaultProfile().Only(tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Only(%v) error = %v, wantErr %v", tt.keys, err, tt.wantErr)
+			}
+			if err == nil && !reflect.DeepEqual(only.Keys(), tt.want) {
+				t.Errorf("Only(%v) keys = %v, want %v", tt.keys, only.Keys(), tt.want)
+			}
+		})
+	}
+}
+
+func TestUseOnlyCategories(t *testing.T) {
+	useProfile(t, DefaultProfile())
+	if err := useOnlyCategories("alpine_huts"); err != nil {
+		t.Fatalf("useOnlyCategories() error = %v", err)
+	}
+
+	data := ValidatedData{
+		AlpineHuts:          ValidatedCategory{ValidElements: []OSMElement{{Type: "node", ID: 1}}},
+		OtherAccommodations: ValidatedCategory{ValidElements: []OSMElement{{Type: "node", ID: 2}}},
+	}
+	if got := collectAllElements(data); len(got) != 1 || got[0].ID != 1 {
+		t.Errorf("collectAllElements() = %+v, want only the alpine hut", got)
+	}
+}
+
+func TestFilterDataDeduplicatesAcrossQueries(t *testing.T) {
+	filter := NewElevationFilter()
+	peakShelter := OSMElement{Type: "node", ID: 7, Lat: 45.6, Lon: 24.7, Tags: map[string]string{"natural": "peak", "amenity": "shelter"}}
+	data := &OSMData{
+		Peaks:    []OSMElement{peakShelter},
+		Shelters: []OSMElement{peakShelter},
+		Accommodations: []OSMElement{
+			{Type: "node", ID: 1, Lat: 45.0, Lon: 25.0, Tags: map[string]string{"tourism": "alpine_hut"}},
+			{Type: "way", ID: 2, Center: &OSMCenter{Lat: 45.0, Lon: 25.0}, Tags: map[string]string{"tourism": "hotel"}},
+		},
+	}
+
+	filtered := filter.FilterData(data)
+	if len(filtered.Peaks) != 1 || len(filtered.Shelters) != 0 {
+		t.Errorf("Expected the peak shelter once as a peak, got %d peaks and %d shelters", len(filtered.Peaks), len(filtered.Shelters))
+	}
+	if len(filtered.AlpineHuts) != 1 || len(filtered.OtherAccommodations) != 1 {
+		t.Errorf("Expected accommodations split into 1 alpine hut and 1 other, got %d and %d", len(filtered.AlpineHuts), len(filtered.OtherAccommodations))
+	}
+}
+
+func TestFilterDataSkipsExcludedElements(t *testing.T) {
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Lat: 44.4, Lon: 26.1, Tags: map[string]string{"railway": "station"}},
+		{Type: "node", ID: 2, Lat: 44.4, Lon: 26.1, Tags: map[string]string{"railway": "station", "location": "underground"}},
+		{Type: "node", ID: 3, Lat: 44.4, Lon: 26.1, Tags: map[string]string{"railway": "station", "layer": "-2"}},
+		{Type: "node", ID: 4, Lat: 44.4, Lon: 26.1, Tags: map[string]string{"railway": "station", "level": "1"}},
+		{Type: "node", ID: 5, Lat: 44.4, Lon: 26.1, Tags: map[string]string{"railway": "station", "indoor": "yes"}},
+		{Type: "node", ID: 6, Lat: 44.4, Lon: 26.1, Tags: map[string]string{"railway": "station", "fixme": "still open?"}},
+		{Type: "node", ID: 7, Lat: 44.4, Lon: 26.1, Tags: map[string]string{"railway": "station", "disused:railway": "station"}},
+		{Type: "node", ID: 8, Lat: 44.4, Lon: 26.1, Tags: map[string]string{"railway": "station", "construction": "yes"}},
+	}
+
+	filter := NewElevationFilter()
+	filtered := filter.FilterData(&OSMData{TrainStations: elements})
+	if len(filtered.TrainStations) != 1 || filter.Excluded != 7 {
+		t.Errorf("Expected 1 station kept and 7 excluded, got %d and %d", len(filtered.TrainStations), filter.Excluded)
+	}
+
+	profile := DefaultProfile()
+	profile.Exclude = []string{}
+	useProfile(t, profile)
+	filter = NewElevationFilter()
+	filtered = filter.FilterData(&OSMData{TrainStations: elements})
+	if len(filtered.TrainStations) != 8 || filter.Excluded != 0 {
+		t.Errorf("Expected all stations kept with exclude: [], got %d kept and %d excluded", len(filtered.TrainStations), filter.Excluded)
+	}
+}
@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// DefaultProposalFile is the proposal written by propose and executed by apply
+const DefaultProposalFile = "output/proposal.json"
+
+// ProposedEdit describes a single element change computed during the propose phase
+type ProposedEdit struct {
+	Category string            `json:"category"`
+	Version  int               `json:"version"`
+	OldTags  map[string]string `json:"old_tags"`
+	NewTags  map[string]string `json:"new_tags"`
+	Element  OSMElement        `json:"element"`
+}
+
+// Proposal is a reviewable set of edits that can later be executed by apply
+type Proposal struct {
+	Country   string         `json:"country"`
+	CreatedAt string         `json:"created_at"`
+	Edits     []ProposedEdit `json:"edits"`
+	// Checksum is a SHA-256 of the content. It catches accidental edits, but anyone can
+	// recompute it, so it does not prove where the file came from.
+	Checksum string `json:"checksum"`
+	// Signature is an HMAC-SHA256 of the content with PROPOSAL_SIGNING_KEY, set only when a
+	// key is configured; only holders of the key can produce it
+	Signature string `json:"signature,omitempty"`
+}
+
+// elementKey returns a unique key for an element type and ID
+func elementKey(elementType string, id int64) string {
+	return fmt.Sprintf("%s/%d", elementType, id)
+}
+
+// payload is the proposal content covered by the checksum and signature
+func (p *Proposal) payload() ([]byte, error) {
+	payload, err := json.Marshal(struct {
+		Country   string         `json:"country"`
+		CreatedAt string         `json:"created_at"`
+		Edits     []ProposedEdit `json:"edits"`
+	}{p.Country, p.CreatedAt, p.Edits})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal proposal: %v", err)
+	}
+	return payload, nil
+}
+
+// proposalHMAC returns the signature of a payload with key
+func proposalHMAC(payload []byte, key string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write(payload)
+	return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign stores the checksum of the proposal, and its signature when PROPOSAL_SIGNING_KEY is set
+func (p *Proposal) Sign() error {
+	payload, err := p.payload()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(payload)
+	p.Checksum = "sha256:" + hex.EncodeToString(sum[:])
+	p.Signature = ""
+	if key := os.Getenv("PROPOSAL_SIGNING_KEY"); key != "" {
+		p.Signature = proposalHMAC(payload, key)
+	}
+	return nil
+}
+
+// Signed reports whether the proposal carries a signature rather than only a checksum
+func (p *Proposal) Signed() bool {
+	return p.Signature != ""
+}
+
+// Verify checks that the proposal has not been modified since propose. Without a signing key
+// this only catches accidental edits; with PROPOSAL_SIGNING_KEY set the proposal must carry a
+// signature made with that key, so a recomputed checksum is not enough.
+func (p *Proposal) Verify() error {
+	payload, err := p.payload()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(payload)
+	if p.Checksum != "sha256:"+hex.EncodeToString(sum[:]) {
+		return fmt.Errorf("proposal checksum mismatch: file was modified after propose")
+	}
+
+	key := os.Getenv("PROPOSAL_SIGNING_KEY")
+	switch {
+	case key == "" && p.Signed():
+		return fmt.Errorf("proposal is signed; set PROPOSAL_SIGNING_KEY to verify it")
+	case key != "" && !p.Signed():
+		return fmt.Errorf("proposal is not signed although PROPOSAL_SIGNING_KEY is set; re-run --propose with the key")
+	case key != "" && !hmac.Equal([]byte(proposalHMAC(payload, key)), []byte(p.Signature)):
+		return fmt.Errorf("proposal signature mismatch: file was modified after propose or signed with a different key")
+	}
+	return nil
+}
+
+// ExpectedVersions returns the upstream version each edit was computed against
+func (p *Proposal) ExpectedVersions() map[string]int {
+	versions := make(map[string]int, len(p.Edits))
+	for _, edit := range p.Edits {
+		versions[elementKey(edit.Element.Type, edit.Element.ID)] = edit.Version
+	}
+	return versions
+}
+
+// ProposedTags returns the signed tags each edit merges into its element
+func (p *Proposal) ProposedTags() map[string]map[string]string {
+	tags := make(map[string]map[string]string, len(p.Edits))
+	for _, edit := range p.Edits {
+		tags[elementKey(edit.Element.Type, edit.Element.ID)] = edit.NewTags
+	}
+	return tags
+}
+
+// ToValidatedData converts the proposal edits back into the validated data layout used by the uploader
+func (p *Proposal) ToValidatedData() ValidatedData {
+	var data ValidatedData
+	for _, edit := range p.Edits {
+		if category := data.Category(edit.Category); category != nil {
+			category.ValidElements = append(category.ValidElements, edit.Element)
+		}
+	}
+	for _, key := range categoryKeys {
+		data.Category(key).ValidCount = len(data.Category(key).ValidElements)
+	}
+	return data
+}
+
+// fetchUpstreamTags fetches the current version and tags of an element from the OSM API
+func fetchUpstreamTags(ctx context.Context, api *OSMAPIClient, element OSMElement) (int, []NodeTag, error) {
+	switch element.Type {
+	case "node":
+		node, err := api.FetchNode(ctx, element.ID)
+		if err != nil {
+			return 0, nil, err
+		}
+		return node.Version, node.Tags, nil
+	case "way":
+		way, err := api.FetchWay(ctx, element.ID)
+		if err != nil {
+			return 0, nil, err
+		}
+		return way.Version, way.Tags, nil
+	case "relation":
+		relation, err := api.FetchRelation(ctx, element.ID)
+		if err != nil {
+			return 0, nil, err
+		}
+		return relation.Version, relation.Tags, nil
+	default:
+		return 0, nil, fmt.Errorf("unsupported element type: %s", element.Type)
+	}
+}
+
+// buildProposedEdit computes the tag diff between the upstream element and the enriched element
+func buildProposedEdit(category string, element OSMElement, version int, upstreamTags []NodeTag) ProposedEdit {
+	current := make(map[string]string)
+	for _, tag := range upstreamTags {
+		current[tag.Key] = tag.Value
+	}
+
+	edit := ProposedEdit{
+		Category: category,
+		Version:  version,
+		OldTags:  map[string]string{},
+		NewTags:  map[string]string{},
+		Element:  element,
+	}
+
+	sourceKey, _ := eleSourceTag(element.Tags)
+	for _, key := range []string{"ele", sourceKey} {
+		if value, ok := current[key]; ok {
+			edit.OldTags[key] = value
+		}
+		edit.NewTags[key] = element.Tags[key]
+	}
+
+	return edit
+}
+
+// BuildProposal fetches the upstream state of every validated element and records the exact diff
+func BuildProposal(ctx context.Context, api *OSMAPIClient, data ValidatedData, country string) (*Proposal, error) {
+	proposal := &Proposal{
+		Country:   country,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Edits:     []ProposedEdit{},
+	}
+
+	for _, key := range categoryKeys {
+		for _, element := range data.Category(key).ValidElements {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			version, tags, err := fetchUpstreamTags(ctx, api, element)
+			if err != nil {
+				uploadLog.Warn("Skipping %s %d: %v", element.Type, element.ID, err)
+				continue
+			}
+			proposal.Edits = append(proposal.Edits, buildProposedEdit(key, element, version, tags))
+		}
+	}
+
+	if err := proposal.Sign(); err != nil {
+		return nil, err
+	}
+
+	return proposal, nil
+}
+
+// runPropose computes the exact edits for the validated data and writes a signed proposal file
+func runPropose(ctx context.Context, country, proposalFile string) error {
+	if proposalFile == "" {
+		proposalFile = DefaultWorkspace.File(DefaultProposalFile)
+	}
+	uploadLog.Banner("PROPOSE - Computing element diffs against upstream OSM")
+
+	var data ValidatedData
+	validatedFile := DefaultWorkspace.File(DefaultValidatedDataFile)
+	if err := loadPipelineFile(validatedFile, SchemaValidated, &data); err != nil {
+		return pipelineLoadError(validatedFile, "validate", err)
+	}
+
+	// Reading elements does not require authentication
+	api := NewOSMAPIClient(&http.Client{Timeout: 30 * time.Second}, true)
+	proposal, err := BuildProposal(ctx, api, data, country)
+	if err != nil {
+		return err
+	}
+
+	if err := saveJSON(proposalFile, proposal); err != nil {
+		return err
+	}
+
+	reviewCSV, reviewGeoJSON := reviewPaths(proposalFile)
+	if err := ExportProposalReviewCSV(proposal, reviewCSV); err != nil {
+		return err
+	}
+	if err := ExportProposalReviewGeoJSON(proposal, reviewGeoJSON); err != nil {
+		return err
+	}
+
+	uploadLog.Info("✓ Proposed %d edits", len(proposal.Edits))
+	if proposal.Signed() {
+		uploadLog.Info("✓ Signed proposal saved to %s", proposalFile)
+	} else {
+		uploadLog.Info("✓ Proposal saved to %s (unsigned, set PROPOSAL_SIGNING_KEY to sign it)", proposalFile)
+	}
+	uploadLog.Info("✓ Review files saved to %s and %s", reviewCSV, reviewGeoJSON)
+	uploadLog.Info("Review the file, then run --apply to execute it")
+	uploadLog.Info("To upload only reviewed rows, fill the approve column and run --apply --approved <file.csv>")
+
+	return nil
+}
+
+// runApply executes a previously generated proposal, refusing elements whose upstream version
+// changed. The proposed tags are uploaded as signed, whatever the current elevation settings.
+func runApply(ctx context.Context, dryRun bool, oauthConfig *OAuthConfig, proposalFile, approvedFile string) error {
+	if proposalFile == "" {
+		proposalFile = DefaultWorkspace.File(DefaultProposalFile)
+	}
+	undoLogFile := DefaultWorkspace.File(DefaultUndoLogFile)
+	changesetsFile := DefaultWorkspace.File(DefaultChangesetsFile)
+	dryRunDiffFile := DefaultWorkspace.File(DefaultDryRunDiffFile)
+	resultsFile := DefaultWorkspace.File(DefaultUploadResultsFile)
+	if dryRun {
+		uploadLog.Banner("APPLY (DRY-RUN) - Preview proposal")
+	} else {
+		uploadLog.Banner("APPLY - Executing proposal")
+	}
+	started := time.Now()
+
+	var proposal Proposal
+	if err := loadJSON(proposalFile, &proposal); err != nil {
+		return fmt.Errorf("%s not found. Run --propose first: %v", proposalFile, err)
+	}
+
+	if err := proposal.Verify(); err != nil {
+		return err
+	}
+	if proposal.Signed() {
+		uploadLog.Info("✓ Proposal signature verified (%d edits, created %s)", len(proposal.Edits), proposal.CreatedAt)
+	} else {
+		uploadLog.Info("✓ Proposal checksum verified (%d edits, created %s); unsigned, so it only detects accidental changes", len(proposal.Edits), proposal.CreatedAt)
+	}
+	if err := runStepStartHook(DefaultWorkspace, StepApply, proposal.Country); err != nil {
+		return err
+	}
+
+	toApply := &proposal
+	if approvedFile != "" {
+		file, err := os.Open(approvedFile)
+		if err != nil {
+			return fmt.Errorf("failed to open approved file: %v", err)
+		}
+		approved, err := LoadApprovedKeys(file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+
+		toApply = proposal.FilterApproved(approved)
+		uploadLog.Info("✓ %d of %d edits approved in %s", len(toApply.Edits), len(proposal.Edits), approvedFile)
+		if len(toApply.Edits) == 0 {
+			return fmt.Errorf("no approved edits found in %s", approvedFile)
+		}
+	}
+
+	uploader, err := NewOSMUploader(oauthConfig, dryRun, proposal.Country, undoLogFile)
+	if err != nil {
+		return err
+	}
+	uploader.useProposal(toApply)
+	if !dryRun {
+		if uploader.changesetLog, err = LoadChangesetLog(changesetsFile); err != nil {
+			return err
+		}
+	}
+
+	stats, err := uploader.UploadAll(ctx, toApply.ToValidatedData())
+	interrupted := err != nil && ctx.Err() != nil
+	aborted := errors.Is(err, ErrTooManyFailures)
+	paused := errors.Is(err, ErrEditQuotaReached)
+	if err != nil && !interrupted && !aborted && !paused {
+		return err
+	}
+
+	printUploadStats(stats, dryRun)
+	printChangesetLinks(uploader.created, changesetsFile)
+
+	outputs := []string{dryRunDiffFile}
+	if dryRun {
+		if err := uploader.dryRunDiff.Save(dryRunDiffFile); err != nil {
+			return err
+		}
+		uploadLog.Info("✓ Tag changes saved to %s", dryRunDiffFile)
+	} else {
+		if err := SaveUploadResults(resultsFile, proposal.Country, stats); err != nil {
+			return err
+		}
+		recordHistory(StepApply, proposal.Country, stats, len(uploader.created))
+		outputs = []string{resultsFile, undoLogFile, changesetsFile}
+	}
+	inputs := []string{proposalFile}
+	if approvedFile != "" {
+		inputs = append(inputs, approvedFile)
+	}
+	config := NewConfig()
+	config.LoadFromEnv()
+	recordManifestStep(DefaultWorkspace, ManifestStep{
+		Step:    StepApply,
+		Country: proposal.Country,
+		DryRun:  dryRun,
+		Sources: []string{config.Get("OSM_API_URL")},
+		Counts:  uploadCounts(stats),
+	}, started, inputs, outputs)
+	if interrupted {
+		return fmt.Errorf("apply interrupted: %v", err)
+	}
+	if aborted {
+		return fmt.Errorf("apply aborted: %v", err)
+	}
+	if paused {
+		uploadLog.Info("Drip-feed: %v. Rerun the same proposal then; edits applied so far fail its version check and are left alone.", err)
+		runSummary.AddProblem(fmt.Sprintf("apply paused: %v", err))
+	}
+	return nil
+}
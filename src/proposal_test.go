@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestProposal() *Proposal {
+	return &Proposal{
+		Country:   "România",
+		CreatedAt: "2024-01-01T00:00:00Z",
+		Edits: []ProposedEdit{
+			{
+				Category: "alpine_huts",
+				Version:  3,
+				OldTags:  map[string]string{},
+				NewTags:  map[string]string{"ele": "1500.0", "ele:source": "SRTM"},
+				Element: OSMElement{
+					Type: "node",
+					ID:   42,
+					Lat:  45.5,
+					Lon:  25.5,
+					Tags: map[string]string{"tourism": "alpine_hut", "ele": "1500.0", "ele:source": "SRTM"},
+				},
+			},
+		},
+	}
+}
+
+func TestProposalSignAndVerify(t *testing.T) {
+	proposal := newTestProposal()
+	if err := proposal.Sign(); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	if err := proposal.Verify(); err != nil {
+		t.Errorf("Verify() on untouched proposal error = %v", err)
+	}
+
+	proposal.Edits[0].NewTags["ele"] = "9999.0"
+	if err := proposal.Verify(); err == nil {
+		t.Error("Verify() should fail after the proposal was modified")
+	}
+}
+
+func TestProposalSignWithKey(t *testing.T) {
+	t.Setenv("PROPOSAL_SIGNING_KEY", "secret")
+	proposal := newTestProposal()
+	if err := proposal.Sign(); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+
+	if err := proposal.Verify(); err != nil {
+		t.Errorf("Verify() with the signing key error = %v", err)
+	}
+
+	t.Setenv("PROPOSAL_SIGNING_KEY", "other")
+	if err := proposal.Verify(); err == nil {
+		t.Error("Verify() should fail with a different signing key")
+	}
+}
+
+func TestProposalVerifyRejectsForgedChecksum(t *testing.T) {
+	t.Setenv("PROPOSAL_SIGNING_KEY", "secret")
+	proposal := newTestProposal()
+	if err := proposal.Sign(); err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	signature := proposal.Signature
+
+	// Someone without the key edits the file and recomputes the checksum
+	t.Setenv("PROPOSAL_SIGNING_KEY", "")
+	proposal.Edits[0].NewTags["ele"] = "9999.0"
+	if err := proposal.Sign(); err != nil {
+		t.Fatal(err)
+	}
+	if proposal.Signed() {
+		t.Error("Sign() without a key produced a signature")
+	}
+
+	t.Setenv("PROPOSAL_SIGNING_KEY", "secret")
+	if err := proposal.Verify(); err == nil {
+		t.Error("Verify() accepted an unsigned proposal although a signing key is set")
+	}
+	proposal.Signature = signature
+	if err := proposal.Verify(); err == nil {
+		t.Error("Verify() accepted a modified proposal with the old signature")
+	}
+
+	t.Setenv("PROPOSAL_SIGNING_KEY", "")
+	if err := proposal.Verify(); err == nil {
+		t.Error("Verify() accepted a signed proposal without a key to check it")
+	}
+}
+
+func TestBuildProposedEdit(t *testing.T) {
+	element := OSMElement{
+		Type: "way",
+		ID:   7,
+		Tags: map[string]string{"tourism": "hotel", "ele": "250.0", "ele:source": "SRTM"},
+	}
+	upstream := []NodeTag{
+		{Key: "tourism", Value: "hotel"},
+		{Key: "ele:source", Value: "GPS"},
+	}
+
+	edit := buildProposedEdit("other_accommodations", element, 5, upstream)
+
+	if edit.Version != 5 {
+		t.Errorf("Version = %d, want 5", edit.Version)
+	}
+	if _, ok := edit.OldTags["ele"]; ok {
+		t.Error("OldTags should not contain ele when upstream has none")
+	}
+	if edit.OldTags["ele:source"] != "GPS" {
+		t.Errorf("OldTags[ele:source] = %q, want GPS", edit.OldTags["ele:source"])
+	}
+	if edit.NewTags["ele"] != "250.0" {
+		t.Errorf("NewTags[ele] = %q, want 250.0", edit.NewTags["ele"])
+	}
+}
+
+func TestUploaderCheckExpectedVersion(t *testing.T) {
+	uploader := &OSMUploader{expectedVersions: newTestProposal().ExpectedVersions()}
+
+	if err := uploader.checkExpectedVersion("node", 42, 3); err != nil {
+		t.Errorf("matching version should pass, got %v", err)
+	}
+	if err := uploader.checkExpectedVersion("node", 42, 4); err == nil {
+		t.Error("changed upstream version should be refused")
+	}
+	if err := uploader.checkExpectedVersion("way", 42, 3); err == nil {
+		t.Error("element outside the proposal should be refused")
+	}
+}
+
+func TestApplyUploadsProposedTagsAfterConfigChange(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	for _, mode := range []string{UploadModeElement, UploadModeDiff} {
+		t.Run(mode, func(t *testing.T) {
+			flagConfig = NewConfig()
+			var uploaded []NodeTag
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				switch {
+				case r.Method == "GET" && r.URL.Path == "/api/0.6/node/42":
+					fmt.Fprint(w, `<osm version="0.6"><node id="42" version="3" lat="45.5" lon="25.5"><tag k="tourism" v="alpine_hut"/></node></osm>`)
+				case r.Method == "PUT":
+					var doc OSMNode
+					if err := xml.Unmarshal(body, &doc); err != nil || doc.Node == nil {
+						http.Error(w, "bad XML", http.StatusBadRequest)
+						return
+					}
+					uploaded = doc.Node.Tags
+					fmt.Fprint(w, "4")
+				case r.Method == "POST":
+					var change OSMChange
+					if err := xml.Unmarshal(body, &change); err != nil || len(change.Modify) == 0 || len(change.Modify[0].Nodes) != 1 {
+						http.Error(w, "bad XML", http.StatusBadRequest)
+						return
+					}
+					uploaded = change.Modify[0].Nodes[0].Tags
+					fmt.Fprint(w, `<diffResult version="0.6"/>`)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+			flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+			fetched := 1500.26
+			var data ValidatedData
+			data.Category("alpine_huts").ValidElements = []OSMElement{{
+				Type:             "node",
+				ID:               42,
+				Tags:             map[string]string{"tourism": "alpine_hut", "ele": "1500.3", "ele:source": "SRTM"},
+				ElevationFetched: &fetched,
+			}}
+			proposal, err := BuildProposal(context.Background(), NewOSMAPIClient(server.Client(), true), data, "România")
+			if err != nil {
+				t.Fatalf("BuildProposal() error = %v", err)
+			}
+
+			// The elevation settings change between propose and apply
+			flagConfig.Set("ELE_PRECISION", "0")
+			flagConfig.Set("ELE_SOURCE_KEY", "source:ele")
+			config := NewConfig()
+			config.LoadFromEnv()
+
+			changesets := NewChangesetManager(server.Client(), false)
+			changesets.changesetID = 10
+			changesets.changesetOpen = true
+			uploader := &OSMUploader{
+				apiClient:        NewOSMAPIClient(server.Client(), false),
+				changesetManager: changesets,
+				eleFormat:        elevationFormatOrDefault(config),
+				eleSource:        elevationSourceOrDefault(config),
+			}
+			uploader.useProposal(proposal)
+
+			toApply := proposal.ToValidatedData()
+			elements := toApply.Category("alpine_huts").ValidElements
+			if mode == UploadModeElement {
+				if err := uploader.UploadElement(context.Background(), elements[0]); err != nil {
+					t.Fatalf("UploadElement() error = %v", err)
+				}
+			} else {
+				stats := uploader.UploadClusterDiff(context.Background(), []categoryElements{{key: "alpine_huts", elements: elements}})["alpine_huts"]
+				if stats.Successful != 1 {
+					t.Fatalf("stats = %+v, want the edit uploaded", stats)
+				}
+			}
+
+			got := make(map[string]string)
+			for _, tag := range uploaded {
+				got[tag.Key] = tag.Value
+			}
+			for key, value := range proposal.Edits[0].NewTags {
+				if got[key] != value {
+					t.Errorf("uploaded %s = %q, want the proposed %q", key, got[key], value)
+				}
+			}
+			if _, ok := got["source:ele"]; ok || len(got) != 3 {
+				t.Errorf("uploaded tags = %v, want only the proposed tags merged", got)
+			}
+		})
+	}
+}
+
+func TestRunApplyUsesWorkspaceProposal(t *testing.T) {
+	saved := DefaultWorkspace
+	t.Cleanup(func() { DefaultWorkspace = saved })
+	DefaultWorkspace = Workspace{Dir: t.TempDir()}
+
+	err := runApply(context.Background(), true, nil, "", "")
+	want := filepath.Join(DefaultWorkspace.Dir, "proposal.json")
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("runApply() error = %v, want it to read %s", err, want)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Bounds of the adaptive per-host request spacing
+const (
+	rateLimitMinBackoff = 1 * time.Second
+	rateLimitMaxSpacing = 60 * time.Second
+	// rateLimitRecovery shrinks the spacing after every successful response
+	rateLimitRecovery = 0.9
+	// rateLimitBandwidthBackoff is the pause after a 509 Bandwidth Limit Exceeded without
+	// Retry-After: the OSM API answers 509 once a download quota is used up, which takes minutes to recover
+	rateLimitBandwidthBackoff = 5 * time.Minute
+)
+
+// StatusBandwidthLimitExceeded is the non-standard status the OSM API uses for exhausted download quotas
+const StatusBandwidthLimitExceeded = 509
+
+// hostRateLimit is the request pacing of one API host
+type hostRateLimit struct {
+	spacing time.Duration // minimum time between two requests
+	next    time.Time     // earliest start of the next request
+}
+
+// AdaptiveRateLimiter paces requests per host. It starts without delays, slows down when a
+// server answers 429/503/509 or reports an exhausted quota, honoring Retry-After and
+// X-RateLimit-Reset, and speeds up again while requests succeed.
+type AdaptiveRateLimiter struct {
+	mu    sync.Mutex
+	hosts map[string]*hostRateLimit
+	now   func() time.Time
+}
+
+// NewAdaptiveRateLimiter creates a limiter with no delays
+func NewAdaptiveRateLimiter() *AdaptiveRateLimiter {
+	return &AdaptiveRateLimiter{
+		hosts: make(map[string]*hostRateLimit),
+		now:   time.Now,
+	}
+}
+
+// host returns the pacing state of a host; the caller holds mu
+func (l *AdaptiveRateLimiter) host(name string) *hostRateLimit {
+	h, ok := l.hosts[name]
+	if !ok {
+		h = &hostRateLimit{}
+		l.hosts[name] = h
+	}
+	return h
+}
+
+// Delay returns how long a request to host would have to wait now
+func (l *AdaptiveRateLimiter) Delay(host string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if wait := l.host(host).next.Sub(l.now()); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// Wait reserves the next request slot for host and sleeps until it starts
+func (l *AdaptiveRateLimiter) Wait(ctx context.Context, host string) error {
+	l.mu.Lock()
+	h := l.host(host)
+	now := l.now()
+	slot := h.next
+	if slot.Before(now) {
+		slot = now
+	}
+	h.next = slot.Add(h.spacing)
+	l.mu.Unlock()
+
+	return sleepContext(ctx, slot.Sub(now))
+}
+
+// Observe adapts the pacing of host to a response and returns the delay the server asked
+// for (0 if none)
+func (l *AdaptiveRateLimiter) Observe(host string, resp *http.Response) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	h := l.host(host)
+	now := l.now()
+
+	throttled := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+	delay := retryAfterDelay(resp.Header, now)
+	if delay == 0 && resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		delay = rateLimitResetDelay(resp.Header, now)
+		throttled = throttled || delay > 0
+	}
+
+	switch {
+	case resp.StatusCode == StatusBandwidthLimitExceeded:
+		h.spacing = rateLimitMaxSpacing
+		if delay == 0 {
+			delay = rateLimitBandwidthBackoff
+		}
+	case throttled:
+		h.spacing *= 2
+		if h.spacing < rateLimitMinBackoff {
+			h.spacing = rateLimitMinBackoff
+		}
+		if h.spacing > rateLimitMaxSpacing {
+			h.spacing = rateLimitMaxSpacing
+		}
+	case resp.StatusCode < 400:
+		h.spacing = time.Duration(float64(h.spacing) * rateLimitRecovery)
+		if h.spacing < 10*time.Millisecond {
+			h.spacing = 0
+		}
+	}
+
+	if delay > 0 && now.Add(delay).After(h.next) {
+		h.next = now.Add(delay)
+	}
+	return delay
+}
+
+// retryAfterDelay parses a Retry-After header given in seconds or as an HTTP date
+func retryAfterDelay(header http.Header, now time.Time) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// rateLimitResetDelay parses X-RateLimit-Reset, given either as a Unix timestamp or as
+// seconds until the quota resets
+func rateLimitResetDelay(header http.Header, now time.Time) time.Duration {
+	value, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || value <= 0 {
+		return 0
+	}
+	if value > 1000000000 {
+		if at := time.Unix(value, 0); at.After(now) {
+			return at.Sub(now)
+		}
+		return 0
+	}
+	return time.Duration(value) * time.Second
+}
+
+var (
+	sharedRateLimiterOnce     sync.Once
+	sharedRateLimiterInstance *AdaptiveRateLimiter
+)
+
+// sharedRateLimiter returns the process-wide limiter, so all clients of a host slow down together
+func sharedRateLimiter() *AdaptiveRateLimiter {
+	sharedRateLimiterOnce.Do(func() {
+		sharedRateLimiterInstance = NewAdaptiveRateLimiter()
+	})
+	return sharedRateLimiterInstance
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryAfterDelay(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"absent", "", 0},
+		{"seconds", "30", 30 * time.Second},
+		{"http date", now.Add(2 * time.Minute).Format(http.TimeFormat), 2 * time.Minute},
+		{"date in the past", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"garbage", "soon", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			if tt.value != "" {
+				header.Set("Retry-After", tt.value)
+			}
+			if got := retryAfterDelay(header, now); got != tt.want {
+				t.Errorf("retryAfterDelay(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitResetDelay(t *testing.T) {
+	now := time.Unix(1750000000, 0)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"seconds until reset", "45", 45 * time.Second},
+		{"unix timestamp", "1750000090", 90 * time.Second},
+		{"timestamp passed", "1749999990", 0},
+		{"missing", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{"X-Ratelimit-Reset": {tt.value}}
+			if got := rateLimitResetDelay(header, now); got != tt.want {
+				t.Errorf("rateLimitResetDelay(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdaptiveRateLimiterObserve(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	limiter := NewAdaptiveRateLimiter()
+	limiter.now = func() time.Time { return now }
+
+	response := func(status int, header map[string]string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		for key, value := range header {
+			resp.Header.Set(key, value)
+		}
+		return resp
+	}
+
+	if got := limiter.Observe("api.example", response(429, map[string]string{"Retry-After": "20"})); got != 20*time.Second {
+		t.Errorf("Observe() = %v, want 20s", got)
+	}
+	if got := limiter.Delay("api.example"); got != 20*time.Second {
+		t.Errorf("Delay() after Retry-After = %v, want 20s", got)
+	}
+	if got := limiter.Delay("other.example"); got != 0 {
+		t.Errorf("other host Delay() = %v, want 0", got)
+	}
+	if spacing := limiter.hosts["api.example"].spacing; spacing != rateLimitMinBackoff {
+		t.Errorf("spacing after 429 = %v, want %v", spacing, rateLimitMinBackoff)
+	}
+
+	limiter.Observe("api.example", response(503, nil))
+	if spacing := limiter.hosts["api.example"].spacing; spacing != 2*rateLimitMinBackoff {
+		t.Errorf("spacing after second throttle = %v, want %v", spacing, 2*rateLimitMinBackoff)
+	}
+
+	limiter.Observe("api.example", response(200, nil))
+	if spacing := limiter.hosts["api.example"].spacing; spacing >= 2*rateLimitMinBackoff {
+		t.Errorf("spacing after success = %v, want it to shrink", spacing)
+	}
+
+	now = now.Add(time.Minute)
+	limiter.Observe("quota.example", response(200, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "10"}))
+	if got := limiter.Delay("quota.example"); got != 10*time.Second {
+		t.Errorf("Delay() after exhausted quota = %v, want 10s", got)
+	}
+
+	if got := limiter.Observe("osm.example", response(509, nil)); got != rateLimitBandwidthBackoff {
+		t.Errorf("Observe() after 509 = %v, want %v", got, rateLimitBandwidthBackoff)
+	}
+	if spacing := limiter.hosts["osm.example"].spacing; spacing != rateLimitMaxSpacing {
+		t.Errorf("spacing after 509 = %v, want %v", spacing, rateLimitMaxSpacing)
+	}
+	if got := limiter.Observe("osm.example", response(509, map[string]string{"Retry-After": "90"})); got != 90*time.Second {
+		t.Errorf("Observe() after 509 with Retry-After = %v, want 90s", got)
+	}
+}
+
+func TestHTTPClientWrapperHonorsRetryAfter(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	wrapper := NewHTTPClientWrapper(server.Client(), RetryConfig{MaxRetries: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond, Multiplier: 1}, NewLoggerWithOutput("", io.Discard))
+	wrapper.limiter = NewAdaptiveRateLimiter()
+
+	start := time.Now()
+	resp, err := wrapper.Get(server.URL)
+	if err != nil {
+		t.Fatalf("Get() error = %v", err)
+	}
+	resp.Body.Close()
+
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the 1s Retry-After", elapsed)
+	}
+}
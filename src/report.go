@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultReportFile is the shareable HTML report of a run
+const DefaultReportFile = "output/report.html"
+
+// reportBinWidth is the width in meters of the elevation histogram bins
+const reportBinWidth = 250.0
+
+// reportCategory is one row of the per-category statistics
+type reportCategory struct {
+	Category string
+	Valid    int
+	Invalid  int
+	Uploaded int
+	Failed   int
+	Skipped  int
+}
+
+// reportBin is one bar of the elevation histogram
+type reportBin struct {
+	From  float64
+	To    float64
+	Count int
+	// Width is the bar length relative to the largest bin, in percent
+	Width float64
+}
+
+// reportCount is one row of a failure breakdown
+type reportCount struct {
+	Reason string
+	Count  int
+}
+
+// ReportData is the content of the HTML report
+type ReportData struct {
+	Country            string
+	GeneratedAt        string
+	Categories         []reportCategory
+	Totals             reportCategory
+	Uploaded           bool
+	Histogram          []reportBin
+	ValidationFailures []reportCount
+	UploadFailures     []reportCount
+	Changesets         []ChangesetRecord
+}
+
+// elevationHistogram counts elevations per bin of width meters, including the empty bins
+// between the lowest and highest one
+func elevationHistogram(elevations []float64, width float64) []reportBin {
+	if len(elevations) == 0 {
+		return nil
+	}
+	counts := make(map[int]int)
+	low, high := math.MaxInt, math.MinInt
+	for _, ele := range elevations {
+		bin := int(math.Floor(ele / width))
+		counts[bin]++
+		low = min(low, bin)
+		high = max(high, bin)
+	}
+
+	largest := 0
+	for _, count := range counts {
+		largest = max(largest, count)
+	}
+	bins := make([]reportBin, 0, high-low+1)
+	for bin := low; bin <= high; bin++ {
+		bins = append(bins, reportBin{
+			From:  float64(bin) * width,
+			To:    float64(bin+1) * width,
+			Count: counts[bin],
+			Width: 100 * float64(counts[bin]) / float64(largest),
+		})
+	}
+	return bins
+}
+
+// validationFailureKind groups a validation error by its check, dropping the values that
+// differ per element
+func validationFailureKind(reason string) string {
+	switch {
+	case strings.Contains(reason, "below minimum"):
+		return "Elevation below minimum"
+	case strings.HasPrefix(reason, "Elevation") && strings.Contains(reason, "above maximum"):
+		return "Elevation above maximum"
+	case strings.HasPrefix(reason, "Terrain slope"):
+		return "Terrain slope above maximum"
+	default:
+		return reason
+	}
+}
+
+// validationFailureBreakdown counts the invalid elements per kind of validation error. The
+// store keeps the errors of an element joined with "; ", so an element may count under
+// several kinds.
+func validationFailureBreakdown(errorCounts map[string]int) []reportCount {
+	kinds := make(map[string]int)
+	for reasons, count := range errorCounts {
+		for _, reason := range strings.Split(reasons, "; ") {
+			if reason = strings.TrimSpace(reason); reason != "" {
+				kinds[validationFailureKind(reason)] += count
+			}
+		}
+	}
+	return sortedCounts(kinds)
+}
+
+// sortedCounts orders counts from the most frequent reason down
+func sortedCounts(counts map[string]int) []reportCount {
+	rows := make([]reportCount, 0, len(counts))
+	for reason, count := range counts {
+		rows = append(rows, reportCount{Reason: reason, Count: count})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].Reason < rows[j].Reason
+	})
+	return rows
+}
+
+// BuildReport gathers the report of a workspace from its validated data, upload results,
+// pipeline store and changesets file. Only the validated data is required; the sections of
+// steps that did not run are left empty.
+func BuildReport(ws Workspace) (ReportData, error) {
+	report := ReportData{GeneratedAt: time.Now().UTC().Format(time.RFC3339)}
+
+	var data ValidatedData
+	validatedFile := ws.File(DefaultValidatedDataFile)
+	if err := loadPipelineFile(validatedFile, SchemaValidated, &data); err != nil {
+		return report, pipelineLoadError(validatedFile, "validate", err)
+	}
+
+	var results UploadResults
+	if err := loadJSON(ws.File(DefaultUploadResultsFile), &results); err == nil {
+		report.Uploaded = true
+		report.Country = results.Country
+		report.UploadFailures = sortedCounts(results.FailuresByClass)
+	}
+	if manifest, err := LoadRunManifest(ws.File(DefaultManifestFile)); err == nil {
+		if step, ok := manifest.Step(StepValidate); ok && step.Country != "" {
+			report.Country = step.Country
+		}
+	}
+
+	var elevations []float64
+	for _, key := range categoryKeys {
+		category := data.Category(key)
+		stats := results.Stats[key]
+		row := reportCategory{
+			Category: key,
+			Valid:    category.ValidCount,
+			Invalid:  category.InvalidCount,
+			Uploaded: stats.Successful,
+			Failed:   stats.Failed,
+			Skipped:  stats.Skipped,
+		}
+		report.Categories = append(report.Categories, row)
+		report.Totals.Valid += row.Valid
+		report.Totals.Invalid += row.Invalid
+		report.Totals.Uploaded += row.Uploaded
+		report.Totals.Failed += row.Failed
+		report.Totals.Skipped += row.Skipped
+
+		for _, element := range category.ValidElements {
+			if element.ElevationFetched != nil {
+				elevations = append(elevations, *element.ElevationFetched)
+			}
+		}
+	}
+	report.Totals.Category = "total"
+	report.Histogram = elevationHistogram(elevations, reportBinWidth)
+
+	// Opening the store creates it, so a workspace without one is left alone
+	storeFile := ws.File(DefaultPipelineStoreFile)
+	if _, err := os.Stat(storeFile); err == nil {
+		store, err := OpenPipelineStore(storeFile)
+		if err != nil {
+			return report, err
+		}
+		errorCounts, err := store.ErrorCounts(StateInvalid)
+		store.Close()
+		if err != nil {
+			return report, err
+		}
+		report.ValidationFailures = validationFailureBreakdown(errorCounts)
+	}
+
+	changesetLog, err := LoadChangesetLog(ws.File(DefaultChangesetsFile))
+	if err != nil {
+		return report, err
+	}
+	report.Changesets = changesetLog.Changesets
+	return report, nil
+}
+
+// WriteReportHTML renders the report as a self-contained page with no external resources
+func WriteReportHTML(w io.Writer, report ReportData) error {
+	return reportTemplate.Execute(w, report)
+}
+
+// runReport writes the HTML report of a workspace
+func runReport(ws Workspace) error {
+	exportLog.Banner("REPORT - Writing HTML report of the run")
+
+	report, err := BuildReport(ws)
+	if err != nil {
+		return err
+	}
+
+	outputFile := ws.File(DefaultReportFile)
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", outputFile, err)
+	}
+	defer file.Close()
+
+	if err := WriteReportHTML(file, report); err != nil {
+		return fmt.Errorf("failed to write report: %v", err)
+	}
+
+	exportLog.Info("✓ Report of %d valid elements and %d changesets saved to %s", report.Totals.Valid, len(report.Changesets), outputFile)
+	return nil
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"meters": func(value float64) string { return fmt.Sprintf("%.0f", value) },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>elevate-romania report{{if .Country}}: {{.Country}}{{end}}</title>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; padding: 0 1em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: right; }
+th:first-child, td:first-child { text-align: left; }
+tr.total td { font-weight: bold; }
+.bar { display: inline-block; height: 12px; background: #3a7bd5; vertical-align: middle; }
+.empty { color: #888; }
+</style>
+</head>
+<body>
+<h1>Elevation data report{{if .Country}}: {{.Country}}{{end}}</h1>
+<p>Generated {{.GeneratedAt}} by elevate-romania. Elevations are fetched from digital elevation models and added as <code>ele</code> tags to OpenStreetMap elements that lack one.</p>
+
+<h2>Per-category statistics</h2>
+<table>
+<tr><th>Category</th><th>Valid</th><th>Invalid</th>{{if .Uploaded}}<th>Uploaded</th><th>Failed</th><th>Skipped</th>{{end}}</tr>
+{{range .Categories}}<tr><td>{{.Category}}</td><td>{{.Valid}}</td><td>{{.Invalid}}</td>{{if $.Uploaded}}<td>{{.Uploaded}}</td><td>{{.Failed}}</td><td>{{.Skipped}}</td>{{end}}</tr>
+{{end}}{{with .Totals}}<tr class="total"><td>Total</td><td>{{.Valid}}</td><td>{{.Invalid}}</td>{{if $.Uploaded}}<td>{{.Uploaded}}</td><td>{{.Failed}}</td><td>{{.Skipped}}</td>{{end}}</tr>{{end}}
+</table>
+
+<h2>Elevation distribution</h2>
+{{if .Histogram}}<table>
+<tr><th>Elevation (m)</th><th>Elements</th><th></th></tr>
+{{range .Histogram}}<tr><td>{{meters .From}} – {{meters .To}}</td><td>{{.Count}}</td><td style="text-align: left; width: 400px"><span class="bar" style="width: {{printf "%.1f" .Width}}%"></span></td></tr>
+{{end}}</table>{{else}}<p class="empty">No valid elements with an elevation.</p>{{end}}
+
+<h2>Validation failures</h2>
+{{if .ValidationFailures}}<table>
+<tr><th>Reason</th><th>Elements</th></tr>
+{{range .ValidationFailures}}<tr><td>{{.Reason}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No validation failures recorded.</p>{{end}}
+
+{{if .UploadFailures}}<h2>Upload failures</h2>
+<table>
+<tr><th>Error class</th><th>Elements</th></tr>
+{{range .UploadFailures}}<tr><td>{{.Reason}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>{{end}}
+
+<h2>Changesets</h2>
+{{if .Changesets}}<table>
+<tr><th>Changeset</th><th>Country</th><th>Elements</th><th>Created</th><th>Comment</th></tr>
+{{range .Changesets}}<tr><td><a href="{{.URL}}">#{{.ID}}</a></td><td>{{.Country}}</td><td>{{.Elements}}</td><td>{{.CreatedAt}}</td><td style="text-align: left">{{.Comment}}</td></tr>
+{{end}}</table>{{else}}<p class="empty">No changesets created yet.</p>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestElevationHistogram(t *testing.T) {
+	bins := elevationHistogram([]float64{10, 240, 260, 900}, 250)
+	var got []int
+	for _, bin := range bins {
+		got = append(got, bin.Count)
+	}
+	if want := []int{2, 1, 0, 1}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("bin counts = %v, want %v", got, want)
+	}
+	if bins[0].From != 0 || bins[3].To != 1000 || bins[0].Width != 100 || bins[1].Width != 50 {
+		t.Errorf("unexpected bins %+v", bins)
+	}
+	if bins := elevationHistogram(nil, 250); bins != nil {
+		t.Errorf("elevationHistogram(nil) = %v, want nil", bins)
+	}
+}
+
+func TestValidationFailureBreakdown(t *testing.T) {
+	got := validationFailureBreakdown(map[string]int{
+		"No elevation data":                                        4,
+		"Elevation 2600.0m above maximum 2544.0m":                  2,
+		"Elevation 2700.0m above maximum 2544.0m":                  1,
+		"Elevation -5.0m below minimum 0.0m":                       1,
+		"Terrain slope 40.0° above maximum 35.0° (low confidence)": 1,
+	})
+	want := []reportCount{
+		{Reason: "No elevation data", Count: 4},
+		{Reason: "Elevation above maximum", Count: 3},
+		{Reason: "Elevation below minimum", Count: 1},
+		{Reason: "Terrain slope above maximum", Count: 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("validationFailureBreakdown() = %v, want %v", got, want)
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	ws := Workspace{Dir: t.TempDir()}
+	if _, err := BuildReport(ws); err == nil {
+		t.Fatal("BuildReport() without validated data succeeded, want an error")
+	}
+
+	elevation := 1800.0
+	data := ValidatedData{
+		Peaks: ValidatedCategory{ValidCount: 1, InvalidCount: 1, ValidElements: []OSMElement{
+			{Type: "node", ID: 1, ElevationFetched: &elevation},
+		}},
+	}
+	if err := savePipelineFile(ws.File(DefaultValidatedDataFile), SchemaValidated, &data); err != nil {
+		t.Fatal(err)
+	}
+	stats := map[string]UploadStats{"peaks": {Total: 1, Successful: 1}}
+	if err := SaveUploadResults(ws.File(DefaultUploadResultsFile), "Romania", stats); err != nil {
+		t.Fatal(err)
+	}
+	store, err := OpenPipelineStore(ws.File(DefaultPipelineStoreFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	invalid := OSMElement{Type: "node", ID: 2}
+	reasons := map[string]string{elementKey("node", 2): "No elevation data"}
+	if err := store.RecordErrors(StateInvalid, map[string][]OSMElement{"peaks": {invalid}}, reasons); err != nil {
+		t.Fatal(err)
+	}
+	store.Close()
+	changesetLog, err := LoadChangesetLog(ws.File(DefaultChangesetsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := changesetLog.Add(ChangesetRecord{ID: 42, Country: "Romania", Elements: 1, Comment: "Add <ele>", URL: "https://www.openstreetmap.org/changeset/42"}); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := BuildReport(ws)
+	if err != nil {
+		t.Fatalf("BuildReport() error = %v", err)
+	}
+	if report.Country != "Romania" || !report.Uploaded || report.Totals.Valid != 1 || report.Totals.Uploaded != 1 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if want := []reportCount{{Reason: "No elevation data", Count: 1}}; !reflect.DeepEqual(report.ValidationFailures, want) {
+		t.Errorf("ValidationFailures = %v, want %v", report.ValidationFailures, want)
+	}
+	if len(report.Histogram) != 1 || report.Histogram[0].From != 1750 {
+		t.Errorf("Histogram = %+v, want one bin from 1750 m", report.Histogram)
+	}
+
+	var out bytes.Buffer
+	if err := WriteReportHTML(&out, report); err != nil {
+		t.Fatalf("WriteReportHTML() error = %v", err)
+	}
+	page := out.String()
+	for _, want := range []string{
+		"report: Romania",
+		"1750 – 2000",
+		"No elevation data",
+		`<a href="https://www.openstreetmap.org/changeset/42">#42</a>`,
+		"Add &lt;ele&gt;",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("report missing %q", want)
+		}
+	}
+}
+
+func TestLegacyReportFlagAlone(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+	previousWorkspace, previousFlags, previousArgs := DefaultWorkspace, flag.CommandLine, os.Args
+	t.Cleanup(func() { DefaultWorkspace, flag.CommandLine, os.Args = previousWorkspace, previousFlags, previousArgs })
+
+	ws := Workspace{Dir: t.TempDir()}
+	elevation := 1800.0
+	data := ValidatedData{
+		Peaks: ValidatedCategory{ValidCount: 1, ValidElements: []OSMElement{
+			{Type: "node", ID: 1, ElevationFetched: &elevation},
+		}},
+	}
+	if err := savePipelineFile(ws.File(DefaultValidatedDataFile), SchemaValidated, &data); err != nil {
+		t.Fatal(err)
+	}
+
+	flag.CommandLine = flag.NewFlagSet("elevate-romania", flag.ContinueOnError)
+	os.Args = []string{"elevate-romania", "--report", "--workspace", ws.Dir}
+	runLegacy(context.Background())
+
+	if _, err := os.Stat(ws.File(DefaultReportFile)); err != nil {
+		t.Errorf("--report alone did not write the report: %v", err)
+	}
+}
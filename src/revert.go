@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// revertedTags are the tags an upload sets and a revert restores
+var revertedTags = []string{"ele", "ele:source", "source:ele"}
+
+// osmElements is an OSM API document holding nodes, ways and relations, e.g. a historic element version
+type osmElements struct {
+	XMLName   xml.Name       `xml:"osm"`
+	Nodes     []NodeData     `xml:"node"`
+	Ways      []WayData      `xml:"way"`
+	Relations []RelationData `xml:"relation"`
+}
+
+// osmChangesetInfo is the metadata document of a changeset
+type osmChangesetInfo struct {
+	XMLName   xml.Name `xml:"osm"`
+	Changeset struct {
+		ID   int            `xml:"id,attr"`
+		User string         `xml:"user,attr"`
+		Open bool           `xml:"open,attr"`
+		Tags []ChangesetTag `xml:"tag"`
+	} `xml:"changeset"`
+}
+
+// Tag returns the value of a changeset tag, or "" when absent
+func (info *osmChangesetInfo) Tag(key string) string {
+	for _, tag := range info.Changeset.Tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// RevertEdit is the tag restoration planned for one element of a reverted changeset
+type RevertEdit struct {
+	ElementType string
+	ElementID   int64
+	// Restore maps a tag to its value before the reverted edit; "" removes the tag
+	Restore map[string]string
+	// Conflicts lists tags changed again after the reverted edit, which are left untouched
+	Conflicts []string
+}
+
+// FetchChangesetInfo fetches the metadata and tags of a changeset
+func (api *OSMAPIClient) FetchChangesetInfo(ctx context.Context, changesetID int) (*osmChangesetInfo, error) {
+	raw, err := api.FetchElementXML(ctx, "changeset", int64(changesetID))
+	if err != nil {
+		return nil, err
+	}
+
+	var info osmChangesetInfo
+	if err := xml.Unmarshal(raw, &info); err != nil {
+		return nil, fmt.Errorf("failed to decode changeset XML: %v", err)
+	}
+	return &info, nil
+}
+
+// FetchChangesetDownload fetches the osmChange document of the edits made in a changeset
+func (api *OSMAPIClient) FetchChangesetDownload(ctx context.Context, changesetID int) (*OSMChange, error) {
+	body, err := api.fetchXML(ctx, fmt.Sprintf("%s/changeset/%d/download", api.baseURL, changesetID), "changeset download")
+	if err != nil {
+		return nil, err
+	}
+
+	var change OSMChange
+	if err := xml.Unmarshal(body, &change); err != nil {
+		return nil, fmt.Errorf("failed to decode changeset download: %v", err)
+	}
+	return &change, nil
+}
+
+// FetchElementVersionTags fetches the tags of a specific historic version of an element
+func (api *OSMAPIClient) FetchElementVersionTags(ctx context.Context, elementType string, elementID int64, version int) ([]NodeTag, error) {
+	body, err := api.fetchXML(ctx, fmt.Sprintf("%s/%s/%d/%d", api.baseURL, elementType, elementID, version), elementType+" version")
+	if err != nil {
+		return nil, err
+	}
+	return elementTags(elementType, body)
+}
+
+// elementTags decodes the tags of the element in an OSM document
+func elementTags(elementType string, body []byte) ([]NodeTag, error) {
+	var doc osmElements
+	if err := xml.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode %s XML: %v", elementType, err)
+	}
+	switch {
+	case elementType == "node" && len(doc.Nodes) > 0:
+		return doc.Nodes[0].Tags, nil
+	case elementType == "way" && len(doc.Ways) > 0:
+		return doc.Ways[0].Tags, nil
+	case elementType == "relation" && len(doc.Relations) > 0:
+		return doc.Relations[0].Tags, nil
+	}
+	return nil, fmt.Errorf("no %s data in response", elementType)
+}
+
+// undoSnapshots indexes the undo log entries of a changeset by element key
+func undoSnapshots(entries []UndoEntry) map[string]UndoEntry {
+	snapshots := make(map[string]UndoEntry, len(entries))
+	for _, entry := range entries {
+		snapshots[elementKey(entry.ElementType, entry.ElementID)] = entry
+	}
+	return snapshots
+}
+
+// previousTags returns the tags of an element before the reverted edit: from the pre-edit
+// snapshot the upload recorded in the undo log, or from the version history when the log has
+// no snapshot of that version
+func previousTags(ctx context.Context, api *OSMAPIClient, snapshots map[string]UndoEntry, elementType string, elementID int64, editedVersion int) ([]NodeTag, error) {
+	if entry, ok := snapshots[elementKey(elementType, elementID)]; ok && entry.Version == editedVersion-1 {
+		tags, err := elementTags(elementType, []byte(entry.XML))
+		if err == nil {
+			return tags, nil
+		}
+		pipelineLog.Warn("Undo log snapshot of %s %d is unreadable, using its version history: %v", elementType, elementID, err)
+	}
+	return api.FetchElementVersionTags(ctx, elementType, elementID, editedVersion-1)
+}
+
+// tagValue returns the value of key in tags, or "" when absent
+func tagValue(tags []NodeTag, key string) string {
+	for _, tag := range tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// planRevertTags computes which of revertedTags to restore. previous and edited are the tags
+// before and after the reverted edit, current the tags of the latest version. When any of
+// them was changed again since, nothing is restored and the changed tags are returned.
+func planRevertTags(previous, edited, current []NodeTag) (map[string]string, []string) {
+	restore := make(map[string]string)
+	var conflicts []string
+
+	for _, key := range revertedTags {
+		editedValue := tagValue(edited, key)
+		previousValue := tagValue(previous, key)
+		if editedValue == previousValue {
+			// Not changed by the reverted edit
+			continue
+		}
+		if tagValue(current, key) != editedValue {
+			conflicts = append(conflicts, key)
+			continue
+		}
+		restore[key] = previousValue
+	}
+
+	// Restoring only some of the tags would mix values from different sources
+	if len(conflicts) > 0 {
+		return map[string]string{}, conflicts
+	}
+	return restore, nil
+}
+
+// RestoreTags sets the tags in restore on tags, removing those restored to ""
+func RestoreTags(tags []NodeTag, restore map[string]string) []NodeTag {
+	result := make([]NodeTag, 0, len(tags))
+	for _, tag := range tags {
+		if _, ok := restore[tag.Key]; !ok {
+			result = append(result, tag)
+		}
+	}
+
+	keys := make([]string, 0, len(restore))
+	for key := range restore {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if restore[key] != "" {
+			result = append(result, NodeTag{Key: key, Value: restore[key]})
+		}
+	}
+	return result
+}
+
+// planRevert looks up the previous tags of every element modified in a changeset, in snapshots
+// or the element history, and stages the restoring modifications into change. Elements created
+// in the changeset are not touched.
+func planRevert(ctx context.Context, api *OSMAPIClient, download *OSMChange, change *OSMChange, snapshots map[string]UndoEntry) ([]RevertEdit, error) {
+	var edits []RevertEdit
+
+	for _, block := range download.Modify {
+		for _, edited := range block.Nodes {
+			if edited.Version < 2 {
+				continue
+			}
+			previous, err := previousTags(ctx, api, snapshots, "node", edited.ID, edited.Version)
+			if err != nil {
+				return nil, fmt.Errorf("node %d: %v", edited.ID, err)
+			}
+			current, err := api.FetchNode(ctx, edited.ID)
+			if err != nil {
+				return nil, fmt.Errorf("node %d: %v", edited.ID, err)
+			}
+
+			edit := RevertEdit{ElementType: "node", ElementID: edited.ID}
+			edit.Restore, edit.Conflicts = planRevertTags(previous, edited.Tags, current.Tags)
+			if len(edit.Restore) > 0 {
+				current.Tags = RestoreTags(current.Tags, edit.Restore)
+				change.ModifyNode(*current)
+			}
+			edits = append(edits, edit)
+		}
+
+		for _, edited := range block.Ways {
+			if edited.Version < 2 {
+				continue
+			}
+			previous, err := previousTags(ctx, api, snapshots, "way", edited.ID, edited.Version)
+			if err != nil {
+				return nil, fmt.Errorf("way %d: %v", edited.ID, err)
+			}
+			current, snapshot, err := api.FetchWaySnapshot(ctx, edited.ID)
+			if err != nil {
+				return nil, fmt.Errorf("way %d: %v", edited.ID, err)
+			}
+
+			edit := RevertEdit{ElementType: "way", ElementID: edited.ID}
+			edit.Restore, edit.Conflicts = planRevertTags(previous, edited.Tags, current.Tags)
+			if len(edit.Restore) > 0 {
+				if err := checkChildCount("way", edited.ID, snapshot, "nd", len(current.Nodes)); err != nil {
+					return nil, err
+				}
+				current.Tags = RestoreTags(current.Tags, edit.Restore)
+				change.ModifyWay(*current)
+			}
+			edits = append(edits, edit)
+		}
+
+		for _, edited := range block.Relations {
+			if edited.Version < 2 {
+				continue
+			}
+			previous, err := previousTags(ctx, api, snapshots, "relation", edited.ID, edited.Version)
+			if err != nil {
+				return nil, fmt.Errorf("relation %d: %v", edited.ID, err)
+			}
+			current, snapshot, err := api.FetchRelationSnapshot(ctx, edited.ID)
+			if err != nil {
+				return nil, fmt.Errorf("relation %d: %v", edited.ID, err)
+			}
+
+			edit := RevertEdit{ElementType: "relation", ElementID: edited.ID}
+			edit.Restore, edit.Conflicts = planRevertTags(previous, edited.Tags, current.Tags)
+			if len(edit.Restore) > 0 {
+				if err := checkChildCount("relation", edited.ID, snapshot, "member", len(current.Members)); err != nil {
+					return nil, err
+				}
+				current.Tags = RestoreTags(current.Tags, edit.Restore)
+				change.ModifyRelation(*current)
+			}
+			edits = append(edits, edit)
+		}
+	}
+
+	return edits, nil
+}
+
+// describeRestore formats the planned tag changes of an element
+func describeRestore(restore map[string]string) string {
+	keys := make([]string, 0, len(restore))
+	for key := range restore {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		if restore[key] == "" {
+			parts[i] = "-" + key
+		} else {
+			parts[i] = key + "=" + restore[key]
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// createdByThisTool reports whether a created_by tag names this tool: elevate-romania or the
+// configured CHANGESET_CREATED_BY, in any version
+func createdByThisTool(createdBy string) bool {
+	config := NewConfig()
+	config.LoadFromEnv()
+	for _, name := range []string{"elevate-romania", config.Get("CHANGESET_CREATED_BY")} {
+		name, _, _ = strings.Cut(name, "/")
+		if name != "" && strings.HasPrefix(createdBy, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// runRevert undoes the ele/ele:source edits of a changeset made by this tool
+func runRevert(ctx context.Context, dryRun bool, oauthConfig *OAuthConfig, changesetID int, force bool) error {
+	if dryRun {
+		uploadLog.Banner("REVERT (DRY-RUN) - Changeset #%d", changesetID)
+	} else {
+		uploadLog.Banner("REVERT - Changeset #%d", changesetID)
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	if !dryRun {
+		_, oauthClient, err := CreateOAuthClient(oauthConfig)
+		if err != nil {
+			return fmt.Errorf("failed to create OAuth client: %v", err)
+		}
+		client = oauthClient
+	}
+	api := NewOSMAPIClient(client, dryRun)
+
+	info, err := api.FetchChangesetInfo(ctx, changesetID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch changeset #%d: %v", changesetID, err)
+	}
+	if createdBy := info.Tag("created_by"); !createdByThisTool(createdBy) && !force {
+		return fmt.Errorf("changeset #%d was created by %q, not elevate-romania or CHANGESET_CREATED_BY (use --force to revert it anyway)", changesetID, createdBy)
+	}
+	if info.Changeset.Open {
+		return fmt.Errorf("changeset #%d is still open, wait until it is closed", changesetID)
+	}
+
+	download, err := api.FetchChangesetDownload(ctx, changesetID)
+	if err != nil {
+		return fmt.Errorf("failed to download changeset #%d: %v", changesetID, err)
+	}
+
+	undoLogFile := DefaultWorkspace.File(DefaultUndoLogFile)
+	entries, err := LoadUndoEntries(undoLogFile, changesetID)
+	if err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		uploadLog.Info("Restoring from %d pre-edit snapshots in %s", len(entries), undoLogFile)
+	} else {
+		uploadLog.Info("No snapshots of changeset #%d in %s, using the element history", changesetID, undoLogFile)
+	}
+
+	change := NewOSMChange()
+	edits, err := planRevert(ctx, api, download, change, undoSnapshots(entries))
+	if err != nil {
+		return err
+	}
+
+	conflicts := 0
+	for _, edit := range edits {
+		if len(edit.Restore) > 0 {
+			uploadLog.Info("  %s %d: %s", edit.ElementType, edit.ElementID, describeRestore(edit.Restore))
+		}
+		if len(edit.Conflicts) > 0 {
+			conflicts++
+			uploadLog.Warn("  %s %d: skipping, %s changed again since #%d", edit.ElementType, edit.ElementID, strings.Join(edit.Conflicts, ", "), changesetID)
+		}
+	}
+	uploadLog.Info("%d of %d modified elements to revert, %d with conflicting later edits", change.Len(), len(edits), conflicts)
+
+	if change.Len() == 0 {
+		uploadLog.Info("Nothing to revert")
+		return nil
+	}
+	if dryRun {
+		uploadLog.Info("[DRY-RUN] No revert uploaded")
+		return nil
+	}
+
+	changesets := NewChangesetManager(client, false)
+	comment := fmt.Sprintf("Revert elevation edits of changeset #%d", changesetID)
+	if err := changesets.Create(ctx, comment); err != nil {
+		return fmt.Errorf("failed to create changeset: %v", err)
+	}
+	defer changesets.Close()
+
+	revertID := changesets.GetID()
+	for i := range change.Modify[0].Nodes {
+		change.Modify[0].Nodes[i].Changeset = revertID
+	}
+	for i := range change.Modify[0].Ways {
+		change.Modify[0].Ways[i].Changeset = revertID
+	}
+	for i := range change.Modify[0].Relations {
+		change.Modify[0].Relations[i].Changeset = revertID
+	}
+
+	if _, err := api.UploadChange(ctx, revertID, change); err != nil {
+		return fmt.Errorf("failed to upload revert: %v", err)
+	}
+
+	uploadLog.Info("✓ Reverted %d elements of changeset #%d in changeset #%d", change.Len(), changesetID, revertID)
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanRevertTags(t *testing.T) {
+	tags := func(pairs ...string) []NodeTag {
+		var result []NodeTag
+		for i := 0; i < len(pairs); i += 2 {
+			result = append(result, NodeTag{Key: pairs[i], Value: pairs[i+1]})
+		}
+		return result
+	}
+
+	tests := []struct {
+		name          string
+		previous      []NodeTag
+		edited        []NodeTag
+		current       []NodeTag
+		wantRestore   map[string]string
+		wantConflicts []string
+	}{
+		{
+			name:        "added tags are removed",
+			previous:    tags("natural", "peak"),
+			edited:      tags("natural", "peak", "ele", "2010", "ele:source", "SRTM"),
+			current:     tags("natural", "peak", "ele", "2010", "ele:source", "SRTM", "name", "Vf"),
+			wantRestore: map[string]string{"ele": "", "ele:source": ""},
+		},
+		{
+			name:        "overwritten values are restored",
+			previous:    tags("ele", "2000", "ele:source", "survey"),
+			edited:      tags("ele", "2010", "ele:source", "SRTM"),
+			current:     tags("ele", "2010", "ele:source", "SRTM"),
+			wantRestore: map[string]string{"ele": "2000", "ele:source": "survey"},
+		},
+		{
+			name:        "untouched tags are left alone",
+			previous:    tags("ele", "2000", "ele:source", "SRTM"),
+			edited:      tags("ele", "2010", "ele:source", "SRTM"),
+			current:     tags("ele", "2010", "ele:source", "SRTM"),
+			wantRestore: map[string]string{"ele": "2000"},
+		},
+		{
+			name:          "later edits block the revert",
+			previous:      tags("natural", "peak"),
+			edited:        tags("natural", "peak", "ele", "2010", "ele:source", "SRTM"),
+			current:       tags("natural", "peak", "ele", "2012", "ele:source", "SRTM"),
+			wantRestore:   map[string]string{},
+			wantConflicts: []string{"ele"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore, conflicts := planRevertTags(tt.previous, tt.edited, tt.current)
+			if !reflect.DeepEqual(restore, tt.wantRestore) {
+				t.Errorf("restore = %v, want %v", restore, tt.wantRestore)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("conflicts = %v, want %v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
+
+func TestRestoreTags(t *testing.T) {
+	tags := []NodeTag{{Key: "natural", Value: "peak"}, {Key: "ele", Value: "2010"}, {Key: "ele:source", Value: "SRTM"}}
+	got := RestoreTags(tags, map[string]string{"ele": "2000", "ele:source": ""})
+	want := []NodeTag{{Key: "natural", Value: "peak"}, {Key: "ele", Value: "2000"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RestoreTags() = %v, want %v", got, want)
+	}
+}
+
+// revertServer fakes the OSM API for a changeset 5 that added ele to node 1 and way 2;
+// way 2 has been edited again since
+func revertServer(t *testing.T, createdBy string, uploaded *string) *httptest.Server {
+	t.Helper()
+	responses := map[string]string{
+		"GET /api/0.6/changeset/5": fmt.Sprintf(`<osm><changeset id="5" open="false"><tag k="created_by" v="%s"/></changeset></osm>`, createdBy),
+		"GET /api/0.6/changeset/5/download": `<osmChange version="0.6">
+			<modify><node id="1" version="3" lat="45" lon="25"><tag k="natural" v="peak"/><tag k="ele" v="2010"/><tag k="ele:source" v="SRTM"/></node></modify>
+			<modify><way id="2" version="4"><nd ref="1"/><tag k="tourism" v="alpine_hut"/><tag k="ele" v="1800"/><tag k="ele:source" v="SRTM"/></way></modify>
+		</osmChange>`,
+		"GET /api/0.6/node/1/2":           `<osm><node id="1" version="2" lat="45" lon="25"><tag k="natural" v="peak"/></node></osm>`,
+		"GET /api/0.6/node/1":             `<osm><node id="1" version="3" lat="45" lon="25"><tag k="natural" v="peak"/><tag k="ele" v="2010"/><tag k="ele:source" v="SRTM"/></node></osm>`,
+		"GET /api/0.6/way/2/3":            `<osm><way id="2" version="3"><nd ref="1"/><tag k="tourism" v="alpine_hut"/></way></osm>`,
+		"GET /api/0.6/way/2":              `<osm><way id="2" version="5"><nd ref="1"/><tag k="tourism" v="alpine_hut"/><tag k="ele" v="1795"/><tag k="ele:source" v="survey"/></way></osm>`,
+		"PUT /api/0.6/changeset/create":   "77",
+		"PUT /api/0.6/changeset/77/close": "",
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Method + " " + r.URL.Path
+		if key == "POST /api/0.6/changeset/77/upload" {
+			body, _ := io.ReadAll(r.Body)
+			*uploaded = string(body)
+			fmt.Fprint(w, `<diffResult version="0.6"><node old_id="1" new_id="1" new_version="4"/></diffResult>`)
+			return
+		}
+		response, ok := responses[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestRunRevert(t *testing.T) {
+	disableSharedBudget(t)
+	saved := DefaultWorkspace
+	t.Cleanup(func() {
+		flagConfig = NewConfig()
+		DefaultWorkspace = saved
+	})
+	DefaultWorkspace = Workspace{Dir: t.TempDir()}
+
+	var uploaded string
+	server := revertServer(t, "elevate-romania", &uploaded)
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	oauthConfig := &OAuthConfig{ClientID: "id", ClientSecret: "secret", AccessToken: "token"}
+	if err := runRevert(context.Background(), false, oauthConfig, 5, false); err != nil {
+		t.Fatalf("runRevert: %v", err)
+	}
+
+	if !strings.Contains(uploaded, `<node id="1" version="3" changeset="77"`) {
+		t.Errorf("Expected node 1 to be reverted in changeset 77, uploaded:\n%s", uploaded)
+	}
+	if strings.Contains(uploaded, `k="ele"`) || strings.Contains(uploaded, `k="ele:source"`) {
+		t.Errorf("Expected ele tags to be removed, uploaded:\n%s", uploaded)
+	}
+	if strings.Contains(uploaded, `<way`) {
+		t.Errorf("Expected way 2 with later edits to be skipped, uploaded:\n%s", uploaded)
+	}
+}
+
+func TestRunRevertRefusesForeignChangeset(t *testing.T) {
+	disableSharedBudget(t)
+	saved := DefaultWorkspace
+	t.Cleanup(func() {
+		flagConfig = NewConfig()
+		DefaultWorkspace = saved
+	})
+	DefaultWorkspace = Workspace{Dir: t.TempDir()}
+
+	var uploaded string
+	server := revertServer(t, "JOSM/1.5", &uploaded)
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	err := runRevert(context.Background(), true, nil, 5, false)
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Errorf("Expected refusal without --force, got %v", err)
+	}
+
+	if err := runRevert(context.Background(), true, nil, 5, true); err != nil {
+		t.Errorf("Expected dry-run revert with --force to succeed: %v", err)
+	}
+	if uploaded != "" {
+		t.Error("Expected no upload in dry-run mode")
+	}
+}
+
+func TestRunRevertAcceptsConfiguredCreatedBy(t *testing.T) {
+	disableSharedBudget(t)
+	saved := DefaultWorkspace
+	t.Cleanup(func() {
+		flagConfig = NewConfig()
+		DefaultWorkspace = saved
+	})
+	DefaultWorkspace = Workspace{Dir: t.TempDir()}
+
+	var uploaded string
+	server := revertServer(t, "ro-ele-bot/1.2", &uploaded)
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+	flagConfig.Set("CHANGESET_CREATED_BY", "ro-ele-bot/1.3")
+
+	if err := runRevert(context.Background(), true, nil, 5, false); err != nil {
+		t.Errorf("Expected a changeset of the configured created_by to be reverted without --force: %v", err)
+	}
+}
+
+func TestRunRevertUsesUndoLog(t *testing.T) {
+	disableSharedBudget(t)
+	saved := DefaultWorkspace
+	t.Cleanup(func() {
+		flagConfig = NewConfig()
+		DefaultWorkspace = saved
+	})
+	DefaultWorkspace = Workspace{Dir: t.TempDir()}
+
+	var uploaded string
+	server := revertServer(t, "elevate-romania", &uploaded)
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	// The snapshot of node 1 differs from its version history, so the restored ele shows which
+	// one was used; the snapshot of way 2 is of another version and is ignored
+	undoLog, err := NewUndoLog(DefaultWorkspace.File(DefaultUndoLogFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot := `<osm><node id="1" version="2" lat="45" lon="25"><tag k="natural" v="peak"/><tag k="ele" v="1990"/></node></osm>`
+	if err := undoLog.Record(5, "node", 1, 2, []byte(snapshot)); err != nil {
+		t.Fatal(err)
+	}
+	if err := undoLog.Record(5, "way", 2, 1, []byte(`<osm/>`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := undoLog.Record(6, "node", 1, 2, []byte(`<osm/>`)); err != nil {
+		t.Fatal(err)
+	}
+
+	oauthConfig := &OAuthConfig{ClientID: "id", ClientSecret: "secret", AccessToken: "token"}
+	if err := runRevert(context.Background(), false, oauthConfig, 5, false); err != nil {
+		t.Fatalf("runRevert: %v", err)
+	}
+	if !strings.Contains(uploaded, `<tag k="ele" v="1990">`) || strings.Contains(uploaded, `k="ele:source"`) {
+		t.Errorf("Expected node 1 restored from the undo log snapshot, uploaded:\n%s", uploaded)
+	}
+}
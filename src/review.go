@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// reviewCSVHeader lists the columns of the community review CSV
+var reviewCSVHeader = []string{
+	"approve", "category", "type", "id", "version", "name", "lat", "lon",
+	"old_ele", "new_ele", "ele_source", "osm_link",
+}
+
+// GeoJSONFeatureCollection is a minimal GeoJSON feature collection
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// GeoJSONFeature is a GeoJSON point feature with free-form properties
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   GeoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// GeoJSONGeometry is a GeoJSON point geometry
+type GeoJSONGeometry struct {
+	Type        string    `json:"type"`
+	Coordinates []float64 `json:"coordinates"`
+}
+
+// reviewPaths derives the review CSV and GeoJSON paths from a proposal file path
+func reviewPaths(proposalFile string) (string, string) {
+	base := strings.TrimSuffix(proposalFile, ".json")
+	return base + "_review.csv", base + "_review.geojson"
+}
+
+// elementName returns the name (or ref) of an element
+func elementName(element OSMElement) string {
+	if element.Tags == nil {
+		return ""
+	}
+	if name, ok := element.Tags["name"]; ok {
+		return name
+	}
+	return element.Tags["ref"]
+}
+
+// osmLink returns the openstreetmap.org URL of an element
+func osmLink(elementType string, id int64) string {
+	return fmt.Sprintf("https://www.openstreetmap.org/%s/%d", elementType, id)
+}
+
+// ExportProposalReviewCSV writes the proposed edits as a CSV with an empty "approve" column
+func ExportProposalReviewCSV(proposal *Proposal, outputFile string) error {
+	file, err := os.Create(outputFile)
+	if err != nil {
+		return fmt.Errorf("failed to create review CSV: %v", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	defer writer.Flush()
+
+	if err := writer.Write(reviewCSVHeader); err != nil {
+		return fmt.Errorf("failed to write header: %v", err)
+	}
+
+	extractor := NewCoordinateExtractor()
+	for _, edit := range proposal.Edits {
+		element := edit.Element
+		coords, _ := extractor.Extract(element)
+		_, newSource := eleSourceTag(edit.NewTags)
+		record := []string{
+			"",
+			edit.Category,
+			element.Type,
+			strconv.FormatInt(element.ID, 10),
+			strconv.Itoa(edit.Version),
+			elementName(element),
+			fmt.Sprintf("%.6f", coords.Lat),
+			fmt.Sprintf("%.6f", coords.Lon),
+			edit.OldTags["ele"],
+			edit.NewTags["ele"],
+			newSource,
+			osmLink(element.Type, element.ID),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write row: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// ExportProposalReviewGeoJSON writes the proposed edits as GeoJSON points with an "approve" property
+func ExportProposalReviewGeoJSON(proposal *Proposal, outputFile string) error {
+	collection := GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []GeoJSONFeature{},
+	}
+
+	extractor := NewCoordinateExtractor()
+	for _, edit := range proposal.Edits {
+		element := edit.Element
+		coords, valid := extractor.Extract(element)
+		if !valid {
+			continue
+		}
+		_, newSource := eleSourceTag(edit.NewTags)
+
+		collection.Features = append(collection.Features, GeoJSONFeature{
+			Type: "Feature",
+			Geometry: GeoJSONGeometry{
+				Type:        "Point",
+				Coordinates: []float64{coords.Lon, coords.Lat},
+			},
+			Properties: map[string]interface{}{
+				"approve":    "",
+				"category":   edit.Category,
+				"type":       element.Type,
+				"id":         element.ID,
+				"version":    edit.Version,
+				"name":       elementName(element),
+				"old_ele":    edit.OldTags["ele"],
+				"new_ele":    edit.NewTags["ele"],
+				"ele_source": newSource,
+				"osm_link":   osmLink(element.Type, element.ID),
+			},
+		})
+	}
+
+	return saveJSON(outputFile, collection)
+}
+
+// isApprovedValue reports whether a reviewer marked a row as approved
+func isApprovedValue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "yes", "y", "true", "1", "x", "ok", "approved":
+		return true
+	}
+	return false
+}
+
+// LoadApprovedKeys reads a review CSV and returns the element keys marked as approved
+func LoadApprovedKeys(r io.Reader) (map[string]bool, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read review CSV header: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"approve", "type", "id"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("review CSV is missing the %q column", required)
+		}
+	}
+
+	approved := make(map[string]bool)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read review CSV: %v", err)
+		}
+
+		if columns["approve"] >= len(record) || !isApprovedValue(record[columns["approve"]]) {
+			continue
+		}
+		for _, required := range []string{"type", "id"} {
+			if columns[required] >= len(record) {
+				return nil, fmt.Errorf("review CSV line %d: missing the %q column", line, required)
+			}
+		}
+
+		id, err := strconv.ParseInt(strings.TrimSpace(record[columns["id"]]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("review CSV line %d: invalid element id %q", line, record[columns["id"]])
+		}
+		approved[elementKey(strings.TrimSpace(record[columns["type"]]), id)] = true
+	}
+
+	return approved, nil
+}
+
+// FilterApproved returns a copy of the proposal containing only the approved edits
+func (p *Proposal) FilterApproved(approved map[string]bool) *Proposal {
+	filtered := *p
+	filtered.Edits = []ProposedEdit{}
+	for _, edit := range p.Edits {
+		if approved[elementKey(edit.Element.Type, edit.Element.ID)] {
+			filtered.Edits = append(filtered.Edits, edit)
+		}
+	}
+	return &filtered
+}
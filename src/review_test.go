@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoadApprovedKeys(t *testing.T) {
+	input := `approve,category,type,id,version
+yes,alpine_huts,node,1,3
+,train_stations,node,2,1
+X,other_accommodations,way,3,7
+no,other_accommodations,way,4,2
+`
+	approved, err := LoadApprovedKeys(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("LoadApprovedKeys() error = %v", err)
+	}
+
+	expected := map[string]bool{"node/1": true, "way/3": true}
+	if len(approved) != len(expected) {
+		t.Fatalf("Expected %d approved keys, got %d: %v", len(expected), len(approved), approved)
+	}
+	for key := range expected {
+		if !approved[key] {
+			t.Errorf("Expected %s to be approved", key)
+		}
+	}
+}
+
+func TestLoadApprovedKeysMissingColumn(t *testing.T) {
+	if _, err := LoadApprovedKeys(strings.NewReader("type,id\nnode,1\n")); err == nil {
+		t.Error("Expected error for CSV without approve column")
+	}
+}
+
+func TestLoadApprovedKeysShortRow(t *testing.T) {
+	input := "approve,category,type,id\nyes,alpine_huts\n"
+	_, err := LoadApprovedKeys(strings.NewReader(input))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("LoadApprovedKeys() error = %v, want an error for line 2", err)
+	}
+}
+
+func TestProposalFilterApproved(t *testing.T) {
+	proposal := &Proposal{
+		Country: "România",
+		Edits: []ProposedEdit{
+			{Category: "alpine_huts", Element: OSMElement{Type: "node", ID: 1}},
+			{Category: "train_stations", Element: OSMElement{Type: "node", ID: 2}},
+			{Category: "other_accommodations", Element: OSMElement{Type: "way", ID: 3}},
+		},
+	}
+
+	filtered := proposal.FilterApproved(map[string]bool{"node/2": true, "way/3": true})
+
+	if len(filtered.Edits) != 2 {
+		t.Fatalf("Expected 2 approved edits, got %d", len(filtered.Edits))
+	}
+	if len(proposal.Edits) != 3 {
+		t.Error("FilterApproved() should not modify the original proposal")
+	}
+	if filtered.Edits[0].Element.ID != 2 || filtered.Edits[1].Element.ID != 3 {
+		t.Errorf("Unexpected approved edits: %+v", filtered.Edits)
+	}
+}
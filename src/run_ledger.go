@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// DefaultRunLedgerFile is where per-country run history is stored
+const DefaultRunLedgerFile = "output/run_ledger.json"
+
+// CountryRunState tracks incremental processing state for a single country
+type CountryRunState struct {
+	// LastExtract is the OSM data timestamp of the most recent extraction
+	LastExtract string `json:"last_extract,omitempty"`
+	// LastSuccess is the data timestamp of the last extraction whose upload completed without failures
+	LastSuccess string `json:"last_success,omitempty"`
+	// Uploaded lists element keys (type/id) that were successfully uploaded
+	Uploaded []string `json:"uploaded"`
+
+	uploadedSet map[string]bool
+}
+
+// RunLedger stores run state for all processed countries
+type RunLedger struct {
+	path      string
+	Countries map[string]*CountryRunState `json:"countries"`
+}
+
+// LoadRunLedger loads the ledger from disk, returning an empty ledger if the file does not exist
+func LoadRunLedger(path string) (*RunLedger, error) {
+	ledger := &RunLedger{
+		path:      path,
+		Countries: make(map[string]*CountryRunState),
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		if err := loadJSON(path, ledger); err != nil {
+			return nil, fmt.Errorf("failed to load run ledger %s: %v", path, err)
+		}
+		if ledger.Countries == nil {
+			ledger.Countries = make(map[string]*CountryRunState)
+		}
+	}
+
+	return ledger, nil
+}
+
+// Country returns the state for a country, creating it if needed
+func (l *RunLedger) Country(country string) *CountryRunState {
+	state, ok := l.Countries[country]
+	if !ok {
+		state = &CountryRunState{Uploaded: []string{}}
+		l.Countries[country] = state
+	}
+	if state.uploadedSet == nil {
+		state.uploadedSet = make(map[string]bool, len(state.Uploaded))
+		for _, key := range state.Uploaded {
+			state.uploadedSet[key] = true
+		}
+	}
+	return state
+}
+
+// Save writes the ledger to disk
+func (l *RunLedger) Save() error {
+	for _, state := range l.Countries {
+		sort.Strings(state.Uploaded)
+	}
+	return saveJSON(l.path, l)
+}
+
+// IsUploaded reports whether an element was already uploaded for this country
+func (s *CountryRunState) IsUploaded(elementType string, id int64) bool {
+	return s.uploadedSet[elementKey(elementType, id)]
+}
+
+// MarkUploaded records a successfully uploaded element
+func (s *CountryRunState) MarkUploaded(elementType string, id int64) {
+	key := elementKey(elementType, id)
+	if s.uploadedSet[key] {
+		return
+	}
+	s.uploadedSet[key] = true
+	s.Uploaded = append(s.Uploaded, key)
+}
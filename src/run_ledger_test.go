@@ -0,0 +1,96 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRunLedgerPersistsUploadedElements(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run_ledger.json")
+
+	ledger, err := LoadRunLedger(path)
+	if err != nil {
+		t.Fatalf("LoadRunLedger() error = %v", err)
+	}
+
+	state := ledger.Country("România")
+	state.LastExtract = "2024-05-01T10:00:00Z"
+	state.MarkUploaded("node", 10)
+	state.MarkUploaded("way", 20)
+	state.MarkUploaded("node", 10)
+
+	if err := ledger.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	reloaded, err := LoadRunLedger(path)
+	if err != nil {
+		t.Fatalf("LoadRunLedger() reload error = %v", err)
+	}
+
+	state = reloaded.Country("România")
+	if len(state.Uploaded) != 2 {
+		t.Errorf("Expected 2 uploaded elements, got %d", len(state.Uploaded))
+	}
+	if !state.IsUploaded("node", 10) || !state.IsUploaded("way", 20) {
+		t.Error("Expected node/10 and way/20 to be marked as uploaded")
+	}
+	if state.IsUploaded("way", 10) {
+		t.Error("way/10 should not be marked as uploaded")
+	}
+	if state.LastExtract != "2024-05-01T10:00:00Z" {
+		t.Errorf("LastExtract = %q, want 2024-05-01T10:00:00Z", state.LastExtract)
+	}
+	if reloaded.Country("Moldova").IsUploaded("node", 10) {
+		t.Error("Uploaded elements should be tracked per country")
+	}
+}
+
+func TestOverpassExtractorNewerFilter(t *testing.T) {
+	extractor := NewOverpassExtractor("România")
+	if got := extractor.newerFilter(); got != "" {
+		t.Errorf("newerFilter() without baseline = %q, want empty", got)
+	}
+
+	extractor.NewerThan = "2024-05-01T10:00:00Z"
+	if got := extractor.newerFilter(); got != `(newer:"2024-05-01T10:00:00Z")` {
+		t.Errorf("newerFilter() = %q", got)
+	}
+}
+
+func TestUploaderFlushesLedgerAfterEachEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run_ledger.json")
+
+	ledger, err := LoadRunLedger(path)
+	if err != nil {
+		t.Fatalf("LoadRunLedger() error = %v", err)
+	}
+
+	uploader := &OSMUploader{
+		ledger:       ledger,
+		runState:     ledger.Country("România"),
+		skipUploaded: true,
+	}
+	uploader.markUploaded(OSMElement{Type: "node", ID: 7})
+
+	// An interrupted run resumes from what is on disk
+	reloaded, err := LoadRunLedger(path)
+	if err != nil {
+		t.Fatalf("LoadRunLedger() reload error = %v", err)
+	}
+	resumed := &OSMUploader{
+		runState:     reloaded.Country("România"),
+		skipUploaded: true,
+	}
+	if !resumed.alreadyUploaded(OSMElement{Type: "node", ID: 7}) {
+		t.Error("Expected node 7 to be skipped after resuming")
+	}
+	if resumed.alreadyUploaded(OSMElement{Type: "node", ID: 8}) {
+		t.Error("Node 8 was never uploaded")
+	}
+
+	resumed.skipUploaded = false
+	if resumed.alreadyUploaded(OSMElement{Type: "node", ID: 7}) {
+		t.Error("Expected --reupload to disable skipping")
+	}
+}
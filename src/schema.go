@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// pipelineSchemaVersion is the format of the pipeline data files. Bump it whenever a change
+// makes files written by earlier versions decode wrongly.
+const pipelineSchemaVersion = 1
+
+// Kinds of pipeline data file
+const (
+	SchemaRaw       = "raw"
+	SchemaFiltered  = "filtered"
+	SchemaEnriched  = "enriched"
+	SchemaNoData    = "nodata"
+	SchemaValidated = "validated"
+)
+
+// schemaProducers maps each kind of file to the step that writes it
+var schemaProducers = map[string]string{
+	SchemaRaw:       StepExtract,
+	SchemaFiltered:  StepFilter,
+	SchemaEnriched:  StepEnrich,
+	SchemaNoData:    StepEnrich,
+	SchemaValidated: StepValidate,
+}
+
+// ErrSchemaMismatch is returned when a pipeline file was written in another format
+var ErrSchemaMismatch = errors.New("incompatible pipeline file")
+
+// SchemaHeader identifies the format of a pipeline data file; it is embedded in the file types
+type SchemaHeader struct {
+	SchemaVersion int    `json:"schema_version"`
+	Kind          string `json:"kind"`
+	ToolVersion   string `json:"tool_version,omitempty"`
+}
+
+func (h *SchemaHeader) schemaHeader() *SchemaHeader {
+	return h
+}
+
+// pipelineFile is a pipeline data file type embedding a SchemaHeader
+type pipelineFile interface {
+	schemaHeader() *SchemaHeader
+}
+
+// savePipelineFile stamps data with the current schema version and kind and writes it
+func savePipelineFile(path, kind string, data pipelineFile) error {
+	*data.schemaHeader() = SchemaHeader{SchemaVersion: pipelineSchemaVersion, Kind: kind, ToolVersion: appVersion}
+	return saveJSON(path, data)
+}
+
+// loadPipelineFile reads a pipeline data file and checks that it has the expected kind and
+// the current schema version, so a file in an old format fails clearly instead of decoding
+// to empty categories
+func loadPipelineFile(path, kind string, data pipelineFile) error {
+	if err := loadJSON(path, data); err != nil {
+		// A file in another format may not even decode; its header explains why
+		var header SchemaHeader
+		if !os.IsNotExist(err) && loadJSON(path, &header) == nil {
+			if mismatch := checkSchema(path, kind, header); mismatch != nil {
+				return mismatch
+			}
+		}
+		return err
+	}
+	return checkSchema(path, kind, *data.schemaHeader())
+}
+
+// checkSchema reports why a file header does not match the expected kind and version
+func checkSchema(path, kind string, header SchemaHeader) error {
+	step := schemaProducers[kind]
+	switch {
+	case header.SchemaVersion == 0:
+		return fmt.Errorf("%w: %s has no schema version, so it was produced by an older version; re-run --%s", ErrSchemaMismatch, path, step)
+	case header.SchemaVersion < pipelineSchemaVersion:
+		return fmt.Errorf("%w: %s was produced by an older version (schema %d, expected %d); re-run --%s",
+			ErrSchemaMismatch, path, header.SchemaVersion, pipelineSchemaVersion, step)
+	case header.SchemaVersion > pipelineSchemaVersion:
+		return fmt.Errorf("%w: %s was produced by a newer version (schema %d, this version reads %d); update %s",
+			ErrSchemaMismatch, path, header.SchemaVersion, pipelineSchemaVersion, commandName)
+	case header.Kind != kind:
+		return fmt.Errorf("%w: %s holds %s data, expected %s data written by --%s", ErrSchemaMismatch, path, header.Kind, kind, step)
+	}
+	return nil
+}
+
+// pipelineLoadError explains a failed load of the input of a step: a schema mismatch says
+// itself what to do, anything else means the producing step has to run first
+func pipelineLoadError(path, step string, err error) error {
+	if errors.Is(err, ErrSchemaMismatch) {
+		return err
+	}
+	return fmt.Errorf("%s not found. Run --%s first: %v", path, step, err)
+}
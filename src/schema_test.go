@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPipelineFileRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enriched.json")
+	elevation := 2544.0
+	data := &EnrichedData{Peaks: []OSMElement{{Type: "node", ID: 1, ElevationFetched: &elevation}}}
+	if err := savePipelineFile(path, SchemaEnriched, data); err != nil {
+		t.Fatalf("savePipelineFile() error = %v", err)
+	}
+
+	var loaded EnrichedData
+	if err := loadPipelineFile(path, SchemaEnriched, &loaded); err != nil {
+		t.Fatalf("loadPipelineFile() error = %v", err)
+	}
+	if loaded.SchemaVersion != pipelineSchemaVersion || loaded.Kind != SchemaEnriched || len(loaded.Peaks) != 1 {
+		t.Errorf("loaded %+v, want schema %d enriched data with 1 peak", loaded, pipelineSchemaVersion)
+	}
+
+	var validated ValidatedData
+	err := loadPipelineFile(path, SchemaValidated, &validated)
+	if !errors.Is(err, ErrSchemaMismatch) || !strings.Contains(err.Error(), "holds enriched data") {
+		t.Errorf("loading enriched data as validated: error = %v, want a schema mismatch", err)
+	}
+}
+
+func TestCheckSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		header SchemaHeader
+		want   string
+	}{
+		{"Current", SchemaHeader{SchemaVersion: pipelineSchemaVersion, Kind: SchemaRaw}, ""},
+		{"Unversioned", SchemaHeader{}, "older version; re-run --extract"},
+		{"Older", SchemaHeader{SchemaVersion: pipelineSchemaVersion - 1, Kind: SchemaRaw}, "re-run --extract"},
+		{"Newer", SchemaHeader{SchemaVersion: pipelineSchemaVersion + 1, Kind: SchemaRaw}, "newer version"},
+		{"WrongKind", SchemaHeader{SchemaVersion: pipelineSchemaVersion, Kind: SchemaFiltered}, "holds filtered data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkSchema("raw.json", SchemaRaw, tt.header)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("checkSchema() error = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrSchemaMismatch) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("checkSchema() error = %v, want a mismatch containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPipelineLoadError(t *testing.T) {
+	mismatch := checkSchema("raw.json", SchemaRaw, SchemaHeader{})
+	if got := pipelineLoadError("raw.json", "extract", mismatch); got != mismatch {
+		t.Errorf("pipelineLoadError(mismatch) = %v, want it unchanged", got)
+	}
+	missing := pipelineLoadError("raw.json", "extract", errors.New("no such file"))
+	if !strings.Contains(missing.Error(), "not found. Run --extract first") {
+		t.Errorf("pipelineLoadError(missing) = %v", missing)
+	}
+}
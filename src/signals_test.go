@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSleepContext(t *testing.T) {
+	if err := sleepContext(context.Background(), time.Millisecond); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if err := sleepContext(ctx, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("Expected canceled sleep to return immediately")
+	}
+}
+
+func TestBudgetAcquireContextCanceled(t *testing.T) {
+	budget := NewBudget("", map[string]BudgetLimit{BudgetOverpass: {Hourly: 1}}, 2*time.Hour)
+	if err := budget.AcquireContext(context.Background(), BudgetOverpass); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := budget.AcquireContext(ctx, BudgetOverpass); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled while waiting for the budget, got %v", err)
+	}
+}
+
+// cancelingProvider cancels the run after answering its first batch
+type cancelingProvider struct {
+	fakeBatchProvider
+	cancel context.CancelFunc
+}
+
+func (p *cancelingProvider) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	defer p.cancel()
+	return p.fakeBatchProvider.BatchGetElevations(ctx, locations)
+}
+
+func TestEnrichInterruptedSavesCheckpoint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "enrich_checkpoint.json")
+	checkpoint, err := LoadEnrichCheckpoint(path, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	provider := &cancelingProvider{
+		fakeBatchProvider: fakeBatchProvider{elevations: map[int64]float64{1: 100, 2: 200}},
+		cancel:            cancel,
+	}
+	enricher := NewBatchElevationEnricher("opentopo", 0, 1)
+	enricher.Provider = provider
+	enricher.Checkpoint = checkpoint
+
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.1, Lon: 25.1},
+		{Type: "node", ID: 2, Lat: 45.2, Lon: 25.2},
+	}
+	if _, err := enricher.EnrichElementsBatch(ctx, elements, 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected enrichment to stop after the first batch, got %d calls", provider.calls)
+	}
+
+	saved, err := LoadEnrichCheckpoint(path, "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved.Lookup(elements[0]); !ok || len(saved.Elements) != 1 {
+		t.Errorf("Expected the first batch in the saved checkpoint, got %v", saved.Elements)
+	}
+}
+
+func TestUploadElementsStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	uploader := &OSMUploader{dryRun: true}
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Tags: map[string]string{"ele": "100", "ele:source": "SRTM"}},
+		{Type: "node", ID: 2, Tags: map[string]string{"ele": "200", "ele:source": "SRTM"}},
+	}
+	stats := uploader.UploadElements(ctx, elements, "peaks")
+	if stats.Successful != 0 || stats.Failed != 0 {
+		t.Errorf("Expected no uploads after cancellation, got %+v", stats)
+	}
+}
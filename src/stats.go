@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultCoverageFile keeps the ele coverage of every area checked with the stats command
+const DefaultCoverageFile = "output/coverage.json"
+
+// CategoryCoverage is the share of a category's features that already have ele
+type CategoryCoverage struct {
+	Category   string  `json:"category"`
+	Label      string  `json:"label"`
+	Total      int     `json:"total"`
+	WithEle    int     `json:"with_ele"`
+	WithoutEle int     `json:"without_ele"`
+	Coverage   float64 `json:"coverage_percent"`
+}
+
+// AreaCoverage is the ele coverage of one country or area
+type AreaCoverage struct {
+	Country    string             `json:"country"`
+	Area       string             `json:"area"`
+	Key        string             `json:"key"`
+	CheckedAt  string             `json:"checked_at"`
+	Categories []CategoryCoverage `json:"categories"`
+	Total      int                `json:"total"`
+	WithEle    int                `json:"with_ele"`
+	WithoutEle int                `json:"without_ele"`
+	Coverage   float64            `json:"coverage_percent"`
+}
+
+// CoverageFile is the coverage file, one entry per area
+type CoverageFile struct {
+	Areas []AreaCoverage `json:"areas"`
+}
+
+// coveragePercent returns the share of features with ele; an area without features has
+// nothing left to tag and counts as covered
+func coveragePercent(withEle, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return 100 * float64(withEle) / float64(total)
+}
+
+// coverageQuery counts a category's features in the area, then those of them with ele. The
+// response holds two count elements in that order.
+func (e *OverpassExtractor) coverageQuery(cat ProfileCategory) string {
+	setup, area := e.Area.overpassFilter(e.Country)
+	return fmt.Sprintf(`
+[out:json][timeout:300];
+%s(
+%s
+)->.found;
+.found out count;
+nwr.found["ele"];
+out count;
+`, setup, e.categoryStatements(cat, "", area))
+}
+
+// parseCoverageCounts reads the totals of the two count elements of a coverage query
+func parseCoverageCounts(elements []OSMElement) (total, withEle int, err error) {
+	if len(elements) != 2 || elements[0].Type != "count" || elements[1].Type != "count" {
+		return 0, 0, fmt.Errorf("unexpected Overpass count response (%d elements)", len(elements))
+	}
+	if total, err = strconv.Atoi(elements[0].Tags["total"]); err != nil {
+		return 0, 0, fmt.Errorf("invalid Overpass count %q: %v", elements[0].Tags["total"], err)
+	}
+	if withEle, err = strconv.Atoi(elements[1].Tags["total"]); err != nil {
+		return 0, 0, fmt.Errorf("invalid Overpass count %q: %v", elements[1].Tags["total"], err)
+	}
+	return total, withEle, nil
+}
+
+// CategoryCoverage counts the features of a category with and without ele
+func (e *OverpassExtractor) CategoryCoverage(ctx context.Context, cat ProfileCategory) (CategoryCoverage, error) {
+	extractLog.Info("Counting %s in %s...", cat.Label, e.Area.Describe(e.Country))
+	query := e.coverageQuery(cat)
+	e.Queries = append(e.Queries, query)
+	elements, err := e.queryOverpass(ctx, query)
+	if err != nil {
+		return CategoryCoverage{}, err
+	}
+	total, withEle, err := parseCoverageCounts(elements)
+	if err != nil {
+		return CategoryCoverage{}, err
+	}
+	return CategoryCoverage{
+		Category:   cat.Key,
+		Label:      cat.Label,
+		Total:      total,
+		WithEle:    withEle,
+		WithoutEle: total - withEle,
+		Coverage:   coveragePercent(withEle, total),
+	}, nil
+}
+
+// Coverage counts every category of the active profile
+func (e *OverpassExtractor) Coverage(ctx context.Context) (AreaCoverage, error) {
+	coverage := AreaCoverage{
+		Country:    e.Country,
+		Area:       e.Area.Describe(e.Country),
+		Key:        e.Area.LedgerKey(e.Country),
+		Categories: []CategoryCoverage{},
+	}
+	for i, cat := range activeProfile().Categories {
+		if i > 0 {
+			// Be nice to Overpass API
+			if err := sleepContext(ctx, 2*time.Second); err != nil {
+				return coverage, err
+			}
+		}
+		category, err := e.CategoryCoverage(ctx, cat)
+		if err != nil {
+			return coverage, err
+		}
+		coverage.Categories = append(coverage.Categories, category)
+		coverage.Total += category.Total
+		coverage.WithEle += category.WithEle
+	}
+	coverage.WithoutEle = coverage.Total - coverage.WithEle
+	coverage.Coverage = coveragePercent(coverage.WithEle, coverage.Total)
+	coverage.CheckedAt = time.Now().UTC().Format(time.RFC3339)
+	return coverage, nil
+}
+
+// LoadCoverageFile reads the coverage file; a missing file yields an empty one
+func LoadCoverageFile(path string) (*CoverageFile, error) {
+	file := &CoverageFile{Areas: []AreaCoverage{}}
+	if err := loadJSON(path, file); err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to load coverage file %s: %v", path, err)
+	}
+	return file, nil
+}
+
+// Record replaces the previous coverage of the area and keeps the areas ordered from the
+// lowest coverage, i.e. the most work left, up
+func (f *CoverageFile) Record(coverage AreaCoverage) {
+	replaced := false
+	for i := range f.Areas {
+		if f.Areas[i].Key == coverage.Key {
+			f.Areas[i] = coverage
+			replaced = true
+		}
+	}
+	if !replaced {
+		f.Areas = append(f.Areas, coverage)
+	}
+	sort.SliceStable(f.Areas, func(i, j int) bool {
+		return f.Areas[i].Coverage < f.Areas[j].Coverage
+	})
+}
+
+// printCoverage prints the coverage table of an area
+func printCoverage(coverage AreaCoverage) {
+	fmt.Printf("\n%-25s %8s %8s %8s %9s\n", "Category", "Total", "With ele", "Missing", "Coverage")
+	for _, category := range coverage.Categories {
+		fmt.Printf("%-25s %8d %8d %8d %8.1f%%\n", category.Label, category.Total, category.WithEle, category.WithoutEle, category.Coverage)
+	}
+	fmt.Printf("%-25s %8d %8d %8d %8.1f%%\n", "Total", coverage.Total, coverage.WithEle, coverage.WithoutEle, coverage.Coverage)
+}
+
+// printCoverageRanking lists the areas of the coverage file from the most work left down
+func printCoverageRanking(file *CoverageFile) {
+	if len(file.Areas) < 2 {
+		return
+	}
+	fmt.Println("\nAreas checked so far, lowest coverage first:")
+	for _, area := range file.Areas {
+		fmt.Printf("  %6.1f%%  %6d missing  %s (%s)\n", area.Coverage, area.WithoutEle, area.Area, area.CheckedAt)
+	}
+}
+
+// runStats counts the target features with and without ele in an area and records the
+// coverage, without running the pipeline
+func runStats(ctx context.Context, country string, area AreaSelector) error {
+	extractLog.Banner("STATS - Counting ele coverage in %s", area.Describe(country))
+
+	config := NewConfig()
+	config.LoadFromEnv()
+	config.Set("COUNTRY", country)
+	factory := NewAPIClientFactory(config, NewLogger("Stats"))
+
+	extractor := factory.CreateOverpassExtractor()
+	extractor.Area = area
+	coverage, err := extractor.Coverage(ctx)
+	if err != nil {
+		return err
+	}
+	printCoverage(coverage)
+
+	path := DefaultWorkspace.File(DefaultCoverageFile)
+	file, err := LoadCoverageFile(path)
+	if err != nil {
+		return err
+	}
+	file.Record(coverage)
+	if err := saveJSON(path, file); err != nil {
+		return fmt.Errorf("failed to save coverage: %v", err)
+	}
+	printCoverageRanking(file)
+	extractLog.Info("✓ Coverage saved to %s", path)
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCategoryCoverage(t *testing.T) {
+	disableSharedBudget(t)
+	var query string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/status" {
+			io.WriteString(w, "Rate limit: 0\n")
+			return
+		}
+		query = r.FormValue("data")
+		io.WriteString(w, `{"elements":[
+			{"type":"count","id":0,"tags":{"nodes":"40","ways":"0","relations":"0","total":"40"}},
+			{"type":"count","id":0,"tags":{"nodes":"30","ways":"0","relations":"0","total":"30"}}
+		]}`)
+	}))
+	defer server.Close()
+
+	extractor := &OverpassExtractor{OverpassURL: server.URL + "/api/interpreter", Country: "România"}
+	peaks, _ := DefaultProfile().Category("peaks")
+	coverage, err := extractor.CategoryCoverage(context.Background(), peaks)
+	if err != nil {
+		t.Fatalf("CategoryCoverage() error = %v", err)
+	}
+	if coverage.Total != 40 || coverage.WithEle != 30 || coverage.WithoutEle != 10 || coverage.Coverage != 75 {
+		t.Errorf("CategoryCoverage() = %+v, want 30 of 40 (75%%)", coverage)
+	}
+	for _, want := range []string{`node["natural"="peak"](area.country)`, ".found out count;", `nwr.found["ele"];`} {
+		if !strings.Contains(query, want) {
+			t.Errorf("query missing %q:\n%s", want, query)
+		}
+	}
+	if strings.Contains(query, `"ele"!~`) {
+		t.Errorf("coverage query filters out elements with ele:\n%s", query)
+	}
+}
+
+func TestParseCoverageCounts(t *testing.T) {
+	count := func(total string) OSMElement {
+		return OSMElement{Type: "count", Tags: map[string]string{"total": total}}
+	}
+	tests := []struct {
+		name     string
+		elements []OSMElement
+		wantErr  bool
+	}{
+		{"valid", []OSMElement{count("5"), count("2")}, false},
+		{"missing count", []OSMElement{count("5")}, true},
+		{"not a count", []OSMElement{count("5"), {Type: "node"}}, true},
+		{"invalid number", []OSMElement{count("5"), count("many")}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := parseCoverageCounts(tt.elements)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCoverageCounts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCoverageFileRecord(t *testing.T) {
+	file := &CoverageFile{}
+	file.Record(AreaCoverage{Key: "România", Coverage: 60})
+	file.Record(AreaCoverage{Key: "Moldova", Coverage: 20})
+	file.Record(AreaCoverage{Key: "România", Coverage: 10})
+
+	if len(file.Areas) != 2 {
+		t.Fatalf("Record() kept %d areas, want 2", len(file.Areas))
+	}
+	if file.Areas[0].Key != "România" || file.Areas[0].Coverage != 10 || file.Areas[1].Key != "Moldova" {
+		t.Errorf("areas = %+v, want the updated România first", file.Areas)
+	}
+	if got := coveragePercent(0, 0); got != 100 {
+		t.Errorf("coveragePercent(0, 0) = %v, want 100", got)
+	}
+}
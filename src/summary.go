@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSummaryFile is the machine-readable outcome of the last run
+const DefaultSummaryFile = "output/summary.json"
+
+// Exit statuses of a run, besides exitInterrupted
+const (
+	exitComplete = 0
+	exitFatal    = 1
+	// exitPartial means the run finished but left failures or remaining work for a rerun
+	exitPartial = 2
+)
+
+// Run outcomes reported in the summary
+const (
+	RunComplete    = "complete"
+	RunPartial     = "partial"
+	RunFatal       = "fatal"
+	RunInterrupted = "interrupted"
+)
+
+// RunSummary collects the outcome of one invocation for automation wrappers
+type RunSummary struct {
+	mu              sync.Mutex
+	Command         string        `json:"command"`
+	Status          string        `json:"status"`
+	ExitCode        int           `json:"exit_code"`
+	StartedAt       string        `json:"started_at"`
+	FinishedAt      string        `json:"finished_at"`
+	DurationSeconds float64       `json:"duration_seconds"`
+	Steps           []SummaryStep `json:"steps"`
+	Failures        int           `json:"failures"`
+	Changesets      []int         `json:"changesets"`
+	// Problems lists what makes a run partial: failed countries, paused or aborted uploads
+	Problems []string `json:"problems,omitempty"`
+	Error    string   `json:"error,omitempty"`
+
+	started time.Time
+}
+
+// SummaryStep is a finished pipeline step
+type SummaryStep struct {
+	Step            string         `json:"step"`
+	Country         string         `json:"country,omitempty"`
+	DryRun          bool           `json:"dry_run,omitempty"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	Counts          map[string]int `json:"counts,omitempty"`
+	Failures        int            `json:"failures"`
+}
+
+// runSummary is the summary of the current invocation
+var runSummary = NewRunSummary(nil)
+
+// NewRunSummary starts a summary for the given command line
+func NewRunSummary(args []string) *RunSummary {
+	var command []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		command = append(command, arg)
+	}
+	now := time.Now()
+	return &RunSummary{
+		Command:    strings.Join(command, " "),
+		StartedAt:  now.UTC().Format(time.RFC3339),
+		Steps:      []SummaryStep{},
+		Changesets: []int{},
+		started:    now,
+	}
+}
+
+// AddStep records a finished step; failed uploads count as failures
+func (s *RunSummary) AddStep(step SummaryStep) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	step.Failures = step.Counts["failed"]
+	s.Steps = append(s.Steps, step)
+	s.Failures += step.Failures
+}
+
+// AddChangeset records a changeset created by the run
+func (s *RunSummary) AddChangeset(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Changesets = append(s.Changesets, id)
+}
+
+// AddProblem records something a rerun has to finish, which makes the run partial
+func (s *RunSummary) AddProblem(problem string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Problems = append(s.Problems, problem)
+}
+
+// CountryResult totals the validate and upload steps of a country, for the completion
+// notification of a single-country run
+func (s *RunSummary) CountryResult(country string) CountryResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := CountryResult{
+		Country:   country,
+		Workspace: DefaultWorkspace.Dir,
+		Success:   true,
+		Duration:  time.Since(s.started).Round(time.Second).String(),
+	}
+	for _, step := range s.Steps {
+		if step.Country != "" && step.Country != country {
+			continue
+		}
+		switch {
+		case step.Step == StepValidate:
+			result.ValidElements = 0
+			for key, count := range step.Counts {
+				if key != "invalid" {
+					result.ValidElements += count
+				}
+			}
+		case step.Step == StepUpload && !step.DryRun:
+			result.Uploaded += step.Counts["successful"]
+			result.FailedUploads += step.Counts["failed"]
+		}
+	}
+	return result
+}
+
+// Finish sets the outcome of the run from its error and returns the exit status
+func (s *RunSummary) Finish(ctx context.Context, err error) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case err != nil && ctx.Err() != nil:
+		s.Status, s.ExitCode = RunInterrupted, exitInterrupted
+	case err != nil:
+		s.Status, s.ExitCode = RunFatal, exitFatal
+	case s.Failures > 0 || len(s.Problems) > 0:
+		s.Status, s.ExitCode = RunPartial, exitPartial
+	default:
+		s.Status, s.ExitCode = RunComplete, exitComplete
+	}
+	if err != nil {
+		s.Error = err.Error()
+	}
+	s.FinishedAt = time.Now().UTC().Format(time.RFC3339)
+	s.DurationSeconds = time.Since(s.started).Round(time.Millisecond).Seconds()
+	return s.ExitCode
+}
+
+// Save writes the summary unless the invocation ran no step and did not fail, like help or
+// listing countries
+func (s *RunSummary) Save(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.Steps) == 0 && len(s.Changesets) == 0 && s.Status != RunFatal && s.Status != RunInterrupted {
+		return nil
+	}
+	return saveJSON(path, s)
+}
+
+// finishRun writes the run summary and returns the exit status of the run
+func finishRun(ctx context.Context, err error) int {
+	code := runSummary.Finish(ctx, err)
+	if saveErr := runSummary.Save(DefaultWorkspace.File(DefaultSummaryFile)); saveErr != nil {
+		pipelineLog.Warn("Failed to save run summary: %v", saveErr)
+	}
+	return code
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunSummaryFinish(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name       string
+		ctx        context.Context
+		failed     int
+		problem    string
+		err        error
+		wantStatus string
+		wantCode   int
+	}{
+		{"Complete", context.Background(), 0, "", nil, RunComplete, exitComplete},
+		{"Failed uploads", context.Background(), 3, "", nil, RunPartial, exitPartial},
+		{"Failed country", context.Background(), 0, "Moldova: timeout", nil, RunPartial, exitPartial},
+		{"Fatal error", context.Background(), 3, "", errors.New("boom"), RunFatal, exitFatal},
+		{"Interrupted", canceled, 0, "", errors.New("interrupted"), RunInterrupted, exitInterrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary := NewRunSummary([]string{"upload", "--dry-run"})
+			summary.AddStep(SummaryStep{Step: StepUpload, Counts: map[string]int{"successful": 5, "failed": tt.failed}})
+			summary.AddChangeset(42)
+			if tt.problem != "" {
+				summary.AddProblem(tt.problem)
+			}
+
+			if code := summary.Finish(tt.ctx, tt.err); code != tt.wantCode {
+				t.Errorf("Expected exit code %d, got %d", tt.wantCode, code)
+			}
+			if summary.Status != tt.wantStatus || summary.ExitCode != tt.wantCode {
+				t.Errorf("Expected status %s, got %s (%d)", tt.wantStatus, summary.Status, summary.ExitCode)
+			}
+			if summary.Failures != tt.failed || summary.Steps[0].Failures != tt.failed {
+				t.Errorf("Expected %d failures, got %d", tt.failed, summary.Failures)
+			}
+			if summary.Command != "upload" || len(summary.Changesets) != 1 || summary.FinishedAt == "" {
+				t.Errorf("Unexpected summary %+v", summary)
+			}
+		})
+	}
+}
+
+func TestRunSummarySave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.json")
+
+	idle := NewRunSummary([]string{"countries", "list"})
+	idle.Finish(context.Background(), nil)
+	if err := idle.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("Expected no summary for a run without steps, got %v", err)
+	}
+
+	failed := NewRunSummary([]string{"extract"})
+	failed.Finish(context.Background(), errors.New("overpass unavailable"))
+	if err := failed.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	var saved RunSummary
+	if err := loadJSON(path, &saved); err != nil {
+		t.Fatalf("Failed to read summary: %v", err)
+	}
+	if saved.Status != RunFatal || saved.Error != "overpass unavailable" || saved.Command != "extract" {
+		t.Errorf("Unexpected saved summary %s %q %q", saved.Status, saved.Error, saved.Command)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+const (
+	// demVerticalError is the stated vertical accuracy of SRTM on flat terrain, in meters
+	demVerticalError = 16.0
+	// demHorizontalError is the horizontal uncertainty of a 30 m DEM cell, in meters
+	demHorizontalError = 30.0
+	// metersPerDegreeLat is the length of one degree of latitude
+	metersPerDegreeLat = 111320.0
+)
+
+// SlopeScorer samples the DEM around elements to estimate the local terrain slope and
+// how far the elevation can be trusted
+type SlopeScorer struct {
+	Provider  BatchElevationProvider
+	Distance  float64 // meters between the element and each of its 4 neighbors
+	BatchSize int     // locations per provider request
+	RateLimit time.Duration
+}
+
+// NewSlopeScorer samples neighbors at distance meters with the given provider
+func NewSlopeScorer(provider BatchElevationProvider, distance float64, batchSize int) *SlopeScorer {
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+	return &SlopeScorer{Provider: provider, Distance: distance, BatchSize: batchSize}
+}
+
+// slopeNeighbors returns the points north, east, south and west of c at distance meters
+func slopeNeighbors(c Coordinates, distance float64) [4]Coordinates {
+	dLat := distance / metersPerDegreeLat
+	dLon := distance / (metersPerDegreeLat * math.Cos(c.Lat*math.Pi/180))
+	return [4]Coordinates{
+		{Lat: c.Lat + dLat, Lon: c.Lon},
+		{Lat: c.Lat, Lon: c.Lon + dLon},
+		{Lat: c.Lat - dLat, Lon: c.Lon},
+		{Lat: c.Lat, Lon: c.Lon - dLon},
+	}
+}
+
+// terrainSlope returns the slope in degrees from the elevations north, east, south and west
+// of a point, each distance meters away
+func terrainSlope(north, east, south, west, distance float64) float64 {
+	dzdx := (east - west) / (2 * distance)
+	dzdy := (north - south) / (2 * distance)
+	return math.Atan(math.Hypot(dzdx, dzdy)) * 180 / math.Pi
+}
+
+// slopeConfidence scores an elevation from 1 (flat terrain) towards 0 as the slope turns the
+// DEM's horizontal uncertainty into vertical error
+func slopeConfidence(slope float64) float64 {
+	expectedError := demVerticalError + demHorizontalError*math.Tan(slope*math.Pi/180)
+	return math.Round(demVerticalError/expectedError*100) / 100
+}
+
+// Score sets Slope and ElevationConfidence on the elements whose 4 neighbors all have DEM data
+func (s *SlopeScorer) Score(ctx context.Context, elements []OSMElement) (int, error) {
+	coords := NewCoordinateExtractor()
+	var locations []LocationRequest
+	var owners []int
+	for i := range elements {
+		c, ok := coords.Extract(elements[i])
+		if !ok {
+			continue
+		}
+		for _, neighbor := range slopeNeighbors(c, s.Distance) {
+			locations = append(locations, LocationRequest{Lat: neighbor.Lat, Lon: neighbor.Lon, Element: &elements[i]})
+			owners = append(owners, i)
+		}
+	}
+
+	samples := make([]*float64, len(locations))
+	for start := 0; start < len(locations); start += s.BatchSize {
+		end := start + s.BatchSize
+		if end > len(locations) {
+			end = len(locations)
+		}
+		if start > 0 {
+			if err := sleepContext(ctx, s.RateLimit); err != nil {
+				return 0, err
+			}
+		}
+		results, err := s.Provider.BatchGetElevations(ctx, locations[start:end])
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		if err != nil {
+			enrichLog.Warn("Slope sampling failed for %d locations: %v", end-start, err)
+			continue
+		}
+		for k, result := range results {
+			if start+k < end && result.Error == nil {
+				samples[start+k] = result.Elevation
+			}
+		}
+	}
+
+	scored := 0
+	for first := 0; first+3 < len(samples); first += 4 {
+		n, e, so, w := samples[first], samples[first+1], samples[first+2], samples[first+3]
+		if n == nil || e == nil || so == nil || w == nil {
+			continue
+		}
+		slope := math.Round(terrainSlope(*n, *e, *so, *w, s.Distance)*10) / 10
+		confidence := slopeConfidence(slope)
+		element := &elements[owners[first]]
+		element.Slope = &slope
+		element.ElevationConfidence = &confidence
+		scored++
+	}
+	return scored, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+// planeProvider returns elevations of a plane rising northwards by rise meters per meter
+type planeProvider struct {
+	rise float64
+}
+
+func (p planeProvider) BatchGetElevations(ctx context.Context, locations []LocationRequest) ([]BatchElevationResult, error) {
+	results := make([]BatchElevationResult, len(locations))
+	for i, loc := range locations {
+		elevation := 1000 + (loc.Lat-45)*metersPerDegreeLat*p.rise
+		results[i] = BatchElevationResult{Elevation: &elevation, Element: loc.Element}
+	}
+	return results, nil
+}
+
+func TestTerrainSlope(t *testing.T) {
+	tests := []struct {
+		name                     string
+		north, east, south, west float64
+		want                     float64
+	}{
+		{"Flat", 500, 500, 500, 500, 0},
+		{"45 degrees north", 530, 500, 470, 500, 45},
+		{"Diagonal", 530, 530, 470, 470, 54.7},
+	}
+	for _, tt := range tests {
+		got := terrainSlope(tt.north, tt.east, tt.south, tt.west, 30)
+		if math.Abs(got-tt.want) > 0.1 {
+			t.Errorf("%s: terrainSlope() = %.2f, want %.1f", tt.name, got, tt.want)
+		}
+	}
+
+	if slopeConfidence(0) != 1 {
+		t.Errorf("slopeConfidence(0) = %v, want 1", slopeConfidence(0))
+	}
+	if c := slopeConfidence(40); c >= 0.5 || c <= 0 {
+		t.Errorf("slopeConfidence(40) = %v, want a low score", c)
+	}
+}
+
+func TestSlopeScorerScore(t *testing.T) {
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Lat: 45, Lon: 25},
+		{Type: "way", ID: 2}, // no coordinates
+	}
+	scorer := NewSlopeScorer(planeProvider{rise: 1}, 30, 3)
+
+	scored, err := scorer.Score(context.Background(), elements)
+	if err != nil {
+		t.Fatalf("Score() error = %v", err)
+	}
+	if scored != 1 || elements[0].Slope == nil || math.Abs(*elements[0].Slope-45) > 0.1 {
+		t.Fatalf("Expected element 1 scored at 45°, got %d scored, slope %v", scored, elements[0].Slope)
+	}
+	if elements[0].ElevationConfidence == nil || *elements[0].ElevationConfidence != slopeConfidence(*elements[0].Slope) {
+		t.Errorf("ElevationConfidence = %v, want %v", elements[0].ElevationConfidence, slopeConfidence(45))
+	}
+	if elements[1].Slope != nil {
+		t.Error("Element without coordinates should not be scored")
+	}
+
+	validator := NewElevationValidator(0, 2600)
+	validator.MaxSlope = 35
+	elevation := 1000.0
+	elements[0].ElevationFetched = &elevation
+	if result := validator.ValidateElement(elements[0]); result.Valid {
+		t.Error("Expected element on 45° terrain to fail validation with a 35° maximum")
+	}
+}
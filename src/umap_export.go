@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultUMapFile is the GeoJSON of the validated elements styled for import into uMap
+const DefaultUMapFile = "output/umap.geojson"
+
+// umapCategoryColors gives each category the uMap marker color closest to its preview hue
+var umapCategoryColors = map[string]string{
+	"peaks":                "Red",
+	"alpine_huts":          "DarkOrange",
+	"shelters":             "Green",
+	"train_stations":       "DodgerBlue",
+	"other_accommodations": "DarkViolet",
+}
+
+// umapCollection is a GeoJSON feature collection with uMap layer options
+type umapCollection struct {
+	Type        string                 `json:"type"`
+	UMapOptions map[string]interface{} `json:"_umap_options"`
+	Features    []GeoJSONFeature       `json:"features"`
+}
+
+// umapDescription is the popup text of an element; uMap renders [[url|text]] as a link
+func umapDescription(category, ele string, element OSMElement) string {
+	lines := []string{"Category: " + category}
+	if ele != "" {
+		lines = append(lines, "Elevation: **"+ele+" m**")
+	}
+	lines = append(lines, fmt.Sprintf("[[%s|%s %d on OpenStreetMap]]", osmLink(element.Type, element.ID), element.Type, element.ID))
+	return strings.Join(lines, "\n")
+}
+
+// ExportUMapGeoJSON writes the validated elements as GeoJSON points that uMap styles by
+// category, with the elevation in the popup. Elements without coordinates are counted as skipped.
+func ExportUMapGeoJSON(data ValidatedData, outputFile string) (int, int, error) {
+	collection := umapCollection{
+		Type:        "FeatureCollection",
+		UMapOptions: map[string]interface{}{"name": "elevate-romania elevations"},
+		Features:    []GeoJSONFeature{},
+	}
+
+	extractor := NewCoordinateExtractor()
+	skipped := 0
+	for _, category := range categoryKeys {
+		for _, element := range data.Category(category).ValidElements {
+			coords, valid := extractor.Extract(element)
+			if !valid {
+				skipped++
+				continue
+			}
+
+			ele := element.Tags["ele"]
+			if ele == "" && element.ElevationFetched != nil {
+				ele = fmt.Sprintf("%.1f", *element.ElevationFetched)
+			}
+			name := elementName(element)
+			if name == "" {
+				name = fmt.Sprintf("%s/%d", element.Type, element.ID)
+			}
+			collection.Features = append(collection.Features, GeoJSONFeature{
+				Type: "Feature",
+				Geometry: GeoJSONGeometry{
+					Type:        "Point",
+					Coordinates: []float64{coords.Lon, coords.Lat},
+				},
+				Properties: map[string]interface{}{
+					"name":        name,
+					"description": umapDescription(category, ele, element),
+					"category":    category,
+					"ele":         ele,
+					"osm_link":    osmLink(element.Type, element.ID),
+					"_umap_options": map[string]interface{}{
+						"color": umapCategoryColors[category],
+					},
+				},
+			})
+		}
+	}
+
+	if err := saveJSON(outputFile, collection); err != nil {
+		return 0, 0, err
+	}
+	return len(collection.Features), skipped, nil
+}
+
+// runExportUMap writes the uMap GeoJSON of the validated data of a workspace
+func runExportUMap(ws Workspace) error {
+	exportLog.Banner("EXPORT UMAP - Writing GeoJSON for a uMap review map")
+
+	var data ValidatedData
+	validatedFile := ws.File(DefaultValidatedDataFile)
+	if err := loadPipelineFile(validatedFile, SchemaValidated, &data); err != nil {
+		return pipelineLoadError(validatedFile, "validate", err)
+	}
+
+	outputFile := ws.File(DefaultUMapFile)
+	count, skipped, err := ExportUMapGeoJSON(data, outputFile)
+	if err != nil {
+		return err
+	}
+	if skipped > 0 {
+		exportLog.Info("✓ %d elements saved to %s (%d without coordinates skipped)", count, outputFile, skipped)
+	} else {
+		exportLog.Info("✓ %d elements saved to %s", count, outputFile)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExportUMapGeoJSON(t *testing.T) {
+	elevation := 2505.3
+	data := ValidatedData{}
+	data.Peaks.ValidElements = []OSMElement{
+		{Type: "node", ID: 1, Lat: 45.6, Lon: 25.5, Tags: map[string]string{"name": "Vârful Omu", "ele": "2505"}, ElevationFetched: &elevation},
+		{Type: "way", ID: 3, Tags: map[string]string{}},
+	}
+	data.TrainStations.ValidElements = []OSMElement{
+		{Type: "way", ID: 2, Center: &OSMCenter{Lat: 44.4, Lon: 26.1}, Tags: map[string]string{}, ElevationFetched: &elevation},
+	}
+
+	outputFile := filepath.Join(t.TempDir(), "umap.geojson")
+	count, skipped, err := ExportUMapGeoJSON(data, outputFile)
+	if err != nil {
+		t.Fatalf("ExportUMapGeoJSON() error = %v", err)
+	}
+	if count != 2 || skipped != 1 {
+		t.Errorf("count, skipped = %d, %d; want 2, 1", count, skipped)
+	}
+
+	var collection umapCollection
+	if err := loadJSON(outputFile, &collection); err != nil {
+		t.Fatalf("loadJSON() error = %v", err)
+	}
+	if collection.UMapOptions["name"] == nil {
+		t.Error("collection has no uMap layer name")
+	}
+	if len(collection.Features) != 2 {
+		t.Fatalf("got %d features, want 2", len(collection.Features))
+	}
+
+	tests := []struct {
+		name        string
+		color       string
+		ele         string
+		description string
+	}{
+		{"Vârful Omu", "Red", "2505", "Category: peaks\nElevation: **2505 m**\n[[https://www.openstreetmap.org/node/1|node 1 on OpenStreetMap]]"},
+		{"way/2", "DodgerBlue", "2505.3", "Category: train_stations\nElevation: **2505.3 m**\n[[https://www.openstreetmap.org/way/2|way 2 on OpenStreetMap]]"},
+	}
+	for i, tt := range tests {
+		properties := collection.Features[i].Properties
+		if properties["name"] != tt.name {
+			t.Errorf("feature %d: name = %v, want %s", i, properties["name"], tt.name)
+		}
+		if properties["ele"] != tt.ele {
+			t.Errorf("feature %d: ele = %v, want %s", i, properties["ele"], tt.ele)
+		}
+		if properties["description"] != tt.description {
+			t.Errorf("feature %d: description = %q, want %q", i, properties["description"], tt.description)
+		}
+		options, _ := properties["_umap_options"].(map[string]interface{})
+		if options["color"] != tt.color {
+			t.Errorf("feature %d: color = %v, want %s", i, options["color"], tt.color)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultUndoLogFile is where pre-edit element snapshots are stored, one JSON entry per line
+const DefaultUndoLogFile = "output/undo_log.jsonl"
+
+// UndoEntry holds the pre-edit state of a single modified element
+type UndoEntry struct {
+	ChangesetID int    `json:"changeset_id"`
+	ElementType string `json:"element_type"`
+	ElementID   int64  `json:"element_id"`
+	Version     int    `json:"version"`
+	XML         string `json:"xml"`
+	RecordedAt  string `json:"recorded_at"`
+	// NotApplied marks an earlier snapshot of the same element version whose edit the API
+	// rejected; snapshots are logged before their edit is sent
+	NotApplied bool `json:"not_applied,omitempty"`
+}
+
+// UndoLog appends pre-edit element snapshots to a JSON Lines file
+type UndoLog struct {
+	path string
+}
+
+// NewUndoLog prepares the undo log at path; entries are appended to an existing log
+func NewUndoLog(path string) (*UndoLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create undo log directory: %v", err)
+	}
+	return &UndoLog{path: path}, nil
+}
+
+// Record appends the pre-edit XML of an element to the log immediately, so snapshots survive
+// an interrupted upload. Only the new entry is written, however large the log grows.
+func (l *UndoLog) Record(changesetID int, elementType string, elementID int64, version int, rawXML []byte) error {
+	return l.append(UndoEntry{
+		ChangesetID: changesetID,
+		ElementType: elementType,
+		ElementID:   elementID,
+		Version:     version,
+		XML:         string(rawXML),
+	})
+}
+
+// MarkNotApplied records that the edit of a logged snapshot was rejected, so the snapshot is
+// no longer loaded
+func (l *UndoLog) MarkNotApplied(changesetID int, elementType string, elementID int64, version int) error {
+	return l.append(UndoEntry{
+		ChangesetID: changesetID,
+		ElementType: elementType,
+		ElementID:   elementID,
+		Version:     version,
+		NotApplied:  true,
+	})
+}
+
+// append writes one entry as a JSON line
+func (l *UndoLog) append(entry UndoEntry) error {
+	entry.RecordedAt = time.Now().UTC().Format(time.RFC3339)
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadUndoEntries reads the snapshots recorded for a changeset, leaving out the ones marked
+// as not applied; a missing log has none
+func LoadUndoEntries(path string, changesetID int) ([]UndoEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []UndoEntry
+	decoder := json.NewDecoder(file)
+	for {
+		var entry UndoEntry
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return entries, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read undo log %s: %v", path, err)
+		}
+		if entry.ChangesetID != changesetID {
+			continue
+		}
+		if !entry.NotApplied {
+			entries = append(entries, entry)
+			continue
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			e := entries[i]
+			if e.ElementType == entry.ElementType && e.ElementID == entry.ElementID && e.Version == entry.Version {
+				entries = append(entries[:i], entries[i+1:]...)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUndoLogRecordAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "undo_log.jsonl")
+
+	undoLog, err := NewUndoLog(path)
+	if err != nil {
+		t.Fatalf("NewUndoLog() error = %v", err)
+	}
+
+	raw := []byte(`<osm><node id="1" version="2" lat="45.5" lon="25.5"><tag k="name" v="Cabana"/></node></osm>`)
+	if err := undoLog.Record(100, "node", 1, 2, raw); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+	if err := undoLog.Record(200, "way", 5, 7, []byte(`<osm/>`)); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	// A later upload appends to the same log
+	reopened, err := NewUndoLog(path)
+	if err != nil {
+		t.Fatalf("NewUndoLog() reopen error = %v", err)
+	}
+	if err := reopened.Record(100, "node", 3, 1, []byte(`<osm/>`)); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(content, []byte("\n")); lines != 3 {
+		t.Errorf("undo log has %d lines, want one per entry", lines)
+	}
+
+	entries, err := LoadUndoEntries(path, 100)
+	if err != nil {
+		t.Fatalf("LoadUndoEntries() error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 entries for changeset 100, got %d", len(entries))
+	}
+	if entries[0].XML != string(raw) {
+		t.Errorf("XML = %q, want %q", entries[0].XML, string(raw))
+	}
+	if entries[0].Version != 2 || entries[1].ElementID != 3 {
+		t.Errorf("entries = %+v", entries)
+	}
+
+	if entries, err := LoadUndoEntries(path, 300); err != nil || len(entries) != 0 {
+		t.Errorf("LoadUndoEntries(300) = %v, %v, want no entries", entries, err)
+	}
+	if entries, err := LoadUndoEntries(filepath.Join(t.TempDir(), "missing.jsonl"), 100); err != nil || len(entries) != 0 {
+		t.Errorf("LoadUndoEntries(missing) = %v, %v, want no entries", entries, err)
+	}
+}
+
+func TestLoadUndoEntriesSkipsNotApplied(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "undo_log.jsonl")
+	undoLog, err := NewUndoLog(path)
+	if err != nil {
+		t.Fatalf("NewUndoLog() error = %v", err)
+	}
+
+	// Node 1 v2 was rejected and re-staged at v3; node 2 was applied
+	steps := []func() error{
+		func() error { return undoLog.Record(100, "node", 1, 2, []byte(`<osm/>`)) },
+		func() error { return undoLog.Record(100, "node", 2, 1, []byte(`<osm/>`)) },
+		func() error { return undoLog.MarkNotApplied(100, "node", 1, 2) },
+		func() error { return undoLog.Record(100, "node", 1, 3, []byte(`<osm/>`)) },
+		func() error { return undoLog.MarkNotApplied(200, "node", 2, 1) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("undo log write error = %v", err)
+		}
+	}
+
+	entries, err := LoadUndoEntries(path, 100)
+	if err != nil {
+		t.Fatalf("LoadUndoEntries() error = %v", err)
+	}
+	if len(entries) != 2 || entries[0].ElementID != 2 || entries[1].ElementID != 1 || entries[1].Version != 3 {
+		t.Errorf("entries = %+v, want node 2 v1 and node 1 v3", entries)
+	}
+}
@@ -1,16 +1,67 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	// MaxBoundingBoxDiagonal is the maximum diagonal distance (in degrees) for a changeset
-	// OSM typically allows up to 0.5 degrees, but we use 0.25 to be conservative
-	// 0.25 degrees is approximately 28km at the equator
-	MaxBoundingBoxDiagonal = 0.25
+	// MaxBoundingBoxDiagonal is the default maximum diagonal distance (in kilometers) for a changeset
+	// OSM typically allows up to about 0.5 degrees, but we use 28 km (0.25 degrees at the
+	// equator) to be conservative
+	MaxBoundingBoxDiagonal = 28.0
+	// MaxAllowedBoundingBoxDiagonal is the largest accepted MAX_BBOX_DIAGONAL_KM, about
+	// 0.5 degrees at the equator; larger changesets are rejected or flagged by reviewers
+	MaxAllowedBoundingBoxDiagonal = 55.0
+)
+
+// resolveMaxBBoxDiagonal reads MAX_BBOX_DIAGONAL_KM, which must lie in (0, MaxAllowedBoundingBoxDiagonal]
+func resolveMaxBBoxDiagonal(config *Config) (float64, error) {
+	value := config.Get("MAX_BBOX_DIAGONAL_KM")
+	if value == "" {
+		return MaxBoundingBoxDiagonal, nil
+	}
+	diagonal, err := strconv.ParseFloat(value, 64)
+	if err != nil || diagonal <= 0 || diagonal > MaxAllowedBoundingBoxDiagonal {
+		return MaxBoundingBoxDiagonal, fmt.Errorf("invalid MAX_BBOX_DIAGONAL_KM %q (expected more than 0 and at most %.0f km)", value, MaxAllowedBoundingBoxDiagonal)
+	}
+	return diagonal, nil
+}
+
+// maxUploadConcurrency bounds UPLOAD_CONCURRENCY so parallel element updates stay polite to the OSM API
+const maxUploadConcurrency = 8
+
+// resolveUploadConcurrency reads UPLOAD_CONCURRENCY, the number of elements updated in parallel
+// within one changeset in element mode
+func resolveUploadConcurrency(config *Config) (int, error) {
+	value := config.Get("UPLOAD_CONCURRENCY")
+	if value == "" {
+		return 1, nil
+	}
+	concurrency, err := strconv.Atoi(value)
+	if err != nil || concurrency < 1 || concurrency > maxUploadConcurrency {
+		return 1, fmt.Errorf("invalid UPLOAD_CONCURRENCY %q (expected 1 to %d)", value, maxUploadConcurrency)
+	}
+	return concurrency, nil
+}
+
+// Upload modes
+const (
+	// UploadModeDiff uploads each cluster as a single osmChange document
+	UploadModeDiff = "diff"
+	// UploadModeElement fetches and PUTs every element individually
+	UploadModeElement = "element"
 )
 
 // OSMUploader handles uploading changes to OpenStreetMap
@@ -20,14 +71,63 @@ type OSMUploader struct {
 	apiClient        *OSMAPIClient
 	dryRun           bool
 	country          string
+	region           string
+	commentTemplate  string
+	maxEdits         int     // maximum elements per changeset, 0 = unlimited
+	maxBBoxDiagonal  float64 // kilometers
+	concurrency      int     // elements updated in parallel in element mode
+	expectedVersions map[string]int
+	proposedTags     map[string]map[string]string
+	undoLog          *UndoLog
+	ledger           *RunLedger
+	runState         *CountryRunState
+	skipUploaded     bool
+	mode             string
+	dryRunDiff       *DryRunDiffReport
+	eleFormat        ElevationFormat
+	eleSource        ElevationSource
+	overwrite        OverwritePolicy
+
+	// stateMu guards the run ledger, undo log and dry-run report when elements upload in parallel
+	stateMu sync.Mutex
+	// failures aborts the upload after too many consecutive failures
+	failures failureStreak
+	// requiredUser is the only OSM display name allowed to upload, "" allows any account
+	requiredUser string
+	// changesetLog records every created changeset; created lists those of this upload
+	changesetLog *ChangesetLog
+	created      []ChangesetRecord
+}
+
+// UploadOptions configures the upload step
+type UploadOptions struct {
+	DryRun      bool
+	Country     string
+	Incremental bool
+	// Reupload ignores the run ledger and edits elements that were already uploaded
+	Reupload bool
+	Mode     string
+	// RetryErrors limits the upload to elements that failed with these error classes in the previous run
+	RetryErrors []string
+	// FromErrorLog uploads the failures of the upload errors file, filtered by RetryErrors,
+	// instead of the validated data
+	FromErrorLog bool
+	// Area is the area selected for the run, which determines its run ledger state
+	Area AreaSelector
+	// Workspace holds the validated data, run ledger, undo log and upload results
+	Workspace Workspace
+	// Review asks for every pending edit to be accepted or rejected in the terminal
+	Review bool
 }
 
 // UploadStats contains statistics about uploads
 type UploadStats struct {
-	Total      int           `json:"total"`
-	Successful int           `json:"successful"`
-	Failed     int           `json:"failed"`
-	Errors     []UploadError `json:"errors"`
+	Total         int           `json:"total"`
+	Successful    int           `json:"successful"`
+	Failed        int           `json:"failed"`
+	Skipped       int           `json:"skipped"`
+	AlreadyHasEle int           `json:"already_has_ele"` // live ele kept by the overwrite policy
+	Errors        []UploadError `json:"errors"`
 }
 
 // UploadError represents an error during upload
@@ -35,23 +135,39 @@ type UploadError struct {
 	ElementType string `json:"element_type"`
 	ElementID   int64  `json:"element_id"`
 	Error       string `json:"error"`
+	Category    string `json:"category"`
 }
 
 // NewOSMUploader creates a new OSM uploader
-func NewOSMUploader(oauthConfig *OAuthConfig, dryRun bool, country string) (*OSMUploader, error) {
+func NewOSMUploader(oauthConfig *OAuthConfig, dryRun bool, country, undoLogFile string) (*OSMUploader, error) {
+	config := NewConfig()
+	config.LoadFromEnv()
+
 	uploader := &OSMUploader{
-		dryRun:  dryRun,
-		country: country,
+		dryRun:    dryRun,
+		country:   country,
+		mode:      UploadModeDiff,
+		maxEdits:  config.GetInt("MAX_EDITS_PER_CHANGESET"),
+		eleFormat: elevationFormatOrDefault(config),
+		eleSource: elevationSourceOrDefault(config),
+		overwrite: overwritePolicyOrDefault(config),
 	}
+	// An invalid value was reported by runUpload; fall back to the default
+	uploader.maxBBoxDiagonal, _ = resolveMaxBBoxDiagonal(config)
+	uploader.concurrency, _ = resolveUploadConcurrency(config)
+	uploader.failures.limit, _ = resolveMaxConsecutiveFailures(config)
+	uploader.requiredUser = strings.TrimSpace(config.Get("REQUIRE_USER"))
 
 	if dryRun {
-		fmt.Println("Running in DRY-RUN mode - no changes will be uploaded")
+		uploadLog.Info("Running in DRY-RUN mode - no changes will be uploaded")
 		uploader.changesetManager = NewChangesetManager(nil, true)
-		uploader.apiClient = NewOSMAPIClient(nil, true)
+		// Reading elements needs no authentication; the live tags show what would change
+		uploader.apiClient = NewOSMAPIClient(&http.Client{Timeout: 30 * time.Second}, true)
+		uploader.dryRunDiff = NewDryRunDiffReport(country)
 		return uploader, nil
 	}
 
-	if oauthConfig.AccessToken == "" {
+	if !oauthConfig.HasToken() {
 		return nil, fmt.Errorf("OAuth access token required for actual upload")
 	}
 
@@ -61,18 +177,35 @@ func NewOSMUploader(oauthConfig *OAuthConfig, dryRun bool, country string) (*OSM
 		return nil, fmt.Errorf("failed to create OAuth client: %v", err)
 	}
 
+	undoLog, err := NewUndoLog(undoLogFile)
+	if err != nil {
+		return nil, err
+	}
+
 	uploader.client = client
 	uploader.changesetManager = NewChangesetManager(client, false)
 	uploader.apiClient = NewOSMAPIClient(client, false)
+	uploader.undoLog = undoLog
 
-	fmt.Println("Connected to OSM API with OAuth 2.0")
+	uploadLog.Info("Connected to OSM API with OAuth 2.0")
 
 	return uploader, nil
 }
 
+// ParseUploadMode validates an upload mode name
+func ParseUploadMode(value string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(value)); mode {
+	case "":
+		return UploadModeDiff, nil
+	case UploadModeDiff, UploadModeElement:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown upload mode %q (expected diff or element)", value)
+}
+
 // CreateChangeset creates a new changeset
-func (u *OSMUploader) CreateChangeset(comment string) error {
-	return u.changesetManager.Create(comment)
+func (u *OSMUploader) CreateChangeset(ctx context.Context, comment string) error {
+	return u.changesetManager.Create(ctx, comment)
 }
 
 // CloseChangeset closes the current changeset
@@ -81,92 +214,408 @@ func (u *OSMUploader) CloseChangeset() error {
 }
 
 // UploadElement uploads a single element to OSM
-func (u *OSMUploader) UploadElement(element OSMElement) (bool, string) {
+func (u *OSMUploader) UploadElement(ctx context.Context, element OSMElement) error {
 	elementType := element.Type
 	elementID := element.ID
-	tags := element.Tags
 
-	if tags == nil || tags["ele"] == "" || tags["ele:source"] == "" {
-		return false, "Missing elevation data in tags"
+	newTags, err := u.newTags(element)
+	if err != nil {
+		return err
 	}
 
-	eleValue := tags["ele"]
+	eleValue := newTags["ele"]
 
 	if u.dryRun {
-		fmt.Printf("[DRY-RUN] Would update %s %d:\n", elementType, elementID)
-		fmt.Printf("  ele=%s, ele:source=SRTM\n", eleValue)
-		return true, "Dry-run successful"
+		return u.previewElement(ctx, element, newTags)
 	}
 
 	// Get changeset ID
 	if !u.changesetManager.IsOpen() {
-		return false, "No active changeset"
+		return fmt.Errorf("no active changeset")
 	}
 	changesetID := u.changesetManager.GetID()
 
-	// Prepare new tags to merge
-	newTags := map[string]string{
-		"ele":        eleValue,
-		"ele:source": "SRTM",
+	// Fetch current element and update it, trying again after transient failures
+	err = retryTransient(ctx, fmt.Sprintf("%s %d", elementType, elementID), func() error {
+		if elementType == "node" {
+			return u.uploadNode(ctx, elementID, newTags, changesetID)
+		} else if elementType == "way" {
+			return u.uploadWay(ctx, elementID, newTags, changesetID)
+		} else if elementType == "relation" {
+			return u.uploadRelation(ctx, elementID, newTags, changesetID)
+		}
+		return fmt.Errorf("%w: unsupported element type: %s", ErrInvalidUpload, elementType)
+	})
+
+	if err != nil {
+		return fmt.Errorf("upload failed: %w", err)
 	}
 
-	// Fetch current element and update it
-	var err error
-	if elementType == "node" {
-		err = u.uploadNode(elementID, newTags, changesetID)
-	} else if elementType == "way" {
-		err = u.uploadWay(elementID, newTags, changesetID)
-	} else {
-		return false, fmt.Sprintf("Unsupported element type: %s", elementType)
+	uploadLog.Info("✓ Updated %s %d with ele=%s", elementType, elementID, eleValue)
+	return nil
+}
+
+// elevationTags returns the tags an upload merges into an element, with ele rounded to format
+// so files enriched with another precision upload consistently, and the recorded source under
+// the configured key
+func elevationTags(element OSMElement, format ElevationFormat, source ElevationSource) (map[string]string, error) {
+	if _, recorded := eleSourceTag(element.Tags); element.Tags["ele"] == "" || recorded == "" {
+		return nil, fmt.Errorf("%w: missing elevation data in tags", ErrInvalidUpload)
 	}
 
-	if err != nil {
-		return false, fmt.Sprintf("Upload failed: %v", err)
+	ele := element.Tags["ele"]
+	if element.ElevationFetched != nil {
+		ele = format.Format(*element.ElevationFetched)
+	}
+	return map[string]string{
+		"ele":      ele,
+		source.Key: source.Of(element),
+	}, nil
+}
+
+// newTags returns the tags to merge into an element: the signed tags of the proposal being
+// applied, or the elevation tags computed with the current settings
+func (u *OSMUploader) newTags(element OSMElement) (map[string]string, error) {
+	if u.proposedTags == nil {
+		return elevationTags(element, u.eleFormat, u.eleSource)
+	}
+	tags, ok := u.proposedTags[elementKey(element.Type, element.ID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s %d is not part of the proposal", ErrInvalidUpload, element.Type, element.ID)
+	}
+	if tags["ele"] == "" {
+		return nil, fmt.Errorf("%w: missing elevation data in the proposal", ErrInvalidUpload)
+	}
+	return tags, nil
+}
+
+// checkChildCount refuses to send an element back with a different number of child elements
+// (way nd refs, relation members) than the fetched XML holds: a truncated parse would
+// otherwise destroy the element's geometry
+func checkChildCount(elementType string, elementID int64, snapshot []byte, child string, sent int) error {
+	fetched := 0
+	decoder := xml.NewDecoder(bytes.NewReader(snapshot))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: cannot verify %s %d: %v", ErrInvalidUpload, elementType, elementID, err)
+		}
+		if start, ok := token.(xml.StartElement); ok && start.Name.Local == child {
+			fetched++
+		}
+	}
+
+	if sent != fetched {
+		return fmt.Errorf("%w: %s %d would be sent with %d %s elements but %d were fetched", ErrInvalidUpload, elementType, elementID, sent, child, fetched)
+	}
+	if elementType == "way" && sent < 2 {
+		return fmt.Errorf("%w: way %d has only %d nodes", ErrInvalidUpload, elementID, sent)
 	}
+	return nil
+}
+
+// maxConflictRetries bounds how often an element is re-fetched and re-merged after
+// the OSM API rejects an update with HTTP 409 (edited by someone else meanwhile)
+const maxConflictRetries = 3
+
+// isVersionConflict reports whether err is an HTTP 409 version conflict from the OSM API
+func isVersionConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusConflict
+}
+
+// diffConflictPattern finds the element in the 409 message of a diff upload, e.g.
+// "Version mismatch: Provided 2, server had: 3 of Node 4326"
+var diffConflictPattern = regexp.MustCompile(`(?i)\bof (node|way|relation) (\d+)`)
 
-	fmt.Printf("✓ Updated %s %d with ele=%s\n", elementType, elementID, eleValue)
-	return true, "Upload successful"
+// diffConflictElement returns the element a version conflict of a diff upload names
+func diffConflictElement(err error) (string, int64, bool) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return "", 0, false
+	}
+	match := diffConflictPattern.FindStringSubmatch(apiErr.Body)
+	if match == nil {
+		return "", 0, false
+	}
+	id, parseErr := strconv.ParseInt(match[2], 10, 64)
+	if parseErr != nil {
+		return "", 0, false
+	}
+	return strings.ToLower(match[1]), id, true
 }
 
-// uploadNode fetches and updates a node
-func (u *OSMUploader) uploadNode(nodeID int64, newTags map[string]string, changesetID int) error {
+// retryOnConflict runs update again while it fails with a version conflict, up to maxConflictRetries times
+func retryOnConflict(elementType string, elementID int64, update func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := update()
+		if err == nil || !isVersionConflict(err) || attempt > maxConflictRetries {
+			return err
+		}
+		uploadLog.Warn("Version conflict on %s %d, re-fetching and retrying (%d/%d)", elementType, elementID, attempt, maxConflictRetries)
+	}
+}
+
+// uploadNode fetches and updates a node, retrying with the latest version on conflicts
+func (u *OSMUploader) uploadNode(ctx context.Context, nodeID int64, newTags map[string]string, changesetID int) error {
+	return retryOnConflict("node", nodeID, func() error {
+		return u.updateNodeOnce(ctx, nodeID, newTags, changesetID)
+	})
+}
+
+// updateNodeOnce fetches the current node, merges the tags and updates it
+func (u *OSMUploader) updateNodeOnce(ctx context.Context, nodeID int64, newTags map[string]string, changesetID int) error {
 	// Fetch current node
-	node, err := u.apiClient.FetchNode(nodeID)
+	node, snapshot, err := u.apiClient.FetchNodeSnapshot(ctx, nodeID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch node: %v", err)
+		return fmt.Errorf("failed to fetch node: %w", err)
 	}
 
+	if err := u.checkExpectedVersion("node", nodeID, node.Version); err != nil {
+		return err
+	}
+	if err := u.overwrite.Check("node", nodeID, node.Tags, newTags["ele"]); err != nil {
+		return err
+	}
+
+	preEditVersion := node.Version
+
 	// Merge tags
 	node.Tags = MergeTags(node.Tags, newTags)
 
-	// Update node
-	if err := u.apiClient.UpdateNode(node, changesetID); err != nil {
-		return fmt.Errorf("failed to update node: %v", err)
+	// The snapshot is logged before the update, so an edit interrupted after the PUT can still be reverted
+	if err := u.recordUndo(changesetID, "node", nodeID, preEditVersion, snapshot); err != nil {
+		return err
 	}
 
+	// Update node
+	if err := u.apiClient.UpdateNode(ctx, node, changesetID); err != nil {
+		if editNotApplied(err) {
+			u.discardUndo(changesetID, "node", nodeID, preEditVersion)
+		}
+		return fmt.Errorf("failed to update node: %w", err)
+	}
 	return nil
 }
 
-// uploadWay fetches and updates a way
-func (u *OSMUploader) uploadWay(wayID int64, newTags map[string]string, changesetID int) error {
+// uploadWay fetches and updates a way, retrying with the latest version on conflicts
+func (u *OSMUploader) uploadWay(ctx context.Context, wayID int64, newTags map[string]string, changesetID int) error {
+	return retryOnConflict("way", wayID, func() error {
+		return u.updateWayOnce(ctx, wayID, newTags, changesetID)
+	})
+}
+
+// updateWayOnce fetches the current way, merges the tags and updates it
+func (u *OSMUploader) updateWayOnce(ctx context.Context, wayID int64, newTags map[string]string, changesetID int) error {
 	// Fetch current way
-	way, err := u.apiClient.FetchWay(wayID)
+	way, snapshot, err := u.apiClient.FetchWaySnapshot(ctx, wayID)
 	if err != nil {
-		return fmt.Errorf("failed to fetch way: %v", err)
+		return fmt.Errorf("failed to fetch way: %w", err)
 	}
 
+	if err := u.checkExpectedVersion("way", wayID, way.Version); err != nil {
+		return err
+	}
+	if err := u.overwrite.Check("way", wayID, way.Tags, newTags["ele"]); err != nil {
+		return err
+	}
+
+	preEditVersion := way.Version
+
 	// Merge tags
 	way.Tags = MergeTags(way.Tags, newTags)
+	if err := checkChildCount("way", wayID, snapshot, "nd", len(way.Nodes)); err != nil {
+		return err
+	}
+
+	// The snapshot is logged before the update, so an edit interrupted after the PUT can still be reverted
+	if err := u.recordUndo(changesetID, "way", wayID, preEditVersion, snapshot); err != nil {
+		return err
+	}
 
 	// Update way
-	if err := u.apiClient.UpdateWay(way, changesetID); err != nil {
-		return fmt.Errorf("failed to update way: %v", err)
+	if err := u.apiClient.UpdateWay(ctx, way, changesetID); err != nil {
+		if editNotApplied(err) {
+			u.discardUndo(changesetID, "way", wayID, preEditVersion)
+		}
+		return fmt.Errorf("failed to update way: %w", err)
 	}
+	return nil
+}
 
+// uploadRelation fetches and updates a relation, retrying with the latest version on conflicts
+func (u *OSMUploader) uploadRelation(ctx context.Context, relationID int64, newTags map[string]string, changesetID int) error {
+	return retryOnConflict("relation", relationID, func() error {
+		return u.updateRelationOnce(ctx, relationID, newTags, changesetID)
+	})
+}
+
+// updateRelationOnce fetches the current relation, merges the tags and updates it
+func (u *OSMUploader) updateRelationOnce(ctx context.Context, relationID int64, newTags map[string]string, changesetID int) error {
+	// Fetch current relation
+	relation, snapshot, err := u.apiClient.FetchRelationSnapshot(ctx, relationID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch relation: %w", err)
+	}
+
+	if err := u.checkExpectedVersion("relation", relationID, relation.Version); err != nil {
+		return err
+	}
+	if err := u.overwrite.Check("relation", relationID, relation.Tags, newTags["ele"]); err != nil {
+		return err
+	}
+
+	preEditVersion := relation.Version
+
+	// Merge tags
+	relation.Tags = MergeTags(relation.Tags, newTags)
+	if err := checkChildCount("relation", relationID, snapshot, "member", len(relation.Members)); err != nil {
+		return err
+	}
+
+	// The snapshot is logged before the update, so an edit interrupted after the PUT can still be reverted
+	if err := u.recordUndo(changesetID, "relation", relationID, preEditVersion, snapshot); err != nil {
+		return err
+	}
+
+	// Update relation
+	if err := u.apiClient.UpdateRelation(ctx, relation, changesetID); err != nil {
+		if editNotApplied(err) {
+			u.discardUndo(changesetID, "relation", relationID, preEditVersion)
+		}
+		return fmt.Errorf("failed to update relation: %w", err)
+	}
 	return nil
 }
 
-func (u *OSMUploader) UploadElements(elements []OSMElement, categoryName string) UploadStats {
+// prefetchElements fetches the elements of a cluster with one request per element type
+// instead of one per element. On failure the elements are fetched one by one.
+func (u *OSMUploader) prefetchElements(ctx context.Context, elements []OSMElement) {
+	ids := make(map[string][]int64)
+	for _, element := range elements {
+		if !u.alreadyUploaded(element) {
+			ids[element.Type] = append(ids[element.Type], element.ID)
+		}
+	}
+	for _, elementType := range []string{"node", "way", "relation"} {
+		if len(ids[elementType]) < 2 {
+			continue
+		}
+		if err := u.apiClient.Prefetch(ctx, elementType, ids[elementType]); err != nil {
+			uploadLog.Warn("Batch fetch of %d %ss failed, fetching them one by one: %v", len(ids[elementType]), elementType, err)
+		}
+	}
+}
+
+// recordUndo stores the pre-edit snapshot of an element about to be modified in the undo log.
+// The edit must not be sent when this fails, as it could not be reverted.
+func (u *OSMUploader) recordUndo(changesetID int, elementType string, elementID int64, version int, snapshot []byte) error {
+	if u.undoLog == nil {
+		return nil
+	}
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+	if err := u.undoLog.Record(changesetID, elementType, elementID, version, snapshot); err != nil {
+		return fmt.Errorf("failed to record undo snapshot: %v", err)
+	}
+	return nil
+}
+
+// discardUndo marks the snapshot of an edit the API rejected as not applied
+func (u *OSMUploader) discardUndo(changesetID int, elementType string, elementID int64, version int) {
+	if u.undoLog == nil {
+		return
+	}
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+	if err := u.undoLog.MarkNotApplied(changesetID, elementType, elementID, version); err != nil {
+		uploadLog.Warn("Failed to update undo log for %s %d: %v", elementType, elementID, err)
+	}
+}
+
+// editNotApplied reports whether an upload error means the edit certainly did not reach the
+// database: the API answered with an error, or the request was never sent. After a network
+// error the edit may have been applied, so its snapshot is kept.
+func editNotApplied(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) || errors.Is(err, ErrBudgetExhausted)
+}
+
+// recordStagedUndo logs the snapshots of the staged edits before their diff is sent. An edit
+// whose snapshot cannot be written is dropped from the diff and counted as failed.
+func (u *OSMUploader) recordStagedUndo(changesetID int, change *OSMChange, staged []stagedEdit, results map[string]UploadStats) []stagedEdit {
+	kept := staged[:0]
+	for _, edit := range staged {
+		if err := u.recordUndo(changesetID, edit.element.Type, edit.element.ID, edit.version, edit.snapshot); err != nil {
+			change.Remove(edit.element.Type, edit.element.ID)
+			stats := results[edit.categoryKey]
+			stats.Failed++
+			stats.Errors = append(stats.Errors, NewUploadError(edit.element.Type, edit.element.ID, err))
+			results[edit.categoryKey] = stats
+			continue
+		}
+		kept = append(kept, edit)
+	}
+	return kept
+}
+
+// alreadyUploaded reports whether the run ledger records the element as uploaded
+func (u *OSMUploader) alreadyUploaded(element OSMElement) bool {
+	if !u.skipUploaded || u.runState == nil {
+		return false
+	}
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+	return u.runState.IsUploaded(element.Type, element.ID)
+}
+
+// markUploaded records successful edits in the run ledger and persists it immediately,
+// so an interrupted upload can resume without editing the same elements again
+func (u *OSMUploader) markUploaded(elements ...OSMElement) {
+	if u.dryRun || u.runState == nil {
+		return
+	}
+	u.stateMu.Lock()
+	defer u.stateMu.Unlock()
+	for _, element := range elements {
+		u.runState.MarkUploaded(element.Type, element.ID)
+	}
+	if u.ledger != nil {
+		if err := u.ledger.Save(); err != nil {
+			uploadLog.Warn("Failed to save run ledger: %v", err)
+		}
+	}
+}
+
+// useProposal restricts the upload to the edits of a proposal: each element must still be at
+// the version it was proposed against and receives exactly the proposed tags
+func (u *OSMUploader) useProposal(proposal *Proposal) {
+	u.expectedVersions = proposal.ExpectedVersions()
+	u.proposedTags = proposal.ProposedTags()
+}
+
+// checkExpectedVersion refuses an update when the upstream version differs from the proposed one
+func (u *OSMUploader) checkExpectedVersion(elementType string, elementID int64, version int) error {
+	if u.expectedVersions == nil {
+		return nil
+	}
+	expected, ok := u.expectedVersions[elementKey(elementType, elementID)]
+	if !ok {
+		return fmt.Errorf("%w: %s %d is not part of the proposal", ErrInvalidUpload, elementType, elementID)
+	}
+	if expected != version {
+		return fmt.Errorf("%w (proposed against v%d, now v%d)", ErrVersionConflict, expected, version)
+	}
+	return nil
+}
+
+// UploadElements uploads elements one by one, or u.concurrency at a time. Requests are paced
+// by the shared per-host rate limiter. When ctx is canceled the remaining elements are left
+// for the next run; the elements in flight are always finished.
+func (u *OSMUploader) UploadElements(ctx context.Context, elements []OSMElement, categoryName string) UploadStats {
 	stats := UploadStats{
 		Total:      len(elements),
 		Successful: 0,
@@ -178,34 +627,349 @@ func (u *OSMUploader) UploadElements(elements []OSMElement, categoryName string)
 		return stats
 	}
 
-	fmt.Printf("\nUploading %s...\n", categoryName)
+	uploadLog.Info("Uploading %s...", categoryName)
+
+	workers := u.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	var mu sync.Mutex
+	processed := 0
+	jobs := make(chan OSMElement)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for element := range jobs {
+				// Elements handed out before the streak tripped are left for the next run
+				if u.failures.tripped() {
+					continue
+				}
+				err := u.UploadElement(ctx, element)
+
+				mu.Lock()
+				if errors.Is(err, ErrAlreadyHasEle) {
+					uploadLog.Info("Skipping %s %d: %v", element.Type, element.ID, err)
+					stats.AlreadyHasEle++
+					u.failures.record(nil)
+				} else if err != nil {
+					u.failures.record(err)
+					stats.Failed++
+					stats.Errors = append(stats.Errors, NewUploadError(element.Type, element.ID, err))
+				} else {
+					stats.Successful++
+					u.failures.record(nil)
+				}
+
+				// Progress update
+				processed++
+				if processed%10 == 0 {
+					uploadLog.Info("Progress: %d/%d", processed, len(elements))
+				}
+				mu.Unlock()
+
+				if err == nil {
+					u.markUploaded(element)
+				}
+
+				// Rate limiting
+				if !u.dryRun {
+					time.Sleep(time.Millisecond * 10)
+				}
+			}
+		}()
+	}
 
 	for i, element := range elements {
-		success, message := u.UploadElement(element)
+		if ctx.Err() != nil {
+			uploadLog.Warn("Interrupted, leaving %d elements for the next run", len(elements)-i)
+			break
+		}
+		if u.failures.tripped() {
+			uploadLog.Error("Too many consecutive failures, leaving the remaining elements for the next run")
+			break
+		}
+
+		if u.alreadyUploaded(element) {
+			mu.Lock()
+			stats.Skipped++
+			processed++
+			mu.Unlock()
+			continue
+		}
+		jobs <- element
+	}
+	close(jobs)
+	wg.Wait()
+
+	return stats
+}
+
+// categoryElements pairs a stats key with the elements of that category
+type categoryElements struct {
+	key      string
+	elements []OSMElement
+}
+
+// stagedEdit is an element modification added to an osmChange document
+type stagedEdit struct {
+	categoryKey string
+	element     OSMElement
+	version     int
+	snapshot    []byte
+}
+
+// stageElement fetches the current element, merges the elevation tags and adds it to the change
+func (u *OSMUploader) stageElement(ctx context.Context, element OSMElement, changesetID int, change *OSMChange) (stagedEdit, error) {
+	edit := stagedEdit{element: element}
+
+	newTags, err := u.newTags(element)
+	if err != nil {
+		return edit, err
+	}
 
-		if success {
-			stats.Successful++
-		} else {
+	switch element.Type {
+	case "node":
+		node, snapshot, err := u.apiClient.FetchNodeSnapshot(ctx, element.ID)
+		if err != nil {
+			return edit, fmt.Errorf("failed to fetch node: %w", err)
+		}
+		if err := u.checkExpectedVersion("node", element.ID, node.Version); err != nil {
+			return edit, err
+		}
+		if err := u.overwrite.Check("node", element.ID, node.Tags, newTags["ele"]); err != nil {
+			return edit, err
+		}
+		edit.version = node.Version
+		edit.snapshot = snapshot
+		node.Tags = MergeTags(node.Tags, newTags)
+		node.Changeset = changesetID
+		change.ModifyNode(*node)
+	case "way":
+		way, snapshot, err := u.apiClient.FetchWaySnapshot(ctx, element.ID)
+		if err != nil {
+			return edit, fmt.Errorf("failed to fetch way: %w", err)
+		}
+		if err := u.checkExpectedVersion("way", element.ID, way.Version); err != nil {
+			return edit, err
+		}
+		if err := u.overwrite.Check("way", element.ID, way.Tags, newTags["ele"]); err != nil {
+			return edit, err
+		}
+		edit.version = way.Version
+		edit.snapshot = snapshot
+		way.Tags = MergeTags(way.Tags, newTags)
+		if err := checkChildCount("way", element.ID, snapshot, "nd", len(way.Nodes)); err != nil {
+			return edit, err
+		}
+		way.Changeset = changesetID
+		change.ModifyWay(*way)
+	case "relation":
+		relation, snapshot, err := u.apiClient.FetchRelationSnapshot(ctx, element.ID)
+		if err != nil {
+			return edit, fmt.Errorf("failed to fetch relation: %w", err)
+		}
+		if err := u.checkExpectedVersion("relation", element.ID, relation.Version); err != nil {
+			return edit, err
+		}
+		if err := u.overwrite.Check("relation", element.ID, relation.Tags, newTags["ele"]); err != nil {
+			return edit, err
+		}
+		edit.version = relation.Version
+		edit.snapshot = snapshot
+		relation.Tags = MergeTags(relation.Tags, newTags)
+		if err := checkChildCount("relation", element.ID, snapshot, "member", len(relation.Members)); err != nil {
+			return edit, err
+		}
+		relation.Changeset = changesetID
+		change.ModifyRelation(*relation)
+	default:
+		return edit, fmt.Errorf("%w: unsupported element type: %s", ErrInvalidUpload, element.Type)
+	}
+
+	return edit, nil
+}
+
+// UploadClusterDiff stages all elements of a cluster into one osmChange document and uploads it
+// to the open changeset in a single request. The upload is atomic: either every staged edit is
+// applied or none is. When ctx is canceled while staging, nothing is uploaded.
+func (u *OSMUploader) UploadClusterDiff(ctx context.Context, groups []categoryElements) map[string]UploadStats {
+	results := make(map[string]UploadStats)
+
+	if u.dryRun {
+		for _, group := range groups {
+			results[group.key] = u.UploadElements(ctx, group.elements, group.key)
+		}
+		return results
+	}
+
+	changesetID := u.changesetManager.GetID()
+	change := NewOSMChange()
+	var staged []stagedEdit
+
+staging:
+	for _, group := range groups {
+		stats := UploadStats{Total: len(group.elements), Errors: []UploadError{}}
+
+		for _, element := range group.elements {
+			if ctx.Err() != nil {
+				uploadLog.Warn("Interrupted while staging, leaving this cluster for the next run")
+				return make(map[string]UploadStats)
+			}
+			if u.failures.tripped() {
+				uploadLog.Error("Too many consecutive failures, stopped staging")
+				results[group.key] = stats
+				break staging
+			}
+
+			if u.alreadyUploaded(element) {
+				stats.Skipped++
+				continue
+			}
+
+			if !u.changesetManager.IsOpen() {
+				stats.Failed++
+				stats.Errors = append(stats.Errors, NewUploadError(element.Type, element.ID, fmt.Errorf("no active changeset")))
+				continue
+			}
+
+			edit, err := u.stageElement(ctx, element, changesetID, change)
+			if errors.Is(err, ErrAlreadyHasEle) {
+				uploadLog.Info("Skipping %s %d: %v", element.Type, element.ID, err)
+				stats.AlreadyHasEle++
+				continue
+			}
+			if err != nil {
+				u.failures.record(err)
+				stats.Failed++
+				stats.Errors = append(stats.Errors, NewUploadError(element.Type, element.ID, fmt.Errorf("upload failed: %w", err)))
+				continue
+			}
+			edit.categoryKey = group.key
+			staged = append(staged, edit)
+		}
+
+		results[group.key] = stats
+	}
+
+	if len(staged) == 0 {
+		return results
+	}
+
+	// The snapshots are logged before the diff is sent, so an upload interrupted after the POST can still be reverted
+	if staged = u.recordStagedUndo(changesetID, change, staged, results); len(staged) == 0 {
+		return results
+	}
+
+	uploadLog.Info("Uploading osmChange with %d modifications to changeset #%d...", len(staged), changesetID)
+
+	err := u.postDiff(ctx, changesetID, change)
+	staged, err = u.resolveDiffConflicts(ctx, changesetID, change, staged, results, err)
+	// The diff is a single request, so it extends or resets the failure streak once
+	u.failures.record(err)
+	if len(staged) == 0 {
+		return results
+	}
+	if err != nil {
+		uploadLog.Warn("Diff upload failed, no elements were modified: %v", err)
+		for _, edit := range staged {
+			if editNotApplied(err) {
+				u.discardUndo(changesetID, edit.element.Type, edit.element.ID, edit.version)
+			}
+			stats := results[edit.categoryKey]
 			stats.Failed++
-			stats.Errors = append(stats.Errors, UploadError{
-				ElementType: element.Type,
-				ElementID:   element.ID,
-				Error:       message,
-			})
+			stats.Errors = append(stats.Errors, NewUploadError(edit.element.Type, edit.element.ID, fmt.Errorf("upload failed: %w", err)))
+			results[edit.categoryKey] = stats
 		}
+		return results
+	}
+
+	uploaded := make([]OSMElement, 0, len(staged))
+	for _, edit := range staged {
+		stats := results[edit.categoryKey]
+		stats.Successful++
+		results[edit.categoryKey] = stats
+
+		uploaded = append(uploaded, edit.element)
+	}
+	u.markUploaded(uploaded...)
+
+	uploadLog.Info("✓ Uploaded %d modifications in a single diff", len(staged))
+	return results
+}
 
-		// Progress update
-		if (i+1)%10 == 0 {
-			fmt.Printf("Progress: %d/%d\n", i+1, len(elements))
+// resolveDiffConflicts handles the HTTP 409 of a diff upload. The OSM API names the element
+// edited by someone else meanwhile; it is re-fetched and staged again against its new version,
+// or dropped when it now has ele or keeps conflicting, and the diff is posted again. It returns
+// the edits still staged and the error of the last post.
+func (u *OSMUploader) resolveDiffConflicts(ctx context.Context, changesetID int, change *OSMChange, staged []stagedEdit, results map[string]UploadStats, err error) ([]stagedEdit, error) {
+	conflicts := make(map[string]int)
+	for err != nil && isVersionConflict(err) {
+		elementType, elementID, ok := diffConflictElement(err)
+		if !ok {
+			return staged, err
+		}
+		index := -1
+		for i, edit := range staged {
+			if edit.element.Type == elementType && edit.element.ID == elementID {
+				index = i
+			}
+		}
+		if index < 0 {
+			return staged, err
 		}
 
-		// Rate limiting
-		if !u.dryRun {
-			time.Sleep(time.Millisecond * 10)
+		edit := staged[index]
+		staged = append(staged[:index], staged[index+1:]...)
+		change.Remove(elementType, elementID)
+		// The rejected diff changed nothing; a restaged edit logs the snapshot of its new version
+		u.discardUndo(changesetID, elementType, elementID, edit.version)
+		key := elementKey(elementType, elementID)
+		conflicts[key]++
+
+		stats := results[edit.categoryKey]
+		restageErr := err
+		if conflicts[key] <= maxConflictRetries {
+			uploadLog.Warn("Version conflict on %s %d, re-fetching it and retrying the diff (%d/%d)", elementType, elementID, conflicts[key], maxConflictRetries)
+			var restaged stagedEdit
+			restaged, restageErr = u.stageElement(ctx, edit.element, changesetID, change)
+			if restageErr == nil {
+				restageErr = u.recordUndo(changesetID, elementType, elementID, restaged.version, restaged.snapshot)
+				if restageErr != nil {
+					change.Remove(elementType, elementID)
+				}
+			}
+			if restageErr == nil {
+				restaged.categoryKey = edit.categoryKey
+				staged = append(staged, restaged)
+			}
+		}
+		switch {
+		case errors.Is(restageErr, ErrAlreadyHasEle):
+			uploadLog.Info("Skipping %s %d: %v", elementType, elementID, restageErr)
+			stats.AlreadyHasEle++
+		case restageErr != nil:
+			uploadLog.Warn("Dropping %s %d from the diff: %v", elementType, elementID, restageErr)
+			stats.Failed++
+			stats.Errors = append(stats.Errors, NewUploadError(elementType, elementID, fmt.Errorf("upload failed: %w", restageErr)))
+		}
+		results[edit.categoryKey] = stats
+
+		if len(staged) == 0 {
+			return staged, err
 		}
+		err = u.postDiff(ctx, changesetID, change)
 	}
+	return staged, err
+}
 
-	return stats
+// postDiff uploads an osmChange document, trying again after transient failures
+func (u *OSMUploader) postDiff(ctx context.Context, changesetID int, change *OSMChange) error {
+	return retryTransient(ctx, fmt.Sprintf("the diff of changeset #%d", changesetID), func() error {
+		_, err := u.apiClient.UploadChange(ctx, changesetID, change)
+		return err
+	})
 }
 
 // clusterProcessor handles processing of a single cluster
@@ -222,55 +986,77 @@ func newClusterProcessor(uploader *OSMUploader) *clusterProcessor {
 	}
 }
 
-// categorizeElements splits elements into categories
-func (cp *clusterProcessor) categorizeElements(elements []OSMElement) (alpineHuts, trainStations, otherAccommodations []OSMElement) {
+// categorizeElements splits elements into categories, in upload priority order
+func (cp *clusterProcessor) categorizeElements(elements []OSMElement) []categoryElements {
+	byKey := make(map[string][]OSMElement)
 	for _, element := range elements {
-		category := cp.categorizer.Categorize(element)
-		switch category {
-		case CategoryAlpineHut:
-			alpineHuts = append(alpineHuts, element)
-		case CategoryTrainStation:
-			trainStations = append(trainStations, element)
-		case CategoryOtherAccommodation:
-			otherAccommodations = append(otherAccommodations, element)
-		}
+		key := categoryToKey(cp.categorizer.Categorize(element))
+		byKey[key] = append(byKey[key], element)
+	}
+
+	groups := make([]categoryElements, 0, len(categoryKeys))
+	for _, key := range categoryKeys {
+		groups = append(groups, categoryElements{key: key, elements: byKey[key]})
 	}
-	return
+	return groups
 }
 
 // processCluster processes a single cluster with its own changeset
-func (cp *clusterProcessor) processCluster(cluster ElementCluster, clusterNum, totalClusters int, categoryStats map[string]*UploadStats) error {
+func (cp *clusterProcessor) processCluster(ctx context.Context, cluster ElementCluster, clusterNum, totalClusters int, categoryStats map[string]*UploadStats) error {
 	clusterSize := len(cluster.Elements)
 	
 	// Print cluster header
 	cp.printClusterHeader(clusterNum, totalClusters, clusterSize, cluster.BBox)
 
 	// Categorize elements
-	alpineHuts, trainStations, otherAccommodations := cp.categorizeElements(cluster.Elements)
+	groups := cp.categorizeElements(cluster.Elements)
+	var categories []string
+	for _, group := range groups {
+		if len(group.elements) > 0 {
+			categories = append(categories, group.key)
+		}
+	}
 
 	// Create changeset for this cluster
-	changesetComment := fmt.Sprintf("Add elevation data to %d locations in %s - cluster %d/%d (alpine huts, train stations, accommodations)",
-		clusterSize, cp.uploader.country, clusterNum, totalClusters)
+	changesetComment := ChangesetComment(cp.uploader.commentTemplate, ChangesetCommentData{
+		Count:        clusterSize,
+		Country:      cp.uploader.country,
+		Region:       cp.uploader.region,
+		ClusterIndex: clusterNum,
+		ClusterTotal: totalClusters,
+		Categories:   categories,
+	})
 	
-	if err := cp.uploader.CreateChangeset(changesetComment); err != nil {
+	if err := cp.uploader.CreateChangeset(ctx, changesetComment); err != nil {
 		cp.handleChangesetCreationError(cluster.Elements, err, categoryStats)
 		return err
 	}
+	cp.uploader.recordChangeset(cluster, changesetComment)
+
+	cp.uploader.prefetchElements(ctx, cluster.Elements)
+	defer cp.uploader.apiClient.ClearPrefetched()
 
 	// Upload elements by category
-	cp.uploadCategoryElements(alpineHuts, "alpine_huts", clusterNum, categoryStats)
-	cp.uploadCategoryElements(trainStations, "train_stations", clusterNum, categoryStats)
-	cp.uploadCategoryElements(otherAccommodations, "other_accommodations", clusterNum, categoryStats)
+	if cp.uploader.mode == UploadModeDiff {
+		results := cp.uploader.UploadClusterDiff(ctx, groups)
+		for key, stats := range results {
+			addUploadStats(categoryStats[key], stats)
+		}
+	} else {
+		for _, group := range groups {
+			cp.uploadCategoryElements(ctx, group.elements, group.key, clusterNum, categoryStats)
+		}
+	}
 
-	// Close changeset
+	// Close changeset, also after an interruption so no changeset is left open on osm.org
 	if err := cp.uploader.CloseChangeset(); err != nil {
-		fmt.Printf("WARNING: Failed to close changeset for cluster %d: %v\n", clusterNum, err)
+		uploadLog.Warn("Failed to close changeset for cluster %d: %v", clusterNum, err)
 	}
 
 	// Rate limiting delay
-	if clusterNum < totalClusters && !cp.uploader.dryRun {
-		fmt.Printf("\nWaiting 2 seconds before next cluster...\n")
-		time.Sleep(2 * time.Second)
+	if clusterNum < totalClusters && !cp.uploader.dryRun && ctx.Err() == nil {
+		uploadLog.Debug("Waiting 2 seconds before next cluster...")
+		sleepContext(ctx, 2*time.Second)
 	}
 
 	return nil
@@ -278,18 +1064,17 @@ func (cp *clusterProcessor) processCluster(cluster ElementCluster, clusterNum, t
 
 // printClusterHeader prints the cluster processing header
 func (cp *clusterProcessor) printClusterHeader(clusterNum, totalClusters, clusterSize int, bbox BoundingBox) {
-	fmt.Printf("\n%s\n", string(repeat('=', 60)))
-	fmt.Printf("Processing cluster %d/%d (%d elements)\n", clusterNum, totalClusters, clusterSize)
-	fmt.Printf("Bounding box: [%.4f,%.4f] to [%.4f,%.4f] (diagonal: %.4f°)\n",
+	uploadLog.Banner("Processing cluster %d/%d (%d elements)\nBounding box: [%.4f,%.4f] to [%.4f,%.4f] (diagonal: %.1f km)",
+		clusterNum, totalClusters, clusterSize,
 		bbox.MinLat, bbox.MinLon,
 		bbox.MaxLat, bbox.MaxLon,
-		bbox.Diagonal())
-	fmt.Printf("%s\n", string(repeat('=', 60)))
+		bbox.DiagonalKm())
 }
 
 // handleChangesetCreationError handles errors when creating a changeset
 func (cp *clusterProcessor) handleChangesetCreationError(elements []OSMElement, err error, categoryStats map[string]*UploadStats) {
-	fmt.Printf("WARNING: Failed to create changeset: %v\n", err)
+	uploadLog.Warn("Failed to create changeset: %v", err)
+	cp.uploader.failures.record(err)
 	
 	// Mark all elements in this cluster as failed
 	for _, elem := range elements {
@@ -298,54 +1083,59 @@ func (cp *clusterProcessor) handleChangesetCreationError(elements []OSMElement,
 		if stats, ok := categoryStats[categoryKey]; ok {
 			stats.Total++
 			stats.Failed++
-			stats.Errors = append(stats.Errors, UploadError{
-				ElementType: elem.Type,
-				ElementID:   elem.ID,
-				Error:       fmt.Sprintf("Failed to create changeset: %v", err),
-			})
+			stats.Errors = append(stats.Errors, NewUploadError(elem.Type, elem.ID, fmt.Errorf("failed to create changeset: %w", err)))
 		}
 	}
 }
 
 // uploadCategoryElements uploads elements of a specific category
-func (cp *clusterProcessor) uploadCategoryElements(elements []OSMElement, categoryKey string, clusterNum int, categoryStats map[string]*UploadStats) {
+func (cp *clusterProcessor) uploadCategoryElements(ctx context.Context, elements []OSMElement, categoryKey string, clusterNum int, categoryStats map[string]*UploadStats) {
 	if len(elements) == 0 {
 		return
 	}
 	
-	stats := cp.uploader.UploadElements(elements, fmt.Sprintf("%s (cluster %d)", categoryKey, clusterNum))
-	categoryStats[categoryKey].Total += stats.Total
-	categoryStats[categoryKey].Successful += stats.Successful
-	categoryStats[categoryKey].Failed += stats.Failed
-	categoryStats[categoryKey].Errors = append(categoryStats[categoryKey].Errors, stats.Errors...)
+	stats := cp.uploader.UploadElements(ctx, elements, fmt.Sprintf("%s (cluster %d)", categoryKey, clusterNum))
+	addUploadStats(categoryStats[categoryKey], stats)
+}
+
+// addUploadStats accumulates stats into a running total
+func addUploadStats(total *UploadStats, stats UploadStats) {
+	total.Total += stats.Total
+	total.Successful += stats.Successful
+	total.Failed += stats.Failed
+	total.Skipped += stats.Skipped
+	total.AlreadyHasEle += stats.AlreadyHasEle
+	total.Errors = append(total.Errors, stats.Errors...)
 }
 
 // initializeCategoryStats creates the initial stats structure
 func initializeCategoryStats() map[string]*UploadStats {
-	return map[string]*UploadStats{
-		"alpine_huts":          {Total: 0, Successful: 0, Failed: 0, Errors: []UploadError{}},
-		"train_stations":       {Total: 0, Successful: 0, Failed: 0, Errors: []UploadError{}},
-		"other_accommodations": {Total: 0, Successful: 0, Failed: 0, Errors: []UploadError{}},
+	stats := make(map[string]*UploadStats, len(categoryKeys))
+	for _, key := range categoryKeys {
+		stats[key] = &UploadStats{Total: 0, Successful: 0, Failed: 0, Errors: []UploadError{}}
 	}
+	return stats
 }
 
 // collectAllElements gathers all elements from validated data
 func collectAllElements(data ValidatedData) []OSMElement {
 	allElements := make([]OSMElement, 0)
-	allElements = append(allElements, data.AlpineHuts.ValidElements...)
-	allElements = append(allElements, data.TrainStations.ValidElements...)
-	allElements = append(allElements, data.OtherAccommodations.ValidElements...)
+	for _, key := range categoryKeys {
+		allElements = append(allElements, data.Category(key).ValidElements...)
+	}
 	return allElements
 }
 
 // printClusteringSummary prints information about the clustering
-func printClusteringSummary(totalElements int, clusters []ElementCluster) {
-	fmt.Printf("\nGrouping %d elements by geographic proximity...\n", totalElements)
-	fmt.Printf("Created %d geographic clusters to avoid bounding box size limits\n", len(clusters))
-	fmt.Printf("Each changeset will cover a maximum area of %.2f degrees diagonal\n\n", MaxBoundingBoxDiagonal)
+func printClusteringSummary(totalElements int, clusters []ElementCluster, maxBBoxDiagonal float64) {
+	uploadLog.Info("Grouping %d elements by geographic proximity...", totalElements)
+	uploadLog.Info("Created %d geographic clusters to avoid bounding box size limits", len(clusters))
+	uploadLog.Debug("Each changeset will cover a maximum area of %g km diagonal", maxBBoxDiagonal)
 }
 
-func (u *OSMUploader) UploadAll(data ValidatedData) (map[string]UploadStats, error) {
+// UploadAll uploads the validated data cluster by cluster. When ctx is canceled it
+// stops after the current cluster and returns the stats so far with the context's error.
+func (u *OSMUploader) UploadAll(ctx context.Context, data ValidatedData) (map[string]UploadStats, error) {
 	allStats := make(map[string]UploadStats)
 
 	// Collect all elements
@@ -356,17 +1146,63 @@ func (u *OSMUploader) UploadAll(data ValidatedData) (map[string]UploadStats, err
 		return allStats, fmt.Errorf("no elements to upload")
 	}
 
+	if !u.dryRun {
+		if err := u.applyCapabilities(ctx); err != nil {
+			return allStats, err
+		}
+		if err := u.checkUser(ctx); err != nil {
+			return allStats, err
+		}
+	}
+
 	// Cluster elements by geographic proximity
-	clusters := ClusterElements(allElements, MaxBoundingBoxDiagonal)
-	printClusteringSummary(totalElements, clusters)
+	clusters := ClusterElements(allElements, u.maxBBoxDiagonal)
+	printClusteringSummary(totalElements, clusters, u.maxBBoxDiagonal)
+
+	if split := SplitOversizedClusters(clusters, u.maxEdits); len(split) > len(clusters) {
+		uploadLog.Info("Capped changesets at %d edits: %d clusters became %d changesets", u.maxEdits, len(clusters), len(split))
+		clusters = split
+	}
 
 	// Initialize stats tracking
 	categoryStats := initializeCategoryStats()
 
 	// Process each cluster
 	processor := newClusterProcessor(u)
-	for clusterIdx, cluster := range clusters {
-		processor.processCluster(cluster, clusterIdx+1, len(clusters), categoryStats)
+	var abortErr error
+	for clusterIdx := 0; clusterIdx < len(clusters); clusterIdx++ {
+		cluster := clusters[clusterIdx]
+		if ctx.Err() != nil {
+			uploadLog.Warn("Interrupted, leaving %d of %d clusters for the next run", len(clusters)-clusterIdx, len(clusters))
+			break
+		}
+
+		// Drip-feed: a changeset holds no more edits than the hourly and daily limits leave
+		if !u.dryRun {
+			available, err := waitForEditBudget(ctx, sharedBudget())
+			if err != nil {
+				if ctx.Err() == nil {
+					abortErr = err
+					uploadLog.Info("%v: leaving %d of %d clusters for the next run", err, len(clusters)-clusterIdx, len(clusters))
+				}
+				break
+			}
+			if available > 0 && available < len(cluster.Elements) {
+				head, tail := splitClusterAt(cluster, available)
+				clusters = append(clusters[:clusterIdx+1], append([]ElementCluster{tail}, clusters[clusterIdx+1:]...)...)
+				cluster = head
+			}
+		}
+
+		processor.processCluster(ctx, cluster, clusterIdx+1, len(clusters), categoryStats)
+
+		// processCluster closed the changeset, so nothing is left open when aborting
+		if u.failures.tripped() {
+			abortErr = u.failures.abortError()
+			uploadLog.Error("Aborting: %v", abortErr)
+			uploadLog.Error("The changeset was closed; %d of %d clusters were left for the next run", len(clusters)-clusterIdx-1, len(clusters))
+			break
+		}
 	}
 
 	// Convert to final stats format
@@ -374,14 +1210,21 @@ func (u *OSMUploader) UploadAll(data ValidatedData) (map[string]UploadStats, err
 		allStats[category] = *stats
 	}
 
-	return allStats, nil
+	if abortErr != nil {
+		return allStats, abortErr
+	}
+	return allStats, ctx.Err()
 }
 
 // categoryToKey converts an ElementCategory to the string key used in stats maps
 func categoryToKey(category ElementCategory) string {
 	switch category {
+	case CategoryPeak:
+		return "peaks"
 	case CategoryAlpineHut:
 		return "alpine_huts"
+	case CategoryShelter:
+		return "shelters"
 	case CategoryTrainStation:
 		return "train_stations"
 	case CategoryOtherAccommodation:
@@ -392,59 +1235,265 @@ func categoryToKey(category ElementCategory) string {
 }
 
 // runUpload runs the upload process
-func runUpload(dryRun bool, oauthConfig *OAuthConfig, country string) error {
-	fmt.Println("\n" + string(repeat('=', 60)))
+func runUpload(ctx context.Context, oauthConfig *OAuthConfig, opts UploadOptions) error {
+	dryRun := opts.DryRun
 	if dryRun {
-		fmt.Println("STEP 6: UPLOAD (DRY-RUN) - Preview changes")
+		uploadLog.Banner("STEP 6: UPLOAD (DRY-RUN) - Preview changes")
 	} else {
-		fmt.Println("STEP 6: UPLOAD - Uploading to OpenStreetMap")
+		uploadLog.Banner("STEP 6: UPLOAD - Uploading to OpenStreetMap")
+	}
+	started := time.Now()
+	if err := runStepStartHook(opts.Workspace, StepUpload, opts.Country); err != nil {
+		return err
 	}
-	fmt.Println(string(repeat('=', 60)))
 
-	// Load validated data
+	// Load validated data, or the failures of an earlier upload
 	var data ValidatedData
-	if err := loadJSON("output/osm_data_validated.json", &data); err != nil {
-		return fmt.Errorf("output/osm_data_validated.json not found. Run --validate first: %v", err)
+	errorsFile := opts.Workspace.File(DefaultUploadErrorsFile)
+	keptFailures := unselectedFailures(errorsFile, opts.RetryErrors)
+	inputFile := opts.Workspace.File(DefaultValidatedDataFile)
+	if opts.FromErrorLog {
+		inputFile = errorsFile
+	}
+	// The errors file is rewritten by the upload, so its input hash is taken now
+	inputs := manifestFiles("input", inputFile)
+	if opts.FromErrorLog {
+		errorLog, retryData, kept, err := loadFailedUploads(errorsFile, opts.RetryErrors)
+		if err != nil {
+			return err
+		}
+		if opts.Country == "" {
+			opts.Country = errorLog.Country
+			opts.Area = errorLog.Area
+		}
+		data, keptFailures = retryData, kept
+		selected := len(errorLog.Failures) - len(kept)
+		uploadLog.Info("Retrying %d of %d failed uploads from %s", selected, len(errorLog.Failures), errorsFile)
+		if selected == 0 {
+			uploadLog.Info("Nothing to retry")
+			return nil
+		}
+	} else if err := loadPipelineFile(opts.Workspace.File(DefaultValidatedDataFile), SchemaValidated, &data); err != nil {
+		return pipelineLoadError(opts.Workspace.File(DefaultValidatedDataFile), "validate", err)
 	}
 
-	// Upload
-	uploader, err := NewOSMUploader(oauthConfig, dryRun, country)
+	if len(opts.RetryErrors) > 0 && !opts.FromErrorLog {
+		retryData, err := selectRetryElements(data, opts.Workspace.File(DefaultUploadResultsFile), opts.RetryErrors)
+		if err != nil {
+			return err
+		}
+		data = retryData
+	}
+
+	ledger, err := LoadRunLedger(opts.Workspace.File(DefaultRunLedgerFile))
 	if err != nil {
 		return err
 	}
+	state := ledger.Country(opts.Area.LedgerKey(opts.Country))
 
-	stats, err := uploader.UploadAll(data)
+	rejects, err := LoadRejects(opts.Workspace.File(DefaultRejectsFile))
 	if err != nil {
 		return err
 	}
+	data, excluded := rejects.ExcludeRejected(data)
+	if excluded > 0 {
+		uploadLog.Info("Excluding %d elements rejected in earlier reviews (%s)", excluded, rejects.path)
+	}
+
+	config := NewConfig()
+	config.LoadFromEnv()
+	ids, err := loadElementIDFilter(config)
+	if err != nil {
+		return err
+	}
+	if ids.Active() {
+		data, excluded = ids.Apply(data)
+		uploadLog.Info("Element IDs: %s; skipping %d elements", ids.describe(), excluded)
+	}
 
-	// Display statistics
-	fmt.Println("\n" + string(repeat('=', 60)))
+	if opts.Review {
+		pending := func(element OSMElement) bool {
+			return opts.Reupload || !state.IsUploaded(element.Type, element.ID)
+		}
+		data, err = ReviewEdits(os.Stdin, os.Stdout, data, rejects, pending)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := resolveElevationFormat(config); err != nil {
+		return err
+	}
+	if _, err := resolveElevationSource(config); err != nil {
+		return err
+	}
+	if _, err := resolveOverwritePolicy(config); err != nil {
+		return err
+	}
+	if _, err := resolveMaxBBoxDiagonal(config); err != nil {
+		return err
+	}
+	if _, err := resolveUploadConcurrency(config); err != nil {
+		return err
+	}
+	if _, err := resolveMaxConsecutiveFailures(config); err != nil {
+		return err
+	}
+	commentTemplate := config.Get("CHANGESET_COMMENT_TEMPLATE")
+	if commentTemplate != "" {
+		if err := ValidateCommentTemplate(commentTemplate); err != nil {
+			return err
+		}
+	}
+
+	// Upload
+	uploader, err := NewOSMUploader(oauthConfig, dryRun, opts.Country, opts.Workspace.File(DefaultUndoLogFile))
+	if err != nil {
+		return err
+	}
+	uploader.ledger = ledger
+	uploader.runState = state
+	changesetsFile := opts.Workspace.File(DefaultChangesetsFile)
+	if !dryRun {
+		if uploader.changesetLog, err = LoadChangesetLog(changesetsFile); err != nil {
+			return err
+		}
+	}
+	uploader.region = opts.Area.Region
+	uploader.commentTemplate = commentTemplate
+	uploader.skipUploaded = !opts.Reupload
+	if opts.Mode != "" {
+		uploader.mode = opts.Mode
+	}
+	if uploader.mode == UploadModeDiff && uploader.concurrency > 1 {
+		uploadLog.Warn("UPLOAD_CONCURRENCY=%d is ignored in diff mode, which uploads each changeset as a single request", uploader.concurrency)
+	}
+
+	stats, err := uploader.UploadAll(ctx, data)
+	// An interrupted, aborted or paused upload still records what was done so the next run can resume
+	interrupted := err != nil && ctx.Err() != nil
+	aborted := errors.Is(err, ErrTooManyFailures)
+	paused := errors.Is(err, ErrEditQuotaReached)
+	if err != nil && !interrupted && !aborted && !paused {
+		return err
+	}
+
+	printUploadStats(stats, dryRun)
+	printChangesetLinks(uploader.created, changesetsFile)
+
+	if dryRun {
+		diffFile := opts.Workspace.File(DefaultDryRunDiffFile)
+		if err := uploader.dryRunDiff.Save(diffFile); err != nil {
+			return err
+		}
+		uploadLog.Info("✓ Tag changes saved to %s", diffFile)
+	} else {
+		if err := SaveUploadResults(opts.Workspace.File(DefaultUploadResultsFile), opts.Country, stats); err != nil {
+			return err
+		}
+		recordHistory(StepUpload, opts.Country, stats, len(uploader.created))
+		errorLog := NewUploadErrorLog(opts.Country, opts.Area, data, stats, keptFailures)
+		if err := errorLog.Save(errorsFile); err != nil {
+			return err
+		}
+		if len(errorLog.Failures) > 0 {
+			uploadLog.Info("✓ %d failed uploads saved to %s (retry them with: elevate retry-errors)", len(errorLog.Failures), errorsFile)
+		}
+
+		failed := 0
+		for _, categoryStats := range stats {
+			failed += categoryStats.Failed
+		}
+		recordUploadState(opts.Workspace, data, state, stats)
+		exportProblemElements(opts.Workspace)
+
+		// Only advance the incremental baseline when nothing is left to retry
+		if failed == 0 && !interrupted && !aborted && !paused && state.LastExtract != "" {
+			state.LastSuccess = state.LastExtract
+		}
+		if err := ledger.Save(); err != nil {
+			return fmt.Errorf("failed to save run ledger: %v", err)
+		}
+	}
+
+	outputs := []string{opts.Workspace.File(DefaultDryRunDiffFile)}
+	if !dryRun {
+		outputs = []string{opts.Workspace.File(DefaultUploadResultsFile), errorsFile, opts.Workspace.File(DefaultUndoLogFile), changesetsFile}
+	}
+	recordManifestStep(opts.Workspace, ManifestStep{
+		Step:    StepUpload,
+		Country: opts.Country,
+		DryRun:  dryRun,
+		Sources: []string{config.Get("OSM_API_URL")},
+		Counts:  uploadCounts(stats),
+		Files:   inputs,
+	}, started, nil, outputs)
+
+	if interrupted {
+		return fmt.Errorf("upload interrupted, rerun to upload the remaining elements: %v", err)
+	}
+	if aborted {
+		return fmt.Errorf("upload aborted (%v); check the credentials and the OSM API status, then rerun to upload the remaining elements", err)
+	}
+	if paused {
+		uploadLog.Info("Drip-feed: %v. Rerun then to upload the remaining elements; uploaded ones are skipped.", err)
+		runSummary.AddProblem(fmt.Sprintf("upload paused: %v", err))
+	}
+	return nil
+}
+
+// printFailuresByClass lists the failures of all categories by cause, retryable classes first
+func printFailuresByClass(counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+
+	uploadLog.Warn("Failures by cause:")
+	var retryable []string
+	for _, retry := range []bool{true, false} {
+		for _, class := range knownErrorClasses {
+			if counts[class] == 0 || IsRetryableErrorClass(class) != retry {
+				continue
+			}
+			kind := "permanent"
+			if retry {
+				kind = "retryable"
+				retryable = append(retryable, class)
+			}
+			uploadLog.Warn("  %-11s %5d  (%s)", class, counts[class], kind)
+		}
+	}
+	if len(retryable) > 0 {
+		uploadLog.Info("Retry the transient failures with: --retry-errors %s", strings.Join(retryable, ","))
+	}
+}
+
+// printUploadStats displays per-category upload statistics
+func printUploadStats(stats map[string]UploadStats, dryRun bool) {
 	if dryRun {
-		fmt.Println("UPLOAD STATISTICS (DRY-RUN)")
+		uploadLog.Banner("UPLOAD STATISTICS (DRY-RUN)")
 	} else {
-		fmt.Println("UPLOAD STATISTICS")
+		uploadLog.Banner("UPLOAD STATISTICS")
 	}
-	fmt.Println(string(repeat('=', 60)))
 
 	for category, categoryStats := range stats {
-		fmt.Printf("\n%s:\n", category)
-		fmt.Printf("  Total: %d\n", categoryStats.Total)
-		fmt.Printf("  Successful: %d\n", categoryStats.Successful)
-		fmt.Printf("  Failed: %d\n", categoryStats.Failed)
+		uploadLog.Info("%s: total %d, successful %d, failed %d", category, categoryStats.Total, categoryStats.Successful, categoryStats.Failed)
+		if categoryStats.Skipped > 0 {
+			uploadLog.Info("  Skipped (already uploaded): %d", categoryStats.Skipped)
+		}
+		if categoryStats.AlreadyHasEle > 0 {
+			uploadLog.Info("  Skipped (existing ele kept): %d", categoryStats.AlreadyHasEle)
+		}
 
-		if categoryStats.Failed > 0 && len(categoryStats.Errors) > 0 {
-			fmt.Println("  First errors:")
+		if categoryStats.Failed > 0 {
 			for i, err := range categoryStats.Errors {
 				if i >= 3 {
 					break
 				}
-				fmt.Printf("    - %s %d: %s\n", err.ElementType, err.ElementID, err.Error)
+				uploadLog.Warn("  %s %d [%s]: %s", err.ElementType, err.ElementID, err.Category, err.Error)
 			}
 		}
 	}
 
-	fmt.Println("\n" + string(repeat('=', 60)) + "\n")
-
-	return nil
+	printFailuresByClass(countFailuresByClass(stats))
 }
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultUploadErrorsFile lists the elements that failed in the last upload together with
+// everything needed to retry them
+const DefaultUploadErrorsFile = "output/upload_errors.json"
+
+// FailedUpload is an element whose upload failed, with its category and classified error
+type FailedUpload struct {
+	Category string     `json:"category"`
+	Class    string     `json:"class"`
+	Error    string     `json:"error"`
+	Element  OSMElement `json:"element"`
+}
+
+// UploadErrorLog is the content of the upload errors file
+type UploadErrorLog struct {
+	Country   string         `json:"country"`
+	Area      AreaSelector   `json:"area"`
+	UpdatedAt string         `json:"updated_at"`
+	Failures  []FailedUpload `json:"failures"`
+}
+
+// NewUploadErrorLog collects the failed elements of an upload from its stats. kept holds earlier
+// failures that were not part of this upload and stay in the log.
+func NewUploadErrorLog(country string, area AreaSelector, data ValidatedData, stats map[string]UploadStats, kept []FailedUpload) *UploadErrorLog {
+	log := &UploadErrorLog{
+		Country:   country,
+		Area:      area,
+		UpdatedAt: time.Now().UTC().Format(time.RFC3339),
+		Failures:  append([]FailedUpload{}, kept...),
+	}
+	for _, key := range categoryKeys {
+		elements := make(map[string]OSMElement)
+		for _, element := range data.Category(key).ValidElements {
+			elements[elementKey(element.Type, element.ID)] = element
+		}
+		for _, uploadErr := range stats[key].Errors {
+			element, ok := elements[elementKey(uploadErr.ElementType, uploadErr.ElementID)]
+			if !ok {
+				continue
+			}
+			log.Failures = append(log.Failures, FailedUpload{
+				Category: key,
+				Class:    uploadErr.Category,
+				Error:    uploadErr.Error,
+				Element:  element,
+			})
+		}
+	}
+	return log
+}
+
+// LoadUploadErrorLog reads the upload errors file
+func LoadUploadErrorLog(path string) (*UploadErrorLog, error) {
+	var log UploadErrorLog
+	if err := loadJSON(path, &log); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%s not found. Run an upload first", path)
+		}
+		return nil, fmt.Errorf("failed to load upload errors %s: %v", path, err)
+	}
+	return &log, nil
+}
+
+// Save writes the upload errors file
+func (l *UploadErrorLog) Save(path string) error {
+	if err := saveJSON(path, l); err != nil {
+		return fmt.Errorf("failed to save upload errors: %v", err)
+	}
+	return nil
+}
+
+// Select splits the failures into the elements of the given classes, as validated data to
+// upload again, and the remaining failures. Empty classes select every failure.
+func (l *UploadErrorLog) Select(classes map[string]bool) (ValidatedData, []FailedUpload) {
+	var data ValidatedData
+	var kept []FailedUpload
+	for _, failure := range l.Failures {
+		if len(classes) > 0 && !classes[failure.Class] {
+			kept = append(kept, failure)
+			continue
+		}
+		category := data.Category(failure.Category)
+		if category == nil {
+			kept = append(kept, failure)
+			continue
+		}
+		category.ValidElements = append(category.ValidElements, failure.Element)
+		category.ValidCount++
+	}
+	return data, kept
+}
+
+// retryableClassNames returns the transient error classes, the default of the retry-errors command
+func retryableClassNames() string {
+	var names []string
+	for _, class := range knownErrorClasses {
+		if IsRetryableErrorClass(class) {
+			names = append(names, class)
+		}
+	}
+	return strings.Join(names, ",")
+}
+
+// loadFailedUploads reads the upload errors file and selects the failures to retry with the
+// given classes (every failure when none are given)
+func loadFailedUploads(path string, classNames []string) (*UploadErrorLog, ValidatedData, []FailedUpload, error) {
+	classes, err := ParseErrorClasses(classNames)
+	if err != nil {
+		return nil, ValidatedData{}, nil, err
+	}
+	log, err := LoadUploadErrorLog(path)
+	if err != nil {
+		return nil, ValidatedData{}, nil, err
+	}
+	data, kept := log.Select(classes)
+	return log, data, kept, nil
+}
+
+// unselectedFailures returns the logged failures that a --retry-errors run with the given
+// classes leaves alone, so they stay in the upload errors file
+func unselectedFailures(path string, classNames []string) []FailedUpload {
+	if len(classNames) == 0 {
+		return nil
+	}
+	classes, err := ParseErrorClasses(classNames)
+	if err != nil {
+		return nil
+	}
+	var log UploadErrorLog
+	if err := loadJSON(path, &log); err != nil {
+		return nil
+	}
+	_, kept := log.Select(classes)
+	return kept
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestUploadErrorLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload_errors.json")
+
+	data := ValidatedData{
+		Peaks: ValidatedCategory{
+			ValidCount: 2,
+			ValidElements: []OSMElement{
+				{Type: "node", ID: 1, Tags: map[string]string{"name": "Omu"}},
+				{Type: "node", ID: 2},
+			},
+		},
+		TrainStations: ValidatedCategory{
+			ValidCount:    1,
+			ValidElements: []OSMElement{{Type: "way", ID: 3}},
+		},
+	}
+	stats := map[string]UploadStats{
+		"peaks": {Total: 2, Failed: 1, Errors: []UploadError{
+			{ElementType: "node", ElementID: 1, Error: "HTTP 409", Category: ErrorClassConflict},
+		}},
+		"train_stations": {Total: 1, Failed: 1, Errors: []UploadError{
+			{ElementType: "way", ElementID: 3, Error: "HTTP 401", Category: ErrorClassAuth},
+		}},
+	}
+	area := AreaSelector{Region: "Brașov", AdminLevel: 4}
+	if err := NewUploadErrorLog("România", area, data, stats, nil).Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		classes []string
+		retry   map[string]int64
+		kept    int
+	}{
+		{name: "Transient classes", classes: []string{"conflict", "network"}, retry: map[string]int64{"peaks": 1}, kept: 1},
+		{name: "All classes", retry: map[string]int64{"peaks": 1, "train_stations": 3}},
+		{name: "No match", classes: []string{"rate-limit"}, retry: map[string]int64{}, kept: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log, retry, kept, err := loadFailedUploads(path, tt.classes)
+			if err != nil {
+				t.Fatalf("loadFailedUploads() error = %v", err)
+			}
+			if log.Country != "România" || log.Area.Region != "Brașov" {
+				t.Errorf("Expected the country and area to be kept, got %q %+v", log.Country, log.Area)
+			}
+			if len(kept) != tt.kept {
+				t.Errorf("Expected %d kept failures, got %d", tt.kept, len(kept))
+			}
+			for _, key := range categoryKeys {
+				elements := retry.Category(key).ValidElements
+				id, want := tt.retry[key]
+				if !want {
+					if len(elements) != 0 {
+						t.Errorf("%s: expected nothing to retry, got %+v", key, elements)
+					}
+					continue
+				}
+				if len(elements) != 1 || elements[0].ID != id {
+					t.Errorf("%s: expected element %d, got %+v", key, id, elements)
+				}
+			}
+		})
+	}
+
+	if _, retry, _, _ := loadFailedUploads(path, []string{"conflict"}); retry.Peaks.ValidElements[0].Tags["name"] != "Omu" {
+		t.Errorf("Expected the element tags to be kept, got %+v", retry.Peaks.ValidElements[0])
+	}
+	if kept := unselectedFailures(path, []string{"auth"}); len(kept) != 1 || kept[0].Class != ErrorClassConflict {
+		t.Errorf("unselectedFailures() = %+v", kept)
+	}
+	if _, _, _, err := loadFailedUploads(filepath.Join(t.TempDir(), "none.json"), nil); err == nil {
+		t.Error("Expected error for a missing upload errors file")
+	}
+}
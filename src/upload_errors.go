@@ -0,0 +1,314 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Upload error classes recorded in the results file
+const (
+	ErrorClassAuth       = "auth"
+	ErrorClassConflict   = "conflict"
+	ErrorClassGone       = "gone"
+	ErrorClassBBox       = "bbox"
+	ErrorClassRateLimit  = "rate-limit"
+	ErrorClassNetwork    = "network"
+	ErrorClassValidation = "validation"
+	ErrorClassUnknown    = "unknown"
+)
+
+// DefaultUploadResultsFile stores the statistics and classified errors of the last upload
+const DefaultUploadResultsFile = "output/upload_results.json"
+
+var (
+	// ErrVersionConflict is returned when an element changed upstream since it was prepared
+	ErrVersionConflict = errors.New("upstream version changed")
+	// ErrInvalidUpload is returned when an element cannot be uploaded as prepared
+	ErrInvalidUpload = errors.New("invalid upload")
+	// ErrAlreadyHasEle is returned when the live element gained an ele tag after extraction
+	ErrAlreadyHasEle = errors.New("element already has ele")
+	// ErrTooManyFailures is returned by UploadAll when it stopped after consecutive failures
+	ErrTooManyFailures = errors.New("too many consecutive upload failures")
+)
+
+// knownErrorClasses lists the classes accepted by --retry-errors
+var knownErrorClasses = []string{
+	ErrorClassAuth,
+	ErrorClassConflict,
+	ErrorClassGone,
+	ErrorClassBBox,
+	ErrorClassRateLimit,
+	ErrorClassNetwork,
+	ErrorClassValidation,
+	ErrorClassUnknown,
+}
+
+// retryableErrorClasses are the transient classes that may succeed when the element is tried
+// again; the others (auth, gone, bbox, validation, unknown) need a fix first
+var retryableErrorClasses = map[string]bool{
+	ErrorClassConflict:  true,
+	ErrorClassRateLimit: true,
+	ErrorClassNetwork:   true,
+}
+
+// IsRetryableErrorClass reports whether failures of a class are transient
+func IsRetryableErrorClass(class string) bool {
+	return retryableErrorClasses[class]
+}
+
+// maxTransientRetries bounds how often an element is tried again after a rate-limit or network failure
+const maxTransientRetries = 2
+
+// transientRetryDelay is the wait before the first retry of a transient failure; it doubles on each retry
+var transientRetryDelay = 10 * time.Second
+
+// retryTransient runs upload again while it fails with a rate-limit or network error. Conflicts
+// are retried by retryOnConflict and an exhausted budget does not recover within a run. When ctx
+// is canceled during a wait the last error is returned, so the upload is retried next run.
+func retryTransient(ctx context.Context, what string, upload func() error) error {
+	delay := transientRetryDelay
+	for attempt := 1; ; attempt++ {
+		err := upload()
+		class := ClassifyUploadError(err)
+		if err == nil || attempt > maxTransientRetries || errors.Is(err, ErrBudgetExhausted) ||
+			(class != ErrorClassRateLimit && class != ErrorClassNetwork) {
+			return err
+		}
+		uploadLog.Warn("Transient %s error on %s, retrying in %v (%d/%d): %v", class, what, delay, attempt, maxTransientRetries, err)
+		if sleepContext(ctx, delay) != nil {
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// DefaultMaxConsecutiveFailures is how many uploads in a row may fail before the run is aborted
+const DefaultMaxConsecutiveFailures = 25
+
+// resolveMaxConsecutiveFailures reads MAX_CONSECUTIVE_FAILURES; 0 never aborts
+func resolveMaxConsecutiveFailures(config *Config) (int, error) {
+	value := config.Get("MAX_CONSECUTIVE_FAILURES")
+	if value == "" {
+		return DefaultMaxConsecutiveFailures, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 0 {
+		return DefaultMaxConsecutiveFailures, fmt.Errorf("invalid MAX_CONSECUTIVE_FAILURES %q (expected a number, 0 to never abort)", value)
+	}
+	return limit, nil
+}
+
+// failureStreak counts consecutive upload failures across changesets. Once the limit is reached
+// the upload stops: when every request fails (expired token, blocked account, API outage) the
+// remaining ones would fail too.
+type failureStreak struct {
+	mu      sync.Mutex
+	limit   int
+	count   int
+	lastErr error
+}
+
+// record resets the streak on success and extends it on failure
+func (s *failureStreak) record(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.count = 0
+		return
+	}
+	s.count++
+	s.lastErr = err
+}
+
+// tripped reports whether the streak reached the limit
+func (s *failureStreak) tripped() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.limit > 0 && s.count >= s.limit
+}
+
+// abortError describes why the upload stopped
+func (s *failureStreak) abortError() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Errorf("%w: %d in a row, last error (%s): %v", ErrTooManyFailures, s.count, ClassifyUploadError(s.lastErr), s.lastErr)
+}
+
+// UploadResults is the persisted outcome of an upload run
+type UploadResults struct {
+	Country   string                 `json:"country"`
+	CreatedAt string                 `json:"created_at"`
+	Stats     map[string]UploadStats `json:"stats"`
+	// FailuresByClass counts the failed elements of all categories per error class
+	FailuresByClass map[string]int `json:"failures_by_class"`
+}
+
+// countFailuresByClass counts the upload errors of all categories per error class
+func countFailuresByClass(stats map[string]UploadStats) map[string]int {
+	counts := make(map[string]int)
+	for _, categoryStats := range stats {
+		for _, uploadErr := range categoryStats.Errors {
+			class := uploadErr.Category
+			if class == "" {
+				class = ErrorClassUnknown
+			}
+			counts[class]++
+		}
+	}
+	return counts
+}
+
+// ClassifyUploadError maps an upload error to one of the error classes
+func ClassifyUploadError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, ErrBudgetExhausted) {
+		return ErrorClassRateLimit
+	}
+	if errors.Is(err, ErrVersionConflict) {
+		return ErrorClassConflict
+	}
+	if errors.Is(err, ErrInvalidUpload) {
+		return ErrorClassValidation
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return classifyStatusCode(apiErr.StatusCode)
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return ErrorClassNetwork
+	}
+
+	return ErrorClassUnknown
+}
+
+// classifyStatusCode maps an OSM API status code to an error class
+func classifyStatusCode(statusCode int) string {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return ErrorClassAuth
+	case statusCode == http.StatusConflict:
+		return ErrorClassConflict
+	case statusCode == http.StatusNotFound || statusCode == http.StatusGone:
+		return ErrorClassGone
+	case statusCode == http.StatusRequestEntityTooLarge:
+		return ErrorClassBBox
+	case statusCode == http.StatusTooManyRequests || statusCode == StatusBandwidthLimitExceeded:
+		return ErrorClassRateLimit
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusPreconditionFailed:
+		return ErrorClassValidation
+	case statusCode >= 500:
+		return ErrorClassNetwork
+	default:
+		return ErrorClassUnknown
+	}
+}
+
+// NewUploadError builds a classified UploadError for an element
+func NewUploadError(elementType string, elementID int64, err error) UploadError {
+	return UploadError{
+		ElementType: elementType,
+		ElementID:   elementID,
+		Error:       err.Error(),
+		Category:    ClassifyUploadError(err),
+	}
+}
+
+// ParseErrorClasses validates a list of error class names
+func ParseErrorClasses(values []string) (map[string]bool, error) {
+	classes := make(map[string]bool)
+	for _, value := range values {
+		class := strings.ToLower(strings.TrimSpace(value))
+		known := false
+		for _, k := range knownErrorClasses {
+			if class == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown error class %q (expected one of %s)", value, strings.Join(knownErrorClasses, ", "))
+		}
+		classes[class] = true
+	}
+	return classes, nil
+}
+
+// SaveUploadResults writes upload statistics with classified errors to disk
+func SaveUploadResults(filename, country string, stats map[string]UploadStats) error {
+	results := UploadResults{
+		Country:   country,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+		Stats:     stats,
+
+		FailuresByClass: countFailuresByClass(stats),
+	}
+	if err := saveJSON(filename, results); err != nil {
+		return fmt.Errorf("failed to save upload results: %v", err)
+	}
+	uploadLog.Info("✓ Upload results saved to %s", filename)
+	return nil
+}
+
+// RetryKeys returns the keys of elements that failed with one of the given classes
+func (r *UploadResults) RetryKeys(classes map[string]bool) map[string]bool {
+	keys := make(map[string]bool)
+	for _, stats := range r.Stats {
+		for _, uploadErr := range stats.Errors {
+			if classes[uploadErr.Category] {
+				keys[elementKey(uploadErr.ElementType, uploadErr.ElementID)] = true
+			}
+		}
+	}
+	return keys
+}
+
+// filterElementsByKey keeps only elements whose type/id key is in keys
+func filterElementsByKey(elements []OSMElement, keys map[string]bool) []OSMElement {
+	filtered := make([]OSMElement, 0)
+	for _, element := range elements {
+		if keys[elementKey(element.Type, element.ID)] {
+			filtered = append(filtered, element)
+		}
+	}
+	return filtered
+}
+
+// selectRetryElements restricts validated data to elements that failed with the given classes in the previous run
+func selectRetryElements(data ValidatedData, resultsFile string, classNames []string) (ValidatedData, error) {
+	classes, err := ParseErrorClasses(classNames)
+	if err != nil {
+		return data, err
+	}
+
+	var results UploadResults
+	if err := loadJSON(resultsFile, &results); err != nil {
+		return data, fmt.Errorf("%s not found. Run --upload first: %v", resultsFile, err)
+	}
+
+	keys := results.RetryKeys(classes)
+	if len(keys) == 0 {
+		return data, fmt.Errorf("no failed elements with classes %s in %s", strings.Join(classNames, ","), resultsFile)
+	}
+
+	var retry ValidatedData
+	for _, key := range categoryKeys {
+		elements := filterElementsByKey(data.Category(key).ValidElements, keys)
+		*retry.Category(key) = ValidatedCategory{ValidCount: len(elements), ValidElements: elements}
+	}
+
+	uploadLog.Info("Retrying %d elements that failed with %s", len(keys), strings.Join(classNames, ","))
+	return retry, nil
+}
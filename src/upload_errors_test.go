@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClassifyUploadError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"unauthorized", &APIError{Operation: "failed to update node", StatusCode: 401}, ErrorClassAuth},
+		{"forbidden", &APIError{StatusCode: 403}, ErrorClassAuth},
+		{"api conflict", fmt.Errorf("upload failed: %w", &APIError{StatusCode: 409}), ErrorClassConflict},
+		{"version mismatch", fmt.Errorf("%w (proposed against v1, now v2)", ErrVersionConflict), ErrorClassConflict},
+		{"deleted", &APIError{StatusCode: 410}, ErrorClassGone},
+		{"not found", &APIError{StatusCode: 404}, ErrorClassGone},
+		{"bbox", &APIError{StatusCode: 413}, ErrorClassBBox},
+		{"too many requests", &APIError{StatusCode: 429}, ErrorClassRateLimit},
+		{"bandwidth exceeded", &APIError{StatusCode: 509}, ErrorClassRateLimit},
+		{"budget", fmt.Errorf("%w for osm_edits", ErrBudgetExhausted), ErrorClassRateLimit},
+		{"server error", &APIError{StatusCode: 503}, ErrorClassNetwork},
+		{"transport", fmt.Errorf("failed to fetch node: %w", &url.Error{Op: "Get", URL: "x", Err: timeoutError{}}), ErrorClassNetwork},
+		{"bad request", &APIError{StatusCode: 400}, ErrorClassValidation},
+		{"missing tags", fmt.Errorf("%w: missing elevation data in tags", ErrInvalidUpload), ErrorClassValidation},
+		{"other", errors.New("something odd"), ErrorClassUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyUploadError(tt.err); got != tt.expected {
+				t.Errorf("ClassifyUploadError() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// timeoutError is a minimal net.Error used to simulate transport failures
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestParseErrorClasses(t *testing.T) {
+	classes, err := ParseErrorClasses([]string{"conflict", " Network "})
+	if err != nil {
+		t.Fatalf("ParseErrorClasses() error = %v", err)
+	}
+	if !classes[ErrorClassConflict] || !classes[ErrorClassNetwork] || len(classes) != 2 {
+		t.Errorf("Unexpected classes: %v", classes)
+	}
+
+	if _, err := ParseErrorClasses([]string{"bogus"}); err == nil {
+		t.Error("Expected error for unknown class")
+	}
+}
+
+func TestSelectRetryElements(t *testing.T) {
+	dir := t.TempDir()
+	resultsFile := dir + "/upload_results.json"
+
+	stats := map[string]UploadStats{
+		"train_stations": {
+			Total:  3,
+			Failed: 2,
+			Errors: []UploadError{
+				{ElementType: "node", ElementID: 1, Category: ErrorClassConflict},
+				{ElementType: "node", ElementID: 2, Category: ErrorClassAuth},
+			},
+		},
+	}
+	if err := SaveUploadResults(resultsFile, "România", stats); err != nil {
+		t.Fatalf("SaveUploadResults() error = %v", err)
+	}
+
+	data := ValidatedData{
+		TrainStations: ValidatedCategory{
+			ValidCount: 3,
+			ValidElements: []OSMElement{
+				{Type: "node", ID: 1},
+				{Type: "node", ID: 2},
+				{Type: "node", ID: 3},
+			},
+		},
+	}
+
+	retry, err := selectRetryElements(data, resultsFile, []string{"conflict", "network"})
+	if err != nil {
+		t.Fatalf("selectRetryElements() error = %v", err)
+	}
+	if retry.TrainStations.ValidCount != 1 || retry.TrainStations.ValidElements[0].ID != 1 {
+		t.Errorf("Expected only node 1 to be retried, got %+v", retry.TrainStations.ValidElements)
+	}
+
+	if _, err := selectRetryElements(data, resultsFile, []string{"gone"}); err == nil {
+		t.Error("Expected error when no elements match the requested classes")
+	}
+}
+
+func TestRetryTransient(t *testing.T) {
+	delay := transientRetryDelay
+	transientRetryDelay = 0
+	t.Cleanup(func() { transientRetryDelay = delay })
+
+	network := fmt.Errorf("failed to update node: %w", &APIError{StatusCode: 503})
+	tests := []struct {
+		name      string
+		failures  []error
+		wantCalls int
+		wantErr   bool
+	}{
+		{"success", nil, 1, false},
+		{"network then success", []error{network}, 2, false},
+		{"rate limited then success", []error{&APIError{StatusCode: 429}, &APIError{StatusCode: 429}}, 3, false},
+		{"persistent network", []error{network, network, network, network}, maxTransientRetries + 1, true},
+		{"gone is permanent", []error{&APIError{StatusCode: 410}}, 1, true},
+		{"conflict is left to retryOnConflict", []error{&APIError{StatusCode: 409}}, 1, true},
+		{"budget is not retried", []error{fmt.Errorf("%w for osm_edits", ErrBudgetExhausted)}, 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := retryTransient(context.Background(), "node 1", func() error {
+				calls++
+				if calls <= len(tt.failures) {
+					return tt.failures[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("calls = %d, want %d", calls, tt.wantCalls)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("retryTransient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryTransientStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	calls := 0
+	start := time.Now()
+	err := retryTransient(ctx, "node 1", func() error {
+		calls++
+		return &APIError{StatusCode: 503}
+	})
+	if calls != 1 || err == nil {
+		t.Errorf("calls = %d, error = %v, want the first error without retrying", calls, err)
+	}
+	if elapsed := time.Since(start); elapsed >= transientRetryDelay {
+		t.Errorf("retryTransient() waited %v after the cancel", elapsed)
+	}
+}
+
+func TestCountFailuresByClass(t *testing.T) {
+	stats := map[string]UploadStats{
+		"peaks": {Errors: []UploadError{
+			{ElementType: "node", ElementID: 1, Category: ErrorClassNetwork},
+			{ElementType: "node", ElementID: 2, Category: ErrorClassGone},
+		}},
+		"train_stations": {Errors: []UploadError{
+			{ElementType: "node", ElementID: 3, Category: ErrorClassNetwork},
+			{ElementType: "node", ElementID: 4},
+		}},
+	}
+	want := map[string]int{ErrorClassNetwork: 2, ErrorClassGone: 1, ErrorClassUnknown: 1}
+	if got := countFailuresByClass(stats); !reflect.DeepEqual(got, want) {
+		t.Errorf("countFailuresByClass() = %v, want %v", got, want)
+	}
+	if IsRetryableErrorClass(ErrorClassGone) || !IsRetryableErrorClass(ErrorClassNetwork) {
+		t.Error("gone must be permanent and network retryable")
+	}
+}
+
+func TestFailureStreak(t *testing.T) {
+	failure := errors.New("HTTP 403: blocked")
+	tests := []struct {
+		name    string
+		limit   int
+		results []error
+		want    bool
+	}{
+		{name: "Reaches the limit", limit: 3, results: []error{failure, failure, failure}, want: true},
+		{name: "Success resets", limit: 3, results: []error{failure, failure, nil, failure, failure}},
+		{name: "Disabled", limit: 0, results: []error{failure, failure, failure, failure}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streak := &failureStreak{limit: tt.limit}
+			for _, err := range tt.results {
+				streak.record(err)
+			}
+			if got := streak.tripped(); got != tt.want {
+				t.Errorf("tripped() = %v, want %v", got, tt.want)
+			}
+			if tt.want && !errors.Is(streak.abortError(), ErrTooManyFailures) {
+				t.Errorf("abortError() = %v", streak.abortError())
+			}
+		})
+	}
+
+	config := NewConfig()
+	config.Set("MAX_CONSECUTIVE_FAILURES", "-1")
+	if _, err := resolveMaxConsecutiveFailures(config); err == nil {
+		t.Error("Expected error for a negative MAX_CONSECUTIVE_FAILURES")
+	}
+}
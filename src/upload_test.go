@@ -0,0 +1,730 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// conflictingNodeServer serves node 7 and rejects the first conflicts PUTs with HTTP 409,
+// bumping the node version each time as if someone else had edited it
+func conflictingNodeServer(t *testing.T, conflicts int) (*httptest.Server, *int, *NodeData) {
+	t.Helper()
+	version := 1
+	puts := 0
+	var uploaded NodeData
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/0.6/node/7" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			fmt.Fprintf(w, `<osm version="0.6"><node id="7" version="%d" lat="45" lon="25"><tag k="natural" v="peak"/><tag k="name" v="v%d"/></node></osm>`, version, version)
+		case "PUT":
+			puts++
+			if puts <= conflicts {
+				version++
+				http.Error(w, "Version mismatch", http.StatusConflict)
+				return
+			}
+			body, _ := io.ReadAll(r.Body)
+			var doc OSMNode
+			if err := xml.Unmarshal(body, &doc); err != nil || doc.Node == nil {
+				http.Error(w, "bad XML", http.StatusBadRequest)
+				return
+			}
+			uploaded = *doc.Node
+			fmt.Fprint(w, version+1)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &puts, &uploaded
+}
+
+func TestUploadNodeRetriesVersionConflict(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	tests := []struct {
+		name      string
+		conflicts int
+		wantPuts  int
+		wantErr   bool
+	}{
+		{"no conflict", 0, 1, false},
+		{"conflict then success", 2, 3, false},
+		{"persistent conflict", maxConflictRetries + 1, maxConflictRetries + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, puts, uploaded := conflictingNodeServer(t, tt.conflicts)
+			flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+			uploader := &OSMUploader{apiClient: NewOSMAPIClient(server.Client(), false)}
+			err := uploader.uploadNode(context.Background(), 7, map[string]string{"ele": "1234", "ele:source": "SRTM"}, 99)
+
+			if *puts != tt.wantPuts {
+				t.Errorf("PUT requests = %d, want %d", *puts, tt.wantPuts)
+			}
+			if tt.wantErr {
+				if err == nil || ClassifyUploadError(err) != ErrorClassConflict {
+					t.Errorf("Expected conflict error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("uploadNode: %v", err)
+			}
+
+			wantVersion := tt.conflicts + 1
+			if uploaded.Version != wantVersion {
+				t.Errorf("uploaded version = %d, want %d", uploaded.Version, wantVersion)
+			}
+			tags := make(map[string]string)
+			for _, tag := range uploaded.Tags {
+				tags[tag.Key] = tag.Value
+			}
+			if tags["ele"] != "1234" || tags["name"] != fmt.Sprintf("v%d", wantVersion) {
+				t.Errorf("tags not re-merged onto the latest version: %v", tags)
+			}
+		})
+	}
+}
+
+func TestUploadNodeConflictWithProposalIsNotRetried(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	server, puts, _ := conflictingNodeServer(t, 1)
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	uploader := &OSMUploader{
+		apiClient:        NewOSMAPIClient(server.Client(), false),
+		expectedVersions: map[string]int{elementKey("node", 7): 1},
+	}
+	err := uploader.uploadNode(context.Background(), 7, map[string]string{"ele": "1234"}, 99)
+	if err == nil || ClassifyUploadError(err) != ErrorClassConflict {
+		t.Errorf("Expected conflict error, got %v", err)
+	}
+	if *puts != 1 {
+		t.Errorf("PUT requests = %d, want 1 (re-fetched version no longer matches the proposal)", *puts)
+	}
+}
+
+func TestUploadNodeRecordsUndoBeforePut(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	tests := []struct {
+		name        string
+		status      int
+		wantErr     bool
+		wantEntries int
+	}{
+		{"applied edit keeps its snapshot", http.StatusOK, false, 1},
+		{"rejected edit drops its snapshot", http.StatusBadRequest, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), DefaultUndoLogFile)
+			loggedAtPut := -1
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case "GET":
+					fmt.Fprint(w, `<osm version="0.6"><node id="7" version="1" lat="45" lon="25"><tag k="natural" v="peak"/></node></osm>`)
+				case "PUT":
+					entries, _ := LoadUndoEntries(path, 99)
+					loggedAtPut = len(entries)
+					if tt.status != http.StatusOK {
+						http.Error(w, "rejected", tt.status)
+						return
+					}
+					fmt.Fprint(w, "2")
+				}
+			}))
+			defer server.Close()
+			flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+			undoLog, err := NewUndoLog(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			uploader := &OSMUploader{apiClient: NewOSMAPIClient(server.Client(), false), undoLog: undoLog}
+			err = uploader.uploadNode(context.Background(), 7, map[string]string{"ele": "1234"}, 99)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("uploadNode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if loggedAtPut != 1 {
+				t.Errorf("undo entries when the PUT arrived = %d, want the snapshot already logged", loggedAtPut)
+			}
+			entries, err := LoadUndoEntries(path, 99)
+			if err != nil || len(entries) != tt.wantEntries {
+				t.Errorf("undo entries after upload = %v, %v, want %d", entries, err, tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestUploadSkipsElementsWithLiveEle(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	writes := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writes++
+			fmt.Fprint(w, "2")
+			return
+		}
+		switch r.URL.Path {
+		case "/api/0.6/node/1":
+			fmt.Fprint(w, `<osm version="0.6"><node id="1" version="2" lat="45" lon="25"><tag k="natural" v="peak"/><tag k="ele" v="2010"/></node></osm>`)
+		case "/api/0.6/way/2":
+			fmt.Fprint(w, `<osm version="0.6"><way id="2" version="4"><nd ref="1"/><tag k="tourism" v="alpine_hut"/><tag k="ele" v="1800"/></way></osm>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	elements := []OSMElement{
+		{Type: "node", ID: 1, Tags: map[string]string{"ele": "2000", "ele:source": "SRTM"}},
+		{Type: "way", ID: 2, Tags: map[string]string{"ele": "1790", "ele:source": "SRTM"}},
+	}
+
+	newUploader := func() *OSMUploader {
+		changesets := NewChangesetManager(server.Client(), false)
+		changesets.changesetID = 10
+		changesets.changesetOpen = true
+		return &OSMUploader{
+			apiClient:        NewOSMAPIClient(server.Client(), false),
+			changesetManager: changesets,
+		}
+	}
+
+	stats := newUploader().UploadElements(context.Background(), elements, "peaks")
+	if stats.AlreadyHasEle != 2 || stats.Successful != 0 || stats.Failed != 0 {
+		t.Errorf("element mode stats = %+v, want 2 already_has_ele", stats)
+	}
+
+	results := newUploader().UploadClusterDiff(context.Background(), []categoryElements{{key: "peaks", elements: elements}})
+	if got := results["peaks"]; got.AlreadyHasEle != 2 || got.Successful != 0 || got.Failed != 0 {
+		t.Errorf("diff mode stats = %+v, want 2 already_has_ele", got)
+	}
+
+	if writes != 0 {
+		t.Errorf("Expected no write requests, got %d", writes)
+	}
+}
+
+func TestUploadClusterDiffResolvesConflicts(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	tests := []struct {
+		name           string
+		conflicts      int
+		wantPosts      int
+		wantSuccessful int
+		wantFailed     int
+	}{
+		{"no conflict", 0, 1, 2, 0},
+		{"conflict is re-staged", 1, 2, 2, 0},
+		{"persistent conflict drops the element", maxConflictRetries + 1, maxConflictRetries + 2, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version := 1
+			posts := 0
+			var uploaded OSMChange
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == "GET" && r.URL.Path == "/api/0.6/node/1":
+					fmt.Fprintf(w, `<osm version="0.6"><node id="1" version="%d" lat="45" lon="25"><tag k="natural" v="peak"/></node></osm>`, version)
+				case r.Method == "GET" && r.URL.Path == "/api/0.6/node/2":
+					fmt.Fprint(w, `<osm version="0.6"><node id="2" version="1" lat="45" lon="25"><tag k="natural" v="peak"/></node></osm>`)
+				case r.Method == "POST" && r.URL.Path == "/api/0.6/changeset/10/upload":
+					posts++
+					body, _ := io.ReadAll(r.Body)
+					uploaded = OSMChange{}
+					if err := xml.Unmarshal(body, &uploaded); err != nil {
+						http.Error(w, "bad XML", http.StatusBadRequest)
+						return
+					}
+					for _, node := range uploaded.Modify[0].Nodes {
+						if node.ID == 1 && posts <= tt.conflicts {
+							http.Error(w, fmt.Sprintf("Version mismatch: Provided %d, server had: %d of Node 1", node.Version, version+1), http.StatusConflict)
+							version++
+							return
+						}
+					}
+					fmt.Fprint(w, `<diffResult version="0.6"/>`)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+			flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+			changesets := NewChangesetManager(server.Client(), false)
+			changesets.changesetID = 10
+			changesets.changesetOpen = true
+			uploader := &OSMUploader{apiClient: NewOSMAPIClient(server.Client(), false), changesetManager: changesets}
+			elements := []OSMElement{
+				{Type: "node", ID: 1, Tags: map[string]string{"ele": "2000", "ele:source": "SRTM"}},
+				{Type: "node", ID: 2, Tags: map[string]string{"ele": "1500", "ele:source": "SRTM"}},
+			}
+
+			got := uploader.UploadClusterDiff(context.Background(), []categoryElements{{key: "peaks", elements: elements}})["peaks"]
+			if posts != tt.wantPosts {
+				t.Errorf("diff posts = %d, want %d", posts, tt.wantPosts)
+			}
+			if got.Successful != tt.wantSuccessful || got.Failed != tt.wantFailed {
+				t.Errorf("stats = %+v, want %d successful and %d failed", got, tt.wantSuccessful, tt.wantFailed)
+			}
+			if tt.wantFailed > 0 {
+				if len(got.Errors) != 1 || got.Errors[0].ElementID != 1 || got.Errors[0].Category != ErrorClassConflict {
+					t.Errorf("errors = %+v, want a conflict on node 1", got.Errors)
+				}
+				return
+			}
+			for _, node := range uploaded.Modify[0].Nodes {
+				if node.ID == 1 && node.Version != version {
+					t.Errorf("node 1 uploaded as v%d, want the re-fetched v%d", node.Version, version)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadClusterDiffRecordsUndoBeforePost(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	tests := []struct {
+		name        string
+		status      int
+		wantEntries int
+	}{
+		{"applied diff keeps the snapshots", http.StatusOK, 2},
+		{"rejected diff drops the snapshots", http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), DefaultUndoLogFile)
+			loggedAtPost := -1
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == "GET" && strings.HasPrefix(r.URL.Path, "/api/0.6/node/"):
+					id := strings.TrimPrefix(r.URL.Path, "/api/0.6/node/")
+					fmt.Fprintf(w, `<osm version="0.6"><node id="%s" version="1" lat="45" lon="25"><tag k="natural" v="peak"/></node></osm>`, id)
+				case r.Method == "POST":
+					entries, _ := LoadUndoEntries(path, 10)
+					loggedAtPost = len(entries)
+					if tt.status != http.StatusOK {
+						http.Error(w, "rejected", tt.status)
+						return
+					}
+					fmt.Fprint(w, `<diffResult version="0.6"/>`)
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+			flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+			undoLog, err := NewUndoLog(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			changesets := NewChangesetManager(server.Client(), false)
+			changesets.changesetID = 10
+			changesets.changesetOpen = true
+			uploader := &OSMUploader{apiClient: NewOSMAPIClient(server.Client(), false), changesetManager: changesets, undoLog: undoLog}
+			elements := []OSMElement{
+				{Type: "node", ID: 1, Tags: map[string]string{"ele": "2000", "ele:source": "SRTM"}},
+				{Type: "node", ID: 2, Tags: map[string]string{"ele": "1500", "ele:source": "SRTM"}},
+			}
+
+			uploader.UploadClusterDiff(context.Background(), []categoryElements{{key: "peaks", elements: elements}})
+			if loggedAtPost != 2 {
+				t.Errorf("undo entries when the diff arrived = %d, want both snapshots already logged", loggedAtPost)
+			}
+			entries, err := LoadUndoEntries(path, 10)
+			if err != nil || len(entries) != tt.wantEntries {
+				t.Errorf("undo entries after upload = %v, %v, want %d", entries, err, tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestUploadClusterDiffRetriesTransientFailure(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+	delay := transientRetryDelay
+	transientRetryDelay = 0
+	t.Cleanup(func() { transientRetryDelay = delay })
+
+	posts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/0.6/node/1":
+			fmt.Fprint(w, `<osm version="0.6"><node id="1" version="1" lat="45" lon="25"><tag k="natural" v="peak"/></node></osm>`)
+		case r.Method == "POST":
+			posts++
+			if posts == 1 {
+				http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, `<diffResult version="0.6"/>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	changesets := NewChangesetManager(server.Client(), false)
+	changesets.changesetID = 10
+	changesets.changesetOpen = true
+	uploader := &OSMUploader{apiClient: NewOSMAPIClient(server.Client(), false), changesetManager: changesets}
+	elements := []OSMElement{{Type: "node", ID: 1, Tags: map[string]string{"ele": "2000", "ele:source": "SRTM"}}}
+
+	got := uploader.UploadClusterDiff(context.Background(), []categoryElements{{key: "peaks", elements: elements}})["peaks"]
+	if posts != 2 || got.Successful != 1 || got.Failed != 0 {
+		t.Errorf("posts = %d, stats = %+v, want the diff retried after the 503", posts, got)
+	}
+}
+
+func TestCheckChildCount(t *testing.T) {
+	way := `<osm version="0.6"><way id="2" version="4"><nd ref="1"/><nd ref="2"/><nd ref="1"/><tag k="building" v="yes"/></way></osm>`
+	tests := []struct {
+		name        string
+		elementType string
+		snapshot    string
+		child       string
+		sent        int
+		wantErr     bool
+	}{
+		{"all nodes sent", "way", way, "nd", 3, false},
+		{"truncated nodes", "way", way, "nd", 2, true},
+		{"no nodes parsed", "way", way, "nd", 0, true},
+		{"degenerate way", "way", `<osm><way id="2"><nd ref="1"/></way></osm>`, "nd", 1, true},
+		{"relation members", "relation", `<osm><relation id="3"><member type="way" ref="2" role="outer"/></relation></osm>`, "member", 1, false},
+		{"malformed snapshot", "way", `<osm><way id="2"><nd ref="1"/>`, "nd", 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkChildCount(tt.elementType, 2, []byte(tt.snapshot), tt.child, tt.sent)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkChildCount() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidUpload) {
+				t.Errorf("error %v is not ErrInvalidUpload", err)
+			}
+		})
+	}
+}
+
+func TestResolveMaxBBoxDiagonal(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "", want: MaxBoundingBoxDiagonal},
+		{value: "10", want: 10},
+		{value: "55", want: 55},
+		{value: "0", wantErr: true},
+		{value: "-5", wantErr: true},
+		{value: "80", wantErr: true},
+		{value: "wide", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			config := NewConfig()
+			config.Set("MAX_BBOX_DIAGONAL_KM", tt.value)
+			got, err := resolveMaxBBoxDiagonal(config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveMaxBBoxDiagonal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("resolveMaxBBoxDiagonal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaxBBoxDiagonalFlag(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: nil, want: ""},
+		{args: []string{"--max-bbox-diagonal", "12.5"}, want: "12.5"},
+		{args: []string{"--max-bbox-diagonal", "100"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		flagConfig = NewConfig()
+		fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+		apply := registerMaxBBoxDiagonalFlag(fs)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		err := apply()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: apply() error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+		if got := flagConfig.Get("MAX_BBOX_DIAGONAL_KM"); !tt.wantErr && got != tt.want {
+			t.Errorf("%v: MAX_BBOX_DIAGONAL_KM = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestUploadElementsConcurrently(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	var mu sync.Mutex
+	inFlight, maxInFlight, puts := 0, 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(20 * time.Millisecond)
+
+		var id int64
+		if _, err := fmt.Sscanf(r.URL.Path, "/api/0.6/node/%d", &id); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "PUT" {
+			mu.Lock()
+			puts++
+			mu.Unlock()
+			fmt.Fprint(w, "2")
+			return
+		}
+		fmt.Fprintf(w, `<osm version="0.6"><node id="%d" version="1" lat="45" lon="25"><tag k="natural" v="peak"/></node></osm>`, id)
+	}))
+	defer server.Close()
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	var elements []OSMElement
+	for i := 1; i <= 20; i++ {
+		elements = append(elements, OSMElement{Type: "node", ID: int64(i), Tags: map[string]string{"ele": "1000", "ele:source": "SRTM"}})
+	}
+	ledger, err := LoadRunLedger(t.TempDir() + "/run_ledger.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changesets := NewChangesetManager(server.Client(), false)
+	changesets.changesetID = 10
+	changesets.changesetOpen = true
+	uploader := &OSMUploader{
+		apiClient:        NewOSMAPIClient(server.Client(), false),
+		changesetManager: changesets,
+		concurrency:      4,
+		ledger:           ledger,
+		runState:         ledger.Country("România"),
+		skipUploaded:     true,
+	}
+
+	stats := uploader.UploadElements(context.Background(), elements, "peaks")
+	if stats.Successful != 20 || stats.Failed != 0 {
+		t.Errorf("stats = %+v, want 20 successful", stats)
+	}
+	if puts != 20 {
+		t.Errorf("PUT requests = %d, want 20", puts)
+	}
+	if maxInFlight < 2 || maxInFlight > 4 {
+		t.Errorf("max requests in flight = %d, want 2 to 4", maxInFlight)
+	}
+	for _, element := range elements {
+		if !uploader.runState.IsUploaded("node", element.ID) {
+			t.Errorf("node %d not recorded in the run ledger", element.ID)
+		}
+	}
+}
+
+func TestResolveUploadConcurrency(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: 1},
+		{value: "4", want: 4},
+		{value: "0", wantErr: true},
+		{value: "9", wantErr: true},
+		{value: "many", wantErr: true},
+	}
+	for _, tt := range tests {
+		config := NewConfig()
+		config.Set("UPLOAD_CONCURRENCY", tt.value)
+		got, err := resolveUploadConcurrency(config)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("resolveUploadConcurrency(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("resolveUploadConcurrency(%q) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestUploadConcurrencyFlagRequiresElementMode(t *testing.T) {
+	t.Cleanup(func() { flagConfig = NewConfig() })
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{args: []string{"--upload-mode", "element", "--upload-concurrency", "4"}, want: "4"},
+		{args: []string{"--upload-concurrency", "4"}, wantErr: true},
+		{args: []string{"--upload-mode", "diff", "--upload-concurrency", "1"}, want: "1"},
+		{args: []string{"--upload-mode", "element", "--upload-concurrency", "9"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		flagConfig = NewConfig()
+		fs := flag.NewFlagSet("upload", flag.ContinueOnError)
+		uploadMode := fs.String("upload-mode", UploadModeDiff, "")
+		apply := registerUploadConcurrencyFlag(fs, uploadMode)
+		if err := fs.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+		err := apply()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: apply() error = %v, wantErr %v", tt.args, err, tt.wantErr)
+		}
+		if got := flagConfig.Get("UPLOAD_CONCURRENCY"); !tt.wantErr && got != tt.want {
+			t.Errorf("%v: UPLOAD_CONCURRENCY = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestUploadAllAbortsAfterConsecutiveFailures(t *testing.T) {
+	disableSharedBudget(t)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	fetches, closes := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/0.6/changeset/create":
+			fmt.Fprint(w, "11")
+		case r.URL.Path == "/api/0.6/changeset/11/close":
+			closes++
+		case r.URL.Path == "/api/0.6/nodes":
+			http.Error(w, "blocked", http.StatusForbidden)
+		case r.URL.Path == "/api/0.6/capabilities" || r.URL.Path == "/api/0.6/user/details":
+			http.NotFound(w, r)
+		default:
+			fetches++
+			http.Error(w, "blocked", http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	var data ValidatedData
+	for i := 1; i <= 10; i++ {
+		data.Peaks.ValidElements = append(data.Peaks.ValidElements, OSMElement{
+			Type: "node", ID: int64(i), Lat: 45.5, Lon: 25 + float64(i)/1000,
+			Tags: map[string]string{"natural": "peak", "ele": "1000", "ele:source": "SRTM"},
+		})
+	}
+	uploader := &OSMUploader{
+		apiClient:        NewOSMAPIClient(server.Client(), false),
+		changesetManager: NewChangesetManager(server.Client(), false),
+		maxBBoxDiagonal:  MaxBoundingBoxDiagonal,
+		mode:             UploadModeElement,
+	}
+	uploader.failures.limit = 3
+
+	stats, err := uploader.UploadAll(context.Background(), data)
+	if !errors.Is(err, ErrTooManyFailures) {
+		t.Fatalf("UploadAll() error = %v, want ErrTooManyFailures", err)
+	}
+	if fetches != 3 || stats["peaks"].Failed != 3 {
+		t.Errorf("%d fetches and %d failures, want 3 of each", fetches, stats["peaks"].Failed)
+	}
+	if closes != 1 {
+		t.Errorf("changeset closed %d times, want 1", closes)
+	}
+}
+
+func TestUploadAllDripFeed(t *testing.T) {
+	disableSharedBudget(t)
+	sharedBudgetInstance = NewBudget("", map[string]BudgetLimit{BudgetOSMEdits: {Daily: 3}}, 0)
+	t.Cleanup(func() { flagConfig = NewConfig() })
+
+	puts, closes := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var id int64
+		switch {
+		case r.URL.Path == "/api/0.6/changeset/create":
+			fmt.Fprint(w, "11")
+		case r.URL.Path == "/api/0.6/changeset/11/close":
+			closes++
+		case r.Method == "PUT":
+			puts++
+			fmt.Fprint(w, "2")
+		case r.Method == "GET":
+			if _, err := fmt.Sscanf(r.URL.Path, "/api/0.6/node/%d", &id); err != nil {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprintf(w, `<osm version="0.6"><node id="%d" version="1" lat="45.5" lon="25"><tag k="natural" v="peak"/></node></osm>`, id)
+		}
+	}))
+	defer server.Close()
+	flagConfig.Set("OSM_API_URL", server.URL+"/api/0.6")
+
+	var data ValidatedData
+	for i := 1; i <= 5; i++ {
+		data.Peaks.ValidElements = append(data.Peaks.ValidElements, OSMElement{
+			Type: "node", ID: int64(i), Lat: 45.5, Lon: 25 + float64(i)/1000,
+			Tags: map[string]string{"natural": "peak", "ele": "1000", "ele:source": "SRTM"},
+		})
+	}
+	uploader := &OSMUploader{
+		apiClient:        NewOSMAPIClient(server.Client(), false),
+		changesetManager: NewChangesetManager(server.Client(), false),
+		maxBBoxDiagonal:  MaxBoundingBoxDiagonal,
+		mode:             UploadModeElement,
+	}
+
+	stats, err := uploader.UploadAll(context.Background(), data)
+	if !errors.Is(err, ErrEditQuotaReached) {
+		t.Fatalf("UploadAll() error = %v, want ErrEditQuotaReached", err)
+	}
+	if puts != 3 || stats["peaks"].Successful != 3 || stats["peaks"].Failed != 0 {
+		t.Errorf("%d PUTs, stats %+v, want 3 successful uploads", puts, stats["peaks"])
+	}
+	if closes != 1 {
+		t.Errorf("changeset closed %d times, want 1", closes)
+	}
+}
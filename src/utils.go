@@ -1,10 +1,17 @@
 package main
 
 import (
+	"compress/gzip"
 	"encoding/json"
+	"io"
 	"os"
+	"strings"
 )
 
+// gzipSuffix marks JSON files stored compressed
+const gzipSuffix = ".gz"
+
+// saveJSON writes data as indented JSON, compressed with gzip when the file name ends in .gz
 func saveJSON(filename string, data interface{}) error {
 	file, err := os.Create(filename)
 	if err != nil {
@@ -12,19 +19,59 @@ func saveJSON(filename string, data interface{}) error {
 	}
 	defer file.Close()
 
-	encoder := json.NewEncoder(file)
+	var w io.Writer = file
+	var gz *gzip.Writer
+	if strings.HasSuffix(filename, gzipSuffix) {
+		gz = gzip.NewWriter(file)
+		w = gz
+	}
+
+	encoder := json.NewEncoder(w)
 	encoder.SetIndent("", "  ")
 	encoder.SetEscapeHTML(false)
 
-	return encoder.Encode(data)
+	if err := encoder.Encode(data); err != nil {
+		return err
+	}
+	if gz != nil {
+		return gz.Close()
+	}
+	return nil
 }
 
+// loadJSON reads a JSON file, decompressing it when its name ends in .gz. A missing file is
+// read from its compressed or uncompressed twin, so datasets written before GZIP_INTERMEDIATE
+// was switched keep working.
 func loadJSON(filename string, data interface{}) error {
-	file, err := os.Open(filename)
+	file, err := os.Open(existingJSONFile(filename))
 	if err != nil {
 		return err
 	}
 	defer file.Close()
 
-	return json.NewDecoder(file).Decode(data)
+	var r io.Reader = file
+	if strings.HasSuffix(file.Name(), gzipSuffix) {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return json.NewDecoder(r).Decode(data)
+}
+
+// existingJSONFile returns filename, or its .gz twin (or the file without .gz) when only that exists
+func existingJSONFile(filename string) string {
+	if _, err := os.Stat(filename); err == nil {
+		return filename
+	}
+	twin := filename + gzipSuffix
+	if strings.HasSuffix(filename, gzipSuffix) {
+		twin = strings.TrimSuffix(filename, gzipSuffix)
+	}
+	if _, err := os.Stat(twin); err == nil {
+		return twin
+	}
+	return filename
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGzipJSONRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	elevation := 2544.0
+	data := EnrichedData{Peaks: []OSMElement{{Type: "node", ID: 1, Tags: map[string]string{"name": "Moldoveanu"}, ElevationFetched: &elevation}}}
+
+	tests := []struct {
+		name       string
+		file       string
+		compressed bool
+	}{
+		{"Plain", "data.json", false},
+		{"Gzip", "data.json.gz", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			if err := saveJSON(path, data); err != nil {
+				t.Fatalf("saveJSON() error = %v", err)
+			}
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// gzip streams start with the magic bytes 1f 8b
+			if got := bytes.HasPrefix(raw, []byte{0x1f, 0x8b}); got != tt.compressed {
+				t.Errorf("compressed = %v, want %v", got, tt.compressed)
+			}
+
+			var loaded EnrichedData
+			if err := loadJSON(path, &loaded); err != nil {
+				t.Fatalf("loadJSON() error = %v", err)
+			}
+			if !reflect.DeepEqual(loaded.Peaks, data.Peaks) {
+				t.Errorf("loaded %+v, want %+v", loaded.Peaks, data.Peaks)
+			}
+		})
+	}
+}
+
+func TestLoadJSONReadsTwin(t *testing.T) {
+	dir := t.TempDir()
+	if err := saveJSON(filepath.Join(dir, "plain.json"), map[string]int{"n": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveJSON(filepath.Join(dir, "packed.json.gz"), map[string]int{"n": 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		file string
+		want int
+	}{
+		{"plain.json.gz", 1},
+		{"packed.json", 2},
+	}
+	for _, tt := range tests {
+		var got map[string]int
+		if err := loadJSON(filepath.Join(dir, tt.file), &got); err != nil {
+			t.Fatalf("loadJSON(%s) error = %v", tt.file, err)
+		}
+		if got["n"] != tt.want {
+			t.Errorf("loadJSON(%s) = %v, want n=%d", tt.file, got, tt.want)
+		}
+	}
+
+	var missing map[string]int
+	if err := loadJSON(filepath.Join(dir, "missing.json"), &missing); !os.IsNotExist(err) {
+		t.Errorf("loadJSON(missing) error = %v, want not exist", err)
+	}
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"fmt"
+	"strings"
+	"time"
 )
 
 type ElevationValidator struct {
 	MinElevation float64
 	MaxElevation float64
+	MaxSlope     float64 // degrees; steeper elements are rejected when set
+	// Format re-rounds elevations and their ele tags before validation when set
+	Format *ElevationFormat
 }
 
 type ValidationResult struct {
@@ -34,9 +39,32 @@ type ValidatedCategory struct {
 }
 
 type ValidatedData struct {
+	SchemaHeader
 	TrainStations       ValidatedCategory `json:"train_stations"`
 	AlpineHuts          ValidatedCategory `json:"alpine_huts"`
 	OtherAccommodations ValidatedCategory `json:"other_accommodations"`
+	Peaks               ValidatedCategory `json:"peaks"`
+	Shelters            ValidatedCategory `json:"shelters"`
+}
+
+// categoryKeys lists the pipeline categories in processing priority order
+var categoryKeys = []string{"peaks", "alpine_huts", "shelters", "train_stations", "other_accommodations"}
+
+// Category returns the validated category with the given key
+func (d *ValidatedData) Category(key string) *ValidatedCategory {
+	switch key {
+	case "peaks":
+		return &d.Peaks
+	case "alpine_huts":
+		return &d.AlpineHuts
+	case "shelters":
+		return &d.Shelters
+	case "train_stations":
+		return &d.TrainStations
+	case "other_accommodations":
+		return &d.OtherAccommodations
+	}
+	return nil
 }
 
 func NewElevationValidator(minElevation, maxElevation float64) *ElevationValidator {
@@ -71,6 +99,9 @@ func (v *ElevationValidator) ValidateElement(element OSMElement) ValidationResul
 	} else if elevation > v.MaxElevation {
 		result.Errors = append(result.Errors,
 			fmt.Sprintf("Elevation %.1fm above maximum %.1fm", elevation, v.MaxElevation))
+	} else if v.MaxSlope > 0 && element.Slope != nil && *element.Slope > v.MaxSlope {
+		result.Errors = append(result.Errors,
+			fmt.Sprintf("Terrain slope %.1f° above maximum %.1f° (low confidence)", *element.Slope, v.MaxSlope))
 	} else {
 		result.Valid = true
 	}
@@ -85,6 +116,9 @@ func (v *ElevationValidator) ValidateElements(elements []OSMElement) ValidationR
 	}
 
 	for _, element := range elements {
+		if v.Format != nil {
+			v.Format.Apply(&element)
+		}
 		validation := v.ValidateElement(element)
 
 		if validation.Valid {
@@ -103,31 +137,22 @@ func (v *ElevationValidator) ValidateElements(elements []OSMElement) ValidationR
 func (v *ElevationValidator) ValidateAll(data *EnrichedData) map[string]ValidationResults {
 	results := make(map[string]ValidationResults)
 
-	categories := map[string][]OSMElement{
-		"train_stations":       data.TrainStations,
-		"alpine_huts":          data.AlpineHuts,
-		"other_accommodations": data.OtherAccommodations,
-	}
-
-	for category, elements := range categories {
+	for _, category := range categoryKeys {
+		elements := *data.Category(category)
 		if len(elements) > 0 {
-			fmt.Printf("\nValidating %s...\n", category)
+			validateLog.Info("Validating %s...", category)
 			validation := v.ValidateElements(elements)
 			results[category] = validation
 
-			fmt.Printf("  Valid: %d\n", len(validation.Valid))
-			fmt.Printf("  Invalid: %d\n", len(validation.Invalid))
+			validateLog.Info("  Valid: %d, invalid: %d", len(validation.Valid), len(validation.Invalid))
 
 			// Show invalid examples
-			if len(validation.Invalid) > 0 {
-				fmt.Println("  Invalid examples:")
-				for i, item := range validation.Invalid {
-					if i >= 3 {
-						break
-					}
-					val := item.Validation
-					fmt.Printf("    - ID %d: %v\n", val.ElementID, val.Errors)
+			for i, item := range validation.Invalid {
+				if i >= 3 {
+					break
 				}
+				val := item.Validation
+				validateLog.Warn("  Invalid ID %d: %v", val.ElementID, val.Errors)
 			}
 		}
 	}
@@ -135,45 +160,101 @@ func (v *ElevationValidator) ValidateAll(data *EnrichedData) map[string]Validati
 	return results
 }
 
-func runValidate() error {
-	fmt.Println("\n" + string(repeat('=', 60)))
-	fmt.Println("STEP 4: VALIDATE - Checking elevation ranges (0-2600m)")
-	fmt.Println(string(repeat('=', 60)))
+// runValidate checks the enriched elevations against the range of country
+func runValidate(ws Workspace, country string) error {
+	started := time.Now()
+	if err := runStepStartHook(ws, StepValidate, country); err != nil {
+		return err
+	}
+	config := NewConfig()
+	config.LoadFromEnv()
+	elevationRange, err := resolveElevationRange(config, country)
+	if err != nil {
+		return err
+	}
+	minElevation, maxElevation := elevationRange.Min, elevationRange.Max
+
+	validateLog.Banner("STEP 4: VALIDATE - Checking elevation ranges (%g-%gm)", minElevation, maxElevation)
 
 	// Load enriched data
 	var data EnrichedData
-	if err := loadJSON("output/osm_data_enriched.json", &data); err != nil {
-		return fmt.Errorf("output/osm_data_enriched.json not found. Run --enrich first: %v", err)
+	enrichedFile := ws.File(DefaultEnrichedDataFile)
+	if err := loadPipelineFile(enrichedFile, SchemaEnriched, &data); err != nil {
+		return pipelineLoadError(enrichedFile, "enrich", err)
 	}
 
 	// Validate
-	validator := NewElevationValidator(0, 2600)
+	validator := NewElevationValidator(minElevation, maxElevation)
+	validator.MaxSlope = config.GetFloat("SLOPE_MAX_DEG")
+	format, err := resolveElevationFormat(config)
+	if err != nil {
+		return err
+	}
+	validator.Format = &format
 	results := validator.ValidateAll(&data)
 
 	// Save validation results
-	output := ValidatedData{
-		TrainStations: ValidatedCategory{
-			ValidCount:    len(results["train_stations"].Valid),
-			InvalidCount:  len(results["train_stations"].Invalid),
-			ValidElements: results["train_stations"].Valid,
-		},
-		AlpineHuts: ValidatedCategory{
-			ValidCount:    len(results["alpine_huts"].Valid),
-			InvalidCount:  len(results["alpine_huts"].Invalid),
-			ValidElements: results["alpine_huts"].Valid,
-		},
-		OtherAccommodations: ValidatedCategory{
-			ValidCount:    len(results["other_accommodations"].Valid),
-			InvalidCount:  len(results["other_accommodations"].Invalid),
-			ValidElements: results["other_accommodations"].Valid,
-		},
-	}
-
-	if err := saveJSON("output/osm_data_validated.json", output); err != nil {
+	var output ValidatedData
+	for _, key := range categoryKeys {
+		*output.Category(key) = ValidatedCategory{
+			ValidCount:    len(results[key].Valid),
+			InvalidCount:  len(results[key].Invalid),
+			ValidElements: results[key].Valid,
+		}
+	}
+
+	validatedFile := ws.File(DefaultValidatedDataFile)
+	if err := savePipelineFile(validatedFile, SchemaValidated, &output); err != nil {
 		return err
 	}
 
-	fmt.Println("\n✓ Validation complete! Results saved to output/osm_data_validated.json")
+	validateLog.Info("✓ Validation complete! Results saved to %s", validatedFile)
+
+	invalid := make(map[string][]InvalidElement)
+	for _, key := range categoryKeys {
+		invalid[key] = results[key].Invalid
+	}
+	challengeFile := ws.File(DefaultMapRouletteFile)
+	tasks, skipped, err := ExportMapRouletteChallenge(invalid, challengeFile)
+	if err != nil {
+		return fmt.Errorf("failed to write MapRoulette challenge: %v", err)
+	}
+	if skipped > 0 {
+		validateLog.Info("✓ %d invalid elements saved as MapRoulette tasks to %s (%d without coordinates skipped)", tasks, challengeFile, skipped)
+	} else {
+		validateLog.Info("✓ %d invalid elements saved as MapRoulette tasks to %s", tasks, challengeFile)
+	}
+
+	recordPipelineState(ws, func(store *PipelineStore) error {
+		valid := make(map[string][]OSMElement)
+		invalidElements := make(map[string][]OSMElement)
+		reasons := make(map[string]string)
+		for _, key := range categoryKeys {
+			valid[key] = results[key].Valid
+			for _, item := range results[key].Invalid {
+				invalidElements[key] = append(invalidElements[key], item.Element)
+				reasons[elementKey(item.Element.Type, item.Element.ID)] = strings.Join(item.Validation.Errors, "; ")
+			}
+		}
+		if err := store.Record(StateValidated, valid); err != nil {
+			return err
+		}
+		return store.RecordErrors(StateInvalid, invalidElements, reasons)
+	})
+	exportProblemElements(ws)
+
+	counts := categoryCounts(func(key string) int {
+		return output.Category(key).ValidCount
+	})
+	counts["invalid"] = 0
+	for _, key := range categoryKeys {
+		counts["invalid"] += output.Category(key).InvalidCount
+	}
+	recordManifestStep(ws, ManifestStep{
+		Step:    StepValidate,
+		Country: country,
+		Counts:  counts,
+	}, started, []string{enrichedFile}, []string{validatedFile, challengeFile})
 
 	return nil
 }
@@ -39,7 +39,7 @@ func (v *ElementValidatorImpl) Validate(element OSMElement) (bool, string) {
 	}
 	
 	// Check element type
-	if element.Type != "node" && element.Type != "way" {
+	if element.Type != "node" && element.Type != "way" && element.Type != "relation" {
 		errors = append(errors, fmt.Sprintf("invalid element type: %s", element.Type))
 	}
 	
@@ -73,8 +73,7 @@ func (v *ElementValidatorImpl) ValidateElevation(element OSMElement) (bool, stri
 	}
 	
 	// Check elevation source
-	eleSource := element.Tags["ele:source"]
-	if eleSource == "" {
+	if _, eleSource := eleSourceTag(element.Tags); eleSource == "" {
 		return false, "missing ele:source tag"
 	}
 	
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Pipeline data files of the default workspace
+const (
+	DefaultRawDataFile       = "output/osm_data_raw.json"
+	DefaultFilteredDataFile  = "output/osm_data_filtered.json"
+	DefaultEnrichedDataFile  = "output/osm_data_enriched.json"
+	DefaultNoDataFile        = "output/osm_data_nodata.json"
+	DefaultValidatedDataFile = "output/osm_data_validated.json"
+	DefaultCSVFile           = "output/elevation_data.csv"
+)
+
+// intermediateFiles are the pipeline data files stored as .json.gz with GZIP_INTERMEDIATE
+var intermediateFiles = map[string]bool{
+	DefaultRawDataFile:       true,
+	DefaultFilteredDataFile:  true,
+	DefaultEnrichedDataFile:  true,
+	DefaultNoDataFile:        true,
+	DefaultValidatedDataFile: true,
+}
+
+// Workspace is the directory holding the pipeline files of one run. Countries
+// processed by a global run each get their own workspace so they can run in parallel.
+type Workspace struct {
+	Dir string
+	// Paths relocates individual files anywhere, keyed by their default path (--input, --output)
+	Paths map[string]string
+	// Gzip compresses the intermediate data files
+	Gzip bool
+}
+
+// DefaultWorkspace is used by single-country runs
+var DefaultWorkspace = Workspace{Dir: "output"}
+
+// CountryWorkspace returns the isolated workspace of a country in a global run
+func CountryWorkspace(country string) Workspace {
+	return Workspace{
+		Dir:  filepath.Join(DefaultWorkspace.Dir, "countries", safeFileName(country)),
+		Gzip: DefaultWorkspace.Gzip,
+	}
+}
+
+// File relocates one of the default output files (e.g. DefaultRunLedgerFile) into the workspace
+func (w Workspace) File(defaultPath string) string {
+	if path, ok := w.Paths[defaultPath]; ok {
+		return path
+	}
+	path := defaultPath
+	if w.Dir != "" {
+		path = filepath.Join(w.Dir, filepath.Base(defaultPath))
+	}
+	if w.Gzip && intermediateFiles[defaultPath] {
+		path += gzipSuffix
+	}
+	return path
+}
+
+// Create makes sure the workspace directory exists
+func (w Workspace) Create() error {
+	if err := os.MkdirAll(w.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	return nil
+}
+
+// safeFileName replaces characters that are not allowed in file names
+func safeFileName(name string) string {
+	replacer := strings.NewReplacer("/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_")
+	name = strings.TrimSpace(replacer.Replace(name))
+	if name == "" || name == "." || name == ".." {
+		return "_"
+	}
+	return name
+}
+
+// SetFile points one of the default files at path instead of the workspace directory
+func (w *Workspace) SetFile(defaultPath, path string) {
+	if w.Paths == nil {
+		w.Paths = make(map[string]string)
+	}
+	w.Paths[defaultPath] = path
+}
+
+// registerWorkspaceFlag adds --workspace and returns a function that applies it when given
+func registerWorkspaceFlag(fs *flag.FlagSet) func() {
+	dir := fs.String("workspace", "", "Directory holding the pipeline files, to keep several datasets apart (default: WORKSPACE or output)")
+	return func() {
+		if flagWasSet(fs, "workspace") {
+			flagConfig.Set("WORKSPACE", *dir)
+		}
+	}
+}
+
+// useWorkspace makes the WORKSPACE directory the default workspace and applies GZIP_INTERMEDIATE
+func useWorkspace(config *Config) {
+	if dir := strings.TrimSpace(config.Get("WORKSPACE")); dir != "" {
+		DefaultWorkspace.Dir = filepath.Clean(dir)
+	}
+	DefaultWorkspace.Gzip = config.GetBool("GZIP_INTERMEDIATE")
+}
+
+// registerStepFileFlags adds --input and --output to a step command, relocating the default
+// file the step reads and the one it writes; a step without one passes "". The returned
+// function applies the flags to the default workspace and creates its directory.
+func registerStepFileFlags(fs *flag.FlagSet, input, output string) func() error {
+	var inputFile, outputFile *string
+	if input != "" {
+		inputFile = fs.String("input", "", "Read "+filepath.Base(input)+" from this file instead of the workspace")
+	}
+	if output != "" {
+		outputFile = fs.String("output", "", "Write "+filepath.Base(output)+" to this file instead of the workspace")
+	}
+	return func() error {
+		if inputFile != nil && *inputFile != "" {
+			DefaultWorkspace.SetFile(input, *inputFile)
+		}
+		if outputFile != nil && *outputFile != "" {
+			if err := os.MkdirAll(filepath.Dir(*outputFile), 0755); err != nil {
+				return fmt.Errorf("failed to create output directory: %v", err)
+			}
+			DefaultWorkspace.SetFile(output, *outputFile)
+		}
+		return DefaultWorkspace.Create()
+	}
+}
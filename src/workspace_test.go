@@ -0,0 +1,114 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWorkspaceFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		ws       Workspace
+		file     string
+		expected string
+	}{
+		{"Zero value uses defaults", Workspace{}, DefaultRunLedgerFile, DefaultRunLedgerFile},
+		{"Default workspace", DefaultWorkspace, DefaultRawDataFile, "output/osm_data_raw.json"},
+		{"Country workspace", CountryWorkspace("România"), DefaultValidatedDataFile, "output/countries/România/osm_data_validated.json"},
+		{"Unsafe name", CountryWorkspace("Bosnia/Herzegovina"), DefaultCSVFile, "output/countries/Bosnia_Herzegovina/elevation_data.csv"},
+		{"Dot name", CountryWorkspace(".."), DefaultUndoLogFile, "output/countries/_/undo_log.jsonl"},
+		{"Relocated file", Workspace{Dir: "output", Paths: map[string]string{DefaultRawDataFile: "data/raw.json"}}, DefaultRawDataFile, "data/raw.json"},
+		{"Gzip intermediate", Workspace{Dir: "output", Gzip: true}, DefaultEnrichedDataFile, "output/osm_data_enriched.json.gz"},
+		{"Gzip leaves other files", Workspace{Dir: "output", Gzip: true}, DefaultRunLedgerFile, "output/run_ledger.json"},
+		{"Other files stay", Workspace{Dir: "output", Paths: map[string]string{DefaultRawDataFile: "data/raw.json"}}, DefaultFilteredDataFile, "output/osm_data_filtered.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.ws.File(tt.file); got != filepath.FromSlash(tt.expected) {
+				t.Errorf("File(%q) = %q, expected %q", tt.file, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestStepFileFlags(t *testing.T) {
+	saved := DefaultWorkspace
+	t.Cleanup(func() {
+		DefaultWorkspace = saved
+		flagConfig = NewConfig()
+	})
+	dir := t.TempDir()
+
+	fs := flag.NewFlagSet("filter", flag.ContinueOnError)
+	applyWorkspace := registerWorkspaceFlag(fs)
+	applyFiles := registerStepFileFlags(fs, DefaultRawDataFile, DefaultFilteredDataFile)
+	output := filepath.Join(dir, "datasets", "filtered.json")
+	if err := fs.Parse([]string{"--workspace", filepath.Join(dir, "ws"), "--input", "raw.json", "--output", output}); err != nil {
+		t.Fatal(err)
+	}
+	applyWorkspace()
+	config := NewConfig()
+	config.LoadFromEnv()
+	useWorkspace(config)
+	if err := applyFiles(); err != nil {
+		t.Fatalf("applyFiles() error = %v", err)
+	}
+
+	if got := DefaultWorkspace.File(DefaultRawDataFile); got != "raw.json" {
+		t.Errorf("input = %q, want raw.json", got)
+	}
+	if got := DefaultWorkspace.File(DefaultFilteredDataFile); got != output {
+		t.Errorf("output = %q, want %q", got, output)
+	}
+	if got, want := DefaultWorkspace.File(DefaultEnrichedDataFile), filepath.Join(dir, "ws", "osm_data_enriched.json"); got != want {
+		t.Errorf("other file = %q, want %q", got, want)
+	}
+	for _, path := range []string{filepath.Dir(output), filepath.Join(dir, "ws")} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("directory %s not created: %v", path, err)
+		}
+	}
+	if got, want := CountryWorkspace("Moldova").Dir, filepath.Join(dir, "ws", "countries", "Moldova"); got != want {
+		t.Errorf("country workspace = %q, want %q", got, want)
+	}
+}
+
+func TestSummarizeCountry(t *testing.T) {
+	ws := Workspace{Dir: t.TempDir()}
+
+	validated := ValidatedData{
+		Peaks:         ValidatedCategory{ValidCount: 3},
+		TrainStations: ValidatedCategory{ValidCount: 2},
+	}
+	if err := savePipelineFile(ws.File(DefaultValidatedDataFile), SchemaValidated, &validated); err != nil {
+		t.Fatal(err)
+	}
+	stats := map[string]UploadStats{
+		"peaks":          {Total: 3, Successful: 3},
+		"train_stations": {Total: 2, Successful: 1, Failed: 1},
+	}
+	if err := saveJSON(ws.File(DefaultUploadResultsFile), UploadResults{Stats: stats}); err != nil {
+		t.Fatal(err)
+	}
+
+	result := summarizeCountry("Moldova", ws, false)
+	if result.ValidElements != 5 || result.Uploaded != 4 || result.FailedUploads != 1 {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	dryRun := summarizeCountry("Moldova", ws, true)
+	if dryRun.ValidElements != 5 || dryRun.Uploaded != 0 {
+		t.Errorf("Expected upload results to be ignored in dry-run, got %+v", dryRun)
+	}
+
+	var summary GlobalSummary
+	result.Success = true
+	summary.Add(result)
+	summary.Add(CountryResult{Country: "Narnia", Error: "extract failed"})
+	if summary.Successful != 1 || summary.Failed != 1 || summary.ValidElements != 5 || summary.Uploaded != 4 {
+		t.Errorf("Unexpected summary: %+v", summary)
+	}
+}